@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/stack"
+	"github.com/alinemone/go-port-forward/internal/status"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// runApplyCommand brings the config and the running session in line with a
+// stack file: its services and groups are saved, and the session starts what
+// the file runs and stops the rest. With no session running it starts one.
+// dryRun only prints what would change.
+func runApplyCommand(file string, dryRun bool) {
+	if file == "" {
+		fmt.Println("Usage: pf apply -f <stack.json> [--dry-run]")
+		os.Exit(exitError)
+	}
+	var raw []byte
+	var err error
+	if file == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(file)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	s, err := stack.Parse(raw)
+	if err != nil {
+		fatal(err)
+	}
+
+	st := storage.NewStorage()
+	data, err := st.LoadData()
+	if err != nil {
+		fatal(err)
+	}
+	plan, err := stack.Make(data, s, nil)
+	if err != nil {
+		fatal(err)
+	}
+	// The session to reconcile is the one already running most of the stack.
+	dir, _ := status.Dir()
+	sessions, _ := status.ReadSessions(dir, time.Now())
+	session, _, running := status.FindFailed(sessions, plan.Desired)
+	if running {
+		names := make([]string, 0, len(session.Services))
+		for _, svc := range session.Services {
+			names = append(names, svc.Name)
+		}
+		if plan, err = stack.Make(data, s, names); err != nil {
+			fatal(err)
+		}
+	}
+
+	if plan.Empty() {
+		lipgloss.Println(cliMuted.Render("Nothing to change"))
+		return
+	}
+	printPlan(plan, running)
+	if dryRun {
+		lipgloss.Println(cliMuted.Render("Dry run: nothing was changed"))
+		return
+	}
+
+	if len(plan.Config) > 0 {
+		if err := st.SaveData(plan.Merged); err != nil {
+			fatal(err)
+		}
+		fmt.Printf("✓ Saved %d services and groups\n", len(plan.Config))
+	}
+	switch {
+	case running && (len(plan.Start) > 0 || len(plan.Stop) > 0 || len(plan.Restart) > 0):
+		r := status.Request{
+			Start: append(append([]string(nil), plan.Start...), plan.Restart...),
+			Stop:  append(append([]string(nil), plan.Stop...), plan.Restart...),
+		}
+		if err := sendSessionRequest(dir, session, r); err != nil {
+			fatal(err)
+		}
+		fmt.Printf("✓ Updated the session running '%s'\n", session.Label)
+	case !running && len(s.Run) > 0:
+		runStartCommand([]string{strings.Join(s.Run, ",")}, runOptions{})
+	}
+}
+
+// printPlan lists what applying a stack changes, in the config first and then
+// in the session (a new one when running is false).
+func printPlan(plan *stack.Plan, running bool) {
+	items := make([][2]string, 0, len(plan.Config)+len(plan.Start)+len(plan.Stop)+len(plan.Restart))
+	for _, c := range plan.Config {
+		items = append(items, [2]string{c.Action + " " + c.Kind + " " + c.Name, c.Detail})
+	}
+	where := "in the running session"
+	if !running {
+		where = "in a new session"
+	}
+	for _, name := range plan.Start {
+		items = append(items, [2]string{"start " + name, where})
+	}
+	for _, name := range plan.Stop {
+		items = append(items, [2]string{"stop " + name, "not in the stack"})
+	}
+	for _, name := range plan.Restart {
+		items = append(items, [2]string{"restart " + name, "its local port changes"})
+	}
+	printList("Apply", fmt.Sprintf("(%d changes)", len(items)), items)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/x/term"
+
+	"github.com/alinemone/go-port-forward/internal/cast"
+)
+
+// recordingTerminal is the terminal the TUI draws on, with everything drawn
+// also going to a recording. It still passes for a terminal (term.File), so
+// bubbletea sizes it and follows its resizes as usual.
+type recordingTerminal struct {
+	*os.File
+	rec *cast.Recorder
+}
+
+func (t recordingTerminal) Write(p []byte) (int, error) {
+	if w, h, err := term.GetSize(t.Fd()); err == nil {
+		t.rec.Resize(w, h)
+	}
+	t.rec.Output(p)
+	return t.File.Write(p)
+}
+
+// startRecording records the TUI to path for --record, as an asciinema
+// recording (see package cast) titled title, and returns the program options
+// that draw through it. The returned func ends the recording and says where
+// it is. "" records nothing.
+func startRecording(path, title string) ([]tea.ProgramOption, func(), error) {
+	if path == "" {
+		return nil, func() {}, nil
+	}
+	width, height, err := term.GetSize(os.Stdout.Fd())
+	if err != nil {
+		return nil, nil, fmt.Errorf("--record needs a terminal to record: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	rec, err := cast.New(f, width, height, title, time.Now())
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	stop := func() {
+		err := rec.Err()
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Printf("Warning: the recording in %s is incomplete: %v\n", path, err)
+			return
+		}
+		fmt.Printf("Session recorded to %s (asciinema play %s)\n", path, path)
+	}
+	return []tea.ProgramOption{tea.WithOutput(recordingTerminal{File: os.Stdout, rec: rec})}, stop, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/catalog"
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// catalogSyncTimeout bounds one catalog refresh, so an unreachable catalog
+// server delays a run by seconds at most; the local copy is used instead.
+const catalogSyncTimeout = 3 * time.Second
+
+// runCatalogCommand lists the configured remote catalogs, with "sync"
+// refreshes their local copies (insecure accepts ones whose signature fails),
+// and with "trust" or "untrust" approves catalog services their allow list
+// refuses, or takes that back.
+func runCatalogCommand(args []string, insecure bool) {
+	st := storage.NewStorage()
+	if insecure && (len(args) != 1 || args[0] != "sync") {
+		fmt.Println("Error: --insecure only goes with pf catalog sync")
+		os.Exit(exitError)
+	}
+	switch {
+	case len(args) == 1 && args[0] == "sync":
+		syncCatalogs(os.Stdout, st, nil, true, insecure)
+		return
+	case len(args) > 1 && (args[0] == "trust" || args[0] == "untrust"):
+		trustCatalogServices(st, args[1:], args[0] == "trust")
+		return
+	case len(args) > 0:
+		fmt.Println("Usage: pf catalog [sync | trust <catalog/service>... | untrust <catalog/service>...]")
+		os.Exit(exitError)
+	}
+
+	catalogs, err := st.Catalogs()
+	if err != nil {
+		fatal(err)
+	}
+	if len(catalogs) == 0 {
+		lipgloss.Println(cliMuted.Render("No catalogs configured (add them under \"catalogs\" with pf edit)"))
+		return
+	}
+	items := make([][2]string, 0, len(catalogs))
+	var refused [][2]string
+	for _, c := range catalogs {
+		detail := c.URL + " · never fetched"
+		remote, fetched, err := catalog.Load(st.CatalogDir(), c.Name, c.PublicKey)
+		if errors.Is(err, catalog.ErrUnverified) {
+			detail = fmt.Sprintf("%s · copy from %s not verified, its services are off (pf catalog sync)", c.URL, fetched.Format("2006-01-02 15:04"))
+		}
+		if err == nil {
+			detail = fmt.Sprintf("%s · %d services, %d groups · checked %s", c.URL, len(remote.Services), len(remote.Groups), fetched.Format("2006-01-02 15:04"))
+			for _, name := range slices.Sorted(maps.Keys(remote.Services)) {
+				command := remote.Services[name]
+				if err := c.Policy().Check(name, command); err != nil && !cmdline.IsMonitor(command) {
+					refused = append(refused, [2]string{c.Name + "/" + name, err.Error()})
+				}
+			}
+		}
+		if c.PublicKey != "" {
+			detail += " · signed"
+		}
+		if len(c.Allow) > 0 {
+			detail += " · allows " + strings.Join(c.Allow, ", ")
+		}
+		items = append(items, [2]string{c.Name, detail})
+	}
+	printList("Catalogs", fmt.Sprintf("(%d)", len(items)), items)
+	if len(refused) > 0 {
+		printList("Refused", "(pf catalog trust <name> to run one anyway)", refused)
+	}
+}
+
+// trustCatalogServices approves the current commands of catalog services
+// despite their catalog's allow list, or with trust false takes that back.
+func trustCatalogServices(st *storage.Storage, names []string, trust bool) {
+	for _, name := range names {
+		if err := st.TrustCatalogService(name, trust); err != nil {
+			fatal(err)
+		}
+		if !trust {
+			fmt.Printf("✓ '%s' is no longer trusted\n", name)
+			continue
+		}
+		command, _ := st.LocalCommand(name)
+		fmt.Printf("✓ Trusted '%s' to run: %s\n", name, command)
+		lipgloss.Println(cliMuted.Render("  If the catalog changes this command, it is refused again."))
+	}
+}
+
+// syncCatalogs refreshes the local copies of the named catalogs, or of all
+// configured ones when names is nil. A catalog that cannot be reached, or
+// fails its signature check, only gets a warning on w: its last copy stays in
+// use, unless its public key did not verify that copy either. verbose also reports successes; insecure accepts a catalog whose
+// signature is missing or wrong.
+func syncCatalogs(w io.Writer, st *storage.Storage, names []string, verbose, insecure bool) {
+	catalogs, err := st.Catalogs()
+	if err != nil {
+		return // the command reports a broken config itself
+	}
+	for _, c := range catalogs {
+		if names != nil && !slices.Contains(names, c.Name) {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), catalogSyncTimeout)
+		changed, err := catalog.Sync(ctx, http.DefaultClient, st.CatalogDir(), c.Name, c.URL, catalog.Verification{PublicKey: c.PublicKey, Insecure: insecure})
+		cancel()
+		switch {
+		case err != nil:
+			_, fetched, loadErr := catalog.Load(st.CatalogDir(), c.Name, c.PublicKey)
+			switch {
+			case loadErr == nil:
+				fmt.Fprintf(w, "! Catalog '%s' could not be synced, using its copy from %s: %v\n", c.Name, fetched.Format("2006-01-02 15:04"), err)
+			case errors.Is(loadErr, catalog.ErrUnverified):
+				fmt.Fprintf(w, "! Catalog '%s' could not be synced, and its copy from %s is not verified by its public key, so its services are off: %v\n", c.Name, fetched.Format("2006-01-02 15:04"), err)
+			default:
+				fmt.Fprintf(w, "! Catalog '%s' could not be synced and has no local copy: %v\n", c.Name, err)
+			}
+		case verbose && changed:
+			fmt.Fprintf(w, "✓ Catalog '%s' updated\n", c.Name)
+		case verbose:
+			fmt.Fprintf(w, "✓ Catalog '%s' is up to date\n", c.Name)
+		}
+	}
+}
+
+// catalogsNamedIn returns the catalogs that run targets in input belong to
+// ("corp" for corp/db), so a run only refreshes the catalogs it uses.
+func catalogsNamedIn(input string) []string {
+	var names []string
+	for _, target := range strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		if name, _, ok := storage.SplitCatalogName(target); ok && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
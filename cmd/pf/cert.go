@@ -13,13 +13,13 @@ func runCertAddCommand(certMgr *cert.Manager, args []string) {
 	if len(args) < 1 {
 		fmt.Println("Usage: pf cert add <p12-file>")
 		fmt.Println("Example: pf cert add company-vpn.p12")
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	p12Path := args[0]
 	if _, err := os.Stat(p12Path); os.IsNotExist(err) {
 		fmt.Printf("Error: P12 file not found: %s\n", p12Path)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	var password string
@@ -27,8 +27,7 @@ func runCertAddCommand(certMgr *cert.Manager, args []string) {
 	fmt.Scanln(&password)
 
 	if err := certMgr.AddCertificate(p12Path, password); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
 	}
 
 	fmt.Println("✓ Certificate added successfully")
@@ -58,8 +57,7 @@ func runCertListCommand(certMgr *cert.Manager) {
 
 func runCertRemoveCommand(certMgr *cert.Manager) {
 	if err := certMgr.RemoveCertificate(); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
 	}
 
 	fmt.Println("✓ Certificate removed successfully")
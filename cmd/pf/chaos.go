@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/relay"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// runChaosCommand sets or clears the degradation injected into a relayed
+// service's traffic. Running sessions apply it within a second, to open
+// connections too. With no name it lists the services under chaos.
+func runChaosCommand(args []string, latency, jitter time.Duration, bandwidth, drop string, off bool) {
+	st := storage.NewStorage()
+	if len(args) == 0 {
+		printChaos(st)
+		return
+	}
+	if len(args) != 1 {
+		fmt.Println("Usage: pf chaos <name> [--latency 200ms] [--jitter 50ms] [--bandwidth 1mb] [--drop 1%] | --off")
+		os.Exit(exitError)
+	}
+
+	var cfg storage.ChaosConfig
+	if !off {
+		if latency > 0 {
+			cfg.Latency = latency.String()
+		}
+		if jitter > 0 {
+			cfg.Jitter = jitter.String()
+		}
+		if bandwidth != "" {
+			bw, err := relay.ParseBandwidth(bandwidth)
+			if err != nil {
+				fatal(err)
+			}
+			cfg.Bandwidth = bw
+		}
+		if drop != "" {
+			pct, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(drop), "%"), 64)
+			if err != nil || pct <= 0 || pct > 100 {
+				fmt.Printf("Error: invalid --drop %q (e.g. 1%%)\n", drop)
+				os.Exit(exitError)
+			}
+			cfg.Drop = pct
+		}
+		if cfg == (storage.ChaosConfig{}) {
+			fmt.Println("Error: give at least one of --latency, --jitter, --bandwidth, --drop (or --off)")
+			os.Exit(exitError)
+		}
+	}
+
+	name := args[0]
+	if err := st.SetChaos(name, cfg); err != nil {
+		fatal(err)
+	}
+	if off {
+		fmt.Printf("✓ Chaos off for '%s'\n", name)
+		return
+	}
+	fmt.Printf("✓ Chaos on for '%s': %s\n", name, describeChaos(cfg))
+}
+
+func describeChaos(c storage.ChaosConfig) string {
+	var parts []string
+	if c.Latency != "" {
+		parts = append(parts, c.Latency+" latency")
+	}
+	if c.Jitter != "" {
+		parts = append(parts, "±"+c.Jitter+" jitter")
+	}
+	if c.Bandwidth > 0 {
+		parts = append(parts, relay.FormatBandwidth(c.Bandwidth))
+	}
+	if c.Drop > 0 {
+		parts = append(parts, strconv.FormatFloat(c.Drop, 'g', -1, 64)+"% drop")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func printChaos(st *storage.Storage) {
+	configs, err := st.Chaos()
+	if err != nil {
+		fatal(err)
+	}
+	if len(configs) == 0 {
+		lipgloss.Println(cliMuted.Render("No services under chaos"))
+		return
+	}
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := make([][2]string, 0, len(names))
+	for _, name := range names {
+		items = append(items, [2]string{name, describeChaos(configs[name])})
+	}
+	printList("Chaos", fmt.Sprintf("(%d)", len(names)), items)
+}
@@ -2,13 +2,11 @@ package main
 
 import (
 	"fmt"
-	"os"
-	"os/exec"
-	"runtime"
 	"sort"
 	"strings"
 
-	"github.com/alinemone/go-port-forward/internal/manager"
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/proc"
 	"github.com/alinemone/go-port-forward/internal/storage"
 )
 
@@ -19,14 +17,15 @@ func runCleanupCommand(args []string) {
 			return
 		}
 		cleanupAllProcesses()
+		expireEphemeral(storage.NewStorage(), true)
 		return
 	}
 
 	st := storage.NewStorage()
+	expireEphemeral(st, true)
 	ports, err := configuredPorts(st)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
 	}
 
 	if len(ports) == 0 {
@@ -37,7 +36,7 @@ func runCleanupCommand(args []string) {
 
 	fmt.Printf("Freeing configured ports: %s\n", strings.Join(ports, ", "))
 	for _, port := range ports {
-		killed := manager.FreePort(port)
+		killed := proc.FreePort(port)
 		if len(killed) > 0 {
 			fmt.Printf("  • port %s: killed PID(s) %v\n", port, killed)
 		} else {
@@ -79,13 +78,7 @@ func confirm(prompt string) bool {
 func cleanupAllProcesses() {
 	fmt.Println("Cleaning up ALL kubectl and ssh processes...")
 
-	if runtime.GOOS == "windows" {
-		exec.Command("taskkill", "/F", "/IM", "kubectl.exe").Run()
-		exec.Command("taskkill", "/F", "/IM", "ssh.exe").Run()
-	} else {
-		exec.Command("pkill", "-9", "kubectl").Run()
-		exec.Command("pkill", "-9", "ssh").Run()
-	}
+	proc.KillNamed("kubectl", "ssh")
 
 	fmt.Println("✓ Cleanup complete")
 	fmt.Println("Note: This kills ALL kubectl and ssh processes")
@@ -106,7 +99,7 @@ func configuredPorts(st *storage.Storage) ([]string, error) {
 	seen := make(map[string]bool)
 	ports := make([]string, 0, len(names))
 	for _, name := range names {
-		local, _ := storage.ParsePortsFromCommand(services[name])
+		local, _ := cmdline.ParsePorts(services[name])
 		if local == "" || seen[local] {
 			continue
 		}
@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"charm.land/lipgloss/v2"
 
 	"github.com/alinemone/go-port-forward/internal/cert"
+	"github.com/alinemone/go-port-forward/internal/report"
+	"github.com/alinemone/go-port-forward/internal/schema"
 	"github.com/alinemone/go-port-forward/internal/storage"
 )
 
@@ -28,19 +31,25 @@ func newRootCmd() *cobra.Command {
 		// this Run only when the first arg isn't a known subcommand.
 		Run: func(cmd *cobra.Command, args []string) {
 			if len(args) == 0 {
+				// A first run with nothing configured gets a guide instead.
+				if st := storage.NewStorage(); stdinIsTerminal() && isFirstRun(st) {
+					runOnboarding(st)
+					return
+				}
 				showUsage()
 				return
 			}
 			if looksLikeRunTarget(storage.NewStorage(), strings.Join(args, " ")) {
-				runStartCommand(args)
+				runStartCommand(args, rootRunOpts)
 				return
 			}
 			lipgloss.Println(cliMuted.Render("Unknown command: " + args[0]))
 			lipgloss.Println(cliMuted.Render("Run 'pf help' for usage"))
-			os.Exit(1)
+			os.Exit(exitError)
 		},
 		ValidArgsFunction: completeServicesAndGroups,
 	}
+	addRunFlags(root, &rootRunOpts)
 	// Preserve our themed help for `pf`, `pf -h`, and `pf help`.
 	root.SetHelpFunc(func(*cobra.Command, []string) { showUsage() })
 
@@ -49,20 +58,24 @@ func newRootCmd() *cobra.Command {
 	root.CompletionOptions.DisableDefaultCmd = true
 
 	root.AddCommand(
-		newAddCmd(), newListCmd(), newRunCmd(), newRaCmd(), newDeleteCmd(),
+		newAddCmd(), newListCmd(), newRunCmd(), newRaCmd(), newExecCmd(), newDeleteCmd(), newRestoreCmd(),
 		newRenameCmd(), newKubectlCmd(), newCleanupCmd(), newUpdateCmd(),
-		newEditCmd(), newIconCmd(), newThemeCmd(), newVersionCmd(),
+		newEditCmd(), newIconCmd(), newThemeCmd(), newVersionCmd(), newStatusCmd(), newEnvCmd(), newMaintenanceCmd(), newDeprecateCmd(), newDisableCmd(), newEnableCmd(), newLabelCmd(), newEphemeralCmd(), newOverrideCmd(), newSwitchCmd(), newHistoryCmd(), newRollbackCmd(), newRecordCmd(), newReplayCmd(), newChaosCmd(), newDNSCmd(), newDockerCmd(), newSSHCmd(), newDiscoverCmd(), newCatalogCmd(), newLintCmd(), newReportCmd(), newSchemaCmd(), newSelftestCmd(), newProbesCmd(), newForwardersCmd(), newStateCmd(), newPruneCmd(), newApplyCmd(), newStatsCmd(), newLogsCmd(), newConfigCmd(),
 		newGroupCmd(), newCertCmd(), newCompletionCmd(),
 	)
 	return root
 }
 
 func newAddCmd() *cobra.Command {
-	return &cobra.Command{
+	var force, overwrite bool
+	c := &cobra.Command{
 		Use: "add", Aliases: []string{"a"}, Short: "Save a new service",
 		Args: cobra.ArbitraryArgs,
-		Run:  func(_ *cobra.Command, args []string) { runAddCommand(args) },
+		Run:  func(_ *cobra.Command, args []string) { runAddCommand(args, force, overwrite) },
 	}
+	c.Flags().BoolVar(&force, "force", false, "Save the command even if it looks broken")
+	c.Flags().BoolVar(&overwrite, "overwrite", false, "Replace a service of the same name without asking")
+	return c
 }
 
 func newListCmd() *cobra.Command {
@@ -72,20 +85,68 @@ func newListCmd() *cobra.Command {
 	}
 }
 
+// rootRunOpts holds the run flags given to a bare `pf <service|group>`.
+var rootRunOpts runOptions
+
+// addRunFlags registers the flags shared by every command that opens the TUI.
+func addRunFlags(c *cobra.Command, opts *runOptions) {
+	c.Flags().BoolVar(&opts.noConfirm, "no-confirm", false, "Don't ask before stop/restart/quit in the TUI")
+	c.Flags().StringVar(&opts.envFile, "env-file", "", "Keep a dotenv of live endpoints (PF_<NAME>_HOST/PORT/ADDR) at this path")
+	c.Flags().StringVar(&opts.metrics, "metrics-file", "", "Append each service's uptime, reconnects, errors and connect time on exit (CSV, or JSON Lines for .json)")
+	c.Flags().DurationVar(&opts.ttl, "ttl", 0, "Stop every forward after this long, e.g. 4h or 90m")
+	c.Flags().BoolVar(&opts.follow, "follow", false, "Start and stop forwards as the groups run (or, with all, the saved services) change in the config")
+	c.Flags().BoolVar(&opts.accessible, "accessible", false, "Print plain-text status lines and read typed commands instead of the TUI (also ACCESSIBLE=1)")
+	c.Flags().BoolVar(&opts.failFast, "fail-fast", false, "Run headless in plain-text mode and exit 6 as soon as a service fails (for CI)")
+	c.Flags().StringVar(&opts.profile, "profile", "", "Write a pprof profile of the session: cpu or mem (to pf-cpu.pprof or pf-mem.pprof)")
+	c.Flags().StringVar(&opts.record, "record", "", "Record the TUI to this file as an asciinema cast, e.g. session.cast")
+	c.Flags().BoolVar(&opts.lowPower, "low-power", false, "Poll and redraw less often, for small machines (e.g. a Raspberry Pi) running forwards around the clock")
+	c.Flags().StringArrayVar(&opts.set, "set", nil, "Change a field for this session only: namespace, context, local-port or remote-port (key=value, or name.key=value for one service)")
+}
+
 func newRunCmd() *cobra.Command {
-	return &cobra.Command{
+	var opts runOptions
+	c := &cobra.Command{
 		Use: "run", Aliases: []string{"r"}, Short: "Run services/groups in the live TUI",
 		Args:              cobra.ArbitraryArgs,
 		ValidArgsFunction: completeServicesAndGroups,
-		Run:               func(_ *cobra.Command, args []string) { runStartCommand(args) },
+		Run:               func(_ *cobra.Command, args []string) { runStartCommand(args, opts) },
 	}
+	addRunFlags(c, &opts)
+	c.Flags().BoolVar(&opts.fromStdin, "from-stdin", false, "Also run forwards defined on stdin as JSON lines ({\"name\": ..., \"command\": ...})")
+	c.Flags().BoolVar(&opts.onlyFailed, "only-failed", false, "Start only the services that failed into the session already running them")
+	c.Flags().BoolVar(&opts.demo, "demo", false, "Play scripted fake services instead of real forwards, for screenshots and trying the UI")
+	c.Flags().StringVarP(&opts.namespace, "namespace", "n", "", "Namespace whose Services --all-services forwards")
+	c.Flags().BoolVar(&opts.allServices, "all-services", false, "Forward every Service in --namespace on its own loopback address, with hosts-file entries, as they come and go")
+	return c
 }
 
 func newRaCmd() *cobra.Command {
-	return &cobra.Command{
+	var opts runOptions
+	c := &cobra.Command{
 		Use: "ra", Short: "Run every saved service",
-		Run: func(_ *cobra.Command, _ []string) { runStartCommand([]string{"all"}) },
+		Run: func(_ *cobra.Command, _ []string) { runStartCommand([]string{"all"}, opts) },
 	}
+	addRunFlags(c, &opts)
+	return c
+}
+
+func newExecCmd() *cobra.Command {
+	var timeout time.Duration
+	c := &cobra.Command{
+		Use: "exec", Aliases: []string{"x"}, Short: "Run a command with forwards up, then stop them",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeServicesAndGroups,
+		// Everything after "--" is the command, passed through untouched.
+		Run: func(c *cobra.Command, args []string) {
+			targets, command := args, []string(nil)
+			if dash := c.ArgsLenAtDash(); dash >= 0 {
+				targets, command = args[:dash], args[dash:]
+			}
+			runExecCommand(targets, command, timeout)
+		},
+	}
+	c.Flags().DurationVar(&timeout, "timeout", time.Minute, "How long to wait for the forwards to become healthy")
+	return c
 }
 
 func newDeleteCmd() *cobra.Command {
@@ -97,6 +158,21 @@ func newDeleteCmd() *cobra.Command {
 	}
 }
 
+func newRestoreCmd() *cobra.Command {
+	return &cobra.Command{
+		Use: "restore [name]", Short: "Bring back a deleted service, or list the trash",
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeTrashed,
+		Run: func(_ *cobra.Command, args []string) {
+			name := ""
+			if len(args) > 0 {
+				name = args[0]
+			}
+			runRestoreCommand(name)
+		},
+	}
+}
+
 func newRenameCmd() *cobra.Command {
 	return &cobra.Command{
 		Use: "rename", Aliases: []string{"ren", "mv"}, Short: "Rename a service or group",
@@ -134,7 +210,7 @@ func newUpdateCmd() *cobra.Command {
 
 func newEditCmd() *cobra.Command {
 	return &cobra.Command{
-		Use: "edit", Aliases: []string{"config"}, Short: "Bulk-edit services & groups in $EDITOR",
+		Use: "edit", Short: "Bulk-edit services & groups in $EDITOR",
 		Run: func(_ *cobra.Command, _ []string) { runEditCommand() },
 	}
 }
@@ -157,6 +233,432 @@ func newThemeCmd() *cobra.Command {
 	}
 }
 
+func newStatusCmd() *cobra.Command {
+	var format, group string
+	var check bool
+	c := &cobra.Command{
+		Use: "status", Aliases: []string{"st"}, Short: "Show forwards of running pf sessions",
+		Run: func(_ *cobra.Command, _ []string) { runStatusCommand(format, group, check) },
+	}
+	c.Flags().StringVarP(&format, "format", "f", "text", "Output format: text, waybar or json")
+	c.Flags().StringVarP(&group, "group", "g", "", "Only a started group's combined status; exits 1 unless healthy")
+	c.Flags().BoolVar(&check, "check", false, "Report pf's own health (live sessions, config access); exits 1 unless ready")
+	_ = c.RegisterFlagCompletionFunc("group", completeGroups)
+	_ = c.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"text", "waybar", "json"}, cobra.ShellCompDirectiveNoFileComp))
+	return c
+}
+
+func newEnvCmd() *cobra.Command {
+	var templatePath string
+	c := &cobra.Command{
+		Use: "env", Short: "Print live endpoints as dotenv or through a Go template",
+		Run: func(_ *cobra.Command, _ []string) { runEnvCommand(templatePath) },
+	}
+	c.Flags().StringVarP(&templatePath, "template", "t", "", "Go template file to render (- for stdin)")
+	return c
+}
+
+func newMaintenanceCmd() *cobra.Command {
+	var window time.Duration
+	var end bool
+	c := &cobra.Command{
+		Use: "maintenance", Aliases: []string{"mt"}, Short: "Hold off reconnects and alerts for a service while its backend is down",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeServices,
+		Run:               func(_ *cobra.Command, args []string) { runMaintenanceCommand(args, window, end) },
+	}
+	c.Flags().DurationVar(&window, "for", time.Hour, "How long the maintenance window lasts")
+	c.Flags().BoolVar(&end, "end", false, "End the maintenance window now")
+	return c
+}
+
+func newDeprecateCmd() *cobra.Command {
+	var d storage.Deprecation
+	var undo bool
+	c := &cobra.Command{
+		Use: "deprecate", Short: "Mark a service as deprecated, with a replacement and sunset date",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeServices,
+		Run:               func(_ *cobra.Command, args []string) { runDeprecateCommand(args, d, undo) },
+	}
+	c.Flags().StringVar(&d.Replacement, "use", "", "Service to use instead")
+	c.Flags().StringVar(&d.Sunset, "sunset", "", "Date the service goes away, YYYY-MM-DD")
+	c.Flags().StringVar(&d.Note, "note", "", "A short note, e.g. where to read more")
+	c.Flags().BoolVar(&undo, "undo", false, "Take the deprecation back")
+	return c
+}
+
+func newDisableCmd() *cobra.Command {
+	return &cobra.Command{
+		Use: "disable", Short: "Keep services saved but out of run all, groups and the add list",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeServices,
+		Run:               func(_ *cobra.Command, args []string) { runEnableCommand(args, false) },
+	}
+}
+
+func newEnableCmd() *cobra.Command {
+	return &cobra.Command{
+		Use: "enable", Short: "Enable disabled services again",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeServices,
+		Run:               func(_ *cobra.Command, args []string) { runEnableCommand(args, true) },
+	}
+}
+
+func newLabelCmd() *cobra.Command {
+	return &cobra.Command{
+		Use: "label", Short: "Label a service, to run it with others as label:key=value",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeServices,
+		Run:               func(_ *cobra.Command, args []string) { runLabelCommand(args) },
+	}
+}
+
+func newEphemeralCmd() *cobra.Command {
+	e := &cobra.Command{
+		Use: "ephemeral", Short: "List short-lived services made from a template",
+		Args: cobra.NoArgs,
+		Run:  func(_ *cobra.Command, _ []string) { runEphemeralListCommand() },
+	}
+	var template string
+	var sets []string
+	var ttl time.Duration
+	add := &cobra.Command{
+		Use: "add", Short: "Save a copy of a service with --set values, deleted after --ttl or by the next cleanup",
+		Args: cobra.ArbitraryArgs,
+		Run:  func(_ *cobra.Command, args []string) { runEphemeralAddCommand(args, template, sets, ttl) },
+	}
+	add.Flags().StringVar(&template, "from", "", "Service to copy")
+	add.Flags().StringArrayVar(&sets, "set", nil, "Field to change: namespace, context, local-port or remote-port (key=value)")
+	add.Flags().DurationVar(&ttl, "ttl", 0, "Delete the service after this long, e.g. 48h (default: at the next pf cleanup)")
+	_ = add.RegisterFlagCompletionFunc("from", completeServices)
+	e.AddCommand(add)
+	return e
+}
+
+func newOverrideCmd() *cobra.Command {
+	var o storage.Override
+	var drop bool
+	c := &cobra.Command{
+		Use: "override", Short: "Change a service on this machine only, e.g. its local port or kube context",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeServices,
+		Run:               func(_ *cobra.Command, args []string) { runOverrideCommand(args, o, drop) },
+	}
+	c.Flags().IntVar(&o.LocalPort, "port", 0, "Local port to forward on instead")
+	c.Flags().StringVar(&o.Context, "context", "", "Kube context to use instead")
+	c.Flags().StringVar(&o.Command, "command", "", "Command to run instead")
+	c.Flags().BoolVar(&drop, "clear", false, "Remove the override")
+	return c
+}
+
+func newPruneCmd() *cobra.Command {
+	var restore string
+	var archived bool
+	c := &cobra.Command{
+		Use: "prune", Short: "Delete or archive services that can no longer work here",
+		Args: cobra.NoArgs,
+		Run:  func(_ *cobra.Command, _ []string) { runPruneCommand(restore, archived) },
+	}
+	c.Flags().StringVar(&restore, "restore", "", "Bring an archived service back")
+	c.Flags().BoolVar(&archived, "archived", false, "List archived services")
+	return c
+}
+
+func newSwitchCmd() *cobra.Command {
+	var target, namespace string
+	c := &cobra.Command{
+		Use: "switch", Aliases: []string{"sw"}, Short: "Repoint a service at another target, keeping its local port",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeServices,
+		Run:               func(_ *cobra.Command, args []string) { runSwitchCommand(args, target, namespace) },
+	}
+	c.Flags().StringVar(&target, "to", "", "New kubectl resource (svc/name) or ssh host[:port]")
+	c.Flags().StringVarP(&namespace, "namespace", "n", "", "New kubectl namespace")
+	return c
+}
+
+func newHistoryCmd() *cobra.Command {
+	return &cobra.Command{
+		Use: "history", Short: "Show a service's earlier commands",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeServices,
+		Run:               func(_ *cobra.Command, args []string) { runHistoryCommand(args) },
+	}
+}
+
+func newRollbackCmd() *cobra.Command {
+	var to int
+	c := &cobra.Command{
+		Use: "rollback", Short: "Restore an earlier command of a service",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeServices,
+		Run:               func(_ *cobra.Command, args []string) { runRollbackCommand(args, to) },
+	}
+	c.Flags().IntVar(&to, "to", 1, "Which earlier command, as numbered by pf history (1 is the previous one)")
+	return c
+}
+
+func newRecordCmd() *cobra.Command {
+	var listen, out string
+	var payload bool
+	c := &cobra.Command{
+		Use: "record", Short: "Relay a running forward and record its traffic",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeServices,
+		Run:               func(_ *cobra.Command, args []string) { runRecordCommand(args, listen, out, payload) },
+	}
+	c.Flags().StringVar(&listen, "listen", "", "Port (or host:port) for clients to connect to")
+	c.Flags().StringVarP(&out, "out", "o", "", "Recording file (default <name>-<time>.pfrec)")
+	c.Flags().BoolVar(&payload, "payload", false, "Also keep the bytes, not just sizes and timings")
+	return c
+}
+
+func newChaosCmd() *cobra.Command {
+	var latency, jitter time.Duration
+	var bandwidth, drop string
+	var off bool
+	c := &cobra.Command{
+		Use: "chaos", Short: "Inject latency, bandwidth caps or disconnects into a relayed forward",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeServices,
+		Run: func(_ *cobra.Command, args []string) {
+			runChaosCommand(args, latency, jitter, bandwidth, drop, off)
+		},
+	}
+	c.Flags().DurationVar(&latency, "latency", 0, "Delay added to every chunk in each direction, e.g. 200ms")
+	c.Flags().DurationVar(&jitter, "jitter", 0, "Random extra delay, up to this")
+	c.Flags().StringVar(&bandwidth, "bandwidth", "", "Cap per connection and direction, e.g. 512kb or 1mb (per second)")
+	c.Flags().StringVar(&drop, "drop", "", "Chance that a chunk cuts its connection, e.g. 1%")
+	c.Flags().BoolVar(&off, "off", false, "Turn chaos off")
+	return c
+}
+
+func newDNSCmd() *cobra.Command {
+	var domains []string
+	c := &cobra.Command{
+		Use: "dns", Short: "Show how to resolve domains through a DNS service's relay",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeServices,
+		Run:               func(_ *cobra.Command, args []string) { runDNSCommand(args, domains) },
+	}
+	c.Flags().StringSliceVar(&domains, "domain", []string{"cluster.local"}, "Domains to resolve through the service")
+	return c
+}
+
+func newDockerCmd() *cobra.Command {
+	var network string
+	c := &cobra.Command{
+		Use: "docker", Short: "Publish running forwards in a Docker network until Ctrl+C",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeServiceList,
+		Run:               func(_ *cobra.Command, args []string) { runDockerCommand(args, network) },
+	}
+	c.Flags().StringVar(&network, "network", "", "Docker network to publish in, e.g. a compose project's myapp_default")
+	return c
+}
+
+func newSSHCmd() *cobra.Command {
+	var yes bool
+	c := &cobra.Command{
+		Use: "ssh", Short: "Pin the host key of a service's ssh destination with trust",
+		Args:      cobra.ArbitraryArgs,
+		ValidArgs: []string{"trust"},
+		Run:       func(_ *cobra.Command, args []string) { runSSHCommand(args, yes) },
+	}
+	c.Flags().BoolVar(&yes, "yes", false, "Pin the keys without asking, e.g. in CI")
+	return c
+}
+
+func newCatalogCmd() *cobra.Command {
+	var insecure bool
+	c := &cobra.Command{
+		Use: "catalog", Short: "List remote service catalogs, refresh them with sync, or trust refused services",
+		Args:      cobra.ArbitraryArgs,
+		ValidArgs: []string{"sync", "trust", "untrust"},
+		Run:       func(_ *cobra.Command, args []string) { runCatalogCommand(args, insecure) },
+	}
+	c.Flags().BoolVar(&insecure, "insecure", false, "With sync, accept catalogs whose signature is missing or wrong")
+	return c
+}
+
+func newLintCmd() *cobra.Command {
+	return &cobra.Command{
+		Use: "lint", Short: "Check stored services and groups for common problems",
+		Args: cobra.NoArgs,
+		Run:  func(_ *cobra.Command, _ []string) { runLintCommand() },
+	}
+}
+
+func newReportCmd() *cobra.Command {
+	var format string
+	var days int
+	c := &cobra.Command{
+		Use: "report", Short: "Sum up the catalog and list idle services and groups, duplicate targets and port collisions",
+		Args: cobra.NoArgs,
+		Run:  func(_ *cobra.Command, _ []string) { runReportCommand(format, days) },
+	}
+	c.Flags().StringVarP(&format, "format", "f", "text", "Output format: text or json")
+	c.Flags().IntVar(&days, "days", int(report.DefaultIdle/(24*time.Hour)), "List services and groups not run in this many days")
+	_ = c.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"text", "json"}, cobra.ShellCompDirectiveNoFileComp))
+	return c
+}
+
+func newSchemaCmd() *cobra.Command {
+	return &cobra.Command{
+		Use: "schema [name]", Short: "Print the JSON Schemas of pf status --format json, notification events and catalogs",
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: schema.Names(),
+		Run: func(_ *cobra.Command, args []string) {
+			name := ""
+			if len(args) > 0 {
+				name = args[0]
+			}
+			runSchemaCommand(name)
+		},
+	}
+}
+
+func newSelftestCmd() *cobra.Command {
+	var timeout time.Duration
+	c := &cobra.Command{
+		Use: "selftest", Short: "Check that pf can run, relay, health-check and restart a forward, with a local echo server",
+		Args: cobra.NoArgs,
+		Run:  func(_ *cobra.Command, _ []string) { runSelftestCommand(timeout) },
+	}
+	c.Flags().DurationVar(&timeout, "timeout", 15*time.Second, "How long each step waits on the forward")
+	return c
+}
+
+func newProbesCmd() *cobra.Command {
+	return &cobra.Command{
+		Use: "probes", Short: "List the probe plugins monitors can run",
+		Args: cobra.NoArgs,
+		Run:  func(_ *cobra.Command, _ []string) { runProbesCommand() },
+	}
+}
+
+func newForwardersCmd() *cobra.Command {
+	return &cobra.Command{
+		Use: "forwarders", Short: "List the forwarder plugins services can run",
+		Args: cobra.NoArgs,
+		Run:  func(_ *cobra.Command, _ []string) { runForwardersCommand() },
+	}
+}
+
+func newStateCmd() *cobra.Command {
+	var format string
+	c := &cobra.Command{
+		Use: "state", Short: "Show where pf keeps its files, their sizes and whether pf can use them",
+		Args: cobra.NoArgs,
+		Run:  func(_ *cobra.Command, _ []string) { runStateCommand(format) },
+	}
+	c.Flags().StringVarP(&format, "format", "f", "text", "Output format: text or json")
+	_ = c.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"text", "json"}, cobra.ShellCompDirectiveNoFileComp))
+	return c
+}
+
+func newStatsCmd() *cobra.Command {
+	s := &cobra.Command{
+		Use: "stats", Short: "Show how often forwards reconnected and why, this session and today",
+		Args: cobra.NoArgs,
+		Run:  func(_ *cobra.Command, _ []string) { runStatsCommand() },
+	}
+	var format, output string
+	export := &cobra.Command{
+		Use: "export", Short: "Write uptime, reconnects, errors and connect times of running forwards as CSV or JSON",
+		Args: cobra.NoArgs,
+		Run:  func(_ *cobra.Command, _ []string) { runStatsExportCommand(format, output) },
+	}
+	export.Flags().StringVarP(&format, "format", "f", "", "csv or json (JSON Lines); default from the -o extension, else csv")
+	export.Flags().StringVarP(&output, "output", "o", "", "Write to this file instead of stdout")
+	s.AddCommand(export)
+	return s
+}
+
+func newLogsCmd() *cobra.Command {
+	l := &cobra.Command{
+		Use: "logs", Short: "Export the logs of running forwards",
+		Args: cobra.ArbitraryArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			fmt.Println("Usage: pf logs export [--since 14:00] [--until 14:30] [--services db,api] [-o file]")
+			os.Exit(exitError)
+		},
+	}
+	var since, until, services, output string
+	export := &cobra.Command{
+		Use: "export", Short: "Write the merged, timestamped logs of running forwards",
+		Args: cobra.NoArgs,
+		Run:  func(_ *cobra.Command, _ []string) { runLogsExportCommand(since, until, services, output) },
+	}
+	export.Flags().StringVar(&since, "since", "", "Start of the range (14:00, 2006-01-02 14:00 or a duration like 30m)")
+	export.Flags().StringVar(&until, "until", "", "End of the range, same forms as --since")
+	export.Flags().StringVar(&services, "services", "", "Comma-separated services to include (default all)")
+	export.Flags().StringVarP(&output, "output", "o", "", "Write to this file instead of stdout")
+	l.AddCommand(export)
+	return l
+}
+
+// newConfigCmd is pf config: on its own the same as pf edit, which it long
+// was an alias of, and the parent of the commands that act on the config of
+// running sessions.
+func newConfigCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use: "config", Short: "Bulk-edit services & groups in $EDITOR, or reload running sessions' config",
+		Args: cobra.NoArgs,
+		Run:  func(_ *cobra.Command, _ []string) { runEditCommand() },
+	}
+	c.AddCommand(&cobra.Command{
+		Use: "reload", Short: "Apply notification and flap settings to running sessions",
+		Args: cobra.NoArgs,
+		Run:  func(_ *cobra.Command, _ []string) { runConfigReloadCommand() },
+	})
+	return c
+}
+
+func newApplyCmd() *cobra.Command {
+	var file string
+	var dryRun bool
+	c := &cobra.Command{
+		Use: "apply", Short: "Save a stack file's services and groups and run what it lists",
+		Args: cobra.NoArgs,
+		Run:  func(_ *cobra.Command, _ []string) { runApplyCommand(file, dryRun) },
+	}
+	c.Flags().StringVarP(&file, "file", "f", "", "Stack file to apply (- for stdin)")
+	c.Flags().BoolVar(&dryRun, "dry-run", false, "Only print what would change")
+	return c
+}
+
+func newDiscoverCmd() *cobra.Command {
+	var namespace string
+	var fromAnnotations, save bool
+	c := &cobra.Command{
+		Use: "discover", Short: "Find forwards declared by annotated Services in a namespace",
+		Args: cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			runDiscoverCommand(namespace, fromAnnotations, save)
+		},
+	}
+	c.Flags().StringVarP(&namespace, "namespace", "n", "", "Namespace to scan (default: kubectl's current one)")
+	c.Flags().BoolVar(&fromAnnotations, "from-annotations", false, "Read forwards from pf.dev/local-port annotations")
+	c.Flags().BoolVar(&save, "save", false, "Add the discovered services to the catalog")
+	return c
+}
+
+func newReplayCmd() *cobra.Command {
+	var listen string
+	var timing bool
+	c := &cobra.Command{
+		Use: "replay", Short: "Serve a recording as a local mock of the remote end",
+		Args: cobra.ArbitraryArgs,
+		Run:  func(_ *cobra.Command, args []string) { runReplayCommand(args, listen, timing) },
+	}
+	c.Flags().StringVar(&listen, "listen", "", "Port (or host:port) to serve on")
+	c.Flags().BoolVar(&timing, "timing", false, "Keep the recorded delays between responses")
+	return c
+}
+
 func newVersionCmd() *cobra.Command {
 	return &cobra.Command{
 		Use: "version", Aliases: []string{"v"}, Short: "Show build version details",
@@ -175,18 +677,54 @@ func newGroupCmd() *cobra.Command {
 				fmt.Printf("Unknown group command: %s\n", args[0])
 			}
 			showGroupUsage()
-			os.Exit(1)
+			os.Exit(exitError)
 		},
 	}
 	g.SetHelpFunc(func(*cobra.Command, []string) { showGroupUsage() })
 
-	g.AddCommand(
-		&cobra.Command{
-			Use: "add", Aliases: []string{"a"}, Short: "Create a group",
-			Args:              cobra.ArbitraryArgs,
-			ValidArgsFunction: completeServiceList,
-			Run:               func(_ *cobra.Command, args []string) { runGroupAddCommand(storage.NewStorage(), args) },
+	var overwrite bool
+	add := &cobra.Command{
+		Use: "add", Aliases: []string{"a"}, Short: "Create a group",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeServiceList,
+		Run:               func(_ *cobra.Command, args []string) { runGroupAddCommand(storage.NewStorage(), args, overwrite) },
+	}
+	add.Flags().BoolVar(&overwrite, "overwrite", false, "Replace a group of the same name without asking")
+
+	var unset bool
+	def := &cobra.Command{
+		Use: "default", Short: "Show or set the group a bare pf run starts",
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeGroups,
+		Run:               func(_ *cobra.Command, args []string) { runGroupDefaultCommand(storage.NewStorage(), args, unset) },
+	}
+	def.Flags().BoolVar(&unset, "unset", false, "Clear the default group")
+
+	var output string
+	export := &cobra.Command{
+		Use: "export", Short: "Write groups as JSON, without their services, to move them to another machine",
+		Args:              cobra.ArbitraryArgs,
+		ValidArgsFunction: completeGroups,
+		Run:               func(_ *cobra.Command, args []string) { runGroupExportCommand(storage.NewStorage(), args, output) },
+	}
+	export.Flags().StringVarP(&output, "output", "o", "", "File to write (default stdout)")
+
+	var createMissing, overwriteGroups bool
+	imp := &cobra.Command{
+		Use: "import", Short: "Save groups written by pf group export",
+		Args: cobra.ArbitraryArgs,
+		Run: func(_ *cobra.Command, args []string) {
+			runGroupImportCommand(storage.NewStorage(), args, createMissing, overwriteGroups)
 		},
+	}
+	imp.Flags().BoolVar(&createMissing, "create-missing", false, "Add disabled stub services for members not saved here")
+	imp.Flags().BoolVar(&overwriteGroups, "overwrite", false, "Replace groups that exist with other services")
+
+	g.AddCommand(
+		add,
+		def,
+		export,
+		imp,
 		&cobra.Command{
 			Use: "add-service", Aliases: []string{"addsvc", "as"}, Short: "Add services to a group",
 			Args:              cobra.ArbitraryArgs,
@@ -225,7 +763,7 @@ func mustCertManager() *cert.Manager {
 	m, err := cert.NewManager()
 	if err != nil {
 		fmt.Printf("Error: Failed to initialize certificate manager: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	return m
 }
@@ -239,7 +777,7 @@ func newCertCmd() *cobra.Command {
 				fmt.Printf("Unknown cert command: %s\n", args[0])
 			}
 			showCertUsage()
-			os.Exit(1)
+			os.Exit(exitError)
 		},
 	}
 	c.SetHelpFunc(func(*cobra.Command, []string) { showCertUsage() })
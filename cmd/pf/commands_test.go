@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+// TestConfigReloadIsReachable guards against pf config resolving to another
+// command, as it did while it was an alias of pf edit.
+func TestConfigReloadIsReachable(t *testing.T) {
+	for name, args := range map[string][]string{
+		"reload": {"config", "reload"},
+		"config": {"config"},
+		"edit":   {"edit"},
+	} {
+		c, _, err := newRootCmd().Find(args)
+		if err != nil {
+			t.Fatalf("Find(%v): %v", args, err)
+		}
+		if c.Name() != name || (name == "reload" && c.Parent().Name() != "config") {
+			t.Errorf("Find(%v) = %s, want %s", args, c.CommandPath(), name)
+		}
+	}
+}
@@ -23,6 +23,21 @@ func serviceNames() []string {
 	return names
 }
 
+// remoteServiceNames returns the services of remote catalogs as "catalog/name",
+// from their local copies (sorted, best-effort).
+func remoteServiceNames() []string {
+	services, err := storage.NewStorage().RemoteServices()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // groupNames returns saved group names (sorted, best-effort).
 func groupNames() []string {
 	groups, err := storage.NewStorage().ListGroups()
@@ -85,7 +100,7 @@ func multiComplete(all, args []string, toComplete string) ([]string, cobra.Shell
 // completeServicesAndGroups completes a multi-value list of services/groups
 // (used by `run` and the bare-name shortcut).
 func completeServicesAndGroups(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	return multiComplete(append(serviceNames(), groupNames()...), args, toComplete)
+	return multiComplete(append(append(serviceNames(), groupNames()...), remoteServiceNames()...), args, toComplete)
 }
 
 // completeServiceList completes a multi-value list of services (group add).
@@ -98,6 +113,23 @@ func completeServices(_ *cobra.Command, _ []string, _ string) ([]string, cobra.S
 	return serviceNames(), cobra.ShellCompDirectiveNoFileComp
 }
 
+// completeTrashed completes the name of a service in the trash (restore).
+func completeTrashed(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	trashed, err := storage.NewStorage().Trashed()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names := make([]string, 0, len(trashed))
+	for name := range trashed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
 // completeGroups completes a single group name (group delete/rename).
 func completeGroups(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
 	return groupNames(), cobra.ShellCompDirectiveNoFileComp
@@ -29,8 +29,7 @@ func newCompletionCmd() *cobra.Command {
 			Use: use, Short: short,
 			Run: func(cmd *cobra.Command, _ []string) {
 				if err := fn(cmd.Root(), os.Stdout); err != nil {
-					fmt.Printf("Error: %v\n", err)
-					os.Exit(1)
+					fatal(err)
 				}
 			},
 		}
@@ -119,11 +118,10 @@ func runCompletionInstall(root *cobra.Command, shell string) {
 		err = installPowerShell(root)
 	default:
 		fmt.Printf("Unknown shell: %s (use bash|zsh|fish|powershell)\n", shell)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
 	}
 }
 
@@ -134,12 +132,21 @@ const (
 	endMarker   = "# <<< pf completion <<<"
 )
 
-func completionDir() (string, error) {
+// completionPath is where the completion scripts are written:
+// ~/.pf/completion.
+func completionPath() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
 	}
-	dir := filepath.Join(home, ".pf", "completion")
+	return filepath.Join(home, ".pf", "completion"), nil
+}
+
+func completionDir() (string, error) {
+	dir, err := completionPath()
+	if err != nil {
+		return "", err
+	}
 	return dir, os.MkdirAll(dir, 0o755)
 }
 
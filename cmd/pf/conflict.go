@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/manager"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// resolveNameConflict decides what to do when a new service or group (kind)
+// would replace an existing one called name: the user can overwrite it, save
+// under another name, look at the difference first, or abort. It returns the
+// name to save under, and false to abort. Without a terminal to ask on it
+// aborts with a hint at --overwrite, so scripts never replace entries by
+// accident.
+func resolveNameConflict(st *storage.Storage, kind, name, current, proposed string) (string, bool) {
+	if !stdinIsTerminal() {
+		fmt.Printf("Error: %s '%s' already exists (use --overwrite to replace it)\n", kind, name)
+		return "", false
+	}
+	taken := func(n string) bool { return nameTaken(st, n) }
+	return askNameConflict(bufio.NewReader(os.Stdin), taken, kind, name, current, proposed)
+}
+
+func askNameConflict(in *bufio.Reader, taken func(string) bool, kind, name, current, proposed string) (string, bool) {
+	fmt.Printf("%s '%s' already exists.\n", strings.ToUpper(kind[:1])+kind[1:], name)
+	for {
+		fmt.Print("[o]verwrite, [r]ename, show [d]iff or [a]bort? ")
+		answer, err := readAnswer(in)
+		if err != nil {
+			return "", false
+		}
+		switch strings.ToLower(answer) {
+		case "o", "overwrite":
+			return name, true
+		case "d", "diff":
+			lipgloss.Println(cliMuted.Render("- " + current))
+			lipgloss.Println(cliName.Render("+ " + proposed))
+		case "r", "rename":
+			for {
+				fmt.Print("New name: ")
+				newName, err := readAnswer(in)
+				if err != nil || newName == "" {
+					return "", false
+				}
+				if err := manager.ValidateServiceName(newName); err != nil {
+					fmt.Printf("Invalid name: %v\n", err)
+					continue
+				}
+				if taken(newName) {
+					fmt.Printf("'%s' is taken too\n", newName)
+					continue
+				}
+				return newName, true
+			}
+		case "", "a", "abort":
+			return "", false
+		}
+	}
+}
+
+// readAnswer reads one line, trimmed. EOF ends the prompt like an abort.
+func readAnswer(in *bufio.Reader) (string, error) {
+	line, err := in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// nameTaken reports whether name is a saved service or group.
+func nameTaken(st *storage.Storage, name string) bool {
+	if _, err := st.GetService(name); err == nil {
+		return true
+	}
+	_, err := st.GetGroupServices(name)
+	return err == nil
+}
+
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestAskNameConflict(t *testing.T) {
+	taken := func(name string) bool { return name == "db" || name == "cache" }
+	for _, tc := range []struct {
+		input  string
+		name   string
+		saving bool
+	}{
+		{"o\n", "db", true},
+		{"D\noverwrite\n", "db", true},
+		{"r\ncache\nbad name\ndb-2\n", "db-2", true},
+		{"x\na\n", "", false},
+		{"\n", "", false},
+		{"r\n", "", false}, // EOF at the new name
+		{"", "", false},
+	} {
+		name, ok := askNameConflict(bufio.NewReader(strings.NewReader(tc.input)), taken, "service", "db", "old", "new")
+		if name != tc.name || ok != tc.saving {
+			t.Errorf("answers %q: got (%q, %v), want (%q, %v)", tc.input, name, ok, tc.name, tc.saving)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"github.com/alinemone/go-port-forward/internal/demo"
+	"github.com/alinemone/go-port-forward/internal/storage"
+	"github.com/alinemone/go-port-forward/internal/ui"
+
+	tea "charm.land/bubbletea/v2"
+)
+
+// runDemo opens the live view on scripted services instead of real forwards:
+// nothing is started, and the session is not published to other pf commands.
+// It is meant for screenshots and for working on the UI.
+func runDemo(args []string, opts runOptions) {
+	if len(args) > 0 || opts.fromStdin || opts.onlyFailed || opts.follow || opts.envFile != "" || len(opts.set) > 0 {
+		fmt.Println("Error: --demo plays its own services: it takes no names, --from-stdin, --only-failed, --follow, --env-file or --set")
+		os.Exit(exitError)
+	}
+	if opts.ttl < 0 {
+		fmt.Println("Error: --ttl must be positive")
+		os.Exit(exitError)
+	}
+
+	scenario := demo.Builtin()
+	cleanup, err := sandboxHome(scenario)
+	if err != nil {
+		fatal(err)
+	}
+	defer cleanup()
+
+	session := demo.New(scenario)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if opts.ttl > 0 {
+		var cancelTTL context.CancelFunc
+		ctx, cancelTTL = context.WithTimeout(ctx, opts.ttl)
+		defer cancelTTL()
+	}
+	deadline, _ := ctx.Deadline()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		cancel()
+	}()
+
+	go session.Run(ctx)
+	for _, name := range session.Names() {
+		_ = session.StartStoredService(ctx, name)
+	}
+	stopProfile, err := startProfile(opts.profile)
+	if err != nil {
+		fatal(err)
+	}
+	recording, stopRecording, err := startRecording(opts.record, "pf demo")
+	if err != nil {
+		fatal(err)
+	}
+	confirm := ui.ConfirmOptions{Stop: true, Restart: true, Quit: true}
+	if opts.noConfirm {
+		confirm = ui.ConfirmOptions{}
+	}
+
+	if accessibleMode(opts) {
+		p := ui.NewPlain(session, os.Stdin, os.Stdout)
+		p.SetConfirm(confirm)
+		p.SetDeadline(deadline)
+		err = p.Run(ctx)
+	} else {
+		u := ui.NewUI(session, ctx)
+		u.SetSessionInfo("demo", "demo-cluster")
+		u.SetConfirm(confirm)
+		u.SetDeadline(deadline)
+		_, err = tea.NewProgram(u, recording...).Run()
+	}
+	session.StopAllServices()
+	stopRecording()
+	stopProfile()
+	reportTTLExpired(ctx, opts)
+	if err != nil && !errors.Is(err, tea.ErrProgramKilled) {
+		fatal(err)
+	}
+}
+
+// sandboxHome points the home directory at a temporary one holding the
+// scenario's services and groups, so what the live view reads from or writes
+// to the config (the manage overlay, the editor) never touches the real one.
+// It returns the func that removes it.
+func sandboxHome(scenario demo.Scenario) (func(), error) {
+	dir, err := os.MkdirTemp("", "pf-demo-")
+	if err != nil {
+		return nil, err
+	}
+	os.Setenv("HOME", dir)
+	os.Setenv("USERPROFILE", dir)
+
+	st := storage.NewStorage()
+	data := &storage.StorageData{Services: map[string]string{}, Groups: map[string][]string{}}
+	for _, script := range scenario.Scripts {
+		data.Services[script.Name] = script.Command
+	}
+	for name, members := range scenario.Groups {
+		data.Groups[name] = slices.Clone(members)
+	}
+	if err := st.SaveData(data); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return func() { os.RemoveAll(dir) }, nil
+}
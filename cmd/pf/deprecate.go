@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// runDeprecateCommand marks services as deprecated, with an optional
+// replacement, sunset date and note, or with undo takes that back. With no
+// names it lists the deprecated services.
+func runDeprecateCommand(names []string, d storage.Deprecation, undo bool) {
+	st := storage.NewStorage()
+	if len(names) == 0 {
+		printDeprecated(st)
+		return
+	}
+
+	for _, name := range names {
+		if undo {
+			if err := st.SetDeprecation(name, nil); err != nil {
+				fatal(err)
+			}
+			fmt.Printf("✓ '%s' is no longer deprecated\n", name)
+			continue
+		}
+		if d.Replacement != "" {
+			if _, err := st.GetService(d.Replacement); err != nil || d.Replacement == name {
+				fmt.Printf("Error: replacement '%s' is not another saved service\n", d.Replacement)
+				os.Exit(exitError)
+			}
+		}
+		if err := st.SetDeprecation(name, &d); err != nil {
+			fatal(err)
+		}
+		fmt.Printf("✓ '%s' is %s\n", name, d.Describe(time.Now()))
+	}
+}
+
+func printDeprecated(st *storage.Storage) {
+	data, err := st.LoadData()
+	if err != nil {
+		fatal(err)
+	}
+	if len(data.Deprecated) == 0 {
+		lipgloss.Println(cliMuted.Render("No deprecated services"))
+		return
+	}
+	names := make([]string, 0, len(data.Deprecated))
+	for name := range data.Deprecated {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	now := time.Now()
+	items := make([][2]string, 0, len(names))
+	for _, name := range names {
+		items = append(items, [2]string{name, data.Deprecated[name].Describe(now)})
+	}
+	printList("Deprecated", fmt.Sprintf("(%d)", len(names)), items)
+}
+
+// warnDeprecated tells the user on w about deprecated services among the ones
+// about to run, and what to use instead.
+func warnDeprecated(w io.Writer, st *storage.Storage, serviceNames []string) {
+	now := time.Now()
+	for _, name := range serviceNames {
+		if d, ok, err := st.Deprecation(name); err == nil && ok {
+			fmt.Fprintf(w, "! '%s' is %s\n", name, d.Describe(now))
+		}
+	}
+}
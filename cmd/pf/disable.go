@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// runEnableCommand disables services, which keeps them saved but out of
+// "all", group runs and the TUI's add list, or enables them again. Disabling
+// with no names lists the disabled services.
+func runEnableCommand(names []string, enabled bool) {
+	st := storage.NewStorage()
+	if len(names) == 0 {
+		if enabled {
+			fmt.Println("Usage: pf enable <names>")
+			fmt.Println("Example: pf enable legacy-db")
+			os.Exit(exitError)
+		}
+		printDisabled(st)
+		return
+	}
+
+	for _, name := range names {
+		if err := st.SetEnabled(name, enabled); err != nil {
+			fatal(err)
+		}
+		if enabled {
+			fmt.Printf("✓ '%s' is enabled\n", name)
+		} else {
+			fmt.Printf("✓ '%s' is disabled: kept, but left out of run all, groups and the add list\n", name)
+		}
+	}
+}
+
+func printDisabled(st *storage.Storage) {
+	data, err := st.LoadData()
+	if err != nil {
+		fatal(err)
+	}
+	var names []string
+	for name, enabled := range data.Enabled {
+		if !enabled {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		lipgloss.Println(cliMuted.Render("No disabled services"))
+		return
+	}
+	sort.Strings(names)
+	items := make([][2]string, 0, len(names))
+	for _, name := range names {
+		command, _ := st.GetService(name)
+		items = append(items, [2]string{name, command})
+	}
+	printList("Disabled", fmt.Sprintf("(%d)", len(names)), items)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/discover"
+	"github.com/alinemone/go-port-forward/internal/manager"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// runDiscoverCommand lists the forwards a namespace's Services declare
+// through pf.dev annotations, and with save adds them to the catalog. Saved
+// services keep their command: a Service that now declares something else is
+// reported, not overwritten.
+func runDiscoverCommand(namespace string, fromAnnotations, save bool) {
+	if !fromAnnotations {
+		fmt.Println("Usage: pf discover --from-annotations [-n <namespace>] [--save]")
+		fmt.Println("Example: pf discover --from-annotations -n data --save")
+		os.Exit(exitError)
+	}
+
+	out, err := getServices(context.Background(), namespace)
+	if err != nil {
+		fatal(err)
+	}
+
+	found, skipped, err := discover.FromAnnotations(out)
+	if err != nil {
+		fatal(err)
+	}
+	for _, err := range skipped {
+		lipgloss.Println(cliMuted.Render("Skipped " + err.Error()))
+	}
+	if len(found) == 0 {
+		lipgloss.Println(cliMuted.Render("No Services annotated with " + discover.AnnotationLocalPort))
+		return
+	}
+
+	if !save {
+		items := make([][2]string, 0, len(found))
+		for _, svc := range found {
+			items = append(items, [2]string{svc.Name, svc.Command})
+		}
+		printList("Discovered", fmt.Sprintf("(%d)", len(found)), items)
+		lipgloss.Println(cliMuted.Render("Run again with --save to add them"))
+		return
+	}
+
+	st := storage.NewStorage()
+	services, err := st.LoadServices()
+	if err != nil {
+		fatal(err)
+	}
+	for _, svc := range found {
+		if err := manager.ValidateServiceName(svc.Name); err != nil {
+			fmt.Printf("! Skipped '%s': %v\n", svc.Name, err)
+			continue
+		}
+		if existing, ok := services[svc.Name]; ok {
+			if existing != svc.Command {
+				fmt.Printf("! Kept '%s': it already exists with another command\n", svc.Name)
+			}
+			continue
+		}
+		if err := st.AddService(svc.Name, svc.Command); err != nil {
+			fatal(err)
+		}
+		fmt.Printf("✓ Service '%s' added\n", svc.Name)
+	}
+}
+
+// getServices returns `kubectl get services -o json` for namespace, or for
+// kubectl's current one when namespace is "".
+func getServices(ctx context.Context, namespace string) ([]byte, error) {
+	args := []string{"get", "services", "-o", "json"}
+	if namespace != "" {
+		args = append(args, "-n", namespace)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "kubectl", withCertArgs(args)...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			err = fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("kubectl get services: %v", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/relay"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// runDNSCommand prints how to send lookups for the given domains to a DNS
+// service's relay, for the resolver setup of this OS. pf does not change
+// resolver settings itself, since that needs root.
+func runDNSCommand(args []string, domains []string) {
+	if len(args) != 1 || len(domains) == 0 {
+		fmt.Println("Usage: pf dns <name> [--domain cluster.local]")
+		os.Exit(exitError)
+	}
+	name := args[0]
+	cfg, ok, err := storage.NewStorage().Relay(name)
+	if err != nil {
+		fatal(err)
+	}
+	if !ok || !cfg.DNS {
+		fmt.Printf("Error: service '%s' has no DNS relay; add one under \"relay\" with \"dns\": true\n", name)
+		os.Exit(exitError)
+	}
+	host, port, err := net.SplitHostPort(relay.ListenAddress(cfg.Listen))
+	if err != nil {
+		fmt.Printf("Error: invalid relay listen %q\n", cfg.Listen)
+		os.Exit(exitError)
+	}
+
+	lipgloss.Println(cliTitle.Render(fmt.Sprintf("Resolve %s through '%s' (%s)", strings.Join(domains, ", "), name, net.JoinHostPort(host, port))))
+	lipgloss.Println(cliMuted.Render("Run while the service is up; undo by removing what it adds."))
+	fmt.Println()
+	switch runtime.GOOS {
+	case "darwin":
+		fmt.Println("sudo mkdir -p /etc/resolver")
+		for _, d := range domains {
+			fmt.Printf("printf 'nameserver %s\\nport %s\\n' | sudo tee /etc/resolver/%s\n", host, port, d)
+		}
+	case "windows":
+		if port != "53" {
+			lipgloss.Println(cliMuted.Render("Windows only queries port 53: set the relay's listen to \"53\" for this."))
+		}
+		for _, d := range domains {
+			fmt.Printf("Add-DnsClientNrptRule -Namespace \".%s\" -NameServers \"%s\"\n", d, host)
+		}
+	default:
+		lipgloss.Println(cliMuted.Render("# dnsmasq (e.g. /etc/NetworkManager/dnsmasq.d/pf.conf):"))
+		for _, d := range domains {
+			fmt.Printf("server=/%s/%s#%s\n", d, host, port)
+		}
+		lipgloss.Println(cliMuted.Render("# or a quick check:"))
+		fmt.Printf("dig @%s -p %s kubernetes.default.svc.%s\n", host, port, domains[0])
+	}
+}
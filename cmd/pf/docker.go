@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/endpoint"
+	"github.com/alinemone/go-port-forward/internal/sidecar"
+	"github.com/alinemone/go-port-forward/internal/status"
+)
+
+// runDockerCommand publishes running forwards (all of them, or the named
+// ones) in a Docker network until Ctrl+C, so its containers reach each as
+// <service>:<port>; see package sidecar. On Linux, where containers reach the
+// host through the bridge's gateway, pf also relays from the gateway to each
+// forward's loopback address. The sidecars are removed on exit.
+func runDockerCommand(args []string, network string) {
+	if network == "" {
+		fmt.Println("Usage: pf docker --network <name> [service...]")
+		os.Exit(exitError)
+	}
+	dir, err := status.Dir()
+	if err != nil {
+		fatal(err)
+	}
+	sessions, err := status.ReadSessions(dir, time.Now())
+	if err != nil {
+		fatal(err)
+	}
+	var forwards []endpoint.Endpoint
+	for _, e := range status.Endpoints(sessions) {
+		if e.Port != "" && (len(args) == 0 || slices.Contains(args, e.Name)) {
+			forwards = append(forwards, e)
+		}
+	}
+	for _, name := range args {
+		if !slices.ContainsFunc(forwards, func(e endpoint.Endpoint) bool { return e.Name == name }) {
+			fmt.Printf("Error: service '%s' is not running\n", name)
+			os.Exit(exitNoSession)
+		}
+	}
+	if len(forwards) == 0 {
+		lipgloss.Println(cliMuted.Render("No port forwards running"))
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	gateway := ""
+	if sidecar.NeedsBridge() {
+		if gateway, err = sidecar.HostGateway(ctx); err != nil {
+			fatal(err)
+		}
+	}
+	var started []string
+	removeAll := func() {
+		cleanup, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := sidecar.Remove(cleanup, started...); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+
+	items := make([][2]string, 0, len(forwards))
+	for _, e := range forwards {
+		s := sidecar.Sidecar{Service: e.Name, Port: sidecar.ContainerPort(e.Target, e.Port), HostPort: e.Port}
+		note := ""
+		if gateway != "" {
+			listen := net.JoinHostPort(gateway, e.Port)
+			if err := sidecar.Bridge(ctx, listen, e.Addr()); err != nil {
+				// Most likely the forward listens on every address already.
+				note = fmt.Sprintf(" (not relayed from %s: %v)", listen, err)
+			}
+		}
+		if err := sidecar.Start(ctx, network, s); err != nil {
+			removeAll()
+			fmt.Printf("Error: %s: %v\n", e.Name, err)
+			os.Exit(exitError)
+		}
+		started = append(started, e.Name)
+		items = append(items, [2]string{net.JoinHostPort(e.Name, s.Port), e.Addr() + note})
+	}
+
+	printList("Published in "+network, fmt.Sprintf("(%d forwards, Ctrl+C to remove)", len(items)), items)
+	<-ctx.Done()
+	removeAll()
+	fmt.Printf("✓ Removed %d sidecar container(s)\n", len(started))
+}
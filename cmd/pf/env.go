@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/endpoint"
+	"github.com/alinemone/go-port-forward/internal/status"
+)
+
+// runEnvCommand prints the endpoints of every running pf session. Without a
+// template it prints the same dotenv the env file holds; with one it renders
+// the Go template at templatePath ("-" reads stdin) against them.
+func runEnvCommand(templatePath string) {
+	dir, err := status.Dir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	sessions, err := status.ReadSessions(dir, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	endpoints := status.Endpoints(sessions)
+
+	if templatePath == "" {
+		os.Stdout.Write(endpoint.Dotenv(endpoints))
+		return
+	}
+
+	var text []byte
+	if templatePath == "-" {
+		text, err = io.ReadAll(os.Stdin)
+	} else {
+		text, err = os.ReadFile(templatePath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if err := endpoint.RenderTemplate(os.Stdout, templatePath, string(text), endpoints); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitError)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/manager"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// runEphemeralAddCommand saves a short-lived copy of the template service with
+// the --set values in its command, for a preview environment that goes away:
+// it is deleted after ttl, or by the next `pf cleanup` when ttl is 0.
+func runEphemeralAddCommand(args []string, template string, sets []string, ttl time.Duration) {
+	if len(args) != 1 || template == "" {
+		fmt.Println("Usage: pf ephemeral add <name> --from <service> [--set key=value ...] [--ttl 48h]")
+		fmt.Println("Example: pf ephemeral add pr-42-api --from api --set namespace=pr-42 --ttl 48h")
+		os.Exit(exitError)
+	}
+	name := args[0]
+	if err := manager.ValidateServiceName(name); err != nil {
+		fmt.Printf("Error: invalid name: %v\n", err)
+		os.Exit(exitError)
+	}
+	if ttl < 0 {
+		fmt.Println("Error: --ttl must be positive")
+		os.Exit(exitError)
+	}
+	params, err := parseRunParams(sets)
+	if err != nil {
+		fatal(err)
+	}
+	for target := range params {
+		if target != "" {
+			fmt.Printf("Error: --set %s.…: an ephemeral service takes plain key=value\n", target)
+			os.Exit(exitError)
+		}
+	}
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl).Round(time.Second)
+	}
+	st := storage.NewStorage()
+	command, err := st.AddEphemeral(name, template, params[""], expires)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("✓ Service '%s' added: %s\n", name, command)
+	lipgloss.Println(cliMuted.Render(describeExpiry(expires, time.Now())))
+}
+
+// runEphemeralListCommand lists the ephemeral services and when they go,
+// after deleting those already expired.
+func runEphemeralListCommand() {
+	st := storage.NewStorage()
+	expireEphemeral(st, false)
+	data, err := st.LoadData()
+	if err != nil {
+		fatal(err)
+	}
+	if len(data.Ephemeral) == 0 {
+		lipgloss.Println(cliMuted.Render("No ephemeral services"))
+		return
+	}
+	names := make([]string, 0, len(data.Ephemeral))
+	for name := range data.Ephemeral {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	now := time.Now()
+	items := make([][2]string, 0, len(names))
+	for _, name := range names {
+		e := data.Ephemeral[name]
+		from := "from " + e.Template
+		if len(e.Params) > 0 {
+			from += " with " + formatLabels(e.Params)
+		}
+		items = append(items, [2]string{name, from + ", " + describeExpiry(e.Expires, now)})
+	}
+	printList("Ephemeral", fmt.Sprintf("(%d)", len(names)), items)
+}
+
+// describeExpiry says when an ephemeral service expiring at expires goes.
+func describeExpiry(expires, now time.Time) string {
+	if expires.IsZero() {
+		return "deleted by the next pf cleanup"
+	}
+	return fmt.Sprintf("expires %s (in %s)", expires.Format("Jan 2 15:04"), expires.Sub(now).Round(time.Minute))
+}
+
+// expireEphemeral deletes the ephemeral services that have expired, and with
+// cleanup those waiting for a cleanup, and says which. A failure is only
+// warned about: it must not stop the command that triggered it.
+func expireEphemeral(st *storage.Storage, cleanup bool) {
+	expired, err := st.ExpireEphemeral(time.Now(), cleanup)
+	if err != nil {
+		fmt.Printf("Warning: cannot delete expired ephemeral services: %v\n", err)
+		return
+	}
+	if len(expired) > 0 {
+		lipgloss.Println(cliMuted.Render("Deleted ephemeral services: " + strings.Join(expired, ", ")))
+	}
+}
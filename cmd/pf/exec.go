@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/endpoint"
+	"github.com/alinemone/go-port-forward/internal/manager"
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/status"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// runExecCommand starts the forwards named by targets, waits until all are
+// healthy, runs command with PF_<NAME>_HOST/PORT/ADDR in its environment, then
+// stops the forwards and exits with the command's exit code, or 127 when it
+// cannot start it (exitcodes.go lists the codes of pf itself). pf's messages
+// go to stderr so the command's stdout stays clean.
+func runExecCommand(targets, command []string, timeout time.Duration) {
+	// Without names the default group comes up, as for a bare `pf run`.
+	if len(targets) == 0 {
+		if group, err := storage.NewStorage().DefaultGroup(); err == nil && group != "" {
+			targets = []string{group}
+		}
+	}
+	if len(targets) == 0 || len(command) == 0 {
+		fmt.Println("Usage: pf exec <names> -- <command> [args...]")
+		fmt.Println("Example: pf exec db,redis -- go test ./...")
+		os.Exit(exitError)
+	}
+
+	st := storage.NewStorage()
+	if names := catalogsNamedIn(strings.Join(targets, " ")); len(names) > 0 {
+		syncCatalogs(os.Stderr, st, names, false, false)
+	}
+	serviceNames, err := resolveRunTargets(st, strings.Join(targets, " "))
+	if err != nil {
+		fatal(err)
+	}
+	checkRunnable(st, serviceNames)
+	warnDeprecated(os.Stderr, st, serviceNames)
+
+	mgr := manager.NewServiceManager(st)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Ctrl+C reaches the child directly from the terminal; pf only has to
+	// outlive it to clean up. Before the child starts, a signal aborts the wait.
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	trackGroups(st, mgr, strings.Join(targets, " "))
+	unpublish := status.Publish(strings.Join(targets, " "), mgr.ListServiceStates, mgr.GroupStates)
+	stop := func() {
+		stuck := followShutdown(mgr, nil)
+		mgr.StopAllServices()
+		warnStuck(os.Stderr, "pf: ", stuck())
+		unpublish()
+	}
+
+	for _, name := range serviceNames {
+		if err := mgr.StartService(ctx, name); err != nil {
+			fmt.Fprintf(os.Stderr, "pf: error starting %s: %v\n", name, err)
+			stop()
+			os.Exit(exitStartFailed)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "pf: waiting for %s…\n", strings.Join(serviceNames, ", "))
+	services, err := waitHealthy(ctx, mgr, serviceNames, timeout, sigChan)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pf: %v\n", err)
+		stop()
+		os.Exit(exitCode(err))
+	}
+
+	endpoints := endpoint.FromServices(services)
+	for _, e := range endpoints {
+		fmt.Fprintf(os.Stderr, "pf: %s ready on %s\n", e.Name, e.Addr())
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), endpoint.Env(endpoints)...)
+
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "pf: %v\n", err)
+		stop()
+		os.Exit(127)
+	}
+	waitDone := make(chan error, 1)
+	go func() { waitDone <- cmd.Wait() }()
+
+	var runErr error
+	for waiting := true; waiting; {
+		select {
+		case sig := <-sigChan:
+			if sig != os.Interrupt {
+				_ = cmd.Process.Signal(sig)
+			}
+		case runErr = <-waitDone:
+			waiting = false
+		}
+	}
+
+	stop()
+
+	var exitErr *exec.ExitError
+	switch {
+	case runErr == nil:
+	case errors.As(runErr, &exitErr):
+		code := exitErr.ExitCode()
+		if code < 0 {
+			code = 1 // killed by a signal
+		}
+		os.Exit(code)
+	default:
+		fmt.Fprintf(os.Stderr, "pf: %v\n", runErr)
+		os.Exit(exitError)
+	}
+}
+
+// waitHealthy blocks until every named service reports healthy and returns
+// their states. It gives up after timeout (an error with exitHealthTimeout), or
+// when a signal arrives, naming the services that are not up yet and their
+// last error.
+func waitHealthy(ctx context.Context, mgr *manager.ServiceManager, names []string, timeout time.Duration, sigChan <-chan os.Signal) ([]model.Service, error) {
+	deadline := time.After(timeout)
+	for {
+		var ready []model.Service
+		pending := map[string]string{}
+		for _, name := range names {
+			pending[name] = ""
+		}
+		for _, svc := range mgr.ListServiceStates() {
+			if _, ok := pending[svc.Name]; !ok {
+				continue
+			}
+			if svc.Status == model.StatusHealthy {
+				delete(pending, svc.Name)
+				ready = append(ready, svc)
+			} else {
+				pending[svc.Name] = svc.LastError
+			}
+		}
+		if len(pending) == 0 {
+			return ready, nil
+		}
+
+		select {
+		case <-mgr.Updates():
+		case <-deadline:
+			return nil, withExitCode(exitHealthTimeout, fmt.Errorf("not healthy after %s: %s", timeout, describePending(pending)))
+		case <-sigChan:
+			return nil, fmt.Errorf("interrupted while waiting for %s", describePending(pending))
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func describePending(pending map[string]string) string {
+	parts := make([]string, 0, len(pending))
+	for name, lastErr := range pending {
+		if lastErr != "" {
+			name += " (" + lastErr + ")"
+		}
+		parts = append(parts, name)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// Exit codes, which scripts and CI can rely on; the README lists them. pf
+// exec exits with its command's code once the command ran.
+const (
+	exitOK            = 0
+	exitError         = 1 // any other error, bad usage included
+	exitNotFound      = 2 // a service or group that is not defined
+	exitStartFailed   = 3 // a forward pf could not start
+	exitHealthTimeout = 4 // forwards that were not healthy in time
+	exitNoSession     = 5 // no running session to act on, or one that did not answer
+	exitServiceFailed = 6 // a service that went to error, with --fail-fast
+)
+
+// codedError is an error that ends pf with its own exit code.
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e codedError) Error() string { return e.err.Error() }
+func (e codedError) Unwrap() error { return e.err }
+
+// withExitCode gives err the exit code code; nil stays nil.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return codedError{code: code, err: err}
+}
+
+// exitCode is the code pf exits with for err: its own for a codedError,
+// exitNotFound for an undefined service or group, exitError otherwise.
+func exitCode(err error) int {
+	var e codedError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &e):
+		return e.code
+	case errors.Is(err, storage.ErrNotFound):
+		return exitNotFound
+	}
+	return exitError
+}
+
+// fatal prints err and exits with its exit code (see exitCode).
+func fatal(err error) {
+	fmt.Printf("Error: %v\n", err)
+	os.Exit(exitCode(err))
+}
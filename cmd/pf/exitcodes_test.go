@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+func TestExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{nil, exitOK},
+		{errors.New("cannot read config"), exitError},
+		{fmt.Errorf("service 'api' %w", storage.ErrNotFound), exitNotFound},
+		{fmt.Errorf("resolve: %w", fmt.Errorf("group 'backend' %w", storage.ErrNotFound)), exitNotFound},
+		{errors.New("service 'api': kubectl not found in PATH"), exitError},
+		{withExitCode(exitHealthTimeout, errors.New("not healthy after 1m0s: db")), exitHealthTimeout},
+		{fmt.Errorf("resume: %w", withExitCode(exitNoSession, errors.New("did not respond"))), exitNoSession},
+	} {
+		if got := exitCode(tc.err); got != tc.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+	if withExitCode(exitStartFailed, nil) != nil {
+		t.Error("withExitCode(nil) should stay nil")
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/forwarder"
+)
+
+// runForwardersCommand lists the forwarder plugins services can run (see
+// package forwarder), and where to install more.
+func runForwardersCommand() {
+	dir, err := forwarder.Dir()
+	if err != nil {
+		fatal(err)
+	}
+	plugins, err := forwarder.List(dir)
+	if err != nil {
+		fatal(err)
+	}
+	if len(plugins) == 0 {
+		lipgloss.Println(cliMuted.Render(fmt.Sprintf("No forwarder plugins in %s", dir)))
+		return
+	}
+	items := make([][2]string, 0, len(plugins))
+	for _, p := range plugins {
+		items = append(items, [2]string{p.Name, p.Path})
+	}
+	printList("Forwarder plugins", fmt.Sprintf("(%d, use with pf add <name> plugin <plugin> <local>:<remote>)", len(plugins)), items)
+}
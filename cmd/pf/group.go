@@ -1,8 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 	"unicode"
@@ -20,23 +23,32 @@ func splitNameList(args []string) []string {
 	})
 }
 
-func runGroupAddCommand(st *storage.Storage, args []string) {
+// runGroupAddCommand creates a group. Replacing a group of the same name is
+// asked about unless overwrite is set.
+func runGroupAddCommand(st *storage.Storage, args []string, overwrite bool) {
 	if len(args) < 2 {
 		fmt.Println("Usage: pf group add <group-name> <service1,service2,...>")
 		fmt.Println("Example: pf group add database auth,core,crm")
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	groupName := args[0]
 	serviceNames := splitNameList(args[1:])
 	if len(serviceNames) == 0 {
 		fmt.Println("Usage: pf group add <group-name> <service1,service2,...>")
-		os.Exit(1)
+		os.Exit(exitError)
+	}
+
+	if current, err := st.GetGroupServices(groupName); err == nil && !overwrite && !slices.Equal(current, serviceNames) {
+		var ok bool
+		groupName, ok = resolveNameConflict(st, "group", groupName, strings.Join(current, ", "), strings.Join(serviceNames, ", "))
+		if !ok {
+			os.Exit(exitError)
+		}
 	}
 
 	if err := st.AddGroup(groupName, serviceNames); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
 	}
 
 	fmt.Printf("✓ Group '%s' created with %d services\n", groupName, len(serviceNames))
@@ -46,19 +58,18 @@ func runGroupAddServiceCommand(st *storage.Storage, args []string) {
 	if len(args) < 2 {
 		fmt.Println("Usage: pf group add-service <group-name> <service1,service2,...>")
 		fmt.Println("Example: pf group add-service database redis,wallet-pg")
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	groupName := args[0]
 	serviceNames := splitNameList(args[1:])
 	if len(serviceNames) == 0 {
 		fmt.Println("Usage: pf group add-service <group-name> <service1,service2,...>")
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	if err := st.AddServicesToGroup(groupName, serviceNames); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
 	}
 
 	services, _ := st.GetGroupServices(groupName)
@@ -69,19 +80,18 @@ func runGroupRemoveServiceCommand(st *storage.Storage, args []string) {
 	if len(args) < 2 {
 		fmt.Println("Usage: pf group remove-service <group-name> <service1,service2,...>")
 		fmt.Println("Example: pf group remove-service database redis")
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	groupName := args[0]
 	serviceNames := splitNameList(args[1:])
 	if len(serviceNames) == 0 {
 		fmt.Println("Usage: pf group remove-service <group-name> <service1,service2,...>")
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	if err := st.RemoveServicesFromGroup(groupName, serviceNames); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
 	}
 
 	services, _ := st.GetGroupServices(groupName)
@@ -91,7 +101,7 @@ func runGroupRemoveServiceCommand(st *storage.Storage, args []string) {
 func runGroupRenameCommand(st *storage.Storage, args []string) {
 	if len(args) < 2 {
 		fmt.Println("Usage: pf group rename <old-name> <new-name>")
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	oldName := args[0]
@@ -99,12 +109,11 @@ func runGroupRenameCommand(st *storage.Storage, args []string) {
 
 	if err := manager.ValidateServiceName(newName); err != nil {
 		fmt.Printf("Error: invalid new name: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	if err := st.RenameGroup(oldName, newName); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
 	}
 
 	fmt.Printf("✓ Group renamed '%s' → '%s'\n", oldName, newName)
@@ -113,8 +122,7 @@ func runGroupRenameCommand(st *storage.Storage, args []string) {
 func runGroupListCommand(st *storage.Storage) {
 	groups, err := st.ListGroups()
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
 	}
 
 	if len(groups) == 0 {
@@ -129,10 +137,14 @@ func runGroupListCommand(st *storage.Storage) {
 	}
 	sort.Strings(names)
 
+	defaultGroup, _ := st.DefaultGroup()
 	items := make([][2]string, 0, len(names))
 	for _, name := range names {
 		services := groups[name]
 		title := fmt.Sprintf("%s  (%d)", name, len(services))
+		if name == defaultGroup {
+			title += "  default"
+		}
 		detail := strings.Join(services, ", ")
 		if detail == "" {
 			detail = "(empty)"
@@ -145,26 +157,134 @@ func runGroupListCommand(st *storage.Storage) {
 func runGroupDeleteCommand(st *storage.Storage, args []string) {
 	if len(args) < 1 {
 		fmt.Println("Usage: pf group delete <group-name>")
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	groupName := args[0]
 	if err := st.DeleteGroup(groupName); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
 	}
 
 	fmt.Printf("✓ Group '%s' deleted\n", groupName)
 }
 
+// runGroupDefaultCommand sets the group a bare `pf run` starts, clears it
+// with unset, or without a name shows it.
+func runGroupDefaultCommand(st *storage.Storage, args []string, unset bool) {
+	switch {
+	case unset:
+		if err := st.SetDefaultGroup(""); err != nil {
+			fatal(err)
+		}
+		fmt.Println("✓ No default group: pf run without names lets you pick")
+	case len(args) > 0:
+		if err := st.SetDefaultGroup(args[0]); err != nil {
+			fatal(err)
+		}
+		fmt.Printf("✓ pf run without names now runs '%s'\n", args[0])
+	default:
+		group, err := st.DefaultGroup()
+		if err != nil {
+			fatal(err)
+		}
+		if group == "" {
+			lipgloss.Println(cliMuted.Render("No default group; set one with 'pf group default <name>'"))
+			return
+		}
+		fmt.Println(group)
+	}
+}
+
+// runGroupExportCommand writes the named groups, or all of them, as JSON to
+// output ("" or "-" for stdout), without the services they name.
+func runGroupExportCommand(st *storage.Storage, args []string, output string) {
+	f, err := st.ExportGroups(splitNameList(args))
+	if err != nil {
+		fatal(err)
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		fatal(err)
+	}
+	data = append(data, '\n')
+	if output == "" || output == "-" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(output, data, 0o644); err != nil {
+		fatal(err)
+	}
+	fmt.Printf("✓ Exported %d groups to %s\n", len(f.Groups), output)
+}
+
+// runGroupImportCommand saves the groups of a file `pf group export` wrote
+// ("-" reads stdin); see Storage.ImportGroups.
+func runGroupImportCommand(st *storage.Storage, args []string, createMissing, overwrite bool) {
+	if len(args) != 1 {
+		fmt.Println("Usage: pf group import <file|-> [--create-missing] [--overwrite]")
+		os.Exit(exitError)
+	}
+	var raw []byte
+	var err error
+	if args[0] == "-" {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		fatal(err)
+	}
+	var f storage.GroupsFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		fatal(fmt.Errorf("%s: %v", args[0], err))
+	}
+	for name := range f.Groups {
+		if err := manager.ValidateServiceName(name); err != nil {
+			fatal(fmt.Errorf("group '%s': %v", name, err))
+		}
+		for _, member := range f.Groups[name] {
+			if _, _, remote := storage.SplitCatalogName(member); remote {
+				continue
+			}
+			if err := manager.ValidateServiceName(member); err != nil {
+				fatal(fmt.Errorf("group '%s': service '%s': %v", name, member, err))
+			}
+		}
+	}
+
+	result, err := st.ImportGroups(f, createMissing, overwrite)
+	if err != nil {
+		fatal(err)
+	}
+	for _, name := range result.Added {
+		fmt.Printf("✓ Added group '%s'\n", name)
+	}
+	for _, name := range result.Replaced {
+		fmt.Printf("✓ Replaced group '%s'\n", name)
+	}
+	for _, name := range result.Kept {
+		fmt.Printf("! Kept group '%s': it exists with other services (use --overwrite to replace it)\n", name)
+	}
+	if len(result.Stubs) > 0 {
+		fmt.Printf("✓ Added disabled stubs for %s\n", strings.Join(result.Stubs, ", "))
+		lipgloss.Println(cliMuted.Render("  Give each its command with pf add <name> \"<command>\" --overwrite, then pf enable <name>"))
+	}
+	if len(result.Added)+len(result.Replaced)+len(result.Kept) == 0 {
+		lipgloss.Println(cliMuted.Render("Every group is already up to date"))
+	}
+}
+
 func showGroupUsage() {
 	uHead("GROUPS:")
-	uRow(34, "group add <name> <svcs>", "Create a group from comma-separated services")
+	uRow(34, "group add <name> <svcs>", "Create a group from comma-separated services (--overwrite)")
 	uRow(34, "group add-service <name> <svcs>", "Add services to an existing group")
 	uRow(34, "group remove-service <name> <svcs>", "Remove services from a group")
 	uRow(34, "group list", "List all groups and their members")
 	uRow(34, "group delete <name>", "Delete a group (member services are kept)")
 	uRow(34, "group rename <old> <new>", "Rename a group")
+	uRow(34, "group default [name]", "Show or set the group a bare pf run starts (--unset)")
+	uRow(34, "group export [names] [-o file]", "Write groups as JSON, without their services")
+	uRow(34, "group import <file>", "Save exported groups (--create-missing, --overwrite)")
 	uExample(
 		"group add database auth,core,crm",
 		"group add-service database wallet-pg,redis",
@@ -173,6 +293,7 @@ func showGroupUsage() {
 		"run database",
 		"run database,cache",
 		"run database,db",
+		"group default database",
 	)
 
 	uHead("NOTES:")
@@ -45,12 +45,33 @@ func showUsage() {
 	uRow(26, "pf <name>", "Shortcut for: pf run <name>  (service or group)")
 
 	uHead("SERVICES:")
-	uRow(27, `a, add <name> "<command>"`, "Add a new service")
+	uRow(27, `a, add <name> "<command>"`, "Add a new service (--force saves a command that looks broken)")
 	uRow(27, "l, list", "List all saved services")
 	uRow(27, "r, run <names>", "Run one or more services in the live view (comma-separated)")
+	uRow(27, "r, run", "Pick services and groups to run from a searchable list")
 	uRow(27, "ra, run all", "Run every saved service")
-	uRow(27, "d, delete <name>", "Delete a service")
+	uRow(27, "run <names> --no-confirm", "Don't ask before stop/restart/quit in the live view")
+	uRow(27, "run <names> --ttl 4h", "Stop every forward after the given time (countdown in the header)")
+	uRow(27, "run <names> --env-file <p>", "Keep a dotenv of live endpoints at <p> while running")
+	uRow(27, "run <names> --follow", "Start/stop forwards as the groups run (or all services) change")
+	uRow(27, "run <names> --accessible", "Plain-text status lines and typed commands (screen readers)")
+	uRow(27, "run <names> --fail-fast", "Headless: stop and exit 6 when any service fails (CI)")
+	uRow(27, "run --from-stdin [names]", "Also run forwards piped in as JSON lines (tilt, skaffold, scripts)")
+	uRow(27, "run -n <ns> --all-services", "Forward every Service in a namespace on its own loopback address")
+	uRow(27, "run <group> --only-failed", "Start a group's failed services in its running session")
+	uRow(27, "run --demo", "Play scripted fake services in the live view (screenshots, UI work)")
+	uRow(27, "run <names> --profile cpu", "Write a pprof profile of the session (cpu or mem)")
+	uRow(27, "run <names> --record <f>", "Record the live view as an asciinema cast (asciinema play <f>)")
+	uRow(27, "run <names> --set k=v", "Change namespace, context or ports for this session only")
+	uRow(27, "x, exec <names> -- <cmd>", "Run a command with the forwards up (PF_<NAME>_HOST/PORT/ADDR)")
+	uRow(27, "d, delete <name>", "Delete a service (kept in the trash for 30 days)")
+	uRow(27, "restore [name]", "Bring back a deleted service, or list the trash")
 	uRow(27, "rename <old> <new>", "Rename a service")
+	uRow(27, "ephemeral add <n> --from s", "Save a copy with --set values, deleted after --ttl or cleanup")
+	uRow(27, "disable <names>", "Keep services saved but out of run all and groups (enable to undo)")
+	uRow(27, "sw, switch <name> --to <t>", "Repoint a service (and its running forward) at another target")
+	uRow(27, "history <name>", "Show a service's earlier commands")
+	uRow(27, "rollback <name> [--to <n>]", "Restore an earlier command (default: the previous one)")
 	uExample(`add db "kubectl port-forward service/postgres 5432:5432"`, "run db,redis")
 
 	uHead("GROUPS:")
@@ -59,6 +80,10 @@ func showUsage() {
 	uRow(39, "g, group remove-service <name> <svcs>", "Remove services from a group")
 	uRow(39, "g, group list", "List all groups and their members")
 	uRow(39, "g, group rename <old> <new>", "Rename a group")
+	uRow(39, "g, group default [name]", "Show or set the group a bare pf run starts (--unset)")
+	uRow(39, "g, group export [names] [-o file]", "Write groups as JSON, to import on another machine")
+	uRow(39, "g, group import <file>", "Save exported groups (--create-missing stubs, --overwrite)")
+	uRow(39, "label <name> key=value [key-]", "Label a service; run label:key=value, ns:<ns>, context:<ctx>")
 	uRow(39, "g, group delete <name>", "Delete a group (services are kept)")
 	uExample("group add backend api,db,redis", "run backend")
 
@@ -73,8 +98,38 @@ func showUsage() {
 	uExample("k get pods -n production", "k logs deploy/api -f")
 
 	uHead("OTHER:")
+	uRow(26, "status [--format waybar]", "Show running forwards (waybar/json for status bars)")
+	uRow(26, "status --group <name>", "Show a started group's combined status; exits 1 unless healthy")
+	uRow(26, "status --check", "Report pf's own health for monitors; exits 1 unless ready")
+	uRow(26, "stats", "Count reconnects by cause, this session and today; latest errors")
+	uRow(26, "stats export [-o <file>]", "Write uptime, reconnects, errors, connect times (CSV/JSON)")
+	uRow(26, "config reload", "Apply notification/flap/hint settings to running sessions (or SIGHUP)")
+	uRow(26, "logs export", "Merged, timestamped logs for an incident doc (--since, -o)")
+	uRow(26, "env [--template <file>]", "Print live endpoints as dotenv, or render a Go template")
+	uRow(26, "mt, maintenance <name>", "Pause reconnects and alerts for a service (--for 1h, --end)")
+	uRow(26, "deprecate <name> --use <n>", "Mark a service deprecated (--sunset 2026-12-31, --note, --undo)")
+	uRow(26, "override <name> --port <p>", "Change a service on this machine only (--context, --command, --clear)")
+	uRow(26, "record <name> --listen <p>", "Relay a running forward on <p> and record its traffic")
+	uRow(26, "replay <file> --listen <p>", "Serve a recording on <p> as a mock of the remote end")
+	uRow(26, "chaos <name> --latency <d>", "Degrade a relayed forward (--jitter, --bandwidth, --drop 1%, --off)")
+	uRow(26, "dns <name> [--domain <d>]", "Show how to resolve a domain through a DNS service's relay")
+	uRow(26, "docker --network <n>", "Publish running forwards in a Docker network as <name>:<port>")
+	uRow(26, "ssh trust <name> [--yes]", "Fetch and pin the host key of a service's ssh destination")
+	uRow(26, "discover --from-annotations", "List forwards annotated on a namespace's Services (-n, --save)")
+	uRow(26, "catalog [sync]", "List remote catalogs (run their services as catalog/name), or refresh them")
+	uRow(26, "catalog sync --insecure", "Refresh catalogs even if their signature is missing or wrong")
+	uRow(26, "catalog trust <c/name>", "Run a catalog service its allow list refuses (untrust to undo)")
 	uRow(26, "c, cleanup [--all]", "Free configured ports (--all kills all kubectl/ssh)")
-	uRow(26, "edit", "Edit all services and groups as JSON")
+	uRow(26, "edit, config", "Edit all services and groups as JSON")
+	uRow(26, "lint", "Check services and groups for common problems, with fixes")
+	uRow(26, "report [--days n]", "Sum up the catalog; list idle services/groups, shared targets and ports")
+	uRow(26, "schema [name]", "Print the JSON Schemas of status output, events and catalogs")
+	uRow(26, "selftest", "Check this install end to end against a local echo server")
+	uRow(26, "probes", "List the probe plugins monitors can run (monitor --probe)")
+	uRow(26, "forwarders", "List the forwarder plugins services can run (plugin <name>)")
+	uRow(26, "state [-f json]", "Show where pf keeps its files, their sizes and access")
+	uRow(26, "prune", "Delete or archive services that can no longer work (--archived, --restore)")
+	uRow(26, "apply -f <stack.json>", "Save a stack's services/groups and run what it lists (--dry-run)")
 	uRow(26, "theme [name|list]", "Change the color theme")
 	uRow(26, "icon [on|off|status]", "Toggle service icons")
 	uRow(26, "completion install", "Install shell tab-completion")
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// runHistoryCommand shows a service's current command and lists its earlier
+// ones, newest first, numbered as pf rollback --to takes them.
+func runHistoryCommand(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: pf history <name>")
+		os.Exit(exitError)
+	}
+
+	name := args[0]
+	st := storage.NewStorage()
+	command, err := st.GetService(name)
+	if err != nil {
+		fatal(err)
+	}
+	revisions, err := st.ServiceHistory(name)
+	if err != nil {
+		fatal(err)
+	}
+
+	if len(revisions) == 0 {
+		fmt.Printf("%s: %s\n", name, command)
+		lipgloss.Println(cliMuted.Render("No earlier commands"))
+		return
+	}
+	items := make([][2]string, 0, len(revisions))
+	for _, r := range revisions {
+		items = append(items, [2]string{r.Command, "replaced " + r.Replaced.Local().Format("2006-01-02 15:04")})
+	}
+	printList("History of "+name, "(now: "+command+")", items)
+	lipgloss.Println(cliMuted.Render(fmt.Sprintf("pf rollback %s --to <n> restores one", name)))
+}
+
+// runRollbackCommand restores an earlier command of a service. Sessions
+// running it restart it with that command within a second, as after pf
+// switch.
+func runRollbackCommand(args []string, to int) {
+	if len(args) != 1 {
+		fmt.Println("Usage: pf rollback <name> [--to <n>]")
+		os.Exit(exitError)
+	}
+
+	name := args[0]
+	command, err := storage.NewStorage().RollbackService(name, to)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("✓ '%s' rolled back: %s\n", name, command)
+	fmt.Println("  Running sessions reconnect with it within a second.")
+}
@@ -20,8 +20,7 @@ func runIconCommand(args []string) {
 	case "", "status":
 		enabled, err := st.IconEnabled()
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			fatal(err)
 		}
 		printIconStatus(enabled)
 	case "on", "enable", "true":
@@ -31,14 +30,13 @@ func runIconCommand(args []string) {
 	default:
 		fmt.Printf("Unknown option: %s\n", action)
 		fmt.Println("Usage: pf icon [on|off|status]")
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 }
 
 func setIcons(st *storage.Storage, enabled bool) {
 	if err := st.SetIconEnabled(enabled); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
 	}
 	printIconStatus(enabled)
 	if enabled {
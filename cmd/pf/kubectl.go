@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/alinemone/go-port-forward/internal/cert"
 )
@@ -14,9 +16,26 @@ func runKubectlCommand(args []string) {
 		fmt.Println("Usage: pf kubectl <kubectl-args...>")
 		fmt.Println("Alias: pf k <kubectl-args...>")
 		fmt.Println("Example: pf k get pods -n production")
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
+	cmd := exec.Command("kubectl", withCertArgs(args)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitErr.ExitCode())
+		}
+		fmt.Printf("Error: failed to run kubectl: %v\n", err)
+		os.Exit(exitError)
+	}
+}
+
+// withCertArgs prepends the configured client certificate to kubectl args,
+// unless they already name one.
+func withCertArgs(args []string) []string {
 	finalArgs := append([]string{}, args...)
 
 	certMgr, err := cert.NewManager()
@@ -29,19 +48,19 @@ func runKubectlCommand(args []string) {
 			finalArgs = append(certArgs, finalArgs...)
 		}
 	}
+	return finalArgs
+}
 
-	cmd := exec.Command("kubectl", finalArgs...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			os.Exit(exitErr.ExitCode())
-		}
-		fmt.Printf("Error: failed to run kubectl: %v\n", err)
-		os.Exit(1)
+// currentKubeContext returns kubectl's current context for display, or "" if
+// kubectl is missing, unconfigured, or slow to answer.
+func currentKubeContext() string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "kubectl", "config", "current-context").Output()
+	if err != nil {
+		return ""
 	}
+	return strings.TrimSpace(string(out))
 }
 
 func hasKubectlClientCertArgs(args []string) bool {
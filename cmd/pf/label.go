@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// runLabelCommand sets labels on a service ("team=payments") and removes them
+// ("team-"); with no labels it shows the service's. Labels select services as
+// a virtual group: pf run label:team=payments.
+func runLabelCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: pf label <name> [key=value ...] [key- ...]")
+		fmt.Println("Example: pf label api team=payments tier=backend")
+		os.Exit(exitError)
+	}
+	st := storage.NewStorage()
+	name := args[0]
+	if len(args) == 1 {
+		printLabels(st, name)
+		return
+	}
+
+	set := map[string]string{}
+	var remove []string
+	for _, arg := range args[1:] {
+		if key, value, ok := strings.Cut(arg, "="); ok {
+			set[key] = value
+		} else if key, ok := strings.CutSuffix(arg, "-"); ok {
+			remove = append(remove, key)
+		} else {
+			fmt.Printf("Error: '%s' is neither key=value nor key- to remove a label\n", arg)
+			os.Exit(exitError)
+		}
+	}
+	if err := st.SetLabels(name, set, remove); err != nil {
+		fatal(err)
+	}
+	labels, _ := st.Labels(name)
+	if len(labels) == 0 {
+		fmt.Printf("✓ '%s' has no labels\n", name)
+		return
+	}
+	fmt.Printf("✓ '%s' labels: %s\n", name, formatLabels(labels))
+}
+
+func printLabels(st *storage.Storage, name string) {
+	if _, err := st.GetService(name); err != nil {
+		fatal(err)
+	}
+	labels, err := st.Labels(name)
+	if err != nil {
+		fatal(err)
+	}
+	if len(labels) == 0 {
+		lipgloss.Println(cliMuted.Render(fmt.Sprintf("'%s' has no labels", name)))
+		return
+	}
+	fmt.Println(formatLabels(labels))
+}
+
+// formatLabels lists labels as key=value, sorted by key.
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, key+"="+labels[key])
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/lint"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// runLintCommand checks the stored services and groups and prints each
+// problem with a suggested fix. It exits 1 if any is an error, so it can gate
+// a shared config in CI.
+func runLintCommand() {
+	data, err := storage.NewStorage().LoadData()
+	if err != nil {
+		fatal(err)
+	}
+
+	contexts, err := kubeContexts()
+	if err != nil {
+		lipgloss.Println(cliMuted.Render("Skipped the kube context check: " + err.Error()))
+	}
+	findings := lint.Check(data, contexts)
+	if len(findings) == 0 {
+		lipgloss.Println(cliMuted.Render(fmt.Sprintf("No problems in %d services and %d groups", len(data.Services), len(data.Groups))))
+		return
+	}
+
+	failed := 0
+	items := make([][2]string, 0, len(findings))
+	for _, f := range findings {
+		mark := "!"
+		if f.Severity == lint.Error {
+			mark = "✗"
+			failed++
+		}
+		items = append(items, [2]string{fmt.Sprintf("%s %s: %s", mark, f.Subject, f.Problem), f.Fix})
+	}
+	printList("Lint", fmt.Sprintf("(%d errors, %d warnings)", failed, len(findings)-failed), items)
+	if failed > 0 {
+		os.Exit(exitError)
+	}
+}
+
+// kubeContexts returns the contexts in the kubeconfig, or an error when
+// kubectl cannot tell.
+func kubeContexts() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "kubectl", "config", "get-contexts", "-o", "name").Output()
+	if err != nil {
+		return nil, fmt.Errorf("kubectl config get-contexts: %v", err)
+	}
+	return strings.Fields(string(out)), nil
+}
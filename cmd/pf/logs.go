@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/logexport"
+	"github.com/alinemone/go-port-forward/internal/status"
+)
+
+// runLogsExportCommand gathers the logs the running sessions hold for
+// services (all when empty), merges them with their status changes in time
+// order between since and until, and writes them to output, or stdout when
+// it is empty, ready to paste into an incident doc.
+func runLogsExportCommand(since, until, services, output string) {
+	now := time.Now()
+	var from, to time.Time
+	var err error
+	if since != "" {
+		if from, err = logexport.ParseTime(since, now); err != nil {
+			fmt.Printf("Error: --since: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+	if until != "" {
+		if to, err = logexport.ParseTime(until, now); err != nil {
+			fmt.Printf("Error: --until: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+	var names []string
+	for _, name := range strings.Split(services, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	dir, err := status.Dir()
+	if err != nil {
+		fatal(err)
+	}
+	sessions, err := status.ReadSessions(dir, now)
+	if err != nil {
+		fatal(err)
+	}
+	var lines []logexport.Line
+	asked := 0
+	for _, session := range sessions {
+		if len(names) > 0 && !slices.ContainsFunc(session.Services, func(svc status.Service) bool {
+			return slices.Contains(names, svc.Name)
+		}) {
+			continue
+		}
+		asked++
+		dumped, err := exportSession(dir, session)
+		if err != nil {
+			fatal(err)
+		}
+		lines = append(lines, dumped...)
+	}
+	if asked == 0 {
+		fmt.Println("Error: none of these services is running (export reads the logs of running sessions)")
+		os.Exit(exitNoSession)
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := logexport.Write(w, logexport.Select(lines, names, from, to)); err != nil {
+		fatal(err)
+	}
+	if output != "" {
+		fmt.Printf("✓ Logs written to %s\n", output)
+	}
+}
+
+// exportSession asks a running session to dump its logs next to its status
+// file and reads them back.
+func exportSession(dir string, session status.Session) ([]logexport.Line, error) {
+	path := filepath.Join(dir, fmt.Sprintf("%d.export-%d", session.PID, os.Getpid()))
+	defer os.Remove(path)
+	if err := sendSessionRequest(dir, session, status.Request{Export: []string{path}}); err != nil {
+		return nil, err
+	}
+	for deadline := time.Now().Add(sessionRequestWait); ; time.Sleep(100 * time.Millisecond) {
+		lines, err := logexport.ReadDump(path)
+		if err == nil {
+			return lines, nil
+		}
+		if !os.IsNotExist(err) || time.Now().After(deadline) {
+			return nil, fmt.Errorf("the session running '%s' (pid %d) did not export its logs: %v", session.Label, session.PID, err)
+		}
+	}
+}
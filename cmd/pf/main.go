@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	_ "time/tzdata" // schedule zones on systems without a zoneinfo database
 
+	"github.com/alinemone/go-port-forward/forwarder"
+	"github.com/alinemone/go-port-forward/internal/manager"
 	"github.com/alinemone/go-port-forward/internal/storage"
 	"github.com/alinemone/go-port-forward/internal/theme"
 	"github.com/alinemone/go-port-forward/internal/ui"
@@ -10,6 +17,36 @@ import (
 )
 
 func main() {
+	// ssh runs pf as its askpass for services that prompt in the TUI; see
+	// manager.Askpass.
+	if manager.IsAskpass(os.Args) {
+		if err := manager.Askpass(os.Args[1], os.Stdin, os.Stdout, os.Stderr); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitError)
+		}
+		return
+	}
+	// A service that runs a forwarder plugin runs pf as the plugin's host;
+	// see forwarder.Host.
+	if forwarder.IsHost(os.Args) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err := forwarder.Host(ctx, os.Args[2:], os.Stdout, os.Stderr)
+		stop()
+		if err != nil && ctx.Err() == nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(exitError)
+		}
+		return
+	}
+	// pf selftest forwards through pf itself; see selftestForward.
+	if isSelftestForward(os.Args) {
+		if err := selftestForward(os.Args[2], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(exitError)
+		}
+		return
+	}
+
 	updater.CleanupStaleArtifacts()
 	storage.NewStorage().EnsureExists()
 
@@ -24,7 +61,14 @@ func main() {
 		ui.ApplyTheme()
 	}
 
+	// A bad keymap leaves the defaults active rather than blocking startup.
+	if km, err := st.Keymap(); err == nil && km != nil {
+		if err := ui.ApplyKeymap(km); err != nil {
+			fmt.Printf("Warning: %v (using default keys)\n", err)
+		}
+	}
+
 	if err := newRootCmd().Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 }
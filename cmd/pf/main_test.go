@@ -3,18 +3,25 @@ package main
 import (
 	"fmt"
 	"sort"
+	"strings"
 	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
 )
 
 type fakeRunTargetStore struct {
 	services map[string]string
 	groups   map[string][]string
+	disabled map[string]bool
+	labels   map[string]map[string]string
 }
 
-func (f *fakeRunTargetStore) ListServiceNames() ([]string, error) {
+func (f *fakeRunTargetStore) EnabledServiceNames() ([]string, error) {
 	names := make([]string, 0, len(f.services))
 	for name := range f.services {
-		names = append(names, name)
+		if !f.disabled[name] {
+			names = append(names, name)
+		}
 	}
 	sort.Strings(names)
 	return names, nil
@@ -29,19 +36,37 @@ func (f *fakeRunTargetStore) HasNameConflict(name string) (bool, error) {
 func (f *fakeRunTargetStore) GetService(name string) (string, error) {
 	command, exists := f.services[name]
 	if !exists {
-		return "", fmt.Errorf("service '%s' not found", name)
+		return "", fmt.Errorf("service '%s' %w", name, storage.ErrNotFound)
 	}
 	return command, nil
 }
 
-func (f *fakeRunTargetStore) GetGroupServices(name string) ([]string, error) {
-	services, exists := f.groups[name]
+func (f *fakeRunTargetStore) EnabledGroupServices(name string) ([]string, error) {
+	members, exists := f.groups[name]
 	if !exists {
-		return nil, fmt.Errorf("group '%s' not found", name)
+		return nil, fmt.Errorf("group '%s' %w", name, storage.ErrNotFound)
+	}
+	var services []string
+	for _, m := range members {
+		if !f.disabled[m] {
+			services = append(services, m)
+		}
 	}
 	return services, nil
 }
 
+func (f *fakeRunTargetStore) SelectServices(selector string) ([]string, error) {
+	key, value, _ := strings.Cut(strings.TrimPrefix(selector, "label:"), "=")
+	var names []string
+	for name, labels := range f.labels {
+		if got, ok := labels[key]; ok && got == value && !f.disabled[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
 func TestLooksLikeRunTarget(t *testing.T) {
 	st := &fakeRunTargetStore{
 		services: map[string]string{"db": "cmd", "redis": "cmd"},
@@ -180,6 +205,52 @@ func TestResolveRunTargetsAllIgnoresGroups(t *testing.T) {
 	}
 }
 
+func TestResolveRunTargetsSkipsDisabled(t *testing.T) {
+	st := &fakeRunTargetStore{
+		services: map[string]string{"db": "cmd", "api": "cmd", "old": "cmd"},
+		groups:   map[string][]string{"backend": {"old", "db"}},
+		disabled: map[string]bool{"old": true},
+	}
+
+	for input, want := range map[string]string{"all": "api db", "backend": "db", "old": "old", "backend,old": "db old"} {
+		got, err := resolveRunTargets(st, input)
+		if err != nil {
+			t.Fatalf("resolveRunTargets(%q): %v", input, err)
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("resolveRunTargets(%q) = %v, want [%s]", input, got, want)
+		}
+	}
+}
+
+func TestResolveRunTargetsSelectsByLabel(t *testing.T) {
+	st := &fakeRunTargetStore{
+		services: map[string]string{"db": "cmd", "api": "cmd", "old": "cmd", "web": "cmd"},
+		groups:   map[string][]string{},
+		disabled: map[string]bool{"old": true},
+		labels: map[string]map[string]string{
+			"db":  {"team": "payments"},
+			"api": {"team": "payments"},
+			"old": {"team": "payments"},
+			"web": {"team": "growth"},
+		},
+	}
+
+	if !looksLikeRunTarget(st, "label:team=payments") {
+		t.Error("looksLikeRunTarget(label:team=payments) = false")
+	}
+	got, err := resolveRunTargets(st, "label:team=payments,web")
+	if err != nil {
+		t.Fatalf("resolveRunTargets: %v", err)
+	}
+	if strings.Join(got, " ") != "api db web" {
+		t.Errorf("got %v, want [api db web]", got)
+	}
+	if _, err := resolveRunTargets(st, "label:team=nobody"); err == nil {
+		t.Error("a selector matching nothing resolved")
+	}
+}
+
 func TestResolveRunTargetsSkipsEmptyTargets(t *testing.T) {
 	st := &fakeRunTargetStore{
 		services: map[string]string{"db": "cmd", "api": "cmd"},
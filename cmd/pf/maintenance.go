@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// runMaintenanceCommand puts services under maintenance for window, or ends
+// their windows. Running sessions pick the change up within a second: they
+// stop reconnecting and alerting until the window ends. With no names it lists
+// the open windows.
+func runMaintenanceCommand(names []string, window time.Duration, end bool) {
+	st := storage.NewStorage()
+	if len(names) == 0 {
+		printMaintenance(st)
+		return
+	}
+	if !end && window <= 0 {
+		fmt.Println("Error: --for must be positive")
+		os.Exit(exitError)
+	}
+
+	until := time.Now().Add(window).Round(time.Second)
+	for _, name := range names {
+		if end {
+			if err := st.SetMaintenance(name, time.Time{}); err != nil {
+				fatal(err)
+			}
+			fmt.Printf("✓ Maintenance ended for '%s'\n", name)
+			continue
+		}
+		if err := st.SetMaintenance(name, until); err != nil {
+			fatal(err)
+		}
+		fmt.Printf("✓ '%s' is in maintenance until %s\n", name, until.Format("15:04"))
+	}
+}
+
+func printMaintenance(st *storage.Storage) {
+	windows, err := st.MaintenanceWindows(time.Now())
+	if err != nil {
+		fatal(err)
+	}
+	if len(windows) == 0 {
+		lipgloss.Println(cliMuted.Render("No services in maintenance"))
+		return
+	}
+	names := make([]string, 0, len(windows))
+	for name := range windows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := make([][2]string, 0, len(names))
+	for _, name := range names {
+		left := time.Until(windows[name]).Round(time.Minute)
+		items = append(items, [2]string{name, fmt.Sprintf("until %s (%s left)", windows[name].Format("15:04"), left)})
+	}
+	printList("Maintenance", fmt.Sprintf("(%d)", len(names)), items)
+}
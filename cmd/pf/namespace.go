@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/discover"
+	"github.com/alinemone/go-port-forward/internal/hostsfile"
+	"github.com/alinemone/go-port-forward/internal/manager"
+)
+
+// namespacePoll is how often `pf run --all-services` lists the namespace
+// again for Services that came or went.
+const namespacePoll = 30 * time.Second
+
+// namespaceForward forwards every Service of a namespace the way kubefwd
+// does: each Service on a loopback address of its own, keeping its ports,
+// with hosts-file entries for its in-cluster names, so "api:80" or
+// "api.payments.svc.cluster.local:80" reach it as they would from a pod. The
+// forwards run as ad-hoc services of this session, tracked as the group
+// "ns:<namespace>", and follow the namespace as Services are added and
+// deleted.
+type namespaceForward struct {
+	namespace string
+	mgr       *manager.ServiceManager
+	list      func(ctx context.Context) ([]byte, error) // `kubectl get services -o json` of the namespace
+	onError   func(error)
+	reserved  []string // the session's saved services, which keep their names
+	lowPorts  bool     // pf may bind ports below 1024
+	hostsPath string   // "" once the hosts file proved not writable
+
+	ips      discover.Loopback
+	running  map[string]string // pf service → its command
+	reported map[string]string // pf service → the error last reported for it
+	aliases  []string          // loopback addresses added to lo0 (macOS)
+	stopOnce sync.Once
+}
+
+// forwardNamespace starts forwarding namespace's Services into mgr and
+// follows the namespace until ctx ends or the returned stop is called. What
+// it cannot forward is reported as a session notice (see
+// ServiceManager.Notice), as the TUI may own the terminal. stop
+// removes the hosts-file entries and loopback addresses it added; call it
+// before stopping the services. A Service named like one of serviceNames,
+// the saved services the session runs, is skipped. It exits when the
+// namespace cannot be listed at all.
+func forwardNamespace(ctx context.Context, mgr *manager.ServiceManager, namespace string, serviceNames []string) (stop func()) {
+	f := &namespaceForward{
+		namespace: namespace,
+		mgr:       mgr,
+		list:      func(ctx context.Context) ([]byte, error) { return getServices(ctx, namespace) },
+		onError:   func(err error) { mgr.Notice(err.Error()) },
+		lowPorts:  canBindLowPorts(),
+		hostsPath: hostsfile.Path(),
+		reserved:  serviceNames,
+		running:   map[string]string{},
+		reported:  map[string]string{},
+	}
+	out, err := f.list(ctx)
+	if err != nil {
+		fatal(err)
+	}
+	hosts, err := f.apply(ctx, out)
+	if err != nil {
+		fatal(err)
+	}
+	if len(f.running) == 0 {
+		lipgloss.Println(cliMuted.Render(fmt.Sprintf("No Services in %s yet; pf keeps looking every %s", namespace, namespacePoll)))
+	}
+	if err := f.setHosts(hosts); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		fmt.Println("  Adding the Services' names takes root (an administrator on Windows); until then they answer on these addresses:")
+		for _, line := range hostsfile.Lines(hosts) {
+			fmt.Println("    " + line)
+		}
+		f.hostsPath = ""
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.follow(ctx)
+	}()
+	return func() {
+		f.stopOnce.Do(func() {
+			cancel()
+			<-done
+			_ = f.setHosts(nil)
+			for _, ip := range f.aliases {
+				_ = exec.Command("ifconfig", "lo0", "-alias", ip).Run()
+			}
+		})
+	}
+}
+
+// follow lists the namespace every namespacePoll and applies what changed.
+// A failed listing keeps the forwards as they are until the next one.
+func (f *namespaceForward) follow(ctx context.Context) {
+	ticker := time.NewTicker(namespacePoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		out, err := f.list(ctx)
+		if err != nil {
+			continue
+		}
+		if hosts, err := f.apply(ctx, out); err == nil {
+			_ = f.setHosts(hosts)
+		}
+	}
+}
+
+// apply makes the session's forwards match the Services listed in out:
+// new Services start, deleted ones stop and changed ones restart. It returns
+// the hosts-file entries for what runs. A Service's error is reported once,
+// not on every poll, until it changes or clears.
+func (f *namespaceForward) apply(ctx context.Context, out []byte) ([]hostsfile.Entry, error) {
+	forwards, err := discover.AllServices(out, "", &f.ips, f.lowPorts)
+	if err != nil {
+		return nil, err
+	}
+	others := slices.Clone(f.reserved)
+	for _, s := range f.mgr.ListServiceStates() {
+		if _, own := f.running[s.Name]; !own {
+			others = append(others, s.Name)
+		}
+	}
+
+	wanted := map[string]bool{}
+	addresses := map[string]string{} // Service → loopback address
+	failed := map[string]string{}    // pf service → its error this time
+	report := func(name string, err error) {
+		failed[name] = err.Error()
+		if f.reported[name] != failed[name] {
+			f.onError(err)
+		}
+	}
+	for _, fw := range forwards {
+		if slices.Contains(others, fw.Name) {
+			report(fw.Name, fmt.Errorf("skipped Service %s/%s: the session already runs a service named '%s'", f.namespace, fw.Host, fw.Name))
+			continue
+		}
+		if f.running[fw.Name] == fw.Command {
+			wanted[fw.Name] = true
+			addresses[fw.Host] = fw.IP
+			continue
+		}
+		if err := f.addAlias(fw.IP); err != nil {
+			report(fw.Name, err)
+			continue
+		}
+		if err := f.mgr.StartAdhoc(ctx, fw.Name, fw.Command); err != nil {
+			report(fw.Name, err)
+			continue
+		}
+		f.running[fw.Name] = fw.Command
+		wanted[fw.Name] = true
+		addresses[fw.Host] = fw.IP
+	}
+	f.reported = failed
+	for name := range f.running {
+		if !wanted[name] {
+			f.mgr.StopService(name)
+			delete(f.running, name)
+		}
+	}
+
+	members := make([]string, 0, len(f.running))
+	for name := range f.running {
+		members = append(members, name)
+	}
+	sort.Strings(members)
+	f.mgr.TrackGroup("ns:"+f.namespace, members)
+
+	hosts := make([]hostsfile.Entry, 0, len(addresses))
+	for host, ip := range addresses {
+		hosts = append(hosts, hostsfile.Entry{IP: ip, Names: hostsfile.Names(host, f.namespace)})
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Names[0] < hosts[j].Names[0] })
+	return hosts, nil
+}
+
+// setHosts writes the namespace's block of the hosts file.
+func (f *namespaceForward) setHosts(entries []hostsfile.Entry) error {
+	if f.hostsPath == "" {
+		return nil
+	}
+	return hostsfile.Set(f.hostsPath, "ns:"+f.namespace, entries)
+}
+
+// addAlias makes ip a loopback address. Linux and Windows route all of
+// 127.0.0.0/8 to loopback already; macOS answers only on 127.0.0.1 until
+// lo0 gets an alias, which takes root.
+func (f *namespaceForward) addAlias(ip string) error {
+	if runtime.GOOS != "darwin" || slices.Contains(f.aliases, ip) {
+		return nil
+	}
+	if out, err := exec.Command("ifconfig", "lo0", "alias", ip, "up").CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot add loopback address %s (run pf with sudo): %v %s", ip, err, out)
+	}
+	f.aliases = append(f.aliases, ip)
+	return nil
+}
+
+// canBindLowPorts reports whether pf may listen on ports below 1024 without
+// moving them up: as root, and on macOS and Windows, which do not reserve
+// them.
+func canBindLowPorts() bool {
+	return runtime.GOOS == "darwin" || runtime.GOOS == "windows" || os.Geteuid() == 0
+}
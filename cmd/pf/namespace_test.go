@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/manager"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// TestNamespaceReportsSkipsOnce reports a Service that clashes with a saved
+// service once, not on every poll, and again once it clashed no more.
+func TestNamespaceReportsSkipsOnce(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	var reported []string
+	f := &namespaceForward{
+		namespace: "payments",
+		mgr:       manager.NewServiceManager(storage.NewStorage()),
+		onError:   func(err error) { reported = append(reported, err.Error()) },
+		reserved:  []string{"api"},
+		running:   map[string]string{},
+		reported:  map[string]string{},
+	}
+	api := []byte(`{"items": [{"metadata": {"name": "api", "namespace": "payments"}, "spec": {"ports": [{"port": 80}]}}]}`)
+	for range 3 {
+		if _, err := f.apply(context.Background(), api); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(reported) != 1 || !strings.Contains(reported[0], "skipped Service payments/api") {
+		t.Fatalf("reported %q, want the skip once", reported)
+	}
+
+	if _, err := f.apply(context.Background(), []byte(`{"items": []}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.apply(context.Background(), api); err != nil {
+		t.Fatal(err)
+	}
+	if len(reported) != 2 {
+		t.Errorf("reported %q, want the skip again after it cleared", reported)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/proc"
+	"github.com/alinemone/go-port-forward/internal/status"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// Answers to the taken-ports prompt.
+const (
+	takenSkip = "skip"
+	takenFree = "free"
+	takenRun  = "run"
+	takenQuit = "quit"
+)
+
+// portFreeWait is how long a run waits for the ports it freed to close.
+const portFreeWait = 5 * time.Second
+
+// portClaim is a local port a service about to start needs, and who already
+// holds it: another pf session, or another program such as kubefwd or a
+// kubectl port-forward started by hand.
+type portClaim struct {
+	service string
+	host    string // where the service listens
+	port    string
+	session *status.Session // the pf session that runs a service there, if one does
+	owners  []proc.Process  // otherwise, what listens there as far as the system tells
+}
+
+func (c portClaim) holder() string {
+	if c.session != nil {
+		return sessionName(*c.session)
+	}
+	if len(c.owners) == 0 {
+		return "another program"
+	}
+	names := make([]string, len(c.owners))
+	for i, o := range c.owners {
+		names[i] = o.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// portProbe asks the machine about a port: inUse whether pf could not listen
+// on it at host, listeners what does. portInUse and proc.ListListeners outside
+// tests.
+type portProbe struct {
+	inUse     func(host, port string) bool
+	listeners func(port string) []proc.Process
+}
+
+// findPortClaims returns the services among names whose local port is taken,
+// by another pf session (from sessions; self is this one's pid) or by any
+// other program. Another pf session is found from what it publishes, even
+// while its forward is down and the port is free for the moment.
+func findPortClaims(st *storage.Storage, names []string, sessions []status.Session, self int, p portProbe) []portClaim {
+	var claims []portClaim
+	for _, name := range names {
+		command, err := st.LocalCommand(name)
+		if err != nil || cmdline.IsMonitor(command) {
+			continue
+		}
+		port, _ := cmdline.ParsePorts(command)
+		if port == "" {
+			continue
+		}
+		host := cmdline.ParseForward(command).Address
+		if host == "" || host == "localhost" {
+			host = "127.0.0.1"
+		}
+		if s := sessionOnPort(sessions, self, port); s != nil {
+			claims = append(claims, portClaim{service: name, host: host, port: port, session: s})
+			continue
+		}
+		if p.inUse(host, port) {
+			claims = append(claims, portClaim{service: name, host: host, port: port, owners: p.listeners(port)})
+		}
+	}
+	return claims
+}
+
+// sessionName names a pf session for the user, e.g. "pf session 'backend'
+// (pid 4410)".
+func sessionName(s status.Session) string {
+	label := s.Label
+	if label == "" {
+		label = "pf"
+	}
+	return fmt.Sprintf("pf session '%s' (pid %d)", label, s.PID)
+}
+
+// sessionOnPort returns the other pf session running a service on port.
+func sessionOnPort(sessions []status.Session, self int, port string) *status.Session {
+	for i := range sessions {
+		if sessions[i].PID == self {
+			continue
+		}
+		if slices.ContainsFunc(sessions[i].Services, func(svc status.Service) bool { return svc.Port == port }) {
+			return &sessions[i]
+		}
+	}
+	return nil
+}
+
+// portInUse reports whether pf could not listen on port at host.
+func portInUse(host, port string) bool {
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return true
+	}
+	ln.Close()
+	return false
+}
+
+// reconcilePorts checks the local ports of the services about to run before
+// any starts, so pf does not fight another session or tool over them, and
+// returns the services to run. In a terminal it lists what holds each taken
+// port and asks whether to skip those services, free their ports (asking
+// another pf session to stop its service, stopping any other program), run
+// anyway or quit. Without one it warns and runs everything, as before.
+func reconcilePorts(st *storage.Storage, names []string) []string {
+	var sessions []status.Session
+	if dir, err := status.Dir(); err == nil {
+		sessions, _ = status.ReadSessions(dir, time.Now())
+	}
+	claims := findPortClaims(st, names, sessions, os.Getpid(), portProbe{portInUse, proc.ListListeners})
+	if len(claims) == 0 {
+		return names
+	}
+
+	lipgloss.Println()
+	lipgloss.Println(cliHeading.Render("Ports already taken"))
+	for _, c := range claims {
+		fmt.Printf("  • %s needs port %s: %s\n", c.service, c.port, c.holder())
+	}
+	if !stdinIsTerminal() {
+		lipgloss.Println(cliMuted.Render("  Starting anyway: these services retry until their port is free."))
+		return names
+	}
+
+	switch askTakenPorts(bufio.NewReader(os.Stdin)) {
+	case takenSkip:
+		return slices.DeleteFunc(slices.Clone(names), func(name string) bool {
+			return slices.ContainsFunc(claims, func(c portClaim) bool { return c.service == name })
+		})
+	case takenFree:
+		freePorts(claims)
+	case takenQuit:
+		os.Exit(exitError)
+	}
+	return names
+}
+
+// askTakenPorts asks what to do about taken ports. EOF quits.
+func askTakenPorts(in *bufio.Reader) string {
+	for {
+		fmt.Print("[s]kip these services, [f]ree the ports, [r]un anyway or [q]uit? ")
+		answer, err := readAnswer(in)
+		if err != nil {
+			return takenQuit
+		}
+		switch strings.ToLower(answer) {
+		case "s", "skip":
+			return takenSkip
+		case "f", "free":
+			return takenFree
+		case "r", "run":
+			return takenRun
+		case "q", "quit":
+			return takenQuit
+		}
+	}
+}
+
+// freePorts asks other pf sessions to stop the services on the claimed
+// ports, stops any other program holding one, and waits a moment for the
+// ports to close.
+func freePorts(claims []portClaim) {
+	dir, dirErr := status.Dir()
+	stop := map[int][]string{} // session pid → its services to stop
+	sessions := map[int]status.Session{}
+	for _, c := range claims {
+		if c.session == nil {
+			if killed := proc.FreePort(c.port); len(killed) > 0 {
+				fmt.Printf("  • port %s: stopped PID(s) %v\n", c.port, killed)
+			}
+			continue
+		}
+		for _, svc := range c.session.Services {
+			if svc.Port == c.port && !slices.Contains(stop[c.session.PID], svc.Name) {
+				stop[c.session.PID] = append(stop[c.session.PID], svc.Name)
+			}
+		}
+		sessions[c.session.PID] = *c.session
+	}
+	for pid, services := range stop {
+		err := dirErr
+		if err == nil {
+			err = sendSessionRequest(dir, sessions[pid], status.Request{Stop: services})
+		}
+		if err != nil {
+			fmt.Printf("  • cannot ask %s to stop %s: %v\n", sessionName(sessions[pid]), strings.Join(services, ", "), err)
+			continue
+		}
+		fmt.Printf("  • asked %s to stop %s\n", sessionName(sessions[pid]), strings.Join(services, ", "))
+	}
+
+	for deadline := time.Now().Add(portFreeWait); time.Now().Before(deadline); time.Sleep(200 * time.Millisecond) {
+		if !slices.ContainsFunc(claims, func(c portClaim) bool { return portInUse(c.host, c.port) }) {
+			return
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/proc"
+	"github.com/alinemone/go-port-forward/internal/status"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+func TestFindPortClaims(t *testing.T) {
+	withTempHome(t)
+	st := storage.NewStorage()
+	for name, command := range map[string]string{
+		"db":    "kubectl port-forward svc/db 5432:5432",
+		"api":   "kubectl port-forward svc/api 8080:80",
+		"cache": "kubectl port-forward --address 0.0.0.0 svc/redis 6379:6379",
+		"free":  "kubectl port-forward svc/free 9000:9000",
+	} {
+		if err := st.AddService(name, command); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sessions := []status.Session{
+		{PID: 1, Label: "self", Services: []status.Service{{Name: "free", Port: "9000"}}},
+		{PID: 2, Label: "backend", Services: []status.Service{{Name: "api", Port: "8080"}}},
+	}
+	var asked []string
+	probe := portProbe{
+		inUse: func(host, port string) bool {
+			asked = append(asked, host+":"+port)
+			return port == "5432" || port == "6379"
+		},
+		listeners: func(port string) []proc.Process {
+			if port == "5432" {
+				return []proc.Process{{PID: 812, Name: "kubefwd"}}
+			}
+			return nil
+		},
+	}
+
+	claims := findPortClaims(st, []string{"db", "api", "cache", "free"}, sessions, 1, probe)
+	var got []string
+	for _, c := range claims {
+		got = append(got, c.service+" "+c.port+": "+c.holder())
+	}
+	want := []string{
+		"db 5432: kubefwd (pid 812)",
+		"api 8080: pf session 'backend' (pid 2)",
+		"cache 6379: another program",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("claims:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if strings.Join(asked, " ") != "127.0.0.1:5432 0.0.0.0:6379 127.0.0.1:9000" {
+		t.Errorf("asked about %v; this session's own services and other sessions' ports need no probe", asked)
+	}
+}
+
+func TestAskTakenPorts(t *testing.T) {
+	for input, want := range map[string]string{
+		"s\n":      takenSkip,
+		"Free\n":   takenFree,
+		"x\nr\n":   takenRun,
+		"q\n":      takenQuit,
+		"":         takenQuit,
+		"\nskip\n": takenSkip,
+	} {
+		if got := askTakenPorts(bufio.NewReader(strings.NewReader(input))); got != want {
+			t.Errorf("answers %q: got %s, want %s", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/discover"
+	"github.com/alinemone/go-port-forward/internal/lint"
+	"github.com/alinemone/go-port-forward/internal/manager"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// onboarding is the first-run guide: it offers to import forwards from the
+// ssh config or a Kubernetes namespace, or to add one by hand, then explains
+// the commands to go on with. Its fields reach the outside world, so tests
+// can stand in for them.
+type onboarding struct {
+	in           *bufio.Reader
+	sshConfig    func() ([]byte, error)
+	kubeContexts func() ([]string, error)
+	kubeServices func(kubeContext, namespace string) ([]byte, error)
+	lookPath     func(string) (string, error)
+	taken        func(name string) bool
+	save         func(name, command string) error
+	saved        []string
+}
+
+// isFirstRun reports whether the config has nothing to run yet: no services,
+// groups or catalogs.
+func isFirstRun(st *storage.Storage) bool {
+	data, err := st.LoadData()
+	return err == nil && len(data.Services) == 0 && len(data.Groups) == 0 && len(data.Catalogs) == 0
+}
+
+// runOnboarding walks a new user through adding their first services, then
+// shows where to go from there.
+func runOnboarding(st *storage.Storage) {
+	o := &onboarding{
+		in: bufio.NewReader(os.Stdin),
+		sshConfig: func() ([]byte, error) {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			return os.ReadFile(filepath.Join(home, ".ssh", "config"))
+		},
+		kubeContexts: kubeContexts,
+		kubeServices: func(kubeContext, namespace string) ([]byte, error) {
+			args := []string{"get", "services", "-o", "json", "-n", namespace}
+			if kubeContext != "" {
+				args = append(args, "--context", kubeContext)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			out, err := exec.CommandContext(ctx, "kubectl", withCertArgs(args)...).Output()
+			if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+				err = fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+			}
+			return out, err
+		},
+		lookPath: exec.LookPath,
+		taken:    func(name string) bool { return nameTaken(st, name) },
+		save:     st.AddService,
+	}
+	o.run()
+}
+
+// run asks what to do until the user is done, then prints the next steps.
+func (o *onboarding) run() {
+	fmt.Println()
+	lipgloss.Println(cliHeading.Render("Welcome to pf"))
+	fmt.Println("pf keeps kubectl and ssh port forwards running. You have no services yet;")
+	fmt.Println("let's add the first ones.")
+
+	sshForwards, sshSkipped := o.sshForwards()
+	for {
+		var choices []string
+		if len(sshForwards) > 0 {
+			choices = append(choices, fmt.Sprintf("Import from ~/.ssh/config (%d LocalForward entries)", len(sshForwards)))
+		}
+		choices = append(choices, "Import Services from a Kubernetes namespace", "Add a service by hand")
+		if len(o.saved) == 0 {
+			choices = append(choices, "Skip")
+		} else {
+			choices = append(choices, "Done")
+		}
+
+		fmt.Println()
+		for i, choice := range choices {
+			fmt.Printf("  %d. %s\n", i+1, choice)
+		}
+		fmt.Printf("Choose [1-%d]: ", len(choices))
+		answer, err := readAnswer(o.in)
+		if err != nil {
+			break
+		}
+		n, err := strconv.Atoi(answer)
+		if err != nil || n < 1 || n > len(choices) {
+			continue
+		}
+		if len(sshForwards) == 0 {
+			n++ // numbering starts at the Kubernetes import
+		}
+		switch n {
+		case 1:
+			for _, err := range sshSkipped {
+				lipgloss.Println(cliMuted.Render("Skipped " + err.Error()))
+			}
+			o.pick(sshForwards)
+			continue
+		case 2:
+			o.importKubernetes()
+			continue
+		case 3:
+			o.addByHand()
+			continue
+		}
+		break
+	}
+	o.printNextSteps()
+}
+
+// sshForwards reads the ssh config's LocalForwards, if there is a config.
+func (o *onboarding) sshForwards() ([]discover.Service, []error) {
+	data, err := o.sshConfig()
+	if err != nil {
+		return nil, nil
+	}
+	return discover.FromSSHConfig(data)
+}
+
+// importKubernetes asks for a context and namespace and offers a forward for
+// each Service in it.
+func (o *onboarding) importKubernetes() {
+	contexts, err := o.kubeContexts()
+	if err != nil || len(contexts) == 0 {
+		fmt.Println("No kube contexts found: is kubectl installed and configured?")
+		return
+	}
+	for i, c := range contexts {
+		fmt.Printf("  %d. %s\n", i+1, c)
+	}
+	fmt.Print("Context [Enter for the current one]: ")
+	kubeContext, err := readAnswer(o.in)
+	if err != nil {
+		return
+	}
+	if n, err := strconv.Atoi(kubeContext); err == nil && n >= 1 && n <= len(contexts) {
+		kubeContext = contexts[n-1]
+	}
+	fmt.Print("Namespace [default]: ")
+	namespace, err := readAnswer(o.in)
+	if err != nil {
+		return
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	out, err := o.kubeServices(kubeContext, namespace)
+	if err != nil {
+		fmt.Printf("Could not list its Services: %v\n", err)
+		return
+	}
+	found, err := discover.FromServices(out, kubeContext)
+	if err != nil {
+		fmt.Printf("Could not list its Services: %v\n", err)
+		return
+	}
+	if len(found) == 0 {
+		fmt.Printf("No Services in %s\n", namespace)
+		return
+	}
+	o.pick(found)
+}
+
+// pick lists candidate services and saves the ones the user chooses. Names
+// already taken or not valid are reported and left out.
+func (o *onboarding) pick(candidates []discover.Service) {
+	items := make([][2]string, 0, len(candidates))
+	for _, svc := range candidates {
+		items = append(items, [2]string{svc.Name, svc.Command})
+	}
+	printList("Found", fmt.Sprintf("(%d)", len(candidates)), items)
+	fmt.Print("Add which? [all, none, or numbers like 1,3]: ")
+	answer, err := readAnswer(o.in)
+	if err != nil {
+		return
+	}
+	chosen, err := parseSelection(answer, len(candidates))
+	if err != nil {
+		fmt.Printf("%v; nothing added\n", err)
+		return
+	}
+	for _, i := range chosen {
+		svc := candidates[i]
+		if err := manager.ValidateServiceName(svc.Name); err != nil {
+			fmt.Printf("! Skipped '%s': %v\n", svc.Name, err)
+			continue
+		}
+		if o.taken(svc.Name) {
+			fmt.Printf("! Skipped '%s': the name is taken\n", svc.Name)
+			continue
+		}
+		o.add(svc.Name, svc.Command)
+	}
+}
+
+// addByHand asks for a name and a command, as pf add would take them, until
+// both are usable or the user gives up with an empty answer.
+func (o *onboarding) addByHand() {
+	var name string
+	for name == "" {
+		fmt.Print("Name (e.g. db): ")
+		answer, err := readAnswer(o.in)
+		if err != nil || answer == "" {
+			return
+		}
+		if err := manager.ValidateServiceName(answer); err != nil {
+			fmt.Printf("Invalid name: %v\n", err)
+			continue
+		}
+		if o.taken(answer) {
+			fmt.Printf("'%s' is taken\n", answer)
+			continue
+		}
+		name = answer
+	}
+	for {
+		fmt.Print("Command (e.g. kubectl port-forward svc/postgres 5432:5432): ")
+		command, err := readAnswer(o.in)
+		if err != nil || command == "" {
+			return
+		}
+		broken := false
+		for _, f := range lint.CheckCommand(command, o.lookPath) {
+			if f.Severity == lint.Error {
+				broken = true
+				fmt.Printf("✗ %s\n", f.Problem)
+				lipgloss.Println(cliMuted.Render("  → " + f.Fix))
+			}
+		}
+		if !broken {
+			o.add(name, command)
+			return
+		}
+	}
+}
+
+func (o *onboarding) add(name, command string) {
+	if err := o.save(name, command); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	o.saved = append(o.saved, name)
+	fmt.Printf("✓ Service '%s' added\n", name)
+}
+
+// printNextSteps explains the commands to go on with, using a saved service
+// in the examples when there is one.
+func (o *onboarding) printNextSteps() {
+	example := "<name>"
+	if len(o.saved) > 0 {
+		example = o.saved[0]
+	}
+	uHead("NEXT STEPS:")
+	uRow(26, "pf "+example, "Start it in the live view (its keys are listed at the bottom)")
+	uRow(26, `pf add <name> "<command>"`, "Save another forward")
+	uRow(26, "pf group add <name> <svcs>", "Start several services together as one name")
+	uRow(26, "pf list", "Show your services")
+	uRow(26, "pf help", "Everything else")
+	fmt.Println()
+}
+
+// parseSelection reads "all", "none" or a list of 1-based numbers such as
+// "1,3 4" into 0-based indexes below n.
+func parseSelection(answer string, n int) ([]int, error) {
+	switch strings.ToLower(answer) {
+	case "a", "all":
+		all := make([]int, n)
+		for i := range all {
+			all[i] = i
+		}
+		return all, nil
+	case "", "none":
+		return nil, nil
+	}
+	var chosen []int
+	for _, field := range strings.FieldsFunc(answer, func(r rune) bool { return r == ',' || r == ' ' }) {
+		i, err := strconv.Atoi(field)
+		if err != nil || i < 1 || i > n {
+			return nil, fmt.Errorf("%q is not one of 1-%d", field, n)
+		}
+		chosen = append(chosen, i-1)
+	}
+	return chosen, nil
+}
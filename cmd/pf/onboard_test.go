@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseSelection(t *testing.T) {
+	for answer, want := range map[string]string{
+		"all":   "[0 1 2]",
+		"":      "[]",
+		"none":  "[]",
+		"1,3":   "[0 2]",
+		"2 3":   "[1 2]",
+		"3, 1":  "[2 0]",
+		"4":     "error",
+		"one":   "error",
+		"0,1":   "error",
+		" all ": "error", // readAnswer trims; parseSelection does not
+	} {
+		got, err := parseSelection(answer, 3)
+		s := fmt.Sprint(got)
+		if got == nil {
+			s = "[]"
+		}
+		if err != nil {
+			s = "error"
+		}
+		if s != want {
+			t.Errorf("parseSelection(%q) = %s, want %s", answer, s, want)
+		}
+	}
+}
+
+func TestOnboardingImportsAndAdds(t *testing.T) {
+	sshConfig := "Host bastion\n  HostName bastion.corp\n  LocalForward 5432 db:5432\n  LocalForward 6379 cache:6379\n"
+	services := `{"items": [{"metadata": {"name": "api", "namespace": "web"}, "spec": {"ports": [{"port": 80}]}}]}`
+	saved := map[string]string{}
+	var asked []string
+	o := &onboarding{
+		// ssh: add the second forward; kubernetes: context 2, namespace web,
+		// all; by hand: a taken name, then a broken command, then a good one.
+		in: bufio.NewReader(strings.NewReader(strings.Join([]string{
+			"1", "2",
+			"2", "2", "web", "all",
+			"3", "api", "db", "kubectl get pods", "kubectl port-forward svc/db 5433:5432",
+			"4",
+		}, "\n") + "\n")),
+		sshConfig:    func() ([]byte, error) { return []byte(sshConfig), nil },
+		kubeContexts: func() ([]string, error) { return []string{"prod", "staging"}, nil },
+		kubeServices: func(kubeContext, namespace string) ([]byte, error) {
+			asked = append(asked, kubeContext+"/"+namespace)
+			return []byte(services), nil
+		},
+		lookPath: func(bin string) (string, error) { return "/usr/bin/" + bin, nil },
+		taken:    func(name string) bool { _, ok := saved[name]; return ok },
+		save:     func(name, command string) error { saved[name] = command; return nil },
+	}
+	o.run()
+
+	want := map[string]string{
+		"bastion-6379": "ssh -N -L 6379:cache:6379 bastion.corp",
+		"api":          "kubectl port-forward --context staging -n web svc/api 8080:80",
+		"db":           "kubectl port-forward svc/db 5433:5432",
+	}
+	if fmt.Sprint(saved) != fmt.Sprint(want) {
+		t.Errorf("saved %v, want %v", saved, want)
+	}
+	if fmt.Sprint(asked) != "[staging/web]" {
+		t.Errorf("listed Services of %v", asked)
+	}
+	if fmt.Sprint(o.saved) != "[bastion-6379 api db]" {
+		t.Errorf("saved in order %v", o.saved)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/manager"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// runOverrideCommand saves this machine's override of a service (another
+// command, local port or kube context) in services.local.json, or with drop
+// removes it. With no name it lists the overrides.
+func runOverrideCommand(args []string, o storage.Override, drop bool) {
+	st := storage.NewStorage()
+	if len(args) == 0 {
+		printOverrides(st)
+		return
+	}
+	if len(args) != 1 || (!drop && o == (storage.Override{})) {
+		fmt.Println("Usage: pf override <name> [--port <p>] [--context <c>] [--command <cmd>] | --clear")
+		fmt.Println("Example: pf override corp/db --port 15432")
+		os.Exit(exitError)
+	}
+
+	name := args[0]
+	if drop {
+		if err := st.SetOverride(name, nil); err != nil {
+			fatal(err)
+		}
+		fmt.Printf("✓ '%s' runs its shared command again\n", name)
+		return
+	}
+	if o.Command != "" {
+		if err := manager.ValidateCommand(o.Command); err != nil {
+			fmt.Printf("Error: invalid command: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
+	if err := st.SetOverride(name, &o); err != nil {
+		fatal(err)
+	}
+	command, err := st.LocalCommand(name)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("✓ '%s' runs here as: %s\n", name, command)
+	lipgloss.Println(cliMuted.Render("  Kept in services.local.json, so it never reaches the shared config."))
+}
+
+func printOverrides(st *storage.Storage) {
+	overrides, err := st.Overrides()
+	if err != nil {
+		fatal(err)
+	}
+	if len(overrides) == 0 {
+		lipgloss.Println(cliMuted.Render("No local overrides"))
+		return
+	}
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := make([][2]string, 0, len(names))
+	for _, name := range names {
+		items = append(items, [2]string{name, overrides[name].Describe()})
+	}
+	printList("Local overrides", fmt.Sprintf("(%d)", len(names)), items)
+}
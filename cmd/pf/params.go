@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alinemone/go-port-forward/internal/manager"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// parseRunParams reads the --set values of a run: "key=value" for every
+// service of the run, "name.key=value" for the service called name only.
+// Keys are storage.ParamKeys. The result is keyed by service name, "" for
+// every service, as manager.SetParams takes it.
+func parseRunParams(sets []string) (map[string]map[string]string, error) {
+	if len(sets) == 0 {
+		return nil, nil
+	}
+	params := map[string]map[string]string{}
+	for _, set := range sets {
+		key, value, ok := strings.Cut(set, "=")
+		if !ok {
+			return nil, fmt.Errorf("--set %s: want key=value, e.g. namespace=feature-123", set)
+		}
+		name := ""
+		if dot := strings.LastIndex(key, "."); dot >= 0 {
+			name, key = key[:dot], key[dot+1:]
+			if name == "" {
+				return nil, fmt.Errorf("--set %s: no service name before the dot", set)
+			}
+		}
+		if err := storage.ValidateParam(key, value); err != nil {
+			return nil, fmt.Errorf("--set %s: %v", set, err)
+		}
+		if params[name] == nil {
+			params[name] = map[string]string{}
+		}
+		params[name][key] = value
+	}
+	return params, nil
+}
+
+// checkRunParams exits with a message unless the parameters mgr was given
+// apply to every service of the run, and each service they name is saved.
+func checkRunParams(st *storage.Storage, mgr *manager.ServiceManager, params map[string]map[string]string, serviceNames []string) {
+	for name := range params {
+		if name == "" {
+			continue
+		}
+		if _, err := st.GetService(name); err != nil {
+			fmt.Printf("Error: --set for '%s': %v\n", name, err)
+			os.Exit(exitError)
+		}
+	}
+	for _, name := range serviceNames {
+		command, err := st.LocalCommand(name)
+		if err != nil {
+			continue // the start reports it
+		}
+		if _, err := storage.ApplyParams(command, mgr.ServiceParams(name)); err != nil {
+			fmt.Printf("Error: --set for '%s': %v\n", name, err)
+			os.Exit(exitError)
+		}
+	}
+}
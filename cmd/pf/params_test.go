@@ -0,0 +1,26 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRunParams(t *testing.T) {
+	got, err := parseRunParams([]string{"namespace=feature-123", "api.remote-port=8081", "api.namespace=pr-7"})
+	if err != nil {
+		t.Fatalf("parseRunParams: %v", err)
+	}
+	want := map[string]map[string]string{
+		"":    {"namespace": "feature-123"},
+		"api": {"remote-port": "8081", "namespace": "pr-7"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"namespace", "image=nginx", "remote-port=0", ".namespace=x"} {
+		if _, err := parseRunParams([]string{bad}); err == nil {
+			t.Errorf("parseRunParams(%q) should fail", bad)
+		}
+	}
+}
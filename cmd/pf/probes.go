@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/probe"
+)
+
+// runProbesCommand lists the probe plugins monitors can run with --probe
+// (see package probe), and where to install more.
+func runProbesCommand() {
+	dir, err := probe.Dir()
+	if err != nil {
+		fatal(err)
+	}
+	plugins, err := probe.List(dir)
+	if err != nil {
+		fatal(err)
+	}
+	if len(plugins) == 0 {
+		lipgloss.Println(cliMuted.Render(fmt.Sprintf("No probe plugins in %s", dir)))
+		return
+	}
+	items := make([][2]string, 0, len(plugins))
+	for _, p := range plugins {
+		items = append(items, [2]string{p.Name, p.Path})
+	}
+	printList("Probe plugins", fmt.Sprintf("(%d, use with monitor --probe <name>)", len(plugins)), items)
+}
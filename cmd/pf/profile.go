@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// profileFiles are where --profile writes, in the current directory.
+var profileFiles = map[string]string{"cpu": "pf-cpu.pprof", "mem": "pf-mem.pprof"}
+
+// checkProfile reports an unknown --profile kind.
+func checkProfile(kind string) error {
+	if _, ok := profileFiles[kind]; kind != "" && !ok {
+		return fmt.Errorf("--profile must be cpu or mem, not %q", kind)
+	}
+	return nil
+}
+
+// startProfile starts profiling a session for --profile: "cpu" samples the
+// whole session, "mem" takes a heap profile at its end, and "" does nothing.
+// The returned func writes the profile and says where it is.
+func startProfile(kind string) (func(), error) {
+	path, ok := profileFiles[kind]
+	if !ok {
+		return func() {}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if kind == "cpu" {
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return func() {
+		if kind == "cpu" {
+			pprof.StopCPUProfile()
+		} else {
+			runtime.GC() // count only what is still live
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				fmt.Printf("Warning: cannot write the memory profile: %v\n", err)
+			}
+		}
+		f.Close()
+		fmt.Printf("%s profile written to %s (go tool pprof %s)\n", kind, path, path)
+	}, nil
+}
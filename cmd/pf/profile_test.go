@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestProfileWritesPprofFiles(t *testing.T) {
+	if err := checkProfile("block"); err == nil {
+		t.Error("an unknown kind should be refused")
+	}
+	t.Chdir(t.TempDir())
+	for kind, path := range profileFiles {
+		stop, err := startProfile(kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stop()
+		if fi, err := os.Stat(path); err != nil || fi.Size() == 0 {
+			t.Errorf("%s profile: %v", kind, err)
+		}
+	}
+	stop, err := startProfile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/prune"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// Answers to the prune prompt.
+const (
+	pruneDelete  = "delete"
+	pruneArchive = "archive"
+	pruneKeep    = "keep"
+	pruneQuit    = "quit"
+)
+
+// runPruneCommand checks every saved service against this machine and offers
+// to delete or archive the ones that can no longer work. restore brings an
+// archived service back; listArchived lists the archive.
+func runPruneCommand(restore string, listArchived bool) {
+	st := storage.NewStorage()
+	switch {
+	case restore != "":
+		if err := st.RestoreService(restore); err != nil {
+			fatal(err)
+		}
+		fmt.Printf("✓ Restored '%s'\n", restore)
+		return
+	case listArchived:
+		printArchived(st)
+		return
+	}
+
+	services, err := st.LocalServices()
+	if err != nil {
+		fatal(err)
+	}
+	contexts, err := kubeContexts()
+	if err != nil {
+		lipgloss.Println(cliMuted.Render("Skipped the kube context check: " + err.Error()))
+	}
+	dead := prune.Check(services, prune.Probe{LookPath: exec.LookPath, Contexts: contexts, ResolveHost: resolveSSHHost})
+	if len(dead) == 0 {
+		lipgloss.Println(cliMuted.Render(fmt.Sprintf("All %d services can still work here", len(services))))
+		return
+	}
+
+	if !stdinIsTerminal() {
+		items := make([][2]string, 0, len(dead))
+		for _, d := range dead {
+			items = append(items, [2]string{d.Name, strings.Join(d.Problems, "; ")})
+		}
+		printList("Cannot work here", fmt.Sprintf("(%d)", len(dead)), items)
+		os.Exit(exitError)
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	counts := map[string]int{}
+	for _, d := range dead {
+		lipgloss.Println()
+		lipgloss.Println(cliName.Render(d.Name) + "  " + cliMuted.Render(d.Command))
+		for _, problem := range d.Problems {
+			fmt.Printf("  ✗ %s\n", problem)
+		}
+		answer := askPrune(in)
+		if answer == pruneQuit {
+			break
+		}
+		switch answer {
+		case pruneDelete:
+			err = st.DeleteService(d.Name)
+		case pruneArchive:
+			err = st.ArchiveService(d.Name, strings.Join(d.Problems, "; "))
+		}
+		if err != nil {
+			fatal(err)
+		}
+		counts[answer]++
+	}
+	lipgloss.Println()
+	fmt.Printf("Deleted %d, archived %d, kept %d\n", counts[pruneDelete], counts[pruneArchive], counts[pruneKeep])
+	if counts[pruneArchive] > 0 {
+		lipgloss.Println(cliMuted.Render("  pf prune --archived lists the archive; pf prune --restore <name> brings one back."))
+	}
+}
+
+// askPrune asks what to do with a service that cannot work. EOF quits.
+func askPrune(in *bufio.Reader) string {
+	for {
+		fmt.Print("[d]elete, [a]rchive, [k]eep or [q]uit? ")
+		answer, err := readAnswer(in)
+		if err != nil {
+			return pruneQuit
+		}
+		switch strings.ToLower(answer) {
+		case "d", "delete":
+			return pruneDelete
+		case "a", "archive":
+			return pruneArchive
+		case "", "k", "keep":
+			return pruneKeep
+		case "q", "quit":
+			return pruneQuit
+		}
+	}
+}
+
+func printArchived(st *storage.Storage) {
+	archived, err := st.Archived()
+	if err != nil {
+		fatal(err)
+	}
+	if len(archived) == 0 {
+		lipgloss.Println(cliMuted.Render("No archived services"))
+		return
+	}
+	names := make([]string, 0, len(archived))
+	for name := range archived {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := make([][2]string, 0, len(names))
+	for _, name := range names {
+		a := archived[name]
+		detail := "archived " + a.Archived.Format("2006-01-02")
+		if a.Reason != "" {
+			detail += ": " + a.Reason
+		}
+		items = append(items, [2]string{name, detail})
+	}
+	printList("Archived", fmt.Sprintf("(%d)", len(names)), items)
+}
+
+// resolveSSHHost fails when an ssh destination does not resolve. Aliases
+// from ~/.ssh/config are looked up as ssh would, through ssh -G.
+func resolveSSHHost(host string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	hostname := host
+	if out, err := exec.CommandContext(ctx, "ssh", "-G", host).Output(); err == nil {
+		for _, line := range strings.Split(string(out), "\n") {
+			if key, value, ok := strings.Cut(strings.TrimSpace(line), " "); ok && key == "hostname" {
+				hostname = value
+				break
+			}
+		}
+	}
+	_, err := net.DefaultResolver.LookupHost(ctx, hostname)
+	return err
+}
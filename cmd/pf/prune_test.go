@@ -0,0 +1,23 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestAskPrune(t *testing.T) {
+	for input, want := range map[string]string{
+		"d\n":       pruneDelete,
+		"Archive\n": pruneArchive,
+		"\n":        pruneKeep,
+		"x\nk\n":    pruneKeep,
+		"q\n":       pruneQuit,
+		"":          pruneQuit,
+		"what\nd\n": pruneDelete,
+	} {
+		if got := askPrune(bufio.NewReader(strings.NewReader(input))); got != want {
+			t.Errorf("answers %q: got %s, want %s", input, got, want)
+		}
+	}
+}
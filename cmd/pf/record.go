@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/endpoint"
+	"github.com/alinemone/go-port-forward/internal/recording"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// runRecordCommand relays listen to a running service's local port and
+// records the traffic to out until Ctrl+C. Payload bytes are only kept when
+// payload is set; by default the file holds just sizes and timings.
+func runRecordCommand(args []string, listen, out string, payload bool) {
+	if len(args) != 1 || listen == "" {
+		fmt.Println("Usage: pf record <name> --listen <port> [--out <file>] [--payload]")
+		os.Exit(exitError)
+	}
+	name := args[0]
+	command, err := storage.NewStorage().GetService(name)
+	if err != nil {
+		fatal(err)
+	}
+	local, _ := cmdline.ParsePorts(command)
+	target := net.JoinHostPort(endpoint.DialHost(cmdline.ParseForward(command).Address), local)
+	if out == "" {
+		out = fmt.Sprintf("%s-%s.pfrec", name, time.Now().Format("20060102-150405"))
+	}
+
+	ln, err := net.Listen("tcp", listenAddress(listen))
+	if err != nil {
+		fatal(err)
+	}
+	f, err := os.OpenFile(out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		ln.Close()
+		fatal(err)
+	}
+	defer f.Close()
+	rec, err := recording.NewRecorder(f, recording.Header{Service: name, Target: target, Payload: payload})
+	if err != nil {
+		ln.Close()
+		fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Printf("Recording %s: connect to %s instead of %s (Ctrl+C to stop)\n", name, ln.Addr(), target)
+	if !payload {
+		fmt.Println("  Sizes and timings only; add --payload to keep the bytes (needed for replay).")
+	}
+	if err := recording.Relay(ctx, ln, target, rec); err != nil {
+		fatal(err)
+	}
+	conns, bytes := rec.Stats()
+	fmt.Printf("✓ Recorded %d connection(s), %d bytes to %s\n", conns, bytes, out)
+}
+
+// runReplayCommand serves a recording on listen as a mock of the remote end.
+func runReplayCommand(args []string, listen string, timing bool) {
+	if len(args) != 1 || listen == "" {
+		fmt.Println("Usage: pf replay <file> --listen <port> [--timing]")
+		os.Exit(exitError)
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		fatal(err)
+	}
+	h, conns, err := recording.Load(f)
+	f.Close()
+	if err != nil {
+		fmt.Printf("Error: %s: %v\n", args[0], err)
+		os.Exit(exitError)
+	}
+
+	ln, err := net.Listen("tcp", listenAddress(listen))
+	if err != nil {
+		fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Printf("Replaying %d connection(s) of %s (recorded %s) on %s (Ctrl+C to stop)\n",
+		len(conns), h.Service, h.Started.Format("2006-01-02 15:04"), ln.Addr())
+	if err := recording.Replay(ctx, ln, conns, timing); err != nil {
+		ln.Close()
+		fatal(err)
+	}
+}
+
+// listenAddress turns a bare port into a loopback address.
+func listenAddress(listen string) string {
+	if !strings.Contains(listen, ":") {
+		return "127.0.0.1:" + listen
+	}
+	return listen
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/events"
+	"github.com/alinemone/go-port-forward/internal/hints"
+	"github.com/alinemone/go-port-forward/internal/manager"
+	"github.com/alinemone/go-port-forward/internal/notify"
+	"github.com/alinemone/go-port-forward/internal/status"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// sessionSettings holds the settings of a running session that SIGHUP or
+// `pf config reload` re-read without restarting its forwards: notifications
+// and flap thresholds. Maintenance, chaos and switched commands are followed
+// all along (see manager.WatchConfig).
+type sessionSettings struct {
+	st         *storage.Storage
+	mgr        *manager.ServiceManager
+	dispatcher *notify.Dispatcher
+	stop       func()
+}
+
+// startSessionSettings runs the notifiers from the config's "notify" list for
+// this session and reloads the settings on SIGHUP until stop. A bad config is
+// reported before the TUI starts and leaves notifications off until a reload
+// fixes it; delivery failures are dropped, since the TUI owns the terminal by
+// then.
+func startSessionSettings(st *storage.Storage, mgr *manager.ServiceManager) *sessionSettings {
+	rules, err := notificationRules(st)
+	if err != nil {
+		fmt.Printf("Warning: notifications disabled: %v\n", err)
+	}
+	d := notify.NewDispatcher(rules, mgr.ListServiceStates, nil)
+	d.FollowGroups(mgr.GroupStates)
+	sub := mgr.Events().Subscribe(0, events.ServiceStarted, events.ServiceStopped, events.ServiceStatus)
+	d.FollowEvents(sub.C)
+	s := &sessionSettings{st: st, mgr: mgr, dispatcher: d}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		d.Run(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-hup:
+				s.reload()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	s.stop = func() {
+		signal.Stop(hup)
+		sub.Close()
+		cancel()
+		wg.Wait()
+	}
+	return s
+}
+
+// reload applies the config's current settings. One that is invalid is
+// skipped and the session keeps its old value; `pf config reload` checks the
+// config before asking, so that only happens on a bare SIGHUP.
+func (s *sessionSettings) reload() {
+	s.mgr.ReloadSettings()
+	if rules, err := notificationRules(s.st); err == nil {
+		s.dispatcher.SetRules(rules)
+	}
+}
+
+// notificationRules builds the rules from the config's "notify" list.
+func notificationRules(st *storage.Storage) ([]notify.Rule, error) {
+	cfgs, err := st.Notifiers()
+	if err != nil {
+		return nil, err
+	}
+	return notify.Build(cfgs)
+}
+
+// runConfigReloadCommand checks the config and asks every running session to
+// apply its settings.
+func runConfigReloadCommand() {
+	st := storage.NewStorage()
+	if _, _, err := st.FlapSettings(); err != nil {
+		fatal(err)
+	}
+	if _, err := notificationRules(st); err != nil {
+		fatal(err)
+	}
+	if _, err := hints.Load(st.HintsFile()); err != nil {
+		fatal(err)
+	}
+
+	dir, err := status.Dir()
+	if err != nil {
+		fatal(err)
+	}
+	sessions, err := status.ReadSessions(dir, time.Now())
+	if err != nil {
+		fatal(err)
+	}
+	if len(sessions) == 0 {
+		fmt.Println("No port forwards running; the next pf run reads the config anyway")
+		return
+	}
+	for _, session := range sessions {
+		if err := sendSessionRequest(dir, session, status.Request{Reload: true}); err != nil {
+			fatal(err)
+		}
+	}
+	fmt.Printf("✓ Reloaded the settings of %d running sessions\n", len(sessions))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/report"
+	"github.com/alinemone/go-port-forward/internal/stats"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// runReportCommand sums up the saved services and groups (see package
+// report) in format "text" or "json": counts by type, label and kube
+// context, then what a cleanup should look at. Services and groups count as
+// idle when no session has run them in the last days.
+func runReportCommand(format string, days int) {
+	if days <= 0 {
+		fmt.Println("Error: --days must be positive")
+		os.Exit(exitError)
+	}
+	data, err := storage.NewStorage().LoadData()
+	if err != nil {
+		fatal(err)
+	}
+	var kept stats.File
+	if path, err := stats.Path(); err == nil {
+		if kept, err = stats.Load(path); err != nil {
+			fatal(err)
+		}
+	}
+	now := time.Now()
+	r := report.Build(data, kept, now, time.Duration(days)*24*time.Hour)
+
+	switch format {
+	case "", "text":
+		printReport(r, days)
+	case "json":
+		printJSON(r)
+	default:
+		fmt.Printf("Error: unknown format %q (want text or json)\n", format)
+		os.Exit(exitError)
+	}
+}
+
+func printReport(r report.Report, days int) {
+	items := [][2]string{{"types", strings.Join(report.Counts(r.ByType), ", ")}}
+	if len(r.ByLabel) > 0 {
+		items = append(items, [2]string{"labels", strings.Join(report.Counts(r.ByLabel), ", ")})
+	}
+	if len(r.ByContext) > 0 {
+		items = append(items, [2]string{"kube contexts", strings.Join(report.Counts(r.ByContext), ", ")})
+	}
+	printList("Catalog", fmt.Sprintf("(%d services, %d groups)", r.Services, r.Groups), items)
+
+	idle := fmt.Sprintf("Not run in %d days", days)
+	if len(r.Idle) > 0 {
+		printList(idle, fmt.Sprintf("(%d services)", len(r.Idle)), unusedItems(r.Idle))
+	}
+	if len(r.UnusedGroups) > 0 {
+		printList(idle, fmt.Sprintf("(%d groups)", len(r.UnusedGroups)), unusedItems(r.UnusedGroups))
+	}
+	if len(r.SameTarget) > 0 {
+		printList("Same target", fmt.Sprintf("(%d)", len(r.SameTarget)), sharedItems(r.SameTarget, "forward to "))
+	}
+	if len(r.SamePort) > 0 {
+		printList("Same local port", fmt.Sprintf("(%d)", len(r.SamePort)), sharedItems(r.SamePort, "bind local port "))
+	}
+	if len(r.Idle)+len(r.UnusedGroups)+len(r.SameTarget)+len(r.SamePort) == 0 {
+		lipgloss.Println(cliMuted.Render("Nothing to clean up"))
+		return
+	}
+	lipgloss.Println(cliMuted.Render("  pf only knows runs since it began keeping them; pf delete, pf disable and pf group delete clean up."))
+}
+
+func unusedItems(list []report.Unused) [][2]string {
+	items := make([][2]string, 0, len(list))
+	for _, u := range list {
+		detail := "never run"
+		if !u.LastRun.IsZero() {
+			detail = "last run " + u.LastRun.Local().Format("2006-01-02")
+		}
+		items = append(items, [2]string{u.Name, detail})
+	}
+	return items
+}
+
+func sharedItems(list []report.Shared, verb string) [][2]string {
+	items := make([][2]string, 0, len(list))
+	for _, s := range list {
+		items = append(items, [2]string{strings.Join(s.Services, ", "), verb + s.What})
+	}
+	return items
+}
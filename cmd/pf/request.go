@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/logexport"
+	"github.com/alinemone/go-port-forward/internal/manager"
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/status"
+)
+
+// sessionRequestWait is how long a command waits for a running session to
+// take its request; sessions look once a second.
+const sessionRequestWait = 5 * time.Second
+
+// sendSessionRequest asks the running session to carry out r and waits until
+// it has taken the request.
+func sendSessionRequest(dir string, session status.Session, r status.Request) error {
+	if err := status.SendRequest(dir, session.PID, r); err != nil {
+		return err
+	}
+	for deadline := time.Now().Add(sessionRequestWait); status.RequestPending(dir, session.PID); time.Sleep(200 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			status.TakeRequest(dir, session.PID)
+			return withExitCode(exitNoSession, fmt.Errorf("the session running '%s' (pid %d) did not respond", session.Label, session.PID))
+		}
+	}
+	return nil
+}
+
+// followRequests carries out what other pf processes ask of this session
+// (`pf run --only-failed`, `pf apply`, `pf logs export`, `pf config reload`)
+// until ctx ends. A service it is asked to start that is already here,
+// retrying after an error, restarts at once instead of waiting out its
+// backoff.
+func followRequests(ctx context.Context, mgr *manager.ServiceManager, settings *sessionSettings) {
+	dir, err := status.Dir()
+	if err != nil {
+		return
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		r := status.TakeRequest(dir, os.Getpid())
+		if r.Reload {
+			settings.reload()
+		}
+		for _, path := range r.Export {
+			if err := logexport.Dump(path, mgr.ListServiceStates()); err != nil {
+				fmt.Printf("Error exporting logs: %v\n", err)
+			}
+		}
+		for _, name := range r.Stop {
+			mgr.StopService(name)
+		}
+		if len(r.Start) == 0 {
+			continue
+		}
+		states := mgr.ListServiceStates()
+		for _, name := range r.Start {
+			if slices.ContainsFunc(states, func(svc model.Service) bool { return svc.Name == name }) {
+				mgr.RestartService(ctx, name)
+				continue
+			}
+			go func(serviceName string) {
+				if err := mgr.StartService(ctx, serviceName); err != nil {
+					fmt.Printf("Error starting '%s': %v\n", serviceName, err)
+				}
+			}(name)
+		}
+	}
+}
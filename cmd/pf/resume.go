@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/status"
+)
+
+// runOnlyFailed hands the services of target that failed in the running
+// session back to that session to start, leaving its healthy ones alone.
+func runOnlyFailed(target string, serviceNames []string) {
+	dir, err := status.Dir()
+	if err != nil {
+		fatal(err)
+	}
+	sessions, err := status.ReadSessions(dir, time.Now())
+	if err != nil {
+		fatal(err)
+	}
+	session, failed, ok := status.FindFailed(sessions, serviceNames)
+	if !ok {
+		fmt.Printf("Error: no running session has '%s'; start it with pf run %s\n", target, target)
+		os.Exit(exitNoSession)
+	}
+	if len(failed) == 0 {
+		fmt.Printf("Nothing to do: no service of '%s' has failed\n", target)
+		return
+	}
+
+	if err := sendSessionRequest(dir, session, status.Request{Start: failed}); err != nil {
+		fatal(err)
+	}
+	fmt.Printf("✓ Starting %s in the session running '%s'\n", strings.Join(failed, ", "), session.Label)
+}
@@ -2,14 +2,22 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 	"unicode"
 
+	"github.com/alinemone/go-port-forward/internal/endpoint"
+	"github.com/alinemone/go-port-forward/internal/events"
 	"github.com/alinemone/go-port-forward/internal/manager"
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/status"
 	"github.com/alinemone/go-port-forward/internal/storage"
 	"github.com/alinemone/go-port-forward/internal/ui"
 
@@ -17,8 +25,9 @@ import (
 )
 
 // looksLikeRunTarget reports whether the first whitespace/comma-separated token
-// names an existing service or group, so a bare `pf <name>` can be treated as a
-// run. Read-only and quiet: it never prints or mutates storage.
+// names an existing service or group, or is a virtual group such as
+// "ns:staging", so a bare `pf <name>` can be treated as a run. Read-only and
+// quiet: it never prints or mutates storage.
 func looksLikeRunTarget(st runTargetStore, input string) bool {
 	fields := strings.FieldsFunc(input, func(r rune) bool {
 		return r == ',' || unicode.IsSpace(r)
@@ -27,36 +36,145 @@ func looksLikeRunTarget(st runTargetStore, input string) bool {
 		return false
 	}
 	first := fields[0]
-	if _, err := st.GetService(first); err == nil {
+	if storage.IsSelector(first) {
 		return true
 	}
-	if _, err := st.GetGroupServices(first); err == nil {
+	// A refused catalog service is still a run target; the run says why not.
+	if _, err := st.GetService(first); err == nil || !errors.Is(err, storage.ErrNotFound) {
+		return true
+	}
+	if _, err := st.EnabledGroupServices(first); err == nil {
 		return true
 	}
 	return false
 }
 
-func runStartCommand(args []string) {
-	if len(args) < 1 {
+// runOptions are the flags accepted by the commands that open the TUI.
+type runOptions struct {
+	noConfirm   bool          // skip every confirmation prompt, whatever the config says
+	accessible  bool          // plain-text mode for screen readers instead of the TUI
+	failFast    bool          // plain-text mode that ends the session on the first failure
+	ttl         time.Duration // stop everything after this long; 0 = never
+	envFile     string        // dotenv of live endpoints; overrides the config's envFile
+	metrics     string        // file the session appends its metrics to; overrides the config's metricsFile
+	fromStdin   bool          // also run the forwards defined on stdin (see FollowDefinitions)
+	onlyFailed  bool          // hand failed services to the running session instead
+	follow      bool          // start/stop forwards as the targets' groups change in the config
+	demo        bool          // play scripted services instead of running any (see runDemo)
+	profile     string        // "cpu" or "mem": write a pprof profile of the session
+	set         []string      // --set key=value: session-only parameters (see parseRunParams)
+	record      string        // asciinema recording of the TUI to write (see startRecording)
+	lowPower    bool          // poll and redraw less, for small always-on machines (see manager.UseLowPower)
+	namespace   string        // with allServices, the namespace to forward
+	allServices bool          // forward every Service in namespace (see forwardNamespace)
+}
+
+// accessibleMode reports whether to use the plain-text front end: the
+// --accessible or --fail-fast flag, or a non-empty ACCESSIBLE environment
+// variable (the convention Charm's tools use for the same purpose).
+func accessibleMode(opts runOptions) bool {
+	return opts.accessible || opts.failFast || os.Getenv("ACCESSIBLE") != ""
+}
+
+func runStartCommand(args []string, opts runOptions) {
+	if err := checkProfile(opts.profile); err != nil {
+		fatal(err)
+	}
+	if opts.record != "" && accessibleMode(opts) {
+		fmt.Println("Error: --record records the TUI, which accessible mode does not show")
+		os.Exit(exitError)
+	}
+	if opts.demo {
+		runDemo(args, opts)
+		return
+	}
+	// A bare `pf run` starts the default group, if one is set; otherwise a
+	// terminal user picks what to run in the TUI.
+	if len(args) == 0 && !opts.fromStdin && !opts.allServices {
+		if group, err := storage.NewStorage().DefaultGroup(); err == nil && group != "" {
+			fmt.Printf("Running the default group '%s'\n", group)
+			args = []string{group}
+		}
+	}
+	pick := len(args) == 0 && !opts.fromStdin && !opts.allServices && !opts.onlyFailed && !accessibleMode(opts) && stdinIsTerminal()
+	if len(args) < 1 && !opts.fromStdin && !opts.allServices && !pick {
 		fmt.Println("Usage: pf run <name1,name2,...>")
 		fmt.Println("       pf run all")
 		fmt.Println("       pf run <group-name>")
 		fmt.Println("       pf run <group1,group2,...>")
 		fmt.Println("       pf run <group-or-service,...>")
-		os.Exit(1)
+		fmt.Println("       <tool> | pf run --from-stdin [names]")
+		fmt.Println("       pf run --namespace <namespace> --all-services [names]")
+		fmt.Println("       pf run <group-name> --only-failed")
+		os.Exit(exitError)
 	}
 
-	st := storage.NewStorage()
-	serviceNames, err := resolveRunTargets(st, strings.Join(args, " "))
+	if opts.ttl < 0 {
+		fmt.Println("Error: --ttl must be positive")
+		os.Exit(exitError)
+	}
+	if opts.allServices != (opts.namespace != "") {
+		fmt.Println("Error: --all-services needs --namespace, and --namespace goes with --all-services")
+		os.Exit(exitError)
+	}
+	if opts.onlyFailed && (opts.fromStdin || opts.allServices || len(args) == 0) {
+		fmt.Println("Error: --only-failed needs the services or group to resume, and no --from-stdin or --all-services")
+		os.Exit(exitError)
+	}
+	if opts.onlyFailed && len(opts.set) > 0 {
+		fmt.Println("Error: --only-failed resumes services with the running session's parameters; it takes no --set")
+		os.Exit(exitError)
+	}
+	params, err := parseRunParams(opts.set)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
 	}
 
+	st := storage.NewStorage()
+	expireEphemeral(st, false)
+	var serviceNames []string
+	session := strings.Join(args, " ")
+	if len(args) > 0 {
+		if names := catalogsNamedIn(session); len(names) > 0 {
+			syncCatalogs(os.Stdout, st, names, false, false)
+		}
+		var err error
+		serviceNames, err = resolveRunTargets(st, session)
+		if err != nil {
+			fatal(err)
+		}
+		if session == "all" {
+			fmt.Printf("Running all %d services...\n", len(serviceNames))
+		}
+	}
+	if opts.onlyFailed {
+		runOnlyFailed(session, serviceNames)
+		return
+	}
+	if opts.fromStdin {
+		session = strings.TrimSpace(session + " +stdin")
+	}
+	if opts.allServices {
+		session = strings.TrimSpace(session + " ns:" + opts.namespace)
+	}
+
+	if opts.lowPower {
+		manager.UseLowPower()
+	}
 	mgr := manager.NewServiceManager(st)
+	mgr.SetParams(params)
+	trackGroups(st, mgr, strings.Join(args, " "))
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	// The TTL is a deadline on the session context: when it passes, the TUI
+	// (or accessible mode) exits exactly as on SIGTERM and everything stops.
+	if opts.ttl > 0 {
+		var cancelTTL context.CancelFunc
+		ctx, cancelTTL = context.WithTimeout(ctx, opts.ttl)
+		defer cancelTTL()
+	}
+	deadline, _ := ctx.Deadline()
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -65,17 +183,153 @@ func runStartCommand(args []string) {
 		cancel()
 	}()
 
+	checkRunnable(st, serviceNames)
+	if len(serviceNames) > 0 {
+		if serviceNames = reconcilePorts(st, serviceNames); len(serviceNames) == 0 && !opts.fromStdin && !opts.allServices {
+			fmt.Println("Nothing left to run")
+			return
+		}
+	}
+	checkRunParams(st, mgr, params, serviceNames)
+	warnDeprecated(os.Stdout, st, serviceNames)
+	// Notifications and flap thresholds, re-read on SIGHUP or `pf config
+	// reload`.
+	settings := startSessionSettings(st, mgr)
+	// Follow `pf maintenance` and `pf switch` from other terminals.
+	go mgr.WatchConfig(ctx)
+	go followRequests(ctx, mgr, settings)
+	if opts.follow && len(args) > 0 {
+		// Start and stop forwards as the groups (or, for "all", the saved
+		// services) change in the config.
+		go mgr.FollowTargets(ctx, serviceNames, func() ([]string, error) {
+			trackGroups(st, mgr, strings.Join(args, " "))
+			return resolveRunTargets(st, strings.Join(args, " "))
+		})
+	}
+	// What the session reports beyond its services is shown by the UI, not
+	// printed over it; see showNotices.
+	notices := mgr.Events().Subscribe(events.DefaultBuffer, events.SessionNotice)
+	if opts.fromStdin {
+		// Tools like tilt or skaffold hand their forwards over as they go.
+		go mgr.FollowDefinitions(ctx, os.Stdin, func(err error) {
+			mgr.Notice(err.Error())
+		})
+	}
+	stopNamespace := func() {}
+	if opts.allServices {
+		// Every Service of the namespace, kubefwd style, as they come and go.
+		stopNamespace = forwardNamespace(ctx, mgr, opts.namespace, serviceNames)
+	}
+
+	// Let `pf status`, status bars, the env file and notifiers follow this
+	// session while it runs.
+	unpublish := status.Publish(session, mgr.ListServiceStates, mgr.GroupStates)
+	stopEnvFile := keepEnvFile(st, opts, mgr)
+	history, stopStats := keepStats(mgr)
+	saveMetrics := sessionMetrics(st, opts, session, mgr)
+	stopProfile, err := startProfile(opts.profile)
+	if err != nil {
+		fatal(err)
+	}
+	stopSharing := func() {
+		stopNamespace()
+		notices.Close()
+		settings.stop()
+		stopEnvFile()
+		stopStats()
+		unpublish()
+		stopProfile()
+	}
+
+	if accessibleMode(opts) {
+		go showNotices(notices, func(text string) { fmt.Printf("Warning: %s\n", text) })
+		err := runAccessible(ctx, mgr, st, serviceNames, opts, deadline, func() {
+			saveMetrics()
+			stopNamespace()
+		})
+		stopSharing()
+		reportTTLExpired(ctx, opts)
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	// Start UI immediately. It answers what services ask for, which would
+	// otherwise prompt on the terminal the UI draws on.
+	mgr.EnablePrompts()
+	u := ui.NewUI(mgr, ctx)
+	u.SetSessionInfo(session, currentKubeContext())
+	u.SetConfirm(confirmOptions(st, opts))
+	u.SetDeadline(deadline)
+	if opts.lowPower {
+		u.SetLowPower()
+	}
+	if pick {
+		u.OpenPicker()
+	}
+	prefsPath, prefsErr := ui.PrefsPath()
+	if prefsErr == nil {
+		prefs, _ := ui.LoadPrefs(prefsPath)
+		u.SetPrefs(prefs)
+	}
+	if history != nil {
+		u.SetHistory(serviceHistory(history))
+	}
+	recording, stopRecording, err := startRecording(opts.record, "pf run "+session)
+	if err != nil {
+		stopSharing()
+		fatal(err)
+	}
+	program := tea.NewProgram(u, recording...)
+	go showNotices(notices, func(text string) { program.Send(ui.NoticeMsg(text)) })
+
+	// Start all services in parallel - they will appear in UI as they connect
 	for _, name := range serviceNames {
-		if _, err := st.GetService(name); err != nil {
+		go func(serviceName string) {
+			if err := mgr.StartService(ctx, serviceName); err != nil {
+				fmt.Printf("Error starting '%s': %v\n", serviceName, err)
+			}
+		}(name)
+	}
+
+	stuck := followShutdown(mgr, nil)
+	_, err = program.Run()
+	saveMetrics()
+	stopNamespace()
+	if prefsErr == nil {
+		if saveErr := ui.SavePrefs(prefsPath, u.Prefs()); saveErr != nil {
+			fmt.Printf("Warning: cannot save the live view's layout: %v\n", saveErr)
+		}
+	}
+	// Every exit path stops the forwards, including a program error or a kill
+	// from bubbletea's own signal handling, so no kubectl is left behind.
+	mgr.StopAllServices()
+	stopRecording()
+	warnStuck(os.Stdout, "Warning: ", stuck())
+	stopSharing()
+	reportTTLExpired(ctx, opts)
+	if err != nil && !errors.Is(err, tea.ErrProgramKilled) {
+		fatal(err)
+	}
+}
+
+// checkRunnable exits with a message unless every name is a stored service and
+// no two of them listen on the same local port.
+func checkRunnable(st *storage.Storage, serviceNames []string) {
+	for _, name := range serviceNames {
+		if _, err := st.GetService(name); errors.Is(err, storage.ErrNotFound) {
 			fmt.Printf("Error: Service '%s' not found\n", name)
-			os.Exit(1)
+			os.Exit(exitNotFound)
+		} else if err != nil {
+			fatal(err)
 		}
 	}
 
 	conflicts, err := st.FindPortConflicts(serviceNames)
 	if err != nil {
 		fmt.Printf("Error checking port conflicts: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	if len(conflicts) > 0 {
@@ -89,47 +343,243 @@ func runStartCommand(args []string) {
 			fmt.Println()
 		}
 		fmt.Println("Please fix the port conflicts before running these services together.")
-		os.Exit(1)
+		os.Exit(exitError)
 	}
+}
 
-	// Start UI immediately
-	u := ui.NewUI(mgr, ctx)
-	program := tea.NewProgram(u)
+// runAccessible is runStartCommand's plain-text path: no alt screen, one line
+// per status change, and commands typed at the prompt. Like the TUI path it
+// stops every service before returning, calling beforeStop first. With
+// --fail-fast the first service that fails ends it, and it returns why.
+func runAccessible(ctx context.Context, mgr *manager.ServiceManager, st *storage.Storage, serviceNames []string, opts runOptions, deadline time.Time, beforeStop func()) error {
+	var ff *failFast
+	if opts.failFast {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		ff = watchFailures(mgr, cancel)
+		defer ff.stop()
+	}
+	var in io.Reader = os.Stdin
+	if opts.fromStdin {
+		// stdin carries definitions, so commands come from the terminal; with
+		// none (a tool running pf headless) the session runs until stopped.
+		if tty, _, err := tea.OpenTTY(); err == nil {
+			in = tty
+		} else {
+			in = blockingReader{}
+		}
+	} else if opts.failFast && !stdinIsTerminal() {
+		// In CI stdin is empty or closed; its end must not end the session.
+		in = blockingReader{}
+	}
+	p := ui.NewPlain(mgr, in, os.Stdout)
+	p.SetConfirm(confirmOptions(st, opts))
+	p.SetDeadline(deadline)
 
-	// Start all services in parallel - they will appear in UI as they connect
 	for _, name := range serviceNames {
 		go func(serviceName string) {
 			if err := mgr.StartService(ctx, serviceName); err != nil {
-				fmt.Printf("Error starting '%s': %v\n", serviceName, err)
+				fmt.Printf("Error starting %s: %v\n", serviceName, err)
+				ff.fail(withExitCode(exitStartFailed, fmt.Errorf("cannot start %s: %v", serviceName, err)))
 			}
 		}(name)
 	}
 
-	if _, err := program.Run(); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+	err := p.Run(ctx)
+	if err == nil {
+		err = ff.err()
 	}
-
+	beforeStop()
+	fmt.Println("Stopping all services.")
+	stuck := followShutdown(mgr, os.Stdout)
 	mgr.StopAllServices()
+	warnStuck(os.Stdout, "Warning: ", stuck())
+	return err
+}
+
+// failFast ends a --fail-fast session on its first failure: a service
+// entering the error status, or one reported with fail. Its methods do
+// nothing on a nil *failFast, the session without the flag.
+type failFast struct {
+	sub    *events.Subscription
+	cancel context.CancelFunc
+
+	mu    sync.Mutex
+	first error
+}
+
+// watchFailures starts watching mgr's services; cancel ends the session.
+func watchFailures(mgr *manager.ServiceManager, cancel context.CancelFunc) *failFast {
+	f := &failFast{sub: mgr.Events().Subscribe(events.DefaultBuffer, events.ServiceStatus), cancel: cancel}
+	go func() {
+		for e := range f.sub.C {
+			if e.To != model.StatusError {
+				continue
+			}
+			reason := "it failed"
+			for _, svc := range mgr.ListServiceStates() {
+				if svc.Name == e.Service && svc.LastError != "" {
+					reason = svc.LastError
+				}
+			}
+			f.fail(withExitCode(exitServiceFailed, fmt.Errorf("%s failed: %s", e.Service, reason)))
+		}
+	}()
+	return f
+}
+
+// fail records err, unless a failure came first, and ends the session.
+func (f *failFast) fail(err error) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	if f.first == nil {
+		f.first = err
+	}
+	f.mu.Unlock()
+	f.cancel()
+}
+
+// err is the first failure, or nil.
+func (f *failFast) err() error {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.first
+}
+
+// stop stops watching.
+func (f *failFast) stop() {
+	if f != nil {
+		f.sub.Close()
+	}
+}
+
+// followShutdown follows the session's shutdown, printing each service to
+// progress as it stops when progress is not nil. The returned func, called
+// once StopAllServices has returned, lists the services that refused to die.
+func followShutdown(mgr *manager.ServiceManager, progress io.Writer) func() []string {
+	sub := mgr.Events().Subscribe(events.DefaultBuffer, events.ServiceStopped, events.ServiceStuck)
+	var stuck []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for e := range sub.C {
+			switch {
+			case e.Kind == events.ServiceStuck:
+				stuck = append(stuck, fmt.Sprintf("%s (%s)", e.Service, e.Detail))
+			case progress == nil:
+			case e.Detail != "":
+				fmt.Fprintf(progress, "Stopped %s (%s).\n", e.Service, e.Detail)
+			default:
+				fmt.Fprintf(progress, "Stopped %s.\n", e.Service)
+			}
+		}
+	}()
+	return func() []string {
+		sub.Close()
+		<-done
+		return stuck
+	}
+}
+
+// warnStuck reports the services a shutdown could not stop, whose processes
+// may still hold their ports.
+func warnStuck(w io.Writer, prefix string, stuck []string) {
+	for _, s := range stuck {
+		fmt.Fprintf(w, "%s%s refused to stop; run `pf cleanup` to free its port\n", prefix, s)
+	}
 }
 
+// blockingReader never yields input, for an accessible session that has no
+// terminal to read commands from.
+type blockingReader struct{}
+
+func (blockingReader) Read([]byte) (int, error) {
+	select {}
+}
+
+// showNotices hands each session notice to show until sub is closed.
+func showNotices(sub *events.Subscription, show func(text string)) {
+	for e := range sub.C {
+		show(e.Detail)
+	}
+}
+
+// keepEnvFile starts maintaining the dotenv of live endpoints when --env-file
+// or the config's "envFile" names one, and returns the func that removes it.
+// A path that cannot be written is reported before the TUI starts.
+func keepEnvFile(st *storage.Storage, opts runOptions, mgr *manager.ServiceManager) func() {
+	path := storage.ExpandHome(opts.envFile)
+	if path == "" {
+		path, _ = st.EnvFile()
+	}
+	if path == "" {
+		return func() {}
+	}
+	sub := mgr.Events().Subscribe(0, events.ServiceStarted, events.ServiceStopped, events.ServiceStatus, events.ConfigChanged)
+	stop, err := endpoint.KeepEnvFile(path, mgr.ListServiceStates, sub.C)
+	if err != nil {
+		sub.Close()
+		fmt.Printf("Warning: cannot write env file: %v\n", err)
+		return func() {}
+	}
+	return func() {
+		sub.Close()
+		stop()
+	}
+}
+
+// reportTTLExpired says why the session ended when it was the TTL that ended
+// it, so a closed tunnel the next morning is not a mystery.
+func reportTTLExpired(ctx context.Context, opts runOptions) {
+	if opts.ttl > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		fmt.Printf("Session TTL of %s reached: all forwards stopped.\n", opts.ttl)
+	}
+}
+
+// confirmOptions reads the per-action prompt settings from config; --no-confirm
+// turns them all off.
+func confirmOptions(st *storage.Storage, opts runOptions) ui.ConfirmOptions {
+	if opts.noConfirm {
+		return ui.ConfirmOptions{}
+	}
+	enabled := func(action string) bool {
+		on, _ := st.ConfirmEnabled(action)
+		return on
+	}
+	return ui.ConfirmOptions{
+		Stop:    enabled("stop"),
+		Restart: enabled("restart"),
+		Quit:    enabled("quit"),
+	}
+}
+
+// runTargetStore is what resolving run targets needs of the storage. "all"
+// and groups expand to their enabled services only; a disabled service still
+// runs when named. A virtual group ("label:team=payments", "ns:staging",
+// "context:prod") expands to the enabled services it selects.
 type runTargetStore interface {
-	ListServiceNames() ([]string, error)
+	EnabledServiceNames() ([]string, error)
 	HasNameConflict(name string) (bool, error)
 	GetService(name string) (string, error)
-	GetGroupServices(name string) ([]string, error)
+	EnabledGroupServices(name string) ([]string, error)
+	SelectServices(selector string) ([]string, error)
 }
 
 func resolveRunTargets(st runTargetStore, input string) ([]string, error) {
 	if strings.TrimSpace(input) == "all" {
-		names, err := st.ListServiceNames()
+		names, err := st.EnabledServiceNames()
 		if err != nil {
 			return nil, err
 		}
 		if len(names) == 0 {
 			return nil, fmt.Errorf("no services found")
 		}
-		fmt.Printf("Running all %d services...\n", len(names))
 		return names, nil
 	}
 
@@ -171,6 +621,18 @@ func resolveSingleRunTarget(st runTargetStore, target string) ([]string, error)
 		return nil, fmt.Errorf("invalid run target: empty value")
 	}
 
+	if storage.IsSelector(target) {
+		selected, err := st.SelectServices(target)
+		if err != nil {
+			return nil, err
+		}
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("no services match '%s'", target)
+		}
+		fmt.Printf("Running %s (%d services)...\n", target, len(selected))
+		return selected, nil
+	}
+
 	hasConflict, err := st.HasNameConflict(target)
 	if err != nil {
 		return nil, err
@@ -181,27 +643,41 @@ func resolveSingleRunTarget(st runTargetStore, target string) ([]string, error)
 
 	if _, err := st.GetService(target); err == nil {
 		return []string{target}, nil
-	} else if !isNotFoundErr(err) {
+	} else if !errors.Is(err, storage.ErrNotFound) {
 		return nil, err
 	}
 
-	groupServices, err := st.GetGroupServices(target)
+	groupServices, err := st.EnabledGroupServices(target)
 	if err == nil {
 		if len(groupServices) > 0 {
 			fmt.Printf("Running group '%s' (%d services)...\n", target, len(groupServices))
 		}
 		return groupServices, nil
 	}
-	if !isNotFoundErr(err) {
+	if !errors.Is(err, storage.ErrNotFound) {
 		return nil, err
 	}
 
-	return nil, fmt.Errorf("service or group '%s' not found", target)
+	return nil, fmt.Errorf("service or group '%s' %w", target, storage.ErrNotFound)
 }
 
-func isNotFoundErr(err error) bool {
-	if err == nil {
-		return false
+// trackGroups tells mgr about the groups named among the run targets in input,
+// virtual ones included, so their combined status is shown and published.
+func trackGroups(st runTargetStore, mgr *manager.ServiceManager, input string) {
+	for _, target := range strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		if storage.IsSelector(target) {
+			if members, err := st.SelectServices(target); err == nil && len(members) > 0 {
+				mgr.TrackGroup(target, members)
+			}
+			continue
+		}
+		if _, err := st.GetService(target); err == nil {
+			continue
+		}
+		if members, err := st.EnabledGroupServices(target); err == nil && len(members) > 0 {
+			mgr.TrackGroup(target, members)
+		}
 	}
-	return strings.Contains(err.Error(), "not found")
 }
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/schema"
+)
+
+// runSchemaCommand prints the JSON Schema called name (see package schema),
+// or without a name lists the schemas there are.
+func runSchemaCommand(name string) {
+	if name == "" {
+		items := make([][2]string, 0, len(schema.Descriptions))
+		for _, n := range schema.Names() {
+			items = append(items, [2]string{n, schema.Descriptions[n]})
+		}
+		printList("Schemas", fmt.Sprintf("(v%d)", schema.Version), items)
+		lipgloss.Println(cliMuted.Render("  pf schema <name> prints one"))
+		return
+	}
+	data, ok := schema.Get(name)
+	if !ok {
+		fmt.Printf("Error: no schema %q (want %s)\n", name, strings.Join(schema.Names(), ", "))
+		os.Exit(exitError)
+	}
+	fmt.Print(string(data))
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/manager"
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// The services pf selftest runs: a forward to its echo server, with a relay
+// in front, and a monitor that health-checks it.
+const (
+	selftestService = "selftest"
+	selftestMonitor = "selftest-check"
+)
+
+// selftestForwardArg is the argument that starts pf as the self-test's
+// forward; see selftestForward.
+const selftestForwardArg = "__selftest-forward"
+
+// isSelftestForward reports whether this pf was started as the self-test's
+// forward.
+func isSelftestForward(args []string) bool {
+	return len(args) == 3 && args[1] == selftestForwardArg
+}
+
+// selftestForward is pf as the forward of `pf selftest`, standing in for
+// kubectl port-forward: it listens on the local port of ports
+// ("<local>:<remote>") and relays each connection to the remote port on
+// loopback, printing what kubectl prints. Like kubectl when the pod goes
+// away, it fails as soon as the remote port does not answer, so pf sees the
+// forward die and reconnects it.
+func selftestForward(ports string, stdout io.Writer) error {
+	local, remote, ok := strings.Cut(ports, ":")
+	if !ok {
+		return fmt.Errorf("usage: pf %s <local>:<remote>", selftestForwardArg)
+	}
+	ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", local))
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	fmt.Fprintf(stdout, "Forwarding from 127.0.0.1:%s -> %s\n", local, remote)
+
+	upstream := net.JoinHostPort("127.0.0.1", remote)
+	for {
+		client, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Handling connection for %s\n", local)
+		server, err := net.DialTimeout("tcp", upstream, 5*time.Second)
+		if err != nil {
+			client.Close()
+			return fmt.Errorf("lost connection to %s: %v", upstream, err)
+		}
+		go func() {
+			defer client.Close()
+			defer server.Close()
+			go io.Copy(server, client)
+			io.Copy(client, server)
+		}()
+	}
+}
+
+// echoServer is the far end of the self-test's forward: it sends back what
+// each connection sends. Close drops its connections too, as a pod that goes
+// away does, and start brings it back on the same address.
+type echoServer struct {
+	addr  string
+	mu    sync.Mutex
+	ln    net.Listener
+	conns map[net.Conn]bool
+}
+
+func (e *echoServer) start() error {
+	addr := e.addr
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.addr, e.ln, e.conns = ln.Addr().String(), ln, map[net.Conn]bool{}
+	e.mu.Unlock()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			e.mu.Lock()
+			e.conns[conn] = true
+			e.mu.Unlock()
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return nil
+}
+
+func (e *echoServer) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ln == nil {
+		return
+	}
+	e.ln.Close()
+	for conn := range e.conns {
+		conn.Close()
+	}
+	e.ln, e.conns = nil, nil
+}
+
+// port is the port the echo server listens on.
+func (e *echoServer) port() string {
+	_, port, _ := net.SplitHostPort(e.addr)
+	return port
+}
+
+// runSelftestCommand checks that this pf can run, relay, health-check,
+// restart and reconnect a forward, with nothing outside pf: an echo server
+// stands in for the far end and pf itself for kubectl (see
+// selftestForward). It runs against a throwaway config and home directory,
+// so ~/.pf is left alone. Each step is reported as it passes; the first one
+// that fails ends the test, and pf with exitError. timeout bounds each wait
+// on the services.
+func runSelftestCommand(timeout time.Duration) {
+	lipgloss.Println(cliHeading.Render("pf selftest"))
+	if err := selftest(timeout); err != nil {
+		fmt.Printf("✗ %v\n", err)
+		lipgloss.Println(cliMuted.Render("Self-test failed"))
+		os.Exit(exitError)
+	}
+	fmt.Println("✓ Self-test passed")
+}
+
+// selftest runs the self-test's steps, printing each that passes, and
+// returns the first failure prefixed with its step.
+func selftest(timeout time.Duration) error {
+	home, err := os.MkdirTemp("", "pf-selftest-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(home)
+	// Point the home directory at home for the test only, and back after.
+	for _, key := range []string{"HOME", "USERPROFILE"} {
+		if old, ok := os.LookupEnv(key); ok {
+			defer os.Setenv(key, old)
+		} else {
+			defer os.Unsetenv(key)
+		}
+		os.Setenv(key, home)
+	}
+
+	echo := &echoServer{}
+	if err := echo.start(); err != nil {
+		return fmt.Errorf("echo server: %v", err)
+	}
+	defer echo.close()
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	local, err := freeLocalPort()
+	if err != nil {
+		return err
+	}
+	relayPort, err := freeLocalPort()
+	if err != nil {
+		return err
+	}
+
+	st := storage.NewStorage()
+	forward := fmt.Sprintf("%s %s %s:%s", quoteArg(exe), selftestForwardArg, local, echo.port())
+	err = st.SaveData(&storage.StorageData{
+		Services: map[string]string{
+			selftestService: forward,
+			selftestMonitor: "monitor --via " + selftestService + " --every 1s",
+		},
+		Groups: map[string][]string{},
+		Relay:  map[string]storage.RelayConfig{selftestService: {Listen: relayPort}},
+	})
+	if err != nil {
+		return err
+	}
+
+	mgr := manager.NewServiceManager(st)
+	// Reconnect within a moment, not the seconds a session waits.
+	mgr.SetBackoff(manager.Backoff{Base: 250 * time.Millisecond, Max: time.Second, Reset: manager.DefaultBackoff.Reset})
+	defer mgr.StopAllServices()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	forwardAddr := net.JoinHostPort("127.0.0.1", local)
+	relayAddr := net.JoinHostPort("127.0.0.1", relayPort)
+	var started time.Time
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"the forward comes up", func() error {
+			for _, name := range []string{selftestService, selftestMonitor} {
+				if err := mgr.StartService(ctx, name); err != nil {
+					return err
+				}
+			}
+			s, err := awaitService(mgr, selftestService, "healthy", timeout, healthy)
+			started = s.StartTime
+			return err
+		}},
+		{"traffic round-trips through the forward", func() error { return roundTrip(forwardAddr) }},
+		{"traffic round-trips through the relay", func() error { return roundTrip(relayAddr) }},
+		{"the health check passes", func() error {
+			_, err := awaitService(mgr, selftestMonitor, "healthy", timeout, healthy)
+			return err
+		}},
+		{"the forward restarts", func() error {
+			if err := mgr.RestartService(ctx, selftestService); err != nil {
+				return err
+			}
+			_, err := awaitService(mgr, selftestService, "restarted and healthy", timeout, func(s model.Service) bool {
+				return healthy(s) && s.StartTime.After(started)
+			})
+			if err != nil {
+				return err
+			}
+			return roundTrip(forwardAddr)
+		}},
+		{"the health check fails when the far end goes away", func() error {
+			echo.close()
+			_, err := awaitService(mgr, selftestMonitor, "in error", timeout, func(s model.Service) bool {
+				return s.Status == model.StatusError
+			})
+			return err
+		}},
+		{"the forward reconnects when the far end is back", func() error {
+			if err := echo.start(); err != nil {
+				return fmt.Errorf("echo server: %v", err)
+			}
+			for _, name := range []string{selftestService, selftestMonitor} {
+				if _, err := awaitService(mgr, name, "healthy", timeout, healthy); err != nil {
+					return err
+				}
+			}
+			return roundTrip(forwardAddr)
+		}},
+		{"the forward stops", func() error {
+			mgr.StopService(selftestMonitor)
+			mgr.StopService(selftestService)
+			if conn, err := net.DialTimeout("tcp", forwardAddr, time.Second); err == nil {
+				conn.Close()
+				return fmt.Errorf("%s still listens after stopping", forwardAddr)
+			}
+			return nil
+		}},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			return fmt.Errorf("%s: %v", step.name, err)
+		}
+		fmt.Printf("✓ %s\n", step.name)
+	}
+	return nil
+}
+
+func healthy(s model.Service) bool { return s.Status == model.StatusHealthy }
+
+// awaitService waits up to timeout for the service called name to be in a
+// state ok accepts (described by want) and returns that state. It fails with
+// the state the service is in instead and its last error.
+func awaitService(mgr *manager.ServiceManager, name, want string, timeout time.Duration, ok func(model.Service) bool) (model.Service, error) {
+	deadline := time.After(timeout)
+	for {
+		var svc model.Service
+		for _, s := range mgr.ListServiceStates() {
+			if s.Name == name {
+				svc = s
+			}
+		}
+		if svc.Name != "" && ok(svc) {
+			return svc, nil
+		}
+		select {
+		case <-mgr.Updates():
+		case <-deadline:
+			state := svc.Status
+			if state == "" {
+				state = "not running"
+			}
+			if svc.LastError != "" {
+				state += " (" + svc.LastError + ")"
+			}
+			return svc, fmt.Errorf("%s not %s after %s: it is %s", name, want, timeout, state)
+		}
+	}
+}
+
+// roundTrip sends random bytes to addr and checks that the same come back.
+func roundTrip(addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	sent := make([]byte, 64<<10)
+	rand.Read(sent)
+	go conn.Write(sent)
+	got := make([]byte, len(sent))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		return fmt.Errorf("reading the echo from %s: %v", addr, err)
+	}
+	if !bytes.Equal(got, sent) {
+		return fmt.Errorf("%s echoed other bytes than were sent", addr)
+	}
+	return nil
+}
+
+// freeLocalPort returns a loopback port nothing listens on at the moment.
+func freeLocalPort() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer ln.Close()
+	return strconv.Itoa(ln.Addr().(*net.TCPAddr).Port), nil
+}
+
+// quoteArg wraps a path in double quotes for the shell a service's command
+// runs in, when it has spaces.
+func quoteArg(path string) string {
+	if strings.ContainsAny(path, " \t") {
+		return `"` + path + `"`
+	}
+	return path
+}
@@ -3,29 +3,64 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/alinemone/go-port-forward/internal/lint"
 	"github.com/alinemone/go-port-forward/internal/manager"
 	"github.com/alinemone/go-port-forward/internal/storage"
 
 	"charm.land/lipgloss/v2"
 )
 
-func runAddCommand(args []string) {
+// runAddCommand saves a service. Unless force is set, a command that would
+// only fail at run time (no forwarder pf knows, no readable ports, a missing
+// program) is refused with the reason and a fix. Replacing a service of the
+// same name is asked about unless overwrite is set.
+func runAddCommand(args []string, force, overwrite bool) {
 	if len(args) < 2 {
 		fmt.Println("Usage: pf add <name> <command>")
 		fmt.Println("Example: pf add db \"kubectl port-forward service/postgres 5432:5432\"")
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	name := args[0]
 	command := strings.Join(args[1:], " ")
+	if err := manager.ValidateServiceName(name); err != nil {
+		fmt.Printf("Error: invalid name: %v\n", err)
+		os.Exit(exitError)
+	}
+
+	broken := false
+	for _, f := range lint.CheckCommand(command, exec.LookPath) {
+		mark := "!"
+		if f.Severity == lint.Error {
+			mark = "✗"
+			broken = true
+		}
+		fmt.Printf("%s %s\n", mark, f.Problem)
+		lipgloss.Println(cliMuted.Render("  → " + f.Fix))
+	}
+	if broken && !force {
+		fmt.Println("Error: the command looks broken; fix it or add --force to save it anyway")
+		os.Exit(exitError)
+	}
 
 	st := storage.NewStorage()
+	if _, err := st.GetGroupServices(name); err == nil {
+		fmt.Printf("Error: a group with name '%s' already exists, cannot create service with same name\n", name)
+		os.Exit(exitError)
+	}
+	if current, err := st.GetService(name); err == nil && current != command && !overwrite {
+		var ok bool
+		if name, ok = resolveNameConflict(st, "service", name, current, command); !ok {
+			os.Exit(exitError)
+		}
+	}
 	if err := st.AddService(name, command); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
 	}
 
 	fmt.Printf("✓ Service '%s' added\n", name)
@@ -33,17 +68,35 @@ func runAddCommand(args []string) {
 
 func runListCommand() {
 	st := storage.NewStorage()
+	expireEphemeral(st, false)
 	services, err := st.LoadServices()
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
+	}
+
+	syncCatalogs(os.Stdout, st, nil, false, false)
+	remote, err := st.RemoteServices()
+	if err != nil {
+		fatal(err)
 	}
 
-	if len(services) == 0 {
-		lipgloss.Println(cliMuted.Render("No services found"))
+	if len(services) == 0 && len(remote) == 0 {
+		lipgloss.Println(cliMuted.Render("No services yet: run pf on its own to import or add the first ones"))
 		return
 	}
+	if len(services) > 0 {
+		printList("Services", fmt.Sprintf("(%d)", len(services)), markEphemeral(st, markDisabled(st, sortedItems(services))))
+	}
+	if len(remote) > 0 {
+		printList("Catalog services", fmt.Sprintf("(%d, read-only)", len(remote)), markDisabled(st, sortedItems(remote)))
+	}
+	if overrides, err := st.Overrides(); err == nil && len(overrides) > 0 {
+		printOverrides(st)
+	}
+}
 
+// sortedItems turns name → command into printList items, by name.
+func sortedItems(services map[string]string) [][2]string {
 	names := make([]string, 0, len(services))
 	for name := range services {
 		names = append(names, name)
@@ -54,14 +107,39 @@ func runListCommand() {
 	for _, name := range names {
 		items = append(items, [2]string{name, services[name]})
 	}
-	printList("Services", fmt.Sprintf("(%d)", len(items)), items)
+	return items
+}
+
+// markDisabled notes on the items of disabled services that they are.
+func markDisabled(st *storage.Storage, items [][2]string) [][2]string {
+	for i, it := range items {
+		if enabled, err := st.IsEnabled(it[0]); err == nil && !enabled {
+			items[i][1] += "  (disabled)"
+		}
+	}
+	return items
+}
+
+// markEphemeral notes on the items of ephemeral services when they go.
+func markEphemeral(st *storage.Storage, items [][2]string) [][2]string {
+	data, err := st.LoadData()
+	if err != nil || len(data.Ephemeral) == 0 {
+		return items
+	}
+	now := time.Now()
+	for i, it := range items {
+		if e, ok := data.Ephemeral[it[0]]; ok {
+			items[i][1] += "  (ephemeral, " + describeExpiry(e.Expires, now) + ")"
+		}
+	}
+	return items
 }
 
 func runRenameCommand(args []string) {
 	if len(args) < 2 {
 		fmt.Println("Usage: pf rename <old-name> <new-name>")
 		fmt.Println("Example: pf rename db database")
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	oldName := args[0]
@@ -69,15 +147,14 @@ func runRenameCommand(args []string) {
 
 	if err := manager.ValidateServiceName(newName); err != nil {
 		fmt.Printf("Error: invalid new name: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	st := storage.NewStorage()
 
 	if _, err := st.GetService(oldName); err == nil {
 		if err := st.RenameService(oldName, newName); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			fatal(err)
 		}
 		fmt.Printf("✓ Service renamed '%s' → '%s'\n", oldName, newName)
 		return
@@ -85,29 +162,63 @@ func runRenameCommand(args []string) {
 
 	if _, err := st.GetGroupServices(oldName); err == nil {
 		if err := st.RenameGroup(oldName, newName); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			fatal(err)
 		}
 		fmt.Printf("✓ Group renamed '%s' → '%s'\n", oldName, newName)
 		return
 	}
 
 	fmt.Printf("Error: service or group '%s' not found\n", oldName)
-	os.Exit(1)
+	os.Exit(exitNotFound)
 }
 
 func runDeleteCommand(args []string) {
 	if len(args) < 1 {
 		fmt.Println("Usage: pf delete <name>")
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	name := args[0]
 	st := storage.NewStorage()
-	if err := st.DeleteService(name); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+	if err := st.TrashService(name); err != nil {
+		fatal(err)
 	}
 
 	fmt.Printf("✓ Service '%s' deleted\n", name)
+	lipgloss.Println(cliMuted.Render(fmt.Sprintf("  pf restore %s brings it back for %d days", name, int(storage.TrashRetention/(24*time.Hour)))))
+}
+
+// runRestoreCommand brings a deleted service back from the trash, with its
+// settings and groups, or without a name lists what the trash holds.
+func runRestoreCommand(name string) {
+	st := storage.NewStorage()
+	if name != "" {
+		if err := st.RestoreTrashed(name); err != nil {
+			fatal(err)
+		}
+		fmt.Printf("✓ Restored '%s'\n", name)
+		return
+	}
+
+	trashed, err := st.Trashed()
+	if err != nil {
+		fatal(err)
+	}
+	if len(trashed) == 0 {
+		lipgloss.Println(cliMuted.Render("The trash is empty"))
+		return
+	}
+	names := make([]string, 0, len(trashed))
+	for name := range trashed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := make([][2]string, 0, len(names))
+	for _, name := range names {
+		t := trashed[name]
+		purge := t.Archived.Add(storage.TrashRetention)
+		items = append(items, [2]string{name, fmt.Sprintf("deleted %s, kept until %s", t.Archived.Format("2006-01-02"), purge.Format("2006-01-02"))})
+	}
+	printList("Trash", fmt.Sprintf("(%d)", len(names)), items)
+	lipgloss.Println(cliMuted.Render("  pf restore <name> brings one back"))
 }
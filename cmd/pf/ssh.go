@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/hostkeys"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// trustTimeout bounds resolving, scanning and pinning a host's keys.
+const trustTimeout = 30 * time.Second
+
+// runSSHCommand handles `pf ssh trust <name>`: it fetches the host keys of the
+// host the service's ssh connects to and pins them in known_hosts (see
+// package hostkeys), after showing their fingerprints for a check, so the
+// service connects with strict host key checking on a machine that never
+// connected before. yes skips the question, for CI.
+func runSSHCommand(args []string, yes bool) {
+	if len(args) != 2 || args[0] != "trust" {
+		fmt.Println("Usage: pf ssh trust <name> [--yes]")
+		os.Exit(exitError)
+	}
+	name := args[1]
+	command, err := storage.NewStorage().GetService(name)
+	if err != nil {
+		fatal(err)
+	}
+	sshArgs := cmdline.SSHArgs(command)
+	if sshArgs == nil {
+		fmt.Printf("Error: service '%s' does not run ssh\n", name)
+		os.Exit(exitError)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), trustTimeout)
+	defer cancel()
+	target, err := hostkeys.Resolve(ctx, sshArgs)
+	if err != nil {
+		fatal(err)
+	}
+	keys, err := hostkeys.Scan(ctx, target)
+	if err != nil {
+		fatal(err)
+	}
+	pinned, err := hostkeys.Pinned(ctx, target)
+	if err != nil {
+		fatal(err)
+	}
+	if hostkeys.Same(keys, pinned) {
+		fmt.Printf("✓ %s's host keys are already pinned in %s\n", target.Name, target.File)
+		return
+	}
+
+	items := make([][2]string, 0, len(keys))
+	for _, line := range keys {
+		keyType, fingerprint, err := hostkeys.Fingerprint(line)
+		if err != nil {
+			fatal(err)
+		}
+		items = append(items, [2]string{keyType, fingerprint})
+	}
+	printList("Host keys of "+target.Name, fmt.Sprintf("(from %s port %s)", target.Host, target.Port), items)
+	prompt := fmt.Sprintf("Pin them in %s?", target.File)
+	if len(pinned) > 0 {
+		lipgloss.Println(cliMuted.Render(fmt.Sprintf("These differ from the %d key(s) pinned for %s. A changed key can mean the host was reinstalled, or that someone is intercepting the connection: check the fingerprints with the host's owner.", len(pinned), target.Name)))
+		prompt = fmt.Sprintf("Replace the pinned keys in %s?", target.File)
+	}
+	if !yes && !confirm(prompt) {
+		fmt.Println("Aborted.")
+		return
+	}
+	if err := hostkeys.Pin(ctx, target, keys); err != nil {
+		fatal(err)
+	}
+	fmt.Printf("✓ Pinned %d host key(s) for %s in %s\n", len(keys), target.Name, target.File)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/alinemone/go-port-forward/forwarder"
+	"github.com/alinemone/go-port-forward/internal/cert"
+	"github.com/alinemone/go-port-forward/internal/probe"
+	"github.com/alinemone/go-port-forward/internal/statedir"
+	"github.com/alinemone/go-port-forward/internal/stats"
+	"github.com/alinemone/go-port-forward/internal/status"
+	"github.com/alinemone/go-port-forward/internal/storage"
+	"github.com/alinemone/go-port-forward/internal/ui"
+)
+
+// runStateCommand prints where pf keeps everything, how much each place
+// holds and whether pf can read and write it (see package statedir), in
+// format "text" or "json". It exits 1 when a place that exists cannot be
+// read or written, or cannot be created when missing.
+func runStateCommand(format string) {
+	st := storage.NewStorage()
+	configDir := filepath.Dir(st.Path())
+	entries := []statedir.Entry{
+		statedir.Inspect("config", st.Path(), false),
+		statedir.Inspect("local overrides", st.OverridesFile(), false),
+		statedir.Inspect("error hints", st.HintsFile(), false),
+		statedir.Inspect("archive", st.ArchiveFile(), false),
+		statedir.Inspect("trash", st.TrashFile(), false),
+		statedir.Inspect("catalogs", st.CatalogDir(), true),
+	}
+	// Everything else has a place of its own; one that cannot be found
+	// (no home directory) is left out.
+	places := []struct {
+		name string
+		path func() (string, error)
+		dir  bool
+	}{
+		{"certificate", cert.ConfigPath, false},
+		{"certificates", cert.Dir, true},
+		{"stats", stats.Path, false},
+		{"ui preferences", ui.PrefsPath, false},
+		{"sessions", status.Dir, true},
+		{"probe plugins", probe.Dir, true},
+		{"forwarder plugins", forwarder.Dir, true},
+		{"completion", completionPath, true},
+	}
+	for _, p := range places {
+		if path, err := p.path(); err == nil {
+			entries = append(entries, statedir.Inspect(p.name, path, p.dir))
+		}
+	}
+	if metrics, err := st.MetricsFile(); err == nil && metrics != "" {
+		entries = append(entries, statedir.Inspect("metrics", metrics, false))
+	}
+
+	ok := true
+	for _, e := range entries {
+		ok = ok && e.OK()
+	}
+	switch format {
+	case "", "text":
+		items := make([][2]string, 0, len(entries))
+		for _, e := range entries {
+			detail := e.Access()
+			if e.Exists && e.Dir {
+				detail = fmt.Sprintf("%s in %d files, %s", statedir.FormatSize(e.Size), e.Files, detail)
+			} else if e.Exists {
+				detail = statedir.FormatSize(e.Size) + ", " + detail
+			}
+			if e.Error != "" {
+				detail += ": " + e.Error
+			}
+			name := e.Name
+			if !e.OK() {
+				name = "✗ " + name
+			}
+			items = append(items, [2]string{name, e.Path + "  (" + detail + ")"})
+		}
+		printList("State", fmt.Sprintf("(%s in all, config in %s)", statedir.FormatSize(statedir.Total(entries)), configDir), items)
+	case "json":
+		printJSON(entries)
+	default:
+		fmt.Printf("Error: unknown format %q (use text or json)\n", format)
+		os.Exit(exitError)
+	}
+	if !ok {
+		os.Exit(exitError)
+	}
+}
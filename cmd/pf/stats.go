@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/events"
+	"github.com/alinemone/go-port-forward/internal/manager"
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/stats"
+	"github.com/alinemone/go-port-forward/internal/status"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// recentErrors is how many errors `pf stats` lists from the stats file.
+const recentErrors = 10
+
+// runStatsCommand prints how often each forward in the running sessions has
+// reconnected and why, busiest first, so a flaky network reads differently
+// from a crashing backend. What the stats file kept across sessions follows:
+// today's reconnects and the latest errors, which a fresh session's own
+// counters cannot tell.
+func runStatsCommand() {
+	dir, err := status.Dir()
+	if err != nil {
+		fatal(err)
+	}
+	sessions, err := status.ReadSessions(dir, time.Now())
+	if err != nil {
+		fatal(err)
+	}
+	path, err := stats.Path()
+	if err != nil {
+		fatal(err)
+	}
+	kept, err := stats.Load(path)
+	if err != nil {
+		fatal(err)
+	}
+	printSessionReconnects(sessions)
+	printKeptStats(kept, time.Now())
+}
+
+// printSessionReconnects lists the reconnects of the running sessions.
+func printSessionReconnects(sessions []status.Session) {
+	if len(sessions) == 0 {
+		lipgloss.Println(cliMuted.Render("No port forwards running"))
+		return
+	}
+
+	type row struct {
+		name   string
+		total  int
+		counts map[string]int
+	}
+	var rows []row
+	quiet := 0
+	for _, s := range sessions {
+		for _, svc := range s.Services {
+			total := 0
+			for _, n := range svc.Reconnects {
+				total += n
+			}
+			if total == 0 {
+				quiet++
+				continue
+			}
+			rows = append(rows, row{svc.Name, total, svc.Reconnects})
+		}
+	}
+	if len(rows) == 0 {
+		lipgloss.Println(cliMuted.Render(fmt.Sprintf("No reconnects in %d running forwards", quiet)))
+		return
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].total > rows[j].total })
+
+	items := make([][2]string, 0, len(rows))
+	for _, r := range rows {
+		items = append(items, [2]string{fmt.Sprintf("%s  (%d)", r.name, r.total), model.FormatReconnects(r.counts)})
+	}
+	meta := fmt.Sprintf("(%d forwards", len(rows))
+	if quiet > 0 {
+		meta += fmt.Sprintf(", %d more without any", quiet)
+	}
+	printList("Reconnects", meta+")", items)
+}
+
+// printKeptStats lists today's reconnects across sessions, busiest first, and
+// the latest errors, newest first.
+func printKeptStats(f stats.File, now time.Time) {
+	type row struct {
+		name  string
+		today stats.Day
+	}
+	type recent struct {
+		name string
+		stats.Error
+	}
+	var rows []row
+	var errs []recent
+	for name, s := range f.Services {
+		if today := s.Today(now); today.Total() > 0 {
+			rows = append(rows, row{name, today})
+		}
+		for _, e := range s.Errors {
+			errs = append(errs, recent{name, e})
+		}
+	}
+
+	if len(rows) > 0 {
+		sort.Slice(rows, func(i, j int) bool {
+			if rows[i].today.Total() != rows[j].today.Total() {
+				return rows[i].today.Total() > rows[j].today.Total()
+			}
+			return rows[i].name < rows[j].name
+		})
+		items := make([][2]string, 0, len(rows))
+		for _, r := range rows {
+			items = append(items, [2]string{fmt.Sprintf("%s  (%d)", r.name, r.today.Total()), model.FormatReconnects(r.today.Reconnects)})
+		}
+		printList("Reconnects today", "(all sessions, kept across restarts)", items)
+	}
+
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].At.After(errs[j].At) })
+		errs = errs[:min(len(errs), recentErrors)]
+		items := make([][2]string, 0, len(errs))
+		for _, e := range errs {
+			items = append(items, [2]string{e.name + "  " + formatStatTime(e.At, now), e.Error.Error})
+		}
+		printList("Recent errors", fmt.Sprintf("(latest %d)", len(errs)), items)
+	}
+}
+
+// formatStatTime shows a time of today as "14:02" and an older one with its
+// date.
+func formatStatTime(t, now time.Time) string {
+	t = t.Local()
+	if y, m, d := t.Date(); y == now.Year() && m == now.Month() && d == now.Day() {
+		return t.Format("15:04")
+	}
+	return t.Format("Jan 2 15:04")
+}
+
+// runStatsExportCommand writes the reliability figures of the forwards in
+// the running sessions (see stats.Row) as CSV or JSON Lines, to output or
+// stdout. The format defaults to what output's extension names.
+func runStatsExportCommand(format, output string) {
+	if format == "" {
+		format = stats.FormatOf(output)
+	}
+	if format != "csv" && format != "json" {
+		fmt.Printf("Error: --format must be csv or json, not %q\n", format)
+		os.Exit(exitError)
+	}
+	dir, err := status.Dir()
+	if err != nil {
+		fatal(err)
+	}
+	now := time.Now()
+	sessions, err := status.ReadSessions(dir, now)
+	if err != nil {
+		fatal(err)
+	}
+	rows := stats.Rows(sessions, now)
+	if len(rows) == 0 {
+		fmt.Println("Error: no port forwards running (export reads the running sessions)")
+		os.Exit(exitNoSession)
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := stats.Write(w, rows, format, true); err != nil {
+		fatal(err)
+	}
+	if output != "" {
+		fmt.Printf("✓ Metrics of %d forwards written to %s\n", len(rows), output)
+	}
+}
+
+// sessionMetrics returns the func that appends the session's figures to the
+// metrics file (see stats.Append) named by --metrics-file or the config's
+// "metricsFile"; call it before the services stop, as stopped services are
+// gone from the manager. It does nothing when neither names a file.
+func sessionMetrics(st *storage.Storage, opts runOptions, session string, mgr *manager.ServiceManager) func() {
+	path := storage.ExpandHome(opts.metrics)
+	if path == "" {
+		path, _ = st.MetricsFile()
+	}
+	if path == "" {
+		return func() {}
+	}
+	return func() {
+		now := time.Now()
+		s := status.Session{Label: session, Services: status.Snapshot(mgr.ListServiceStates())}
+		if err := stats.Append(path, stats.Rows([]status.Session{s}, now)); err != nil {
+			fmt.Printf("Warning: cannot write the session's metrics: %v\n", err)
+		}
+	}
+}
+
+// keepStats adds this session's reconnects and errors to the stats file (see
+// stats.Keeper) until the returned stop func is called, and notes which
+// services and groups it runs, for `pf report`. The keeper also answers the
+// live view's questions about a service's history; an unreadable file is
+// started over.
+func keepStats(mgr *manager.ServiceManager) (*stats.Keeper, func()) {
+	path, err := stats.Path()
+	if err != nil {
+		return nil, func() {}
+	}
+	k, _ := stats.NewKeeper(path)
+	var groups []string
+	for _, g := range mgr.GroupStates() {
+		groups = append(groups, g.Name)
+	}
+	k.RecordGroups(groups, time.Now())
+	sub := mgr.Events().Subscribe(0, events.ServiceStatus, events.ServiceStopped)
+	stop := k.Follow(mgr.ListServiceStates, sub.C)
+	return k, func() {
+		sub.Close()
+		stop()
+	}
+}
+
+// serviceHistory sums up what the stats file holds of a service for the live
+// view's detail, e.g. "47 reconnects today across sessions · last error 14:02
+// connection refused"; "" when it holds nothing.
+func serviceHistory(k *stats.Keeper) func(name string) string {
+	return func(name string) string {
+		s := k.Service(name)
+		if s == nil {
+			return ""
+		}
+		now := time.Now()
+		line := fmt.Sprintf("%d reconnects today across sessions", s.Today(now).Total())
+		if n := len(s.Errors); n > 0 {
+			last := s.Errors[n-1]
+			line += fmt.Sprintf(" · last error %s %s", formatStatTime(last.At, now), last.Error)
+		}
+		return line
+	}
+}
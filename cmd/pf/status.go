@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/status"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// runStatusCommand prints the forwards of every running pf session, read from
+// the files those sessions publish. format is "text" (default), "waybar" for a
+// status-bar module, or "json" for the raw session data. With group it reports
+// just that group's combined status, and in text form exits 1 unless the group
+// is healthy, for scripts. check reports pf's own health instead.
+func runStatusCommand(format, group string, check bool) {
+	dir, err := status.Dir()
+	if err != nil {
+		fatal(err)
+	}
+	sessions, err := status.ReadSessions(dir, time.Now())
+	if err != nil {
+		fatal(err)
+	}
+
+	if check {
+		printHealth(dir, sessions, format)
+		return
+	}
+	if group != "" {
+		printGroupStatus(sessions, format, group)
+		return
+	}
+
+	switch format {
+	case "", "text":
+		printStatusText(sessions)
+	case "waybar":
+		printJSON(status.Waybar(sessions))
+	case "json":
+		if sessions == nil {
+			sessions = []status.Session{}
+		}
+		printJSON(sessions)
+	default:
+		fmt.Printf("Error: unknown format %q (use text, waybar or json)\n", format)
+		os.Exit(exitError)
+	}
+}
+
+func printStatusText(sessions []status.Session) {
+	if len(sessions) == 0 {
+		lipgloss.Println(cliMuted.Render("No port forwards running"))
+		return
+	}
+	for _, s := range sessions {
+		heading := "pf run " + s.Label
+		meta := fmt.Sprintf("pid %d, up %s", s.PID, time.Since(s.Started).Round(time.Second))
+		items := make([][2]string, 0, len(s.Services))
+		for _, svc := range s.Services {
+			detail := svc.Status + "  " + svc.Address
+			if svc.Target != "" {
+				detail += " → " + svc.Target
+			}
+			if svc.Error != "" && svc.Status != model.StatusHealthy {
+				detail += "  (" + svc.Error + ")"
+			}
+			items = append(items, [2]string{svc.Name, detail})
+		}
+		for _, g := range s.Groups {
+			meta += fmt.Sprintf(", %s %s", g.Name, g.Status)
+		}
+		printList(heading, meta, items)
+	}
+}
+
+func printGroupStatus(sessions []status.Session, format, name string) {
+	g, running := status.FindGroup(sessions, name)
+	switch format {
+	case "", "text":
+		if !running {
+			lipgloss.Println(cliMuted.Render("Group '" + name + "' is not running"))
+			os.Exit(exitError)
+		}
+		line := fmt.Sprintf("%s: %s (%d/%d healthy)", g.Name, g.Status, g.Healthy, len(g.Members))
+		if g.Reason != "" {
+			line += "  " + g.Reason
+		}
+		fmt.Println(line)
+		if g.Status != model.StatusHealthy {
+			os.Exit(exitError)
+		}
+	case "waybar":
+		printJSON(status.WaybarGroup(sessions, name))
+	case "json":
+		if !running {
+			fmt.Printf("Error: group '%s' is not running\n", name)
+			os.Exit(exitError)
+		}
+		printJSON(g)
+	default:
+		fmt.Printf("Error: unknown format %q (use text, waybar or json)\n", format)
+		os.Exit(exitError)
+	}
+}
+
+// printHealth reports whether pf itself is live and ready, for a monitor or a
+// supervisor's health check: it exits 1 unless it is ready.
+func printHealth(dir string, sessions []status.Session, format string) {
+	_, configErr := storage.NewStorage().LoadData()
+	h := status.CheckHealth(sessions, time.Now(), configErr, dirWritable(dir))
+	switch format {
+	case "", "text":
+		items := [][2]string{{"services", fmt.Sprintf("%d managed, %d healthy", h.Services, h.Healthy)}}
+		if h.Live {
+			items = append([][2]string{{"sessions", fmt.Sprintf("%d live, last heartbeat %s ago", h.Sessions, h.Age)}}, items...)
+		}
+		for _, p := range h.Problems {
+			items = append(items, [2]string{"✗", p})
+		}
+		meta := "(ready)"
+		if !h.Ready {
+			meta = "(not ready)"
+		}
+		printList("Health", meta, items)
+	case "json":
+		printJSON(h)
+	default:
+		fmt.Printf("Error: --check takes the text or json format, not %q\n", format)
+		os.Exit(exitError)
+	}
+	if !h.Ready {
+		os.Exit(exitError)
+	}
+}
+
+// dirWritable reports why sessions could not publish their files in dir.
+func dirWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".check-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func printJSON(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println(string(data))
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// runSwitchCommand repoints a service at another target (a new resource,
+// namespace or host) keeping its local port, and saves the new command.
+// Sessions running the service restart it on the new target within a second.
+func runSwitchCommand(args []string, target, namespace string) {
+	if len(args) != 1 || (target == "" && namespace == "") {
+		fmt.Println("Usage: pf switch <name> --to <resource|host[:port]> [-n <namespace>]")
+		os.Exit(exitError)
+	}
+
+	name := args[0]
+	st := storage.NewStorage()
+	command, err := st.GetService(name)
+	if err != nil {
+		fatal(err)
+	}
+	switched, err := cmdline.Retarget(command, target, namespace)
+	if err != nil {
+		fatal(err)
+	}
+	if switched == command {
+		fmt.Printf("'%s' already forwards there\n", name)
+		return
+	}
+	if err := st.AddService(name, switched); err != nil {
+		fatal(err)
+	}
+
+	fmt.Printf("✓ '%s' switched: %s\n", name, switched)
+	fmt.Println("  Running sessions reconnect on the new target within a second.")
+}
@@ -17,20 +17,18 @@ func runEditCommand() {
 
 	data, err := st.LoadData()
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
 	}
 
 	seed, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
 	}
 
 	tmp, err := os.CreateTemp("", "pf-config-*.json")
 	if err != nil {
 		fmt.Printf("Error: failed to create temp file: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 	tmpPath := tmp.Name()
 	tmp.Write(seed)
@@ -41,20 +39,20 @@ func runEditCommand() {
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
 			os.Remove(tmpPath)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
 
 		if err := cmd.Run(); err != nil {
 			fmt.Printf("Error: editor exited with error: %v\n", err)
 			os.Remove(tmpPath)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
 
 		edited, err := os.ReadFile(tmpPath)
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
 			os.Remove(tmpPath)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
 
 		validated, err := configedit.Validate(edited)
@@ -62,7 +60,7 @@ func runEditCommand() {
 			if err := st.SaveData(validated); err != nil {
 				fmt.Printf("Error: failed to save config: %v\n", err)
 				os.Remove(tmpPath)
-				os.Exit(1)
+				os.Exit(exitError)
 			}
 			fmt.Printf("✓ Config saved: %d service(s), %d group(s)\n", len(validated.Services), len(validated.Groups))
 			os.Remove(tmpPath)
@@ -101,13 +99,12 @@ func runUpdateCommand(args []string) {
 		default:
 			fmt.Printf("Unknown flag for update: %s\n", a)
 			showUpdateUsage()
-			os.Exit(1)
+			os.Exit(exitError)
 		}
 	}
 
 	if err := updater.Run(opts); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
 	}
 }
 
@@ -32,12 +32,11 @@ func runThemeCommand(args []string) {
 	if !theme.Exists(action) {
 		lipgloss.Println(cliMuted.Render("Unknown theme: " + action))
 		showThemes(st)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
 	if err := st.SetTheme(action); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		fatal(err)
 	}
 	// Apply immediately so the confirmation prints in the newly chosen theme.
 	theme.Set(action)
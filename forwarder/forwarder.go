@@ -0,0 +1,247 @@
+// Package forwarder runs forwarder plugins: executables a vendor or an
+// organization drops in ~/.pf/plugins/forwarders to add a kind of tunnel pf
+// does not know, such as a corporate zero-trust client, while pf keeps
+// starting, restarting, health-checking and logging it like any other
+// service. A service runs one with a command of its own form (see Command):
+//
+//	plugin <name> <local>:<remote> [<key>=<value>]...
+//
+// Plugins speak hashicorp/go-plugin's gRPC protocol. One is written in Go,
+// outside pf, by implementing Forwarder and handing it to Serve from main:
+//
+//	func main() { forwarder.Serve(ztna{}) }
+//
+// pf does not talk to the plugin itself: the service's process is pf again,
+// started with HostArg, which launches the plugin, passes it the Request and
+// prints what it reports the way kubectl port-forward would, so the output
+// pf already reads tells when the forward is up and why it failed.
+package forwarder
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-plugin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Protocol is the version of the plugin protocol pf speaks.
+const Protocol = 1
+
+// Handshake is what pf and a plugin check of each other before talking: a
+// plugin started by hand, outside pf, exits with a note instead of waiting.
+var Handshake = plugin.HandshakeConfig{
+	ProtocolVersion:  Protocol,
+	MagicCookieKey:   "PF_FORWARDER_PLUGIN",
+	MagicCookieValue: "b1f0d3c2-pf-forwarder",
+}
+
+// pluginName is the name the plugin is dispensed under.
+const pluginName = "forwarder"
+
+// Request is the forward a plugin is asked to run.
+type Request struct {
+	Service    string            // the pf service it runs for
+	LocalPort  string            // the port to listen on, on the loopback address
+	RemotePort string            // the port to reach on the other side
+	Options    map[string]string // the command's <key>=<value> settings
+}
+
+// Events is how a plugin reports on a forward. Its methods may be called
+// from several goroutines.
+type Events interface {
+	// Ready tells that the forward listens on address and reaches target;
+	// pf takes the service as up from then on.
+	Ready(address, target string)
+	// Connection tells that the forward took a connection.
+	Connection()
+	// Log adds a line to the service's log.
+	Log(line string)
+	// Error adds a line to the service's log and makes it the service's
+	// error, without ending the forward.
+	Error(line string)
+}
+
+// Forwarder is what a plugin implements. Forward runs the forward until ctx
+// is done, when pf stops the service, or until it fails: the error it
+// returns is the service's, and pf restarts it as it would a forward that
+// died. It returns nil when it ends on its own without a failure.
+type Forwarder interface {
+	Forward(ctx context.Context, req Request, ev Events) error
+}
+
+// Serve runs f as a forwarder plugin; it is what a plugin's main calls, and
+// returns once pf is done with the plugin.
+func Serve(f Forwarder) {
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig: Handshake,
+		Plugins:         plugin.PluginSet{pluginName: &grpcPlugin{impl: f}},
+		GRPCServer:      plugin.DefaultGRPCServer,
+		Logger:          hclog.NewNullLogger(),
+	})
+}
+
+// grpcPlugin is a Forwarder over gRPC: the plugin's side serves impl, pf's
+// side dispenses a client.
+type grpcPlugin struct {
+	plugin.NetRPCUnsupportedPlugin
+	impl Forwarder
+}
+
+func (p *grpcPlugin) GRPCServer(_ *plugin.GRPCBroker, s *grpc.Server) error {
+	s.RegisterService(&serviceDesc, p.impl)
+	return nil
+}
+
+func (p *grpcPlugin) GRPCClient(_ context.Context, _ *plugin.GRPCBroker, conn *grpc.ClientConn) (any, error) {
+	return &client{conn: conn}, nil
+}
+
+// serviceDesc is the plugin's gRPC service, written out by hand rather than
+// generated, as its messages are plain structpb.Structs:
+//
+//	service Forwarder { rpc Forward(Struct) returns (stream Struct); }
+//
+// The request holds "service", "localPort", "remotePort" and "options"; each
+// event holds "kind" (see the event kinds) and the fields that kind needs.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pf.forwarder.v1.Forwarder",
+	HandlerType: (*Forwarder)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Forward",
+		Handler:       forwardHandler,
+		ServerStreams: true,
+	}},
+}
+
+const forwardMethod = "/pf.forwarder.v1.Forwarder/Forward"
+
+// The event kinds.
+const (
+	eventReady      = "ready"
+	eventConnection = "connection"
+	eventLog        = "log"
+	eventError      = "error"
+)
+
+func forwardHandler(srv any, stream grpc.ServerStream) error {
+	in := new(structpb.Struct)
+	if err := stream.RecvMsg(in); err != nil {
+		return err
+	}
+	return srv.(Forwarder).Forward(stream.Context(), requestFrom(in), &streamEvents{stream: stream})
+}
+
+func (r Request) toStruct() (*structpb.Struct, error) {
+	options := make(map[string]any, len(r.Options))
+	for k, v := range r.Options {
+		options[k] = v
+	}
+	return structpb.NewStruct(map[string]any{
+		"service":    r.Service,
+		"localPort":  r.LocalPort,
+		"remotePort": r.RemotePort,
+		"options":    options,
+	})
+}
+
+func requestFrom(s *structpb.Struct) Request {
+	f := s.GetFields()
+	r := Request{
+		Service:    f["service"].GetStringValue(),
+		LocalPort:  f["localPort"].GetStringValue(),
+		RemotePort: f["remotePort"].GetStringValue(),
+	}
+	if options := f["options"].GetStructValue().GetFields(); len(options) > 0 {
+		r.Options = make(map[string]string, len(options))
+		for k, v := range options {
+			r.Options[k] = v.GetStringValue()
+		}
+	}
+	return r
+}
+
+// streamEvents sends a plugin's events to pf.
+type streamEvents struct {
+	mu     sync.Mutex
+	stream grpc.ServerStream
+}
+
+func (e *streamEvents) send(fields map[string]any) {
+	msg, err := structpb.NewStruct(fields)
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stream.SendMsg(msg) // fails only once pf is gone
+}
+
+func (e *streamEvents) Ready(address, target string) {
+	e.send(map[string]any{"kind": eventReady, "address": address, "target": target})
+}
+
+func (e *streamEvents) Connection() {
+	e.send(map[string]any{"kind": eventConnection})
+}
+
+func (e *streamEvents) Log(line string) {
+	e.send(map[string]any{"kind": eventLog, "text": line})
+}
+
+func (e *streamEvents) Error(line string) {
+	e.send(map[string]any{"kind": eventError, "text": line})
+}
+
+// client is pf's side of a plugin.
+type client struct {
+	conn *grpc.ClientConn
+}
+
+// Forward asks the plugin to run req, handing its events to ev until it
+// ends; see Forwarder.
+func (c *client) Forward(ctx context.Context, req Request, ev Events) error {
+	in, err := req.toStruct()
+	if err != nil {
+		return err
+	}
+	stream, err := c.conn.NewStream(ctx, &serviceDesc.Streams[0], forwardMethod)
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(in); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+	for {
+		out := new(structpb.Struct)
+		err := stream.RecvMsg(out)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return errors.New(status.Convert(err).Message())
+		}
+		f := out.GetFields()
+		switch text := f["text"].GetStringValue(); f["kind"].GetStringValue() {
+		case eventReady:
+			ev.Ready(f["address"].GetStringValue(), f["target"].GetStringValue())
+		case eventConnection:
+			ev.Connection()
+		case eventLog:
+			ev.Log(text)
+		case eventError:
+			ev.Error(text)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package forwarder
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestMain makes the test binary a forwarder plugin when pf starts it as
+// one, so the tests run a real plugin over go-plugin.
+func TestMain(m *testing.M) {
+	if os.Getenv("PF_TEST_FORWARDER") == "1" {
+		Serve(testForwarder{})
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// testForwarder reports what it was asked, then fails with the "fail"
+// option, or runs until stopped.
+type testForwarder struct{}
+
+func (testForwarder) Forward(ctx context.Context, req Request, ev Events) error {
+	ev.Log("service " + req.Service + " zone " + req.Options["zone"])
+	ev.Ready("", "gateway:"+req.RemotePort)
+	ev.Connection()
+	if msg := req.Options["fail"]; msg != "" {
+		ev.Error("tunnel dropped")
+		return errorString(msg)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+type errorString string
+
+func (e errorString) Error() string { return string(e) }
+
+// recorder keeps the events it gets, one line each.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+	ready  chan struct{}
+}
+
+func (r *recorder) add(e string) {
+	r.mu.Lock()
+	r.events = append(r.events, e)
+	r.mu.Unlock()
+}
+
+func (r *recorder) Ready(address, target string) {
+	r.add("ready " + address + " " + target)
+	if r.ready != nil {
+		close(r.ready)
+	}
+}
+func (r *recorder) Connection()       { r.add("connection") }
+func (r *recorder) Log(line string)   { r.add("log " + line) }
+func (r *recorder) Error(line string) { r.add("error " + line) }
+
+func testPlugin(t *testing.T) Plugin {
+	t.Setenv("PF_TEST_FORWARDER", "1")
+	return Plugin{Name: "test", Path: os.Args[0]}
+}
+
+func TestParseCommand(t *testing.T) {
+	got, err := ParseCommand("plugin ztna 5432:5432 zone=eu app=db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Command{Plugin: "ztna", LocalPort: "5432", RemotePort: "5432", Options: map[string]string{"zone": "eu", "app": "db"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	for _, bad := range []string{
+		"kubectl port-forward svc/db 5432:5432",
+		"plugin ztna",
+		"plugin ZTNA 5432:5432",
+		"plugin ztna 5432",
+		"plugin ztna 5432:5432 zone",
+		"plugin ztna 5432:5432 =eu",
+	} {
+		if _, err := ParseCommand(bad); err == nil {
+			t.Errorf("ParseCommand(%q) = nil error", bad)
+		}
+	}
+}
+
+func TestPluginForwardReportsEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var r recorder
+	req := Request{Service: "db", LocalPort: "5432", RemotePort: "6432", Options: map[string]string{"zone": "eu", "fail": "gateway gone"}}
+	err := testPlugin(t).Forward(ctx, req, &r)
+	if err == nil || err.Error() != "gateway gone" {
+		t.Fatalf("err = %v, want gateway gone", err)
+	}
+	want := []string{"log service db zone eu", "ready  gateway:6432", "connection", "error tunnel dropped"}
+	if !reflect.DeepEqual(r.events, want) {
+		t.Errorf("events = %q, want %q", r.events, want)
+	}
+}
+
+func TestPluginForwardStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	r := recorder{ready: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() { done <- testPlugin(t).Forward(ctx, Request{Service: "db"}, &r) }()
+	select {
+	case <-r.ready:
+	case err := <-done:
+		t.Fatalf("Forward ended before the forward was up: %v", err)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestHostPrintsLikeKubectl(t *testing.T) {
+	testPlugin(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	dir, err := Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	exe, err := os.ReadFile(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "ztna"
+	if filepath.Ext(os.Args[0]) == ".exe" {
+		name += ".exe"
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), exe, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	args := strings.Fields("db plugin ztna 15432:5432 zone=eu fail=boom")
+	if err := Host(ctx, args, &stdout, &stderr); err == nil || err.Error() != "boom" {
+		t.Fatalf("err = %v, want boom", err)
+	}
+	wantOut := "service db zone eu\nForwarding from 127.0.0.1:15432 -> gateway:5432\nHandling connection for 15432\n"
+	if stdout.String() != wantOut {
+		t.Errorf("stdout = %q, want %q", stdout.String(), wantOut)
+	}
+	if stderr.String() != "error: tunnel dropped\n" {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+
+	if err := Host(ctx, strings.Fields("db plugin missing 1:2"), &stdout, &stderr); err == nil || !strings.Contains(err.Error(), "no forwarder plugin") {
+		t.Errorf("missing plugin: err = %v", err)
+	}
+}
+
+func TestHostCommand(t *testing.T) {
+	if IsHost([]string{"pf", "run"}) || !IsHost([]string{"pf", HostArg, "db"}) {
+		t.Error("IsHost does not tell the host apart")
+	}
+	if os.PathSeparator == '\\' {
+		got, err := HostCommand(`C:\pf\pf.exe`, "db", `plugin ztna 5432:5432 dir=C:\certs\`)
+		want := `"C:\pf\pf.exe" __forwarder-host "db" "plugin" "ztna" "5432:5432" "dir=C:\certs\\"`
+		if err != nil || got != want {
+			t.Errorf("got %s (%v), want %s", got, err, want)
+		}
+		if _, err := HostCommand(`C:\pf\pf.exe`, "db", `plugin ztna 5432:5432 zone="eu"`); err == nil {
+			t.Error("a double quote should be refused for cmd.exe")
+		}
+		return
+	}
+	got, err := HostCommand("/usr/bin/pf", "db's", "plugin ztna  5432:5432 zone=eu")
+	want := `'/usr/bin/pf' __forwarder-host 'db'\''s' 'plugin' 'ztna' '5432:5432' 'zone=eu'`
+	if err != nil || got != want {
+		t.Errorf("got %s (%v), want %s", got, err, want)
+	}
+}
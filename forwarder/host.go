@@ -0,0 +1,241 @@
+package forwarder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-plugin"
+
+	"github.com/alinemone/go-port-forward/internal/plugindir"
+)
+
+// Command is a service run by a forwarder plugin. It is stored as a command
+// of its own form:
+//
+//	plugin <name> <local>:<remote> [<key>=<value>]...
+//
+// The settings go to the plugin as the Request's Options.
+type Command struct {
+	Plugin     string
+	LocalPort  string
+	RemotePort string
+	Options    map[string]string
+}
+
+// IsCommand reports whether command runs a forwarder plugin.
+func IsCommand(command string) bool {
+	fields := strings.Fields(command)
+	return len(fields) > 0 && fields[0] == "plugin"
+}
+
+// portsPattern is a Command's <local>:<remote>.
+var portsPattern = regexp.MustCompile(`^(\d{1,5}):(\d{1,5})$`)
+
+// ParseCommand reads a plugin command; see Command.
+func ParseCommand(command string) (Command, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 || fields[0] != "plugin" {
+		return Command{}, fmt.Errorf("not a plugin command: %q", command)
+	}
+	if len(fields) < 3 {
+		return Command{}, fmt.Errorf("plugin: want plugin <name> <local>:<remote> [<key>=<value>]...")
+	}
+	if err := ValidName(fields[1]); err != nil {
+		return Command{}, fmt.Errorf("plugin: %v", err)
+	}
+	ports := portsPattern.FindStringSubmatch(fields[2])
+	if ports == nil {
+		return Command{}, fmt.Errorf("plugin: ports are <local>:<remote>, not %q", fields[2])
+	}
+	c := Command{Plugin: fields[1], LocalPort: ports[1], RemotePort: ports[2]}
+	for _, f := range fields[3:] {
+		key, value, ok := strings.Cut(f, "=")
+		if !ok || key == "" {
+			return Command{}, fmt.Errorf("plugin: settings are <key>=<value>, not %q", f)
+		}
+		if c.Options == nil {
+			c.Options = map[string]string{}
+		}
+		c.Options[key] = value
+	}
+	return c, nil
+}
+
+// Dir is where forwarder plugins live: ~/.pf/plugins/forwarders.
+func Dir() (string, error) {
+	return plugindir.Dir("forwarders")
+}
+
+// ValidName reports whether name can name a forwarder plugin.
+func ValidName(name string) error {
+	return plugindir.ValidName("forwarder", name)
+}
+
+// Plugin is a forwarder plugin: the executable Path, found under Name.
+type Plugin struct {
+	Name string
+	Path string
+}
+
+// Find returns the plugin name in dir: the executable <name>, or <name>.exe
+// on Windows.
+func Find(dir, name string) (Plugin, error) {
+	p, err := plugindir.Find("forwarder", dir, name)
+	return Plugin(p), err
+}
+
+// List returns the plugins in dir, by name; none when dir does not exist.
+// Files that are not executable are left out.
+func List(dir string) ([]Plugin, error) {
+	found, err := plugindir.List("forwarder", dir)
+	var plugins []Plugin
+	for _, p := range found {
+		plugins = append(plugins, Plugin(p))
+	}
+	return plugins, err
+}
+
+// Forward starts the plugin, runs req through it as Forwarder.Forward does,
+// and stops it again.
+func (p Plugin) Forward(ctx context.Context, req Request, ev Events) error {
+	c := plugin.NewClient(&plugin.ClientConfig{
+		HandshakeConfig:  Handshake,
+		Plugins:          plugin.PluginSet{pluginName: &grpcPlugin{}},
+		Cmd:              exec.Command(p.Path),
+		AllowedProtocols: []plugin.Protocol{plugin.ProtocolGRPC},
+		Logger:           hclog.NewNullLogger(),
+		Stderr:           logWriter{ev},
+		SyncStdout:       logWriter{ev},
+		SyncStderr:       logWriter{ev},
+	})
+	defer c.Kill()
+	conn, err := c.Client()
+	if err != nil {
+		return fmt.Errorf("forwarder plugin %s: %v", p.Name, err)
+	}
+	raw, err := conn.Dispense(pluginName)
+	if err != nil {
+		return fmt.Errorf("forwarder plugin %s: %v", p.Name, err)
+	}
+	return raw.(*client).Forward(ctx, req, ev)
+}
+
+// logWriter logs what a plugin prints, line by line.
+type logWriter struct {
+	ev Events
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			w.ev.Log(line)
+		}
+	}
+	return len(p), nil
+}
+
+// HostArg is the argument that starts pf as a plugin's host: the process a
+// plugin command's service runs, with the service's name and the command's
+// fields after it.
+const HostArg = "__forwarder-host"
+
+// IsHost reports whether this pf was started as a plugin's host.
+func IsHost(args []string) bool {
+	return len(args) > 1 && args[1] == HostArg
+}
+
+// HostCommand returns how the service whose command is the plugin command
+// command runs: exe, pf's own executable, as the plugin's host. On Windows
+// it refuses a double quote anywhere in them, which cmd.exe has no way to
+// take inside a quoted argument.
+func HostCommand(exe, service, command string) (string, error) {
+	if runtime.GOOS == "windows" && strings.Contains(exe+service+command, `"`) {
+		return "", fmt.Errorf("forwarder plugin: service '%s' cannot have a double quote in its command on Windows", service)
+	}
+	quoted := []string{quote(exe), HostArg, quote(service)}
+	for _, f := range strings.Fields(command) {
+		quoted = append(quoted, quote(f))
+	}
+	return strings.Join(quoted, " "), nil
+}
+
+// quote quotes s for sh, or for cmd.exe on Windows, where s has no double
+// quote (see HostCommand) and backslashes at its end are doubled so the
+// closing quote is not read as an escaped one.
+func quote(s string) string {
+	if runtime.GOOS == "windows" {
+		trimmed := strings.TrimRight(s, `\`)
+		return `"` + s + s[len(trimmed):] + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Host is pf as a plugin's host, with args the arguments after HostArg: it
+// runs the plugin the command names for the service until ctx is done. It
+// prints what the plugin reports the way kubectl port-forward does, which pf
+// reads from the service's output: that the forward is up and each
+// connection on stdout, along with log lines; errors on stderr.
+func Host(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: pf %s <service> plugin <name> <local>:<remote> [<key>=<value>]...", HostArg)
+	}
+	service := args[0]
+	c, err := ParseCommand(strings.Join(args[1:], " "))
+	if err != nil {
+		return err
+	}
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+	p, err := Find(dir, c.Plugin)
+	if err != nil {
+		return err
+	}
+	req := Request{Service: service, LocalPort: c.LocalPort, RemotePort: c.RemotePort, Options: c.Options}
+	return p.Forward(ctx, req, &printer{stdout: stdout, stderr: stderr, port: c.LocalPort})
+}
+
+// printer prints a plugin's events for Host.
+type printer struct {
+	mu             sync.Mutex
+	stdout, stderr io.Writer
+	port           string
+}
+
+func (p *printer) Ready(address, target string) {
+	if address == "" {
+		address = net.JoinHostPort("127.0.0.1", p.port)
+	}
+	line := "Forwarding from " + address
+	if target != "" {
+		line += " -> " + target
+	}
+	p.println(p.stdout, line)
+}
+
+func (p *printer) Connection() {
+	p.println(p.stdout, "Handling connection for "+p.port)
+}
+
+func (p *printer) Log(line string) {
+	p.println(p.stdout, line)
+}
+
+func (p *printer) Error(line string) {
+	p.println(p.stderr, "error: "+line)
+}
+
+func (p *printer) println(w io.Writer, line string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintln(w, line)
+}
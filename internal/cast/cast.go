@@ -0,0 +1,106 @@
+// Package cast writes terminal recordings in asciinema's asciicast v2 format:
+// a header line, then one JSON array per chunk of output, [seconds, "o",
+// text], and per resize, [seconds, "r", "COLSxROWS"]. `pf run --record`
+// records the TUI with it; asciinema play, or asciinema.org, plays it back.
+package cast
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// header is an asciicast v2 file's first line.
+type header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Recorder writes a recording's events as they happen. It is safe for
+// concurrent use. Input is never recorded, so typed passwords stay out.
+type Recorder struct {
+	mu            sync.Mutex
+	w             io.Writer
+	start         time.Time
+	width, height int
+	pending       []byte // an unfinished UTF-8 sequence, kept for the next chunk
+	err           error  // the first write error; later events are dropped
+}
+
+// New writes the header of a recording of a width×height terminal started at
+// start, and returns the recorder for its events.
+func New(w io.Writer, width, height int, title string, start time.Time) (*Recorder, error) {
+	h := header{Version: 2, Width: width, Height: height, Timestamp: start.Unix(), Title: title}
+	if term := os.Getenv("TERM"); term != "" {
+		h.Env = map[string]string{"TERM": term}
+	}
+	line, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(line, '\n')); err != nil {
+		return nil, err
+	}
+	return &Recorder{w: w, start: start, width: width, height: height}, nil
+}
+
+// Output records p as written to the terminal now. A UTF-8 sequence split
+// across chunks waits for its end, as JSON strings cannot hold half of one.
+func (r *Recorder) Output(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data := append(r.pending, p...)
+	cut := len(data)
+	// Back up over at most one unfinished sequence at the end.
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	r.pending = append([]byte(nil), data[cut:]...)
+	if cut > 0 {
+		r.event("o", string(data[:cut]))
+	}
+}
+
+// Resize records the terminal's new size; the same size again records
+// nothing.
+func (r *Recorder) Resize(width, height int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if width == r.width && height == r.height {
+		return
+	}
+	r.width, r.height = width, height
+	r.event("r", fmt.Sprintf("%dx%d", width, height))
+}
+
+// Err returns the first error writing the recording met, if any.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// event writes one event line; r.mu is held.
+func (r *Recorder) event(kind, data string) {
+	if r.err != nil {
+		return
+	}
+	line, err := json.Marshal([]any{time.Since(r.start).Seconds(), kind, data})
+	if err == nil {
+		_, err = r.w.Write(append(line, '\n'))
+	}
+	r.err = err
+}
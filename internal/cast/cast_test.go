@@ -0,0 +1,51 @@
+package cast
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecording(t *testing.T) {
+	t.Setenv("TERM", "xterm-256color")
+	var buf bytes.Buffer
+	r, err := New(&buf, 80, 24, "pf run db", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dot := []byte("●")
+	r.Output(append([]byte("\x1b[1mdb "), dot[:1]...)) // the dot split across writes
+	r.Output(dot[1:])
+	r.Resize(80, 24)
+	r.Resize(100, 30)
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("lines = %q", lines)
+	}
+	var h header
+	if err := json.Unmarshal([]byte(lines[0]), &h); err != nil {
+		t.Fatal(err)
+	}
+	if h.Version != 2 || h.Width != 80 || h.Height != 24 || h.Title != "pf run db" || h.Env["TERM"] != "xterm-256color" {
+		t.Errorf("header = %+v", h)
+	}
+	var want = [][2]string{{"o", "\x1b[1mdb "}, {"o", "●"}, {"r", "100x30"}}
+	for i, line := range lines[1:] {
+		var e []any
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatal(err)
+		}
+		if len(e) != 3 || e[1] != want[i][0] || e[2] != want[i][1] {
+			t.Errorf("event %d = %v, want %q", i, e, want[i])
+		}
+		if secs, ok := e[0].(float64); !ok || secs < 0 {
+			t.Errorf("event %d time = %v", i, e[0])
+		}
+	}
+}
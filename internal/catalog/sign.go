@@ -0,0 +1,107 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// SignatureSuffix is added to a catalog's URL to fetch its signature, the
+// name minisign gives it.
+const SignatureSuffix = ".minisig"
+
+// maxSignatureSize bounds a signature response.
+const maxSignatureSize = 4 << 10
+
+// Verification is how a synced catalog must be signed. PublicKey is a
+// minisign public key ("RWQ…"); empty means the catalog is not signed.
+// Insecure accepts a catalog whose signature is missing or does not match.
+type Verification struct {
+	PublicKey string
+	Insecure  bool
+}
+
+// PublicKey is a parsed minisign Ed25519 public key.
+type PublicKey struct {
+	id  [8]byte
+	key ed25519.PublicKey
+}
+
+// ParsePublicKey reads a minisign public key: the base64 line, or a whole
+// .pub file with its comment.
+func ParsePublicKey(s string) (PublicKey, error) {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[len(lines)-1]))
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != "Ed" {
+		return PublicKey{}, fmt.Errorf("not a minisign public key")
+	}
+	var k PublicKey
+	copy(k.id[:], raw[2:10])
+	k.key = ed25519.PublicKey(raw[10:])
+	return k, nil
+}
+
+// Verify checks a minisign signature file over body: the signature itself,
+// made with this key, and the global signature over its trusted comment.
+// Both of minisign's kinds are supported: prehashed (its default), which
+// signs the BLAKE2b-512 hash of body, and legacy (minisign -S -l), which
+// signs body itself.
+func (k PublicKey) Verify(body, signature []byte) error {
+	lines := strings.Split(strings.ReplaceAll(strings.TrimSpace(string(signature)), "\r\n", "\n"), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "untrusted comment:") || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return fmt.Errorf("not a minisign signature")
+	}
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("not a minisign signature")
+	}
+	signed := body
+	switch string(sig[:2]) {
+	case "ED":
+		sum := blake2b.Sum512(body)
+		signed = sum[:]
+	case "Ed":
+	default:
+		return fmt.Errorf("unknown signature algorithm %q", sig[:2])
+	}
+	switch {
+	case !bytes.Equal(sig[2:10], k.id[:]):
+		return fmt.Errorf("signed with another key")
+	case !ed25519.Verify(k.key, signed, sig[10:]):
+		return fmt.Errorf("the signature does not match the catalog")
+	}
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	comment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	if err != nil || !ed25519.Verify(k.key, append(bytes.Clone(sig[10:]), comment...), global) {
+		return fmt.Errorf("the signature's trusted comment was tampered with")
+	}
+	return nil
+}
+
+// verify checks body against the signature published next to url.
+func verify(ctx context.Context, client *http.Client, url string, body []byte, key PublicKey) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+SignatureSuffix, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s%s: %s", url, SignatureSuffix, resp.Status)
+	}
+	signature, err := io.ReadAll(io.LimitReader(resp.Body, maxSignatureSize))
+	if err != nil {
+		return err
+	}
+	return key.Verify(body, signature)
+}
@@ -20,18 +20,33 @@ type CertStorageConfig struct {
 	KeyPath  string `json:"key_path"`
 }
 
-func NewManager() (*Manager, error) {
+// ConfigPath is where the certificate settings are saved:
+// ~/.pf/certificate.json.
+func ConfigPath() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
+		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
+	return filepath.Join(homeDir, ".pf", "certificate.json"), nil
+}
 
-	configDir := filepath.Join(homeDir, ".pf")
-	if err := os.MkdirAll(configDir, 0700); err != nil {
-		return nil, fmt.Errorf("failed to create config directory: %w", err)
+// Dir is where certificates extracted from a P12 file are kept: ~/.pf/certs.
+func Dir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
+	return filepath.Join(homeDir, ".pf", "certs"), nil
+}
 
-	configPath := filepath.Join(configDir, "certificate.json")
+func NewManager() (*Manager, error) {
+	configPath, err := ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(configPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	}
 
 	manager := &Manager{
 		configPath: configPath,
@@ -36,12 +36,10 @@ func ExtractP12(p12Path, password string) (*P12Config, error) {
 	}
 
 	// Create temporary directory for extracted files
-	homeDir, err := os.UserHomeDir()
+	certDir, err := Dir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
+		return nil, err
 	}
-
-	certDir := filepath.Join(homeDir, ".pf", "certs")
 	if err := os.MkdirAll(certDir, 0700); err != nil {
 		return nil, fmt.Errorf("failed to create cert directory: %w", err)
 	}
@@ -0,0 +1,597 @@
+// Package cmdline reads and rewrites the shell commands services run:
+// kubectl port-forward and proxy, ssh -L and the monitor pseudo-command.
+// It works on the command text, keeping what it does not understand.
+package cmdline
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// SetLocalPort rewrites the local port of a kubectl port-forward, kubectl
+// proxy or ssh -L command.
+func SetLocalPort(command, port string) (string, error) {
+	spans := fieldRegex.FindAllStringIndex(command, -1)
+	fields := make([]string, len(spans))
+	for i, sp := range spans {
+		fields[i] = command[sp[0]:sp[1]]
+	}
+	replace := func(i int, text string) string {
+		return command[:spans[i][0]] + text + command[spans[i][1]:]
+	}
+
+	if verb, ok := kubectlProxyVerb(fields); ok {
+		for i, f := range fields {
+			name, _, inline := strings.Cut(f, "=")
+			switch {
+			case (name == "--port" || name == "-p") && inline:
+				return replace(i, name+"="+port), nil
+			case (name == "--port" || name == "-p") && i+1 < len(fields):
+				return replace(i+1, port), nil
+			case strings.HasPrefix(f, "-p") && !strings.HasPrefix(f, "--") && len(f) > 2:
+				return replace(i, "-p"+port), nil
+			}
+		}
+		return command[:spans[verb][1]] + " --port=" + port + command[spans[verb][1]:], nil
+	}
+	if sshForwardSpec(command) != "" {
+		for i, f := range fields {
+			at, prefix, spec := i, "", ""
+			switch {
+			case f == "-L" && i+1 < len(fields):
+				at, spec = i+1, fields[i+1]
+			case strings.HasPrefix(f, "-L") && len(f) > 2:
+				prefix, spec = "-L", f[2:]
+			default:
+				continue
+			}
+			parts := strings.Split(spec, ":")
+			if len(parts) != 3 && len(parts) != 4 {
+				return "", fmt.Errorf("cannot read the -L spec %q", spec)
+			}
+			parts[len(parts)-3] = port
+			return replace(at, prefix+strings.Join(parts, ":")), nil
+		}
+	}
+	if m := portRegex.FindStringSubmatchIndex(command); m != nil {
+		return command[:m[2]] + port + command[m[3]:], nil
+	}
+	return "", fmt.Errorf("no local port to change in %q", command)
+}
+
+// SetContext sets or replaces the --context of a kubectl command.
+func SetContext(command, context string) (string, error) {
+	return setKubectlFlag(command, "a context", context, "--context")
+}
+
+// SetNamespace sets or replaces the -n/--namespace of a kubectl command.
+func SetNamespace(command, namespace string) (string, error) {
+	return setKubectlFlag(command, "a namespace", namespace, "-n", "--namespace")
+}
+
+// setKubectlFlag sets the value of a kubectl flag known by names, replacing
+// it where the command gives it and adding the last name after "kubectl"
+// otherwise. what names the value in the error for other commands.
+func setKubectlFlag(command, what, value string, names ...string) (string, error) {
+	spans := fieldRegex.FindAllStringIndex(command, -1)
+	fields := make([]string, len(spans))
+	for i, sp := range spans {
+		fields[i] = command[sp[0]:sp[1]]
+	}
+	if len(fields) == 0 || strings.TrimSuffix(filepath.Base(fields[0]), ".exe") != "kubectl" {
+		return "", fmt.Errorf("%s only applies to kubectl", what)
+	}
+	for i, f := range fields {
+		name, _, inline := strings.Cut(f, "=")
+		if !slices.Contains(names, name) {
+			continue
+		}
+		if inline {
+			return command[:spans[i][0]] + name + "=" + value + command[spans[i][1]:], nil
+		}
+		if i+1 < len(fields) {
+			return command[:spans[i+1][0]] + value + command[spans[i+1][1]:], nil
+		}
+	}
+	return command[:spans[0][1]] + " " + names[len(names)-1] + " " + value + command[spans[0][1]:], nil
+}
+
+// SetRemotePort rewrites the remote port of a kubectl port-forward or ssh -L
+// command.
+func SetRemotePort(command, port string) (string, error) {
+	spans := fieldRegex.FindAllStringIndex(command, -1)
+	fields := make([]string, len(spans))
+	for i, sp := range spans {
+		fields[i] = command[sp[0]:sp[1]]
+	}
+	if _, ok := kubectlProxyVerb(fields); ok {
+		return "", fmt.Errorf("kubectl proxy has no remote port")
+	}
+	if sshForwardSpec(command) != "" {
+		for i, f := range fields {
+			at, prefix, spec := i, "", ""
+			switch {
+			case f == "-L" && i+1 < len(fields):
+				at, spec = i+1, fields[i+1]
+			case strings.HasPrefix(f, "-L") && len(f) > 2:
+				prefix, spec = "-L", f[2:]
+			default:
+				continue
+			}
+			parts := strings.Split(spec, ":")
+			if len(parts) != 3 && len(parts) != 4 {
+				return "", fmt.Errorf("cannot read the -L spec %q", spec)
+			}
+			parts[len(parts)-1] = port
+			return command[:spans[at][0]] + prefix + strings.Join(parts, ":") + command[spans[at][1]:], nil
+		}
+	}
+	if m := portRegex.FindStringSubmatchIndex(command); m != nil {
+		return command[:m[4]] + port + command[m[5]:], nil
+	}
+	return "", fmt.Errorf("no remote port to change in %q", command)
+}
+
+var portRegex = regexp.MustCompile(`(\d+):(\d+)`)
+
+func ParsePorts(command string) (local, remote string) {
+	// kubectl proxy serves the whole API on one local port.
+	if port, ok := kubectlProxyPort(command); ok {
+		return port, ""
+	}
+	// ssh -L [bind_address:]port:host:hostport has the host between the ports.
+	if parts := strings.Split(sshForwardSpec(command), ":"); len(parts) == 3 || len(parts) == 4 {
+		return parts[len(parts)-3], parts[len(parts)-1]
+	}
+	matches := portRegex.FindStringSubmatch(command)
+	if len(matches) == 3 {
+		return matches[1], matches[2]
+	}
+	return "", ""
+}
+
+// Forward is what a port-forward command binds locally and where it forwards
+// to, as far as can be read from the command line.
+type Forward struct {
+	Address   string // local bind address; "" means the tool's loopback default
+	Target    string // kubectl resource as kind/name (e.g. "svc/postgres") or ssh -L host
+	Namespace string // kubectl -n/--namespace; "" when not given
+	Context   string // kubectl --context; "" when not given
+	SSH       bool   // an ssh -L forward, so Target is a host name or IP
+	APIProxy  bool   // kubectl proxy: the whole Kubernetes API rather than one port
+}
+
+// kubectlValueFlags are port-forward flags that take a separate value, so the
+// value is not mistaken for the resource name.
+var kubectlValueFlags = map[string]bool{
+	"-n": true, "--namespace": true, "--address": true, "--context": true,
+	"--cluster": true, "--kubeconfig": true, "--user": true, "-s": true,
+	"--server": true, "--pod-running-timeout": true, "--client-certificate": true,
+	"--client-key": true, "--token": true, "--as": true,
+}
+
+// ParseForward reads the bind address and target from a kubectl port-forward
+// or ssh -L command. Fields it cannot find are left empty.
+func ParseForward(command string) Forward {
+	fields := strings.Fields(command)
+	for i, f := range fields {
+		if f == "port-forward" {
+			return parseKubectlForward(fields, i)
+		}
+	}
+	if verb, ok := kubectlProxyVerb(fields); ok {
+		fw := parseKubectlForward(fields, verb)
+		fw.Target, fw.APIProxy = "", true
+		return fw
+	}
+	if spec := sshForwardSpec(command); spec != "" {
+		return parseSSHForward(spec)
+	}
+	return Forward{}
+}
+
+// DefaultProxyPort is kubectl proxy's port when the command does not set one.
+const DefaultProxyPort = "8001"
+
+// kubectlProxyVerb finds the proxy verb of a `kubectl proxy` command.
+func kubectlProxyVerb(fields []string) (int, bool) {
+	if len(fields) == 0 {
+		return 0, false
+	}
+	if tool := strings.TrimSuffix(filepath.Base(fields[0]), ".exe"); tool != "kubectl" {
+		return 0, false
+	}
+	verb := slices.Index(fields, "proxy")
+	return verb, verb > 0
+}
+
+// kubectlProxyPort returns the local port of a `kubectl proxy` command, and
+// whether the command is one.
+func kubectlProxyPort(command string) (string, bool) {
+	fields := strings.Fields(command)
+	if _, ok := kubectlProxyVerb(fields); !ok {
+		return "", false
+	}
+	port := DefaultProxyPort
+	for i, f := range fields {
+		name, value, inline := strings.Cut(f, "=")
+		switch {
+		case name != "--port" && name != "-p" && strings.HasPrefix(f, "-p") && !strings.HasPrefix(f, "--"):
+			port = f[2:] // -p8001
+		case name != "--port" && name != "-p":
+			continue
+		case inline:
+			port = value
+		case i+1 < len(fields):
+			port = fields[i+1]
+		}
+	}
+	return port, true
+}
+
+// AddPortForwardFlags gives a kubectl port-forward command the flags,
+// "--name=value", right after its verb, leaving out those it sets itself.
+// Other commands are returned as they are.
+func AddPortForwardFlags(command string, flags []string) string {
+	locs := fieldRegex.FindAllStringIndex(command, -1)
+	fields := make([]string, len(locs))
+	for i, loc := range locs {
+		fields[i] = command[loc[0]:loc[1]]
+	}
+	verb := slices.Index(fields, "port-forward")
+	if len(flags) == 0 || verb < 1 || strings.TrimSuffix(filepath.Base(fields[0]), ".exe") != "kubectl" {
+		return command
+	}
+	var add strings.Builder
+	for _, flag := range flags {
+		name, _, _ := strings.Cut(flag, "=")
+		own := slices.ContainsFunc(fields, func(f string) bool { return f == name || strings.HasPrefix(f, name+"=") })
+		if !own {
+			add.WriteString(" " + flag)
+		}
+	}
+	end := locs[verb][1]
+	return command[:end] + add.String() + command[end:]
+}
+
+// parseKubectlForward reads a kubectl command whose port-forward verb is at
+// fields[verb]. Flags may come before or after the verb (kubectl accepts
+// "kubectl -n ns port-forward ..."); the resource is the first positional
+// argument after it that isn't a port spec.
+func parseKubectlForward(fields []string, verb int) Forward {
+	var fw Forward
+	for i := 1; i < len(fields); i++ {
+		arg := fields[i]
+		if i == verb {
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			name, value, inline := strings.Cut(arg, "=")
+			if !inline && kubectlValueFlags[name] && i+1 < len(fields) {
+				i++
+				value = fields[i]
+			}
+			switch name {
+			case "--address":
+				// kubectl accepts a comma-separated list; the first is the one
+				// worth showing.
+				fw.Address, _, _ = strings.Cut(value, ",")
+			case "-n", "--namespace":
+				fw.Namespace = value
+			case "--context":
+				fw.Context = value
+			}
+			continue
+		}
+		if i > verb && fw.Target == "" && !portRegex.MatchString(arg) {
+			fw.Target = kubectlResource(arg)
+		}
+	}
+	return fw
+}
+
+// kubectlResource normalizes a port-forward resource to kind/name. A bare name
+// is a pod to kubectl, and long kind names are shortened to kubectl's own
+// abbreviations so the table column stays narrow.
+func kubectlResource(arg string) string {
+	kind, name, ok := strings.Cut(arg, "/")
+	if !ok {
+		return "pod/" + arg
+	}
+	switch strings.ToLower(kind) {
+	case "service", "services":
+		kind = "svc"
+	case "deployment", "deployments":
+		kind = "deploy"
+	case "statefulset", "statefulsets":
+		kind = "sts"
+	case "replicaset", "replicasets":
+		kind = "rs"
+	case "pods":
+		kind = "pod"
+	}
+	return kind + "/" + name
+}
+
+// fieldRegex finds a command's whitespace-separated fields with their
+// positions, so Retarget can edit one without re-spacing (or unquoting) the
+// rest.
+var fieldRegex = regexp.MustCompile(`\S+`)
+
+// RolloutStatusCommand turns a kubectl port-forward command into the `kubectl
+// rollout status --watch=false` that tells whether the workload behind it is
+// mid-rollout, with the same kubeconfig, context and namespace. A Service is
+// taken to front the Deployment of the same name. ok is false for other
+// commands and for pods, which have no rollout.
+func RolloutStatusCommand(command string) (rollout string, ok bool) {
+	kept, workload, ok := splitPortForward(command)
+	if !ok {
+		return "", false
+	}
+	kind, name, _ := strings.Cut(workload, "/")
+	switch kind {
+	case "svc":
+		workload = "deploy/" + name
+	case "deploy", "sts":
+	default:
+		return "", false
+	}
+	return strings.Join(append(kept, "rollout", "status", workload, "--watch=false"), " "), true
+}
+
+// splitPortForward splits a kubectl port-forward command into kubectl with
+// the flags another kubectl command needs to reach the same cluster and
+// namespace, and the resource it forwards to as kind/name. ok is false for
+// other commands.
+func splitPortForward(command string) (kubectl []string, resource string, ok bool) {
+	fields := fieldRegex.FindAllString(command, -1)
+	verb := slices.Index(fields, "port-forward")
+	if verb < 0 {
+		return nil, "", false
+	}
+	// Everything before the verb is kubectl and its global flags.
+	kubectl = slices.Clone(fields[:verb])
+	for i := verb + 1; i < len(fields); i++ {
+		arg := fields[i]
+		if strings.HasPrefix(arg, "-") {
+			name, _, inline := strings.Cut(arg, "=")
+			flag := []string{arg}
+			if !inline && kubectlValueFlags[name] && i+1 < len(fields) {
+				i++
+				flag = append(flag, fields[i])
+			}
+			if name != "--address" && name != "--pod-running-timeout" {
+				kubectl = append(kubectl, flag...)
+			}
+			continue
+		}
+		if resource == "" && !portRegex.MatchString(arg) {
+			resource = kubectlResource(arg)
+		}
+	}
+	return kubectl, resource, true
+}
+
+// RemoteProbe is how to ask whether the far end of a forward is up: a
+// command, run by the shell, whose outcome Kind says how to read.
+type RemoteProbe struct {
+	Command string
+	Kind    string // see the RemoteProbe kinds
+	Target  string // what is checked, for messages: "pod/api-0", "db.internal:5432"
+}
+
+// RemoteProbe kinds, each with what its command prints or how it exits.
+const (
+	RemotePod       = "pod"       // kubectl: the ready flags of the pod's containers
+	RemoteEndpoints = "endpoints" // kubectl: the ready addresses behind a Service
+	RemoteReplicas  = "replicas"  // kubectl: a workload's ready replicas
+	RemoteSSH       = "ssh"       // ssh: nc -z through the bastion, exit 0 when it answers
+)
+
+// RemoteProbeFor returns the probe of the far end of a kubectl port-forward
+// or ssh -L command: whether the pod, the Service's endpoints or the
+// workload's replicas are ready, through the Kubernetes API with the
+// command's kubeconfig, context and namespace; or whether the forwarded
+// host:port accepts a connection, with nc on the ssh destination, reached
+// with the command's options and no prompts. ok is false for other commands.
+func RemoteProbeFor(command string) (RemoteProbe, bool) {
+	if kubectl, resource, ok := splitPortForward(command); ok {
+		kind, name, _ := strings.Cut(resource, "/")
+		if name == "" {
+			return RemoteProbe{}, false
+		}
+		var get, kindName string
+		switch kind {
+		case "pod":
+			kindName, get = RemotePod, "pod/"+name+` "-o=jsonpath={.status.containerStatuses[*].ready}"`
+		case "svc":
+			kindName, get = RemoteEndpoints, "endpoints/"+name+` "-o=jsonpath={.subsets[*].addresses[*].ip}"`
+		default:
+			kindName, get = RemoteReplicas, resource+` "-o=jsonpath={.status.readyReplicas}"`
+		}
+		return RemoteProbe{Command: strings.Join(append(kubectl, "get", get), " "), Kind: kindName, Target: resource}, true
+	}
+
+	inv := sshInvocations(command)
+	parts := strings.Split(sshForwardSpec(command), ":")
+	if len(inv) == 0 || len(parts) < 3 || len(parts) > 4 {
+		return RemoteProbe{}, false
+	}
+	host, port := parts[len(parts)-2], parts[len(parts)-1]
+	ssh := sshConnectArgs(inv[0].fields)
+	if ssh == nil {
+		return RemoteProbe{}, false
+	}
+	ssh = append(ssh, "nc", "-z", "-w", "5", host, port)
+	return RemoteProbe{Command: strings.Join(ssh, " "), Kind: RemoteSSH, Target: net.JoinHostPort(host, port)}, true
+}
+
+// MergeablePortForward splits a kubectl port-forward into the port specs it
+// forwards and the rest: kubectl, its flags and the resource. Forwards whose
+// rest is the same can run as one kubectl that forwards all their ports; see
+// MergedPortForward. ok is false for other commands, and for a forward
+// without a resource or ports.
+func MergeablePortForward(command string) (rest string, ports []string, ok bool) {
+	fields := fieldRegex.FindAllString(command, -1)
+	verb := slices.Index(fields, "port-forward")
+	if verb < 0 {
+		return "", nil, false
+	}
+	kept := slices.Clone(fields[:verb+1])
+	resource := false
+	for i := verb + 1; i < len(fields); i++ {
+		arg := fields[i]
+		if strings.HasPrefix(arg, "-") {
+			name, _, inline := strings.Cut(arg, "=")
+			kept = append(kept, arg)
+			if !inline && kubectlValueFlags[name] && i+1 < len(fields) {
+				i++
+				kept = append(kept, fields[i])
+			}
+			continue
+		}
+		if !resource && !portRegex.MatchString(arg) {
+			resource = true
+			kept = append(kept, arg)
+			continue
+		}
+		ports = append(ports, arg)
+	}
+	if !resource || len(ports) == 0 {
+		return "", nil, false
+	}
+	return strings.Join(kept, " "), ports, true
+}
+
+// MergedPortForward is the kubectl port-forward that forwards ports for
+// every forward whose MergeablePortForward rest is rest.
+func MergedPortForward(rest string, ports []string) string {
+	return rest + " " + strings.Join(ports, " ")
+}
+
+// PodWatch is how to follow the pod a kubectl port-forward goes to: a
+// kubectl that streams the events of every pod in the forward's namespace as
+// JSON, which forwards into the same namespace can share, and the pod.
+type PodWatch struct {
+	Command string
+	Pod     string
+}
+
+// PodWatchFor returns the pod watch of a kubectl port-forward to a pod. ok is
+// false for other commands, and for forwards to a Service or workload, where
+// kubectl picks a pod that pf does not learn.
+func PodWatchFor(command string) (PodWatch, bool) {
+	kubectl, resource, ok := splitPortForward(command)
+	kind, name, _ := strings.Cut(resource, "/")
+	if !ok || kind != "pod" || name == "" {
+		return PodWatch{}, false
+	}
+	watch := append(kubectl, "get", "pods", "--watch", "--output-watch-events", "-o", "json")
+	return PodWatch{Command: strings.Join(watch, " "), Pod: name}, true
+}
+
+// Retarget rewrites a kubectl port-forward or ssh -L command to forward to a
+// different target, keeping its local port. For kubectl, target is the
+// resource (e.g. "svc/postgres-green") and namespace, if set, replaces or adds
+// -n; for ssh, target is the remote host, optionally with a new port
+// ("db-green:5433"). An empty target keeps the current one.
+func Retarget(command, target, namespace string) (string, error) {
+	type edit struct {
+		start, end int
+		text       string
+	}
+	spans := fieldRegex.FindAllStringIndex(command, -1)
+	fields := make([]string, len(spans))
+	for i, sp := range spans {
+		fields[i] = command[sp[0]:sp[1]]
+	}
+	var edits []edit
+
+	verb := slices.Index(fields, "port-forward")
+	switch {
+	case verb >= 0:
+		resource, nsValue, nsInline := -1, -1, false
+		for i := 1; i < len(fields); i++ {
+			arg := fields[i]
+			if i == verb {
+				continue
+			}
+			if strings.HasPrefix(arg, "-") {
+				name, _, inline := strings.Cut(arg, "=")
+				valueAt := i
+				if !inline && kubectlValueFlags[name] && i+1 < len(fields) {
+					i++
+					valueAt = i
+				}
+				if name == "-n" || name == "--namespace" {
+					nsValue, nsInline = valueAt, inline
+				}
+				continue
+			}
+			if i > verb && resource < 0 && !portRegex.MatchString(arg) {
+				resource = i
+			}
+		}
+		if target != "" {
+			if resource < 0 {
+				return "", fmt.Errorf("no resource to switch in %q", command)
+			}
+			edits = append(edits, edit{spans[resource][0], spans[resource][1], target})
+		}
+		switch {
+		case namespace == "":
+		case nsValue < 0:
+			edits = append(edits, edit{spans[verb][1], spans[verb][1], " -n " + namespace})
+		case nsInline:
+			name, _, _ := strings.Cut(fields[nsValue], "=")
+			edits = append(edits, edit{spans[nsValue][0], spans[nsValue][1], name + "=" + namespace})
+		default:
+			edits = append(edits, edit{spans[nsValue][0], spans[nsValue][1], namespace})
+		}
+
+	case sshForwardSpec(command) != "":
+		if namespace != "" {
+			return "", fmt.Errorf("a namespace only applies to kubectl port-forward")
+		}
+		for i, f := range fields {
+			at, prefix, spec := i, "", ""
+			letters := len(f) > 1 && strings.Trim(f[1:], "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") == ""
+			switch {
+			case strings.HasPrefix(f, "-") && letters && strings.HasSuffix(f, "L") && i+1 < len(fields):
+				at, spec = i+1, fields[i+1]
+			case strings.HasPrefix(f, "-L") && len(f) > 2:
+				prefix, spec = "-L", f[2:]
+			default:
+				continue
+			}
+			parts := strings.Split(spec, ":")
+			if len(parts) != 3 && len(parts) != 4 {
+				return "", fmt.Errorf("cannot read the -L spec %q", spec)
+			}
+			if target != "" {
+				host, port, hasPort := strings.Cut(target, ":")
+				parts[len(parts)-2] = host
+				if hasPort {
+					parts[len(parts)-1] = port
+				}
+			}
+			edits = append(edits, edit{spans[at][0], spans[at][1], prefix + strings.Join(parts, ":")})
+			break
+		}
+
+	default:
+		return "", fmt.Errorf("only kubectl port-forward and ssh -L commands can be switched")
+	}
+
+	// Apply from the end so earlier positions stay valid.
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		command = command[:e.start] + e.text + command[e.end:]
+	}
+	return command, nil
+}
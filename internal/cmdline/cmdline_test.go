@@ -0,0 +1,186 @@
+package cmdline
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		command    string
+		wantLocal  string
+		wantRemote string
+	}{
+		{"kubectl port-forward svc/db 5432:5432", "5432", "5432"},
+		{"kubectl port-forward svc/redis 6379:6379", "6379", "6379"},
+		{"kubectl port-forward svc/web 8080:80", "8080", "80"},
+		{"ssh -N -L 15432:db.internal:5432 bastion", "15432", "5432"},
+		{"ssh -L127.0.0.2:6379:cache:6380 bastion", "6379", "6380"},
+		{"kubectl proxy", "8001", ""},
+		{"kubectl --context prod proxy --port=8011", "8011", ""},
+		{"kubectl proxy -p 8002 --address 0.0.0.0", "8002", ""},
+		{"no ports here", "", ""},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			local, remote := ParsePorts(tt.command)
+			if local != tt.wantLocal {
+				t.Errorf("local = %q, want %q", local, tt.wantLocal)
+			}
+			if remote != tt.wantRemote {
+				t.Errorf("remote = %q, want %q", remote, tt.wantRemote)
+			}
+		})
+	}
+}
+
+func TestParseForward(t *testing.T) {
+	tests := []struct {
+		command string
+		want    Forward
+	}{
+		{"kubectl port-forward svc/db 5432:5432", Forward{Target: "svc/db"}},
+		{"kubectl port-forward -n payments svc/postgres 15432:5432", Forward{Target: "svc/postgres", Namespace: "payments"}},
+		{"kubectl --namespace=payments port-forward service/postgres 15432:5432", Forward{Target: "svc/postgres", Namespace: "payments"}},
+		{"kubectl port-forward api-7d9f 9000:9000 --namespace billing", Forward{Target: "pod/api-7d9f", Namespace: "billing"}},
+		{"kubectl port-forward --address 0.0.0.0 deploy/web 8080:80", Forward{Address: "0.0.0.0", Target: "deploy/web"}},
+		{"kubectl port-forward --address=localhost,10.0.0.5 pod/api 9000:9000", Forward{Address: "localhost", Target: "pod/api"}},
+		{"kubectl -n x port-forward 8080:80 svc/late", Forward{Target: "svc/late", Namespace: "x"}},
+		{"ssh -N -L 5432:db.internal:5432 bastion", Forward{Target: "db.internal", SSH: true}},
+		{"ssh -L127.0.0.2:6379:cache:6379 bastion", Forward{Address: "127.0.0.2", Target: "cache", SSH: true}},
+		{"kubectl proxy --address=0.0.0.0 --port=8011", Forward{Address: "0.0.0.0", APIProxy: true}},
+		{"no forward here", Forward{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			if got := ParseForward(tt.command); got != tt.want {
+				t.Errorf("ParseForward = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetarget(t *testing.T) {
+	tests := []struct {
+		command, target, namespace string
+		want                       string
+	}{
+		{"kubectl port-forward svc/db 5432:5432", "svc/db-green", "", "kubectl port-forward svc/db-green 5432:5432"},
+		{"kubectl port-forward -n blue svc/db 5432:5432", "", "green", "kubectl port-forward -n green svc/db 5432:5432"},
+		{"kubectl --namespace=blue port-forward svc/db 5432:5432", "svc/db2", "green", "kubectl --namespace=green port-forward svc/db2 5432:5432"},
+		{"kubectl port-forward svc/db 5432:5432 --address 0.0.0.0", "", "green", "kubectl port-forward -n green svc/db 5432:5432 --address 0.0.0.0"},
+		{`kubectl --kubeconfig "/my path/cfg" port-forward svc/db 5432:5432`, "svc/x", "", `kubectl --kubeconfig "/my path/cfg" port-forward svc/x 5432:5432`},
+		{"ssh -N -L 15432:db-blue:5432 bastion", "db-green", "", "ssh -N -L 15432:db-green:5432 bastion"},
+		{"ssh -L127.0.0.2:15432:db-blue:5432 bastion", "db-green:5433", "", "ssh -L127.0.0.2:15432:db-green:5433 bastion"},
+		{"ssh -fNL 15432:db-blue:5432 bastion", "10.0.0.5", "", "ssh -fNL 15432:10.0.0.5:5432 bastion"},
+	}
+	for _, tt := range tests {
+		got, err := Retarget(tt.command, tt.target, tt.namespace)
+		if err != nil || got != tt.want {
+			t.Errorf("Retarget(%q, %q, %q) = %q, %v; want %q", tt.command, tt.target, tt.namespace, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range [][3]string{
+		{"ssh -N -L 15432:db:5432 bastion", "db2", "ns"},
+		{"socat TCP-LISTEN:5432 TCP:db:5432", "db2", ""},
+	} {
+		if _, err := Retarget(bad[0], bad[1], bad[2]); err == nil {
+			t.Errorf("Retarget(%q, %q, %q) should fail", bad[0], bad[1], bad[2])
+		}
+	}
+}
+
+func TestRolloutStatusCommand(t *testing.T) {
+	for command, want := range map[string]string{
+		"kubectl port-forward svc/api 8080:80":                                                "kubectl rollout status deploy/api --watch=false",
+		"kubectl --context prod port-forward -n web deployment/api 8080:80 --address 0.0.0.0": "kubectl --context prod -n web rollout status deploy/api --watch=false",
+		`kubectl --kubeconfig "/my path/cfg" port-forward statefulset/db 5432`:                `kubectl --kubeconfig "/my path/cfg" rollout status sts/db --watch=false`,
+		"kubectl port-forward pod/api-7d9f 8080:80":                                           "",
+		"ssh -N -L 5432:db:5432 bastion":                                                      "",
+	} {
+		got, ok := RolloutStatusCommand(command)
+		if got != want || ok != (want != "") {
+			t.Errorf("RolloutStatusCommand(%q) = %q, %v; want %q", command, got, ok, want)
+		}
+	}
+}
+
+func TestRemoteProbeFor(t *testing.T) {
+	for command, want := range map[string]RemoteProbe{
+		"kubectl port-forward api-7d9f 8080:80": {
+			Command: `kubectl get pod/api-7d9f "-o=jsonpath={.status.containerStatuses[*].ready}"`, Kind: RemotePod, Target: "pod/api-7d9f"},
+		"kubectl --context prod port-forward -n web service/api 8080:80 --address 0.0.0.0": {
+			Command: `kubectl --context prod -n web get endpoints/api "-o=jsonpath={.subsets[*].addresses[*].ip}"`, Kind: RemoteEndpoints, Target: "svc/api"},
+		"kubectl port-forward sts/db 5432": {
+			Command: `kubectl get sts/db "-o=jsonpath={.status.readyReplicas}"`, Kind: RemoteReplicas, Target: "sts/db"},
+		"ssh -fNL 5432:db.internal:5432 -p 2222 ops@bastion": {
+			Command: "ssh -o BatchMode=yes -p 2222 ops@bastion nc -z -w 5 db.internal 5432", Kind: RemoteSSH, Target: "db.internal:5432"},
+		"ssh -N -o ServerAliveInterval=15 -L127.0.0.1:6379:cache:6379 -J jump bastion": {
+			Command: "ssh -o BatchMode=yes -o ServerAliveInterval=15 -J jump bastion nc -z -w 5 cache 6379", Kind: RemoteSSH, Target: "cache:6379"},
+		"kubectl proxy --port=8001":         {},
+		"socat TCP-LISTEN:5432 TCP:db:5432": {},
+	} {
+		got, ok := RemoteProbeFor(command)
+		if got != want || ok != (want.Command != "") {
+			t.Errorf("RemoteProbeFor(%q) = %+v, %v; want %+v", command, got, ok, want)
+		}
+	}
+}
+
+func TestMergeablePortForward(t *testing.T) {
+	type split struct {
+		rest  string
+		ports []string
+	}
+	for command, want := range map[string]split{
+		"kubectl --context prod port-forward -n db svc/postgres 5432:5432 --address 127.0.0.1": {
+			"kubectl --context prod port-forward -n db svc/postgres --address 127.0.0.1", []string{"5432:5432"}},
+		"kubectl port-forward sts/db 5432 15432:5433 --pod-running-timeout 1m": {
+			"kubectl port-forward sts/db --pod-running-timeout 1m", []string{"5432", "15432:5433"}},
+		"kubectl port-forward svc/api":            {},
+		"kubectl proxy --port=8001":               {},
+		"ssh -N -L 5432:db.internal:5432 bastion": {},
+	} {
+		rest, ports, ok := MergeablePortForward(command)
+		if rest != want.rest || !slices.Equal(ports, want.ports) || ok != (want.rest != "") {
+			t.Errorf("MergeablePortForward(%q) = %q, %q, %v; want %q, %q", command, rest, ports, ok, want.rest, want.ports)
+		}
+	}
+	if got := MergedPortForward("kubectl port-forward -n db svc/postgres", []string{"5432", "5433:5432"}); got != "kubectl port-forward -n db svc/postgres 5432 5433:5432" {
+		t.Errorf("MergedPortForward = %q", got)
+	}
+}
+
+func TestPodWatchFor(t *testing.T) {
+	for command, want := range map[string]PodWatch{
+		"kubectl --context prod port-forward -n db pods/postgres-0 5432": {
+			Command: "kubectl --context prod -n db get pods --watch --output-watch-events -o json", Pod: "postgres-0"},
+		"kubectl port-forward redis-0 6379:6379 --address 0.0.0.0": {
+			Command: "kubectl get pods --watch --output-watch-events -o json", Pod: "redis-0"},
+		"kubectl port-forward svc/api 8080:80":    {},
+		"kubectl port-forward deploy/api 8080":    {},
+		"ssh -N -L 5432:db.internal:5432 bastion": {},
+	} {
+		got, ok := PodWatchFor(command)
+		if got != want || ok != (want.Pod != "") {
+			t.Errorf("PodWatchFor(%q) = %+v, %v; want %+v", command, got, ok, want)
+		}
+	}
+}
+
+func TestAddPortForwardFlags(t *testing.T) {
+	flags := []string{"--pod-running-timeout=20s"}
+	for command, want := range map[string]string{
+		"kubectl -n db port-forward svc/db 5432:5432":                    "kubectl -n db port-forward --pod-running-timeout=20s svc/db 5432:5432",
+		"kubectl port-forward --pod-running-timeout 5m svc/db 5432:5432": "kubectl port-forward --pod-running-timeout 5m svc/db 5432:5432",
+		"ssh -N -L 5432:db:5432 port-forward":                            "ssh -N -L 5432:db:5432 port-forward",
+	} {
+		if got := AddPortForwardFlags(command, flags); got != want {
+			t.Errorf("AddPortForwardFlags(%q) = %q, want %q", command, got, want)
+		}
+	}
+}
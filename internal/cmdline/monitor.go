@@ -0,0 +1,122 @@
+package cmdline
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/probe"
+)
+
+// Monitor is a service that forwards nothing but keeps checking a remote
+// endpoint's health. It is stored as a command of its own form:
+//
+//	monitor --via <service> [--http <path>] [--every <duration>]
+//	monitor --kube [<namespace>/]<service> [--every <duration>]
+//	monitor --probe <plugin> [--via <service>] [--param <key>=<value>]... [--every <duration>]
+//
+// --via checks through another service's forward in the same session: the
+// connection must stay open, or with --http a GET must answer below 400.
+// --kube asks the Kubernetes API whether a Service has ready endpoints.
+// --probe runs a probe plugin (see package probe) instead, handing it the
+// --via forward's address, if any, and the --param settings.
+type Monitor struct {
+	Via       string // service whose forward the check goes through
+	HTTPPath  string // GET this path through Via instead of holding a TCP connection
+	Kube      string // Kubernetes Service whose endpoints are checked
+	Namespace string // Kube's namespace; "" = kubectl's current one
+	Probe     string // probe plugin that checks instead; "" = pf's own check
+	Params    map[string]string
+	Every     time.Duration
+}
+
+// DefaultMonitorInterval is how often a monitor checks without --every.
+const DefaultMonitorInterval = 15 * time.Second
+
+// IsMonitor reports whether command is a monitor rather than a forward.
+func IsMonitor(command string) bool {
+	fields := strings.Fields(command)
+	return len(fields) > 0 && fields[0] == "monitor"
+}
+
+// ParseMonitor reads a monitor command; see Monitor.
+func ParseMonitor(command string) (Monitor, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 || fields[0] != "monitor" {
+		return Monitor{}, fmt.Errorf("not a monitor: %q", command)
+	}
+	m := Monitor{Every: DefaultMonitorInterval}
+	for i := 1; i < len(fields); i++ {
+		name, value, inline := strings.Cut(fields[i], "=")
+		if !inline {
+			if i+1 == len(fields) {
+				return Monitor{}, fmt.Errorf("monitor: %s needs a value", name)
+			}
+			i++
+			value = fields[i]
+		}
+		switch name {
+		case "--via":
+			m.Via = value
+		case "--http":
+			if !strings.HasPrefix(value, "/") {
+				return Monitor{}, fmt.Errorf("monitor: --http takes a path such as /healthz, not %q", value)
+			}
+			m.HTTPPath = value
+		case "--kube":
+			if ns, svc, ok := strings.Cut(value, "/"); ok {
+				m.Namespace, m.Kube = ns, svc
+			} else {
+				m.Kube = value
+			}
+		case "--every":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < time.Second {
+				return Monitor{}, fmt.Errorf("monitor: --every must be a duration of at least 1s, not %q", value)
+			}
+			m.Every = d
+		case "--probe":
+			if err := probe.ValidName(value); err != nil {
+				return Monitor{}, fmt.Errorf("monitor: %v", err)
+			}
+			m.Probe = value
+		case "--param":
+			key, v, ok := strings.Cut(value, "=")
+			if !ok || key == "" {
+				return Monitor{}, fmt.Errorf("monitor: --param takes key=value, not %q", value)
+			}
+			if m.Params == nil {
+				m.Params = map[string]string{}
+			}
+			m.Params[key] = v
+		default:
+			return Monitor{}, fmt.Errorf("monitor: unknown flag %q", name)
+		}
+	}
+	switch {
+	case m.Kube != "" && (m.Via != "" || m.Probe != ""):
+		return Monitor{}, fmt.Errorf("monitor: --kube checks on its own; give no --via or --probe with it")
+	case m.Via == "" && m.Kube == "" && m.Probe == "":
+		return Monitor{}, fmt.Errorf("monitor: give --via <service>, --kube <namespace>/<service> or --probe <plugin>")
+	case m.HTTPPath != "" && (m.Via == "" || m.Probe != ""):
+		return Monitor{}, fmt.Errorf("monitor: --http needs --via, and no --probe")
+	case m.Params != nil && m.Probe == "":
+		return Monitor{}, fmt.Errorf("monitor: --param is for --probe plugins")
+	}
+	return m, nil
+}
+
+// Describe is a short label of what the monitor checks, e.g. "db /healthz",
+// "kube data/postgres" or "db probe pg-lag".
+func (m Monitor) Describe() string {
+	if m.Kube != "" {
+		if m.Namespace != "" {
+			return "kube " + m.Namespace + "/" + m.Kube
+		}
+		return "kube " + m.Kube
+	}
+	if m.Probe != "" {
+		return strings.TrimSpace(m.Via + " probe " + m.Probe)
+	}
+	return strings.TrimSpace(m.Via + " " + m.HTTPPath)
+}
@@ -0,0 +1,49 @@
+package cmdline
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseMonitor(t *testing.T) {
+	tests := []struct {
+		command string
+		want    Monitor
+	}{
+		{"monitor --via db", Monitor{Via: "db", Every: DefaultMonitorInterval}},
+		{"monitor --via api --http /healthz --every=1m", Monitor{Via: "api", HTTPPath: "/healthz", Every: time.Minute}},
+		{"monitor --kube data/postgres", Monitor{Kube: "postgres", Namespace: "data", Every: DefaultMonitorInterval}},
+		{"monitor --kube postgres --every 30s", Monitor{Kube: "postgres", Every: 30 * time.Second}},
+		{"monitor --probe pg-lag --via db --param max=10s --param=db=orders", Monitor{Via: "db", Probe: "pg-lag", Params: map[string]string{"max": "10s", "db": "orders"}, Every: DefaultMonitorInterval}},
+		{"monitor --probe vpn", Monitor{Probe: "vpn", Every: DefaultMonitorInterval}},
+	}
+	for _, tt := range tests {
+		got, err := ParseMonitor(tt.command)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseMonitor(%q) = %+v, %v; want %+v", tt.command, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{
+		"monitor",
+		"monitor --via db --kube data/postgres",
+		"monitor --kube data/postgres --http /healthz",
+		"monitor --via db --http healthz",
+		"monitor --via db --every 10ms",
+		"monitor --via",
+		"monitor --via db --tcp",
+		"monitor --kube data/postgres --probe pg-lag",
+		"monitor --probe pg-lag --via db --http /healthz",
+		"monitor --via db --param max=10s",
+		"monitor --probe ../bin/sh",
+		"monitor --probe pg-lag --param max",
+	} {
+		if _, err := ParseMonitor(bad); err == nil {
+			t.Errorf("ParseMonitor(%q) should fail", bad)
+		}
+	}
+	if IsMonitor("kubectl port-forward svc/monitor 1:1") {
+		t.Error("a forward is not a monitor")
+	}
+}
@@ -0,0 +1,247 @@
+package cmdline
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// sshValueFlags are ssh options that take a separate value.
+const sshValueFlags = "BbcDEeFIiJLlmOoPpQRSWw"
+
+// SSHHost returns the destination of an ssh invocation, without its user;
+// fields[0] is the ssh program.
+func SSHHost(fields []string) string {
+	i := sshDestinationIndex(fields)
+	if i < 0 {
+		return ""
+	}
+	_, host, found := strings.Cut(fields[i], "@")
+	if !found {
+		host = fields[i]
+	}
+	return host
+}
+
+// sshDestinationIndex returns the index of an ssh invocation's destination
+// in fields, or -1 when it has none.
+func sshDestinationIndex(fields []string) int {
+	for i := 1; i < len(fields); i++ {
+		f := fields[i]
+		if strings.HasPrefix(f, "-") && len(f) > 1 {
+			// In a run of flags such as -fNL, only the last can take a value,
+			// and it follows as the next field unless attached (-p2222).
+			letters := strings.Trim(f[1:], "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") == ""
+			if letters && strings.Contains(sshValueFlags, f[len(f)-1:]) {
+				i++
+			}
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+// sshInvocation is one run of the ssh program within a command: its fields,
+// program first, and where the program field ends in the command.
+type sshInvocation struct {
+	fields []string
+	end    int
+}
+
+// shellSeparators end one command of a shell line and start the next.
+var shellSeparators = map[string]bool{"&&": true, "||": true, ";": true, "|": true, "&": true}
+
+// sshInvocations finds where command runs ssh itself: a field naming the ssh
+// program (by path or not) in command position, i.e. first, after a shell
+// separator, or after leading VAR=value assignments or exec. Other fields
+// that merely contain "ssh", such as autossh or a path through an ssh
+// directory, are left alone.
+func sshInvocations(command string) []sshInvocation {
+	var out []sshInvocation
+	var current *sshInvocation
+	atCommand := true
+	for _, loc := range fieldRegex.FindAllStringIndex(command, -1) {
+		f := command[loc[0]:loc[1]]
+		separated := strings.HasSuffix(f, ";")
+		f = strings.TrimSuffix(f, ";")
+		switch {
+		case shellSeparators[f] || f == "":
+			current, atCommand = nil, true
+			continue
+		case atCommand && (f == "exec" || isEnvAssignment(f)):
+		case atCommand:
+			atCommand = false
+			if tool := strings.TrimSuffix(filepath.Base(strings.Trim(f, `"'`)), ".exe"); tool == "ssh" {
+				out = append(out, sshInvocation{fields: []string{f}, end: loc[1]})
+				current = &out[len(out)-1]
+			}
+		case current != nil:
+			current.fields = append(current.fields, f)
+		}
+		if separated {
+			current, atCommand = nil, true
+		}
+	}
+	return out
+}
+
+func isEnvAssignment(f string) bool {
+	name, _, ok := strings.Cut(f, "=")
+	if !ok || name == "" {
+		return false
+	}
+	for i, r := range name {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// sshOptionsSet returns the -o options an ssh invocation sets, by lower-cased
+// name.
+func sshOptionsSet(fields []string) map[string]bool {
+	set := map[string]bool{}
+	for i := 1; i < len(fields); i++ {
+		var value string
+		switch f := fields[i]; {
+		case f == "-o" && i+1 < len(fields):
+			i++
+			value = fields[i]
+		case strings.HasPrefix(f, "-o") && len(f) > 2:
+			value = f[2:]
+		default:
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimLeft(value, `"'`), "=")
+		name, _, _ = strings.Cut(name, " ")
+		set[strings.ToLower(name)] = true
+	}
+	return set
+}
+
+// SSHDestination returns the destination host of the first ssh invocation in
+// command, or "" when it runs none.
+func SSHDestination(command string) string {
+	if inv := sshInvocations(command); len(inv) > 0 {
+		return SSHHost(inv[0].fields)
+	}
+	return ""
+}
+
+// SSHArgs returns the arguments of the first ssh invocation in command, from
+// after the program through the destination and without shell quotes, so
+// another ssh run (such as ssh -G) sees the same port, config and user; nil
+// when it runs none.
+func SSHArgs(command string) []string {
+	inv := sshInvocations(command)
+	if len(inv) == 0 {
+		return nil
+	}
+	i := sshDestinationIndex(inv[0].fields)
+	if i < 0 {
+		return nil
+	}
+	args := make([]string, 0, i)
+	for _, f := range inv[0].fields[1 : i+1] {
+		args = append(args, strings.Trim(f, `"'`))
+	}
+	return args
+}
+
+// AddSSHOptions gives each ssh invocation in command (see sshInvocations) the
+// -o options, "Name=value", right after the program, leaving out those it
+// sets itself: options on the command line win over pf's.
+func AddSSHOptions(command string, options []string) string {
+	if len(options) == 0 {
+		return command
+	}
+	var b strings.Builder
+	last := 0
+	for _, inv := range sshInvocations(command) {
+		set := sshOptionsSet(inv.fields)
+		b.WriteString(command[last:inv.end])
+		for _, opt := range options {
+			if name, _, _ := strings.Cut(opt, "="); !set[strings.ToLower(name)] {
+				b.WriteString(" -o " + opt)
+			}
+		}
+		last = inv.end
+	}
+	b.WriteString(command[last:])
+	return b.String()
+}
+
+// sshForwardSpec returns the argument of the command's first ssh -L flag, or
+// "" when it has none. The flag may end a run of flags, as in -fNL.
+func sshForwardSpec(command string) string {
+	fields := strings.Fields(command)
+	for i, f := range fields {
+		letters := len(f) > 1 && strings.Trim(f[1:], "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") == ""
+		switch {
+		case strings.HasPrefix(f, "-") && letters && strings.HasSuffix(f, "L") && i+1 < len(fields):
+			return fields[i+1]
+		case strings.HasPrefix(f, "-L") && len(f) > 2:
+			return f[2:]
+		}
+	}
+	return ""
+}
+
+// parseSSHForward reads an ssh -L spec: [bind_address:]port:host:hostport.
+func parseSSHForward(spec string) Forward {
+	parts := strings.Split(spec, ":")
+	switch len(parts) {
+	case 3:
+		return Forward{Target: parts[1], SSH: true}
+	case 4:
+		return Forward{Address: parts[0], Target: parts[2], SSH: true}
+	}
+	return Forward{}
+}
+
+// sshConnectArgs returns an ssh invocation's fields through its destination
+// with the forwarding flags (-L, -R, -D, -N, -f, -g) left out, and BatchMode
+// on so it fails instead of prompting: the ssh that runs a command on the
+// same host. nil when it has no destination.
+func sshConnectArgs(fields []string) []string {
+	dest := sshDestinationIndex(fields)
+	if dest < 0 {
+		return nil
+	}
+	out := []string{fields[0], "-o", "BatchMode=yes"}
+	for i := 1; i <= dest; i++ {
+		f := fields[i]
+		letters := strings.HasPrefix(f, "-") && len(f) > 1 && strings.Trim(f[1:], "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") == ""
+		switch {
+		case i == dest:
+			out = append(out, f)
+			continue
+		case !letters && len(f) > 2 && strings.Contains("LRD", f[1:2]):
+			continue // -L5432:db:5432
+		case !letters:
+			out = append(out, f) // a flag with its value attached, -p2222
+			continue
+		}
+		last := f[len(f)-1:]
+		takesValue := strings.Contains(sshValueFlags, last) && i+1 < dest
+		kept := strings.Map(func(r rune) rune {
+			if strings.ContainsRune("LRDNfg", r) {
+				return -1
+			}
+			return r
+		}, f[1:])
+		if takesValue && strings.Contains("LRD", last) {
+			i++ // drop the forward spec with its flag
+			takesValue = false
+		}
+		if kept != "" {
+			out = append(out, "-"+kept)
+		}
+		if takesValue {
+			i++
+			out = append(out, fields[i])
+		}
+	}
+	return out
+}
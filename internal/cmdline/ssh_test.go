@@ -0,0 +1,60 @@
+package cmdline
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSSHHost(t *testing.T) {
+	for command, want := range map[string]string{
+		"ssh -N -L 5432:db:5432 bastion":              "bastion",
+		"ssh -fNL 5432:db:5432 me@bastion.corp":       "bastion.corp",
+		"ssh -L5432:db:5432 -p2222 -oBatchMode=yes b": "b",
+		"ssh -J jump -L 1:x:1 target":                 "target",
+	} {
+		if got := SSHHost(strings.Fields(command)); got != want {
+			t.Errorf("SSHHost(%q) = %q, want %q", command, got, want)
+		}
+	}
+}
+
+func TestAddSSHOptions(t *testing.T) {
+	opts := []string{"ServerAliveInterval=15", "ServerAliveCountMax=3"}
+	const added = " -o ServerAliveInterval=15 -o ServerAliveCountMax=3"
+	for command, want := range map[string]string{
+		"ssh -N -L 5432:db:5432 bastion":          "ssh" + added + " -N -L 5432:db:5432 bastion",
+		"/usr/bin/ssh -N -L 5432:db:5432 bastion": "/usr/bin/ssh" + added + " -N -L 5432:db:5432 bastion",
+		"SSH_AUTH_SOCK=/tmp/a ssh -L 1:x:1 b":     "SSH_AUTH_SOCK=/tmp/a ssh" + added + " -L 1:x:1 b",
+		"aws sso login --check && ssh -L 1:x:1 b": "aws sso login --check && ssh" + added + " -L 1:x:1 b",
+		"knock b 7000; exec ssh -L 1:x:1 b":       "knock b 7000; exec ssh" + added + " -L 1:x:1 b",
+		// Not ssh itself: left alone.
+		"autossh -M 0 -N -L 5432:db:5432 bastion": "autossh -M 0 -N -L 5432:db:5432 bastion",
+		"/home/sshuser/bin/tunnel -L 1:x:1 ssh":   "/home/sshuser/bin/tunnel -L 1:x:1 ssh",
+		"kubectl port-forward svc/ssh 2222:22":    "kubectl port-forward svc/ssh 2222:22",
+		// Its own options win.
+		"ssh -o ServerAliveInterval=60 -L 1:x:1 b":                   "ssh -o ServerAliveCountMax=3 -o ServerAliveInterval=60 -L 1:x:1 b",
+		"ssh -oserveralivecountmax=10 -L 1:x:1 b":                    "ssh -o ServerAliveInterval=15 -oserveralivecountmax=10 -L 1:x:1 b",
+		"ssh -o ServerAliveInterval=60 -o 'ServerAliveCountMax 5' b": "ssh -o ServerAliveInterval=60 -o 'ServerAliveCountMax 5' b",
+	} {
+		if got := AddSSHOptions(command, opts); got != want {
+			t.Errorf("AddSSHOptions(%q)\n = %q\nwant %q", command, got, want)
+		}
+	}
+	if got := SSHDestination("knock b 7000 && ssh -p 2222 me@bastion -L 1:x:1"); got != "bastion" {
+		t.Errorf("SSHDestination = %q", got)
+	}
+}
+
+func TestSSHArgs(t *testing.T) {
+	for command, want := range map[string][]string{
+		"ssh -N -L 5432:db:5432 -p 2222 me@bastion":       {"-N", "-L", "5432:db:5432", "-p", "2222", "me@bastion"},
+		"knock b && ssh -F ~/.ssh/work -J jump target ls": {"-F", "~/.ssh/work", "-J", "jump", "target"},
+		"ssh -o 'ProxyJump=jump' b -L 1:x:1":              {"-o", "ProxyJump=jump", "b"},
+		"kubectl port-forward svc/db 5432:5432":           nil,
+	} {
+		if got := SSHArgs(command); !slices.Equal(got, want) {
+			t.Errorf("SSHArgs(%q) = %q, want %q", command, got, want)
+		}
+	}
+}
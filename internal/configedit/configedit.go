@@ -3,12 +3,18 @@ package configedit
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"os/exec"
 	"runtime"
 	"strings"
 
+	"github.com/alinemone/go-port-forward/internal/catalog"
+	"github.com/alinemone/go-port-forward/internal/cmdline"
 	"github.com/alinemone/go-port-forward/internal/manager"
+	"github.com/alinemone/go-port-forward/internal/notify"
+	"github.com/alinemone/go-port-forward/internal/relay"
 	"github.com/alinemone/go-port-forward/internal/storage"
 )
 
@@ -63,6 +69,21 @@ func Validate(data []byte) (*storage.StorageData, error) {
 		if err := manager.ValidateCommand(command); err != nil {
 			return nil, fmt.Errorf("service %q: %v", name, err)
 		}
+		if !cmdline.IsMonitor(command) {
+			continue
+		}
+		if mon, _ := cmdline.ParseMonitor(command); mon.Via != "" {
+			via, ok := sd.Services[mon.Via]
+			if !ok || cmdline.IsMonitor(via) {
+				return nil, fmt.Errorf("service %q: monitor --via %q is not a forward", name, mon.Via)
+			}
+		}
+		_, hasAlternates := sd.Alternates[name]
+		_, hasFallback := sd.Fallback[name]
+		_, hasRelay := sd.Relay[name]
+		if hasAlternates || hasFallback || hasRelay {
+			return nil, fmt.Errorf("service %q is a monitor: it cannot have alternates, a fallback or a relay", name)
+		}
 	}
 
 	for groupName, members := range sd.Groups {
@@ -79,5 +100,287 @@ func Validate(data []byte) (*storage.StorageData, error) {
 		}
 	}
 
+	for name, alternates := range sd.Alternates {
+		command, ok := sd.Services[name]
+		if !ok {
+			return nil, fmt.Errorf("alternates for unknown service %q", name)
+		}
+		for _, alt := range alternates {
+			if err := manager.ValidateCommand(alt); err != nil {
+				return nil, fmt.Errorf("service %q alternate: %v", name, err)
+			}
+		}
+		if err := storage.CheckSamePort(command, alternates...); err != nil {
+			return nil, fmt.Errorf("service %q: %v", name, err)
+		}
+	}
+
+	for name, fb := range sd.Fallback {
+		command, ok := sd.Services[name]
+		if !ok {
+			return nil, fmt.Errorf("fallback for unknown service %q", name)
+		}
+		if err := manager.ValidateCommand(fb.Command); err != nil {
+			return nil, fmt.Errorf("service %q fallback: %v", name, err)
+		}
+		if fb.After < 0 {
+			return nil, fmt.Errorf("service %q fallback: after must not be negative", name)
+		}
+		if err := storage.CheckSamePort(command, fb.Command); err != nil {
+			return nil, fmt.Errorf("service %q fallback: %v", name, err)
+		}
+	}
+
+	for name, r := range sd.Relay {
+		command, ok := sd.Services[name]
+		if !ok {
+			return nil, fmt.Errorf("relay for unknown service %q", name)
+		}
+		_, port, err := net.SplitHostPort(relay.ListenAddress(r.Listen))
+		if err != nil || port == "" {
+			return nil, fmt.Errorf("service %q relay: invalid listen %q", name, r.Listen)
+		}
+		if local, _ := cmdline.ParsePorts(command); port == local {
+			return nil, fmt.Errorf("service %q relay: listen port %s is the forward's own port", name, port)
+		}
+		hold, err := r.HoldDuration()
+		if err != nil {
+			return nil, fmt.Errorf("service %q: %v", name, err)
+		}
+		if r.DNS && r.HTTP {
+			return nil, fmt.Errorf("service %q relay: dns and http cannot be combined", name)
+		}
+		if r.HoldConnections && (r.HTTP || hold == 0) {
+			return nil, fmt.Errorf("service %q relay: holdConnections needs a TCP relay with a hold", name)
+		}
+		if rw := r.Rewrite; rw != nil {
+			if !r.HTTP {
+				return nil, fmt.Errorf("service %q relay: rewrite needs \"http\": true", name)
+			}
+			if rw.Auth != nil && (rw.Auth.Service == "" || rw.Auth.Account == "") {
+				return nil, fmt.Errorf("service %q relay: auth needs a keyring service and account", name)
+			}
+		}
+	}
+
+	for name, c := range sd.Chaos {
+		if _, ok := sd.Relay[name]; !ok {
+			return nil, fmt.Errorf("chaos for service %q, which has no relay", name)
+		}
+		if _, _, err := c.Durations(); err != nil {
+			return nil, fmt.Errorf("service %q: %v", name, err)
+		}
+	}
+
+	for name, conds := range sd.WaitFor {
+		if _, ok := sd.Services[name]; !ok {
+			return nil, fmt.Errorf("waitFor for unknown service %q", name)
+		}
+		for _, c := range conds {
+			if err := c.Validate(); err != nil {
+				return nil, fmt.Errorf("service %q: %v", name, err)
+			}
+			if c.Command != "" {
+				if err := manager.ValidateCommand(c.Command); err != nil {
+					return nil, fmt.Errorf("service %q waitFor: %v", name, err)
+				}
+			}
+		}
+	}
+
+	for name, p := range sd.PreConnect {
+		if _, ok := sd.Services[name]; !ok {
+			return nil, fmt.Errorf("preConnect for unknown service %q", name)
+		}
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("service %q: %v", name, err)
+		}
+		if err := manager.ValidateCommand(p.Command); err != nil {
+			return nil, fmt.Errorf("service %q preConnect: %v", name, err)
+		}
+	}
+
+	for name, p := range sd.PostConnect {
+		if _, ok := sd.Services[name]; !ok {
+			return nil, fmt.Errorf("postConnect for unknown service %q", name)
+		}
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("service %q: %v", name, err)
+		}
+		if err := manager.ValidateCommand(p.Command); err != nil {
+			return nil, fmt.Errorf("service %q postConnect: %v", name, err)
+		}
+	}
+
+	for name, r := range sd.RemoteCheck {
+		if _, ok := sd.Services[name]; !ok {
+			return nil, fmt.Errorf("remoteCheck for unknown service %q", name)
+		}
+		if err := r.Validate(); err != nil {
+			return nil, fmt.Errorf("service %q: %v", name, err)
+		}
+	}
+
+	for name, d := range sd.DNS {
+		if _, ok := sd.Services[name]; !ok {
+			return nil, fmt.Errorf("dns for unknown service %q", name)
+		}
+		if err := d.Validate(); err != nil {
+			return nil, fmt.Errorf("service %q: %v", name, err)
+		}
+	}
+
+	for name, k := range sd.Keepalive {
+		if _, ok := sd.Services[name]; !ok {
+			return nil, fmt.Errorf("keepalive for unknown service %q", name)
+		}
+		if err := k.Validate(); err != nil {
+			return nil, fmt.Errorf("service %q: %v", name, err)
+		}
+	}
+
+	for name, o := range sd.OTP {
+		if _, ok := sd.Services[name]; !ok {
+			return nil, fmt.Errorf("otp for unknown service %q", name)
+		}
+		if err := o.Validate(); err != nil {
+			return nil, fmt.Errorf("service %q: %v", name, err)
+		}
+	}
+
+	for name, h := range sd.HostKeyChecking {
+		if _, ok := sd.Services[name]; !ok {
+			return nil, fmt.Errorf("hostKeyChecking for unknown service %q", name)
+		}
+		if err := h.Validate(); err != nil {
+			return nil, fmt.Errorf("service %q: %v", name, err)
+		}
+	}
+
+	for name, d := range sd.Deprecated {
+		if _, ok := sd.Services[name]; !ok {
+			return nil, fmt.Errorf("deprecated: unknown service %q", name)
+		}
+		if err := d.Validate(); err != nil {
+			return nil, fmt.Errorf("service %q: %v", name, err)
+		}
+		if _, _, remote := storage.SplitCatalogName(d.Replacement); d.Replacement != "" && !remote {
+			if _, ok := sd.Services[d.Replacement]; !ok || d.Replacement == name {
+				return nil, fmt.Errorf("service %q: replacement %q is not another saved service", name, d.Replacement)
+			}
+		}
+	}
+
+	for name, r := range sd.Schedule {
+		if _, ok := sd.Services[name]; !ok {
+			return nil, fmt.Errorf("schedule for unknown service %q", name)
+		}
+		if err := r.Validate(); err != nil {
+			return nil, fmt.Errorf("service %q: %v", name, err)
+		}
+	}
+
+	for name, l := range sd.Limits {
+		if _, ok := sd.Services[name]; !ok {
+			return nil, fmt.Errorf("limits for unknown service %q", name)
+		}
+		if err := l.Validate(); err != nil {
+			return nil, fmt.Errorf("service %q: %v", name, err)
+		}
+	}
+
+	for name := range sd.Enabled {
+		if _, _, remote := storage.SplitCatalogName(name); remote {
+			continue
+		}
+		if _, ok := sd.Services[name]; !ok {
+			return nil, fmt.Errorf("enabled: unknown service %q", name)
+		}
+	}
+
+	for name, e := range sd.Ephemeral {
+		if _, ok := sd.Services[name]; !ok {
+			return nil, fmt.Errorf("ephemeral: unknown service %q", name)
+		}
+		for key, value := range e.Params {
+			if err := storage.ValidateParam(key, value); err != nil {
+				return nil, fmt.Errorf("ephemeral service %q: %v", name, err)
+			}
+		}
+	}
+
+	for name, labels := range sd.Labels {
+		if _, ok := sd.Services[name]; !ok {
+			return nil, fmt.Errorf("labels for unknown service %q", name)
+		}
+		for key, value := range labels {
+			if err := storage.ValidateLabel(key, value); err != nil {
+				return nil, fmt.Errorf("service %q: %v", name, err)
+			}
+		}
+	}
+
+	if sd.Shutdown != nil {
+		if err := sd.Shutdown.Validate(); err != nil {
+			return nil, err
+		}
+		for _, name := range sd.Shutdown.Order {
+			if _, ok := sd.Services[name]; !ok {
+				return nil, fmt.Errorf("shutdown order: unknown service %q", name)
+			}
+		}
+		for name := range sd.Shutdown.Timeouts {
+			if _, ok := sd.Services[name]; !ok {
+				return nil, fmt.Errorf("shutdown timeouts: unknown service %q", name)
+			}
+		}
+	}
+
+	for name, variants := range sd.Variants {
+		command, ok := sd.Services[name]
+		if !ok {
+			return nil, fmt.Errorf("variants for unknown service %q", name)
+		}
+		for _, v := range variants {
+			if err := v.Validate(); err != nil {
+				return nil, fmt.Errorf("service %q: %v", name, err)
+			}
+			if err := manager.ValidateCommand(v.Command); err != nil {
+				return nil, fmt.Errorf("service %q variant: %v", name, err)
+			}
+			if err := storage.CheckSamePort(command, v.Command); err != nil {
+				return nil, fmt.Errorf("service %q variant: %v", name, err)
+			}
+		}
+	}
+
+	seenCatalogs := map[string]bool{}
+	for _, c := range sd.Catalogs {
+		if err := manager.ValidateServiceName(c.Name); err != nil {
+			return nil, fmt.Errorf("catalog %q: %v", c.Name, err)
+		}
+		if seenCatalogs[c.Name] {
+			return nil, fmt.Errorf("catalog %q is listed twice", c.Name)
+		}
+		seenCatalogs[c.Name] = true
+		if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("catalog %q: url must be http(s)://…", c.Name)
+		}
+		if c.PublicKey != "" {
+			if _, err := catalog.ParsePublicKey(c.PublicKey); err != nil {
+				return nil, fmt.Errorf("catalog %q: publicKey: %v", c.Name, err)
+			}
+		}
+		for _, program := range c.Allow {
+			if program == "" || strings.ContainsAny(program, " \t/\\") {
+				return nil, fmt.Errorf("catalog %q: allow takes program names such as kubectl, not %q", c.Name, program)
+			}
+		}
+	}
+
+	if _, err := notify.Build(sd.Notify); err != nil {
+		return nil, err
+	}
+
 	return &sd, nil
 }
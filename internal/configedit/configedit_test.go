@@ -51,6 +51,33 @@ func TestValidateRejects(t *testing.T) {
 		"unknown group ref":   `{"services": {}, "groups": {"g": ["ghost"]}}`,
 		"group/service clash": `{"services": {"db": "kubectl port-forward svc/db 5432:5432"}, "groups": {"db": ["db"]}}`,
 		"invalid icon type":   `{"icon": {"enable": "yes"}, "services": {}}`,
+		"bad notifier":        `{"notify": [{"type": "webhook"}]}`,
+		"alternate port":      `{"services": {"db": "kubectl port-forward svc/db 5432:5432"}, "alternates": {"db": ["ssh -N -L 6432:db:5432 bastion"]}}`,
+		"fallback port":       `{"services": {"db": "kubectl port-forward svc/db 5432:5432"}, "fallback": {"db": {"command": "ssh -N -L 6432:db:5432 bastion"}}}`,
+		"alternate orphan":    `{"services": {}, "alternates": {"db": ["ssh -N -L 5432:db:5432 bastion"]}}`,
+		"relay same port":     `{"services": {"api": "kubectl port-forward svc/api 8080:80"}, "relay": {"api": {"listen": "8080", "http": true}}}`,
+		"relay orphan":        `{"services": {}, "relay": {"api": {"listen": "8081"}}}`,
+		"rewrite, no http":    `{"services": {"api": "kubectl port-forward svc/api 8080:80"}, "relay": {"api": {"listen": "8081", "rewrite": {"stripCookies": true}}}}`,
+		"relay hold":          `{"services": {"db": "kubectl port-forward svc/db 5432:5432"}, "relay": {"db": {"listen": "15432", "hold": "a while"}}}`,
+		"held, no hold":       `{"services": {"db": "kubectl port-forward svc/db 5432:5432"}, "relay": {"db": {"listen": "15432", "holdConnections": true}}}`,
+		"chaos, no relay":     `{"services": {"db": "kubectl port-forward svc/db 5432:5432"}, "chaos": {"db": {"latency": "200ms"}}}`,
+		"monitor, bad via":    `{"services": {"db-up": "monitor --via db"}}`,
+		"monitor relay":       `{"services": {"db-up": "monitor --kube data/db"}, "relay": {"db-up": {"listen": "8081"}}}`,
+		"waitFor, two kinds":  `{"services": {"db": "kubectl port-forward svc/db 5432:5432"}, "waitFor": {"db": [{"tcp": "vpn:443", "file": "/tmp/up"}]}}`,
+		"waitFor orphan":      `{"services": {}, "waitFor": {"db": [{"file": "/tmp/up"}]}}`,
+		"catalog, no url":     `{"services": {}, "catalogs": [{"name": "corp"}]}`,
+		"deprecated, no repl": `{"services": {"db": "kubectl port-forward svc/db 5432:5432"}, "deprecated": {"db": {"replacement": "pg"}}}`,
+		"deprecated, bad day": `{"services": {"db": "kubectl port-forward svc/db 5432:5432"}, "deprecated": {"db": {"sunset": "soon"}}}`,
+		"catalog, bad name":   `{"services": {}, "catalogs": [{"name": "corp/x", "url": "https://pf.corp/catalog.json"}]}`,
+		"limits, bad memory":  `{"services": {"db": "kubectl port-forward svc/db 5432:5432"}, "limits": {"db": {"memory": "lots"}}}`,
+		"catalog, bad allow":  `{"services": {}, "catalogs": [{"name": "corp", "url": "https://pf.corp/catalog.json", "allow": ["/usr/bin/kubectl"]}]}`,
+		"limits orphan":       `{"services": {}, "limits": {"db": {"nice": 10}}}`,
+		"variant port":        `{"services": {"db": "ssh -N -L 5432:db:5432 bastion"}, "variants": {"db": [{"os": "windows", "command": "ssh.exe -N -L 6432:db:5432 bastion"}]}}`,
+		"variant, no os/host": `{"services": {"db": "ssh -N -L 5432:db:5432 bastion"}, "variants": {"db": [{"command": "ssh.exe -N -L 5432:db:5432 bastion"}]}}`,
+		"enabled orphan":      `{"services": {}, "enabled": {"db": false}}`,
+		"variants orphan":     `{"services": {}, "variants": {"db": [{"os": "windows", "command": "ssh.exe -N -L 5432:db:5432 bastion"}]}}`,
+		"catalog, bad key":    `{"services": {}, "catalogs": [{"name": "corp", "url": "https://pf.corp/catalog.json", "publicKey": "RWQ"}]}`,
+		"auth, no account":    `{"services": {"api": "kubectl port-forward svc/api 8080:80"}, "relay": {"api": {"listen": "8081", "http": true, "rewrite": {"auth": {"service": "pf"}}}}}`,
 	}
 
 	for name, payload := range cases {
@@ -0,0 +1,387 @@
+// Package demo plays a scripted pf session: fake services that connect, fail,
+// reconnect and log on a timeline, with no processes or network behind them.
+// A Session stands in for the service manager behind the TUI and the plain
+// front end, for screenshots, work on the UI, and end-to-end tests of it.
+package demo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// tick is how often Run moves the clock on.
+const tick = 100 * time.Millisecond
+
+// maxLogEntries and maxTransitions match what the service manager keeps.
+const (
+	maxLogEntries  = 120
+	maxTransitions = 20
+)
+
+// Step is one moment of a script: After its start, a service moves to Status
+// (with Error as its last error) and logs Log. Empty fields change nothing.
+type Step struct {
+	After  time.Duration
+	Status string
+	Error  string
+	Cause  string // counts a reconnect for this cause, e.g. model.CauseNetworkError
+	// Reconnect logs the manager's marker for a reconnect attempt due in
+	// this long.
+	Reconnect time.Duration
+	Log       string
+	IsError   bool
+	Kind      model.LogKind
+}
+
+// Script is what one fake service does: Steps once from its start, then
+// Repeat over and over. Repeat's offsets count from the end of Steps, and a
+// round lasts until its last step.
+type Script struct {
+	Name    string
+	Command string
+	Steps   []Step
+	Repeat  []Step
+}
+
+// Scenario is the services a Session can run and the groups they form.
+type Scenario struct {
+	Scripts []Script
+	Groups  map[string][]string
+}
+
+// Session runs the scripts of a Scenario against its own clock, which Run
+// moves on in real time and tests move with Advance.
+type Session struct {
+	mu       sync.Mutex
+	now      time.Time
+	scripts  map[string]Script
+	names    []string
+	groups   map[string][]string
+	services map[string]*service
+	updates  chan struct{}
+}
+
+type service struct {
+	script Script
+	start  time.Time
+	next   int // steps played since start, Steps first then Repeat rounds
+	since  time.Time
+	state  model.Service
+}
+
+// New returns a session for sc, with nothing running yet.
+func New(sc Scenario) *Session {
+	s := &Session{
+		now:      time.Now(),
+		scripts:  make(map[string]Script, len(sc.Scripts)),
+		groups:   make(map[string][]string),
+		services: make(map[string]*service),
+		updates:  make(chan struct{}, 1),
+	}
+	for _, script := range sc.Scripts {
+		s.scripts[script.Name] = script
+		s.names = append(s.names, script.Name)
+	}
+	for name, members := range sc.Groups {
+		s.groups[name] = slices.Clone(members)
+	}
+	return s
+}
+
+// Names returns the scenario's services in the order it lists them.
+func (s *Session) Names() []string {
+	return slices.Clone(s.names)
+}
+
+// Run moves the clock on in real time until ctx is done.
+func (s *Session) Run(ctx context.Context) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.Advance(now.Sub(last))
+			last = now
+		}
+	}
+}
+
+// Advance moves the clock on by d and plays every step that has come due.
+func (s *Session) Advance(d time.Duration) {
+	s.mu.Lock()
+	s.now = s.now.Add(d)
+	changed := false
+	for _, svc := range s.services {
+		changed = svc.play(s.now) || changed
+	}
+	s.mu.Unlock()
+	if changed {
+		s.notify()
+	}
+}
+
+// Updates returns a channel that receives a value whenever a service changes;
+// like the manager's, signals are coalesced.
+func (s *Session) Updates() <-chan struct{} {
+	return s.updates
+}
+
+func (s *Session) notify() {
+	select {
+	case s.updates <- struct{}{}:
+	default:
+	}
+}
+
+// StartStoredService starts the script called name from its beginning.
+func (s *Session) StartStoredService(_ context.Context, name string) error {
+	s.mu.Lock()
+	script, ok := s.scripts[name]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("service '%s' %w in storage", name, storage.ErrNotFound)
+	}
+	if _, running := s.services[name]; running {
+		s.mu.Unlock()
+		return fmt.Errorf("service '%s' is already running", name)
+	}
+	local, remote := cmdline.ParsePorts(script.Command)
+	forward := cmdline.ParseForward(script.Command)
+	svc := &service{script: script, state: model.Service{
+		Name:        name,
+		Command:     script.Command,
+		Endpoint:    1,
+		Endpoints:   1,
+		LocalPort:   local,
+		MainPort:    remote,
+		BindAddress: forward.Address,
+		Target:      forward.Target,
+		Namespace:   forward.Namespace,
+		APIProxy:    forward.APIProxy,
+	}}
+	svc.restart(s.now)
+	s.services[name] = svc
+	s.mu.Unlock()
+	s.notify()
+	return nil
+}
+
+// StopService stops name, which drops it from the list as in the manager.
+func (s *Session) StopService(name string) {
+	s.mu.Lock()
+	_, ok := s.services[name]
+	delete(s.services, name)
+	s.mu.Unlock()
+	if ok {
+		s.notify()
+	}
+}
+
+// StopAllServices stops every running service.
+func (s *Session) StopAllServices() {
+	s.mu.Lock()
+	s.services = make(map[string]*service)
+	s.mu.Unlock()
+	s.notify()
+}
+
+// RestartService plays name's script again from its beginning.
+func (s *Session) RestartService(_ context.Context, name string) error {
+	s.mu.Lock()
+	svc, ok := s.services[name]
+	if ok {
+		svc.count(model.CauseRestart)
+		svc.restart(s.now)
+	}
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("service '%s' is not running", name)
+	}
+	s.notify()
+	return nil
+}
+
+// RestartAllServices restarts every running service.
+func (s *Session) RestartAllServices(ctx context.Context) {
+	s.mu.Lock()
+	names := slices.Collect(maps.Keys(s.services))
+	s.mu.Unlock()
+	for _, name := range names {
+		_ = s.RestartService(ctx, name)
+	}
+}
+
+// ListServiceStates returns the running services, sorted by name.
+func (s *Session) ListServiceStates() []model.Service {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states := make([]model.Service, 0, len(s.services))
+	for _, svc := range s.services {
+		state := svc.state
+		state.Reconnects = maps.Clone(state.Reconnects)
+		state.Transitions = slices.Clone(state.Transitions)
+		state.Logs = slices.Clone(state.Logs)
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+// TrackGroup follows the combined status of a group of the session.
+func (s *Session) TrackGroup(name string, members []string) {
+	s.mu.Lock()
+	s.groups[name] = slices.Clone(members)
+	s.mu.Unlock()
+	s.notify()
+}
+
+// AnswerPrompt fails: demo services never ask for input.
+func (s *Session) AnswerPrompt(name, answer string) error {
+	return fmt.Errorf("'%s' is not waiting for input", name)
+}
+
+// SocketStats has nothing to report: demo services hold no connections.
+func (s *Session) SocketStats(name string) ([]model.Socket, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// GroupStates returns the combined status of every group, sorted by name.
+func (s *Session) GroupStates() []model.GroupState {
+	s.mu.Lock()
+	groups := maps.Clone(s.groups)
+	s.mu.Unlock()
+	if len(groups) == 0 {
+		return nil
+	}
+	services := s.ListServiceStates()
+	states := make([]model.GroupState, 0, len(groups))
+	for name, members := range groups {
+		states = append(states, model.NewGroupState(name, members, services))
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+// restart puts the service back at the start of its script, connecting, and
+// plays the steps due at once.
+func (svc *service) restart(now time.Time) {
+	svc.start, svc.next = now, 0
+	svc.state.StartTime = now
+	svc.state.RestartCount = 0
+	svc.setStatus(model.StatusConnecting, now)
+	svc.state.LastError = ""
+	svc.play(now)
+}
+
+// play applies the steps due by now and reports whether any were.
+func (svc *service) play(now time.Time) bool {
+	played := false
+	for {
+		step, at, ok := svc.upcoming()
+		if !ok || at.After(now) {
+			return played
+		}
+		svc.apply(step, at)
+		svc.next++
+		played = true
+	}
+}
+
+// upcoming returns the next step to play and when it is due; false when the
+// script has run out.
+func (svc *service) upcoming() (Step, time.Time, bool) {
+	steps, repeat := svc.script.Steps, svc.script.Repeat
+	if svc.next < len(steps) {
+		step := steps[svc.next]
+		return step, svc.start.Add(step.After), true
+	}
+	if len(repeat) == 0 || repeat[len(repeat)-1].After <= 0 {
+		return Step{}, time.Time{}, false
+	}
+	base := svc.start
+	if len(steps) > 0 {
+		base = base.Add(steps[len(steps)-1].After)
+	}
+	i := svc.next - len(steps)
+	round, step := i/len(repeat), repeat[i%len(repeat)]
+	base = base.Add(time.Duration(round) * repeat[len(repeat)-1].After)
+	return step, base.Add(step.After), true
+}
+
+func (svc *service) apply(step Step, at time.Time) {
+	if step.Cause != "" {
+		svc.state.RestartCount++
+		svc.count(step.Cause)
+	}
+	if step.Status != "" {
+		svc.setStatus(step.Status, at)
+		switch step.Status {
+		case model.StatusError:
+			svc.state.LastError = step.Error
+		case model.StatusHealthy:
+			svc.state.LastError = ""
+		}
+	}
+	if step.Reconnect > 0 {
+		svc.log(model.LogEntry{
+			Time:    at,
+			Message: fmt.Sprintf("━━━━ RECONNECTING (attempt #%d) in %.1fs ━━━━", svc.state.RestartCount+1, step.Reconnect.Seconds()),
+			Kind:    model.LogKindReconnect,
+		})
+	}
+	if step.Log != "" {
+		svc.log(model.LogEntry{Time: at, Message: step.Log, IsError: step.IsError, Kind: step.Kind})
+	}
+}
+
+func (svc *service) count(cause string) {
+	if svc.state.Reconnects == nil {
+		svc.state.Reconnects = make(map[string]int)
+	}
+	svc.state.Reconnects[cause]++
+}
+
+// setStatus moves the service to status, with the transition and log marker
+// the manager writes for it.
+func (svc *service) setStatus(status string, at time.Time) {
+	prev := svc.state.Status
+	if prev == status {
+		return
+	}
+	svc.state.Status = status
+	if prev != "" {
+		t := model.Transition{From: prev, To: status, At: at, Duration: at.Sub(svc.since)}
+		if len(svc.state.Transitions) == maxTransitions {
+			svc.state.Transitions = slices.Delete(svc.state.Transitions, 0, 1)
+		}
+		svc.state.Transitions = append(svc.state.Transitions, t)
+		svc.log(model.LogEntry{
+			Time:    at,
+			Message: fmt.Sprintf("━━━━ %s → %s ━━━━", strings.ToUpper(prev), strings.ToUpper(status)),
+			Kind:    model.LogKindStatus,
+			Status:  status,
+		})
+	}
+	svc.since = at
+}
+
+func (svc *service) log(entry model.LogEntry) {
+	if len(svc.state.Logs) == maxLogEntries {
+		svc.state.Logs = slices.Delete(svc.state.Logs, 0, 1)
+	}
+	svc.state.Logs = append(svc.state.Logs, entry)
+}
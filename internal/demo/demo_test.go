@@ -0,0 +1,160 @@
+package demo
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func testScenario() Scenario {
+	return Scenario{
+		Scripts: []Script{{
+			Name:    "api",
+			Command: "kubectl port-forward -n shop svc/api 8080:80",
+			Steps:   []Step{{After: time.Second, Status: model.StatusHealthy, Log: "Forwarding from 127.0.0.1:8080 -> 80"}},
+			Repeat: []Step{
+				{After: 2 * time.Second, Status: model.StatusError, Error: "lost connection to pod", Log: "error: lost connection to pod", IsError: true},
+				{After: 3 * time.Second, Status: model.StatusConnecting, Cause: model.CauseNetworkError},
+				{After: 4 * time.Second, Status: model.StatusHealthy},
+			},
+		}},
+		Groups: map[string][]string{"shop": {"api"}},
+	}
+}
+
+func state(t *testing.T, s *Session, name string) model.Service {
+	t.Helper()
+	for _, svc := range s.ListServiceStates() {
+		if svc.Name == name {
+			return svc
+		}
+	}
+	t.Fatalf("%s is not running", name)
+	return model.Service{}
+}
+
+func TestSessionPlaysScript(t *testing.T) {
+	s := New(testScenario())
+	if err := s.StartStoredService(context.Background(), "api"); err != nil {
+		t.Fatal(err)
+	}
+	api := state(t, s, "api")
+	if api.Status != model.StatusConnecting || api.LocalPort != "8080" || api.Target != "svc/api" || api.Namespace != "shop" {
+		t.Fatalf("started = %+v", api)
+	}
+	select {
+	case <-s.Updates():
+	default:
+		t.Error("starting should signal an update")
+	}
+
+	s.Advance(time.Second)
+	if api = state(t, s, "api"); api.Status != model.StatusHealthy {
+		t.Fatalf("status after 1s = %s", api.Status)
+	}
+	if groups := s.GroupStates(); len(groups) != 1 || groups[0].Status != model.StatusHealthy {
+		t.Errorf("groups = %+v", groups)
+	}
+
+	s.Advance(2 * time.Second)
+	if api = state(t, s, "api"); api.Status != model.StatusError || api.LastError != "lost connection to pod" {
+		t.Fatalf("after 3s = %s %q", api.Status, api.LastError)
+	}
+
+	// The repeat runs a round every 4s after the first second: by 10s the
+	// second drop is over.
+	s.Advance(7 * time.Second)
+	api = state(t, s, "api")
+	if api.Status != model.StatusHealthy || api.RestartCount != 2 || api.Reconnects[model.CauseNetworkError] != 2 {
+		t.Errorf("after 10s = %s, %d restarts, %v", api.Status, api.RestartCount, api.Reconnects)
+	}
+	if len(api.Transitions) != 7 {
+		t.Errorf("got %d transitions, want 7", len(api.Transitions))
+	}
+	var markers []string
+	for _, entry := range api.Logs {
+		if entry.Kind == model.LogKindStatus {
+			markers = append(markers, entry.Message)
+		}
+	}
+	if len(markers) != 7 || markers[0] != "━━━━ CONNECTING → HEALTHY ━━━━" {
+		t.Errorf("status markers = %q", markers)
+	}
+}
+
+func TestSessionControls(t *testing.T) {
+	s := New(testScenario())
+	ctx := context.Background()
+	if err := s.StartStoredService(ctx, "nope"); err == nil {
+		t.Error("starting an unknown service should fail")
+	}
+	if err := s.StartStoredService(ctx, "api"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StartStoredService(ctx, "api"); err == nil {
+		t.Error("starting a running service should fail")
+	}
+
+	s.Advance(3 * time.Second)
+	if err := s.RestartService(ctx, "api"); err != nil {
+		t.Fatal(err)
+	}
+	api := state(t, s, "api")
+	if api.Status != model.StatusConnecting || api.LastError != "" || api.Reconnects[model.CauseRestart] != 1 {
+		t.Errorf("after restart = %s %q %v", api.Status, api.LastError, api.Reconnects)
+	}
+	s.Advance(time.Second)
+	if api = state(t, s, "api"); api.Status != model.StatusHealthy {
+		t.Errorf("restart should play the script again, status = %s", api.Status)
+	}
+
+	s.StopService("api")
+	if len(s.ListServiceStates()) != 0 {
+		t.Error("a stopped service should leave the list")
+	}
+	if groups := s.GroupStates(); groups[0].Status != model.StatusError || groups[0].Reason != "api is stopped" {
+		t.Errorf("groups = %+v", groups)
+	}
+	if err := s.RestartService(ctx, "api"); err == nil {
+		t.Error("restarting a stopped service should fail")
+	}
+}
+
+func TestBuiltinShowsEveryStatus(t *testing.T) {
+	s := New(Builtin())
+	for _, name := range s.Names() {
+		if err := s.StartStoredService(context.Background(), name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seen := map[string]bool{}
+	kinds := map[model.LogKind]bool{}
+	for range 600 {
+		s.Advance(100 * time.Millisecond)
+		for _, svc := range s.ListServiceStates() {
+			seen[svc.Status] = true
+			for _, entry := range svc.Logs {
+				kinds[entry.Kind] = true
+				if entry.Kind == model.LogKindHint && strings.TrimPrefix(entry.Message, "Hint: ") == "" {
+					t.Errorf("%s logs an empty hint", svc.Name)
+				}
+			}
+		}
+	}
+	for _, status := range []string{model.StatusConnecting, model.StatusHealthy, model.StatusError} {
+		if !seen[status] {
+			t.Errorf("the demo never shows %s", status)
+		}
+	}
+	for _, kind := range []model.LogKind{model.LogKindOutput, model.LogKindStatus, model.LogKindReconnect, model.LogKindHint} {
+		if !kinds[kind] {
+			t.Errorf("the demo never logs kind %d", kind)
+		}
+	}
+	if len(s.GroupStates()) != 1 {
+		t.Errorf("groups = %+v", s.GroupStates())
+	}
+}
@@ -0,0 +1,72 @@
+package demo
+
+import (
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/hints"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// grafanaError is what the demo's broken forward keeps dying of.
+const grafanaError = `Error from server (NotFound): services "grafana" not found`
+
+// Builtin is the scenario pf run --demo plays: a steady database, an API that
+// drops and reconnects now and then, a quiet ssh tunnel, and a dashboard whose
+// Service is gone, so every status and kind of log line shows.
+func Builtin() Scenario {
+	return Scenario{
+		Scripts: []Script{
+			{
+				Name:    "postgres",
+				Command: "kubectl port-forward -n data svc/postgres 5432:5432",
+				Steps: []Step{
+					{After: 800 * time.Millisecond, Status: model.StatusHealthy, Log: "Forwarding from 127.0.0.1:5432 -> 5432"},
+					{After: 900 * time.Millisecond, Log: "Forwarding from [::1]:5432 -> 5432"},
+				},
+				Repeat: []Step{
+					{After: 3 * time.Second, Log: "Handling connection for 5432"},
+					{After: 7 * time.Second, Log: "Handling connection for 5432"},
+					{After: 8 * time.Second, Log: "Handling connection for 5432"},
+				},
+			},
+			{
+				Name:    "api",
+				Command: "kubectl port-forward -n shop deploy/api 8080:80",
+				Steps: []Step{
+					{After: 1500 * time.Millisecond, Status: model.StatusHealthy, Log: "Forwarding from 127.0.0.1:8080 -> 80"},
+				},
+				Repeat: []Step{
+					{After: 4 * time.Second, Log: "Handling connection for 8080"},
+					{After: 9 * time.Second, Log: "Handling connection for 8080"},
+					{After: 18 * time.Second, Log: "E1016 10:42:07.512 portforward.go:413] an error occurred forwarding 8080 -> 80: connection reset by peer", IsError: true},
+					{After: 18100 * time.Millisecond, Status: model.StatusError, Error: "lost connection to pod", Log: "error: lost connection to pod", IsError: true},
+					{After: 18200 * time.Millisecond, Reconnect: 2 * time.Second},
+					{After: 20200 * time.Millisecond, Status: model.StatusConnecting, Cause: model.CauseNetworkError},
+					{After: 21500 * time.Millisecond, Status: model.StatusHealthy, Log: "Forwarding from 127.0.0.1:8080 -> 80"},
+					{After: 30 * time.Second, Log: "Handling connection for 8080"},
+				},
+			},
+			{
+				Name:    "redis",
+				Command: "ssh -N -L 6379:redis.internal:6379 bastion",
+				Steps: []Step{
+					{After: 2 * time.Second, Status: model.StatusHealthy},
+				},
+			},
+			{
+				Name:    "grafana",
+				Command: "kubectl port-forward -n monitoring svc/grafana 3000:80",
+				Steps: []Step{
+					{After: 1200 * time.Millisecond, Status: model.StatusError, Error: `services "grafana" not found`, Log: grafanaError, IsError: true},
+					{After: 1300 * time.Millisecond, Kind: model.LogKindHint, Log: "Hint: " + hints.Builtin().Hint(grafanaError)},
+				},
+				Repeat: []Step{
+					{After: 100 * time.Millisecond, Reconnect: 5 * time.Second},
+					{After: 5100 * time.Millisecond, Status: model.StatusConnecting, Cause: "exit 1"},
+					{After: 6300 * time.Millisecond, Status: model.StatusError, Error: `services "grafana" not found`, Log: grafanaError, IsError: true},
+				},
+			},
+		},
+		Groups: map[string][]string{"shop": {"postgres", "api", "redis"}},
+	}
+}
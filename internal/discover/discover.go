@@ -0,0 +1,220 @@
+// Package discover builds pf services from what a cluster declares about
+// itself, so platform teams can keep the recommended forwards next to the
+// workloads instead of in everyone's services.json.
+package discover
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Annotations read from a Kubernetes Service.
+const (
+	AnnotationLocalPort = "pf.dev/local-port" // required: the local port to forward from
+	AnnotationPort      = "pf.dev/port"       // the Service port, by number or name; default its first
+	AnnotationName      = "pf.dev/name"       // the pf service name; default the Service's name
+)
+
+// Service is one discovered forward.
+type Service struct {
+	Name    string
+	Command string
+}
+
+// serviceList is the part of `kubectl get services -o json` we read.
+type serviceList struct {
+	Items []struct {
+		Metadata struct {
+			Name        string            `json:"name"`
+			Namespace   string            `json:"namespace"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+		Spec struct {
+			Ports []struct {
+				Name string `json:"name"`
+				Port int    `json:"port"`
+			} `json:"ports"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+// FromAnnotations reads the output of `kubectl get services -o json` and
+// returns a forward for every Service annotated with pf.dev/local-port,
+// sorted by name. Services whose annotations don't add up are reported in
+// skipped rather than failing the rest.
+func FromAnnotations(data []byte) (services []Service, skipped []error, err error) {
+	var list serviceList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, nil, fmt.Errorf("invalid kubectl output: %v", err)
+	}
+	for _, item := range list.Items {
+		md := item.Metadata
+		local, ok := md.Annotations[AnnotationLocalPort]
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(local); err != nil || n < 1 || n > 65535 {
+			skipped = append(skipped, fmt.Errorf("%s/%s: %s %q is not a port", md.Namespace, md.Name, AnnotationLocalPort, local))
+			continue
+		}
+		if len(item.Spec.Ports) == 0 {
+			skipped = append(skipped, fmt.Errorf("%s/%s: the Service has no ports", md.Namespace, md.Name))
+			continue
+		}
+
+		remote := item.Spec.Ports[0].Port
+		if want, ok := md.Annotations[AnnotationPort]; ok {
+			remote = 0
+			for _, p := range item.Spec.Ports {
+				if p.Name == want || strconv.Itoa(p.Port) == want {
+					remote = p.Port
+				}
+			}
+			if remote == 0 {
+				skipped = append(skipped, fmt.Errorf("%s/%s: no port %q", md.Namespace, md.Name, want))
+				continue
+			}
+		}
+
+		name := md.Name
+		if n := md.Annotations[AnnotationName]; n != "" {
+			name = n
+		}
+		command := fmt.Sprintf("kubectl port-forward svc/%s %s:%d", md.Name, local, remote)
+		if md.Namespace != "" {
+			command = fmt.Sprintf("kubectl port-forward -n %s svc/%s %s:%d", md.Namespace, md.Name, local, remote)
+		}
+		services = append(services, Service{Name: name, Command: command})
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services, skipped, nil
+}
+
+// FromServices reads the output of `kubectl get services -o json` and returns
+// a forward of the first port of every Service, annotated or not, sorted by
+// name: a starting point for someone with no services yet. The local port is
+// the Service port, moved up to 8000+port when that needs root, and to the
+// next free one when another Service took it. kubeContext, if set, pins the
+// commands to that context.
+func FromServices(data []byte, kubeContext string) ([]Service, error) {
+	var list serviceList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid kubectl output: %v", err)
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Metadata.Name < list.Items[j].Metadata.Name })
+
+	var services []Service
+	used := map[int]bool{}
+	for _, item := range list.Items {
+		md := item.Metadata
+		if len(item.Spec.Ports) == 0 {
+			continue
+		}
+		remote := item.Spec.Ports[0].Port
+		local := remote
+		if local < 1024 {
+			local += 8000
+		}
+		for used[local] {
+			local++
+		}
+		used[local] = true
+
+		command := "kubectl port-forward"
+		if kubeContext != "" {
+			command += " --context " + kubeContext
+		}
+		if md.Namespace != "" {
+			command += " -n " + md.Namespace
+		}
+		command += fmt.Sprintf(" svc/%s %d:%d", md.Name, local, remote)
+		services = append(services, Service{Name: md.Name, Command: command})
+	}
+	return services, nil
+}
+
+// Bulk is a forward of one port of a Service on a loopback address the
+// Service has to itself, as kubefwd forwards a namespace: every Service keeps
+// its own port, and a hosts-file entry for Host at IP makes the in-cluster
+// name work locally.
+type Bulk struct {
+	Service
+	Host string // the Service's name
+	IP   string // the loopback address its forwards listen on
+}
+
+// Loopback hands out loopback addresses from 127.1.0.1 up, one per Service,
+// and keeps handing the same one out for a Service so its forwards and
+// hosts-file entry stay put while a namespace is followed.
+type Loopback struct {
+	next int
+	ips  map[string]string
+}
+
+// IP returns host's loopback address.
+func (l *Loopback) IP(host string) string {
+	if ip, ok := l.ips[host]; ok {
+		return ip
+	}
+	if l.ips == nil {
+		l.ips = map[string]string{}
+	}
+	l.next++
+	if l.next%256 == 255 {
+		l.next += 2 // skip x.255 and x.0
+	}
+	ip := fmt.Sprintf("127.1.%d.%d", l.next/256, l.next%256)
+	l.ips[host] = ip
+	return ip
+}
+
+// AllServices reads the output of `kubectl get services -n ns -o json` and
+// returns a forward of every port of every Service, sorted by name, with
+// each Service on its address from ips. A Service with one port gives a pf
+// service of its name, one with several a service per port named after the
+// port ("api-http"). Ports below 1024 move up to 8000+port unless lowPorts
+// says pf may bind them. kubeContext, if set, pins the commands to that
+// context.
+func AllServices(data []byte, kubeContext string, ips *Loopback, lowPorts bool) ([]Bulk, error) {
+	var list serviceList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid kubectl output: %v", err)
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Metadata.Name < list.Items[j].Metadata.Name })
+
+	var forwards []Bulk
+	for _, item := range list.Items {
+		md := item.Metadata
+		if len(item.Spec.Ports) == 0 {
+			continue
+		}
+		ip := ips.IP(md.Name)
+		for _, p := range item.Spec.Ports {
+			name := md.Name
+			if len(item.Spec.Ports) > 1 {
+				suffix := p.Name
+				if suffix == "" {
+					suffix = strconv.Itoa(p.Port)
+				}
+				name += "-" + suffix
+			}
+			local := p.Port
+			if local < 1024 && !lowPorts {
+				local += 8000
+			}
+
+			command := "kubectl port-forward"
+			if kubeContext != "" {
+				command += " --context " + kubeContext
+			}
+			if md.Namespace != "" {
+				command += " -n " + md.Namespace
+			}
+			command += fmt.Sprintf(" --address %s svc/%s %d:%d", ip, md.Name, local, p.Port)
+			forwards = append(forwards, Bulk{Service: Service{Name: name, Command: command}, Host: md.Name, IP: ip})
+		}
+	}
+	return forwards, nil
+}
@@ -0,0 +1,159 @@
+package discover
+
+import (
+	"strings"
+	"testing"
+)
+
+const kubectlOutput = `{"items": [
+  {"metadata": {"name": "postgres", "namespace": "data", "annotations": {"pf.dev/local-port": "5432"}},
+   "spec": {"ports": [{"name": "pg", "port": 5432}]}},
+  {"metadata": {"name": "api", "namespace": "data", "annotations": {"pf.dev/local-port": "8080", "pf.dev/port": "http", "pf.dev/name": "data-api"}},
+   "spec": {"ports": [{"name": "grpc", "port": 9090}, {"name": "http", "port": 80}]}},
+  {"metadata": {"name": "plain", "namespace": "data"},
+   "spec": {"ports": [{"port": 80}]}},
+  {"metadata": {"name": "broken", "namespace": "data", "annotations": {"pf.dev/local-port": "http"}},
+   "spec": {"ports": [{"port": 80}]}},
+  {"metadata": {"name": "wrong-port", "namespace": "data", "annotations": {"pf.dev/local-port": "9000", "pf.dev/port": "metrics"}},
+   "spec": {"ports": [{"port": 80}]}}
+]}`
+
+func TestFromAnnotations(t *testing.T) {
+	services, skipped, err := FromAnnotations([]byte(kubectlOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Service{
+		{Name: "data-api", Command: "kubectl port-forward -n data svc/api 8080:80"},
+		{Name: "postgres", Command: "kubectl port-forward -n data svc/postgres 5432:5432"},
+	}
+	if len(services) != len(want) {
+		t.Fatalf("got %+v, want %+v", services, want)
+	}
+	for i := range want {
+		if services[i] != want[i] {
+			t.Errorf("services[%d] = %+v, want %+v", i, services[i], want[i])
+		}
+	}
+	if len(skipped) != 2 || !strings.Contains(skipped[0].Error(), "data/broken") || !strings.Contains(skipped[1].Error(), `no port "metrics"`) {
+		t.Errorf("skipped = %v", skipped)
+	}
+
+	if _, _, err := FromAnnotations([]byte("error: not json")); err == nil {
+		t.Error("garbage should fail")
+	}
+}
+
+func TestFromServices(t *testing.T) {
+	services, err := FromServices([]byte(kubectlOutput), "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Service{
+		{Name: "api", Command: "kubectl port-forward --context staging -n data svc/api 9090:9090"},
+		{Name: "broken", Command: "kubectl port-forward --context staging -n data svc/broken 8080:80"},
+		{Name: "plain", Command: "kubectl port-forward --context staging -n data svc/plain 8081:80"},
+		{Name: "postgres", Command: "kubectl port-forward --context staging -n data svc/postgres 5432:5432"},
+		{Name: "wrong-port", Command: "kubectl port-forward --context staging -n data svc/wrong-port 8082:80"},
+	}
+	if len(services) != len(want) {
+		t.Fatalf("got %+v, want %+v", services, want)
+	}
+	for i := range want {
+		if services[i] != want[i] {
+			t.Errorf("services[%d] = %+v, want %+v", i, services[i], want[i])
+		}
+	}
+}
+
+func TestFromSSHConfig(t *testing.T) {
+	config := `
+# work
+Host bastion
+    HostName bastion.corp.example
+    User ali
+    IdentityFile ~/.ssh/corp
+    LocalForward 5432 db.internal:5432
+    LocalForward 127.0.0.1:6379 cache.internal:6379
+
+Host metrics
+    HostName 10.0.3.7
+    ProxyJump bastion
+    Port 2222
+    LocalForward=9090 localhost:9090
+
+Host *.corp.example
+    LocalForward 1 nowhere:1
+
+Host plain
+    HostName plain.example
+
+Host legacy
+    LocalForward 8000 localhost:8000
+`
+	got, skipped := FromSSHConfig([]byte(config))
+	want := []Service{
+		{Name: "bastion-5432", Command: "ssh -N -L 5432:db.internal:5432 -i ~/.ssh/corp ali@bastion.corp.example"},
+		{Name: "bastion-6379", Command: "ssh -N -L 127.0.0.1:6379:cache.internal:6379 -i ~/.ssh/corp ali@bastion.corp.example"},
+		{Name: "metrics", Command: "ssh -N -L 9090:localhost:9090 -p 2222 -J bastion 10.0.3.7"},
+	}
+	if len(skipped) != 1 || !strings.HasPrefix(skipped[0].Error(), "legacy: no HostName") {
+		t.Errorf("skipped = %v", skipped)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("services[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestAllServices(t *testing.T) {
+	var ips Loopback
+	forwards, err := AllServices([]byte(kubectlOutput), "", &ips, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Bulk{
+		{Service{"api-grpc", "kubectl port-forward -n data --address 127.1.0.1 svc/api 9090:9090"}, "api", "127.1.0.1"},
+		{Service{"api-http", "kubectl port-forward -n data --address 127.1.0.1 svc/api 8080:80"}, "api", "127.1.0.1"},
+		{Service{"broken", "kubectl port-forward -n data --address 127.1.0.2 svc/broken 8080:80"}, "broken", "127.1.0.2"},
+		{Service{"plain", "kubectl port-forward -n data --address 127.1.0.3 svc/plain 8080:80"}, "plain", "127.1.0.3"},
+		{Service{"postgres", "kubectl port-forward -n data --address 127.1.0.4 svc/postgres 5432:5432"}, "postgres", "127.1.0.4"},
+		{Service{"wrong-port", "kubectl port-forward -n data --address 127.1.0.5 svc/wrong-port 8080:80"}, "wrong-port", "127.1.0.5"},
+	}
+	if len(forwards) != len(want) {
+		t.Fatalf("got %+v, want %+v", forwards, want)
+	}
+	for i := range want {
+		if forwards[i] != want[i] {
+			t.Errorf("forwards[%d] = %+v, want %+v", i, forwards[i], want[i])
+		}
+	}
+
+	// A second listing keeps the addresses handed out and puts new Services
+	// after them.
+	again, err := AllServices([]byte(`{"items": [
+	  {"metadata": {"name": "cache", "namespace": "data"}, "spec": {"ports": [{"port": 6379}]}},
+	  {"metadata": {"name": "postgres", "namespace": "data"}, "spec": {"ports": [{"port": 5432}]}}
+	]}`), "eu", &ips, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 2 || again[0].IP != "127.1.0.6" || again[1].IP != "127.1.0.4" ||
+		again[1].Command != "kubectl port-forward --context eu -n data --address 127.1.0.4 svc/postgres 5432:5432" {
+		t.Errorf("second listing = %+v", again)
+	}
+}
+
+func TestLoopbackSkipsNetworkAndBroadcast(t *testing.T) {
+	l := Loopback{next: 253}
+	if got := l.IP("a"); got != "127.1.0.254" {
+		t.Errorf("IP = %s, want 127.1.0.254", got)
+	}
+	if got := l.IP("b"); got != "127.1.1.1" {
+		t.Errorf("IP = %s, want 127.1.1.1", got)
+	}
+}
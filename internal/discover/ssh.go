@@ -0,0 +1,121 @@
+package discover
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// sshHost is what FromSSHConfig reads of one Host block.
+type sshHost struct {
+	alias    string
+	hostName string
+	user     string
+	port     string
+	jump     string
+	identity string
+	forwards []string // LocalForward values, "[bind:]port host:hostport"
+}
+
+// FromSSHConfig reads an ssh config (~/.ssh/config) and returns a forward for
+// every LocalForward of a Host block naming a single host, in file order.
+// The commands connect to the block's HostName with its User, Port,
+// ProxyJump and IdentityFile rather than to the alias: going through the
+// alias would also open the block's own LocalForwards, binding their ports
+// twice. Blocks without a HostName can only be reached through the alias, so
+// they are reported in skipped. A host with several forwards gets one service
+// per local port, named alias-port.
+func FromSSHConfig(data []byte) (services []Service, skipped []error) {
+	var hosts []*sshHost
+	var current *sshHost
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := sshConfigLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		switch key {
+		case "host":
+			current = nil
+			if !strings.ContainsAny(value, "*?! \t") {
+				current = &sshHost{alias: value}
+				hosts = append(hosts, current)
+			}
+		case "match":
+			current = nil
+		}
+		if current == nil {
+			continue
+		}
+		switch key {
+		case "hostname":
+			current.hostName = value
+		case "user":
+			current.user = value
+		case "port":
+			current.port = value
+		case "proxyjump":
+			current.jump = value
+		case "identityfile":
+			current.identity = value
+		case "localforward":
+			current.forwards = append(current.forwards, value)
+		}
+	}
+
+	for _, h := range hosts {
+		if len(h.forwards) == 0 {
+			continue
+		}
+		if h.hostName == "" {
+			skipped = append(skipped, fmt.Errorf("%s: no HostName, so its forwards only run as ssh -N %s", h.alias, h.alias))
+			continue
+		}
+		target := h.hostName
+		if h.user != "" {
+			target = h.user + "@" + target
+		}
+		var opts string
+		if h.port != "" && h.port != "22" {
+			opts += " -p " + h.port
+		}
+		if h.jump != "" && h.jump != "none" {
+			opts += " -J " + h.jump
+		}
+		if h.identity != "" {
+			opts += " -i " + h.identity
+		}
+		for _, fw := range h.forwards {
+			listen, dest, ok := strings.Cut(strings.Join(strings.Fields(fw), " "), " ")
+			if !ok || !strings.Contains(dest, ":") {
+				continue
+			}
+			name := h.alias
+			if len(h.forwards) > 1 {
+				name += "-" + listen[strings.LastIndex(listen, ":")+1:]
+			}
+			services = append(services, Service{
+				Name:    name,
+				Command: fmt.Sprintf("ssh -N -L %s:%s%s %s", listen, dest, opts, target),
+			})
+		}
+	}
+	return services, skipped
+}
+
+// sshConfigLine splits a config line into its lower-cased keyword and value
+// ("Key value" or "Key=value"); ok is false for blank lines and comments.
+func sshConfigLine(line string) (key, value string, ok bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return "", "", false
+	}
+	key = strings.ToLower(line[:i])
+	value = strings.Trim(strings.TrimLeft(line[i:], " \t="), `"`)
+	return key, strings.TrimSpace(value), value != ""
+}
@@ -0,0 +1,176 @@
+// Package endpoint describes where running forwards can be reached locally,
+// in the forms other programs consume: environment variables for `pf exec`
+// and the env file, and plain fields for templates.
+package endpoint
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alinemone/go-port-forward/internal/events"
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/statedir"
+)
+
+// Endpoint is the local side of one forward.
+type Endpoint struct {
+	Name   string // service name as stored
+	Host   string // host a local client should dial, e.g. "127.0.0.1"
+	Port   string // local port
+	Status string
+	Target string // what the forward reaches, e.g. "svc/postgres:5432"; may be ""
+}
+
+// Addr is Host:Port, bracketing IPv6 hosts.
+func (e Endpoint) Addr() string {
+	return net.JoinHostPort(e.Host, e.Port)
+}
+
+// FromServices lists the endpoints of services, sorted by name. A forward bound
+// to a wildcard address is reached through loopback, so that is the Host.
+// Monitors have no local side and are left out.
+func FromServices(services []model.Service) []Endpoint {
+	out := make([]Endpoint, 0, len(services))
+	for i := range services {
+		svc := &services[i]
+		if svc.Monitor != "" {
+			continue
+		}
+		out = append(out, Endpoint{
+			Name:   svc.Name,
+			Host:   DialHost(svc.BindAddress),
+			Port:   svc.LocalPort,
+			Status: svc.Status,
+			Target: TargetLabel(svc),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// DialHost is the host a local client uses to reach a forward bound to bind.
+func DialHost(bind string) string {
+	switch bind {
+	case "", "0.0.0.0", "localhost":
+		return "127.0.0.1"
+	case "::", "[::]":
+		return "::1"
+	}
+	return strings.Trim(bind, "[]")
+}
+
+// TargetLabel describes the remote side of svc: "svc/postgres:5432" when the
+// command names a target, "kube-api" for kubectl proxy, "monitor db" for a
+// monitor, otherwise just the remote port.
+func TargetLabel(svc *model.Service) string {
+	if svc.Monitor != "" {
+		return "monitor " + svc.Monitor
+	}
+	if svc.APIProxy {
+		return "kube-api"
+	}
+	if svc.Target == "" {
+		return svc.MainPort
+	}
+	if svc.MainPort == "" {
+		return svc.Target
+	}
+	return svc.Target + ":" + svc.MainPort
+}
+
+// EnvPrefix turns a service name into its variable prefix: "PF_" plus the name
+// upper-cased, with anything but letters and digits replaced by "_"
+// ("redis-cache" → "PF_REDIS_CACHE").
+func EnvPrefix(name string) string {
+	var b strings.Builder
+	b.WriteString("PF_")
+	for _, r := range strings.ToUpper(name) {
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// Env returns KEY=value pairs for endpoints: PF_<NAME>_HOST, PF_<NAME>_PORT
+// and PF_<NAME>_ADDR for each one.
+func Env(endpoints []Endpoint) []string {
+	env := make([]string, 0, 3*len(endpoints))
+	for _, e := range endpoints {
+		prefix := EnvPrefix(e.Name)
+		env = append(env,
+			prefix+"_HOST="+e.Host,
+			prefix+"_PORT="+e.Port,
+			prefix+"_ADDR="+e.Addr(),
+		)
+	}
+	return env
+}
+
+// Dotenv renders endpoints as a dotenv file: a comment header, then the Env
+// pairs one per line, grouped by service.
+func Dotenv(endpoints []Endpoint) []byte {
+	var b strings.Builder
+	b.WriteString("# Live pf endpoints. Rewritten while pf runs; removed when it exits.\n")
+	for _, e := range endpoints {
+		b.WriteString("\n")
+		for _, kv := range Env([]Endpoint{e}) {
+			b.WriteString(kv + "\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+// KeepEnvFile writes the dotenv for states() to path and rewrites it whenever
+// the endpoints change, which it learns from changes: the session's events of
+// services starting, stopping or changing status. It does so until the
+// returned stop func is called, which removes the file. The first write
+// happens before KeepEnvFile returns, so a bad path is reported as its error;
+// later write failures are retried on the next change.
+func KeepEnvFile(path string, states func() []model.Service, changes <-chan events.Event) (stop func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	last := Dotenv(FromServices(states()))
+	if err := statedir.WriteFile(path, last, 0600); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			case _, ok := <-changes:
+				if !ok {
+					changes = nil // the bus is gone: wait for stop
+					continue
+				}
+			}
+			content := Dotenv(FromServices(states()))
+			if !bytes.Equal(content, last) && statedir.WriteFile(path, content, 0600) == nil {
+				last = content
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+			os.Remove(path)
+		})
+	}, nil
+}
@@ -0,0 +1,96 @@
+package endpoint
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/events"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestEnvDescribesEachEndpoint(t *testing.T) {
+	eps := FromServices([]model.Service{
+		{Name: "redis-cache", LocalPort: "6379", BindAddress: "0.0.0.0"},
+		{Name: "db", LocalPort: "15432"},
+		{Name: "v6", LocalPort: "8080", BindAddress: "::1"},
+	})
+
+	want := []string{
+		"PF_DB_HOST=127.0.0.1", "PF_DB_PORT=15432", "PF_DB_ADDR=127.0.0.1:15432",
+		"PF_REDIS_CACHE_HOST=127.0.0.1", "PF_REDIS_CACHE_PORT=6379", "PF_REDIS_CACHE_ADDR=127.0.0.1:6379",
+		"PF_V6_HOST=::1", "PF_V6_PORT=8080", "PF_V6_ADDR=[::1]:8080",
+	}
+	if got := Env(eps); !reflect.DeepEqual(got, want) {
+		t.Errorf("Env() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestKeepEnvFileFollowsStatesAndCleansUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "pf.env")
+	var mu sync.Mutex
+	services := []model.Service{{Name: "db", LocalPort: "15432"}}
+	states := func() []model.Service {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]model.Service(nil), services...)
+	}
+
+	var bus events.Bus
+	changes := bus.Subscribe(0)
+	defer changes.Close()
+	stop, err := KeepEnvFile(path, states, changes.C)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForFile(t, path, "PF_DB_PORT=15432")
+
+	mu.Lock()
+	services = append(services, model.Service{Name: "redis", LocalPort: "6379"})
+	mu.Unlock()
+	bus.Publish(events.Event{Kind: events.ServiceStarted, Service: "redis"})
+	waitForFile(t, path, "PF_REDIS_PORT=6379")
+
+	stop()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("env file should be removed on stop")
+	}
+}
+
+func waitForFile(t *testing.T, path, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, err := os.ReadFile(path); err == nil && strings.Contains(string(data), want) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("%s never contained %q", path, want)
+}
+
+func TestRenderTemplate(t *testing.T) {
+	eps := FromServices([]model.Service{
+		{Name: "db", LocalPort: "15432", MainPort: "5432", Target: "svc/postgres", Status: model.StatusHealthy},
+		{Name: "api", LocalPort: "8080", Status: model.StatusConnecting},
+	})
+
+	var b strings.Builder
+	text := `{{range .Services}}{{.Name}} {{.Addr}} {{.Status}}
+{{end}}{{with .ByName.db}}{{.Host}}:{{.Port}}:*:app:secret {{.Target}}{{end}}`
+	if err := RenderTemplate(&b, "pgpass", text, eps); err != nil {
+		t.Fatal(err)
+	}
+	want := "api 127.0.0.1:8080 connecting\ndb 127.0.0.1:15432 healthy\n127.0.0.1:15432:*:app:secret svc/postgres:5432"
+	if b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+
+	if err := RenderTemplate(&b, "missing", `{{.ByName.redis.Port}}`, eps); err == nil {
+		t.Error("a service that is not running should be an error")
+	}
+}
@@ -0,0 +1,35 @@
+package endpoint
+
+import (
+	"io"
+	"strings"
+	"text/template"
+)
+
+// TemplateData is what a `pf env --template` template is executed against.
+type TemplateData struct {
+	Services []Endpoint          // every running forward, sorted by name
+	ByName   map[string]Endpoint // the same, keyed by service name
+}
+
+var templateFuncs = template.FuncMap{
+	"envPrefix": EnvPrefix,
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+}
+
+// RenderTemplate parses text as a Go text/template and executes it against
+// endpoints, writing the result to w. Besides the standard functions,
+// templates can use envPrefix, upper and lower. A key missing from ByName
+// is an error rather than "<no value>", so a stopped service fails loudly.
+func RenderTemplate(w io.Writer, name, text string, endpoints []Endpoint) error {
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]Endpoint, len(endpoints))
+	for _, e := range endpoints {
+		byName[e.Name] = e
+	}
+	return t.Execute(w, TemplateData{Services: endpoints, ByName: byName})
+}
@@ -0,0 +1,120 @@
+// Package events is the session's event bus. The service manager publishes
+// what happens to its services (started, stopped, a status change, a log
+// line) and the config changes it picks up while running; the notifier, the
+// env file and any other follower subscribe to the kinds they care about
+// instead of polling the manager's state.
+package events
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// Kind is what an event is about.
+type Kind string
+
+const (
+	ServiceStarted Kind = "service.started"
+	ServiceStopped Kind = "service.stopped" // Detail: how, when it had to be killed
+	ServiceStatus  Kind = "service.status"  // From → To
+	ServiceLog     Kind = "service.log"     // Log
+	// ServiceStuck is a service whose process was still running after the
+	// session's shutdown killed it; Detail says which. ServiceStopped follows,
+	// as the session gives up on it.
+	ServiceStuck Kind = "service.stuck"
+	// ConfigChanged is a change of the config a running session applied:
+	// a switched command, a maintenance window, chaos, or reloaded settings.
+	// Service is empty for session-wide ones.
+	ConfigChanged Kind = "config.changed"
+	// SessionNotice is something the session reports beyond its services,
+	// such as a Service `pf run --all-services` had to skip; Detail says
+	// what. Frontends show it rather than letting it be printed over them.
+	SessionNotice Kind = "session.notice"
+)
+
+// Event is one thing that happened in the session.
+type Event struct {
+	Kind    Kind
+	Service string
+	Time    time.Time
+	From    string         // ServiceStatus: the status left
+	To      string         // ServiceStatus: the status entered
+	Log     model.LogEntry // ServiceLog: the line logged
+	Detail  string         // ConfigChanged: what changed, as the service's log says it; see the kinds for others
+}
+
+// DefaultBuffer is how many events a subscriber may fall behind by before
+// new ones are dropped for it.
+const DefaultBuffer = 256
+
+// Bus hands every published event to the subscribers that want its kind.
+// Publishing never blocks: a subscriber whose buffer is full misses the event
+// and counts it in Dropped, so a slow follower cannot hold up the services.
+// The zero value is ready to use.
+type Bus struct {
+	mu   sync.Mutex
+	subs []*Subscription
+}
+
+// Subscription receives a bus's events on C until Close.
+type Subscription struct {
+	C       <-chan Event
+	ch      chan Event
+	kinds   []Kind
+	dropped atomic.Int64
+	bus     *Bus
+}
+
+// Subscribe returns a subscription to the given kinds, every kind when none
+// are given, buffered for buffer events (DefaultBuffer when 0).
+func (b *Bus) Subscribe(buffer int, kinds ...Kind) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	ch := make(chan Event, buffer)
+	s := &Subscription{C: ch, ch: ch, kinds: kinds, bus: b}
+	b.mu.Lock()
+	b.subs = append(b.subs, s)
+	b.mu.Unlock()
+	return s
+}
+
+// Publish sends e to every subscriber that wants it, stamping its time when
+// unset.
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range b.subs {
+		if len(s.kinds) > 0 && !slices.Contains(s.kinds, e.Kind) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Close ends the subscription and closes C. It may be called more than once.
+func (s *Subscription) Close() {
+	b := s.bus
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if i := slices.Index(b.subs, s); i >= 0 {
+		b.subs = slices.Delete(b.subs, i, i+1)
+		close(s.ch)
+	}
+}
+
+// Dropped is how many events the subscriber missed with its buffer full.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
@@ -0,0 +1,61 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestSubscribersGetTheKindsTheyAskFor(t *testing.T) {
+	var b Bus
+	all := b.Subscribe(0)
+	status := b.Subscribe(0, ServiceStatus, ServiceStopped)
+
+	b.Publish(Event{Kind: ServiceLog, Service: "db"})
+	b.Publish(Event{Kind: ServiceStatus, Service: "db", From: "connecting", To: "healthy"})
+	b.Publish(Event{Kind: ServiceStopped, Service: "db"})
+
+	if got := drain(all); len(got) != 3 {
+		t.Errorf("the catch-all subscriber got %d events, want 3", len(got))
+	}
+	got := drain(status)
+	if len(got) != 2 || got[0].To != "healthy" || got[1].Kind != ServiceStopped {
+		t.Errorf("the status subscriber got %+v", got)
+	}
+	if got[0].Time.IsZero() {
+		t.Error("Publish should stamp the time")
+	}
+}
+
+func TestSlowSubscriberDropsInsteadOfBlocking(t *testing.T) {
+	var b Bus
+	slow := b.Subscribe(2)
+	for range 5 {
+		b.Publish(Event{Kind: ServiceLog})
+	}
+	if len(drain(slow)) != 2 || slow.Dropped() != 3 {
+		t.Errorf("dropped = %d, want 3 with a buffer of 2", slow.Dropped())
+	}
+}
+
+func TestCloseEndsTheSubscription(t *testing.T) {
+	var b Bus
+	s := b.Subscribe(0)
+	s.Close()
+	s.Close()
+	b.Publish(Event{Kind: ServiceLog})
+	if _, ok := <-s.C; ok {
+		t.Error("a closed subscription received an event")
+	}
+}
+
+// drain returns the events waiting on s.
+func drain(s *Subscription) []Event {
+	var got []Event
+	for {
+		select {
+		case e := <-s.C:
+			got = append(got, e)
+		default:
+			return got
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Package fakeforward stands in for kubectl port-forward in tests: a
+// Forwarder is a command line that behaves like a forward, coming up, failing
+// and dying as its script says, with no kubectl, ssh or cluster behind it.
+// The command is the test binary itself, so a package using Forwarder calls
+// Main first thing in its TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		fakeforward.Main()
+//		os.Exit(m.Run())
+//	}
+//
+// Each start of the command plays the next Run of the script, the last one
+// again once they run out, and is recorded for Starts.
+package fakeforward
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// marker is the first argument that turns the test binary into a forwarder.
+const marker = "pf-fake-forward"
+
+// Run is what one start of the forwarder does. Healthy listens on the local
+// port and prints kubectl's "Forwarding from" line; then Stderr is printed,
+// and after For the process exits with Exit. A zero For runs until killed.
+type Run struct {
+	Healthy bool          `json:"healthy,omitempty"`
+	Stderr  []string      `json:"stderr,omitempty"`
+	For     time.Duration `json:"for,omitempty"`
+	Exit    int           `json:"exit,omitempty"`
+}
+
+// Forwarder is a scripted fake forward between a local and a remote port.
+type Forwarder struct {
+	dir     string
+	command string
+}
+
+// New writes the script of a forwarder from local to remote port. Its files
+// go in a temporary directory the test removes.
+func New(t testing.TB, local, remote int, runs ...Run) *Forwarder {
+	t.Helper()
+	if len(runs) == 0 {
+		runs = []Run{{Healthy: true}}
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("fakeforward: %v", err)
+	}
+	dir := t.TempDir()
+	data, err := json.Marshal(runs)
+	if err != nil {
+		t.Fatalf("fakeforward: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "script.json"), data, 0o600); err != nil {
+		t.Fatalf("fakeforward: %v", err)
+	}
+	return &Forwarder{
+		dir:     dir,
+		command: fmt.Sprintf("%s %s %s %d:%d", quote(exe), marker, quote(dir), local, remote),
+	}
+}
+
+// Command is the forwarder's command line, to save as a service.
+func (f *Forwarder) Command() string {
+	return f.command
+}
+
+// Starts returns when the forwarder was started, oldest first.
+func (f *Forwarder) Starts() []time.Time {
+	data, err := os.ReadFile(filepath.Join(f.dir, "starts"))
+	if err != nil {
+		return nil
+	}
+	var starts []time.Time
+	for _, line := range strings.Fields(string(data)) {
+		if nanos, err := strconv.ParseInt(line, 10, 64); err == nil {
+			starts = append(starts, time.Unix(0, nanos))
+		}
+	}
+	return starts
+}
+
+// FreePort returns a local port nothing listens on at the moment.
+func FreePort(t testing.TB) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("fakeforward: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// NewStorage points the home directory at a temporary one for the rest of the
+// test and saves services (name to command) in its config.
+func NewStorage(t testing.TB, services map[string]string) *storage.Storage {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	st := storage.NewStorage()
+	for name, command := range services {
+		if err := st.AddService(name, command); err != nil {
+			t.Fatalf("fakeforward: %v", err)
+		}
+	}
+	return st
+}
+
+// Main plays a run of the script and exits when the process was started as a
+// forwarder, and returns at once otherwise.
+func Main() {
+	if len(os.Args) != 4 || os.Args[1] != marker {
+		return
+	}
+	if err := play(os.Args[2], os.Args[3]); err != nil {
+		fmt.Fprintf(os.Stderr, "fakeforward: %v\n", err)
+		os.Exit(2)
+	}
+}
+
+func play(dir, ports string) error {
+	data, err := os.ReadFile(filepath.Join(dir, "script.json"))
+	if err != nil {
+		return err
+	}
+	var runs []Run
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return err
+	}
+	// A service runs one process at a time, so the starts file needs no lock.
+	starts, err := os.OpenFile(filepath.Join(dir, "starts"), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	previous, _ := io.ReadAll(starts)
+	fmt.Fprintln(starts, time.Now().UnixNano())
+	starts.Close()
+	run := runs[min(len(strings.Fields(string(previous))), len(runs)-1)]
+
+	local, remote, _ := strings.Cut(ports, ":")
+	if run.Healthy {
+		ln, err := net.Listen("tcp", "127.0.0.1:"+local)
+		if err != nil {
+			return err
+		}
+		defer ln.Close()
+		go serve(ln)
+		fmt.Printf("Forwarding from 127.0.0.1:%s -> %s\n", local, remote)
+	}
+	for _, line := range run.Stderr {
+		fmt.Fprintln(os.Stderr, line)
+	}
+	if run.For == 0 {
+		for {
+			time.Sleep(time.Hour)
+		}
+	}
+	time.Sleep(run.For)
+	os.Exit(run.Exit)
+	return nil
+}
+
+// serve echoes what each connection sends, standing in for the remote end.
+func serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			io.Copy(conn, conn)
+		}()
+	}
+}
+
+// quote wraps a path in double quotes for the shell the manager runs commands
+// in, when it has spaces.
+func quote(path string) string {
+	if strings.ContainsAny(path, " \t") {
+		return `"` + path + `"`
+	}
+	return path
+}
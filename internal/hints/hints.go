@@ -0,0 +1,104 @@
+// Package hints maps the errors kubectl, ssh and the forwards they run print
+// to advice on fixing them, so a failing forward says what to do next and not
+// only what went wrong. pf ships rules for the common ones; users add their
+// own in a rules file.
+package hints
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// Rule gives Hint for an output line matching Match, a regular expression
+// matched regardless of case.
+type Rule struct {
+	Match string `json:"match"`
+	Hint  string `json:"hint"`
+}
+
+// builtin are the rules pf ships, most specific first.
+var builtin = []Rule{
+	{`unable to connect to the server`, "The Kubernetes API is unreachable: check your VPN, and that the kube context points at the right cluster (kubectl config current-context)."},
+	{`you must be logged in|unauthorized`, "Your cluster credentials are missing or expired: log in again (e.g. aws sso login, gcloud auth login) and pf reconnects."},
+	{`x509: certificate`, "The cluster's certificate is not trusted or your client certificate expired: check pf cert list, or renew it."},
+	{`context .*(does not exist|was not found)`, "The kube context is not in your kubeconfig: pf lint suggests the closest one."},
+	{`forbidden`, "Your account may not port-forward here: ask for the pods/portforward permission in this namespace."},
+	{`\(notfound\)|not found`, "The target does not exist: check its name and namespace (-n), or point the service elsewhere with pf switch."},
+	{`address already in use|unable to listen on port`, "Another process holds the local port: pf cleanup frees the ports of saved services, or give this one another local port."},
+	{`permission denied \(publickey`, "ssh rejected your key: load it with ssh-add, or name it in the command with -i ~/.ssh/<key>."},
+	{`host key verification failed`, "ssh does not trust the host's key: check and pin it with pf ssh trust <name>, or fix its line in ~/.ssh/known_hosts."},
+	{`could not resolve hostname|name or service not known`, "The host name does not resolve: check ~/.ssh/config and that your VPN's DNS is up."},
+	{`connection refused`, "Nothing accepts connections on the remote port: check the port, and that the pod or host behind it is up."},
+}
+
+type compiled struct {
+	re   *regexp.Regexp
+	hint string
+}
+
+// Set is an ordered list of rules; the first one matching a line gives its
+// hint.
+type Set struct {
+	rules []compiled
+}
+
+// New compiles rules, followed by pf's own.
+func New(rules []Rule) (*Set, error) {
+	s := &Set{}
+	for _, r := range append(rules, builtin...) {
+		if r.Hint == "" {
+			return nil, fmt.Errorf("rule %q has no hint", r.Match)
+		}
+		re, err := regexp.Compile("(?i)" + r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %v", r.Match, err)
+		}
+		s.rules = append(s.rules, compiled{re: re, hint: r.Hint})
+	}
+	return s, nil
+}
+
+// Builtin returns the set of pf's own rules only.
+func Builtin() *Set {
+	s, err := New(nil)
+	if err != nil {
+		panic(err) // the built-in rules are fixed
+	}
+	return s
+}
+
+// Load reads the user's rules from path, a JSON list of rules tried before
+// pf's own. Without the file only pf's own rules apply.
+func Load(path string) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Builtin(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	s, err := New(rules)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+// Hint returns the hint for line, or "" when no rule matches it.
+func (s *Set) Hint(line string) string {
+	if s == nil {
+		return ""
+	}
+	for _, r := range s.rules {
+		if r.re.MatchString(line) {
+			return r.hint
+		}
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+package hints
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinHints(t *testing.T) {
+	s := Builtin()
+	for line, want := range map[string]string{
+		"Unable to connect to the server: dial tcp 10.0.0.1:443: i/o timeout":                                           "The Kubernetes API is unreachable",
+		"git@bastion: Permission denied (publickey).":                                                                   "ssh rejected your key",
+		"Unable to listen on port 5432: Listeners failed to create: bind: address already in use":                       "Another process holds the local port",
+		`Error from server (NotFound): services "db" not found`:                                                         "The target does not exist",
+		"error: You must be logged in to the server (Unauthorized)":                                                     "Your cluster credentials",
+		`error: context "staging" does not exist`:                                                                       "The kube context",
+		"ssh: Could not resolve hostname bastion.corp: Name or service not known":                                       "The host name does not resolve",
+		`Error from server (Forbidden): pods "db-0" is forbidden: User "ali" cannot create resource "pods/portforward"`: "Your account may not port-forward",
+	} {
+		if got := s.Hint(line); !strings.HasPrefix(got, want) {
+			t.Errorf("Hint(%q) = %q, want %q…", line, got, want)
+		}
+	}
+	if got := s.Hint("Forwarding from 127.0.0.1:5432 -> 5432"); got != "" {
+		t.Errorf("a healthy line got hint %q", got)
+	}
+}
+
+func TestLoadPutsUserRulesFirst(t *testing.T) {
+	dir := t.TempDir()
+	if s, err := Load(filepath.Join(dir, "missing.json")); err != nil || s.Hint("connection refused") == "" {
+		t.Fatalf("without a file the built-in rules apply: %v", err)
+	}
+
+	path := filepath.Join(dir, "hints.json")
+	rules := `[{"match": "connection refused", "hint": "Is the dev database up? make db"},
+	           {"match": "ERR_QUOTA_\\d+", "hint": "Ask #platform for more quota."}]`
+	if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Hint("dial tcp 127.0.0.1:5432: connect: Connection refused"); got != "Is the dev database up? make db" {
+		t.Errorf("user rule should win, got %q", got)
+	}
+	if got := s.Hint("err_quota_17 reached"); got != "Ask #platform for more quota." {
+		t.Errorf("user rule = %q", got)
+	}
+
+	for _, bad := range []string{`[{"match": "(", "hint": "x"}]`, `[{"match": "x"}]`, `{"match": "x"}`} {
+		if err := os.WriteFile(path, []byte(bad), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("Load(%s) should fail", bad)
+		}
+	}
+}
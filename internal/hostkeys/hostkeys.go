@@ -0,0 +1,227 @@
+// Package hostkeys pins ssh host keys for `pf ssh trust`, so a service whose
+// host key checking is strict can connect from a fresh machine or CI runner
+// without an interactive first connect. It asks ssh which host, port and
+// known_hosts file a destination resolves to, fetches the host's keys with
+// ssh-keyscan and writes them where ssh looks them up.
+package hostkeys
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// scanTimeout is ssh-keyscan's timeout, in seconds.
+const scanTimeout = "10"
+
+// Target is where a destination's host keys come from and go to.
+type Target struct {
+	Host string // the address ssh connects to (HostName)
+	Port string
+	// Name is what known_hosts lists the keys under: the HostKeyAlias if
+	// there is one, else the host, as "[host]:port" off port 22.
+	Name string
+	File string // the known_hosts file ssh reads first
+	Hash bool   // whether ssh_config wants names in known_hosts hashed
+}
+
+// Resolve asks ssh -G how it would connect with args (an ssh invocation's
+// arguments through its destination; see cmdline.SSHArgs), so aliases,
+// ports and known_hosts files from ssh_config are honored.
+func Resolve(ctx context.Context, args []string) (Target, error) {
+	out, err := run(ctx, "ssh", append([]string{"-G"}, args...)...)
+	if err != nil {
+		return Target{}, err
+	}
+	home, _ := os.UserHomeDir()
+	return ParseConfig(string(out), home), nil
+}
+
+// ParseConfig reads the target from ssh -G output; home expands "~" in the
+// known_hosts path.
+func ParseConfig(out, home string) Target {
+	t := Target{Port: "22"}
+	alias := ""
+	for _, line := range strings.Split(out, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch key {
+		case "hostname":
+			t.Host = value
+		case "port":
+			t.Port = value
+		case "hostkeyalias":
+			alias = value
+		case "userknownhostsfile":
+			if f, _, _ := strings.Cut(value, " "); f != "" {
+				t.File = f
+			}
+		case "hashknownhosts":
+			t.Hash = value == "yes"
+		}
+	}
+	if t.File == "" {
+		t.File = "~/.ssh/known_hosts"
+	}
+	if rest, ok := strings.CutPrefix(t.File, "~/"); ok {
+		t.File = filepath.Join(home, rest)
+	}
+	switch {
+	case alias != "":
+		t.Name = alias
+	case t.Port != "22":
+		t.Name = "[" + t.Host + "]:" + t.Port
+	default:
+		t.Name = t.Host
+	}
+	return t
+}
+
+// Scan fetches the host's keys with ssh-keyscan, as known_hosts lines under
+// the target's name, sorted.
+func Scan(ctx context.Context, t Target) ([]string, error) {
+	out, err := run(ctx, "ssh-keyscan", "-T", scanTimeout, "-p", t.Port, t.Host)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		lines = append(lines, t.Name+" "+fields[1]+" "+fields[2])
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%s:%s offered no host keys", t.Host, t.Port)
+	}
+	slices.Sort(lines)
+	return slices.Compact(lines), nil
+}
+
+// Pinned returns the known_hosts lines the target's file holds for its name;
+// none when the file does not exist.
+func Pinned(ctx context.Context, t Target) ([]string, error) {
+	if _, err := os.Stat(t.File); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	out, err := run(ctx, "ssh-keygen", "-F", t.Name, "-f", t.File)
+	if err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) && exit.ExitCode() == 1 {
+			return nil, nil // nothing found
+		}
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// Same reports whether pinned holds exactly the keys of scanned, whatever
+// names (hashed or not) they are listed under.
+func Same(scanned, pinned []string) bool {
+	keys := func(lines []string) []string {
+		var out []string
+		for _, line := range lines {
+			if f := strings.Fields(line); len(f) >= 3 {
+				out = append(out, f[1]+" "+f[2])
+			}
+		}
+		slices.Sort(out)
+		return slices.Compact(out)
+	}
+	return slices.Equal(keys(scanned), keys(pinned))
+}
+
+// Pin replaces what the target's file holds for its name with lines,
+// creating the file if need be; ssh-keygen keeps the old file as
+// <file>.old. Names are hashed when ssh_config wants them hashed.
+func Pin(ctx context.Context, t Target, lines []string) error {
+	if err := os.MkdirAll(filepath.Dir(t.File), 0700); err != nil {
+		return err
+	}
+	if _, err := os.Stat(t.File); err == nil {
+		if _, err := run(ctx, "ssh-keygen", "-R", t.Name, "-f", t.File); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(t.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if t.Hash {
+			name, rest, _ := strings.Cut(line, " ")
+			if line, err = hashName(name, rest); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		if _, err := f.WriteString(line + "\n"); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// hashName writes a known_hosts line with its name hashed the way ssh does
+// with HashKnownHosts: "|1|salt|HMAC-SHA1(salt, name)", both base64.
+func hashName(name, rest string) (string, error) {
+	salt := make([]byte, sha1.Size)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(name))
+	enc := base64.StdEncoding
+	return "|1|" + enc.EncodeToString(salt) + "|" + enc.EncodeToString(mac.Sum(nil)) + " " + rest, nil
+}
+
+// Fingerprint returns a known_hosts line's key type and its SHA256
+// fingerprint as ssh shows it, e.g. "SHA256:uNiVz...".
+func Fingerprint(line string) (keyType, fingerprint string, err error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return "", "", fmt.Errorf("not a known_hosts line: %q", line)
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[2])
+	if err != nil {
+		return "", "", fmt.Errorf("bad %s key: %v", fields[1], err)
+	}
+	sum := sha256.Sum256(blob)
+	return fields[1], "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
+}
+
+func run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var notFound *exec.Error
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("%s not found: %v", name, notFound.Err)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %s (%w)", name, msg, err)
+		}
+		return nil, err
+	}
+	return out, nil
+}
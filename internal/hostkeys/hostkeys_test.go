@@ -0,0 +1,82 @@
+package hostkeys
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ed25519Line is a known_hosts line with a real key; its fingerprint is what
+// ssh-keygen -l reports for it.
+const ed25519Line = "bastion ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"
+
+func TestParseConfig(t *testing.T) {
+	out := "user me\nhostname 10.0.0.5\nport 2222\nuserknownhostsfile ~/.ssh/known_hosts ~/.ssh/known_hosts2\nhashknownhosts yes\n"
+	got := ParseConfig(out, "/home/me")
+	want := Target{Host: "10.0.0.5", Port: "2222", Name: "[10.0.0.5]:2222", File: "/home/me/.ssh/known_hosts", Hash: true}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := ParseConfig("hostname db.corp\nport 22\nhostkeyalias db\n", "/home/me"); got.Name != "db" || got.File != "/home/me/.ssh/known_hosts" {
+		t.Errorf("alias: got %+v", got)
+	}
+	if got := ParseConfig("hostname db.corp\nport 22\n", "/home/me"); got.Name != "db.corp" {
+		t.Errorf("port 22: got %+v", got)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	typ, fp, err := Fingerprint(ed25519Line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != "ssh-ed25519" || fp != "SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU" {
+		t.Errorf("got %s %s", typ, fp)
+	}
+	if _, _, err := Fingerprint("bastion ssh-ed25519 !!"); err == nil {
+		t.Error("a bad key should not have a fingerprint")
+	}
+}
+
+func TestSame(t *testing.T) {
+	hashed := "|1|c2FsdA==|aGFzaA== " + strings.SplitN(ed25519Line, " ", 2)[1]
+	if !Same([]string{ed25519Line}, []string{hashed}) {
+		t.Error("the same key under a hashed name should count as pinned")
+	}
+	if Same([]string{ed25519Line}, nil) {
+		t.Error("nothing pinned is not the same")
+	}
+	if Same([]string{ed25519Line, "bastion ssh-rsa AAAA"}, []string{ed25519Line}) {
+		t.Error("a missing key is not the same")
+	}
+}
+
+func TestPinHashes(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+	if err := Pin(context.Background(), Target{Name: "bastion", File: file, Hash: true}, []string{ed25519Line}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, rest, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	if rest != strings.SplitN(ed25519Line, " ", 2)[1] {
+		t.Errorf("key = %q", rest)
+	}
+	parts := strings.Split(name, "|")
+	if len(parts) != 4 || parts[1] != "1" {
+		t.Fatalf("name = %q, want |1|salt|hash", name)
+	}
+	salt, _ := base64.StdEncoding.DecodeString(parts[2])
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte("bastion"))
+	if parts[3] != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+		t.Error("the hash does not match the name")
+	}
+}
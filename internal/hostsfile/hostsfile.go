@@ -0,0 +1,122 @@
+// Package hostsfile keeps pf's entries in the system hosts file, so Services
+// forwarded on loopback addresses answer to their in-cluster names. Each set
+// of entries sits in a block of its own between marker comments, which pf
+// rewrites as the set changes and removes when it is done; the rest of the
+// file is left as it was.
+package hostsfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/alinemone/go-port-forward/internal/statedir"
+)
+
+// Entry maps an address to the names that resolve to it.
+type Entry struct {
+	IP    string
+	Names []string
+}
+
+// Path is the system hosts file.
+func Path() string {
+	if runtime.GOOS == "windows" {
+		root := os.Getenv("SystemRoot")
+		if root == "" {
+			root = `C:\Windows`
+		}
+		return filepath.Join(root, "System32", "drivers", "etc", "hosts")
+	}
+	return "/etc/hosts"
+}
+
+// Names are the names a Service answers to inside the cluster: "api",
+// "api.payments", "api.payments.svc" and "api.payments.svc.cluster.local".
+func Names(service, namespace string) []string {
+	return []string{
+		service,
+		service + "." + namespace,
+		service + "." + namespace + ".svc",
+		service + "." + namespace + ".svc.cluster.local",
+	}
+}
+
+func begin(tag string) string { return "# pf begin " + tag }
+func end(tag string) string   { return "# pf end " + tag }
+
+// Lines are the hosts-file lines for entries, as Set writes them.
+func Lines(entries []Entry) []string {
+	lines := make([]string, 0, len(entries))
+	for _, e := range entries {
+		lines = append(lines, e.IP+"\t"+strings.Join(e.Names, " "))
+	}
+	return lines
+}
+
+// Set replaces the block tagged tag in the hosts file at path with entries,
+// adding it at the end when there is none; no entries removes it. The new
+// file is written beside the old one and renamed over it, so a failed write
+// never leaves the hosts file half written. Where that is impossible, as
+// when /etc/hosts is a mount point (in containers), it is rewritten in place.
+func Set(path, tag string, entries []Entry) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	kept := withoutBlock(strings.Split(strings.TrimRight(text, "\n"), "\n"), tag)
+	if len(entries) > 0 {
+		kept = append(kept, begin(tag))
+		kept = append(kept, Lines(entries)...)
+		kept = append(kept, end(tag))
+	}
+	out := strings.Join(kept, "\n") + "\n"
+	if runtime.GOOS == "windows" {
+		out = strings.ReplaceAll(out, "\n", "\r\n")
+	}
+	if out == string(data) {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if statedir.WriteFile(path, []byte(out), info.Mode().Perm()) == nil {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(out), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("cannot update %s: %w", path, err)
+	}
+	return nil
+}
+
+// withoutBlock returns lines without the block tagged tag. A block is only
+// dropped once its end marker is found: a begin marker without one, as a
+// hand edit may leave, keeps the lines after it.
+func withoutBlock(lines []string, tag string) []string {
+	kept := make([]string, 0, len(lines))
+	var block []string // the open block's lines, begin marker included
+	inside := false
+	for _, line := range lines {
+		switch strings.TrimSpace(line) {
+		case begin(tag):
+			kept = append(kept, block...)
+			block, inside = []string{line}, true
+			continue
+		case end(tag):
+			if inside {
+				block, inside = nil, false
+				continue
+			}
+		}
+		if inside {
+			block = append(block, line)
+		} else {
+			kept = append(kept, line)
+		}
+	}
+	return append(kept, block...)
+}
@@ -0,0 +1,85 @@
+package hostsfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	original := "127.0.0.1\tlocalhost\n::1\tlocalhost\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	read := func() string {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	api := Entry{IP: "127.1.0.1", Names: Names("api", "payments")}
+	if err := Set(path, "payments", []Entry{api}); err != nil {
+		t.Fatal(err)
+	}
+	want := original + "# pf begin payments\n127.1.0.1\tapi api.payments api.payments.svc api.payments.svc.cluster.local\n# pf end payments\n"
+	if got := read(); got != want {
+		t.Errorf("after adding:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Another namespace gets a block of its own, and setting one block again
+	// replaces it without touching the other.
+	if err := Set(path, "billing", []Entry{{IP: "127.1.0.2", Names: []string{"ledger"}}}); err != nil {
+		t.Fatal(err)
+	}
+	db := Entry{IP: "127.1.0.3", Names: []string{"db"}}
+	if err := Set(path, "payments", []Entry{api, db}); err != nil {
+		t.Fatal(err)
+	}
+	want = original +
+		"# pf begin billing\n127.1.0.2\tledger\n# pf end billing\n" +
+		"# pf begin payments\n127.1.0.1\tapi api.payments api.payments.svc api.payments.svc.cluster.local\n127.1.0.3\tdb\n# pf end payments\n"
+	if got := read(); got != want {
+		t.Errorf("after updating:\n%s\nwant:\n%s", got, want)
+	}
+
+	if err := Set(path, "payments", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set(path, "billing", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); got != original {
+		t.Errorf("after removing:\n%s\nwant:\n%s", got, original)
+	}
+}
+
+// TestSetKeepsUnendedBlock keeps the user's lines after a begin marker whose
+// end marker is gone, and still adds the new block.
+func TestSetKeepsUnendedBlock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	original := "127.0.0.1\tlocalhost\n# pf begin payments\n127.1.0.1\tapi\n10.0.0.5\tbuild-server\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set(path, "payments", []Entry{{IP: "127.1.0.2", Names: []string{"db"}}}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := original + "# pf begin payments\n127.1.0.2\tdb\n# pf end payments\n"
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestSetMissingFile(t *testing.T) {
+	if err := Set(filepath.Join(t.TempDir(), "hosts"), "payments", nil); err == nil {
+		t.Error("a missing hosts file should fail")
+	}
+}
@@ -0,0 +1,39 @@
+// Package keyring reads secrets from the OS credential store through its own
+// command-line tool: security on macOS, secret-tool (libsecret) on Linux. That
+// keeps tokens out of services.json without linking a keyring library.
+package keyring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Lookup returns the secret stored under service and account.
+func Lookup(ctx context.Context, service, account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "linux":
+		cmd = exec.CommandContext(ctx, "secret-tool", "lookup", "service", service, "account", account)
+	default:
+		return "", fmt.Errorf("keyring is not supported on %s", runtime.GOOS)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("no keyring secret for service %q account %q", service, account)
+		}
+		return "", fmt.Errorf("keyring: %v", err)
+	}
+	secret := strings.TrimRight(string(out), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("keyring secret for service %q account %q is empty", service, account)
+	}
+	return secret, nil
+}
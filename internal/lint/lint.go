@@ -0,0 +1,451 @@
+// Package lint checks stored service definitions for problems that would
+// otherwise only show up when they run: ports pf cannot read, two services on
+// one local port, kube contexts that do not exist, groups naming missing
+// services and shell syntax that trips up the supervised process, in a
+// service's command and in its per-OS or per-host variants. It also
+// points at deprecated services still in use. Every finding comes with a
+// suggested fix.
+package lint
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alinemone/go-port-forward/forwarder"
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/manager"
+	"github.com/alinemone/go-port-forward/internal/probe"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// Severities.
+const (
+	Error   = "error"   // the service or group cannot work as stored
+	Warning = "warning" // it works, but probably not as meant
+)
+
+// Finding is one problem in the config.
+type Finding struct {
+	Severity string
+	Subject  string // what it is about: "service db", "group backend"
+	Problem  string
+	Fix      string
+}
+
+// Check lints data. contexts are the kube contexts kubectl knows; nil skips
+// the context check, e.g. when kubectl is not installed.
+func Check(data *storage.StorageData, contexts []string) []Finding {
+	var r report
+	names := make([]string, 0, len(data.Services))
+	for name := range data.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ports := map[string][]string{} // local port → services
+	for _, name := range names {
+		command := data.Services[name]
+		subject := "service " + name
+		if err := manager.ValidateCommand(command); err != nil {
+			r.add(Error, subject, err.Error(), "fix the command with `pf edit`")
+			continue
+		}
+		if cmdline.IsMonitor(command) {
+			mon, _ := cmdline.ParseMonitor(command)
+			if mon.Via != "" {
+				if via, ok := data.Services[mon.Via]; !ok || cmdline.IsMonitor(via) {
+					r.add(Error, subject, fmt.Sprintf("monitor --via '%s' is not a saved forward", mon.Via), "point --via at the forward it should check")
+				}
+			}
+			if mon.Probe != "" {
+				if dir, err := probe.Dir(); err == nil {
+					if _, err := probe.Find(dir, mon.Probe); err != nil {
+						r.add(Error, subject, err.Error(), "install the plugin there, or see `pf probes` for the ones installed")
+					}
+				}
+			}
+			continue
+		}
+		if forwarder.IsCommand(command) {
+			checkPlugin(command, subject, &r)
+		}
+
+		if local, ok := checkPorts(command, subject, &r); ok {
+			ports[local] = append(ports[local], name)
+		}
+		if contexts != nil {
+			checkContext(command, subject, contexts, &r)
+		}
+		checkShell(command, subject, &r)
+		checkVariants(data, name, contexts, &r)
+	}
+
+	checkSharedPorts(data, ports, &r)
+	checkDeprecated(data, names, &r)
+	checkRemoteChecks(data, names, &r)
+	checkDNS(data, names, &r)
+
+	for _, group := range sortedGroups(data) {
+		members := data.Groups[group]
+		if len(members) == 0 {
+			r.add(Warning, "group "+group, "has no services", fmt.Sprintf("add some with `pf group add-service %s <names>` or delete it", group))
+		}
+		for _, member := range members {
+			if _, ok := data.Services[member]; ok || isCatalogEntry(data, member) {
+				continue
+			}
+			r.add(Error, "group "+group, fmt.Sprintf("names missing service '%s'", member), fmt.Sprintf("pf group remove-service %s %s", group, member))
+		}
+	}
+	if g := data.DefaultGroup; g != "" && !storage.IsSelector(g) && !isCatalogEntry(data, g) {
+		if _, ok := data.Groups[g]; !ok {
+			r.add(Error, "defaultGroup", fmt.Sprintf("names missing group '%s'", g), "pf group default <group>, or pf group default --unset")
+		}
+	}
+	return r
+}
+
+// CheckCommand is the check `pf add` runs on a new service's command before
+// saving it: it must run a forwarder pf knows (kubectl port-forward or proxy,
+// ssh -L, an installed forwarder plugin) or be a monitor, carry a port
+// mapping pf can read, and start a program that is installed. lookPath is
+// exec.LookPath outside tests.
+func CheckCommand(command string, lookPath func(string) (string, error)) []Finding {
+	var r report
+	const subject = "command"
+	if err := manager.ValidateCommand(command); err != nil {
+		r.add(Error, subject, err.Error(), "fix the command")
+		return r
+	}
+	if cmdline.IsMonitor(command) {
+		return r
+	}
+	if forwarder.IsCommand(command) {
+		checkPlugin(command, subject, &r)
+		checkPorts(command, subject, &r)
+		return r
+	}
+
+	fields := strings.Fields(command)
+	tool := strings.TrimSuffix(filepath.Base(fields[0]), ".exe")
+	switch {
+	case tool == "kubectl" && (slices.Contains(fields, "port-forward") || slices.Contains(fields, "proxy")):
+	case tool == "ssh" && strings.Contains(command, "-L"):
+	default:
+		r.add(Error, subject, fmt.Sprintf("'%s' is not a forward pf knows", strings.Join(fields[:min(2, len(fields))], " ")), "use kubectl port-forward, kubectl proxy, ssh -L, a forwarder plugin or a monitor")
+	}
+	if _, err := lookPath(fields[0]); err != nil {
+		r.add(Error, subject, fmt.Sprintf("'%s' is not installed or not on PATH", fields[0]), "install it, or save anyway with --force if it is only missing here")
+	}
+	checkPorts(command, subject, &r)
+	checkShell(command, subject, &r)
+	return r
+}
+
+// checkPlugin reports a forwarder plugin command (see package forwarder)
+// whose plugin is not installed.
+func checkPlugin(command, subject string, r *report) {
+	c, err := forwarder.ParseCommand(command)
+	if err != nil {
+		return // ValidateCommand reported it
+	}
+	if dir, err := forwarder.Dir(); err == nil {
+		if _, err := forwarder.Find(dir, c.Plugin); err != nil {
+			r.add(Error, subject, err.Error(), "install the plugin there, or see `pf forwarders` for the ones installed")
+		}
+	}
+}
+
+// report collects findings.
+type report []Finding
+
+func (r *report) add(severity, subject, problem, fix string) {
+	*r = append(*r, Finding{Severity: severity, Subject: subject, Problem: problem, Fix: fix})
+}
+
+// checkDeprecated reports deprecated services that are past their sunset, and
+// groups that still hold deprecated services, so they get migrated.
+func checkDeprecated(data *storage.StorageData, names []string, r *report) {
+	now := time.Now()
+	for _, name := range names {
+		d, ok := data.Deprecated[name]
+		if !ok || d.Sunset == "" {
+			continue
+		}
+		if sunset, err := time.ParseInLocation(storage.SunsetLayout, d.Sunset, now.Location()); err == nil && !now.Before(sunset) {
+			r.add(Warning, "service "+name, "is past its sunset on "+d.Sunset, fmt.Sprintf("pf delete %s", name))
+		}
+	}
+	for _, group := range sortedGroups(data) {
+		for _, member := range data.Groups[group] {
+			d, ok := data.Deprecated[member]
+			if !ok {
+				continue
+			}
+			fix := fmt.Sprintf("pf group remove-service %s %s", group, member)
+			if d.Replacement != "" {
+				fix = fmt.Sprintf("pf group remove-service %s %s && pf group add-service %s %s", group, member, group, d.Replacement)
+			}
+			r.add(Warning, "group "+group, fmt.Sprintf("includes deprecated service '%s'", member), fix)
+		}
+	}
+}
+
+// checkRemoteChecks reports a remoteCheck on a service whose far end pf
+// cannot check, which would do nothing.
+func checkRemoteChecks(data *storage.StorageData, names []string, r *report) {
+	for _, name := range names {
+		if _, ok := data.RemoteCheck[name]; !ok {
+			continue
+		}
+		if _, ok := cmdline.RemoteProbeFor(data.Services[name]); !ok {
+			r.add(Warning, "service "+name, "has a remoteCheck, but only kubectl port-forward and ssh -L forwards can be checked", "remove its remoteCheck entry with `pf edit`")
+		}
+	}
+}
+
+// checkDNS reports a dns setting on a service that is not an ssh -L forward,
+// which would do nothing.
+func checkDNS(data *storage.StorageData, names []string, r *report) {
+	for _, name := range names {
+		if _, ok := data.DNS[name]; !ok {
+			continue
+		}
+		if !cmdline.ParseForward(data.Services[name]).SSH {
+			r.add(Warning, "service "+name, "has a dns setting, but only the remote host of an ssh -L forward is resolved by pf", "remove its dns entry with `pf edit`")
+		}
+	}
+}
+
+// checkVariants runs the command checks on each of a service's variants, and
+// reports one that forwards another local port than the service's command.
+func checkVariants(data *storage.StorageData, name string, contexts []string, r *report) {
+	for _, v := range data.Variants[name] {
+		where := "os " + v.OS
+		switch {
+		case v.OS == "":
+			where = "host " + v.Host
+		case v.Host != "":
+			where += ", host " + v.Host
+		}
+		subject := fmt.Sprintf("service %s (variant for %s)", name, where)
+		if err := v.Validate(); err != nil {
+			r.add(Error, subject, err.Error(), "fix the variant with `pf edit`")
+			continue
+		}
+		if err := manager.ValidateCommand(v.Command); err != nil {
+			r.add(Error, subject, err.Error(), "fix the variant with `pf edit`")
+			continue
+		}
+		if err := storage.CheckSamePort(data.Services[name], v.Command); err != nil {
+			r.add(Error, subject, err.Error(), "forward the same local port as the service's command")
+		}
+		if contexts != nil {
+			checkContext(v.Command, subject, contexts, r)
+		}
+		checkShell(v.Command, subject, r)
+	}
+}
+
+// checkPorts reports a command whose ports cannot be read or used, and returns
+// its local port when it is usable.
+func checkPorts(command, subject string, r *report) (string, bool) {
+	local, remote := cmdline.ParsePorts(command)
+	if local == "" {
+		r.add(Error, subject, "no local port found in the command", "add a local:remote port pair, e.g. 8080:80")
+		return "", false
+	}
+	n, err := strconv.Atoi(local)
+	if err != nil || n < 1 || n > 65535 {
+		r.add(Error, subject, fmt.Sprintf("local port %s is not a valid port", local), "use a port from 1024 to 65535")
+		return "", false
+	}
+	if remote != "" {
+		if p, err := strconv.Atoi(remote); err != nil || p < 1 || p > 65535 {
+			r.add(Error, subject, fmt.Sprintf("remote port %s is not a valid port", remote), "use the port the remote end listens on, 1 to 65535")
+		}
+	}
+	if n < 1024 {
+		fix := "use a port above 1023"
+		if n+8000 <= 65535 {
+			fix = fmt.Sprintf("use a port above 1023, e.g. %d", n+8000)
+		}
+		r.add(Warning, subject, fmt.Sprintf("local port %d needs root or admin rights", n), fix)
+	}
+	return local, true
+}
+
+// checkContext reports a kubectl --context that kubectl does not know.
+func checkContext(command, subject string, contexts []string, r *report) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 || fields[0] != "kubectl" {
+		return
+	}
+	for i, f := range fields {
+		name, value, inline := strings.Cut(f, "=")
+		if name != "--context" {
+			continue
+		}
+		if !inline {
+			if i+1 >= len(fields) {
+				return
+			}
+			value = fields[i+1]
+		}
+		value = strings.Trim(value, `"'`)
+		for _, c := range contexts {
+			if c == value {
+				return
+			}
+		}
+		fix := "pick one from `kubectl config get-contexts`"
+		for _, c := range contexts {
+			if strings.EqualFold(c, value) || strings.Contains(c, value) {
+				fix = fmt.Sprintf("did you mean --context %s?", c)
+				break
+			}
+		}
+		r.add(Error, subject, fmt.Sprintf("kube context '%s' does not exist", value), fix)
+		return
+	}
+}
+
+// checkShell reports shell syntax that breaks supervision. Commands run
+// through sh -c (cmd /C on Windows), and pf watches that shell: it must be
+// the forward, running in the foreground.
+func checkShell(command, subject string, r *report) {
+	if strings.ContainsAny(command, "“”‘’") {
+		r.add(Error, subject, "contains typographic quotes, which the shell passes on literally", `replace them with plain " or '`)
+	}
+	bare, ok := unquoted(command)
+	if !ok {
+		r.add(Error, subject, "has an unbalanced quote", "close the quote or remove it")
+		return
+	}
+	trimmed := strings.TrimSpace(bare)
+	switch {
+	case strings.HasSuffix(trimmed, "&") && !strings.HasSuffix(trimmed, "&&"):
+		r.add(Error, subject, "ends with &, so the forward goes to the background and pf sees it exit at once", "remove the trailing &")
+	case strings.Contains(bare, ";") || strings.Contains(bare, "&&") || strings.Contains(bare, "||"):
+		r.add(Warning, subject, "chains several commands, so a failing step looks like a dropped forward", "keep only the forward, and use waitFor for what must happen first")
+	case strings.Contains(bare, "|"):
+		r.add(Warning, subject, "pipes the forward's output, so pf cannot see its errors", "remove the pipe")
+	case strings.Contains(bare, ">"):
+		r.add(Warning, subject, "redirects the forward's output, so pf cannot see its errors", "remove the redirection")
+	}
+	if strings.Contains(bare, "$") || strings.Contains(bare, "`") {
+		r.add(Warning, subject, "uses shell expansion, which Windows cmd does not do", "write the value out if the config is shared with Windows users")
+	}
+}
+
+// unquoted returns command with its quoted parts removed, and false when a
+// quote is left open.
+func unquoted(command string) (string, bool) {
+	var b strings.Builder
+	var quote rune
+	for _, r := range command {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), quote == 0
+}
+
+// checkSharedPorts reports services on the same local port: an error where a
+// group holds two of them, since it can never start, a warning otherwise.
+func checkSharedPorts(data *storage.StorageData, ports map[string][]string, r *report) {
+	used := map[int]bool{}
+	for port := range ports {
+		n, _ := strconv.Atoi(port)
+		used[n] = true
+	}
+	freePort := func(from int) int {
+		for p := from + 10000; p <= 65535; p++ {
+			if !used[p] {
+				used[p] = true
+				return p
+			}
+		}
+		for p := from + 1; p <= 65535; p++ {
+			if !used[p] {
+				used[p] = true
+				return p
+			}
+		}
+		return 0
+	}
+
+	list := make([]string, 0, len(ports))
+	for port, names := range ports {
+		if len(names) > 1 {
+			list = append(list, port)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, _ := strconv.Atoi(list[i])
+		b, _ := strconv.Atoi(list[j])
+		return a < b
+	})
+
+	for _, port := range list {
+		names := ports[port]
+		n, _ := strconv.Atoi(port)
+		fix := fmt.Sprintf("give '%s' another local port", names[len(names)-1])
+		if free := freePort(n); free != 0 {
+			fix = fmt.Sprintf("give '%s' another local port, e.g. %d", names[len(names)-1], free)
+		}
+
+		clash := false
+		for _, group := range sortedGroups(data) {
+			var shared []string
+			for _, name := range names {
+				if slices.Contains(data.Groups[group], name) {
+					shared = append(shared, name)
+				}
+			}
+			if len(shared) > 1 {
+				clash = true
+				r.add(Error, "group "+group, fmt.Sprintf("services %s share local port %s, so the group cannot start", strings.Join(shared, ", "), port), fix)
+			}
+		}
+		if !clash {
+			r.add(Warning, "services "+strings.Join(names, ", "), fmt.Sprintf("share local port %s, so they cannot run together", port), fix)
+		}
+	}
+}
+
+func sortedGroups(data *storage.StorageData) []string {
+	groups := make([]string, 0, len(data.Groups))
+	for name := range data.Groups {
+		groups = append(groups, name)
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// isCatalogEntry reports whether name is a configured remote catalog's
+// "catalog/service", which only the catalog can vouch for.
+func isCatalogEntry(data *storage.StorageData, name string) bool {
+	catalogName, _, ok := storage.SplitCatalogName(name)
+	if !ok {
+		return false
+	}
+	for _, c := range data.Catalogs {
+		if c.Name == catalogName {
+			return true
+		}
+	}
+	return false
+}
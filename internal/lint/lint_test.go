@@ -0,0 +1,182 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+func TestCheckFindsCommonProblems(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // no probe or forwarder plugins
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+	data := &storage.StorageData{
+		Services: map[string]string{
+			"db":      "kubectl port-forward --context prod svc/db 5432:5432",
+			"db-copy": "kubectl port-forward --context=staging svc/db 5432:5432",
+			"web":     "kubectl port-forward svc/web 80:8080",
+			"noport":  "kubectl port-forward svc/x",
+			"bg":      "kubectl port-forward svc/api 9000:80 &",
+			"piped":   "kubectl port-forward svc/api 9001:80 | tee log",
+			"quoted":  `ssh -L 9002:db:5432 bastion -o "ProxyCommand=nc %h %p`,
+			"ok":      `ssh -L 9003:db:5432 bastion -o "ProxyCommand=a | b"`,
+			"up":      "monitor --via gone",
+			"lag":     "monitor --via db --probe pg-lag",
+			"ztna":    "plugin ztna 9004:5432",
+		},
+		Groups: map[string][]string{
+			"data":  {"db", "db-copy"},
+			"stale": {"ok", "removed", "corp/db"},
+		},
+		Catalogs:     []storage.CatalogConfig{{Name: "corp", URL: "https://pf.corp/catalog.json"}},
+		RemoteCheck:  map[string]storage.RemoteCheck{"db": {}, "ztna": {Every: "1m"}},
+		DNS:          map[string]storage.DNS{"ok": {Resolver: storage.DNSPin, IP: "10.0.0.5"}, "web": {}},
+		DefaultGroup: "backend",
+	}
+
+	var got []string
+	for _, f := range Check(data, []string{"prod", "Staging-eu"}) {
+		got = append(got, f.Severity+" "+f.Subject+": "+f.Problem)
+	}
+	for _, want := range []string{
+		"error service db-copy: kube context 'staging' does not exist",
+		"warning service web: local port 80 needs root or admin rights",
+		"error service noport: no local port found in the command",
+		"error service bg: ends with &",
+		"warning service piped: pipes the forward's output",
+		"error service quoted: has an unbalanced quote",
+		"error service up: monitor --via 'gone' is not a saved forward",
+		`error service lag: no probe plugin "pg-lag"`,
+		`error service ztna: no forwarder plugin "ztna"`,
+		"warning service ztna: has a remoteCheck",
+		"warning service web: has a dns setting",
+		"error group data: services db, db-copy share local port 5432",
+		"error group stale: names missing service 'removed'",
+		"error defaultGroup: names missing group 'backend'",
+	} {
+		found := false
+		for _, g := range got {
+			found = found || strings.HasPrefix(g, want)
+		}
+		if !found {
+			t.Errorf("missing finding %q", want)
+		}
+	}
+	for _, g := range got {
+		if strings.Contains(g, "service ok") || strings.Contains(g, "corp/db") || strings.Contains(g, "service db:") {
+			t.Errorf("unexpected finding %q", g)
+		}
+	}
+	if len(got) != 14 {
+		t.Errorf("got %d findings, want 14:\n%s", len(got), strings.Join(got, "\n"))
+	}
+}
+
+func TestCheckFlagsDeprecatedServices(t *testing.T) {
+	data := &storage.StorageData{
+		Services: map[string]string{
+			"db": "kubectl port-forward svc/db 5432:5432",
+			"pg": "kubectl port-forward svc/pg 5433:5432",
+		},
+		Groups:     map[string][]string{"data": {"db"}},
+		Deprecated: map[string]storage.Deprecation{"db": {Replacement: "pg", Sunset: "2000-01-01"}},
+	}
+	var got []string
+	for _, f := range Check(data, nil) {
+		got = append(got, f.Subject+": "+f.Problem+" → "+f.Fix)
+	}
+	want := []string{
+		"service db: is past its sunset on 2000-01-01 → pf delete db",
+		"group data: includes deprecated service 'db' → pf group remove-service data db && pf group add-service data pg",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCheckLintsVariants(t *testing.T) {
+	data := &storage.StorageData{
+		Services: map[string]string{"db": "ssh -N -L 5432:db:5432 bastion"},
+		Variants: map[string][]storage.Variant{"db": {
+			{OS: "windows", Command: "ssh.exe -N -L 5432:db:5432 bastion"},
+			{OS: "darwin", Host: "mac-*", Command: "ssh -N -L 6432:db:5432 bastion"},
+			{Host: "ci", Command: "ssh -N -L 5432:db:5432 bastion && sleep 1"},
+		}},
+	}
+	var got []string
+	for _, f := range Check(data, nil) {
+		got = append(got, f.Subject+": "+f.Problem)
+	}
+	want := []string{
+		`service db (variant for os darwin, host mac-*): "ssh -N -L 6432:db:5432 bastion" forwards local port "6432", not "5432"`,
+		"service db (variant for host ci): chains several commands, so a failing step looks like a dropped forward",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCheckSuggestsFixes(t *testing.T) {
+	data := &storage.StorageData{Services: map[string]string{
+		"a": "kubectl port-forward --context prd svc/a 8080:80",
+		"b": "kubectl port-forward svc/b 8080:80",
+	}}
+	fixes := map[string]string{}
+	for _, f := range Check(data, []string{"eu-prd"}) {
+		fixes[f.Subject] = f.Fix
+	}
+	if got := fixes["service a"]; got != "did you mean --context eu-prd?" {
+		t.Errorf("context fix = %q", got)
+	}
+	if got := fixes["services a, b"]; got != "give 'b' another local port, e.g. 18080" {
+		t.Errorf("port fix = %q", got)
+	}
+	if findings := Check(data, nil); len(findings) != 1 {
+		t.Errorf("without contexts only the shared port should be reported, got %+v", findings)
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	installed := func(bin string) (string, error) {
+		if bin == "kubectl" || bin == "ssh" {
+			return "/usr/bin/" + bin, nil
+		}
+		return "", fmt.Errorf("not found")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	plugins := filepath.Join(home, ".pf", "plugins", "forwarders")
+	os.MkdirAll(plugins, 0o755)
+	os.WriteFile(filepath.Join(plugins, "ztna"), []byte("#!/bin/sh\n"), 0o755)
+	for _, command := range []string{
+		"kubectl port-forward svc/db 5432:5432",
+		"kubectl proxy",
+		"ssh -N -L 5432:db.internal:5432 bastion",
+		"monitor --kube data/db",
+		"plugin ztna 5432:5432 zone=eu",
+	} {
+		if findings := CheckCommand(command, installed); len(findings) != 0 {
+			t.Errorf("CheckCommand(%q) = %+v, want none", command, findings)
+		}
+	}
+
+	for command, want := range map[string]string{
+		"kubectl get pods":                        "'kubectl get' is not a forward pf knows",
+		"kubectl port-forward svc/db":             "no local port found in the command",
+		"socat TCP-LISTEN:5432 TCP:db:5432":       "'socat' is not installed or not on PATH",
+		"kubectl port-forward svc/db 5432:5432 &": "ends with &",
+		"plugin missing 5432:5432":                "no forwarder plugin \"missing\"",
+	} {
+		found := false
+		for _, f := range CheckCommand(command, installed) {
+			found = found || f.Severity == Error && strings.HasPrefix(f.Problem, want)
+		}
+		if !found {
+			t.Errorf("CheckCommand(%q) should report %q", command, want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package logexport turns the logs of running sessions into one merged,
+// timestamped file for incident review: every selected service's output and
+// status changes in time order, in a plain layout that pastes cleanly into a
+// doc or a ticket.
+package logexport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/statedir"
+)
+
+// Line is one log entry of one service, the unit sessions dump and exports
+// merge.
+type Line struct {
+	Service string         `json:"service"`
+	Entry   model.LogEntry `json:"entry"`
+}
+
+// Dump writes the logs of services to path as JSON, replacing it atomically
+// so a reader never sees half a dump. Status changes older than a service's
+// kept log lines come from its transition history, so a long quiet stretch
+// of chatty output does not hide when it went down.
+func Dump(path string, services []model.Service) error {
+	var lines []Line
+	for _, svc := range services {
+		for _, t := range svc.Transitions {
+			if len(svc.Logs) > 0 && !t.At.Before(svc.Logs[0].Time) {
+				continue
+			}
+			lines = append(lines, Line{Service: svc.Name, Entry: model.LogEntry{
+				Time:    t.At,
+				Message: fmt.Sprintf("%s → %s", strings.ToUpper(t.From), strings.ToUpper(t.To)),
+				Kind:    model.LogKindStatus,
+				Status:  t.To,
+			}})
+		}
+		for _, e := range svc.Logs {
+			lines = append(lines, Line{Service: svc.Name, Entry: e})
+		}
+	}
+	data, err := json.Marshal(lines)
+	if err != nil {
+		return err
+	}
+	return statedir.WriteFile(path, data, 0600)
+}
+
+// ReadDump reads a file written by Dump.
+func ReadDump(path string) ([]Line, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lines []Line
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return nil, fmt.Errorf("invalid log dump: %w", err)
+	}
+	return lines, nil
+}
+
+// ParseTime reads a --since or --until value: a time of day today ("14:00",
+// "14:00:30"), a date and time ("2026-03-01 14:00"), RFC 3339, or a duration
+// back from now ("30m").
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			y, m, d := now.Date()
+			return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, now.Location()), nil
+		}
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("cannot read time %q (use 14:00, 2006-01-02 14:00, RFC 3339 or a duration like 30m)", value)
+}
+
+// Select merges lines into time order, keeping those of services (all when
+// empty) from since up to until; a zero bound is open.
+func Select(lines []Line, services []string, since, until time.Time) []Line {
+	var out []Line
+	for _, l := range lines {
+		if len(services) > 0 && !slices.Contains(services, l.Service) {
+			continue
+		}
+		if !since.IsZero() && l.Entry.Time.Before(since) || !until.IsZero() && l.Entry.Time.After(until) {
+			continue
+		}
+		out = append(out, l)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Entry.Time.Before(out[j].Entry.Time) })
+	return out
+}
+
+// Write renders lines for an incident doc: a header naming the services and
+// the time span, then one line per entry with its full timestamp and service.
+// Status changes and reconnects stand out with a "──" prefix, errors with "!".
+func Write(w io.Writer, lines []Line) error {
+	if len(lines) == 0 {
+		_, err := fmt.Fprintln(w, "No log lines in the selected range.")
+		return err
+	}
+	var names []string
+	width := 0
+	for _, l := range lines {
+		if !slices.Contains(names, l.Service) {
+			names = append(names, l.Service)
+		}
+		width = max(width, len(l.Service))
+	}
+	sort.Strings(names)
+	first, last := lines[0].Entry.Time, lines[len(lines)-1].Entry.Time
+	if _, err := fmt.Fprintf(w, "pf logs: %s, %s to %s (%s)\n\n", strings.Join(names, ", "),
+		first.Format("2006-01-02 15:04:05"), last.Format("15:04:05"), first.Format("MST")); err != nil {
+		return err
+	}
+	for _, l := range lines {
+		mark := " "
+		message := l.Entry.Message
+		switch {
+		case l.Entry.Kind == model.LogKindStatus || l.Entry.Kind == model.LogKindReconnect:
+			mark = "──"
+			message = strings.Trim(message, "━ ")
+		case l.Entry.IsError:
+			mark = "!"
+		}
+		if _, err := fmt.Fprintf(w, "%s  %-*s  %-2s %s\n", l.Entry.Time.Format("15:04:05.000"), width, l.Service, mark, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package logexport
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2026, 3, 1, 15, 0, 0, 0, time.UTC)
+	for value, want := range map[string]time.Time{
+		"14:00":                time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC),
+		"14:00:30":             time.Date(2026, 3, 1, 14, 0, 30, 0, time.UTC),
+		"2026-02-28 23:10":     time.Date(2026, 2, 28, 23, 10, 0, 0, time.UTC),
+		"2026-03-01T13:00:00Z": time.Date(2026, 3, 1, 13, 0, 0, 0, time.UTC),
+		"30m":                  time.Date(2026, 3, 1, 14, 30, 0, 0, time.UTC),
+	} {
+		got, err := ParseTime(value, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParseTime(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	if _, err := ParseTime("yesterday", now); err == nil {
+		t.Error("ParseTime should reject what it cannot read")
+	}
+}
+
+func TestExport(t *testing.T) {
+	at := func(min, sec int) time.Time { return time.Date(2026, 3, 1, 14, min, sec, 0, time.UTC) }
+	path := filepath.Join(t.TempDir(), "dump")
+	err := Dump(path, []model.Service{
+		{Name: "db", Transitions: []model.Transition{
+			{From: "connecting", To: "healthy", At: at(0, 1)},
+			{From: "healthy", To: "error", At: at(10, 0)},
+		}, Logs: []model.LogEntry{
+			{Time: at(0, 5), Message: "Forwarding from 127.0.0.1:5432"},
+			{Time: at(10, 0), Message: "error: lost connection to pod", IsError: true},
+			{Time: at(10, 0), Message: "━━━━ HEALTHY → ERROR ━━━━", Kind: model.LogKindStatus},
+			{Time: at(45, 0), Message: "too late"},
+		}},
+		{Name: "api", Logs: []model.LogEntry{{Time: at(5, 0), Message: "Handling connection for 8080"}}},
+		{Name: "cache", Logs: []model.LogEntry{{Time: at(6, 0), Message: "not selected"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, err := ReadDump(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	if err := Write(&out, Select(lines, []string{"db", "api"}, at(0, 0), at(30, 0))); err != nil {
+		t.Fatal(err)
+	}
+	want := `pf logs: api, db, 2026-03-01 14:00:01 to 14:10:00 (UTC)
+
+14:00:01.000  db   ── CONNECTING → HEALTHY
+14:00:05.000  db      Forwarding from 127.0.0.1:5432
+14:05:00.000  api     Handling connection for 8080
+14:10:00.000  db   !  error: lost connection to pod
+14:10:00.000  db   ── HEALTHY → ERROR
+`
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,86 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// failover moves the service on to its next command after a run that never
+// became healthy, logging which endpoint it switches to. It reports whether
+// to try that one at once; false when there is nothing to switch to or the
+// list has wrapped round to the first command, so the caller backs off.
+func (s *runningService) failover() bool {
+	s.mu.Lock()
+	if len(s.commands) < 2 || s.degraded || !s.healthySince.IsZero() {
+		s.mu.Unlock()
+		return false
+	}
+	next := (s.active + 1) % len(s.commands)
+	s.useCommandLocked(next)
+	target := s.forward.Target
+	if target == "" {
+		target = s.command
+	}
+	s.pushLogLocked(model.LogEntry{
+		Message: fmt.Sprintf("━━━━ SWITCHING to endpoint %d/%d: %s ━━━━", next+1, len(s.commands), target),
+		Kind:    model.LogKindReconnect,
+	})
+	s.mu.Unlock()
+
+	s.changed()
+	return next != 0
+}
+
+// fallBack counts runs that never became healthy and, after fallbackAfter of
+// them in a row, switches the service to its fallback command, where it stays
+// (degraded) until restarted. It reports whether it just switched, so the
+// caller can try the fallback at once.
+func (s *runningService) fallBack() bool {
+	s.mu.Lock()
+	if s.fallback == "" || s.degraded {
+		s.mu.Unlock()
+		return false
+	}
+	if !s.healthySince.IsZero() {
+		s.failures = 0
+		s.mu.Unlock()
+		return false
+	}
+	s.failures++
+	if s.failures < s.fallbackAfter {
+		s.mu.Unlock()
+		return false
+	}
+	s.degraded = true
+	s.setCommandLocked(s.fallback)
+	s.pushLogLocked(model.LogEntry{
+		Message: fmt.Sprintf("━━━━ DEGRADED: %d failed attempts, switching to the fallback command ━━━━", s.failures),
+		Kind:    model.LogKindReconnect,
+	})
+	s.mu.Unlock()
+
+	s.changed()
+	return true
+}
+
+// useCommandLocked makes commands[i] the one the next run starts. The caller
+// holds s.mu.
+func (s *runningService) useCommandLocked(i int) {
+	if i >= len(s.commands) {
+		return
+	}
+	s.active = i
+	s.setCommandLocked(s.commands[i])
+}
+
+// setCommandLocked makes command the one the next run starts and updates what
+// the frontends show about where it forwards to. The caller holds s.mu.
+func (s *runningService) setCommandLocked(command string) {
+	s.command = command
+	s.forward = cmdline.ParseForward(command)
+	if _, main := cmdline.ParsePorts(command); main != "" {
+		s.mainPort = main
+	}
+}
@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFailoverCyclesThroughEndpoints(t *testing.T) {
+	commands := []string{
+		"kubectl port-forward svc/db 5432:5432",
+		"ssh -N -L 5432:db-replica:6432 bastion-a",
+		"ssh -N -L 5432:db-replica:6432 bastion-b",
+	}
+	svc := &runningService{name: "db", commands: commands}
+	svc.useCommandLocked(0)
+
+	if !svc.failover() {
+		t.Fatal("a failed first endpoint should move on at once")
+	}
+	s := svc.snapshot()
+	if s.Endpoint != 2 || s.Endpoints != 3 || s.Target != "db-replica" || s.MainPort != "6432" || s.Command != commands[1] {
+		t.Errorf("after one failover: %+v", s)
+	}
+	if !strings.Contains(s.Logs[len(s.Logs)-1].Message, "endpoint 2/3: db-replica") {
+		t.Errorf("switch should be logged: %+v", s.Logs)
+	}
+
+	svc.failover()
+	if svc.failover() {
+		t.Error("wrapping back to the first endpoint should back off")
+	}
+	if s := svc.snapshot(); s.Endpoint != 1 || s.Target != "svc/db" {
+		t.Errorf("should be back on the first endpoint: %+v", s)
+	}
+
+	// A run that got healthy keeps its endpoint for the reconnect.
+	svc.healthySince = time.Now()
+	if svc.failover() || svc.snapshot().Endpoint != 1 {
+		t.Error("a healthy endpoint should be retried, not switched away from")
+	}
+
+	single := &runningService{name: "api", command: "kubectl port-forward svc/api 80:80"}
+	if single.failover() || single.snapshot().Endpoints != 1 {
+		t.Error("a service without alternates has nothing to fail over to")
+	}
+}
+
+func TestFallBackAfterRepeatedFailures(t *testing.T) {
+	svc := &runningService{
+		name:          "db",
+		commands:      []string{"kubectl port-forward svc/db 5432:5432"},
+		fallback:      "ssh -N -L 5432:db.internal:5432 bastion",
+		fallbackAfter: 3,
+	}
+	svc.useCommandLocked(0)
+
+	// A healthy run in between resets the count.
+	svc.fallBack()
+	svc.healthySince = time.Now()
+	svc.fallBack()
+	svc.healthySince = time.Time{}
+
+	for i := 0; i < 2; i++ {
+		if svc.fallBack() {
+			t.Fatalf("switched after %d failures, want 3", i+1)
+		}
+	}
+	if !svc.fallBack() {
+		t.Fatal("third failure in a row should switch to the fallback")
+	}
+	s := svc.snapshot()
+	if !s.Degraded || s.Command != svc.fallback || s.Target != "db.internal" {
+		t.Errorf("after fallback: %+v", s)
+	}
+	if svc.fallBack() || svc.failover() {
+		t.Error("a degraded service stays on its fallback")
+	}
+}
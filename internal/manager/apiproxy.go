@@ -0,0 +1,70 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/endpoint"
+)
+
+// healthzInterval is how often a kubectl proxy service's /healthz is checked,
+// and healthzFailures how many failed checks in a row mark it as in error.
+// Vars so tests can shrink them.
+var (
+	healthzInterval = 5 * time.Second
+	healthzFailures = 2
+)
+
+// healthzTimeout bounds one /healthz check.
+const healthzTimeout = 3 * time.Second
+
+// watchHealthz checks a kubectl proxy service through its own /healthz, on
+// the session's shared pool (see checkScheduler), until ctx ends (the proxy
+// process exits). kubectl proxy prints nothing once it is serving, so this is
+// what marks it healthy, and what notices when the API behind it stops
+// answering while the process lives on.
+func watchHealthz(ctx context.Context, svc *runningService) {
+	svc.mu.RLock()
+	url := "http://" + net.JoinHostPort(endpoint.DialHost(svc.forward.Address), svc.localPort) + "/healthz"
+	svc.mu.RUnlock()
+
+	failures := 0
+	// The first check comes quickly, once the proxy has had a moment to bind.
+	stop := healthChecks.every(ctx, svc.name+" healthz", min(time.Second, healthzInterval), healthzInterval, func() {
+		checkCtx, cancel := context.WithTimeout(ctx, healthzTimeout)
+		err := checkHealthz(checkCtx, checkClient, url)
+		cancel()
+		if err != nil {
+			failures++
+			if failures >= healthzFailures && ctx.Err() == nil {
+				svc.setError(fmt.Sprintf("healthz: %v", err))
+			}
+		} else {
+			failures = 0
+			svc.markHealthy()
+		}
+	})
+	<-ctx.Done()
+	stop()
+}
+
+func checkHealthz(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10)) // so the connection can be reused
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package manager
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestWatchHealthzFollowsTheAPI(t *testing.T) {
+	origInterval := healthzInterval
+	healthzInterval = 10 * time.Millisecond
+	defer func() { healthzInterval = origInterval }()
+
+	var down atomic.Bool
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" || down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer api.Close()
+	_, port, _ := net.SplitHostPort(api.Listener.Addr().String())
+
+	svc := &runningService{name: "api", localPort: port, status: model.StatusConnecting}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { watchHealthz(ctx, svc); close(done) }()
+	defer func() { cancel(); <-done }()
+
+	waitForStatus(t, svc, model.StatusHealthy)
+	down.Store(true)
+	waitForStatus(t, svc, model.StatusError)
+	if e := svc.snapshot().LastError; e != "healthz: 503 Service Unavailable" {
+		t.Errorf("last error = %q", e)
+	}
+}
+
+func waitForStatus(t *testing.T, svc *runningService, status string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for svc.snapshot().Status != status {
+		if time.Now().After(deadline) {
+			t.Fatalf("status = %s, want %s", svc.snapshot().Status, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
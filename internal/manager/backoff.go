@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff is how long a service waits before reconnecting: Base, doubled for
+// each failed attempt in a row up to Max, give or take a fraction Jitter of
+// it. A run that stays healthy for Reset starts the count over.
+type Backoff struct {
+	Base   time.Duration
+	Max    time.Duration
+	Jitter float64
+	Reset  time.Duration
+}
+
+// DefaultBackoff is what every manager uses unless SetBackoff changes it.
+var DefaultBackoff = Backoff{
+	Base:   2 * time.Second,
+	Max:    30 * time.Second,
+	Jitter: 0.1,
+	Reset:  30 * time.Second,
+}
+
+// Delay is the wait before reconnect attempt (1-based), jitter included.
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := b.Max
+	if attempt < 32 {
+		delay = min(b.Base*time.Duration(1<<uint(max(attempt-1, 0))), b.Max)
+	}
+	if b.Jitter > 0 {
+		delay += time.Duration(float64(delay) * b.Jitter * (rand.Float64()*2 - 1))
+	}
+	return delay
+}
+
+// SetBackoff changes how services wait between reconnects, from their next
+// attempt on. Tests use it for short waits without jitter.
+func (m *ServiceManager) SetBackoff(b Backoff) {
+	m.mu.Lock()
+	m.backoff = b
+	m.mu.Unlock()
+}
+
+// reconnectBackoff is the manager's backoff, or DefaultBackoff when none is
+// set.
+func (m *ServiceManager) reconnectBackoff() Backoff {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.backoff == (Backoff{}) {
+		return DefaultBackoff
+	}
+	return m.backoff
+}
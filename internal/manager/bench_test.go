@@ -0,0 +1,57 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/hints"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// chattyOutput is a kubectl port-forward's output under load, with an error
+// now and then.
+func chattyOutput(lines int) string {
+	var b strings.Builder
+	for i := range lines {
+		if i%50 == 49 {
+			b.WriteString("E1016 10:42:07.512 portforward.go:413] an error occurred forwarding 5432 -> 5432: connection reset by peer\n")
+			continue
+		}
+		b.WriteString("Handling connection for 5432\n")
+	}
+	return b.String()
+}
+
+// BenchmarkStreamOutput measures log ingestion: reading, classifying and
+// storing each line a child prints.
+func BenchmarkStreamOutput(b *testing.B) {
+	const lines = 1000
+	output := chattyOutput(lines)
+	m := &ServiceManager{hints: hints.Builtin()}
+	svc := &runningService{name: "db", status: model.StatusHealthy, logs: newLogRing(maxLogEntries), onChange: func() {}}
+	b.SetBytes(int64(len(output)))
+	b.ReportAllocs()
+	for b.Loop() {
+		m.streamOutput(svc, strings.NewReader(output), strings.NewReader(""))
+	}
+}
+
+// BenchmarkListServiceStates measures the snapshot the UI takes on every
+// update, with every service's log full.
+func BenchmarkListServiceStates(b *testing.B) {
+	m := &ServiceManager{services: make(map[string]*runningService)}
+	for i := range 20 {
+		svc := &runningService{name: fmt.Sprintf("svc-%02d", i), status: model.StatusHealthy, logs: newLogRing(maxLogEntries)}
+		for j := range maxLogEntries {
+			svc.logs.Push(model.LogEntry{Message: fmt.Sprintf("Handling connection for %d", j)})
+		}
+		m.services[svc.name] = svc
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		if len(m.ListServiceStates()) != 20 {
+			b.Fatal("lost a service")
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package manager
+
+import (
+	"container/heap"
+	"context"
+	"hash/fnv"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// checkWorkers is how many health checks run at once, across every monitor
+// and kubectl proxy of the session; the others wait their turn. A var so
+// tests can change it.
+var checkWorkers = 8
+
+// checkClient is the HTTP client every HTTP check shares, so checks of the
+// same forward reuse a connection instead of opening one each time. It never
+// goes through a proxy: checks dial pf's own local ports.
+var checkClient = &http.Client{Transport: &http.Transport{
+	MaxIdleConnsPerHost: 1,
+	IdleConnTimeout:     time.Minute,
+}}
+
+// healthChecks runs the session's periodic health checks; see
+// checkScheduler.
+var healthChecks checkScheduler
+
+// checkScheduler runs periodic checks from one timer on a bounded pool of
+// workers, instead of a ticker and a dial per service: with dozens of
+// services those would all fire together. Checks with the same interval are
+// spread over it, each at a point that comes from its name. A check that is
+// still running (or waiting for a worker) when it is due again skips that
+// turn. The zero value is ready to use; its goroutines run while it has
+// checks.
+type checkScheduler struct {
+	mu      sync.Mutex
+	added   []*checkJob
+	wake    chan struct{}
+	running bool
+}
+
+// checkJob is one periodic check; see checkScheduler.every.
+type checkJob struct {
+	name  string
+	every time.Duration
+	check func()
+	ctx   context.Context
+	due   time.Time
+	first bool // the first run has not happened yet
+
+	// runMu is held while check runs; stopped is set under it.
+	runMu   sync.Mutex
+	stopped bool
+}
+
+// every runs check first after first, then every interval, which must be
+// positive, until ctx ends or stop is called. stop waits for a run in
+// progress, so check never runs once stop has returned. name places the check
+// within the interval.
+func (s *checkScheduler) every(ctx context.Context, name string, first, interval time.Duration, check func()) (stop func()) {
+	j := &checkJob{name: name, every: interval, check: check, ctx: ctx, due: time.Now().Add(first), first: true}
+	s.mu.Lock()
+	if s.wake == nil {
+		s.wake = make(chan struct{}, 1)
+	}
+	s.added = append(s.added, j)
+	if !s.running {
+		s.running = true
+		go s.loop()
+	}
+	s.mu.Unlock()
+	s.signal()
+	return func() {
+		j.runMu.Lock()
+		j.stopped = true
+		j.runMu.Unlock()
+		s.signal() // so the loop drops it now rather than when it is due
+	}
+}
+
+func (s *checkScheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// loop hands due checks to the workers and schedules their next run once
+// they are done. It ends, with the workers, when no check is left.
+func (s *checkScheduler) loop() {
+	work := make(chan *checkJob)
+	done := make(chan *checkJob)
+	for range checkWorkers {
+		go func() {
+			for j := range work {
+				j.run()
+				done <- j
+			}
+		}()
+	}
+	defer close(work)
+
+	var (
+		queue jobQueue // waiting for their time
+		ready []*checkJob
+		busy  int
+		timer = time.NewTimer(0)
+	)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		for _, j := range s.added {
+			heap.Push(&queue, j)
+		}
+		s.added = nil
+		now := time.Now()
+		for len(queue) > 0 && !queue[0].due.After(now) {
+			j := heap.Pop(&queue).(*checkJob)
+			if !j.ended() {
+				ready = append(ready, j)
+			}
+		}
+		if len(queue) == 0 && len(ready) == 0 && busy == 0 {
+			s.running = false
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+		// Since Go 1.23 neither Stop nor Reset leaves a stale tick behind.
+		if len(queue) > 0 {
+			timer.Reset(time.Until(queue[0].due))
+		} else {
+			timer.Stop()
+		}
+
+		var send chan *checkJob
+		var next *checkJob
+		if len(ready) > 0 {
+			send, next = work, ready[0]
+		}
+		select {
+		case <-s.wake:
+			queue = slices.DeleteFunc(queue, (*checkJob).ended)
+			heap.Init(&queue)
+		case <-timer.C:
+		case send <- next:
+			ready = ready[1:]
+			busy++
+		case j := <-done:
+			busy--
+			if !j.ended() {
+				j.schedule(time.Now())
+				heap.Push(&queue, j)
+			}
+		}
+	}
+}
+
+// run runs the check, unless it was stopped or its context ended.
+func (j *checkJob) run() {
+	j.runMu.Lock()
+	defer j.runMu.Unlock()
+	if j.stopped || j.ctx.Err() != nil {
+		return
+	}
+	j.check()
+}
+
+func (j *checkJob) ended() bool {
+	j.runMu.Lock()
+	defer j.runMu.Unlock()
+	return j.stopped || j.ctx.Err() != nil
+}
+
+// schedule sets the next run after one that ended at now. After the first, a
+// check moves to its own point of the interval (see checkOffset), at least
+// half an interval away; after that it keeps the interval, skipping the
+// turns it overran.
+func (j *checkJob) schedule(now time.Time) {
+	if j.first {
+		j.first = false
+		j.due = nextSlot(now, j.every, checkOffset(j.name, j.every))
+		return
+	}
+	for j.due = j.due.Add(j.every); !j.due.After(now); j.due = j.due.Add(j.every) {
+	}
+}
+
+// nextSlot is the first time at least half of every after now that lies
+// offset into an interval, counting intervals from the Unix epoch so that
+// checks sharing one line up the same way.
+func nextSlot(now time.Time, every, offset time.Duration) time.Time {
+	earliest := now.Add(every / 2)
+	start := earliest.Truncate(every).Add(offset)
+	if start.Before(earliest) {
+		start = start.Add(every)
+	}
+	return start
+}
+
+// checkOffset is where in an interval of every the check called name runs.
+func checkOffset(name string, every time.Duration) time.Duration {
+	if every <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return time.Duration(h.Sum64() % uint64(every))
+}
+
+// jobQueue orders checks by when they are due; see container/heap.
+type jobQueue []*checkJob
+
+func (q jobQueue) Len() int           { return len(q) }
+func (q jobQueue) Less(i, k int) bool { return q[i].due.Before(q[k].due) }
+func (q jobQueue) Swap(i, k int)      { q[i], q[k] = q[k], q[i] }
+func (q *jobQueue) Push(x any)        { *q = append(*q, x.(*checkJob)) }
+func (q *jobQueue) Pop() any {
+	old := *q
+	j := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return j
+}
@@ -0,0 +1,140 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckSchedulerBoundsConcurrentChecks(t *testing.T) {
+	origWorkers := checkWorkers
+	checkWorkers = 2
+	defer func() { checkWorkers = origWorkers }()
+
+	var s checkScheduler
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var active, most, runs atomic.Int32
+	var stops []func()
+	for i := range 6 {
+		stops = append(stops, s.every(ctx, fmt.Sprintf("svc-%d", i), 0, 20*time.Millisecond, func() {
+			n := active.Add(1)
+			for m := most.Load(); n > m && !most.CompareAndSwap(m, n); m = most.Load() {
+			}
+			time.Sleep(5 * time.Millisecond)
+			active.Add(-1)
+			runs.Add(1)
+		}))
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for runs.Load() < 24 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, stop := range stops {
+		stop()
+	}
+	if runs.Load() < 24 {
+		t.Fatalf("%d checks ran, want at least 24", runs.Load())
+	}
+	if m := most.Load(); m > 2 {
+		t.Errorf("%d checks ran at once, want at most 2", m)
+	}
+}
+
+func TestCheckSchedulerStopWaitsForTheRunningCheck(t *testing.T) {
+	var s checkScheduler
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var mu sync.Mutex
+	running, after := false, 0
+	var once sync.Once
+	stop := s.every(context.Background(), "db", 0, 10*time.Millisecond, func() {
+		mu.Lock()
+		running = true
+		after++
+		mu.Unlock()
+		once.Do(func() { close(started); <-release })
+		mu.Lock()
+		running = false
+		mu.Unlock()
+	})
+	<-started
+	stopped := make(chan struct{})
+	go func() { stop(); close(stopped) }()
+	select {
+	case <-stopped:
+		t.Fatal("stop returned while the check ran")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-stopped
+
+	mu.Lock()
+	runs := after
+	if running {
+		t.Error("the check still runs after stop")
+	}
+	mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if after != runs {
+		t.Errorf("the check ran %d more times after stop", after-runs)
+	}
+}
+
+func TestCheckSchedulerEndsWithTheContext(t *testing.T) {
+	var s checkScheduler
+	ctx, cancel := context.WithCancel(context.Background())
+	var runs atomic.Int32
+	s.every(ctx, "db", 0, 5*time.Millisecond, func() { runs.Add(1) })
+	for runs.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		running := s.running
+		s.mu.Unlock()
+		if !running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the scheduler kept running without checks")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestNextSlotSpreadsChecks(t *testing.T) {
+	every := 10 * time.Second
+	now := time.Date(2026, 1, 1, 12, 0, 3, 0, time.UTC)
+	for _, tc := range []struct {
+		offset time.Duration
+		want   time.Time
+	}{
+		{9 * time.Second, now.Add(6 * time.Second)},  // 12:00:09
+		{2 * time.Second, now.Add(9 * time.Second)},  // 12:00:12, 12:00:02 is too soon
+		{7 * time.Second, now.Add(14 * time.Second)}, // 12:00:17, 12:00:07 is too soon
+	} {
+		if got := nextSlot(now, every, tc.offset); !got.Equal(tc.want) {
+			t.Errorf("nextSlot(offset %s) = %s, want %s", tc.offset, got.Format("15:04:05"), tc.want.Format("15:04:05"))
+		}
+	}
+
+	seen := map[time.Duration]bool{}
+	for i := range 30 {
+		offset := checkOffset(fmt.Sprintf("svc-%02d", i), every)
+		if offset < 0 || offset >= every {
+			t.Fatalf("offset %s is outside the interval", offset)
+		}
+		seen[offset.Truncate(time.Second)] = true
+	}
+	if len(seen) < 5 {
+		t.Errorf("30 checks share %d seconds of a 10s interval", len(seen))
+	}
+}
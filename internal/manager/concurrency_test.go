@@ -0,0 +1,194 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/fakeforward"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// These tests drive one manager from several goroutines at once, the way the
+// TUI, `pf` requests from other terminals and --follow do in one session. Run
+// them with -race.
+
+// newFakeManager saves a healthy fake forwarder under each name and returns a
+// manager over them, with nothing started.
+func newFakeManager(t *testing.T, names ...string) (*ServiceManager, map[string]*fakeforward.Forwarder) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake forwarder is killed through a Unix shell")
+	}
+	fwds := make(map[string]*fakeforward.Forwarder, len(names))
+	services := make(map[string]string, len(names))
+	for _, name := range names {
+		fwds[name] = fakeforward.New(t, fakeforward.FreePort(t), 5432)
+		services[name] = fwds[name].Command()
+	}
+	m := NewServiceManager(fakeforward.NewStorage(t, services))
+	m.SetBackoff(testBackoff)
+	t.Cleanup(m.StopAllServices)
+	return m, fwds
+}
+
+func TestConcurrentStartsRunOneService(t *testing.T) {
+	m, fwd := newFakeManager(t, "db")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- m.StartService(context.Background(), "db")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	started := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			started++
+		case !strings.Contains(err.Error(), "already running"):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if started != 1 {
+		t.Fatalf("%d of 10 concurrent starts succeeded, want 1", started)
+	}
+	waitFor(t, m, "became healthy", func(s model.Service) bool { return s.Status == model.StatusHealthy })
+	if n := len(fwd["db"].Starts()); n != 1 {
+		t.Errorf("the forwarder was started %d times, want 1", n)
+	}
+}
+
+func TestStopDuringRestartStaysStopped(t *testing.T) {
+	m, fwd := newFakeManager(t, "db")
+	if err := m.StartService(context.Background(), "db"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, m, "became healthy", func(s model.Service) bool { return s.Status == model.StatusHealthy })
+
+	for range 3 {
+		m.RestartService(context.Background(), "db")
+	}
+	m.StopService("db")
+
+	// Give the restarts time to wind down: none may bring db back.
+	time.Sleep(500 * time.Millisecond)
+	starts := len(fwd["db"].Starts())
+	time.Sleep(300 * time.Millisecond)
+	if states := m.ListServiceStates(); len(states) != 0 {
+		t.Errorf("db came back after being stopped: %+v", states)
+	}
+	if n := len(fwd["db"].Starts()); n != starts {
+		t.Errorf("the forwarder kept being started after the stop: %d starts, then %d", starts, n)
+	}
+}
+
+func TestFrontendsMutateConcurrently(t *testing.T) {
+	names := []string{"db", "api", "cache"}
+	m, fwds := newFakeManager(t, names...)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A second frontend follows the session as the first does.
+	updates, unsubscribe := m.Subscribe()
+	defer unsubscribe()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-updates:
+				m.ListServiceStates()
+				m.GroupStates()
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	deadline := time.Now().Add(time.Second)
+	for frontend := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(frontend)))
+			for time.Now().Before(deadline) {
+				name := names[rng.Intn(len(names))]
+				switch rng.Intn(7) {
+				case 0, 1:
+					_ = m.StartStoredService(ctx, name)
+				case 2:
+					m.StopService(name)
+				case 3:
+					_ = m.RestartService(ctx, name)
+				case 4:
+					m.TrackGroup("backend", names[:2])
+				case 5:
+					m.SetParams(map[string]map[string]string{name: {"remote-port": fmt.Sprint(5432 + frontend)}})
+				default:
+					for _, svc := range m.ListServiceStates() {
+						_ = svc.Logs
+					}
+				}
+				time.Sleep(time.Duration(rng.Intn(20)) * time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	m.StopAllServices()
+	// Restarts still in flight must see the stop and start nothing more.
+	time.Sleep(500 * time.Millisecond)
+	starts := map[string]int{}
+	for name, fwd := range fwds {
+		starts[name] = len(fwd.Starts())
+	}
+	time.Sleep(300 * time.Millisecond)
+	if states := m.ListServiceStates(); len(states) != 0 {
+		t.Errorf("services still running after StopAllServices: %d", len(states))
+	}
+	for name, fwd := range fwds {
+		if n := len(fwd.Starts()); n != starts[name] {
+			t.Errorf("%s was started again after StopAllServices: %d starts, then %d", name, starts[name], n)
+		}
+	}
+}
+
+func TestSubscribersAreSignalled(t *testing.T) {
+	m := &ServiceManager{services: map[string]*runningService{}, updates: make(chan struct{}, 1)}
+	first, unsubscribe := m.Subscribe()
+	second, _ := m.Subscribe()
+
+	m.notify()
+	m.notify() // coalesced with the pending signal
+	for i, ch := range []<-chan struct{}{m.Updates(), first, second} {
+		select {
+		case <-ch:
+		default:
+			t.Errorf("channel %d was not signalled", i)
+		}
+	}
+
+	unsubscribe()
+	m.notify()
+	select {
+	case <-first:
+		t.Error("an ended subscription was signalled")
+	default:
+	}
+	select {
+	case <-second:
+	default:
+		t.Error("the remaining subscriber was not signalled")
+	}
+}
@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/endpoint"
+)
+
+// Definition is one line of the stream other tools (tilt, skaffold, scripts)
+// pipe into `pf run --from-stdin`: a forward to run in this session, or with
+// Remove set, one to stop.
+type Definition struct {
+	Name    string `json:"name"`
+	Command string `json:"command,omitempty"`
+	Remove  bool   `json:"remove,omitempty"`
+}
+
+// FollowDefinitions reads JSON definitions, one per line, from r and applies
+// each as it arrives until r ends or ctx does. Blank lines and lines starting
+// with # are skipped. Services it starts are ad hoc: nothing is saved, and
+// they stop with the session. Problems with a line go to onError and the
+// stream goes on.
+func (m *ServiceManager) FollowDefinitions(ctx context.Context, r io.Reader, onError func(error)) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var def Definition
+		if err := json.Unmarshal([]byte(line), &def); err != nil {
+			onError(fmt.Errorf("invalid definition %q: %v", line, err))
+			continue
+		}
+		if err := m.applyDefinition(ctx, def); err != nil {
+			onError(err)
+		}
+	}
+}
+
+func (m *ServiceManager) applyDefinition(ctx context.Context, def Definition) error {
+	if def.Remove {
+		m.StopService(def.Name)
+		return nil
+	}
+	return m.StartAdhoc(ctx, def.Name, def.Command)
+}
+
+// StartAdhoc runs command as service name without saving it. A service of
+// that name already running the same command is left alone; on another
+// command it is replaced. The local port must not be in use by another
+// running service on the same address: forwards of one port on different
+// loopback addresses, as `pf run --all-services` makes, may run side by side.
+func (m *ServiceManager) StartAdhoc(ctx context.Context, name, command string) error {
+	if err := ensureValidServiceName(name); err != nil {
+		return fmt.Errorf("invalid service name: %v", err)
+	}
+	local, _ := cmdline.ParsePorts(command)
+	address := cmdline.ParseForward(command).Address
+	replace := false
+	for _, svc := range m.runningList() {
+		svc.mu.RLock()
+		current := svc.commands
+		port := svc.localPort
+		bind := svc.forward.Address
+		svc.mu.RUnlock()
+		if svc.name == name {
+			if len(current) > 0 && current[0] == command {
+				return nil
+			}
+			replace = true
+		} else if port == local && sameBind(bind, address) {
+			return fmt.Errorf("service '%s': port %s is already used by '%s'", name, local, svc.name)
+		}
+	}
+	if replace {
+		m.StopService(name)
+	}
+	return m.startCommand(ctx, name, command, true)
+}
+
+// sameBind reports whether forwards bound to addresses a and b would take
+// the same port: they name the same address, or either is a wildcard.
+func sameBind(a, b string) bool {
+	wildcard := func(addr string) bool { return addr == "0.0.0.0" || addr == "::" || addr == "[::]" }
+	return wildcard(a) || wildcard(b) || endpoint.DialHost(a) == endpoint.DialHost(b)
+}
@@ -0,0 +1,65 @@
+package manager
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+func TestFollowDefinitionsStartsAndStopsAdhocServices(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a sh comment to give the command a port spec")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	m := NewServiceManager(storage.NewStorage())
+	defer m.StopAllServices()
+
+	stream := strings.Join([]string{
+		`# from tilt`,
+		`{"name": "db", "command": "sleep 60 # 59231:5432"}`,
+		`{"name": "db", "command": "sleep 60 # 59231:5432"}`,
+		`{"name": "db2", "command": "sleep 60 # 59231:5432"}`,
+		`{"name": "cache", "command": "sleep 60 # 59232:6379"}`,
+		`not json`,
+		`{"name": "cache", "remove": true}`,
+	}, "\n")
+	var errs []string
+	m.FollowDefinitions(context.Background(), strings.NewReader(stream), func(err error) { errs = append(errs, err.Error()) })
+
+	var names []string
+	for _, s := range m.ListServiceStates() {
+		names = append(names, s.Name)
+	}
+	if strings.Join(names, ",") != "db" {
+		t.Errorf("running %v, want just db", names)
+	}
+	if len(errs) != 2 || !strings.Contains(errs[0], "already used by 'db'") || !strings.Contains(errs[1], "invalid definition") {
+		t.Errorf("errors = %q", errs)
+	}
+	if !m.services["db"].adhoc {
+		t.Error("db should be ad hoc")
+	}
+}
+
+func TestSameBind(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want bool
+	}{
+		{"", "", true},
+		{"", "127.0.0.1", true},
+		{"localhost", "127.0.0.1", true},
+		{"127.1.0.1", "127.1.0.2", false},
+		{"", "127.1.0.1", false},
+		{"0.0.0.0", "127.1.0.1", true},
+		{"127.1.0.1", "::", true},
+	} {
+		if got := sameBind(tc.a, tc.b); got != tc.want {
+			t.Errorf("sameBind(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
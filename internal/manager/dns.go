@@ -0,0 +1,85 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// dnsTimeout bounds one lookup at a service's DNS server, and lookupAt makes
+// it. Vars so tests can fake the server.
+var (
+	dnsTimeout = 5 * time.Second
+	lookupAt   = lookupIPv4At
+)
+
+// lookupIPv4At returns host's IPv4 addresses as the DNS server at server
+// (host:port) gives them, sorted. It asks over TCP, which also goes through a
+// forward of the server's port.
+func lookupIPv4At(ctx context.Context, server, host string) ([]string, error) {
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", server)
+		},
+	}
+	ips, err := r.LookupIP(ctx, "ip4", host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = ip.String()
+	}
+	slices.Sort(addrs)
+	return addrs, nil
+}
+
+// resolveForward points command's ssh -L forward at the address svc's dns
+// setting gives for its remote host, so the ssh server does not resolve the
+// name itself (see storage.DNS). It logs the address when it changes. When the
+// lookup fails it puts the service in error, saying which server did not
+// answer and how, and returns false.
+func resolveForward(ctx context.Context, svc *runningService, command string, dns storage.DNS) (string, bool) {
+	host := cmdline.ParseForward(command).Target
+	if host == "" || net.ParseIP(host) != nil {
+		return command, true
+	}
+	ip, via := dns.IP, "pinned"
+	if dns.Resolver == storage.DNSServer {
+		lookupCtx, cancel := context.WithTimeout(ctx, dnsTimeout)
+		addrs, err := lookupAt(lookupCtx, dns.ServerAddress(), host)
+		cancel()
+		if err == nil && len(addrs) == 0 {
+			err = fmt.Errorf("no IPv4 address")
+		}
+		if err != nil {
+			message := fmt.Sprintf("dns: %s did not resolve at %s: %v", host, dns.ServerAddress(), err)
+			svc.setError(message)
+			svc.appendLog(message, true)
+			return "", false
+		}
+		ip, via = addrs[0], "via "+dns.ServerAddress()
+	}
+	pinned, err := cmdline.Retarget(command, ip, "")
+	if err != nil {
+		message := fmt.Sprintf("dns: cannot forward to %s: %v", ip, err)
+		svc.setError(message)
+		svc.appendLog(message, true)
+		return "", false
+	}
+	svc.mu.Lock()
+	changed := svc.resolved != ip
+	svc.resolved = ip
+	svc.mu.Unlock()
+	if changed {
+		svc.appendLog(fmt.Sprintf("Resolved %s to %s (%s)", host, ip, via), false)
+	}
+	return pinned, true
+}
@@ -0,0 +1,103 @@
+package manager
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// serveDNS answers every A query on a local TCP port with ip, and returns the
+// port's address.
+func serveDNS(t *testing.T, ip net.IP) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					var size [2]byte
+					if _, err := io.ReadFull(conn, size[:]); err != nil {
+						return
+					}
+					query := make([]byte, binary.BigEndian.Uint16(size[:]))
+					if _, err := io.ReadFull(conn, query); err != nil || len(query) < 12 {
+						return
+					}
+					// The header with one question and one answer, the
+					// question as asked (without the query's EDNS record),
+					// and the answer pointing at its name.
+					end := 12
+					for end < len(query) && query[end] != 0 {
+						end += int(query[end]) + 1
+					}
+					end = min(end+5, len(query))
+					reply := append([]byte{query[0], query[1], 0x81, 0x80, 0, 1, 0, 1, 0, 0, 0, 0}, query[12:end]...)
+					reply = append(reply, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+					reply = append(reply, ip.To4()...)
+					conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(reply))))
+					conn.Write(reply)
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestLookupIPv4AtAsksOverTCP(t *testing.T) {
+	server := serveDNS(t, net.IPv4(10, 0, 0, 5))
+	addrs, err := lookupIPv4At(context.Background(), server, "db.internal")
+	if err != nil || len(addrs) != 1 || addrs[0] != "10.0.0.5" {
+		t.Fatalf("lookupIPv4At = %v, %v; want [10.0.0.5]", addrs, err)
+	}
+}
+
+func TestResolveForwardPointsTheTunnelAtTheAnswer(t *testing.T) {
+	defer func(lookup func(context.Context, string, string) ([]string, error)) { lookupAt = lookup }(lookupAt)
+	answer, fail := "10.0.0.5", error(nil)
+	lookupAt = func(_ context.Context, server, host string) ([]string, error) {
+		if server != "127.0.0.1:5353" || host != "db.internal" {
+			t.Errorf("asked %s for %s", server, host)
+		}
+		return []string{answer}, fail
+	}
+	svc := &runningService{name: "db", logs: newLogRing(10)}
+	dns := storage.DNS{Resolver: storage.DNSServer, Server: "127.0.0.1:5353"}
+
+	for range 2 {
+		got, ok := resolveForward(context.Background(), svc, "ssh -N -L 5432:db.internal:5432 bastion", dns)
+		if !ok || got != "ssh -N -L 5432:10.0.0.5:5432 bastion" {
+			t.Fatalf("resolveForward = %q, %v", got, ok)
+		}
+	}
+	if logs := svc.snapshot().Logs; len(logs) != 1 || logs[0].Message != "Resolved db.internal to 10.0.0.5 (via 127.0.0.1:5353)" {
+		t.Errorf("want the address logged once, got %+v", logs)
+	}
+
+	fail = errors.New("connection refused")
+	if _, ok := resolveForward(context.Background(), svc, "ssh -N -L 5432:db.internal:5432 bastion", dns); ok {
+		t.Fatal("a failed lookup should not start the tunnel")
+	}
+	if s := svc.snapshot(); !strings.Contains(s.LastError, "db.internal did not resolve at 127.0.0.1:5353: connection refused") {
+		t.Errorf("last error = %q", s.LastError)
+	}
+
+	pinned, ok := resolveForward(context.Background(), svc, "ssh -fNL 5432:db.internal:5432 bastion", storage.DNS{Resolver: storage.DNSPin, IP: "10.9.9.9"})
+	if !ok || pinned != "ssh -fNL 5432:10.9.9.9:5432 bastion" {
+		t.Errorf("pinned = %q, %v", pinned, ok)
+	}
+}
@@ -0,0 +1,109 @@
+package manager
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// errorSummaryEvery is how often the stderr log says that a service still
+// fails with the error it last wrote.
+const errorSummaryEvery = 5 * time.Minute
+
+// errorLog writes services' errors to stderr when PF_STDERR is set, for a
+// session whose stderr goes to a file or to the system log, as under
+// systemd. A service that stays down fails with the same error on every
+// reconnect, every few seconds; the log writes it once, then only counts it,
+// writing "still failing (xN)" every errorSummaryEvery and the final count
+// once the error changes or the service recovers. The zero value writes to
+// os.Stderr.
+type errorLog struct {
+	mu      sync.Mutex
+	w       io.Writer        // nil = os.Stderr
+	now     func() time.Time // nil = time.Now
+	failing map[string]*repeatedError
+}
+
+// klogHeader is the prefix kubectl puts on its log lines, e.g.
+// "E0301 14:00:01.123456   4242 portforward.go:413] ", which differs on every
+// repeat of an error.
+var klogHeader = regexp.MustCompile(`^[IWEF]\d{4} \d{2}:\d{2}:\d{2}\.\d+\s+\d+ [^\]]*\] `)
+
+// errorKey is what tells two errors apart: message without its klog header.
+func errorKey(message string) string {
+	return klogHeader.ReplaceAllString(message, "")
+}
+
+// repeatedError is the error a service last failed with.
+type repeatedError struct {
+	key     string    // see errorKey
+	message string    // as last seen
+	times   int       // in a row
+	unsaid  int       // of them since the last line about it
+	said    time.Time // when that line was written
+}
+
+// error logs that service failed with message.
+func (l *errorLog) error(service, message string) {
+	if !isStderrLoggingEnabled() {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock()
+	key := errorKey(message)
+	if r := l.failing[service]; r != nil && r.key == key {
+		r.message = message
+		r.times++
+		r.unsaid++
+		if now.Sub(r.said) >= errorSummaryEvery {
+			l.printf("[%s] ERROR: still failing (x%d): %s\n", service, r.times, message)
+			r.unsaid, r.said = 0, now
+		}
+		return
+	}
+	l.settle(service)
+	l.printf("[%s] ERROR: %s\n", service, message)
+	if l.failing == nil {
+		l.failing = make(map[string]*repeatedError)
+	}
+	l.failing[service] = &repeatedError{key: key, message: message, times: 1, said: now}
+}
+
+// recovered logs that service is healthy again.
+func (l *errorLog) recovered(service string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.settle(service)
+}
+
+// settle writes the final count of the error service last failed with, if
+// repeats went unsaid, and forgets it.
+func (l *errorLog) settle(service string) {
+	r := l.failing[service]
+	if r == nil {
+		return
+	}
+	if r.unsaid > 0 {
+		l.printf("[%s] ERROR: failed %d times in a row: %s\n", service, r.times, r.message)
+	}
+	delete(l.failing, service)
+}
+
+func (l *errorLog) printf(format string, args ...any) {
+	w := l.w
+	if w == nil {
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, format, args...)
+}
+
+func (l *errorLog) clock() time.Time {
+	if l.now == nil {
+		return time.Now()
+	}
+	return l.now()
+}
@@ -0,0 +1,49 @@
+package manager
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorLogCountsRepeatedErrors(t *testing.T) {
+	t.Setenv("PF_STDERR", "1")
+	var out bytes.Buffer
+	now := time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC)
+	l := errorLog{w: &out, now: func() time.Time { return now }}
+
+	// Down for 10 minutes, failing every 2 seconds, with kubectl's headers.
+	for i := 0; i < 300; i++ {
+		stamp := now.Format("0102 15:04:05.000000")
+		l.error("db", "E"+stamp+"   4242 portforward.go:413] lost connection to pod")
+		now = now.Add(2 * time.Second)
+	}
+	l.error("api", "Start failed: exec: kubectl: not found")
+	l.error("db", "Start failed: exec: kubectl: not found")
+	l.recovered("db")
+	l.recovered("api") // said nothing since its only line
+
+	// Each line shows the error as last seen.
+	want := strings.Join([]string{
+		"[db] ERROR: E0301 14:00:00.000000   4242 portforward.go:413] lost connection to pod",
+		"[db] ERROR: still failing (x151): E0301 14:05:00.000000   4242 portforward.go:413] lost connection to pod",
+		"[api] ERROR: Start failed: exec: kubectl: not found",
+		"[db] ERROR: failed 300 times in a row: E0301 14:09:58.000000   4242 portforward.go:413] lost connection to pod",
+		"[db] ERROR: Start failed: exec: kubectl: not found",
+	}, "\n") + "\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestErrorLogIsOffWithoutPFStderr(t *testing.T) {
+	t.Setenv("PF_STDERR", "")
+	var out bytes.Buffer
+	l := errorLog{w: &out}
+	l.error("db", "lost connection to pod")
+	l.recovered("db")
+	if out.Len() != 0 {
+		t.Errorf("wrote %q", out.String())
+	}
+}
@@ -0,0 +1,46 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/events"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestManagerPublishesSessionEvents(t *testing.T) {
+	m, _ := newFakeManager(t, "db")
+	sub := m.Events().Subscribe(0, events.ServiceStarted, events.ServiceStopped, events.ServiceStatus)
+	defer sub.Close()
+
+	if err := m.StartService(context.Background(), "db"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, m, "became healthy", func(s model.Service) bool { return s.Status == model.StatusHealthy })
+	m.StopService("db")
+
+	var kinds []events.Kind
+	healthy := false
+	timeout := time.After(3 * time.Second)
+	for len(kinds) == 0 || kinds[len(kinds)-1] != events.ServiceStopped {
+		select {
+		case e := <-sub.C:
+			if e.Service != "db" {
+				t.Errorf("event for %q, want db", e.Service)
+			}
+			if e.Kind == events.ServiceStatus && e.To == model.StatusHealthy {
+				healthy = true
+			}
+			kinds = append(kinds, e.Kind)
+		case <-timeout:
+			t.Fatalf("no stop event; got %v", kinds)
+		}
+	}
+	if kinds[0] != events.ServiceStarted {
+		t.Errorf("first event = %v, want ServiceStarted", kinds[0])
+	}
+	if !healthy {
+		t.Errorf("no status event to healthy in %v", kinds)
+	}
+}
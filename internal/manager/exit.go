@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// exitStderrLines is how many of a process's last stderr lines its exit
+// keeps.
+const exitStderrLines = 8
+
+// outputDrainTimeout bounds the wait for a process's output after it exited.
+// Its pipes only close once every child it left behind has exited too.
+const outputDrainTimeout = 500 * time.Millisecond
+
+// drainOutput waits for the output readers of an exited process to reach
+// the end of its pipes, so its exit sees what it printed last.
+func drainOutput(streams *sync.WaitGroup) {
+	drained := make(chan struct{})
+	go func() {
+		streams.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(outputDrainTimeout):
+	}
+}
+
+// rememberStderr keeps line among the last exitStderrLines the current
+// process printed on stderr.
+func (s *runningService) rememberStderr(line string) {
+	s.mu.Lock()
+	s.stderrTail = append(s.stderrTail, line)
+	if len(s.stderrTail) > exitStderrLines {
+		s.stderrTail = s.stderrTail[len(s.stderrTail)-exitStderrLines:]
+	}
+	s.mu.Unlock()
+}
+
+// newExit describes a process that ended in state after running for ran,
+// with stderr its last lines there. state is nil if waiting for it failed.
+func newExit(state *os.ProcessState, ran time.Duration, stderr []string) model.Exit {
+	exit := model.Exit{Code: -1, At: time.Now(), Ran: ran, Stderr: stderr}
+	if state == nil {
+		return exit
+	}
+	exit.Code = state.ExitCode()
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		exit.Signal = ws.Signal().String()
+	}
+	return exit
+}
+
+// setExit records how the process died and puts the service in error, with
+// the reason and its last stderr line as the error.
+func (s *runningService) setExit(exit model.Exit) {
+	message := "Process died: " + exit.Reason()
+	if n := len(exit.Stderr); n > 0 {
+		message += ": " + normalizeErrorLine(exit.Stderr[n-1])
+	}
+
+	s.mu.Lock()
+	exit.Hint = s.hint
+	s.lastExit = &exit
+	s.lastError = message
+	s.setStatusLocked(model.StatusError)
+	s.mu.Unlock()
+
+	s.changed()
+}
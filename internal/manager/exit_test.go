@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/hints"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestRunRecordsHowTheProcessDied(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a Unix shell")
+	}
+	svc := &runningService{name: "db", status: model.StatusConnecting,
+		command: "echo starting; for i in 1 2 3 4 5 6 7 8 9 10; do echo line $i >&2; done; exit 3"}
+	(&ServiceManager{}).runServiceOnce(context.Background(), svc)
+
+	got := svc.snapshot()
+	if got.Status != model.StatusError || got.LastExit == nil {
+		t.Fatalf("status %q, exit %+v", got.Status, got.LastExit)
+	}
+	exit := got.LastExit
+	if exit.Reason() != "exit 3" || exit.Ran <= 0 {
+		t.Errorf("exit = %+v", exit)
+	}
+	if len(exit.Stderr) != exitStderrLines || exit.Stderr[0] != "line 3" || exit.Stderr[exitStderrLines-1] != "line 10" {
+		t.Errorf("stderr tail = %q", exit.Stderr)
+	}
+	if got.LastError != "Process died: exit 3: line 10" {
+		t.Errorf("last error = %q", got.LastError)
+	}
+
+	svc.markHealthy()
+	if svc.snapshot().LastExit != nil {
+		t.Error("a healthy service should drop its last exit")
+	}
+}
+
+func TestExitBySignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs Unix signals")
+	}
+	cmd := newShellCommand("kill -KILL $$")
+	_ = cmd.Run()
+	exit := newExit(cmd.ProcessState, 0, nil)
+	if exit.Code != -1 || !strings.HasPrefix(exit.Reason(), "signal: killed") {
+		t.Errorf("exit = %+v, reason %q", exit, exit.Reason())
+	}
+}
+
+func TestErrorsGetHints(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a Unix shell")
+	}
+	svc := &runningService{name: "bastion", status: model.StatusConnecting,
+		command: "for i in 1 2; do echo 'git@bastion: Permission denied (publickey).' >&2; done; exit 255"}
+	(&ServiceManager{hints: hints.Builtin()}).runServiceOnce(context.Background(), svc)
+
+	got := svc.snapshot()
+	var logged []string
+	for _, e := range got.Logs {
+		if e.Kind == model.LogKindHint {
+			logged = append(logged, e.Message)
+		}
+	}
+	if len(logged) != 1 || !strings.HasPrefix(logged[0], "Hint: ssh rejected your key") {
+		t.Errorf("hints in the log = %q, want one", logged)
+	}
+	if got.LastExit == nil || !strings.HasPrefix(got.LastExit.Hint, "ssh rejected your key") {
+		t.Errorf("exit = %+v", got.LastExit)
+	}
+}
@@ -0,0 +1,76 @@
+package manager
+
+import (
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// Flap detection defaults: a service that drops into error this many times
+// within the window is flapping.
+const (
+	defaultFlapErrors = 3
+	defaultFlapWindow = 5 * time.Minute
+)
+
+// flapDetector remembers when a service last fell into error. Only the most
+// recent `errors` drops matter: if they all fall within `window`, the service
+// is flapping until the oldest of them ages out of the window.
+type flapDetector struct {
+	errors int
+	window time.Duration
+	drops  []time.Time // oldest first, at most errors long
+}
+
+func newFlapDetector(errors int, window time.Duration) *flapDetector {
+	return &flapDetector{errors: errors, window: window}
+}
+
+// flapSettings reads the config's flap thresholds, with the defaults for
+// those it leaves out.
+func flapSettings(st *storage.Storage) (int, time.Duration, error) {
+	errors, window, err := st.FlapSettings()
+	if err != nil {
+		return 0, 0, err
+	}
+	if errors == 0 {
+		errors = defaultFlapErrors
+	}
+	if window <= 0 {
+		window = defaultFlapWindow
+	}
+	return errors, window, nil
+}
+
+// reset applies new thresholds, keeping the drops seen so far.
+func (f *flapDetector) reset(errors int, window time.Duration) {
+	f.errors, f.window = errors, window
+	if errors > 0 && len(f.drops) > errors {
+		f.drops = f.drops[len(f.drops)-errors:]
+	}
+}
+
+// record notes a drop into error at t.
+func (f *flapDetector) record(t time.Time) {
+	if f == nil || f.errors <= 0 {
+		return
+	}
+	f.drops = append(f.drops, t)
+	if len(f.drops) > f.errors {
+		f.drops = f.drops[len(f.drops)-f.errors:]
+	}
+}
+
+// until reports when the service stops counting as flapping; zero if it is
+// not flapping at all. Frontends compare it with the clock, so the badge
+// clears on time without a state change.
+func (f *flapDetector) until() time.Time {
+	if f == nil || f.errors <= 0 || len(f.drops) < f.errors {
+		return time.Time{}
+	}
+	oldest, newest := f.drops[0], f.drops[len(f.drops)-1]
+	if newest.Sub(oldest) >= f.window {
+		return time.Time{}
+	}
+	return oldest.Add(f.window)
+}
@@ -0,0 +1,73 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+func TestFlapDetector(t *testing.T) {
+	f := newFlapDetector(3, 5*time.Minute)
+	t0 := time.Now()
+
+	f.record(t0)
+	f.record(t0.Add(time.Minute))
+	if !f.until().IsZero() {
+		t.Fatal("two drops should not count as flapping with a threshold of three")
+	}
+
+	f.record(t0.Add(2 * time.Minute))
+	if got, want := f.until(), t0.Add(5*time.Minute); !got.Equal(want) {
+		t.Fatalf("until() = %v, want %v", got, want)
+	}
+
+	// Spread-out drops: the last three span more than the window.
+	f.record(t0.Add(10 * time.Minute))
+	f.record(t0.Add(20 * time.Minute))
+	if !f.until().IsZero() {
+		t.Error("drops spread over more than the window are not flapping")
+	}
+}
+
+func TestReloadSettingsAppliesFlapThresholds(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	st := storage.NewStorage()
+	svc := &runningService{name: "db", flaps: newFlapDetector(defaultFlapErrors, defaultFlapWindow)}
+	m := &ServiceManager{services: map[string]*runningService{"db": svc}, storage: st, updates: make(chan struct{}, 1)}
+
+	t0 := time.Now()
+	svc.flaps.record(t0)
+	svc.flaps.record(t0.Add(time.Second))
+	if !svc.flaps.until().IsZero() {
+		t.Fatal("two drops are not flapping by default")
+	}
+
+	data, err := st.LoadData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.Flap = &storage.FlapConfig{Errors: 2, Window: "1m"}
+	if err := st.SaveData(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ReloadSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := svc.flaps.until(), t0.Add(time.Minute); !got.Equal(want) {
+		t.Errorf("after reload until() = %v, want %v", got, want)
+	}
+	if m.flapErrors != 2 || m.flapWindow != time.Minute {
+		t.Errorf("new services get %d in %v, want 2 in 1m", m.flapErrors, m.flapWindow)
+	}
+
+	data.Flap.Window = "soon"
+	if err := st.SaveData(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ReloadSettings(); err == nil || m.flapWindow != time.Minute {
+		t.Errorf("an invalid config should be rejected and change nothing, got %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// FollowTargets keeps the session in step with what it was started for, like
+// a small reconciler: every configPollInterval until ctx ends it asks resolve
+// for the services the run targets name now (a group's members, or every
+// saved service for "all"), starts the ones added since and stops the ones
+// dropped. started are the services the session began with. Services resolve
+// never named, such as ad-hoc ones from stdin, are left alone, and an error
+// from resolve (a config mid-edit, a deleted group) keeps what runs.
+func (m *ServiceManager) FollowTargets(ctx context.Context, started []string, resolve func() ([]string, error)) {
+	wanted := append([]string(nil), started...)
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		names, err := resolve()
+		if err != nil {
+			continue
+		}
+		added, removed := diffTargets(wanted, names)
+		for _, name := range removed {
+			m.StopService(name)
+		}
+		for _, name := range added {
+			go m.StartService(ctx, name)
+		}
+		wanted = names
+	}
+}
+
+// diffTargets returns the names in now but not in before, and the other way
+// round, each in the order given.
+func diffTargets(before, now []string) (added, removed []string) {
+	for _, name := range now {
+		if !slices.Contains(before, name) {
+			added = append(added, name)
+		}
+	}
+	for _, name := range before {
+		if !slices.Contains(now, name) {
+			removed = append(removed, name)
+		}
+	}
+	return added, removed
+}
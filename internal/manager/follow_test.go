@@ -0,0 +1,19 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffTargets(t *testing.T) {
+	added, removed := diffTargets([]string{"api", "db", "cache"}, []string{"db", "search", "api", "queue"})
+	if strings.Join(added, ",") != "search,queue" {
+		t.Errorf("added = %v", added)
+	}
+	if strings.Join(removed, ",") != "cache" {
+		t.Errorf("removed = %v", removed)
+	}
+	if added, removed := diffTargets([]string{"db"}, []string{"db"}); added != nil || removed != nil {
+		t.Errorf("no change should diff to nothing, got %v, %v", added, removed)
+	}
+}
@@ -0,0 +1,47 @@
+package manager
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// TrackGroup records that group name was started in this session, so
+// GroupStates reports its combined status. Members that stop later hold the
+// group in error rather than dropping out of it.
+func (m *ServiceManager) TrackGroup(name string, members []string) {
+	m.mu.Lock()
+	if current, ok := m.groups[name]; ok && slices.Equal(current, members) {
+		m.mu.Unlock()
+		return
+	}
+	if m.groups == nil {
+		m.groups = make(map[string][]string)
+	}
+	m.groups[name] = append([]string(nil), members...)
+	m.mu.Unlock()
+	m.notify()
+}
+
+// GroupStates returns the combined status of every tracked group, sorted by
+// name.
+func (m *ServiceManager) GroupStates() []model.GroupState {
+	m.mu.RLock()
+	groups := make(map[string][]string, len(m.groups))
+	for name, members := range m.groups {
+		groups[name] = members
+	}
+	m.mu.RUnlock()
+	if len(groups) == 0 {
+		return nil
+	}
+
+	services := m.ListServiceStates()
+	states := make([]model.GroupState, 0, len(groups))
+	for name, members := range groups {
+		states = append(states, model.NewGroupState(name, members, services))
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
@@ -0,0 +1,37 @@
+package manager
+
+import (
+	"github.com/alinemone/go-port-forward/internal/hints"
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// loadHints reads the user's hint rules, which go before pf's own.
+func loadHints(st *storage.Storage) (*hints.Set, error) {
+	return hints.Load(st.HintsFile())
+}
+
+func (m *ServiceManager) hintFor(line string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.hints.Hint(line)
+}
+
+// logHint puts hint in the log under the error it is for, once per run of
+// the process however often the error repeats, and keeps it for the exit.
+func (s *runningService) logHint(hint string) {
+	s.mu.Lock()
+	if s.hinted[hint] {
+		s.mu.Unlock()
+		return
+	}
+	if s.hinted == nil {
+		s.hinted = make(map[string]bool)
+	}
+	s.hinted[hint] = true
+	s.hint = hint
+	s.pushLogLocked(model.LogEntry{Kind: model.LogKindHint, Message: "Hint: " + hint})
+	s.mu.Unlock()
+
+	s.changed()
+}
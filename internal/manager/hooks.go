@@ -0,0 +1,137 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// preConnectOutput is how many of its last output lines a failed pre-connect
+// command logs.
+const preConnectOutput = 5
+
+// runPreConnect runs svc's pre-connect command, if it has one, before a
+// connection attempt. It reports whether the attempt goes ahead: a command
+// that fails or outlives its timeout fails the attempt, which counts as a
+// reconnect and marks the service as in error, unless the service is set to
+// continue anyway. It also reports false when ctx ends. A service with a
+// one-time code generator hands the command a code as $PF_OTP.
+func runPreConnect(ctx context.Context, svc *runningService) bool {
+	svc.mu.RLock()
+	p, otp := svc.preConnect, svc.otp
+	svc.mu.RUnlock()
+	if p == nil {
+		return true
+	}
+	timeout, _ := p.TimeoutDuration() // checked when the service started
+	var env []string
+	if otp != nil {
+		code, err := otp.next(ctx)
+		if err != nil {
+			return false // ended while waiting for a fresh code
+		}
+		env = append(env, "PF_OTP="+code)
+	}
+
+	output, err := runBounded(ctx, p.Command, env, timeout)
+	if ctx.Err() != nil {
+		return false
+	}
+	if err == nil {
+		return true
+	}
+	if p.Continue() {
+		svc.appendLog(fmt.Sprintf("Pre-connect command failed, connecting anyway: %v", err), false)
+		return true
+	}
+	for _, line := range output[max(len(output)-preConnectOutput, 0):] {
+		svc.appendLog(line, true)
+	}
+	message := fmt.Sprintf("Pre-connect command failed: %v", err)
+	svc.appendLog(message, true)
+	if len(output) > 0 {
+		message += ": " + output[len(output)-1]
+	}
+	svc.mu.Lock()
+	svc.lastRunStable = false
+	svc.mu.Unlock()
+	svc.setError(message)
+	svc.countReconnect(model.CausePreConnect)
+	return false
+}
+
+// runPostConnect runs a service's post-connect command, once it is first
+// healthy in the session, logging what it prints and how it ended. A failure
+// leaves the forward alone. ctx ends with the service.
+func runPostConnect(ctx context.Context, svc *runningService, p storage.PostConnect) {
+	timeout, _ := p.TimeoutDuration() // checked when the service started
+	svc.mu.RLock()
+	env := []string{"PF_SERVICE=" + svc.name, "PF_LOCAL_PORT=" + svc.localPort}
+	svc.mu.RUnlock()
+
+	svc.appendLog("Running post-connect command: "+p.Command, false)
+	start := time.Now()
+	output, err := runBounded(ctx, p.Command, env, timeout)
+	for _, line := range output {
+		svc.appendLog(line, false)
+	}
+	switch {
+	case ctx.Err() != nil:
+		// Stopped meanwhile: nobody is left to read the log.
+	case err != nil:
+		svc.appendLog(fmt.Sprintf("Post-connect command failed: %v", err), true)
+	default:
+		svc.appendLog(fmt.Sprintf("Post-connect command done in %s", time.Since(start).Round(time.Millisecond)), false)
+	}
+}
+
+// runBounded runs command in the shell, with env added to pf's environment,
+// killing its process tree once timeout passes or ctx ends, and returns the
+// lines it printed.
+func runBounded(ctx context.Context, command string, env []string, timeout time.Duration) ([]string, error) {
+	var out strings.Builder
+	cmd := newShellCommand(command)
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stdout, cmd.Stderr = &out, &out
+	// A daemon it leaves behind must not hold the attempt up by keeping
+	// the output open.
+	cmd.WaitDelay = time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	var err error
+	select {
+	case err = <-exited:
+	case <-ctx.Done():
+		killProcessTree(cmd.Process)
+		<-exited
+		err = ctx.Err()
+	case <-time.After(timeout):
+		killProcessTree(cmd.Process)
+		<-exited
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	if errors.Is(err, exec.ErrWaitDelay) {
+		err = nil // it exited; only what it started lives on
+	}
+
+	var lines []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, err
+}
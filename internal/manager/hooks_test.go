@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+func TestRunPreConnect(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	svc := &runningService{name: "db", logs: newLogRing(maxLogEntries), status: model.StatusConnecting}
+	if !runPreConnect(context.Background(), svc) {
+		t.Error("a service without a pre-connect command should connect")
+	}
+
+	svc.preConnect = &storage.PreConnect{Command: "echo knocking; exit 0"}
+	if !runPreConnect(context.Background(), svc) {
+		t.Error("a passing pre-connect command should let the service connect")
+	}
+
+	svc.preConnect = &storage.PreConnect{Command: "echo 'token expired' >&2; exit 2"}
+	if runPreConnect(context.Background(), svc) {
+		t.Error("a failing pre-connect command should hold the attempt back")
+	}
+	snap := svc.snapshot()
+	if snap.Status != model.StatusError || snap.LastError != "Pre-connect command failed: exit status 2: token expired" {
+		t.Errorf("status = %s, error = %q", snap.Status, snap.LastError)
+	}
+	if snap.Reconnects[model.CausePreConnect] != 1 {
+		t.Errorf("reconnects = %v, want one pre-connect failure", snap.Reconnects)
+	}
+
+	svc.preConnect = &storage.PreConnect{Command: "sleep 10", Timeout: "100ms", OnFailure: "continue"}
+	start := time.Now()
+	if !runPreConnect(context.Background(), svc) {
+		t.Error("onFailure continue should connect anyway")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the timeout took %v to apply", elapsed)
+	}
+	var logged []string
+	for _, e := range svc.logs.Snapshot() {
+		logged = append(logged, e.Message)
+	}
+	if last := logged[len(logged)-1]; !strings.Contains(last, "connecting anyway: timed out after 100ms") {
+		t.Errorf("log = %q", logged)
+	}
+}
+
+func TestPostConnectRunsOnceWhenFirstHealthy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	marker := filepath.Join(t.TempDir(), "runs")
+	svc := &runningService{name: "db", localPort: "5432", logs: newLogRing(maxLogEntries), status: model.StatusConnecting}
+	p := storage.PostConnect{Command: `echo "migrating $PF_SERVICE on $PF_LOCAL_PORT"; echo run >> ` + marker}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc.postConnect = sync.OnceFunc(func() { go runPostConnect(ctx, svc, p) })
+
+	svc.markHealthy()
+	svc.setError("connection lost")
+	svc.markHealthy() // healthy again after a reconnect: not a second run
+
+	deadline := time.Now().Add(5 * time.Second)
+	var logged []string
+	for time.Now().Before(deadline) {
+		logged = logged[:0]
+		svc.mu.RLock()
+		for _, e := range svc.logs.Snapshot() {
+			logged = append(logged, e.Message)
+		}
+		svc.mu.RUnlock()
+		if strings.HasPrefix(logged[len(logged)-1], "Post-connect command done") {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !slices.Contains(logged, "migrating db on 5432") {
+		t.Errorf("log = %q, want the command's output", logged)
+	}
+	if runs, _ := os.ReadFile(marker); string(runs) != "run\n" {
+		t.Errorf("the command ran %q, want once", runs)
+	}
+}
@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"context"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// sshConfigTimeout bounds asking ssh for its configuration.
+const sshConfigTimeout = 5 * time.Second
+
+// withKeepalive adds the service's connection options (see
+// storage.Keepalive) to command: ssh options to its ssh invocations, except
+// those ssh_config already sets for the destination, since pf's would
+// override them (ssh prefers the command line); kubectl flags to a kubectl
+// port-forward.
+func withKeepalive(ctx context.Context, command string, k storage.Keepalive) string {
+	if k.Off {
+		return command
+	}
+	options, _ := k.SSHOptions() // checked when the service started
+	if host := cmdline.SSHDestination(command); host != "" {
+		configured := sshConfigOptions(ctx, host)
+		options = slices.DeleteFunc(options, func(opt string) bool {
+			name, _, _ := strings.Cut(opt, "=")
+			return configured[strings.ToLower(name)]
+		})
+		command = cmdline.AddSSHOptions(command, options)
+	}
+	flags, _ := k.KubectlFlags()
+	return cmdline.AddPortForwardFlags(command, flags)
+}
+
+// sshConfigOptions returns the keepalive options ssh_config sets for host, by
+// lower-cased name. It asks ssh -G, which resolves the config without
+// connecting; an ssh that cannot tell sets none. ssh -G always reports a
+// ServerAliveCountMax, so it counts as set along with ServerAliveInterval. A
+// var so tests can stub it.
+var sshConfigOptions = func(ctx context.Context, host string) map[string]bool {
+	ctx, cancel := context.WithTimeout(ctx, sshConfigTimeout)
+	defer cancel()
+	set := map[string]bool{}
+	out, err := exec.CommandContext(ctx, "ssh", "-G", host).Output()
+	if err != nil {
+		return set
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch {
+		case key == "serveraliveinterval" && value != "0":
+			set["serveraliveinterval"], set["serveralivecountmax"] = true, true
+		case key == "connecttimeout" && value != "none":
+			set["connecttimeout"] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,37 @@
+package manager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+func TestWithKeepalive(t *testing.T) {
+	orig := sshConfigOptions
+	defer func() { sshConfigOptions = orig }()
+	sshConfigOptions = func(_ context.Context, host string) map[string]bool {
+		if host == "tuned" {
+			return map[string]bool{"serveraliveinterval": true, "serveralivecountmax": true}
+		}
+		return map[string]bool{}
+	}
+
+	const all = " -o ServerAliveInterval=15 -o ServerAliveCountMax=3 -o ConnectTimeout=10"
+	for _, c := range []struct {
+		command string
+		k       storage.Keepalive
+		want    string
+	}{
+		{"ssh -N -L 5432:db:5432 bastion", storage.Keepalive{}, "ssh" + all + " -N -L 5432:db:5432 bastion"},
+		{"ssh -N -L 5432:db:5432 tuned", storage.Keepalive{}, "ssh -o ConnectTimeout=10 -N -L 5432:db:5432 tuned"},
+		{"ssh -N -L 5432:db:5432 bastion", storage.Keepalive{Off: true}, "ssh -N -L 5432:db:5432 bastion"},
+		{"autossh -M 0 -N -L 5432:db:5432 bastion", storage.Keepalive{}, "autossh -M 0 -N -L 5432:db:5432 bastion"},
+		{"kubectl port-forward svc/db 5432:5432", storage.Keepalive{}, "kubectl port-forward svc/db 5432:5432"},
+		{"kubectl port-forward svc/db 5432:5432", storage.Keepalive{PodRunningTimeout: "20s"}, "kubectl port-forward --pod-running-timeout=20s svc/db 5432:5432"},
+	} {
+		if got := withKeepalive(context.Background(), c.command, c.k); got != c.want {
+			t.Errorf("withKeepalive(%q, %+v)\n = %q\nwant %q", c.command, c.k, got, c.want)
+		}
+	}
+}
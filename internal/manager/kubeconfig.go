@@ -0,0 +1,159 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// kubectl writes its kubeconfig under a lock file next to it, and gives up at
+// once when another kubectl holds that lock, as several starting together
+// do. pf lets one kubectl at a time start per kubeconfig, and when one still
+// finds the file locked (another tool's kubectl, say) it tries again soon,
+// instead of after the usual backoff.
+var (
+	// kubeconfigStartHold is the longest a starting kubectl keeps the others
+	// of its kubeconfig waiting; it normally lets them go at its first line
+	// of output, when it has read the file and written it back.
+	kubeconfigStartHold = 10 * time.Second
+	// kubeconfigLockBackoff paces the retries after a locked kubeconfig,
+	// with a lot of jitter so that services that collided once do not collide
+	// again.
+	kubeconfigLockBackoff = Backoff{Base: 300 * time.Millisecond, Max: 5 * time.Second, Jitter: 0.5}
+	// kubeconfigLockRetries is how many of those retries come in a row before
+	// a service backs off as after any failure: a lock file a crashed kubectl
+	// left behind stays.
+	kubeconfigLockRetries = 8
+)
+
+// kubeconfigLockPattern matches kubectl failing to take the lock, e.g.
+// "error: open /home/me/.kube/config.lock: file exists", or the sharing
+// violation Windows reports instead.
+var kubeconfigLockPattern = regexp.MustCompile(`(?i)\.lock: (file exists|the process cannot access the file)`)
+
+func isKubeconfigLockError(line string) bool {
+	return kubeconfigLockPattern.MatchString(line)
+}
+
+// kubeconfigFor is the kubeconfig a kubectl command reads: its --kubeconfig,
+// else $KUBECONFIG, else ~/.kube/config. "" when command does not run
+// kubectl.
+func kubeconfigFor(command string) string {
+	fields := strings.Fields(command)
+	i := slices.IndexFunc(fields, func(f string) bool {
+		base := strings.ToLower(filepath.Base(strings.Trim(f, `"'`)))
+		return base == "kubectl" || base == "kubectl.exe"
+	})
+	if i < 0 {
+		return ""
+	}
+	for k := i + 1; k < len(fields); k++ {
+		if v, ok := strings.CutPrefix(fields[k], "--kubeconfig="); ok {
+			return filepath.Clean(strings.Trim(v, `"'`))
+		}
+		if fields[k] == "--kubeconfig" && k+1 < len(fields) {
+			return filepath.Clean(strings.Trim(fields[k+1], `"'`))
+		}
+	}
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		return env
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "kubeconfig"
+	}
+	return filepath.Join(home, ".kube", "config")
+}
+
+// startGates lets one process at a time start per key; see kubeconfigFor.
+// The zero value is ready to use.
+type startGates struct {
+	mu    sync.Mutex
+	gates map[string]chan struct{}
+}
+
+// kubeconfigGates are the session's gates, one per kubeconfig.
+var kubeconfigGates startGates
+
+// enter waits for key's gate, or for ctx to end, in which case it returns
+// false. The caller lets the next one in with the func it returns, which may
+// be called more than once.
+func (g *startGates) enter(ctx context.Context, key string) (leave func(), ok bool) {
+	g.mu.Lock()
+	if g.gates == nil {
+		g.gates = make(map[string]chan struct{})
+	}
+	gate, exists := g.gates[key]
+	if !exists {
+		gate = make(chan struct{}, 1)
+		g.gates[key] = gate
+	}
+	g.mu.Unlock()
+	select {
+	case gate <- struct{}{}:
+		return sync.OnceFunc(func() { <-gate }), true
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// holdStartGate keeps leave to be called at the service's first line of
+// output, or after kubeconfigStartHold, whichever comes first; runServiceOnce
+// calls it anyway once the process has ended.
+func (s *runningService) holdStartGate(leave func()) {
+	s.mu.Lock()
+	s.leaveStartGate = leave
+	s.mu.Unlock()
+	time.AfterFunc(kubeconfigStartHold, leave)
+}
+
+// openStartGate lets the next process of the service's kubeconfig start, if
+// this one still holds it.
+func (s *runningService) openStartGate() {
+	s.mu.Lock()
+	leave := s.leaveStartGate
+	s.leaveStartGate = nil
+	s.mu.Unlock()
+	if leave != nil {
+		leave()
+	}
+}
+
+// waitOutKubeconfigLock waits a little before the next run when the last one
+// found its kubeconfig locked, and reports whether it did: the loop then
+// reconnects without the usual backoff. It does not once the lock has been
+// found kubeconfigLockRetries times in a row.
+func waitOutKubeconfigLock(ctx context.Context, svc *runningService) bool {
+	locked := svc.kubeconfigLocked.Swap(false)
+	svc.mu.Lock()
+	if locked {
+		svc.lockRetries++
+	} else {
+		svc.lockRetries = 0
+	}
+	retries := svc.lockRetries
+	if retries > kubeconfigLockRetries {
+		svc.lockRetries = 0
+	}
+	svc.mu.Unlock()
+	if !locked || retries > kubeconfigLockRetries {
+		return false
+	}
+
+	delay := kubeconfigLockBackoff.Delay(retries)
+	svc.appendMarker(model.LogKindReconnect,
+		fmt.Sprintf("━━━━ KUBECONFIG locked by another kubectl, retrying in %.1fs ━━━━", delay.Seconds()))
+	select {
+	case <-ctx.Done():
+	case <-time.After(delay):
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package manager
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsKubeconfigLockError(t *testing.T) {
+	for line, want := range map[string]bool{
+		"error: open /home/me/.kube/config.lock: file exists":                                                true,
+		`error: open C:\Users\me\.kube\config.lock: The process cannot access the file because it is in use`: true,
+		"error: open /home/me/.kube/config: permission denied":                                               false,
+		"Forwarding from 127.0.0.1:5432 -> 5432":                                                             false,
+	} {
+		if got := isKubeconfigLockError(line); got != want {
+			t.Errorf("isKubeconfigLockError(%q) = %v, want %v", line, got, want)
+		}
+	}
+}
+
+func TestKubeconfigFor(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+	t.Setenv("HOME", "/home/me")
+	t.Setenv("USERPROFILE", "/home/me")
+	for command, want := range map[string]string{
+		"kubectl port-forward svc/db 5432:5432":                          filepath.Join("/home/me", ".kube", "config"),
+		"kubectl --kubeconfig /tmp/a.yaml port-forward svc/db 5432:5432": filepath.Clean("/tmp/a.yaml"),
+		"kubectl port-forward --kubeconfig=/tmp/b.yaml svc/db 5432:5432": filepath.Clean("/tmp/b.yaml"),
+		"ssh -N -L 5432:db:5432 bastion":                                 "",
+	} {
+		if got := kubeconfigFor(command); got != want {
+			t.Errorf("kubeconfigFor(%q) = %q, want %q", command, got, want)
+		}
+	}
+	t.Setenv("KUBECONFIG", "/etc/kube/ci")
+	if got := kubeconfigFor("kubectl port-forward svc/db 5432:5432"); got != "/etc/kube/ci" {
+		t.Errorf("with $KUBECONFIG: %q", got)
+	}
+}
+
+func TestStartGatesLetOneInPerKey(t *testing.T) {
+	var g startGates
+	leave, ok := g.enter(context.Background(), "a")
+	if !ok {
+		t.Fatal("an open gate should let in")
+	}
+	if other, ok := g.enter(context.Background(), "b"); !ok {
+		t.Fatal("another key's gate should not wait")
+	} else {
+		other()
+	}
+
+	entered := make(chan func())
+	go func() {
+		next, _ := g.enter(context.Background(), "a")
+		entered <- next
+	}()
+	select {
+	case <-entered:
+		t.Fatal("entered while the gate was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	leave()
+	leave() // a second call changes nothing
+	select {
+	case next := <-entered:
+		next()
+	case <-time.After(2 * time.Second):
+		t.Fatal("did not enter once the gate was left")
+	}
+
+	leave, _ = g.enter(context.Background(), "a")
+	defer leave()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := g.enter(ctx, "a"); ok {
+		t.Error("entered a held gate with an ended context")
+	}
+}
+
+func TestWaitOutKubeconfigLock(t *testing.T) {
+	defer func(b Backoff, n int) { kubeconfigLockBackoff, kubeconfigLockRetries = b, n }(kubeconfigLockBackoff, kubeconfigLockRetries)
+	kubeconfigLockBackoff = Backoff{Base: time.Millisecond, Max: time.Millisecond}
+	kubeconfigLockRetries = 2
+
+	svc := &runningService{name: "db", logs: newLogRing(10)}
+	if waitOutKubeconfigLock(context.Background(), svc) {
+		t.Fatal("waited after a run that did not find the kubeconfig locked")
+	}
+	for i := range 2 {
+		svc.kubeconfigLocked.Store(true)
+		if !waitOutKubeconfigLock(context.Background(), svc) {
+			t.Fatalf("retry %d should come soon", i+1)
+		}
+	}
+	svc.kubeconfigLocked.Store(true)
+	if waitOutKubeconfigLock(context.Background(), svc) {
+		t.Error("a lock that stays should get the usual backoff")
+	}
+	svc.kubeconfigLocked.Store(true)
+	if !waitOutKubeconfigLock(context.Background(), svc) {
+		t.Error("the count should start over after the usual backoff")
+	}
+}
@@ -0,0 +1,22 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// ulimitPrefix returns the shell commands that set l's memory and file limits
+// before a service's command runs in the same shell, so its child inherits
+// them. The command does not run at all if a limit cannot be set, e.g. one
+// above the hard limit.
+func ulimitPrefix(l storage.Limits) string {
+	prefix := ""
+	if memory, err := l.MemoryBytes(); err == nil && memory > 0 {
+		prefix += fmt.Sprintf("ulimit -d %d || exit 1; ", max(memory>>10, 1))
+	}
+	if l.Files > 0 {
+		prefix += fmt.Sprintf("ulimit -n %d || exit 1; ", l.Files)
+	}
+	return prefix
+}
@@ -0,0 +1,40 @@
+package manager
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+func TestUlimitPrefix(t *testing.T) {
+	if got := ulimitPrefix(storage.Limits{Nice: 5}); got != "" {
+		t.Errorf("nice alone needs no ulimit, got %q", got)
+	}
+	want := "ulimit -d 524288 || exit 1; ulimit -n 256 || exit 1; "
+	if got := ulimitPrefix(storage.Limits{Memory: "512M", Files: 256}); got != want {
+		t.Errorf("ulimitPrefix = %q, want %q", got, want)
+	}
+}
+
+func TestLimitedCommandAppliesLimits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("ulimit needs a Unix shell")
+	}
+	cmd := newLimitedCommand("ulimit -n; exec sleep 1", storage.Limits{Files: 64, Nice: 5})
+	var out strings.Builder
+	cmd.Stdout = &out
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyNice(cmd.Process, 5); err != nil {
+		t.Errorf("applyNice: %v", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "64" {
+		t.Errorf("open files limit = %q, want 64", got)
+	}
+}
@@ -0,0 +1,60 @@
+package manager
+
+import "github.com/alinemone/go-port-forward/internal/model"
+
+// maxLogEntries is how many log lines each service keeps in memory.
+const maxLogEntries = 120
+
+// logRing is a fixed-capacity ring buffer of log entries. Once full, each push
+// overwrites the oldest entry in place, so a chatty child process never causes
+// the backing array to be reallocated or resliced. The zero value is not
+// usable; construct it with newLogRing. Not safe for concurrent use — callers
+// hold runningService.mu.
+type logRing struct {
+	buf   []model.LogEntry
+	start int // index of the oldest entry
+	n     int // number of valid entries
+}
+
+func newLogRing(capacity int) *logRing {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &logRing{buf: make([]model.LogEntry, capacity)}
+}
+
+// Push appends entry, evicting the oldest one when the ring is full.
+func (r *logRing) Push(entry model.LogEntry) {
+	if r.n < len(r.buf) {
+		r.buf[(r.start+r.n)%len(r.buf)] = entry
+		r.n++
+		return
+	}
+	r.buf[r.start] = entry
+	r.start = (r.start + 1) % len(r.buf)
+}
+
+// Len reports how many entries the ring currently holds.
+func (r *logRing) Len() int {
+	return r.n
+}
+
+// Each calls fn for every entry from oldest to newest, stopping early if fn
+// returns false.
+func (r *logRing) Each(fn func(model.LogEntry) bool) {
+	for i := 0; i < r.n; i++ {
+		if !fn(r.buf[(r.start+i)%len(r.buf)]) {
+			return
+		}
+	}
+}
+
+// Snapshot returns a copy of the entries in chronological order.
+func (r *logRing) Snapshot() []model.LogEntry {
+	out := make([]model.LogEntry, 0, r.n)
+	r.Each(func(e model.LogEntry) bool {
+		out = append(out, e)
+		return true
+	})
+	return out
+}
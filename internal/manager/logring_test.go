@@ -0,0 +1,68 @@
+package manager
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestLogRingKeepsNewestInOrder(t *testing.T) {
+	r := newLogRing(3)
+	for i := 1; i <= 5; i++ {
+		r.Push(model.LogEntry{Message: fmt.Sprintf("line %d", i)})
+	}
+
+	if r.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", r.Len())
+	}
+	got := r.Snapshot()
+	want := []string{"line 3", "line 4", "line 5"}
+	for i, w := range want {
+		if got[i].Message != w {
+			t.Errorf("entry %d = %q, want %q", i, got[i].Message, w)
+		}
+	}
+}
+
+func TestLogRingEachStopsEarly(t *testing.T) {
+	r := newLogRing(4)
+	for i := 0; i < 4; i++ {
+		r.Push(model.LogEntry{Message: fmt.Sprint(i)})
+	}
+
+	seen := 0
+	r.Each(func(model.LogEntry) bool {
+		seen++
+		return seen < 2
+	})
+	if seen != 2 {
+		t.Errorf("Each visited %d entries, want 2", seen)
+	}
+}
+
+func TestLogRingDoesNotReallocate(t *testing.T) {
+	r := newLogRing(maxLogEntries)
+	backing := &r.buf[0]
+	for i := 0; i < maxLogEntries*3; i++ {
+		r.Push(model.LogEntry{Message: "x"})
+	}
+	if &r.buf[0] != backing {
+		t.Error("ring buffer backing array was reallocated")
+	}
+}
+
+func TestAppendLogCapsHistory(t *testing.T) {
+	svc := &runningService{}
+	for i := 0; i < maxLogEntries+10; i++ {
+		svc.appendLog(fmt.Sprintf("line %d", i), false)
+	}
+
+	logs := svc.snapshot().Logs
+	if len(logs) != maxLogEntries {
+		t.Fatalf("len(logs) = %d, want %d", len(logs), maxLogEntries)
+	}
+	if logs[0].Message != "line 10" {
+		t.Errorf("oldest entry = %q, want %q", logs[0].Message, "line 10")
+	}
+}
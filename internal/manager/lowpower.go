@@ -0,0 +1,20 @@
+package manager
+
+import "time"
+
+// UseLowPower slows the session's own polling, for a small machine (a
+// Raspberry Pi gateway, say) that runs forwards around the clock: kubectl
+// proxy health checks, remote host lookups, rollout, waitFor, maintenance and
+// config polls all run several times less often, and a busy local port is
+// reported without running lsof or netstat to name its owner on every retry.
+// Monitors and remote checks keep the intervals their config gives. It
+// applies to the whole process, so call it before any service starts.
+func UseLowPower() {
+	healthzInterval = 30 * time.Second
+	remoteResolveInterval = 2 * time.Minute
+	rolloutPollInterval = 30 * time.Second
+	waitInterval = 10 * time.Second
+	maintenancePollInterval = 10 * time.Second
+	configPollInterval = 5 * time.Second
+	askPortOwners = false
+}
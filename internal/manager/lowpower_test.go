@@ -0,0 +1,29 @@
+package manager
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUseLowPower(t *testing.T) {
+	defer func(healthz, resolve, rollout, wait, maintenance, config time.Duration, ask bool) {
+		healthzInterval, remoteResolveInterval, rolloutPollInterval = healthz, resolve, rollout
+		waitInterval, maintenancePollInterval, configPollInterval = wait, maintenance, config
+		askPortOwners = ask
+	}(healthzInterval, remoteResolveInterval, rolloutPollInterval, waitInterval, maintenancePollInterval, configPollInterval, askPortOwners)
+	UseLowPower()
+
+	if healthzInterval < 30*time.Second || configPollInterval < 5*time.Second || maintenancePollInterval < 10*time.Second {
+		t.Errorf("polls not slowed: healthz %s, config %s, maintenance %s", healthzInterval, configPollInterval, maintenancePollInterval)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	if busy, owners := portBusy("127.0.0.1", port); !busy || owners != nil {
+		t.Errorf("portBusy = %v, %v; want busy without asking who", busy, owners)
+	}
+}
@@ -0,0 +1,64 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/events"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// maintenancePollInterval is how often a service waiting out its maintenance
+// window checks whether it has ended. A var so UseLowPower can lengthen it.
+var maintenancePollInterval = time.Second
+
+// applyMaintenance brings the running services' maintenance windows in step
+// with the config.
+func (m *ServiceManager) applyMaintenance(now time.Time) {
+	windows, err := m.storage.MaintenanceWindows(now)
+	if err != nil {
+		return // keep the current windows; the config may be mid-edit
+	}
+
+	for _, svc := range m.runningList() {
+		until := windows[svc.name]
+		svc.mu.Lock()
+		changed := !svc.maintenance.Equal(until)
+		if changed {
+			svc.maintenance = until
+			message := "━━━━ MAINTENANCE ENDED ━━━━"
+			if !until.IsZero() {
+				message = fmt.Sprintf("━━━━ MAINTENANCE until %s ━━━━", until.Format("15:04"))
+			}
+			svc.pushLogLocked(model.LogEntry{Message: message, Kind: model.LogKindStatus, Status: svc.status})
+			svc.event(events.Event{Kind: events.ConfigChanged, Detail: message})
+		}
+		svc.mu.Unlock()
+		if changed {
+			svc.changed()
+		}
+	}
+}
+
+// waitOutMaintenance holds off a reconnect while svc is under maintenance. It
+// reports whether it waited; it returns early when ctx ends.
+func waitOutMaintenance(ctx context.Context, svc *runningService) bool {
+	if !svc.inMaintenance(time.Now()) {
+		return false
+	}
+	svc.appendMarker(model.LogKindReconnect, "━━━━ IN MAINTENANCE: not reconnecting until the window ends ━━━━")
+
+	ticker := time.NewTicker(maintenancePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return true
+		case now := <-ticker.C:
+			if !svc.inMaintenance(now) {
+				return true
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestWaitOutMaintenance(t *testing.T) {
+	svc := &runningService{name: "db", maintenance: time.Now().Add(-time.Minute)}
+	if waitOutMaintenance(context.Background(), svc) {
+		t.Fatal("an expired window should not hold off the reconnect")
+	}
+
+	svc.maintenance = time.Now().Add(time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() { done <- waitOutMaintenance(ctx, svc) }()
+	select {
+	case <-done:
+		t.Fatal("returned while the window was open")
+	case <-time.After(50 * time.Millisecond):
+	}
+	cancel()
+	select {
+	case waited := <-done:
+		if !waited {
+			t.Error("should report that it waited")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("did not return when ctx ended")
+	}
+
+	if s := svc.snapshot(); !s.InMaintenance(time.Now()) {
+		t.Error("snapshot should carry the window")
+	}
+}
+
+func TestNoFlapCountsDuringMaintenance(t *testing.T) {
+	svc := &runningService{name: "db", flaps: newFlapDetector(2, time.Minute), maintenance: time.Now().Add(time.Hour)}
+	for i := 0; i < 3; i++ {
+		svc.setError("boom")
+		svc.mu.Lock()
+		svc.setStatusLocked(model.StatusConnecting)
+		svc.mu.Unlock()
+	}
+	if !svc.flaps.until().IsZero() {
+		t.Error("errors during maintenance should not count toward flapping")
+	}
+}
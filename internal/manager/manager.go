@@ -1,126 +1,404 @@
 package manager
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"math/rand"
+	"maps"
 	"net"
 	"os"
 	"os/exec"
 	"regexp"
-	"runtime"
+	"slices"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/alinemone/go-port-forward/forwarder"
 	"github.com/alinemone/go-port-forward/internal/cert"
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/endpoint"
+	"github.com/alinemone/go-port-forward/internal/events"
+	"github.com/alinemone/go-port-forward/internal/hints"
 	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/proc"
+	"github.com/alinemone/go-port-forward/internal/relay"
+	"github.com/alinemone/go-port-forward/internal/secrets"
 	"github.com/alinemone/go-port-forward/internal/storage"
 )
 
 type runningService struct {
 	name          string
-	command       string
+	command       string   // the command in use: commands[active]
+	commands      []string // the service's own command, then its alternates
+	active        int
+	fallback      string // last-resort command; "" = none
+	fallbackAfter int    // failed runs in a row before switching to fallback
+	failures      int    // failed runs in a row so far
+	degraded      bool   // running on fallback
+	relayAddr     string // the relay's listen address; "" = no relay
+	adhoc         bool   // started from a definition another tool handed over, not the config
+	waitFor       []storage.WaitCondition
+	preConnect    *storage.PreConnect     // run before each attempt; nil = none
+	otp           *otpSource              // answers code prompts; nil = none
+	keepalive     storage.Keepalive       // connection options for its commands
+	hostKeys      storage.HostKeyChecking // policy for unknown ssh host keys
+	limits        storage.Limits          // for the child process
+	remoteCheck   time.Duration           // how often the far end of its forward is checked; 0 = never
+	dns           storage.DNS             // how its ssh forward's remote host is resolved
+	resolved      string                  // the address resolveForward last used; "" = none yet
+	deprecated    string                  // the deprecation notice; "" = not deprecated
+	mergeKey      string                  // its kubectl shared with others like it; "" = its own. See mergeGroup
+	mergePorts    []string                // its port specs, when merged
+	chaos         relay.Chaos
 	localPort     string
 	mainPort      string
+	forward       cmdline.Forward
 	iconEnabled   bool
 	iconGlyph     string
 	iconColor     string
 	status        string
+	statusSince   time.Time
+	transitions   []model.Transition // the latest maxTransitions, oldest first
 	lastError     string
-	startTime     time.Time
-	restartCount  int
-	healthySince  time.Time
-	lastHealthy   time.Time
-	lastRunStable bool
-	logs          []model.LogEntry
-	cancel        context.CancelFunc
-	done          chan struct{}
-	process       *os.Process
-	mu            sync.RWMutex
+	lastExit      *model.Exit     // how the process last died; nil once healthy again
+	stderrTail    []string        // the current process's last stderr lines
+	hinted        map[string]bool // hints already logged for the current process
+	hint          string          // the latest of them
+	// outputDropped counts the output lines dropped because the process
+	// printed them faster than pf could log them; see lineQueue.
+	outputDropped  int
+	throttledUntil time.Time
+	startTime      time.Time
+	restartCount   int
+	healthySince   time.Time
+	lastHealthy    time.Time
+	lastRunStable  bool
+	flaps          *flapDetector // nil in tests that build services directly
+	maintenance    time.Time     // end of the maintenance window; zero = none
+	nextRestart    time.Time     // the next scheduled restart; zero = none
+	rollingOut     bool          // waiting out a rollout of the workload behind it
+	reconnects     map[string]int
+	metrics        model.Metrics // kept up by setStatusLocked
+	// redial is set when pf drops the tunnel on purpose (see watchRemote), so
+	// the exit is not an error and the loop reconnects without backoff.
+	redial atomic.Bool
+	// kubeconfigLocked is set when the current run found its kubeconfig
+	// locked, and lockRetries counts such runs in a row; see
+	// waitOutKubeconfigLock.
+	kubeconfigLocked atomic.Bool
+	lockRetries      int
+	// retrySoon ends a reconnect backoff early; see redialNow. nil in tests
+	// that build services directly.
+	retrySoon chan struct{}
+	// leaveStartGate lets the next kubectl of the same kubeconfig start while
+	// this run's holds its gate; nil once it has. See startGates.
+	leaveStartGate func()
+	logs           *logRing
+	// cancel and done belong to the current run of the loop; a restart
+	// replaces them, so they are read and written under mu.
+	cancel context.CancelFunc
+	done   chan struct{}
+	// stopped is set under mu once the service is stopped, so a restart
+	// already under way does not bring it back.
+	stopped bool
+	// restarting makes restarts of the service wait for one another, so two
+	// frontends restarting it at once leave one loop running, not two.
+	restarting sync.Mutex
+	// stopRelay ends the service's relay, if it has one. Unlike cancel it is
+	// not replaced on restart: the relay keeps listening throughout.
+	stopRelay context.CancelFunc
+	// stopSchedule ends the service's scheduled restarts, if it has any;
+	// like stopRelay it outlives restarts.
+	stopSchedule context.CancelFunc
+	// postConnect starts the post-connect command the first time the service
+	// is healthy, and stopPostConnect ends it; both outlive restarts, so it
+	// runs once a session. nil = none.
+	postConnect     func()
+	stopPostConnect context.CancelFunc
+	process         *os.Process
+	// stdin is the write end of the process's stdin while it runs with
+	// prompts enabled (see EnablePrompts), and prompt what it waits on an
+	// answer to; "" = nothing.
+	stdin  *os.File
+	prompt string
+	mu     sync.RWMutex
 
 	// bulkKill is set before cancelling during StopAllServices so the per-run
-	// ctx.Done watcher skips its own taskkill — the whole fleet is killed in one
-	// batched call instead of one spawn per service.
+	// ctx.Done watcher skips its own kill: the shutdown asks the process to
+	// exit, gives it its timeout and only then kills it.
 	bulkKill atomic.Bool
+
+	// onChange is called (outside mu) after any change a frontend can see. It's
+	// the manager's notify hook; nil in tests that build services directly.
+	onChange func()
+	// publish puts an event on the manager's bus; it never blocks, so it may
+	// be called under mu. nil in tests that build services directly.
+	publish func(events.Event)
 }
 
-func (s *runningService) markHealthy() {
+func (s *runningService) changed() {
+	if s.onChange != nil {
+		s.onChange()
+	}
+}
+
+// event publishes e about the service.
+func (s *runningService) event(e events.Event) {
+	if s.publish != nil {
+		e.Service = s.name
+		s.publish(e)
+	}
+}
+
+// stop marks the service stopped and cancels its current run and its relay.
+// It returns the run's done channel, to wait on.
+func (s *runningService) stop() chan struct{} {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.stopped = true
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	if s.stopRelay != nil {
+		s.stopRelay()
+	}
+	if s.stopSchedule != nil {
+		s.stopSchedule()
+	}
+	if s.stopPostConnect != nil {
+		s.stopPostConnect()
+	}
+	return done
+}
 
-	if s.status != model.StatusHealthy {
-		s.status = model.StatusHealthy
+func (s *runningService) markHealthy() {
+	s.mu.Lock()
+	transitioned := s.setStatusLocked(model.StatusHealthy)
+	if transitioned {
 		s.lastError = ""
+		s.lastExit = nil
 	}
 	now := time.Now()
 	if s.healthySince.IsZero() {
 		s.healthySince = now
 	}
 	s.lastHealthy = now
+	s.mu.Unlock()
+
+	if transitioned {
+		s.changed()
+		if s.postConnect != nil {
+			s.postConnect()
+		}
+	}
 }
 
 func (s *runningService) snapshot() model.Service {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	logsCopy := make([]model.LogEntry, len(s.logs))
-	copy(logsCopy, s.logs)
+	var logsCopy []model.LogEntry
+	if s.logs != nil {
+		logsCopy = s.logs.Snapshot()
+	}
 
 	return model.Service{
-		Name:         s.name,
-		Command:      s.command,
-		LocalPort:    s.localPort,
-		MainPort:     s.mainPort,
-		IconEnabled:  s.iconEnabled,
-		IconGlyph:    s.iconGlyph,
-		IconColor:    s.iconColor,
-		Status:       s.status,
-		LastError:    s.lastError,
-		StartTime:    s.startTime,
-		RestartCount: s.restartCount,
-		Logs:         logsCopy,
+		Name:             s.name,
+		Command:          s.command,
+		Endpoint:         s.active + 1,
+		Endpoints:        max(len(s.commands), 1),
+		Degraded:         s.degraded,
+		Relay:            s.relayAddr,
+		LocalPort:        s.localPort,
+		MainPort:         s.mainPort,
+		BindAddress:      s.forward.Address,
+		Target:           s.forward.Target,
+		Namespace:        s.forward.Namespace,
+		APIProxy:         s.forward.APIProxy,
+		Monitor:          monitorLabel(s.command),
+		Deprecated:       s.deprecated,
+		IconEnabled:      s.iconEnabled,
+		IconGlyph:        s.iconGlyph,
+		IconColor:        s.iconColor,
+		Status:           s.status,
+		LastError:        s.lastError,
+		StartTime:        s.startTime,
+		RestartCount:     s.restartCount,
+		FlappingUntil:    s.flaps.until(),
+		MaintenanceUntil: s.maintenance,
+		NextRestart:      s.nextRestart,
+		RollingOut:       s.rollingOut,
+		Prompt:           s.prompt,
+		Reconnects:       maps.Clone(s.reconnects),
+		Transitions:      slices.Clone(s.transitions),
+		Metrics:          s.metrics,
+		LastExit:         s.lastExit,
+		OutputDropped:    s.outputDropped,
+		ThrottledUntil:   s.throttledUntil,
+		Logs:             logsCopy,
 	}
 }
 
 func (s *runningService) setError(message string) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	s.lastError = message
-	s.status = model.StatusError
+	s.setStatusLocked(model.StatusError)
+	s.mu.Unlock()
+
+	s.changed()
+}
+
+// setStatusLocked moves the service to status. When that is a real transition
+// it is remembered with how long the service was in its previous status, and
+// logged as a marker line (e.g. "HEALTHY → ERROR"), so the combined log tells
+// the whole story without cross-referencing the table. Reports whether the
+// status changed. The caller holds s.mu.
+func (s *runningService) setStatusLocked(status string) bool {
+	prev := s.status
+	if prev == status {
+		return false
+	}
+	now := time.Now()
+	s.status = status
+	if prev != "" {
+		t := model.Transition{From: prev, To: status, At: now}
+		if !s.statusSince.IsZero() {
+			t.Duration = now.Sub(s.statusSince)
+		}
+		if len(s.transitions) == maxTransitions {
+			s.transitions = slices.Delete(s.transitions, 0, 1)
+		}
+		s.transitions = append(s.transitions, t)
+		s.event(events.Event{Kind: events.ServiceStatus, Time: now, From: prev, To: status})
+		s.pushLogLocked(model.LogEntry{
+			Message: fmt.Sprintf("━━━━ %s → %s ━━━━", strings.ToUpper(prev), strings.ToUpper(status)),
+			Kind:    model.LogKindStatus,
+			Status:  status,
+		})
+	}
+	s.countStatusLocked(prev, status, now)
+	s.statusSince = now
+	if status == model.StatusError && !now.Before(s.maintenance) && !s.rollingOut {
+		wasFlapping := now.Before(s.flaps.until())
+		s.flaps.record(now)
+		if !wasFlapping && now.Before(s.flaps.until()) {
+			s.pushLogLocked(model.LogEntry{
+				Message: fmt.Sprintf("━━━━ FLAPPING: %d errors within %s ━━━━", s.flaps.errors, s.flaps.window),
+				Kind:    model.LogKindStatus,
+				Status:  status,
+			})
+		}
+	}
+	return true
+}
+
+// countStatusLocked adds a status change to the service's metrics: the
+// healthy spell it ends, the error it starts, or the connect it completes.
+// The caller holds s.mu; statusSince is still the start of prev.
+func (s *runningService) countStatusLocked(prev, status string, now time.Time) {
+	m := &s.metrics
+	if !m.UpSince.IsZero() {
+		m.Healthy += now.Sub(m.UpSince)
+		m.UpSince = time.Time{}
+	}
+	switch status {
+	case model.StatusHealthy:
+		m.UpSince = now
+		if prev == model.StatusConnecting && !s.statusSince.IsZero() {
+			m.Connects++
+			m.ConnectTime += now.Sub(s.statusSince)
+		}
+	case model.StatusError:
+		m.Errors++
+	}
+}
+
+// inMaintenance reports whether the service's maintenance window is open.
+func (s *runningService) inMaintenance(now time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return now.Before(s.maintenance)
 }
 
 func (s *runningService) appendLog(message string, isError bool) {
-	message = strings.TrimSpace(message)
-	if message == "" {
+	s.appendEntry(model.LogEntry{Message: message, IsError: isError})
+}
+
+// appendMarker logs a pf-generated line of the given kind.
+func (s *runningService) appendMarker(kind model.LogKind, message string) {
+	s.appendEntry(model.LogEntry{Message: message, Kind: kind})
+}
+
+func (s *runningService) appendEntry(entry model.LogEntry) {
+	entry.Message = strings.TrimSpace(entry.Message)
+	if entry.Message == "" {
 		return
 	}
 
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.pushLogLocked(entry)
+	s.mu.Unlock()
 
-	s.logs = append(s.logs, model.LogEntry{
-		Time:    time.Now(),
-		Message: message,
-		IsError: isError,
-	})
+	s.changed()
+}
 
-	if len(s.logs) > 120 {
-		s.logs = s.logs[len(s.logs)-120:]
+// pushLogLocked timestamps entry and stores it. The caller holds s.mu.
+func (s *runningService) pushLogLocked(entry model.LogEntry) {
+	if s.logs == nil {
+		s.logs = newLogRing(maxLogEntries)
 	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	s.logs.Push(entry)
+	s.event(events.Event{Kind: events.ServiceLog, Time: entry.Time, Log: entry})
 }
 
 type ServiceManager struct {
 	services    map[string]*runningService
 	storage     *storage.Storage
 	certManager *cert.Manager
-	mu          sync.RWMutex
+	// groups are the groups started in this session, by name, with their
+	// members; see TrackGroup.
+	groups map[string][]string
+	// params are the session's `pf run --set` values, by service name, with
+	// "" holding those for every service; see SetParams.
+	params map[string]map[string]string
+	// merged are the services sharing a kubectl, by what they share; see
+	// mergeGroup.
+	merged map[string]*mergeGroup
+	mu     sync.RWMutex
+
+	// flap detection thresholds for new services (see flapDetector)
+	flapErrors int
+	flapWindow time.Duration
+	// hints suggest fixes for the errors services print
+	hints *hints.Set
+	// backoff paces reconnects; the zero value means DefaultBackoff
+	backoff Backoff
+	// shutdown orders and times StopAllServices
+	shutdown storage.ShutdownConfig
+	// prompts gives processes a stdin to answer prompts on; see EnablePrompts
+	prompts bool
+	// stderrLog writes services' errors to stderr, with PF_STDERR set
+	stderrLog errorLog
+
+	// updates carries coalesced "something changed" signals to the frontend.
+	// It has a buffer of one and sends never block, so a burst of log lines
+	// collapses into a single pending notification.
+	updates chan struct{}
+	// subscribers are the channels of further frontends (see Subscribe),
+	// signalled the same way. subMu guards them apart from mu, since services
+	// notify while the manager may be locked.
+	subMu       sync.Mutex
+	subscribers map[chan struct{}]struct{}
+	// bus carries the session's events to whoever follows them; see Events.
+	bus *events.Bus
 }
 
 func NewServiceManager(st *storage.Storage) *ServiceManager {
@@ -130,13 +408,99 @@ func NewServiceManager(st *storage.Storage) *ServiceManager {
 		certMgr = nil
 	}
 
+	flapErrors, flapWindow, err := flapSettings(st)
+	if err != nil {
+		flapErrors, flapWindow = defaultFlapErrors, defaultFlapWindow
+	}
+
+	hintSet, err := loadHints(st)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Ignoring your error hints: %v\n", err)
+		hintSet = hints.Builtin()
+	}
+
+	shutdown, err := st.Shutdown()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Ignoring your shutdown settings: %v\n", err)
+	}
+
 	return &ServiceManager{
 		services:    make(map[string]*runningService),
 		storage:     st,
 		certManager: certMgr,
+		flapErrors:  flapErrors,
+		flapWindow:  flapWindow,
+		hints:       hintSet,
+		shutdown:    shutdown,
+		updates:     make(chan struct{}, 1),
+		bus:         &events.Bus{},
+	}
+}
+
+// Events returns the session's event bus: services starting, stopping,
+// changing status and logging, and the config changes the session applies.
+func (m *ServiceManager) Events() *events.Bus {
+	return m.bus
+}
+
+// Notice reports message to the session's frontends as an
+// events.SessionNotice.
+func (m *ServiceManager) Notice(message string) {
+	m.publish(events.Event{Kind: events.SessionNotice, Detail: message})
+}
+
+// publish puts e on the bus, when the manager has one.
+func (m *ServiceManager) publish(e events.Event) {
+	if m.bus != nil {
+		m.bus.Publish(e)
+	}
+}
+
+// Updates returns a channel that receives a value whenever a service's state or
+// logs change, or a service is started or stopped. Signals are coalesced: a
+// receiver should re-read ListServiceStates after each one rather than count
+// them. The channel is the main frontend's; another one following the same
+// session takes its own from Subscribe.
+func (m *ServiceManager) Updates() <-chan struct{} {
+	return m.updates
+}
+
+// Subscribe returns a channel signalled like Updates, for one more frontend,
+// and the func that ends the subscription once it stops reading.
+func (m *ServiceManager) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	m.subMu.Lock()
+	if m.subscribers == nil {
+		m.subscribers = make(map[chan struct{}]struct{})
+	}
+	m.subscribers[ch] = struct{}{}
+	m.subMu.Unlock()
+	return ch, func() {
+		m.subMu.Lock()
+		delete(m.subscribers, ch)
+		m.subMu.Unlock()
 	}
 }
 
+// notify signals Updates and every subscriber without blocking; a pending
+// signal already covers this change.
+func (m *ServiceManager) notify() {
+	signal := func(ch chan struct{}) {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	if m.updates != nil {
+		signal(m.updates)
+	}
+	m.subMu.Lock()
+	for ch := range m.subscribers {
+		signal(ch)
+	}
+	m.subMu.Unlock()
+}
+
 func ValidateServiceName(name string) error {
 	return ensureValidServiceName(name)
 }
@@ -145,6 +509,18 @@ func ValidateCommand(command string) error {
 	return ensureValidCommand(command)
 }
 
+// ensureValidRunName accepts a local service name or a remote catalog's
+// "catalog/service".
+func ensureValidRunName(name string) error {
+	if catalogName, entry, ok := storage.SplitCatalogName(name); ok {
+		if err := ensureValidServiceName(catalogName); err != nil {
+			return err
+		}
+		return ensureValidServiceName(entry)
+	}
+	return ensureValidServiceName(name)
+}
+
 func ensureValidServiceName(name string) error {
 	if name == "" {
 		return fmt.Errorf("service name cannot be empty")
@@ -196,27 +572,251 @@ func ensureValidCommand(command string) error {
 		}
 	}
 
+	if cmdline.IsMonitor(command) {
+		_, err := cmdline.ParseMonitor(command)
+		return err
+	}
+	if forwarder.IsCommand(command) {
+		_, err := forwarder.ParseCommand(command)
+		return err
+	}
 	return nil
 }
 
 func (m *ServiceManager) StartService(ctx context.Context, name string) error {
-	if err := ensureValidServiceName(name); err != nil {
+	if err := ensureValidRunName(name); err != nil {
 		return fmt.Errorf("invalid service name: %v", err)
 	}
 
-	command, err := m.storage.GetService(name)
+	command, err := m.storage.LocalCommand(name)
 	if err != nil {
 		return err
 	}
+	if command, err = m.withParams(name, command); err != nil {
+		return fmt.Errorf("service '%s': %v", name, err)
+	}
+	return m.startCommand(ctx, name, command, false)
+}
 
+// SetParams sets the fields (see storage.ParamKeys) this session changes in
+// the commands of saved services, by service name, "" for every service. A
+// service's own values win over those for every service. They apply from a
+// service's next start on and are never saved.
+func (m *ServiceManager) SetParams(params map[string]map[string]string) {
+	m.mu.Lock()
+	m.params = params
+	m.mu.Unlock()
+}
+
+// ServiceParams returns the parameters SetParams gives the service called
+// name.
+func (m *ServiceManager) ServiceParams(name string) map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return mergeParams(m.params, name)
+}
+
+// mergeParams returns the parameters of the service called name: those for
+// every service, overridden by its own.
+func mergeParams(params map[string]map[string]string, name string) map[string]string {
+	if len(params[""]) == 0 && len(params[name]) == 0 {
+		return nil
+	}
+	merged := maps.Clone(params[""])
+	if merged == nil {
+		merged = map[string]string{}
+	}
+	maps.Copy(merged, params[name])
+	return merged
+}
+
+// withParams returns command with the session's parameters for name.
+func (m *ServiceManager) withParams(name, command string) (string, error) {
+	return storage.ApplyParams(command, m.ServiceParams(name))
+}
+
+// startCommand starts command as service name. A saved service (adhoc false)
+// also gets its alternates, fallback and relay from the config; an ad-hoc one
+// is just its command.
+func (m *ServiceManager) startCommand(ctx context.Context, name, command string, adhoc bool) error {
+	if m.isRunning(name) {
+		return fmt.Errorf("service '%s' is already running", name)
+	}
 	if err := ensureValidCommand(command); err != nil {
 		return fmt.Errorf("invalid command for service '%s': %v", name, err)
 	}
 
-	localPort, mainPort := storage.ParsePortsFromCommand(command)
-	if localPort == "" {
+	monitor := cmdline.IsMonitor(command)
+	localPort, mainPort := cmdline.ParsePorts(command)
+	if localPort == "" && !monitor {
 		return fmt.Errorf("could not extract ports from command")
 	}
+
+	var alternates []string
+	var fallback storage.FallbackConfig
+	var relayCfg storage.RelayConfig
+	var hasRelay bool
+	var rewrite relay.Rewrite
+	var waitFor []storage.WaitCondition
+	var preConnect *storage.PreConnect
+	var otp *otpSource
+	var postConnect storage.PostConnect
+	var hasPostConnect bool
+	var remoteCheck time.Duration
+	var dns storage.DNS
+	keepalive := storage.Keepalive{} // the defaults
+	var hostKeys storage.HostKeyChecking
+	var limits storage.Limits
+	var schedule storage.RestartSchedule
+	var hasSchedule bool
+	if !adhoc && !monitor {
+		var err error
+		alternates, err = m.storage.Alternates(name)
+		if err != nil {
+			return err
+		}
+		for i, alt := range alternates {
+			if alternates[i], err = m.withParams(name, alt); err != nil {
+				return fmt.Errorf("alternate command for service '%s': %v", name, err)
+			}
+			if err := ensureValidCommand(alternates[i]); err != nil {
+				return fmt.Errorf("invalid alternate command for service '%s': %v", name, err)
+			}
+		}
+		if err := storage.CheckSamePort(command, alternates...); err != nil {
+			return fmt.Errorf("service '%s': %v", name, err)
+		}
+		var hasFallback bool
+		fallback, hasFallback, err = m.storage.Fallback(name)
+		if err != nil {
+			return err
+		}
+		if hasFallback {
+			if fallback.Command, err = m.withParams(name, fallback.Command); err != nil {
+				return fmt.Errorf("fallback command for service '%s': %v", name, err)
+			}
+			if err := ensureValidCommand(fallback.Command); err != nil {
+				return fmt.Errorf("invalid fallback command for service '%s': %v", name, err)
+			}
+			if err := storage.CheckSamePort(command, fallback.Command); err != nil {
+				return fmt.Errorf("service '%s' fallback: %v", name, err)
+			}
+		}
+		relayCfg, hasRelay, err = m.storage.Relay(name)
+		if err != nil {
+			return err
+		}
+		rewrite, err = relayRewrite(ctx, relayCfg.Rewrite)
+		if err != nil {
+			return fmt.Errorf("service '%s' relay: %v", name, err)
+		}
+		if _, err := relayCfg.HoldDuration(); err != nil {
+			return fmt.Errorf("service '%s': %v", name, err)
+		}
+		waitFor, err = m.storage.WaitFor(name)
+		if err != nil {
+			return err
+		}
+		for _, c := range waitFor {
+			if err := c.Validate(); err != nil {
+				return fmt.Errorf("service '%s': %v", name, err)
+			}
+		}
+		p, hasPreConnect, err := m.storage.PreConnect(name)
+		if err != nil {
+			return err
+		}
+		if hasPreConnect {
+			if err := p.Validate(); err != nil {
+				return fmt.Errorf("service '%s': %v", name, err)
+			}
+			preConnect = &p
+		}
+		o, hasOTP, err := m.storage.OTP(name)
+		if err != nil {
+			return err
+		}
+		if hasOTP {
+			if err := o.Validate(); err != nil {
+				return fmt.Errorf("service '%s': %v", name, err)
+			}
+			if otp, err = newOTPSource(ctx, o); err != nil {
+				return fmt.Errorf("service '%s' otp: %v", name, err)
+			}
+		}
+		postConnect, hasPostConnect, err = m.storage.PostConnect(name)
+		if err != nil {
+			return err
+		}
+		if hasPostConnect {
+			if err := postConnect.Validate(); err != nil {
+				return fmt.Errorf("service '%s': %v", name, err)
+			}
+		}
+		r, hasRemoteCheck, err := m.storage.RemoteCheck(name)
+		if err != nil {
+			return err
+		}
+		if hasRemoteCheck {
+			if remoteCheck, err = r.EveryDuration(); err != nil {
+				return fmt.Errorf("service '%s': %v", name, err)
+			}
+		}
+		if dns, _, err = m.storage.DNS(name); err != nil {
+			return err
+		}
+		if err := dns.Validate(); err != nil {
+			return fmt.Errorf("service '%s': %v", name, err)
+		}
+		if keepalive, err = m.storage.Keepalive(name); err != nil {
+			return err
+		}
+		if hostKeys, err = m.storage.HostKeyChecking(name); err != nil {
+			return err
+		}
+		if err := hostKeys.Validate(); err != nil {
+			return fmt.Errorf("service '%s': %v", name, err)
+		}
+		limits, err = m.storage.ServiceLimits(name)
+		if err != nil {
+			return err
+		}
+		if err := limits.Validate(); err != nil {
+			return fmt.Errorf("service '%s': %v", name, err)
+		}
+		schedule, hasSchedule, err = m.storage.RestartSchedule(name)
+		if err != nil {
+			return err
+		}
+		if hasSchedule {
+			if err := schedule.Validate(); err != nil {
+				return fmt.Errorf("service '%s': %v", name, err)
+			}
+		}
+	}
+	var deprecated string
+	if !adhoc {
+		d, ok, err := m.storage.Deprecation(name)
+		if err != nil {
+			return err
+		}
+		if ok {
+			deprecated = d.Describe(time.Now())
+		}
+	}
+	if err := keepalive.Validate(); err != nil {
+		return fmt.Errorf("service '%s': %v", name, err)
+	}
+	// Only a plain forward shares a kubectl: the settings above act on a
+	// service's own process.
+	var mergeKey string
+	var mergePorts []string
+	if !adhoc && !monitor && len(alternates) == 0 && fallback.Command == "" && !hasRelay && len(waitFor) == 0 &&
+		preConnect == nil && otp == nil && remoteCheck == 0 && limits == (storage.Limits{}) && keepalive == (storage.Keepalive{}) {
+		if merge, err := m.storage.MergeForwards(); err == nil && merge {
+			mergeKey, mergePorts, _ = cmdline.MergeablePortForward(command)
+		}
+	}
 	if mainPort == "" {
 		mainPort = localPort
 	}
@@ -226,27 +826,82 @@ func (m *ServiceManager) StartService(ctx context.Context, name string) error {
 	}
 	icon := iconSet.ForPort(mainPort)
 
+	m.mu.RLock()
+	flaps := newFlapDetector(m.flapErrors, m.flapWindow)
+	m.mu.RUnlock()
+
 	svcCtx, cancel := context.WithCancel(ctx)
 	done := make(chan struct{})
 	svc := &runningService{
-		name:         name,
-		command:      command,
-		localPort:    localPort,
-		mainPort:     mainPort,
-		iconEnabled:  iconEnabled,
-		iconGlyph:    icon.Glyph,
-		iconColor:    icon.Color,
-		status:       model.StatusConnecting,
-		startTime:    time.Now(),
-		restartCount: 0,
-		logs:         make([]model.LogEntry, 0),
-		cancel:       cancel,
-		done:         done,
+		name:          name,
+		command:       command,
+		commands:      append([]string{command}, alternates...),
+		fallback:      fallback.Command,
+		fallbackAfter: fallback.After,
+		localPort:     localPort,
+		mainPort:      mainPort,
+		forward:       cmdline.ParseForward(command),
+		iconEnabled:   iconEnabled,
+		iconGlyph:     icon.Glyph,
+		iconColor:     icon.Color,
+		status:        model.StatusConnecting,
+		statusSince:   time.Now(),
+		startTime:     time.Now(),
+		restartCount:  0,
+		flaps:         flaps,
+		logs:          newLogRing(maxLogEntries),
+		retrySoon:     make(chan struct{}, 1),
+		cancel:        cancel,
+		done:          done,
+		onChange:      m.notify,
+		publish:       m.publish,
+		adhoc:         adhoc,
+		waitFor:       waitFor,
+		preConnect:    preConnect,
+		otp:           otp,
+		keepalive:     keepalive,
+		hostKeys:      hostKeys,
+		limits:        limits,
+		remoteCheck:   remoteCheck,
+		dns:           dns,
+		deprecated:    deprecated,
+		mergeKey:      mergeKey,
+		mergePorts:    mergePorts,
+	}
+	if deprecated != "" {
+		svc.appendLog("This service is "+deprecated, false)
 	}
 
+	if hasRelay {
+		relayCtx, stopRelay := context.WithCancel(ctx)
+		svc.stopRelay = stopRelay
+		svc.relayAddr = relay.ListenAddress(relayCfg.Listen)
+		startRelay(relayCtx, svc, relayCfg, rewrite)
+	}
+	scheduleCtx, stopSchedule := context.WithCancel(ctx)
+	svc.stopSchedule = stopSchedule
+	if hasPostConnect {
+		postCtx, stopPostConnect := context.WithCancel(ctx)
+		svc.stopPostConnect = stopPostConnect
+		svc.postConnect = sync.OnceFunc(func() { go runPostConnect(postCtx, svc, postConnect) })
+	}
+
+	// Another frontend may have started the same service meanwhile: the
+	// first one in runs, the other gives up its half-made service.
 	m.mu.Lock()
+	if _, running := m.services[name]; running {
+		m.mu.Unlock()
+		svc.stop()
+		return fmt.Errorf("service '%s' is already running", name)
+	}
 	m.services[name] = svc
 	m.mu.Unlock()
+	m.notify()
+	m.publish(events.Event{Kind: events.ServiceStarted, Service: name})
+
+	if hasSchedule {
+		go m.runSchedule(ctx, scheduleCtx, svc, schedule.Next)
+	}
 
 	go func() {
 		defer close(done)
@@ -257,55 +912,133 @@ func (m *ServiceManager) StartService(ctx context.Context, name string) error {
 }
 
 func (m *ServiceManager) runServiceLoop(ctx context.Context, svc *runningService) {
-	const baseBackoff = 2 * time.Second
-	const maxBackoff = 30 * time.Second
+	svc.mu.RLock()
+	monitor := cmdline.IsMonitor(svc.command)
+	svc.mu.RUnlock()
+	if monitor {
+		m.runMonitor(ctx, svc)
+		return
+	}
+	if svc.mergeKey != "" && m.runMerged(ctx, svc) {
+		return
+	}
 
 	isFirstRun := true
+	svc.mu.RLock()
+	commands := slices.Concat(svc.commands, []string{svc.fallback})
+	svc.mu.RUnlock()
+	for _, command := range commands {
+		if cmdline.ParseForward(command).SSH {
+			go watchRemote(ctx, svc)
+			break
+		}
+	}
+	watched := make(map[cmdline.PodWatch]bool)
+	for _, command := range commands {
+		if w, ok := cmdline.PodWatchFor(command); ok && !watched[w] {
+			watched[w] = true
+			go watchPod(ctx, svc, w)
+		}
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
+			if !isFirstRun && waitOutKubeconfigLock(ctx, svc) {
+				isFirstRun = true
+				continue
+			}
+			if !isFirstRun && waitOutMaintenance(ctx, svc) {
+				// The window is over (or ctx is done): reconnect at once,
+				// without counting the downtime as a restart.
+				isFirstRun = true
+				continue
+			}
+			if !isFirstRun && m.waitOutRollout(ctx, svc) {
+				// Pods came and went under a rollout: reconnect to the new
+				// ones at once, as after maintenance.
+				isFirstRun = true
+				continue
+			}
+			if !isFirstRun && svc.redial.Swap(false) {
+				isFirstRun = true
+			}
 			if !isFirstRun {
 				svc.mu.Lock()
 				svc.restartCount = nextRestartCount(svc.restartCount, svc.lastRunStable)
 				restartCount := svc.restartCount
 				svc.mu.Unlock()
 
-				backoff := baseBackoff * time.Duration(1<<uint(restartCount-1))
-				if backoff > maxBackoff {
-					backoff = maxBackoff
-				}
-
-				jitter := time.Duration(float64(backoff) * 0.1 * (rand.Float64()*2 - 1))
-				backoff += jitter
-
-				svc.appendLog(
+				backoff := m.reconnectBackoff().Delay(restartCount)
+				svc.appendMarker(
+					model.LogKindReconnect,
 					fmt.Sprintf("━━━━ RECONNECTING (attempt #%d) in %.1fs ━━━━", restartCount, backoff.Seconds()),
-					false,
 				)
 
 				select {
 				case <-ctx.Done():
 					return
 				case <-time.After(backoff):
+				case <-svc.retrySoon:
 				}
 			}
 			isFirstRun = false
+			if !awaitConditions(ctx, svc) {
+				return
+			}
+			if !runPreConnect(ctx, svc) {
+				continue // back off and try again, as after a failed run
+			}
 			m.runServiceOnce(ctx, svc)
+			if svc.fallBack() || svc.failover() {
+				// Try the next endpoint at once; back off only once every
+				// one of them has failed.
+				isFirstRun = true
+			}
 		}
 	}
 }
 
 func (m *ServiceManager) runServiceOnce(ctx context.Context, svc *runningService) {
 	svc.mu.Lock()
-	svc.status = model.StatusConnecting
+	svc.setStatusLocked(model.StatusConnecting)
 	svc.lastError = ""
+	svc.stderrTail = nil
+	svc.hinted, svc.hint = nil, ""
 	svc.healthySince = time.Time{}
+	commandStr := svc.command
+	apiProxy := svc.forward.APIProxy
+	limits := svc.limits
+	keepalive := svc.keepalive
+	hostKeys := svc.hostKeys
+	answersCodes := svc.otp != nil
+	remoteCheck := svc.remoteCheck
+	dns := svc.dns
+	localPort, bindHost := svc.localPort, endpoint.DialHost(svc.forward.Address)
 	svc.mu.Unlock()
+	svc.changed()
 
-	commandStr := svc.command
+	// A port another program holds would only make the forward fail; say who
+	// holds it instead. pf does not stop it: that is the user's call.
+	if localPort != "" {
+		if busy, owners := portBusy(bindHost, localPort); busy {
+			message := portConflictMessage(localPort, owners)
+			svc.setError(message)
+			svc.appendLog(message+"; stop it, or give this service another local port", true)
+			return
+		}
+	}
+
+	commandStr = withKeepalive(ctx, commandStr, keepalive)
+	commandStr = cmdline.AddSSHOptions(commandStr, hostKeys.SSHOptions())
+	if dns.Resolver == storage.DNSServer || dns.Resolver == storage.DNSPin {
+		var ok bool
+		if commandStr, ok = resolveForward(ctx, svc, commandStr, dns); !ok {
+			return
+		}
+	}
 	if m.certManager != nil {
 		if certConfig, exists := m.certManager.GetCertificate(); exists {
 			if strings.Contains(commandStr, "kubectl") {
@@ -313,65 +1046,187 @@ func (m *ServiceManager) runServiceOnce(ctx context.Context, svc *runningService
 			}
 		}
 	}
+	// The remote check reaches the far end the way this run does.
+	remoteProbe, hasRemoteProbe := cmdline.RemoteProbeFor(commandStr)
+	hasRemoteProbe = hasRemoteProbe && remoteCheck > 0
+	kubeconfig := kubeconfigFor(commandStr)
+	commandStr, err := withPluginHost(svc.name, commandStr)
+	if err != nil {
+		message := fmt.Sprintf("Failed to host the forwarder plugin: %v", err)
+		svc.setError(message)
+		svc.appendLog(message, true)
+		return
+	}
+	// Secrets the command references reach it in its environment.
+	var secretEnv []string
+	if secrets.Has(commandStr) {
+		if commandStr, secretEnv, err = secrets.Expand(ctx, commandStr); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			message := err.Error()
+			svc.setError(message)
+			svc.appendLog(message, true)
+			return
+		}
+	}
+	// One kubectl at a time starts per kubeconfig, as they lock it.
+	if kubeconfig != "" {
+		leave, ok := kubeconfigGates.enter(ctx, kubeconfig)
+		if !ok {
+			return
+		}
+		defer leave()
+		svc.holdStartGate(leave)
+	}
 
-	cmd := newShellCommand(commandStr)
+	cmd := newLimitedCommand(commandStr, limits)
+	if secretEnv != nil {
+		withDelayedExpansion(cmd)
+		cmd.Env = append(cmd.Environ(), secretEnv...)
+	}
 
-	stdoutPipe, err := cmd.StdoutPipe()
+	// pf owns the read ends rather than using StdoutPipe, whose Wait closes
+	// them: the last lines a dying process wrote are still read afterwards.
+	stdoutPipe, stdoutW, err := os.Pipe()
 	if err != nil {
 		message := fmt.Sprintf("Failed to create stdout pipe: %v", err)
 		svc.setError(message)
 		svc.appendLog(message, true)
 		return
 	}
+	defer stdoutPipe.Close()
 
-	stderrPipe, err := cmd.StderrPipe()
+	stderrPipe, stderrW, err := os.Pipe()
 	if err != nil {
+		stdoutW.Close()
 		message := fmt.Sprintf("Failed to create stderr pipe: %v", err)
 		svc.setError(message)
 		svc.appendLog(message, true)
 		return
 	}
+	defer stderrPipe.Close()
 
-	if err := cmd.Start(); err != nil {
+	stdinR, stdinW, err := m.promptStdin(cmd, answersCodes)
+	if err != nil {
+		stdoutW.Close()
+		stderrW.Close()
+		message := fmt.Sprintf("Failed to create stdin pipe: %v", err)
+		svc.setError(message)
+		svc.appendLog(message, true)
+		return
+	}
+	if stdinW != nil {
+		defer stdinW.Close()
+	}
+
+	cmd.Stdout, cmd.Stderr = stdoutW, stderrW
+	err = cmd.Start()
+	stdoutW.Close() // the child has its own copies
+	stderrW.Close()
+	if stdinR != nil {
+		stdinR.Close()
+	}
+	if err != nil {
 		message := fmt.Sprintf("Start failed: %v", err)
 		svc.setError(message)
-		if isStderrLoggingEnabled() {
-			fmt.Fprintf(os.Stderr, "[%s] ERROR: %v\n", svc.name, err)
-		}
+		svc.countReconnect(model.CauseStartFailed)
+		m.stderrLog.error(svc.name, err.Error())
 		return
 	}
 
+	started := time.Now()
 	svc.mu.Lock()
 	svc.process = cmd.Process
+	svc.stdin = stdinW
 	svc.mu.Unlock()
+	if err := applyNice(cmd.Process, limits.Nice); err != nil {
+		svc.appendLog(fmt.Sprintf("Could not lower the priority to nice %d: %v", limits.Nice, err), false)
+	}
 
 	go func() {
 		<-ctx.Done()
-		// During a bulk shutdown, StopAllServices force-kills every process tree
-		// in one batched call, so skip the per-service kill here.
+		// During a bulk shutdown, StopAllServices kills the process trees that
+		// outlive their timeout, so only ask this one to exit: it may have
+		// started after the shutdown terminated the others.
 		if svc.bulkKill.Load() {
+			terminateProcessTree(cmd.Process)
 			return
 		}
 		killProcessTree(cmd.Process)
 	}()
 
-	go m.streamOutput(svc, stdoutPipe, false)
-	go m.streamOutput(svc, stderrPipe, true)
+	var streams sync.WaitGroup
+	streams.Add(1)
+	go func() { defer streams.Done(); m.streamOutput(svc, stdoutPipe, stderrPipe) }()
+	stopHealth := func() {}
+	if apiProxy || hasRemoteProbe {
+		var healthCtx context.Context
+		healthCtx, stopHealth = context.WithCancel(ctx)
+		if apiProxy {
+			go watchHealthz(healthCtx, svc)
+		}
+		if hasRemoteProbe {
+			go watchRemoteEnd(healthCtx, svc, remoteProbe, remoteCheck)
+		}
+	}
 
 	err = cmd.Wait()
+	ran := time.Since(started)
+	stopHealth()
+	drainOutput(&streams)
 
+	reset := m.reconnectBackoff().Reset
 	svc.mu.Lock()
-	svc.lastRunStable = !svc.healthySince.IsZero() && time.Since(svc.healthySince) >= healthyResetThreshold
+	svc.lastRunStable = !svc.healthySince.IsZero() && time.Since(svc.healthySince) >= reset
 	svc.process = nil
+	svc.stdin, svc.prompt = nil, ""
+	lastError := svc.lastError
+	stderr := slices.Clone(svc.stderrTail)
 	svc.mu.Unlock()
 
-	if err != nil && ctx.Err() == nil {
-		message := fmt.Sprintf("Process died: %v", err)
-		svc.setError(message)
+	if ctx.Err() != nil {
+		return // stopped or restarted, not a reconnect
+	}
+	svc.countReconnect(reconnectCause(err, lastError, svc.redial.Load()))
+	if err != nil && !svc.redial.Load() {
+		svc.setExit(newExit(cmd.ProcessState, ran, stderr))
+	}
+}
+
+// reconnectCause tells why a run ended, from its exit error and the last error
+// the service reported while it ran. redial is set when pf dropped the tunnel
+// itself.
+func reconnectCause(exitErr error, lastError string, redial bool) string {
+	switch {
+	case redial:
+		return model.CauseNetworkChange
+	case strings.HasPrefix(lastError, "healthz:"):
+		return model.CauseHealthCheck
+	case isNetworkError(lastError):
+		return model.CauseNetworkError
 	}
+	var exit *exec.ExitError
+	if errors.As(exitErr, &exit) {
+		if exit.ExitCode() < 0 {
+			return "killed"
+		}
+		return fmt.Sprintf("exit %d", exit.ExitCode())
+	}
+	return "exit 0"
 }
 
-const healthyResetThreshold = 30 * time.Second
+func (s *runningService) countReconnect(cause string) {
+	s.mu.Lock()
+	if s.reconnects == nil {
+		s.reconnects = make(map[string]int)
+	}
+	s.reconnects[cause]++
+	s.mu.Unlock()
+}
+
+// maxTransitions is how many status changes a service remembers.
+const maxTransitions = 20
 
 func nextRestartCount(prev int, lastRunStable bool) int {
 	if lastRunStable {
@@ -407,17 +1262,27 @@ func addKubectlCertFlags(command, certPath, keyPath string) string {
 	return out.String()
 }
 
-func killProcessTree(proc *os.Process) {
-	if proc == nil {
-		return
+func killProcessTree(p *os.Process) {
+	if p != nil {
+		proc.KillTree(p.Pid, false)
 	}
+}
+
+// killProcessTrees force-kills several process trees at once; see
+// proc.KillTrees.
+func killProcessTrees(procs []*os.Process) {
+	proc.KillTrees(pids(procs), false)
+}
 
-	if runtime.GOOS == "windows" {
-		pid := strconv.Itoa(proc.Pid)
-		exec.Command("taskkill", "/F", "/T", "/PID", pid).Run()
-	} else {
-		killUnixProcessGroup(proc.Pid)
+// pids lists the PIDs of procs, skipping nil ones.
+func pids(procs []*os.Process) []int {
+	pids := make([]int, 0, len(procs))
+	for _, p := range procs {
+		if p != nil {
+			pids = append(pids, p.Pid)
+		}
 	}
+	return pids
 }
 
 func waitForPortRelease(port string, timeout time.Duration) {
@@ -437,15 +1302,14 @@ func waitForPortRelease(port string, timeout time.Duration) {
 var shutdownGraceTimeout = 5 * time.Second
 
 // awaitStopOrKill waits for a cancelled service's loop to finish on its own
-// (svc.done closes once the process has exited), and force-kills the process
-// tree if it doesn't within the grace period. The caller must have already
-// invoked svc.cancel().
-func awaitStopOrKill(svc *runningService) {
-	if svc.done == nil {
+// (done, from svc.stop, closes once the process has exited), and force-kills
+// the process tree if it doesn't within the grace period.
+func awaitStopOrKill(svc *runningService, done chan struct{}) {
+	if done == nil {
 		return
 	}
 	select {
-	case <-svc.done:
+	case <-done:
 	case <-time.After(shutdownGraceTimeout):
 		svc.mu.RLock()
 		proc := svc.process
@@ -463,15 +1327,15 @@ func (m *ServiceManager) StopService(name string) {
 	}
 	delete(m.services, name)
 	m.mu.Unlock()
+	m.notify()
+	m.publish(events.Event{Kind: events.ServiceStopped, Service: name})
 
-	if svc.cancel != nil {
-		svc.cancel()
-	}
-
-	awaitStopOrKill(svc)
+	awaitStopOrKill(svc, svc.stop())
 }
 
-func (m *ServiceManager) restartInPlace(ctx context.Context, name string) {
+// restartInPlace restarts the service called name on its first command,
+// counting a reconnect under cause.
+func (m *ServiceManager) restartInPlace(ctx context.Context, name, cause string) {
 	m.mu.RLock()
 	svc, exists := m.services[name]
 	m.mu.RUnlock()
@@ -479,21 +1343,31 @@ func (m *ServiceManager) restartInPlace(ctx context.Context, name string) {
 	if !exists {
 		return
 	}
+	svc.restarting.Lock()
+	defer svc.restarting.Unlock()
 
-	if svc.cancel != nil {
-		svc.cancel()
+	svc.mu.RLock()
+	stopped, cancelRun, runDone := svc.stopped, svc.cancel, svc.done
+	svc.mu.RUnlock()
+	if stopped {
+		return
 	}
+	svc.countReconnect(cause)
 
-	if svc.done != nil {
+	if cancelRun != nil {
+		cancelRun()
+	}
+
+	if runDone != nil {
 		select {
-		case <-svc.done:
+		case <-runDone:
 		case <-time.After(5 * time.Second):
 			svc.mu.RLock()
 			proc := svc.process
 			svc.mu.RUnlock()
 			killProcessTree(proc)
 			select {
-			case <-svc.done:
+			case <-runDone:
 			case <-time.After(2 * time.Second):
 			}
 		}
@@ -505,13 +1379,22 @@ func (m *ServiceManager) restartInPlace(ctx context.Context, name string) {
 	done := make(chan struct{})
 
 	svc.mu.Lock()
-	svc.status = model.StatusConnecting
+	if svc.stopped {
+		// Stopped while the old run wound down: stay stopped.
+		svc.mu.Unlock()
+		cancel()
+		return
+	}
+	svc.setStatusLocked(model.StatusConnecting)
 	svc.lastError = ""
 	svc.startTime = time.Now()
 	svc.restartCount = 0
+	svc.useCommandLocked(0)
+	svc.failures, svc.degraded = 0, false
 	svc.cancel = cancel
 	svc.done = done
 	svc.mu.Unlock()
+	svc.changed()
 
 	go func() {
 		defer close(done)
@@ -520,7 +1403,7 @@ func (m *ServiceManager) restartInPlace(ctx context.Context, name string) {
 }
 
 func (m *ServiceManager) RestartService(ctx context.Context, name string) error {
-	go m.restartInPlace(ctx, name)
+	go m.restartInPlace(ctx, name, model.CauseRestart)
 	return nil
 }
 
@@ -533,57 +1416,30 @@ func (m *ServiceManager) RestartAllServices(ctx context.Context) {
 	m.mu.RUnlock()
 
 	for _, name := range names {
-		go m.restartInPlace(ctx, name)
+		go m.restartInPlace(ctx, name, model.CauseRestart)
 	}
 }
 
-func (m *ServiceManager) StartStoredService(ctx context.Context, name string) error {
+// isRunning reports whether a service called name is running.
+func (m *ServiceManager) isRunning(name string) bool {
 	m.mu.RLock()
+	defer m.mu.RUnlock()
 	_, exists := m.services[name]
-	m.mu.RUnlock()
+	return exists
+}
 
-	if exists {
+func (m *ServiceManager) StartStoredService(ctx context.Context, name string) error {
+	if m.isRunning(name) {
 		return fmt.Errorf("service '%s' is already running", name)
 	}
 
 	if _, err := m.storage.GetService(name); err != nil {
-		return fmt.Errorf("service '%s' not found in storage", name)
+		return fmt.Errorf("service '%s' %w in storage", name, storage.ErrNotFound)
 	}
 
 	return m.StartService(ctx, name)
 }
 
-// StopAllServices tears down every running service as fast as possible. It marks
-// each service for bulk kill and cancels it (so the loops stop and their own
-// ctx.Done watchers stand down), then force-kills all their process trees in a
-// single batched call. On Windows that means one taskkill spawn for the whole
-// fleet instead of one per service; on Unix each kill is a direct syscall.
-func (m *ServiceManager) StopAllServices() {
-	m.mu.Lock()
-	services := make([]*runningService, 0, len(m.services))
-	for _, svc := range m.services {
-		services = append(services, svc)
-		svc.bulkKill.Store(true)
-		if svc.cancel != nil {
-			svc.cancel()
-		}
-	}
-	m.services = make(map[string]*runningService)
-	m.mu.Unlock()
-
-	procs := make([]*os.Process, 0, len(services))
-	for _, svc := range services {
-		svc.mu.RLock()
-		proc := svc.process
-		svc.mu.RUnlock()
-		if proc != nil {
-			procs = append(procs, proc)
-		}
-	}
-
-	killProcessTrees(procs)
-}
-
 func (m *ServiceManager) ListServiceStates() []model.Service {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -600,27 +1456,52 @@ func (m *ServiceManager) ListServiceStates() []model.Service {
 	return states
 }
 
-func (m *ServiceManager) streamOutput(svc *runningService, reader io.Reader, isError bool) {
-	scanner := bufio.NewScanner(reader)
-	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+// streamOutput logs what a process prints on stdout and stderr until both
+// end. The pipes are read apart from the logging, through a lineQueue, so a
+// process flooding its output is never held up by pf.
+func (m *ServiceManager) streamOutput(svc *runningService, stdout, stderr io.Reader) {
+	q := make(lineQueue, lineQueueSize)
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go func() { defer readers.Done(); readOutput(svc, stdout, false, q) }()
+	go func() { defer readers.Done(); readOutput(svc, stderr, true, q) }()
+	go func() {
+		readers.Wait()
+		close(q)
+	}()
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
+	for out := range q {
+		line, isError := out.text, out.isError
+		svc.openStartGate()
+		svc.appendLog(line, isError)
+		if out.prompt {
+			if !svc.answerWithOTP(line) {
+				svc.setPrompt(line)
+			}
 			continue
 		}
-
-		svc.appendLog(line, isError)
+		svc.clearPrompt()
+		if isError {
+			svc.rememberStderr(line)
+			if isKubeconfigLockError(line) {
+				svc.kubeconfigLocked.Store(true)
+			}
+			if hint := m.hintFor(line); hint != "" {
+				svc.logHint(hint)
+			}
+		}
 
 		switch classifyOutputLine(line, isError) {
 		case lineKindHealthy:
 			svc.markHealthy()
+			m.stderrLog.recovered(svc.name)
 		case lineKindFatalError:
+			if svc.inMaintenance(time.Now()) {
+				continue // expected while the backend is down for maintenance
+			}
 			message := normalizeErrorLine(line)
 			svc.setError(message)
-			if isStderrLoggingEnabled() {
-				fmt.Fprintf(os.Stderr, "[%s] ERROR: %s\n", svc.name, message)
-			}
+			m.stderrLog.error(svc.name, message)
 		}
 	}
 }
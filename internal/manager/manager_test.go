@@ -9,14 +9,18 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alinemone/go-port-forward/internal/fakeforward"
 	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/storage"
 )
 
 // TestMain lets the test binary double as a tiny "arg printer": when
 // PF_ARGPRINT=1 it prints its arguments joined by "|" and exits. This is used by
 // TestNewShellCommandPreservesQuotedSpacedPath to observe exactly what arguments
-// a program receives after commandStr passes through the OS shell.
+// a program receives after commandStr passes through the OS shell. It is the
+// fake forwarder of the reconnect tests too.
 func TestMain(m *testing.M) {
+	fakeforward.Main()
 	if os.Getenv("PF_ARGPRINT") == "1" {
 		fmt.Println(strings.Join(os.Args[1:], "|"))
 		os.Exit(0)
@@ -56,13 +60,16 @@ func TestNewShellCommandPreservesQuotedSpacedPath(t *testing.T) {
 	}
 }
 
-// TestStopAllServicesDoesNotWait guards that bulk shutdown never blocks on a
-// service's graceful-exit channel: it cancels + force-kills and returns. Here
-// the services have no live process and a done channel that never closes, so a
-// correct implementation must still return effectively immediately.
-func TestStopAllServicesDoesNotWait(t *testing.T) {
+// TestStopAllServicesBoundsTheWait guards that bulk shutdown never blocks on a
+// service's graceful-exit channel past its timeout. Here the services have no
+// live process and a done channel that never closes, so a correct
+// implementation must return once the (shared) timeout has passed.
+func TestStopAllServicesBoundsTheWait(t *testing.T) {
 	const n = 8
-	m := &ServiceManager{services: make(map[string]*runningService)}
+	m := &ServiceManager{
+		services: make(map[string]*runningService),
+		shutdown: storage.ShutdownConfig{Timeout: "50ms"},
+	}
 	cancelled := make([]bool, n)
 	for i := 0; i < n; i++ {
 		i := i
@@ -84,7 +91,10 @@ func TestStopAllServicesDoesNotWait(t *testing.T) {
 	elapsed := time.Since(start)
 
 	if elapsed > 500*time.Millisecond {
-		t.Errorf("StopAllServices blocked for %v; it must not wait on done", elapsed)
+		t.Errorf("StopAllServices blocked for %v; it must not wait on done past the timeout", elapsed)
+	}
+	if len(m.services) != 0 {
+		t.Errorf("%d services left after StopAllServices", len(m.services))
 	}
 	for i := range cancelled {
 		if !cancelled[i] {
@@ -287,6 +297,19 @@ func TestEnsureValidServiceName(t *testing.T) {
 	}
 }
 
+func TestEnsureValidRunName(t *testing.T) {
+	for _, name := range []string{"db", "corp/db"} {
+		if err := ensureValidRunName(name); err != nil {
+			t.Errorf("ensureValidRunName(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"corp/", "/db", "corp/a/b", "corp/.."} {
+		if err := ensureValidRunName(name); err == nil {
+			t.Errorf("ensureValidRunName(%q) should fail", name)
+		}
+	}
+}
+
 func TestEnsureValidCommand(t *testing.T) {
 	valid := []string{
 		"kubectl port-forward svc/db 5432:5432",
@@ -406,3 +429,128 @@ func containsSubstr(s, substr string) bool {
 	}
 	return false
 }
+
+func TestUpdatesCoalesceAndNeverBlock(t *testing.T) {
+	m := &ServiceManager{
+		services: make(map[string]*runningService),
+		updates:  make(chan struct{}, 1),
+	}
+	svc := &runningService{name: "db", onChange: m.notify}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			svc.appendLog("line", false)
+		}
+		svc.setError("boom")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("appendLog blocked with no Updates reader")
+	}
+
+	select {
+	case <-m.Updates():
+	default:
+		t.Fatal("expected a pending update signal")
+	}
+	select {
+	case <-m.Updates():
+		t.Fatal("signals should be coalesced into one")
+	default:
+	}
+}
+
+func TestStatusTransitionsAreLogged(t *testing.T) {
+	svc := &runningService{name: "db", status: model.StatusConnecting}
+
+	svc.markHealthy()
+	svc.markHealthy() // no transition, no extra marker
+	svc.setError("lost connection to pod")
+
+	var markers []model.LogEntry
+	for _, e := range svc.snapshot().Logs {
+		if e.Kind == model.LogKindStatus {
+			markers = append(markers, e)
+		}
+	}
+	if len(markers) != 2 {
+		t.Fatalf("got %d status markers, want 2: %+v", len(markers), markers)
+	}
+	if markers[0].Status != model.StatusHealthy || !strings.Contains(markers[0].Message, "CONNECTING → HEALTHY") {
+		t.Errorf("first marker = %+v", markers[0])
+	}
+	if markers[1].Status != model.StatusError || !strings.Contains(markers[1].Message, "HEALTHY → ERROR") {
+		t.Errorf("second marker = %+v", markers[1])
+	}
+}
+
+func TestReconnectCause(t *testing.T) {
+	exit1 := newShellCommand("exit 1").Run()
+	for _, tt := range []struct {
+		err       error
+		lastError string
+		redial    bool
+		want      string
+	}{
+		{exit1, "", true, model.CauseNetworkChange},
+		{exit1, "healthz: 503 Service Unavailable", false, model.CauseHealthCheck},
+		{exit1, "error: dial tcp 10.0.0.1:443: i/o timeout", false, model.CauseNetworkError},
+		{exit1, "error: lost connection to pod", false, "exit 1"},
+		{nil, "", false, "exit 0"},
+	} {
+		if got := reconnectCause(tt.err, tt.lastError, tt.redial); got != tt.want {
+			t.Errorf("reconnectCause(%v, %q, %v) = %q, want %q", tt.err, tt.lastError, tt.redial, got, tt.want)
+		}
+	}
+}
+
+func TestStatusTransitionsAreBounded(t *testing.T) {
+	svc := &runningService{name: "db", status: model.StatusConnecting, statusSince: time.Now().Add(-time.Minute)}
+	svc.mu.Lock()
+	svc.setStatusLocked(model.StatusHealthy)
+	svc.mu.Unlock()
+	first := svc.snapshot().Transitions
+	if len(first) != 1 || first[0].From != model.StatusConnecting || first[0].To != model.StatusHealthy || first[0].Duration < time.Minute {
+		t.Fatalf("transitions = %+v", first)
+	}
+
+	for i := 0; i < maxTransitions; i++ {
+		svc.setError("boom")
+		svc.mu.Lock()
+		svc.setStatusLocked(model.StatusHealthy)
+		svc.mu.Unlock()
+	}
+	got := svc.snapshot().Transitions
+	if len(got) != maxTransitions {
+		t.Fatalf("kept %d transitions, want %d", len(got), maxTransitions)
+	}
+	if last := got[len(got)-1]; last.From != model.StatusError || last.To != model.StatusHealthy {
+		t.Errorf("latest transition = %+v", last)
+	}
+}
+
+func TestStatusChangesAddUpToMetrics(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	svc := &runningService{name: "db", status: model.StatusConnecting, statusSince: start}
+	step := func(status string, at time.Time) {
+		svc.mu.Lock()
+		svc.countStatusLocked(svc.status, status, at)
+		svc.status, svc.statusSince = status, at
+		svc.mu.Unlock()
+	}
+	step(model.StatusHealthy, start.Add(2*time.Second)) // a 2s connect
+	step(model.StatusError, start.Add(32*time.Second))  // healthy for 30s
+	step(model.StatusConnecting, start.Add(40*time.Second))
+	step(model.StatusHealthy, start.Add(44*time.Second)) // a 4s connect
+
+	m := svc.snapshot().Metrics
+	if m.Errors != 1 || m.Connects != 2 || m.AvgConnect() != 3*time.Second {
+		t.Errorf("metrics = %+v", m)
+	}
+	if up := m.Uptime(start.Add(54 * time.Second)); up != 40*time.Second {
+		t.Errorf("uptime = %v, want 30s + the 10s of the current spell", up)
+	}
+}
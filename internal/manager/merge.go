@@ -0,0 +1,304 @@
+package manager
+
+import (
+	"context"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/endpoint"
+	"github.com/alinemone/go-port-forward/internal/events"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// mergeSettle is how long a merged kubectl waits after its services changed
+// before it starts again with their ports, so that services started together,
+// as by `pf run <group>`, share one start. A var so tests can shrink it.
+var mergeSettle = 300 * time.Millisecond
+
+// forwardedPortPattern finds the local port a kubectl line is about:
+// "Forwarding from 127.0.0.1:5432 -> 5432" or "Handling connection for 5432".
+var forwardedPortPattern = regexp.MustCompile(`Forwarding from \S+:(\d+) ->|Handling connection for (\d+)`)
+
+// mergeGroup runs the services whose kubectl port-forwards differ only in
+// their ports (see cmdline.MergeablePortForward) as one kubectl forwarding all
+// of them, with "mergeForwards" on. That kubectl belongs to a carrier, a
+// service of its own that no frontend sees and that reconnects like any
+// other; each member keeps its own row, which mirrors the carrier's status,
+// is healthy once kubectl forwards its port, and logs what kubectl prints
+// about its port and about all of them. A member joining or leaving restarts
+// the carrier with the ports left, after mergeSettle.
+type mergeGroup struct {
+	m    *ServiceManager
+	rest string
+	ctx  context.Context // the carrier's parent
+
+	mu      sync.Mutex
+	members []*runningService
+	carrier *runningService // nil until the first start
+	up      map[string]bool // the local ports the carrier's kubectl forwards
+	timer   *time.Timer     // the pending restart
+
+	// applyMu is held while apply replaces the carrier.
+	applyMu sync.Mutex
+}
+
+// runMerged runs svc as a member of its merge group until ctx ends. It
+// reports false, having done nothing, when svc cannot join: another member
+// forwards its local port, or a program outside pf holds it, which would
+// fail the others too. svc then runs on its own.
+func (m *ServiceManager) runMerged(ctx context.Context, svc *runningService) bool {
+	g := m.joinMerged(ctx, svc)
+	if g == nil {
+		return false
+	}
+	<-ctx.Done()
+	g.leave(svc)
+	return true
+}
+
+func (m *ServiceManager) joinMerged(ctx context.Context, svc *runningService) *mergeGroup {
+	svc.mu.RLock()
+	key, port, host := svc.mergeKey, svc.localPort, endpoint.DialHost(svc.forward.Address)
+	svc.mu.RUnlock()
+	busy := portInUse(host, port)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	g := m.merged[key]
+	if g == nil {
+		if busy {
+			return nil
+		}
+		if m.merged == nil {
+			m.merged = make(map[string]*mergeGroup)
+		}
+		g = &mergeGroup{m: m, rest: key, ctx: context.WithoutCancel(ctx), up: make(map[string]bool)}
+		m.merged[key] = g
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if slices.ContainsFunc(g.members, func(other *runningService) bool { return other.localPort == port }) {
+		return nil
+	}
+	if busy && !slices.Contains(g.carrierPorts(), port) {
+		return nil // not just the carrier's kubectl still forwarding it
+	}
+	g.members = append(g.members, svc)
+	g.restartLocked()
+	return g
+}
+
+// carrierPorts lists the local ports the carrier was started with. The caller
+// holds g.mu.
+func (g *mergeGroup) carrierPorts() []string {
+	var ports []string
+	if g.carrier == nil {
+		return nil
+	}
+	_, specs, _ := cmdline.MergeablePortForward(g.carrier.command)
+	for _, spec := range specs {
+		local, _, _ := strings.Cut(spec, ":")
+		ports = append(ports, local)
+	}
+	return ports
+}
+
+// leave takes svc out of the group. The last member to leave stops the
+// carrier and waits for its kubectl to end, as a session may be ending.
+func (g *mergeGroup) leave(svc *runningService) {
+	g.m.mu.Lock()
+	g.mu.Lock()
+	g.members = slices.DeleteFunc(g.members, func(s *runningService) bool { return s == svc })
+	empty := len(g.members) == 0
+	if empty {
+		if g.m.merged[g.rest] == g {
+			delete(g.m.merged, g.rest)
+		}
+		if g.timer != nil {
+			g.timer.Stop()
+		}
+	} else {
+		g.restartLocked()
+	}
+	g.mu.Unlock()
+	g.m.mu.Unlock()
+
+	svc.mu.Lock()
+	svc.process = nil
+	svc.mu.Unlock()
+	if empty {
+		g.apply()
+	}
+}
+
+// restartLocked has apply run after mergeSettle, or mergeSettle after the
+// latest change when more follow. The caller holds g.mu.
+func (g *mergeGroup) restartLocked() {
+	if g.timer == nil {
+		g.timer = time.AfterFunc(mergeSettle, g.apply)
+		return
+	}
+	g.timer.Reset(mergeSettle)
+}
+
+// apply stops the carrier and starts a new one with the members' ports,
+// unless none are left.
+func (g *mergeGroup) apply() {
+	g.applyMu.Lock()
+	defer g.applyMu.Unlock()
+
+	g.mu.Lock()
+	old := g.carrier
+	g.carrier = nil
+	clear(g.up)
+	members := slices.Clone(g.members)
+	g.mu.Unlock()
+	if old != nil {
+		awaitStopOrKill(old, old.stop())
+	}
+	if len(members) == 0 {
+		return
+	}
+
+	slices.SortFunc(members, func(a, b *runningService) int { return strings.Compare(a.name, b.name) })
+	var names, ports []string
+	for _, s := range members {
+		s.mu.RLock()
+		names = append(names, s.name)
+		ports = append(ports, s.mergePorts...)
+		s.mu.RUnlock()
+	}
+	command := cmdline.MergedPortForward(g.rest, ports)
+	for _, s := range members {
+		s.appendLog("Forwarding through one kubectl with "+strings.Join(names, ", ")+": "+command, false)
+	}
+
+	ctx, cancel := context.WithCancel(g.ctx)
+	now := time.Now()
+	carrier := &runningService{
+		name:        strings.Join(names, "+"),
+		command:     command,
+		commands:    []string{command},
+		forward:     cmdline.ParseForward(command),
+		status:      model.StatusConnecting,
+		statusSince: now,
+		startTime:   now,
+		logs:        newLogRing(maxLogEntries),
+		retrySoon:   make(chan struct{}, 1),
+		cancel:      cancel,
+		done:        make(chan struct{}),
+		onChange:    g.sync,
+		publish:     g.route,
+	}
+	g.mu.Lock()
+	g.carrier = carrier
+	g.mu.Unlock()
+	go func() {
+		defer close(carrier.done)
+		g.m.runServiceLoop(ctx, carrier)
+	}()
+}
+
+// route hands a line the carrier logged to the member whose port it is about,
+// or to every member when it is about none, and keeps track of the ports
+// kubectl forwards. It is the carrier's publish hook, so it runs under the
+// carrier's lock.
+func (g *mergeGroup) route(e events.Event) {
+	if e.Kind == events.ServiceStatus && e.To != model.StatusHealthy {
+		g.mu.Lock()
+		clear(g.up) // kubectl forwards nothing until it says so again
+		g.mu.Unlock()
+	}
+	if e.Kind != events.ServiceLog || e.Log.Kind == model.LogKindStatus {
+		return // members log their own status changes
+	}
+	port := ""
+	if match := forwardedPortPattern.FindStringSubmatch(e.Log.Message); match != nil {
+		port = match[1] + match[2]
+	}
+	g.mu.Lock()
+	if port != "" && strings.HasPrefix(e.Log.Message, "Forwarding from") {
+		g.up[port] = true
+	}
+	var to []*runningService
+	for _, s := range g.members {
+		if port == "" || slices.Contains(memberPorts(s), port) {
+			to = append(to, s)
+		}
+	}
+	if len(to) == 0 {
+		to = slices.Clone(g.members)
+	}
+	g.mu.Unlock()
+	for _, s := range to {
+		s.appendEntry(e.Log)
+	}
+}
+
+// memberPorts lists the local ports of a member's forward.
+func memberPorts(s *runningService) []string {
+	ports := make([]string, 0, len(s.mergePorts))
+	for _, spec := range s.mergePorts {
+		local, _, _ := strings.Cut(spec, ":")
+		ports = append(ports, local)
+	}
+	return ports
+}
+
+// sync brings the members in line with the carrier: its status, its error
+// and how its kubectl last died, except that a member is only healthy once
+// kubectl forwards its port. It is the carrier's onChange hook.
+func (g *mergeGroup) sync() {
+	g.mu.Lock()
+	c := g.carrier
+	members := slices.Clone(g.members)
+	g.mu.Unlock()
+	if c == nil {
+		return
+	}
+	c.mu.RLock()
+	status, lastError, lastExit, proc, restarts := c.status, c.lastError, c.lastExit, c.process, c.restartCount
+	c.mu.RUnlock()
+
+	g.mu.Lock()
+	up := maps.Clone(g.up)
+	g.mu.Unlock()
+	for _, s := range members {
+		st := status
+		if st == model.StatusHealthy && !slices.ContainsFunc(memberPorts(s), func(p string) bool { return up[p] }) {
+			st = model.StatusConnecting
+		}
+		s.mirror(st, lastError, lastExit, proc, restarts)
+	}
+}
+
+// mirror sets a member's state from its carrier's.
+func (s *runningService) mirror(status, lastError string, lastExit *model.Exit, proc *os.Process, restarts int) {
+	s.mu.Lock()
+	s.process = proc
+	s.restartCount = restarts
+	s.mu.Unlock()
+	if status == model.StatusHealthy {
+		s.markHealthy()
+		return
+	}
+	s.mu.Lock()
+	changed := s.setStatusLocked(status)
+	if status == model.StatusError && s.lastError != lastError {
+		s.lastError, changed = lastError, true
+	}
+	if lastExit != s.lastExit {
+		s.lastExit, changed = lastExit, true
+	}
+	s.healthySince = time.Time{}
+	s.mu.Unlock()
+	if changed {
+		s.changed()
+	}
+}
@@ -0,0 +1,116 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/fakeforward"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// fakeKubectl is a kubectl that records its arguments and prints kubectl's
+// "Forwarding from" line for each port it is given, then waits to be killed.
+const fakeKubectl = `#!/bin/sh
+echo "$@" >> "$(dirname "$0")/starts"
+shift 2
+for spec in "$@"; do
+	case "$spec" in
+	-*) ;;
+	*:*) echo "Forwarding from 127.0.0.1:${spec%%:*} -> ${spec#*:}" ;;
+	*) echo "Forwarding from 127.0.0.1:$spec -> $spec" ;;
+	esac
+done
+exec sleep 60
+`
+
+func TestMergedForwardsShareOneKubectl(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake kubectl is a shell script")
+	}
+	mergeSettle = 50 * time.Millisecond
+	t.Cleanup(func() { mergeSettle = 300 * time.Millisecond })
+	dir := t.TempDir()
+	kubectl := filepath.Join(dir, "kubectl")
+	if err := os.WriteFile(kubectl, []byte(fakeKubectl), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	p1, p2 := fakeforward.FreePort(t), fakeforward.FreePort(t)
+	st := fakeforward.NewStorage(t, map[string]string{
+		"db":      fmt.Sprintf("%s port-forward svc/postgres %d:5432", kubectl, p1),
+		"db-ro":   fmt.Sprintf("%s port-forward svc/postgres %d:5433", kubectl, p2),
+		"elsewhr": fmt.Sprintf("%s port-forward svc/redis %d:6379", kubectl, fakeforward.FreePort(t)),
+	})
+	config := filepath.Join(os.Getenv("HOME"), ".pf", "services.json")
+	data, _ := os.ReadFile(config)
+	var raw map[string]any
+	json.Unmarshal(data, &raw)
+	raw["mergeForwards"] = true
+	data, _ = json.Marshal(raw)
+	os.WriteFile(config, data, 0o600)
+
+	m := NewServiceManager(st)
+	m.SetBackoff(testBackoff)
+	t.Cleanup(m.StopAllServices)
+	for _, name := range []string{"db", "db-ro", "elsewhr"} {
+		if err := m.StartService(context.Background(), name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		healthy := 0
+		for _, s := range m.ListServiceStates() {
+			if s.Status == model.StatusHealthy {
+				healthy++
+			}
+		}
+		if healthy == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("services never all healthy: %+v", m.ListServiceStates())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	starts, _ := os.ReadFile(filepath.Join(dir, "starts"))
+	lines := strings.Split(strings.TrimSpace(string(starts)), "\n")
+	if len(lines) != 2 || !strings.Contains(string(starts), fmt.Sprintf("port-forward svc/postgres %d:5432 %d:5433", p1, p2)) {
+		t.Fatalf("kubectl starts = %q, want one for both postgres ports and one for redis", lines)
+	}
+
+	for _, s := range m.ListServiceStates() {
+		if s.Name != "db-ro" {
+			continue
+		}
+		var logs []string
+		for _, e := range s.Logs {
+			logs = append(logs, e.Message)
+		}
+		joined := strings.Join(logs, "\n")
+		if !strings.Contains(joined, fmt.Sprintf("Forwarding from 127.0.0.1:%d", p2)) || strings.Contains(joined, fmt.Sprintf("Forwarding from 127.0.0.1:%d", p1)) {
+			t.Errorf("db-ro should log the lines about its own port only: %v", logs)
+		}
+	}
+
+	// Stopping one member restarts kubectl with the other's port alone.
+	m.StopService("db")
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		starts, _ = os.ReadFile(filepath.Join(dir, "starts"))
+		if strings.Contains(string(starts), fmt.Sprintf("port-forward svc/postgres %d:5433\n", p2)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("kubectl not restarted without db: %q", starts)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
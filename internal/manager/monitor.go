@@ -0,0 +1,151 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/endpoint"
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/probe"
+)
+
+// monitorTimeout bounds one monitor check, and monitorHold is how long a TCP
+// check's connection must stay open (see probe.TCP). Vars so tests can
+// shrink them.
+var (
+	monitorTimeout = 5 * time.Second
+	monitorHold    = time.Second
+)
+
+// kubeReadyEndpoints returns the ready addresses of a Kubernetes Service. A
+// var so tests need no cluster.
+var kubeReadyEndpoints = func(ctx context.Context, m *ServiceManager, mon cmdline.Monitor) ([]string, error) {
+	args := []string{"get", "endpoints", mon.Kube, "-o", "jsonpath={.subsets[*].addresses[*].ip}"}
+	if mon.Namespace != "" {
+		args = append(args, "-n", mon.Namespace)
+	}
+	if m.certManager != nil {
+		if certConfig, exists := m.certManager.GetCertificate(); exists {
+			args = append([]string{"--client-certificate=" + certConfig.CertPath, "--client-key=" + certConfig.KeyPath}, args...)
+		}
+	}
+	out, err := exec.CommandContext(ctx, "kubectl", args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// monitorLabel describes what command checks when it is a monitor, and is
+// "" for a forward.
+func monitorLabel(command string) string {
+	if !cmdline.IsMonitor(command) {
+		return ""
+	}
+	mon, err := cmdline.ParseMonitor(command)
+	if err != nil {
+		return "?"
+	}
+	return mon.Describe()
+}
+
+// runMonitor is runServiceLoop for a monitor: no process, just a check every
+// mon.Every, on the session's shared pool (see checkScheduler), until ctx
+// ends. The service is healthy while its checks pass and in error otherwise;
+// each new kind of failure is logged once.
+func (m *ServiceManager) runMonitor(ctx context.Context, svc *runningService) {
+	svc.mu.RLock()
+	mon, err := cmdline.ParseMonitor(svc.command)
+	svc.mu.RUnlock()
+	if err != nil {
+		svc.setError(err.Error())
+		return
+	}
+
+	lastErr := ""
+	// The first check waits a moment, so a forward started alongside can bind.
+	stop := healthChecks.every(ctx, svc.name, min(time.Second, mon.Every), mon.Every, func() {
+		if svc.inMaintenance(time.Now()) {
+			return
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, monitorTimeout)
+		err := m.checkMonitor(checkCtx, svc.name, mon)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			lastErr = ""
+			svc.markHealthy()
+			return
+		}
+		if message := err.Error(); message != lastErr {
+			lastErr = message
+			svc.appendLog(message, true)
+		}
+		svc.setError(lastErr)
+	})
+	<-ctx.Done()
+	stop()
+}
+
+// checkMonitor runs a check of mon, the monitor name: through the probe it
+// names, or else pf's own.
+func (m *ServiceManager) checkMonitor(ctx context.Context, name string, mon cmdline.Monitor) error {
+	if mon.Kube != "" {
+		ready, err := kubeReadyEndpoints(ctx, m, mon)
+		if err != nil {
+			return fmt.Errorf("kube: %v", err)
+		}
+		if len(ready) == 0 {
+			return fmt.Errorf("%s has no ready endpoints", mon.Describe())
+		}
+		return nil
+	}
+
+	var p probe.HealthProbe
+	switch {
+	case mon.Probe != "":
+		dir, err := probe.Dir()
+		if err != nil {
+			return err
+		}
+		plugin, err := probe.Find(dir, mon.Probe)
+		if err != nil {
+			return err
+		}
+		p = plugin
+	case mon.HTTPPath != "":
+		p = probe.HTTP{Path: mon.HTTPPath, Client: checkClient}
+	default:
+		p = probe.TCP{Hold: monitorHold}
+	}
+
+	target := probe.Target{Monitor: name, Via: mon.Via, Params: mon.Params}
+	if mon.Via != "" {
+		m.mu.RLock()
+		via, ok := m.services[mon.Via]
+		m.mu.RUnlock()
+		if !ok {
+			return fmt.Errorf("'%s' is not running in this session", mon.Via)
+		}
+		via.mu.RLock()
+		status := via.status
+		target.Address = net.JoinHostPort(endpoint.DialHost(via.forward.Address), via.localPort)
+		via.mu.RUnlock()
+		if status != model.StatusHealthy {
+			return fmt.Errorf("'%s' is %s", mon.Via, status)
+		}
+	}
+	return p.Check(ctx, target)
+}
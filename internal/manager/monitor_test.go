@@ -0,0 +1,132 @@
+package manager
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestCheckMonitorThroughAForward(t *testing.T) {
+	origHold := monitorHold
+	monitorHold = 50 * time.Millisecond
+	defer func() { monitorHold = origHold }()
+
+	// Like kubectl port-forward with the pod gone: accept, then hang up.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	hangUp := make(chan bool, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if <-hangUp {
+				conn.Close()
+			} else {
+				defer conn.Close()
+			}
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	db := &runningService{name: "db", localPort: port, status: model.StatusHealthy}
+	m := &ServiceManager{services: map[string]*runningService{"db": db}}
+	mon := cmdline.Monitor{Via: "db"}
+
+	hangUp <- false
+	if err := m.checkMonitor(context.Background(), "up", mon); err != nil {
+		t.Errorf("an open connection should pass: %v", err)
+	}
+	hangUp <- true
+	if err := m.checkMonitor(context.Background(), "up", mon); err == nil || !strings.Contains(err.Error(), "dropped") {
+		t.Errorf("a dropped connection should fail, got %v", err)
+	}
+
+	db.status = model.StatusConnecting
+	if err := m.checkMonitor(context.Background(), "up", mon); err == nil || err.Error() != "'db' is connecting" {
+		t.Errorf("a forward that is down should fail, got %v", err)
+	}
+	if err := m.checkMonitor(context.Background(), "up", cmdline.Monitor{Via: "cache"}); err == nil {
+		t.Error("a forward that is not running should fail")
+	}
+}
+
+func TestCheckMonitorHTTPAndKube(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer api.Close()
+	_, port, _ := net.SplitHostPort(api.Listener.Addr().String())
+	m := &ServiceManager{services: map[string]*runningService{
+		"api": {name: "api", localPort: port, status: model.StatusHealthy},
+	}}
+	if err := m.checkMonitor(context.Background(), "up", cmdline.Monitor{Via: "api", HTTPPath: "/healthz"}); err != nil {
+		t.Errorf("GET /healthz should pass: %v", err)
+	}
+	if err := m.checkMonitor(context.Background(), "up", cmdline.Monitor{Via: "api", HTTPPath: "/ready"}); err == nil || err.Error() != "GET /ready: 503 Service Unavailable" {
+		t.Errorf("GET /ready should fail, got %v", err)
+	}
+
+	orig := kubeReadyEndpoints
+	defer func() { kubeReadyEndpoints = orig }()
+	var ready []string
+	kubeReadyEndpoints = func(_ context.Context, _ *ServiceManager, mon cmdline.Monitor) ([]string, error) {
+		if mon.Namespace != "data" || mon.Kube != "postgres" {
+			t.Errorf("asked about %+v", mon)
+		}
+		return ready, nil
+	}
+	mon := cmdline.Monitor{Kube: "postgres", Namespace: "data"}
+	if err := m.checkMonitor(context.Background(), "up", mon); err == nil || err.Error() != "kube data/postgres has no ready endpoints" {
+		t.Errorf("no endpoints should fail, got %v", err)
+	}
+	ready = []string{"10.0.0.7"}
+	if err := m.checkMonitor(context.Background(), "up", mon); err != nil {
+		t.Errorf("a ready endpoint should pass: %v", err)
+	}
+}
+
+func TestCheckMonitorRunsProbePlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	dir := filepath.Join(home, ".pf", "plugins", "probes")
+	os.MkdirAll(dir, 0o755)
+	script := `#!/bin/sh
+case "$(cat)" in *'"address":"127.0.0.1:5432"'*) echo '{"healthy": true}';; *) echo '{"healthy": false, "message": "no address"}';; esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "pg-lag"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	m := &ServiceManager{services: map[string]*runningService{
+		"db": {name: "db", localPort: "5432", status: model.StatusHealthy},
+	}}
+	if err := m.checkMonitor(context.Background(), "db-lag", cmdline.Monitor{Via: "db", Probe: "pg-lag"}); err != nil {
+		t.Errorf("the plugin should get db's address and pass: %v", err)
+	}
+	if err := m.checkMonitor(context.Background(), "lag", cmdline.Monitor{Probe: "pg-lag"}); err == nil || err.Error() != "no address" {
+		t.Errorf("without --via the plugin gets no address, got %v", err)
+	}
+	if err := m.checkMonitor(context.Background(), "vpn", cmdline.Monitor{Probe: "vpn"}); err == nil || !strings.Contains(err.Error(), `no probe plugin "vpn"`) {
+		t.Errorf("a missing plugin should fail, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+	"github.com/alinemone/go-port-forward/internal/totp"
+)
+
+// otpSource hands out a service's one-time codes; see storage.OTP.
+type otpSource struct {
+	key    []byte
+	digits int
+	period time.Duration
+	prompt *regexp.Regexp // the prompts it answers
+
+	mu   sync.Mutex
+	last string // the code handed out last: a bastion takes each code once
+}
+
+// newOTPSource reads the generator's secret from the keyring.
+func newOTPSource(ctx context.Context, o storage.OTP) (*otpSource, error) {
+	digits, period, prompt, err := o.Settings()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := keyringLookup(ctx, o.Service, o.Account)
+	if err != nil {
+		return nil, err
+	}
+	key, err := totp.Decode(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &otpSource{key: key, digits: digits, period: period, prompt: prompt}, nil
+}
+
+// next returns a code not handed out before: when the current period's code
+// was, it waits for the next period, or until ctx ends.
+func (s *otpSource) next(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	code := totp.Code(s.key, now, s.digits, s.period)
+	if code == s.last {
+		select {
+		case <-time.After(time.Until(totp.Next(now, s.period))):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		code = totp.Code(s.key, time.Now(), s.digits, s.period)
+	}
+	s.last = code
+	return code, nil
+}
+
+// answerWithOTP answers prompt with a one-time code when the service has a
+// generator and the prompt asks for a code. It reports whether it took the
+// prompt on; the answer follows once next has a fresh code.
+func (s *runningService) answerWithOTP(prompt string) bool {
+	s.mu.RLock()
+	otp, stdin := s.otp, s.stdin
+	s.mu.RUnlock()
+	if otp == nil || stdin == nil || !otp.prompt.MatchString(prompt) {
+		return false
+	}
+	go func() {
+		code, err := otp.next(context.Background())
+		if err == nil {
+			_, err = io.WriteString(stdin, code+"\n")
+		}
+		if err != nil {
+			s.appendLog(fmt.Sprintf("Could not answer with a one-time code: %v", err), true)
+			return
+		}
+		s.appendLog("Answered with a one-time code: "+prompt, false)
+	}()
+	return true
+}
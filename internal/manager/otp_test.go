@@ -0,0 +1,102 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// rfcSecret is RFC 6238's SHA-1 test key, base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func stubOTPSecret(t *testing.T) {
+	orig := keyringLookup
+	t.Cleanup(func() { keyringLookup = orig })
+	keyringLookup = func(_ context.Context, service, account string) (string, error) {
+		if service == "pf" && account == "db-otp" {
+			return rfcSecret, nil
+		}
+		return "", errors.New("not found")
+	}
+}
+
+func TestOTPSourceHandsOutEachCodeOnce(t *testing.T) {
+	stubOTPSecret(t)
+	src, err := newOTPSource(context.Background(), storage.OTP{Service: "pf", Account: "db-otp", Period: "1s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := src.next(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := src.next(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second || len(first) != 6 || len(second) != 6 {
+		t.Errorf("codes = %q, %q; want two different 6-digit codes", first, second)
+	}
+
+	hourly, err := newOTPSource(context.Background(), storage.OTP{Service: "pf", Account: "db-otp", Period: "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hourly.next(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := hourly.next(ctx); err == nil {
+		t.Error("waiting for a fresh code should end with ctx")
+	}
+
+	if _, err := newOTPSource(context.Background(), storage.OTP{Service: "pf", Account: "other"}); err == nil {
+		t.Error("a missing keyring secret should fail")
+	}
+}
+
+func TestOTPAnswersCodePrompts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	stubOTPSecret(t)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	os.MkdirAll(filepath.Join(home, ".pf"), 0o755)
+	config := `{"services": {"db": "printf 'Verification code: '; read code; echo \"got $code\"; sleep 30 # 5432:5432"},
+		"otp": {"db": {"service": "pf", "account": "db-otp"}}}`
+	if err := os.WriteFile(filepath.Join(home, ".pf", "services.json"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// No EnablePrompts: the code needs no frontend.
+	m := NewServiceManager(storage.NewStorage())
+	if err := m.StartService(context.Background(), "db"); err != nil {
+		t.Fatal(err)
+	}
+	defer m.StopAllServices()
+
+	got := regexp.MustCompile(`^got \d{6}$`)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		logs := m.ListServiceStates()[0].Logs
+		if slices.ContainsFunc(logs, func(e model.LogEntry) bool { return got.MatchString(e.Message) }) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the prompt was not answered with a code; logs: %v", logs)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if p := m.ListServiceStates()[0].Prompt; p != "" {
+		t.Errorf("prompt = %q, want it answered, not shown", p)
+	}
+}
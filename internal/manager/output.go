@@ -64,6 +64,23 @@ func isTransientPortForwardError(line string) bool {
 			strings.Contains(lower, "error copying from local connection to remote stream"))
 }
 
+// isNetworkError reports whether an error line points at the network between
+// here and the cluster or host (a dropped VPN, a sleeping laptop) rather than
+// at the remote end.
+func isNetworkError(line string) bool {
+	lower := strings.ToLower(line)
+	for _, sign := range []string{
+		"i/o timeout", "no route to host", "network is unreachable", "tls handshake timeout",
+		"connection timed out", "unable to connect to the server", "no such host",
+		"temporary failure in name resolution", "connection reset by peer",
+	} {
+		if strings.Contains(lower, sign) {
+			return true
+		}
+	}
+	return false
+}
+
 func normalizeErrorLine(line string) string {
 	if len(line) > 150 {
 		line = line[:147] + "..."
@@ -0,0 +1,27 @@
+package manager
+
+import (
+	"os"
+
+	"github.com/alinemone/go-port-forward/forwarder"
+)
+
+// hostExecutable is the pf that hosts forwarder plugins: this one. A var so
+// tests can host with a stand-in.
+var hostExecutable = os.Executable
+
+// withPluginHost returns how the service called name runs command: a
+// forwarder plugin command (see forwarder.Command) runs under pf itself as
+// the plugin's host, whose output reads like kubectl port-forward's, so the
+// service is health-checked, logged and restarted like any other. Other
+// commands run as they are.
+func withPluginHost(name, command string) (string, error) {
+	if !forwarder.IsCommand(command) {
+		return command, nil
+	}
+	exe, err := hostExecutable()
+	if err != nil {
+		return "", err
+	}
+	return forwarder.HostCommand(exe, name, command)
+}
@@ -0,0 +1,47 @@
+package manager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/fakeforward"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestPluginCommandsRunUnderTheHost(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stand-in host is a shell script")
+	}
+	host := filepath.Join(t.TempDir(), "pf")
+	script := "#!/bin/sh\necho \"$1 for $2: $3 $4 $5 $6\"\necho 'Forwarding from 127.0.0.1:5432 -> gateway:5432'\nexec sleep 30\n"
+	if err := os.WriteFile(host, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	hostExecutable = func() (string, error) { return host, nil }
+	t.Cleanup(func() { hostExecutable = os.Executable })
+
+	m := NewServiceManager(fakeforward.NewStorage(t, map[string]string{"db": "plugin ztna 5432:5432 zone=eu"}))
+	if err := m.StartService(context.Background(), "db"); err != nil {
+		t.Fatal(err)
+	}
+	defer m.StopAllServices()
+
+	svc := waitFor(t, m, "healthy", func(s model.Service) bool { return s.Status == model.StatusHealthy })
+	want := "__forwarder-host for db: plugin ztna 5432:5432 zone=eu"
+	if !slices.ContainsFunc(svc.Logs, func(e model.LogEntry) bool { return e.Message == want }) {
+		t.Errorf("logs = %v, want %q", svc.Logs, want)
+	}
+}
+
+func TestValidateCommandChecksPluginCommands(t *testing.T) {
+	if err := ValidateCommand("plugin ztna 5432:5432 zone=eu"); err != nil {
+		t.Errorf("valid plugin command: %v", err)
+	}
+	if err := ValidateCommand("plugin ztna 5432"); err == nil {
+		t.Error("a plugin command without ports should fail")
+	}
+}
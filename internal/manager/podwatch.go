@@ -0,0 +1,198 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// podWatchRetry is how long a pod watch waits before it starts kubectl again
+// after it ended, and runPodWatch runs its command, writing what it prints to
+// out until ctx ends. Vars so tests can shrink the one and fake the other.
+var (
+	podWatchRetry = 10 * time.Second
+	runPodWatch   = shellStream
+)
+
+// podEvent is one event of `kubectl get pods --watch --output-watch-events
+// -o json`, with only what pf reads of the pod.
+type podEvent struct {
+	Type   string `json:"type"` // ADDED, MODIFIED or DELETED
+	Object struct {
+		Metadata struct {
+			Name              string  `json:"name"`
+			UID               string  `json:"uid"`
+			DeletionTimestamp *string `json:"deletionTimestamp"`
+		} `json:"metadata"`
+		Status struct {
+			Conditions []struct {
+				Type   string `json:"type"`
+				Status string `json:"status"`
+			} `json:"conditions"`
+		} `json:"status"`
+	} `json:"object"`
+}
+
+// gone reports whether the pod is deleted or being deleted.
+func (e podEvent) gone() bool {
+	return e.Type == "DELETED" || e.Object.Metadata.DeletionTimestamp != nil
+}
+
+// ready reports whether the pod takes traffic.
+func (e podEvent) ready() bool {
+	if e.gone() {
+		return false
+	}
+	for _, c := range e.Object.Status.Conditions {
+		if c.Type == "Ready" {
+			return c.Status == "True"
+		}
+	}
+	return false
+}
+
+// podWatchHub runs one pod watch per command (see cmdline.PodWatch) for all
+// the services that follow a pod through it, instead of one per service:
+// forwards into the same namespace share a kubectl. A watch runs while it has
+// subscribers. The zero value is ready to use.
+type podWatchHub struct {
+	mu      sync.Mutex
+	watches map[string]*podWatch
+}
+
+// podWatches are the session's pod watches.
+var podWatches podWatchHub
+
+type podWatch struct {
+	subs   map[*podSub]bool
+	cancel context.CancelFunc
+}
+
+type podSub struct {
+	pod     string
+	onEvent func(podEvent)
+}
+
+// subscribe calls onEvent with each event of pod that command's watch sees,
+// starting the watch if none runs, until unsubscribe is called. onEvent runs
+// on the watch's goroutine, so it must not hold it up.
+func (h *podWatchHub) subscribe(command, pod string, onEvent func(podEvent)) (unsubscribe func()) {
+	sub := &podSub{pod: pod, onEvent: onEvent}
+	h.mu.Lock()
+	if h.watches == nil {
+		h.watches = make(map[string]*podWatch)
+	}
+	w, ok := h.watches[command]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		w = &podWatch{subs: make(map[*podSub]bool), cancel: cancel}
+		h.watches[command] = w
+		go h.run(ctx, command, w)
+	}
+	w.subs[sub] = true
+	h.mu.Unlock()
+
+	return sync.OnceFunc(func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(w.subs, sub)
+		if len(w.subs) == 0 {
+			w.cancel()
+			delete(h.watches, command)
+		}
+	})
+}
+
+// run keeps command running until ctx ends, handing the events it prints to
+// w's subscribers. kubectl sends every pod again when it starts, so a restart
+// loses nothing that matters.
+func (h *podWatchHub) run(ctx context.Context, command string, w *podWatch) {
+	for {
+		runCtx, cancel := context.WithCancel(ctx)
+		r, out := io.Pipe()
+		go func() { out.CloseWithError(runPodWatch(runCtx, command, out)) }()
+		dec := json.NewDecoder(r)
+		for {
+			var e podEvent
+			if dec.Decode(&e) != nil {
+				break
+			}
+			h.dispatch(w, e)
+		}
+		cancel()
+		r.Close()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(podWatchRetry):
+		}
+	}
+}
+
+func (h *podWatchHub) dispatch(w *podWatch, e podEvent) {
+	h.mu.Lock()
+	var subs []*podSub
+	for sub := range w.subs {
+		if sub.pod == e.Object.Metadata.Name {
+			subs = append(subs, sub)
+		}
+	}
+	h.mu.Unlock()
+	for _, sub := range subs {
+		sub.onEvent(e)
+	}
+}
+
+// watchPod follows the pod a forward of svc goes to, through the session's
+// shared pod watch, until ctx ends. When the pod is replaced, as a
+// StatefulSet's is on a rollout or eviction, kubectl would keep forwarding to
+// the old one, or fail and back off while the new one starts; once the new
+// one is ready, svc reconnects to it at once.
+func watchPod(ctx context.Context, svc *runningService, w cmdline.PodWatch) {
+	var mu sync.Mutex
+	uid := "" // the pod as it was when last ready
+	leaving := false
+	unsubscribe := podWatches.subscribe(w.Command, w.Pod, func(e podEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case e.gone():
+			if e.Object.Metadata.UID == uid && !leaving {
+				leaving = true
+				svc.appendLog(fmt.Sprintf("Pod %s is going away; reconnecting once its replacement is ready", w.Pod), false)
+			}
+		case e.ready() && e.Object.Metadata.UID != uid:
+			replaced := uid != ""
+			uid, leaving = e.Object.Metadata.UID, false
+			svc.mu.RLock()
+			current, _ := cmdline.PodWatchFor(svc.command)
+			svc.mu.RUnlock()
+			if replaced && current == w { // not while on an alternate
+				svc.appendMarker(model.LogKindReconnect, fmt.Sprintf("━━━━ pod/%s replaced, reconnecting ━━━━", w.Pod))
+				svc.redialNow()
+			}
+		}
+	})
+	<-ctx.Done()
+	unsubscribe()
+}
+
+// shellStream runs command with the shell, writing what it prints to out,
+// until it ends or ctx does.
+func shellStream(ctx context.Context, command string, out io.Writer) error {
+	cmd := newShellCommand(command)
+	cmd.Stdout = out
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { killProcessTree(cmd.Process) })
+	defer stop()
+	return cmd.Wait()
+}
@@ -0,0 +1,91 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+)
+
+func podEventJSON(typ, name, uid string, ready, deleting bool) string {
+	deletion := "null"
+	if deleting {
+		deletion = `"2026-01-01T00:00:00Z"`
+	}
+	status := "False"
+	if ready {
+		status = "True"
+	}
+	return fmt.Sprintf(`{"type": %q, "object": {"metadata": {"name": %q, "uid": %q, "deletionTimestamp": %s},
+		"status": {"conditions": [{"type": "Ready", "status": %q}]}}}`, typ, name, uid, deletion, status)
+}
+
+func TestWatchPodReconnectsOnceTheReplacementIsReady(t *testing.T) {
+	events := make(chan string)
+	var runs atomic.Int32
+	runPodWatch = func(ctx context.Context, command string, out io.Writer) error {
+		runs.Add(1)
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case e := <-events:
+				io.WriteString(out, e+"\n")
+			}
+		}
+	}
+	t.Cleanup(func() { runPodWatch = shellStream })
+
+	w, _ := cmdline.PodWatchFor("kubectl port-forward -n db pod/postgres-0 5432")
+	svc := &runningService{name: "db", command: "kubectl port-forward -n db pod/postgres-0 5432",
+		logs: newLogRing(20), retrySoon: make(chan struct{}, 1)}
+	other := &runningService{name: "replica", logs: newLogRing(20)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watchPod(ctx, svc, w)
+	go watchPod(ctx, other, cmdline.PodWatch{Command: w.Command, Pod: "postgres-1"})
+
+	send := func(e string) {
+		select {
+		case events <- e:
+		case <-time.After(2 * time.Second):
+			t.Fatal("the watch did not read the event")
+		}
+	}
+	send(podEventJSON("ADDED", "postgres-1", "b", true, false))
+	send(podEventJSON("ADDED", "postgres-0", "a", true, false))
+	send(podEventJSON("MODIFIED", "postgres-0", "a", true, true))
+	send(podEventJSON("DELETED", "postgres-0", "a", false, true))
+	send(podEventJSON("ADDED", "postgres-0", "c", false, false))
+	select {
+	case <-svc.retrySoon:
+		t.Fatal("reconnected before the replacement was ready")
+	default:
+	}
+	send(podEventJSON("MODIFIED", "postgres-0", "c", true, false))
+	select {
+	case <-svc.retrySoon:
+	case <-time.After(2 * time.Second):
+		t.Fatal("did not reconnect once the replacement was ready")
+	}
+
+	if n := runs.Load(); n != 1 {
+		t.Errorf("%d watches ran for one namespace, want 1", n)
+	}
+	var logs []string
+	for _, e := range svc.snapshot().Logs {
+		logs = append(logs, e.Message)
+	}
+	joined := strings.Join(logs, "\n")
+	if !strings.Contains(joined, "Pod postgres-0 is going away") || !strings.Contains(joined, "pod/postgres-0 replaced") {
+		t.Errorf("logs = %v", logs)
+	}
+	if len(other.snapshot().Logs) != 0 {
+		t.Errorf("another pod's churn was logged: %v", other.snapshot().Logs)
+	}
+}
@@ -1,61 +1,48 @@
 package manager
 
 import (
-	"strconv"
+	"fmt"
+	"net"
 	"strings"
+
+	"github.com/alinemone/go-port-forward/internal/proc"
 )
 
-func FreePort(port string) []int {
-	port = strings.TrimSpace(port)
-	if port == "" {
-		return nil
+// portBusy reports whether something already listens on port at host, as
+// pf's own listen would fail there, and which processes do, as far as the
+// system tells. pf never stops them itself: the user decides.
+func portBusy(host, port string) (bool, []proc.Process) {
+	if !portInUse(host, port) {
+		return false, nil
+	}
+	if !askPortOwners {
+		return true, nil
 	}
-	return killListenersOnPort(port)
+	return true, proc.ListListeners(port)
 }
 
-func parseNetstatListeners(output, port string) []int {
-	suffix := ":" + port
-	seen := make(map[int]bool)
-	var pids []int
+// askPortOwners is whether portBusy asks the system (lsof, netstat) who holds
+// a busy port; see UseLowPower.
+var askPortOwners = true
 
-	for _, line := range strings.Split(output, "\n") {
-		fields := strings.Fields(line)
-		if len(fields) < 5 {
-			continue
-		}
-		if !strings.EqualFold(fields[3], "LISTENING") {
-			continue
-		}
-		if !strings.HasSuffix(fields[1], suffix) {
-			continue
-		}
-		pid, err := strconv.Atoi(fields[len(fields)-1])
-		if err != nil || pid <= 0 {
-			continue
-		}
-		if !seen[pid] {
-			seen[pid] = true
-			pids = append(pids, pid)
-		}
+// portInUse is portBusy without asking who.
+func portInUse(host, port string) bool {
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return true
 	}
-
-	return pids
+	ln.Close()
+	return false
 }
 
-func parseLsofPIDs(output string) []int {
-	seen := make(map[int]bool)
-	var pids []int
-
-	for _, field := range strings.Fields(output) {
-		pid, err := strconv.Atoi(strings.TrimSpace(field))
-		if err != nil || pid <= 0 {
-			continue
-		}
-		if !seen[pid] {
-			seen[pid] = true
-			pids = append(pids, pid)
-		}
+// portConflictMessage describes a local port that another process holds.
+func portConflictMessage(port string, owners []proc.Process) string {
+	if len(owners) == 0 {
+		return fmt.Sprintf("Local port %s is already in use", port)
 	}
-
-	return pids
+	names := make([]string, len(owners))
+	for i, o := range owners {
+		names[i] = o.String()
+	}
+	return fmt.Sprintf("Local port %s is already in use by %s", port, strings.Join(names, ", "))
 }
@@ -1,44 +1,53 @@
 package manager
 
 import (
-	"reflect"
+	"context"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
-)
-
-func TestParseNetstatListeners(t *testing.T) {
-	output := `
-Active Connections
 
-  Proto  Local Address          Foreign Address        State           PID
-  TCP    0.0.0.0:5432           0.0.0.0:0              LISTENING       1234
-  TCP    127.0.0.1:5432         0.0.0.0:0              LISTENING       1234
-  TCP    [::1]:5432             [::]:0                 LISTENING       5678
-  TCP    127.0.0.1:15432        0.0.0.0:0              LISTENING       9999
-  TCP    127.0.0.1:6379         0.0.0.0:0              ESTABLISHED     4321
-`
+	"github.com/alinemone/go-port-forward/internal/fakeforward"
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/proc"
+)
 
-	got := parseNetstatListeners(output, "5432")
-	want := []int{1234, 5678}
-	if !reflect.DeepEqual(got, want) {
-		t.Errorf("parseNetstatListeners = %v, want %v", got, want)
+func TestPortConflictMessage(t *testing.T) {
+	owners := []proc.Process{{PID: 812, Name: "postgres"}, {PID: 9001, Name: "docker-proxy"}}
+	if msg := portConflictMessage("5432", owners); msg != "Local port 5432 is already in use by postgres (pid 812), docker-proxy (pid 9001)" {
+		t.Errorf("message = %q", msg)
 	}
-}
-
-func TestParseNetstatListenersNoMatch(t *testing.T) {
-	output := "  TCP    127.0.0.1:8080   0.0.0.0:0   LISTENING   100"
-	if got := parseNetstatListeners(output, "9090"); len(got) != 0 {
-		t.Errorf("expected no pids, got %v", got)
+	if msg := portConflictMessage("5432", nil); msg != "Local port 5432 is already in use" {
+		t.Errorf("message = %q", msg)
 	}
 }
 
-func TestParseLsofPIDs(t *testing.T) {
-	got := parseLsofPIDs("1234\n1234\n5678\n")
-	want := []int{1234, 5678}
-	if !reflect.DeepEqual(got, want) {
-		t.Errorf("parseLsofPIDs = %v, want %v", got, want)
+func TestBusyPortFailsWithoutStartingTheForward(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake forwarder is killed through a Unix shell")
 	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	fwd := fakeforward.New(t, ln.Addr().(*net.TCPAddr).Port, 5432)
+	m := NewServiceManager(fakeforward.NewStorage(t, map[string]string{"db": fwd.Command()}))
+	m.SetBackoff(testBackoff)
+	if err := m.StartService(context.Background(), "db"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.StopAllServices)
 
-	if got := parseLsofPIDs(""); len(got) != 0 {
-		t.Errorf("expected empty, got %v", got)
+	svc := waitFor(t, m, "reported the busy port", func(s model.Service) bool { return s.Status == model.StatusError })
+	if !strings.HasPrefix(svc.LastError, "Local port "+strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)+" is already in use") {
+		t.Errorf("last error = %q", svc.LastError)
 	}
+	if starts := fwd.Starts(); len(starts) != 0 {
+		t.Errorf("the forward started %d times on a busy port", len(starts))
+	}
+
+	ln.Close()
+	waitFor(t, m, "came up once the port was free", func(s model.Service) bool { return s.Status == model.StatusHealthy })
 }
@@ -6,6 +6,9 @@ import (
 	"os"
 	"os/exec"
 	"syscall"
+
+	"github.com/alinemone/go-port-forward/internal/proc"
+	"github.com/alinemone/go-port-forward/internal/storage"
 )
 
 // newShellCommand builds an *exec.Cmd that runs commandStr through sh -c. On
@@ -18,29 +21,34 @@ func newShellCommand(commandStr string) *exec.Cmd {
 	return cmd
 }
 
-// killProcessTrees force-kills several process trees. On Unix each kill is a
-// direct syscall (no process spawn), so a simple loop is already optimal.
-func killProcessTrees(procs []*os.Process) {
-	for _, p := range procs {
-		if p != nil {
-			killUnixProcessGroup(p.Pid)
-		}
-	}
-}
+// withDelayedExpansion is for cmd.exe's delayed expansion on Windows; sh
+// reads the variables of secrets.Expand as they are.
+func withDelayedExpansion(*exec.Cmd) {}
 
-func killUnixProcessGroup(pid int) {
-	syscall.Kill(-pid, syscall.SIGKILL)
+// newLimitedCommand is newShellCommand under the service's resource limits:
+// the shell sets the memory and file limits before it runs commandStr, and
+// applyNice lowers the priority once it has started.
+func newLimitedCommand(commandStr string, l storage.Limits) *exec.Cmd {
+	return newShellCommand(ulimitPrefix(l) + commandStr)
 }
 
-func killListenersOnPort(port string) []int {
-	out, err := exec.Command("lsof", "-ti", "tcp:"+port, "-sTCP:LISTEN").Output()
-	if err != nil {
+// applyNice lowers the priority of the started command's whole process group,
+// which its later children inherit.
+func applyNice(p *os.Process, nice int) error {
+	if nice == 0 || p == nil {
 		return nil
 	}
+	return syscall.Setpriority(syscall.PRIO_PGRP, p.Pid, nice)
+}
+
+// terminateProcessTrees asks several process trees to exit with SIGTERM, so
+// kubectl and ssh close their connections before a shutdown has to kill them.
+func terminateProcessTrees(procs []*os.Process) {
+	proc.KillTrees(pids(procs), true)
+}
 
-	pids := parseLsofPIDs(string(out))
-	for _, pid := range pids {
-		syscall.Kill(pid, syscall.SIGKILL)
+func terminateProcessTree(p *os.Process) {
+	if p != nil {
+		proc.KillTree(p.Pid, true)
 	}
-	return pids
 }
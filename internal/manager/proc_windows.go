@@ -5,8 +5,10 @@ package manager
 import (
 	"os"
 	"os/exec"
-	"strconv"
+	"strings"
 	"syscall"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
 )
 
 // newShellCommand builds an *exec.Cmd that runs commandStr through cmd.exe.
@@ -28,36 +30,46 @@ func newShellCommand(commandStr string) *exec.Cmd {
 	return cmd
 }
 
-// killProcessTrees force-kills several process trees in a single taskkill
-// invocation, so bulk shutdown costs one process spawn regardless of how many
-// services are running.
-func killProcessTrees(procs []*os.Process) {
-	args := make([]string, 0, 2+len(procs)*2)
-	args = append(args, "/F", "/T")
-	for _, p := range procs {
-		if p != nil {
-			args = append(args, "/PID", strconv.Itoa(p.Pid))
-		}
-	}
-	if len(args) == 2 { // no PIDs collected
-		return
-	}
-	exec.Command("taskkill", args...).Run()
+// withDelayedExpansion runs cmd, a newShellCommand, under cmd.exe's delayed
+// expansion, which reads the !NAME! references of secrets.Expand only after
+// the command line is parsed.
+func withDelayedExpansion(cmd *exec.Cmd) {
+	cmd.SysProcAttr.CmdLine = "cmd /V:ON /C " + strings.TrimPrefix(cmd.SysProcAttr.CmdLine, "cmd /C ")
 }
 
-func killUnixProcessGroup(pid int) {
-	// no-op on windows
-}
+// Process priority classes (see CreateProcess).
+const (
+	belowNormalPriorityClass = 0x00004000
+	idlePriorityClass        = 0x00000040
+)
 
-func killListenersOnPort(port string) []int {
-	out, err := exec.Command("netstat", "-ano", "-p", "tcp").Output()
-	if err != nil {
-		return nil
+// newLimitedCommand is newShellCommand under the service's resource limits.
+// Windows has no ulimit, so only Nice applies, as a lower priority class:
+// below normal, or idle from 15 up.
+func newLimitedCommand(commandStr string, l storage.Limits) *exec.Cmd {
+	cmd := newShellCommand(commandStr)
+	switch {
+	case l.Nice >= 15:
+		cmd.SysProcAttr.CreationFlags |= idlePriorityClass
+	case l.Nice > 0:
+		cmd.SysProcAttr.CreationFlags |= belowNormalPriorityClass
 	}
+	return cmd
+}
 
-	pids := parseNetstatListeners(string(out), port)
-	for _, pid := range pids {
-		exec.Command("taskkill", "/F", "/PID", strconv.Itoa(pid)).Run()
-	}
-	return pids
+// applyNice does nothing on Windows: newLimitedCommand already set the
+// priority class.
+func applyNice(*os.Process, int) error {
+	return nil
 }
+
+// terminateProcessTrees kills several process trees at once: Windows has no
+// signal a console program can be asked to exit with, so a shutdown does not
+// wait out a timeout there.
+func terminateProcessTrees(procs []*os.Process) {
+	killProcessTrees(procs)
+}
+
+// terminateProcessTree does nothing on Windows, where a shutdown kills what
+// terminateProcessTrees missed once its timeout passes.
+func terminateProcessTree(*os.Process) {}
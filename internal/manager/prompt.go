@@ -0,0 +1,160 @@
+package manager
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// AskpassVar marks a pf that ssh started as its askpass program; see Askpass.
+const AskpassVar = "PF_ASKPASS"
+
+// promptPattern matches what a process prints, without a newline, when it
+// waits for a password, passphrase or one-time code, or for an answer to a
+// yes/no question such as ssh's about an unknown host key.
+var promptPattern = regexp.MustCompile(`(?i)((password|passphrase|passcode|verification code|one-time|\botp\b|\btoken\b|\bpin\b)[^\n]*:|\(yes/no[^)\n]*\)\?)\s*$`)
+
+// isPrompt reports whether an unfinished line of output looks like a prompt.
+func isPrompt(partial []byte) bool {
+	return promptPattern.Match(partial)
+}
+
+// scanLinesOrPrompt is bufio.ScanLines that also hands over an unfinished
+// line when it looks like a prompt, which would otherwise wait for a newline
+// that only comes after the answer. It sets *prompt to whether the token is
+// such a line.
+func scanLinesOrPrompt(prompt *bool) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		*prompt = false
+		if advance == 0 && token == nil && err == nil && isPrompt(data) {
+			*prompt = true
+			return len(data), data, nil
+		}
+		return advance, token, err
+	}
+}
+
+// EnablePrompts lets services ask for input through a frontend: each process
+// started from now on gets a stdin that AnswerPrompt writes to, and ssh asks
+// for passwords through pf's askpass (see Askpass) instead of the terminal,
+// which a full-screen UI owns. Without it processes get no stdin, as a
+// frontend that cannot answer would leave them waiting for good.
+func (m *ServiceManager) EnablePrompts() {
+	m.mu.Lock()
+	m.prompts = true
+	m.mu.Unlock()
+}
+
+// promptStdin gives cmd a stdin pipe and the askpass environment when prompts
+// are enabled, or when pf answers the service's code prompts itself (see
+// storage.OTP). It returns the pipe's ends; the caller closes the read end
+// once the process started and the write end once it ended. Both are nil
+// when neither applies.
+func (m *ServiceManager) promptStdin(cmd *exec.Cmd, answersCodes bool) (r, w *os.File, err error) {
+	m.mu.RLock()
+	enabled := m.prompts || answersCodes
+	m.mu.RUnlock()
+	if !enabled {
+		return nil, nil, nil
+	}
+	if r, w, err = os.Pipe(); err != nil {
+		return nil, nil, err
+	}
+	cmd.Stdin = r
+	cmd.Env = append(cmd.Environ(), askpassEnv()...)
+	return r, w, nil
+}
+
+// askpassEnv makes ssh ask through pf's askpass even with a terminal at hand;
+// none when the user has an askpass of their own or pf cannot find itself.
+// ssh before 8.4 ignores SSH_ASKPASS_REQUIRE and keeps using the terminal.
+func askpassEnv() []string {
+	if os.Getenv("SSH_ASKPASS") != "" {
+		return nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil
+	}
+	return []string{"SSH_ASKPASS=" + exe, "SSH_ASKPASS_REQUIRE=force", AskpassVar + "=1"}
+}
+
+// IsAskpass reports whether this pf was started by ssh as its askpass: with
+// AskpassVar set and the prompt as its only argument.
+func IsAskpass(args []string) bool {
+	return os.Getenv(AskpassVar) == "1" && len(args) == 2
+}
+
+// Askpass is pf as ssh's askpass program. It shows prompt on stderr, which it
+// shares with ssh and so with the service's output, where pf takes it for a
+// prompt; waits for the answer on stdin, the service's, which AnswerPrompt
+// writes to; and hands it to ssh on stdout.
+func Askpass(prompt string, stdin io.Reader, stdout, stderr io.Writer) error {
+	prompt = strings.TrimRight(prompt, " ")
+	if !isPrompt([]byte(prompt)) {
+		prompt += ":" // e.g. a bare "Verification code" from a PAM module
+	}
+	fmt.Fprint(stderr, prompt+" ")
+	answer, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return fmt.Errorf("no answer to %q: %v", prompt, err)
+	}
+	_, err = fmt.Fprintln(stdout, strings.TrimRight(answer, "\r\n"))
+	return err
+}
+
+// setPrompt records the prompt the service's process waits on, when it can
+// be answered.
+func (s *runningService) setPrompt(prompt string) {
+	s.mu.Lock()
+	answerable := s.stdin != nil
+	if answerable {
+		s.prompt = prompt
+	}
+	s.mu.Unlock()
+	if answerable {
+		s.changed()
+	}
+}
+
+// clearPrompt forgets the prompt: the process printed more, so it is not
+// waiting on it any more.
+func (s *runningService) clearPrompt() {
+	s.mu.Lock()
+	had := s.prompt != ""
+	s.prompt = ""
+	s.mu.Unlock()
+	if had {
+		s.changed()
+	}
+}
+
+// AnswerPrompt writes answer and a newline to the stdin of the service's
+// process, for the prompt it waits on (see model.Service.Prompt). The answer
+// is not logged.
+func (m *ServiceManager) AnswerPrompt(name, answer string) error {
+	m.mu.RLock()
+	svc, ok := m.services[name]
+	m.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("'%s' is not running in this session", name)
+	}
+	svc.mu.Lock()
+	stdin, prompt := svc.stdin, svc.prompt
+	svc.prompt = ""
+	svc.mu.Unlock()
+	if stdin == nil || prompt == "" {
+		return fmt.Errorf("'%s' is not waiting for input", name)
+	}
+	svc.changed()
+	if _, err := io.WriteString(stdin, answer+"\n"); err != nil {
+		return fmt.Errorf("'%s': %v", name, err)
+	}
+	svc.appendLog("Answered: "+prompt, false)
+	return nil
+}
@@ -0,0 +1,107 @@
+package manager
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/fakeforward"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestScanLinesOrPrompt(t *testing.T) {
+	out := "Warning: Permanently added 'b' to the list of known hosts.\nme@bastion's password: "
+	var prompt bool
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	scanner.Split(scanLinesOrPrompt(&prompt))
+	var lines []string
+	var prompts []bool
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		prompts = append(prompts, prompt)
+	}
+	if len(lines) != 2 || lines[1] != "me@bastion's password: " || !slices.Equal(prompts, []bool{false, true}) {
+		t.Errorf("lines = %q, prompts = %v", lines, prompts)
+	}
+
+	for _, s := range []string{
+		"[sudo] password for me: ",
+		"Enter passphrase for key '/home/me/.ssh/id_ed25519': ",
+		"Verification code:",
+		"Enter MFA token: ",
+		"Are you sure you want to continue connecting (yes/no/[fingerprint])? ",
+	} {
+		if !isPrompt([]byte(s)) {
+			t.Errorf("%q should be a prompt", s)
+		}
+	}
+	for _, s := range []string{"Forwarding from 127.0.0.1:5432 -> 5432", "spinning: ", "password"} {
+		if isPrompt([]byte(s)) {
+			t.Errorf("%q should not be a prompt", s)
+		}
+	}
+}
+
+func TestAskpass(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if err := Askpass("Verification code", strings.NewReader("123456\n"), &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if stderr.String() != "Verification code: " || stdout.String() != "123456\n" {
+		t.Errorf("stderr = %q, stdout = %q", stderr.String(), stdout.String())
+	}
+	if err := Askpass("me@b's password: ", strings.NewReader(""), &stdout, &stderr); err == nil {
+		t.Error("no answer should fail, so ssh gives up")
+	}
+}
+
+func TestAnswerPrompt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	command := `printf 'Password for db: '; read answer; [ "$answer" = secret ] && echo accepted; sleep 30 # 5432:5432`
+	m := NewServiceManager(fakeforward.NewStorage(t, map[string]string{"db": command}))
+	m.EnablePrompts()
+	if err := m.StartService(context.Background(), "db"); err != nil {
+		t.Fatal(err)
+	}
+	defer m.StopAllServices()
+
+	waitFor := func(what string, ok func(svc *runningService) bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			m.mu.RLock()
+			svc := m.services["db"]
+			m.mu.RUnlock()
+			svc.mu.RLock()
+			done := ok(svc)
+			svc.mu.RUnlock()
+			if done {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("timed out waiting for %s", what)
+	}
+	waitFor("the prompt", func(svc *runningService) bool { return svc.prompt == "Password for db:" })
+	if err := m.AnswerPrompt("db", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the answer to arrive", func(svc *runningService) bool {
+		return slices.ContainsFunc(svc.logs.Snapshot(), func(e model.LogEntry) bool { return e.Message == "accepted" })
+	})
+	if err := m.AnswerPrompt("db", "again"); err == nil {
+		t.Error("a service that asks nothing should not take an answer")
+	}
+	for _, e := range m.ListServiceStates()[0].Logs {
+		if strings.Contains(e.Message, "secret") {
+			t.Errorf("the answer was logged: %q", e.Message)
+		}
+	}
+}
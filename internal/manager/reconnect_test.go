@@ -0,0 +1,178 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/fakeforward"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// testBackoff keeps reconnects fast and their waits exact.
+var testBackoff = Backoff{Base: 100 * time.Millisecond, Max: 400 * time.Millisecond, Reset: 500 * time.Millisecond}
+
+// startFake runs a fake forwarder with runs as service "db" under a manager
+// using testBackoff.
+func startFake(t *testing.T, runs ...fakeforward.Run) (*ServiceManager, *fakeforward.Forwarder) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake forwarder is killed through a Unix shell")
+	}
+	fwd := fakeforward.New(t, fakeforward.FreePort(t), 5432, runs...)
+	m := NewServiceManager(fakeforward.NewStorage(t, map[string]string{"db": fwd.Command()}))
+	m.SetBackoff(testBackoff)
+	if err := m.StartService(context.Background(), "db"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.StopAllServices)
+	return m, fwd
+}
+
+// waitFor polls db's state until ok accepts it, and fails the test after a
+// few seconds.
+func waitFor(t *testing.T, m *ServiceManager, what string, ok func(model.Service) bool) model.Service {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		states := m.ListServiceStates()
+		if len(states) == 1 && ok(states[0]) {
+			return states[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("db never %s: %+v", what, states)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func markers(svc model.Service, kind model.LogKind) []string {
+	var got []string
+	for _, entry := range svc.Logs {
+		if entry.Kind == kind {
+			got = append(got, entry.Message)
+		}
+	}
+	return got
+}
+
+func TestReconnectBacksOffUntilHealthy(t *testing.T) {
+	m, fwd := startFake(t,
+		fakeforward.Run{Stderr: []string{"error: unable to upgrade connection"}, For: 50 * time.Millisecond, Exit: 1},
+		fakeforward.Run{Stderr: []string{"error: unable to upgrade connection"}, For: 50 * time.Millisecond, Exit: 1},
+		fakeforward.Run{Healthy: true},
+	)
+	svc := waitFor(t, m, "became healthy after two failures", func(s model.Service) bool {
+		return s.Status == model.StatusHealthy && len(fwd.Starts()) == 3
+	})
+
+	// Each failure is an error, then a scheduled reconnect, then a new try.
+	var transitions []string
+	for _, tr := range svc.Transitions {
+		transitions = append(transitions, tr.From+"→"+tr.To)
+	}
+	want := "connecting→error error→connecting connecting→error error→connecting connecting→healthy"
+	if strings.Join(transitions, " ") != want {
+		t.Errorf("transitions = %s\nwant %s", strings.Join(transitions, " "), want)
+	}
+	if got := markers(svc, model.LogKindReconnect); strings.Join(got, "|") !=
+		"━━━━ RECONNECTING (attempt #1) in 0.1s ━━━━|━━━━ RECONNECTING (attempt #2) in 0.2s ━━━━" {
+		t.Errorf("reconnect markers = %q", got)
+	}
+	if svc.Reconnects["exit 1"] != 2 || svc.RestartCount != 2 {
+		t.Errorf("reconnects = %v, restart count %d", svc.Reconnects, svc.RestartCount)
+	}
+
+	// The waits between starts grow with the attempt: run time plus backoff.
+	starts := fwd.Starts()
+	for i, backoff := range []time.Duration{testBackoff.Base, 2 * testBackoff.Base} {
+		if gap := starts[i+1].Sub(starts[i]); gap < backoff+50*time.Millisecond {
+			t.Errorf("start %d came %v after the one before, want at least %v", i+2, gap, backoff+50*time.Millisecond)
+		}
+	}
+}
+
+func TestReconnectReportsTheErrorItDiedOf(t *testing.T) {
+	m, _ := startFake(t,
+		fakeforward.Run{Stderr: []string{`Error from server (NotFound): services "db" not found`}, For: 300 * time.Millisecond, Exit: 1},
+	)
+	svc := waitFor(t, m, "reported the error", func(s model.Service) bool {
+		return s.Status == model.StatusError
+	})
+	if !strings.Contains(svc.LastError, `services "db" not found`) {
+		t.Errorf("last error = %q", svc.LastError)
+	}
+	svc = waitFor(t, m, "recorded the exit", func(s model.Service) bool { return s.LastExit != nil })
+	if svc.LastExit.Reason() != "exit 1" || !strings.HasPrefix(svc.LastExit.Hint, "The target does not exist") {
+		t.Errorf("exit = %+v", svc.LastExit)
+	}
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	m, fwd := startFake(t, fakeforward.Run{For: 10 * time.Millisecond, Exit: 1})
+	svc := waitFor(t, m, "reached the fifth attempt", func(s model.Service) bool {
+		return len(markers(s, model.LogKindReconnect)) >= 5
+	})
+	var delays []string
+	re := regexp.MustCompile(`in ([0-9.]+s)`)
+	for _, marker := range markers(svc, model.LogKindReconnect)[:5] {
+		delays = append(delays, re.FindStringSubmatch(marker)[1])
+	}
+	if got := strings.Join(delays, " "); got != "0.1s 0.2s 0.4s 0.4s 0.4s" {
+		t.Errorf("delays = %s, want them doubling up to the 0.4s cap", got)
+	}
+	if len(fwd.Starts()) < 5 {
+		t.Errorf("started %d times", len(fwd.Starts()))
+	}
+}
+
+func TestStableRunResetsTheAttemptCount(t *testing.T) {
+	m, _ := startFake(t,
+		fakeforward.Run{For: 10 * time.Millisecond, Exit: 1},
+		fakeforward.Run{For: 10 * time.Millisecond, Exit: 1},
+		// Healthy for longer than testBackoff.Reset, then dropped.
+		fakeforward.Run{Healthy: true, For: 700 * time.Millisecond, Exit: 1},
+		fakeforward.Run{Healthy: true},
+	)
+	svc := waitFor(t, m, "reconnected after the stable run", func(s model.Service) bool {
+		return len(markers(s, model.LogKindReconnect)) == 3 && s.Status == model.StatusHealthy
+	})
+	got := markers(svc, model.LogKindReconnect)
+	for i, attempt := range []int{1, 2, 1} {
+		if !strings.Contains(got[i], fmt.Sprintf("(attempt #%d)", attempt)) {
+			t.Errorf("marker %d = %q, want attempt #%d", i+1, got[i], attempt)
+		}
+	}
+}
+
+func TestStopDuringBackoffStartsNothingMore(t *testing.T) {
+	m, fwd := startFake(t, fakeforward.Run{For: 10 * time.Millisecond, Exit: 1})
+	waitFor(t, m, "scheduled a reconnect", func(s model.Service) bool {
+		return len(markers(s, model.LogKindReconnect)) == 1
+	})
+	m.StopService("db")
+	starts := len(fwd.Starts())
+	time.Sleep(3 * testBackoff.Base)
+	if len(m.ListServiceStates()) != 0 || len(fwd.Starts()) != starts {
+		t.Errorf("the forwarder was started again after stopping: %d starts, then %d", starts, len(fwd.Starts()))
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Base: time.Second, Max: 5 * time.Second}
+	for attempt, want := range map[int]time.Duration{0: time.Second, 1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 100: 5 * time.Second} {
+		if got := b.Delay(attempt); got != want {
+			t.Errorf("Delay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+	b.Jitter = 0.1
+	for range 100 {
+		if got := b.Delay(2); got < 1800*time.Millisecond || got > 2200*time.Millisecond {
+			t.Fatalf("Delay(2) with 10%% jitter = %v", got)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/endpoint"
+	"github.com/alinemone/go-port-forward/internal/events"
+	"github.com/alinemone/go-port-forward/internal/keyring"
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/relay"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// relayRewrite turns a relay's rewrite config into rules, reading its auth
+// secret from the keyring once, when the service starts.
+func relayRewrite(ctx context.Context, cfg *storage.RewriteConfig) (relay.Rewrite, error) {
+	if cfg == nil {
+		return relay.Rewrite{}, nil
+	}
+	rw := relay.Rewrite{Host: cfg.Host, Headers: map[string]string{}, StripCookies: cfg.StripCookies}
+	for name, value := range cfg.Headers {
+		rw.Headers[name] = value
+	}
+	if a := cfg.Auth; a != nil {
+		secret, err := keyringLookup(ctx, a.Service, a.Account)
+		if err != nil {
+			return relay.Rewrite{}, err
+		}
+		header := a.Header
+		if header == "" {
+			header = "Authorization"
+		}
+		rw.Headers[header] = a.Prefix + secret
+	}
+	return rw, nil
+}
+
+// keyringLookup is a var so tests can fake the OS keyring.
+var keyringLookup = keyring.Lookup
+
+// startRelay runs the service's relay until ctx ends. It outlives restarts of
+// the forward, so clients keep one stable address; connections made while the
+// forward is down wait out the relay's hold, then fail. An HTTP relay logs
+// every request.
+func startRelay(ctx context.Context, svc *runningService, cfg storage.RelayConfig, rw relay.Rewrite) {
+	svc.mu.RLock()
+	upstream := net.JoinHostPort(endpoint.DialHost(svc.forward.Address), svc.localPort)
+	svc.mu.RUnlock()
+
+	hold, _ := cfg.HoldDuration() // checked by StartService
+	opts := relay.Options{
+		Listen:          cfg.Listen,
+		Upstream:        upstream,
+		HTTP:            cfg.HTTP,
+		DNS:             cfg.DNS,
+		Rewrite:         rw,
+		Chaos:           svc.chaosSettings,
+		Hold:            hold,
+		HoldConnections: cfg.HoldConnections,
+	}
+	opts.OnHeld = func(waited time.Duration, err error) {
+		if err != nil {
+			svc.appendLog(fmt.Sprintf("relay: let a client go after holding it %s: the forward did not come back", waited.Round(time.Millisecond)), true)
+			return
+		}
+		svc.appendLog(fmt.Sprintf("relay: held a client %s until the forward was back", waited.Round(time.Millisecond)), false)
+	}
+	opts.OnCarried = func(err error) {
+		if err != nil {
+			svc.appendLog(fmt.Sprintf("relay: dropped a client connection: %v", err), true)
+			return
+		}
+		svc.appendLog("relay: carried a client connection over to the reconnected forward", false)
+	}
+	if cfg.HTTP {
+		opts.OnRequest = func(r relay.Request) {
+			svc.appendEntry(model.LogEntry{Message: requestLine(r), IsError: r.Status == 0 || r.Status >= 500, Kind: model.LogKindHTTP})
+		}
+	}
+	go func() {
+		if err := relay.Run(ctx, opts); err != nil {
+			svc.appendLog(fmt.Sprintf("relay on %s: %v", relay.ListenAddress(cfg.Listen), err), true)
+		}
+	}()
+}
+
+// requestLine renders one inspected request, e.g. "GET /api/users → 200 (35ms)".
+func requestLine(r relay.Request) string {
+	latency := r.Duration.Round(time.Millisecond)
+	if r.Status == 0 {
+		return fmt.Sprintf("%s %s → failed: %v (%s)", r.Method, r.Path, r.Err, latency)
+	}
+	return fmt.Sprintf("%s %s → %d (%s)", r.Method, r.Path, r.Status, latency)
+}
+
+func (s *runningService) chaosSettings() relay.Chaos {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.chaos
+}
+
+// applyChaos brings the running relays' chaos settings in step with the
+// config. Settings that don't parse are left as they were.
+func (m *ServiceManager) applyChaos() {
+	configs, err := m.storage.Chaos()
+	if err != nil {
+		return
+	}
+	for _, svc := range m.runningList() {
+		cfg := configs[svc.name]
+		latency, jitter, err := cfg.Durations()
+		if err != nil {
+			continue
+		}
+		chaos := relay.Chaos{Latency: latency, Jitter: jitter, Bandwidth: cfg.Bandwidth, Drop: cfg.Drop / 100}
+
+		svc.mu.Lock()
+		changed := svc.relayAddr != "" && svc.chaos != chaos
+		if changed {
+			svc.chaos = chaos
+			svc.pushLogLocked(model.LogEntry{Message: chaosMarker(chaos), Kind: model.LogKindReconnect})
+			svc.event(events.Event{Kind: events.ConfigChanged, Detail: chaosMarker(chaos)})
+		}
+		svc.mu.Unlock()
+		if changed {
+			svc.changed()
+		}
+	}
+}
+
+// chaosMarker is the log line for new chaos settings, e.g.
+// "━━━━ CHAOS: 200ms latency, 1% drop ━━━━".
+func chaosMarker(c relay.Chaos) string {
+	if !c.Active() {
+		return "━━━━ CHAOS OFF ━━━━"
+	}
+	var parts []string
+	if c.Latency > 0 {
+		parts = append(parts, c.Latency.String()+" latency")
+	}
+	if c.Jitter > 0 {
+		parts = append(parts, "±"+c.Jitter.String()+" jitter")
+	}
+	if c.Bandwidth > 0 {
+		parts = append(parts, relay.FormatBandwidth(c.Bandwidth))
+	}
+	if c.Drop > 0 {
+		parts = append(parts, strconv.FormatFloat(c.Drop*100, 'g', -1, 64)+"% drop")
+	}
+	return "━━━━ CHAOS: " + strings.Join(parts, ", ") + " ━━━━"
+}
@@ -0,0 +1,76 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/relay"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+func TestRelayRewriteReadsAuthFromKeyring(t *testing.T) {
+	orig := keyringLookup
+	defer func() { keyringLookup = orig }()
+	keyringLookup = func(_ context.Context, service, account string) (string, error) {
+		if service == "pf" && account == "api" {
+			return "t0ken", nil
+		}
+		return "", errors.New("not found")
+	}
+
+	rw, err := relayRewrite(context.Background(), &storage.RewriteConfig{
+		Host:    "api.internal",
+		Headers: map[string]string{"X-Env": "dev"},
+		Auth:    &storage.KeyringHeader{Prefix: "Bearer ", Service: "pf", Account: "api"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rw.Host != "api.internal" || rw.Headers["X-Env"] != "dev" || rw.Headers["Authorization"] != "Bearer t0ken" {
+		t.Errorf("rewrite = %+v", rw)
+	}
+
+	_, err = relayRewrite(context.Background(), &storage.RewriteConfig{Auth: &storage.KeyringHeader{Service: "pf", Account: "other"}})
+	if err == nil {
+		t.Error("a missing keyring secret should fail")
+	}
+}
+
+func TestApplyChaosFollowsConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	os.MkdirAll(filepath.Join(home, ".pf"), 0o755)
+	config := `{"services": {"db": "kubectl port-forward svc/db 5432:5432"}, "relay": {"db": {"listen": "15432"}}}`
+	if err := os.WriteFile(filepath.Join(home, ".pf", "services.json"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	st := storage.NewStorage()
+	svc := &runningService{name: "db", relayAddr: "127.0.0.1:15432"}
+	m := &ServiceManager{services: map[string]*runningService{"db": svc}, storage: st, updates: make(chan struct{}, 1)}
+
+	if err := st.SetChaos("db", storage.ChaosConfig{Latency: "200ms", Drop: 1}); err != nil {
+		t.Fatal(err)
+	}
+	m.applyChaos()
+	if got, want := svc.chaosSettings(), (relay.Chaos{Latency: 200 * time.Millisecond, Drop: 0.01}); got != want {
+		t.Errorf("chaos = %+v, want %+v", got, want)
+	}
+	if logs := svc.snapshot().Logs; len(logs) != 1 || !strings.Contains(logs[0].Message, "CHAOS: 200ms latency, 1% drop") {
+		t.Errorf("chaos should be logged once: %+v", logs)
+	}
+
+	m.applyChaos()
+	if err := st.SetChaos("db", storage.ChaosConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	m.applyChaos()
+	if logs := svc.snapshot().Logs; len(logs) != 2 || svc.chaosSettings().Active() {
+		t.Errorf("chaos should be off and logged: %+v", logs)
+	}
+}
@@ -0,0 +1,35 @@
+package manager
+
+import "github.com/alinemone/go-port-forward/internal/events"
+
+// ReloadSettings re-reads the settings a running session applies without
+// restarting its forwards: the flap thresholds, for new and running services
+// alike, the error hint rules and the shutdown settings. On an invalid config or rules file it keeps
+// the current settings and returns the error.
+func (m *ServiceManager) ReloadSettings() error {
+	errors, window, err := flapSettings(m.storage)
+	if err != nil {
+		return err
+	}
+	hintSet, err := loadHints(m.storage)
+	if err != nil {
+		return err
+	}
+	shutdown, err := m.storage.Shutdown()
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.flapErrors, m.flapWindow = errors, window
+	m.hints = hintSet
+	m.shutdown = shutdown
+	m.mu.Unlock()
+	for _, svc := range m.runningList() {
+		svc.mu.Lock()
+		svc.flaps.reset(errors, window)
+		svc.mu.Unlock()
+		svc.changed()
+	}
+	m.publish(events.Event{Kind: events.ConfigChanged, Detail: "settings reloaded"})
+	return nil
+}
@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// remoteResolveInterval is how often an ssh forward's remote host is looked
+// up again. Vars so tests can shrink them and fake DNS.
+var (
+	remoteResolveInterval = 30 * time.Second
+	lookupHost            = net.DefaultResolver.LookupHost
+)
+
+// watchRemote re-resolves an ssh forward's remote host until ctx ends. When
+// the addresses change (e.g. a database failover moved its DNS name to a new
+// primary) it logs the change and drops the tunnel, so the service loop
+// reconnects to the new address instead of keeping the old one alive. Names
+// that don't resolve here (only on the ssh server) are left alone. A service
+// with a dns server (see storage.DNS) is looked up there; a pinned one is not
+// watched.
+func watchRemote(ctx context.Context, svc *runningService) {
+	ticker := time.NewTicker(remoteResolveInterval)
+	defer ticker.Stop()
+	var host string
+	var last []string
+	for {
+		// The forward changes when the service fails over to an alternate.
+		svc.mu.RLock()
+		fw, dns := svc.forward, svc.dns
+		svc.mu.RUnlock()
+		if fw.Target != host {
+			host, last = fw.Target, nil
+		}
+
+		if fw.SSH && host != "" && host != "localhost" && net.ParseIP(host) == nil && dns.Resolver != storage.DNSPin {
+			addrs := resolveRemote(ctx, host, dns)
+			if addrs != nil && last != nil && !slices.Equal(addrs, last) {
+				svc.appendMarker(model.LogKindReconnect, fmt.Sprintf("━━━━ %s moved: %s → %s, reconnecting ━━━━",
+					host, strings.Join(last, ", "), strings.Join(addrs, ", ")))
+				svc.redialNow() // between runs the next attempt resolves afresh anyway
+			}
+			if addrs != nil {
+				last = addrs
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// redialNow drops the service's tunnel on purpose, so the loop reconnects at
+// once; between runs it cuts the backoff short instead.
+func (s *runningService) redialNow() {
+	s.mu.RLock()
+	proc := s.process
+	s.mu.RUnlock()
+	if proc == nil {
+		select {
+		case s.retrySoon <- struct{}{}:
+		default:
+		}
+		return
+	}
+	s.redial.Store(true)
+	killProcessTree(proc)
+}
+
+// resolveRemote returns host's addresses sorted, or nil if the lookup fails.
+// It asks dns's server when it has one, else the system resolver.
+func resolveRemote(ctx context.Context, host string, dns storage.DNS) []string {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	lookup := lookupHost
+	if dns.Resolver == storage.DNSServer {
+		lookup = func(ctx context.Context, host string) ([]string, error) {
+			return lookupAt(ctx, dns.ServerAddress(), host)
+		}
+	}
+	addrs, err := lookup(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return nil
+	}
+	slices.Sort(addrs)
+	return addrs
+}
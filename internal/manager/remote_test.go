@@ -0,0 +1,65 @@
+package manager
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+)
+
+func TestWatchRemoteRedialsOnDNSChange(t *testing.T) {
+	var mu sync.Mutex
+	answers := [][]string{{"10.0.0.5"}, nil, {"10.0.0.5"}, {"10.0.0.9"}}
+	lookups := 0
+	defer func(interval time.Duration, lookup func(context.Context, string) ([]string, error)) {
+		remoteResolveInterval, lookupHost = interval, lookup
+	}(remoteResolveInterval, lookupHost)
+	remoteResolveInterval = 10 * time.Millisecond
+	lookupHost = func(_ context.Context, host string) ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if host != "db.internal" {
+			t.Errorf("resolved %q", host)
+		}
+		i := min(lookups, len(answers)-1)
+		lookups++
+		if answers[i] == nil {
+			return nil, context.DeadlineExceeded // a failed lookup is not a change
+		}
+		return answers[i], nil
+	}
+
+	sleepCmd := "sleep 60"
+	if runtime.GOOS == "windows" {
+		sleepCmd = "ping -n 60 127.0.0.1 >NUL"
+	}
+	cmd := newShellCommand(sleepCmd)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	exited := make(chan struct{})
+	go func() { cmd.Wait(); close(exited) }()
+
+	svc := &runningService{name: "db", forward: cmdline.ParseForward("ssh -N -L 5432:db.internal:5432 bastion"), process: cmd.Process}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watchRemote(ctx, svc)
+
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		cmd.Process.Kill()
+		t.Fatal("tunnel was not dropped after the address changed")
+	}
+	if !svc.redial.Load() {
+		t.Error("redial should be set so the exit is not reported as an error")
+	}
+	logs := svc.snapshot().Logs
+	if len(logs) != 1 || !strings.Contains(logs[0].Message, "db.internal moved: 10.0.0.5 → 10.0.0.9") {
+		t.Errorf("want one address-change marker, got %+v", logs)
+	}
+}
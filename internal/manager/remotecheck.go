@@ -0,0 +1,141 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// remoteCheckTimeout bounds one remote check, and runRemoteProbe runs its
+// command, returning what it printed. Vars so tests can shrink the one and
+// fake the other.
+var (
+	remoteCheckTimeout = 10 * time.Second
+	runRemoteProbe     = shellOutput
+)
+
+// remoteOutcome is what a remote check found.
+type remoteOutcome int
+
+const (
+	remoteUp      remoteOutcome = iota
+	remoteDown                  // the far end is not there: a false healthy
+	remoteUnknown               // the check itself failed, e.g. ssh could not log in
+)
+
+// watchRemoteEnd checks the far end of svc's forward with p every interval,
+// on the session's shared pool (see checkScheduler), until ctx ends (the run
+// does). A healthy service whose far end is down goes to error, and back to
+// healthy once it is up again; a check that cannot tell either way is logged
+// and changes nothing.
+func watchRemoteEnd(ctx context.Context, svc *runningService, p cmdline.RemoteProbe, interval time.Duration) {
+	failing := false // the service is in error because of this check
+	lastLogged := ""
+	logOnce := func(message string, isError bool) {
+		if message != lastLogged {
+			lastLogged = message
+			svc.appendLog(message, isError)
+		}
+	}
+	stop := healthChecks.every(ctx, svc.name+" remote", min(5*time.Second, interval), interval, func() {
+		svc.mu.RLock()
+		status := svc.status
+		svc.mu.RUnlock()
+		if status != model.StatusHealthy && !failing {
+			return // not up locally yet, or down for its own reasons
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, remoteCheckTimeout)
+		out, err := runRemoteProbe(checkCtx, p.Command)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		outcome, reason := readRemoteProbe(p, out, err)
+		switch outcome {
+		case remoteUp:
+			if failing {
+				failing = false
+				logOnce("Remote check: "+p.Target+" is back", false)
+				svc.markHealthy()
+			}
+			lastLogged = ""
+		case remoteDown:
+			failing = true
+			logOnce("Remote check: "+reason, true)
+			svc.setError("remote: " + reason)
+		case remoteUnknown:
+			logOnce("Remote check could not tell: "+reason, false)
+		}
+	})
+	<-ctx.Done()
+	stop()
+}
+
+// readRemoteProbe reads what p's command printed, or how it failed.
+func readRemoteProbe(p cmdline.RemoteProbe, out string, err error) (remoteOutcome, string) {
+	if p.Kind == cmdline.RemoteSSH {
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			return remoteUp, ""
+		case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+			return remoteDown, "nothing answers on " + p.Target + " behind the ssh server"
+		case errors.As(err, &exitErr) && exitErr.ExitCode() == 255:
+			return remoteUnknown, "ssh: " + err.Error()
+		}
+		return remoteUnknown, "nc on the ssh server: " + err.Error()
+	}
+
+	if err != nil {
+		if strings.Contains(err.Error(), "NotFound") || strings.Contains(err.Error(), "not found") {
+			return remoteDown, p.Target + " is gone"
+		}
+		return remoteUnknown, "kubectl: " + err.Error()
+	}
+	fields := strings.Fields(out)
+	switch p.Kind {
+	case cmdline.RemotePod:
+		if len(fields) == 0 || strings.Contains(out, "false") {
+			return remoteDown, p.Target + " is not Ready"
+		}
+	case cmdline.RemoteEndpoints:
+		if len(fields) == 0 {
+			return remoteDown, p.Target + " has no ready endpoints"
+		}
+	case cmdline.RemoteReplicas:
+		if n, _ := strconv.Atoi(strings.TrimSpace(out)); n == 0 {
+			return remoteDown, p.Target + " has no ready replicas"
+		}
+	}
+	return remoteUp, ""
+}
+
+// shellOutput runs command with the shell and returns its stdout. A failure
+// is described by the last line the command wrote on stderr, and still
+// unwraps to the *exec.ExitError.
+func shellOutput(ctx context.Context, command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		lines := strings.Split(strings.TrimSpace(string(exitErr.Stderr)), "\n")
+		if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+			return string(out), fmt.Errorf("%s: %w", last, err)
+		}
+	}
+	return string(out), err
+}
@@ -0,0 +1,90 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestReadRemoteProbe(t *testing.T) {
+	pod := cmdline.RemoteProbe{Kind: cmdline.RemotePod, Target: "pod/api-0"}
+	svc := cmdline.RemoteProbe{Kind: cmdline.RemoteEndpoints, Target: "svc/api"}
+	deploy := cmdline.RemoteProbe{Kind: cmdline.RemoteReplicas, Target: "deploy/api"}
+	for _, tc := range []struct {
+		p    cmdline.RemoteProbe
+		out  string
+		err  error
+		want remoteOutcome
+	}{
+		{pod, "true true", nil, remoteUp},
+		{pod, "true false", nil, remoteDown},
+		{pod, "", nil, remoteDown}, // pending: no containers yet
+		{pod, "", errors.New(`Error from server (NotFound): pods "api-0" not found: exit status 1`), remoteDown},
+		{pod, "", errors.New("Unable to connect to the server: dial tcp: i/o timeout"), remoteUnknown},
+		{svc, "10.0.0.7 10.0.0.9", nil, remoteUp},
+		{svc, "", nil, remoteDown},
+		{deploy, "2", nil, remoteUp},
+		{deploy, "", nil, remoteDown}, // readyReplicas is left out at 0
+	} {
+		if got, reason := readRemoteProbe(tc.p, tc.out, tc.err); got != tc.want {
+			t.Errorf("%s %q %v: outcome %d (%s), want %d", tc.p.Kind, tc.out, tc.err, got, reason, tc.want)
+		}
+	}
+}
+
+func TestReadRemoteProbeSSH(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	p := cmdline.RemoteProbe{Kind: cmdline.RemoteSSH, Target: "db:5432"}
+	exit := func(code string) error { return exec.Command("sh", "-c", "exit "+code).Run() }
+	for err, want := range map[error]remoteOutcome{
+		nil:         remoteUp,
+		exit("1"):   remoteDown,    // nc: nothing listens
+		exit("255"): remoteUnknown, // ssh could not connect or log in
+		exit("127"): remoteUnknown, // no nc on the server
+	} {
+		if got, reason := readRemoteProbe(p, "", err); got != want {
+			t.Errorf("%v: outcome %d (%s), want %d", err, got, reason, want)
+		}
+	}
+}
+
+func TestWatchRemoteEndFlagsADeadBackend(t *testing.T) {
+	var ready atomic.Bool
+	ready.Store(true)
+	origRun := runRemoteProbe
+	runRemoteProbe = func(ctx context.Context, command string) (string, error) {
+		if ready.Load() {
+			return "true", nil
+		}
+		return "false", nil
+	}
+	defer func() { runRemoteProbe = origRun }()
+
+	svc := &runningService{name: "api", status: model.StatusHealthy, logs: newLogRing(maxLogEntries)}
+	p := cmdline.RemoteProbe{Kind: cmdline.RemotePod, Target: "pod/api-0"}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { watchRemoteEnd(ctx, svc, p, 10*time.Millisecond); close(done) }()
+	defer func() { cancel(); <-done }()
+
+	time.Sleep(30 * time.Millisecond)
+	if s := svc.snapshot().Status; s != model.StatusHealthy {
+		t.Fatalf("status = %s with the pod ready", s)
+	}
+	ready.Store(false)
+	waitForStatus(t, svc, model.StatusError)
+	if e := svc.snapshot().LastError; e != "remote: pod/api-0 is not Ready" {
+		t.Errorf("last error = %q", e)
+	}
+	ready.Store(true)
+	waitForStatus(t, svc, model.StatusHealthy)
+}
@@ -0,0 +1,91 @@
+package manager
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// rolloutPollInterval is how often a service waiting for a rollout checks
+// whether it has finished; rolloutMaxWait caps the wait, so a stuck rollout
+// does not hold the forward down for good. Vars so tests can shrink them and
+// fake kubectl.
+var (
+	rolloutPollInterval = 5 * time.Second
+	rolloutMaxWait      = 15 * time.Minute
+	rolloutInProgress   = kubectlRolloutInProgress
+)
+
+// waitOutRollout holds off a reconnect while the workload behind svc is being
+// rolled out, since its pods keep going away until the rollout is done. The
+// service is marked as rolling out meanwhile, which keeps its errors from
+// counting as flapping or being notified. It reports whether it waited; it
+// returns early when ctx ends.
+func (m *ServiceManager) waitOutRollout(ctx context.Context, svc *runningService) bool {
+	svc.mu.RLock()
+	command := svc.command
+	svc.mu.RUnlock()
+	check, ok := cmdline.RolloutStatusCommand(command)
+	if !ok {
+		return false
+	}
+	if m.certManager != nil {
+		if certConfig, exists := m.certManager.GetCertificate(); exists {
+			check = addKubectlCertFlags(check, certConfig.CertPath, certConfig.KeyPath)
+		}
+	}
+	if !rolloutInProgress(ctx, check) {
+		return false
+	}
+
+	svc.setRollingOut(true)
+	svc.appendMarker(model.LogKindReconnect, "━━━━ ROLLOUT in progress: reconnecting once it is done ━━━━")
+	defer svc.setRollingOut(false)
+
+	deadline := time.Now().Add(rolloutMaxWait)
+	ticker := time.NewTicker(rolloutPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return true
+		case now := <-ticker.C:
+			if !rolloutInProgress(ctx, check) {
+				svc.appendMarker(model.LogKindReconnect, "━━━━ ROLLOUT finished ━━━━")
+				return true
+			}
+			if now.After(deadline) {
+				svc.appendMarker(model.LogKindReconnect, "━━━━ ROLLOUT still going, reconnecting anyway ━━━━")
+				return true
+			}
+		}
+	}
+}
+
+func (s *runningService) setRollingOut(on bool) {
+	s.mu.Lock()
+	s.rollingOut = on
+	s.mu.Unlock()
+	s.changed()
+}
+
+// kubectlRolloutInProgress runs check, a `kubectl rollout status
+// --watch=false`, and reports whether it says the rollout is still going. A
+// workload kubectl cannot find or read counts as not rolling out.
+func kubectlRolloutInProgress(ctx context.Context, check string) bool {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", check)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", check)
+	}
+	out, err := cmd.Output()
+	return err == nil && strings.Contains(string(out), "Waiting for")
+}
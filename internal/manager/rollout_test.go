@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitOutRollout(t *testing.T) {
+	var checks atomic.Int32
+	var lastCheck atomic.Value
+	rolloutInProgress = func(_ context.Context, check string) bool {
+		lastCheck.Store(check)
+		return checks.Add(1) < 3 // rolling out for the first two checks
+	}
+	rolloutPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() {
+		rolloutInProgress, rolloutPollInterval = kubectlRolloutInProgress, 5*time.Second
+	})
+
+	m := &ServiceManager{}
+	ssh := &runningService{name: "db", command: "ssh -N -L 5432:db:5432 bastion"}
+	if m.waitOutRollout(context.Background(), ssh) || checks.Load() != 0 {
+		t.Fatal("an ssh forward has no rollout to wait for")
+	}
+
+	svc := &runningService{name: "api", command: "kubectl port-forward -n web svc/api 8080:80"}
+	seen := make(chan bool, 1)
+	svc.onChange = func() {
+		select {
+		case seen <- svc.snapshot().RollingOut:
+		default:
+		}
+	}
+	if !m.waitOutRollout(context.Background(), svc) {
+		t.Fatal("should wait while the rollout runs")
+	}
+	if !<-seen {
+		t.Error("the service should show as rolling out while it waits")
+	}
+	if svc.snapshot().RollingOut {
+		t.Error("the rollout mark should clear once it is done")
+	}
+	if got := lastCheck.Load().(string); got != "kubectl -n web rollout status deploy/api --watch=false" {
+		t.Errorf("checked with %q", got)
+	}
+	var logs []string
+	for _, e := range svc.snapshot().Logs {
+		logs = append(logs, e.Message)
+	}
+	if !strings.Contains(strings.Join(logs, "\n"), "ROLLOUT finished") {
+		t.Errorf("logs = %v", logs)
+	}
+
+	// Not rolling out: no wait.
+	checks.Store(10)
+	if m.waitOutRollout(context.Background(), svc) {
+		t.Error("should not wait without a rollout")
+	}
+}
@@ -0,0 +1,43 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// scheduleCheck caps how long the scheduler sleeps at a time, so a restart
+// due while the machine slept happens soon after it wakes.
+const scheduleCheck = time.Minute
+
+// runSchedule restarts svc at each time next returns (see
+// storage.RestartSchedule.Next) until scheduleCtx ends, when the service
+// stops. Restarts run under ctx, the session's context, like those from a
+// frontend.
+func (m *ServiceManager) runSchedule(ctx, scheduleCtx context.Context, svc *runningService, next func(time.Time) (time.Time, error)) {
+	for {
+		at, err := next(time.Now())
+		if err != nil { // checked when the service started
+			return
+		}
+		svc.mu.Lock()
+		svc.nextRestart = at
+		svc.mu.Unlock()
+		svc.changed()
+
+		for wait := time.Until(at); wait > 0; wait = time.Until(at) {
+			select {
+			case <-scheduleCtx.Done():
+				return
+			case <-time.After(min(wait, scheduleCheck)):
+			}
+		}
+		if scheduleCtx.Err() != nil {
+			return
+		}
+		svc.appendLog(fmt.Sprintf("Scheduled restart (%s)", at.Format("15:04 MST")), false)
+		m.restartInPlace(ctx, svc.name, model.CauseRestart)
+	}
+}
@@ -0,0 +1,44 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestScheduledRestart(t *testing.T) {
+	m, fwd := newFakeManager(t, "db")
+	ctx := context.Background()
+	if err := m.StartService(ctx, "db"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, m, "became healthy", func(s model.Service) bool { return s.Status == model.StatusHealthy })
+
+	m.mu.RLock()
+	svc := m.services["db"]
+	m.mu.RUnlock()
+	scheduleCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	first := time.Now().Add(200 * time.Millisecond)
+	go m.runSchedule(ctx, scheduleCtx, svc, func(now time.Time) (time.Time, error) {
+		if now.Before(first) {
+			return first, nil
+		}
+		return now.Add(time.Hour), nil
+	})
+
+	waitFor(t, m, "showed the next restart", func(s model.Service) bool { return s.NextRestart.Equal(first) })
+	// The restart is counted before the old process goes, so the count
+	// alone can still show the old process healthy.
+	state := waitFor(t, m, "restarted on schedule", func(s model.Service) bool {
+		return s.Reconnects[model.CauseRestart] == 1 && s.NextRestart.After(first) && s.Status == model.StatusHealthy
+	})
+	if !state.NextRestart.After(first) {
+		t.Errorf("next restart = %v, want the one after %v", state.NextRestart, first)
+	}
+	if n := len(fwd["db"].Starts()); n != 2 {
+		t.Errorf("the forwarder was started %d times, want 2", n)
+	}
+}
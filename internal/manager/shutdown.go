@@ -0,0 +1,147 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/events"
+)
+
+// shutdownKillWait is how long a killed service's process gets to go before
+// the shutdown gives up on it as stuck.
+var shutdownKillWait = 2 * time.Second
+
+// StopAllServices stops every running service for the end of the session, in
+// the config's shutdown order (see storage.ShutdownConfig): the services the
+// order leaves out stop together first, then the ordered ones one at a time.
+// Each service is cancelled and its process tree asked to terminate; one that
+// has not exited within its timeout (shutdownGraceTimeout unless the config
+// sets one) is killed, and one still running after that is published as
+// events.ServiceStuck. Services leave ListServiceStates as they finish, so a
+// frontend can show the shutdown's progress, and each is published as
+// events.ServiceStopped. It returns once every service is gone.
+func (m *ServiceManager) StopAllServices() {
+	for {
+		m.mu.RLock()
+		running := make([]string, 0, len(m.services))
+		for name := range m.services {
+			running = append(running, name)
+		}
+		order := m.shutdown.Order
+		m.mu.RUnlock()
+		if len(running) == 0 {
+			return
+		}
+		m.stopStage(nextShutdownStage(running, order))
+	}
+}
+
+// nextShutdownStage picks what stops next of the running services: all those
+// order leaves out, or else the first of order still running.
+func nextShutdownStage(running, order []string) []string {
+	var stage []string
+	for _, name := range running {
+		if !slices.Contains(order, name) {
+			stage = append(stage, name)
+		}
+	}
+	if len(stage) > 0 {
+		slices.Sort(stage)
+		return stage
+	}
+	for _, name := range order {
+		if slices.Contains(running, name) {
+			return []string{name}
+		}
+	}
+	return nil
+}
+
+// stopStage stops the named services at once and waits until each has
+// exited, been killed or been given up on.
+func (m *ServiceManager) stopStage(names []string) {
+	m.mu.RLock()
+	stage := make([]*runningService, 0, len(names))
+	for _, name := range names {
+		if svc, ok := m.services[name]; ok {
+			stage = append(stage, svc)
+		}
+	}
+	cfg := m.shutdown
+	m.mu.RUnlock()
+
+	dones := make([]chan struct{}, len(stage))
+	procs := make([]*os.Process, 0, len(stage))
+	for i, svc := range stage {
+		svc.bulkKill.Store(true)
+		dones[i] = svc.stop()
+		svc.mu.RLock()
+		if svc.process != nil {
+			procs = append(procs, svc.process)
+		}
+		svc.mu.RUnlock()
+	}
+	terminateProcessTrees(procs)
+
+	var wg sync.WaitGroup
+	for i, svc := range stage {
+		timeout, _ := cfg.TimeoutFor(svc.name) // checked when read
+		if timeout == 0 {
+			timeout = shutdownGraceTimeout
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.awaitShutdown(svc, dones[i], timeout)
+		}()
+	}
+	wg.Wait()
+}
+
+// awaitShutdown waits for a stopped service's loop to finish (done, from
+// svc.stop), killing its process tree once timeout passes, then takes the
+// service out of the session.
+func (m *ServiceManager) awaitShutdown(svc *runningService, done chan struct{}, timeout time.Duration) {
+	detail := ""
+	if !waitDone(done, timeout) {
+		svc.mu.RLock()
+		proc := svc.process
+		svc.mu.RUnlock()
+		if proc == nil {
+			// Between runs, with nothing to kill: the loop ends on its own.
+			detail = fmt.Sprintf("did not finish within %s", timeout)
+		} else {
+			killProcessTree(proc)
+			detail = fmt.Sprintf("killed after %s", timeout)
+			if !waitDone(done, shutdownKillWait) {
+				detail = fmt.Sprintf("pid %d still running after being killed", proc.Pid)
+				m.publish(events.Event{Kind: events.ServiceStuck, Service: svc.name, Detail: detail})
+			}
+		}
+	}
+
+	m.mu.Lock()
+	if m.services[svc.name] == svc {
+		delete(m.services, svc.name)
+	}
+	m.mu.Unlock()
+	m.notify()
+	m.publish(events.Event{Kind: events.ServiceStopped, Service: svc.name, Detail: detail})
+}
+
+// waitDone reports whether done closes within timeout; a nil done counts as
+// closed.
+func waitDone(done chan struct{}, timeout time.Duration) bool {
+	if done == nil {
+		return true
+	}
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,122 @@
+package manager
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/events"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+func TestNextShutdownStage(t *testing.T) {
+	for _, c := range []struct {
+		running, order, want []string
+	}{
+		{[]string{"db", "api"}, nil, []string{"api", "db"}},
+		{[]string{"db", "api", "cache"}, []string{"api", "db"}, []string{"cache"}},
+		{[]string{"db", "api"}, []string{"api", "db"}, []string{"api"}},
+		{[]string{"db"}, []string{"api", "db"}, []string{"db"}},
+		{nil, []string{"api"}, nil},
+	} {
+		if got := nextShutdownStage(c.running, c.order); !slices.Equal(got, c.want) {
+			t.Errorf("nextShutdownStage(%v, %v) = %v, want %v", c.running, c.order, got, c.want)
+		}
+	}
+}
+
+func TestStopAllServicesFollowsTheOrder(t *testing.T) {
+	m, _ := newFakeManager(t, "db", "api", "cache", "queue")
+	m.shutdown = storage.ShutdownConfig{Order: []string{"api", "db"}}
+	for _, name := range []string{"db", "api", "cache", "queue"} {
+		if err := m.StartService(context.Background(), name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sub := m.Events().Subscribe(0, events.ServiceStopped)
+	m.StopAllServices()
+	sub.Close()
+
+	var stopped []string
+	for e := range sub.C {
+		if e.Detail != "" {
+			t.Errorf("%s: %s, want a clean exit", e.Service, e.Detail)
+		}
+		stopped = append(stopped, e.Service)
+	}
+	if len(stopped) != 4 || !slices.Equal(stopped[2:], []string{"api", "db"}) {
+		t.Errorf("stopped %v, want cache and queue, then api, then db", stopped)
+	}
+}
+
+// startShutdownTarget adds a service running script to m, with a done channel
+// that closes when the script exits unless keepDone is set.
+func startShutdownTarget(t *testing.T, m *ServiceManager, name, script string, keepDone bool) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("terminating uses Unix signals")
+	}
+	cmd := newShellCommand(script)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { killProcessTree(cmd.Process) })
+	svc := &runningService{name: name, process: cmd.Process, done: make(chan struct{})}
+	go func() {
+		cmd.Wait()
+		if !keepDone {
+			close(svc.done)
+		}
+	}()
+	m.services[name] = svc
+}
+
+func TestStopAllServicesKillsWhatOutlivesItsTimeout(t *testing.T) {
+	defer func(old time.Duration) { shutdownKillWait = old }(shutdownKillWait)
+	shutdownKillWait = 200 * time.Millisecond
+	m := &ServiceManager{
+		services: map[string]*runningService{},
+		shutdown: storage.ShutdownConfig{Timeout: "5s", Timeouts: map[string]string{"stubborn": "100ms", "stuck": "100ms"}},
+		bus:      &events.Bus{},
+	}
+	startShutdownTarget(t, m, "polite", "sleep 60", false)
+	startShutdownTarget(t, m, "stubborn", "trap '' TERM; while :; do sleep 0.05; done", false)
+	startShutdownTarget(t, m, "stuck", "sleep 60", true) // its loop never reports the exit
+	sub := m.Events().Subscribe(0, events.ServiceStopped, events.ServiceStuck)
+	time.Sleep(200 * time.Millisecond) // let the shells set their traps
+
+	start := time.Now()
+	m.StopAllServices()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("StopAllServices took %v", elapsed)
+	}
+	sub.Close()
+
+	got := map[string]string{}
+	for e := range sub.C {
+		got[string(e.Kind)+" "+e.Service] = e.Detail
+	}
+	want := map[string]string{
+		"service.stopped polite":   "",
+		"service.stopped stubborn": "killed after 100ms",
+		"service.stopped stuck":    "",
+		"service.stuck stuck":      "",
+	}
+	for key := range want {
+		if _, ok := got[key]; !ok {
+			t.Errorf("no %q event in %v", key, got)
+		}
+	}
+	if got["service.stopped polite"] != "" || got["service.stopped stubborn"] != want["service.stopped stubborn"] {
+		t.Errorf("stop details = %v", got)
+	}
+	if d := got["service.stuck stuck"]; !strings.Contains(d, "still running") {
+		t.Errorf("stuck detail = %q", d)
+	}
+	if len(m.services) != 0 {
+		t.Errorf("%d services left after StopAllServices", len(m.services))
+	}
+}
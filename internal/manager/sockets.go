@@ -0,0 +1,60 @@
+package manager
+
+import (
+	"fmt"
+	"net"
+	"slices"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/proc"
+)
+
+// groupSockets lists the connections a process group holds. A var so tests
+// can fake it.
+var groupSockets = proc.GroupSockets
+
+// SocketStats lists the connections the service's process holds to the far
+// side, with their round-trip times and retransmits, for telling a slow
+// forward from a slow server. Connections to loopback, the clients of its
+// local port, are left out. A service sharing a kubectl (see mergeGroup)
+// reports that kubectl's. Off Linux it returns errors.ErrUnsupported.
+func (m *ServiceManager) SocketStats(name string) ([]model.Socket, error) {
+	m.mu.RLock()
+	svc, ok := m.services[name]
+	var group *mergeGroup
+	if ok && svc.mergeKey != "" {
+		group = m.merged[svc.mergeKey]
+	}
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("'%s' is not running in this session", name)
+	}
+	if group != nil {
+		group.mu.Lock()
+		if group.carrier != nil && slices.Contains(group.members, svc) {
+			svc = group.carrier
+		}
+		group.mu.Unlock()
+	}
+	svc.mu.RLock()
+	process := svc.process
+	svc.mu.RUnlock()
+	if process == nil {
+		return nil, fmt.Errorf("'%s' is not connected", name)
+	}
+
+	held, err := groupSockets(process.Pid)
+	if err != nil {
+		return nil, err
+	}
+	var sockets []model.Socket
+	for _, s := range held {
+		if host, _, err := net.SplitHostPort(s.Remote); err == nil {
+			if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+				continue
+			}
+		}
+		sockets = append(sockets, model.Socket{Local: s.Local, Remote: s.Remote, RTT: s.RTT, RTTVar: s.RTTVar, Retrans: s.Retrans})
+	}
+	return sockets, nil
+}
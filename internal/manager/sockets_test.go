@@ -0,0 +1,41 @@
+package manager
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/proc"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+func TestSocketStatsLeavesOutLocalClients(t *testing.T) {
+	defer func(list func(int) ([]proc.Socket, error)) { groupSockets = list }(groupSockets)
+	groupSockets = func(pgid int) ([]proc.Socket, error) {
+		if pgid != 42 {
+			t.Errorf("asked for group %d", pgid)
+		}
+		return []proc.Socket{
+			{Local: "192.168.1.10:40122", Remote: "10.1.2.3:22", PIDs: []int{43}, RTT: 15 * time.Millisecond, RTTVar: 3 * time.Millisecond, Retrans: 2},
+			{Local: "127.0.0.1:5432", Remote: "127.0.0.1:50000", PIDs: []int{43}},
+			{Local: "[::1]:5432", Remote: "[::1]:50001", PIDs: []int{43}},
+		}, nil
+	}
+	m := NewServiceManager(storage.NewStorage())
+	m.services["db"] = &runningService{name: "db", process: &os.Process{Pid: 42}}
+	m.services["idle"] = &runningService{name: "idle"}
+
+	got, err := m.SocketStats("db")
+	want := []model.Socket{{Local: "192.168.1.10:40122", Remote: "10.1.2.3:22", RTT: 15 * time.Millisecond, RTTVar: 3 * time.Millisecond, Retrans: 2}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("SocketStats = %+v, %v; want %+v", got, err, want)
+	}
+	if _, err := m.SocketStats("idle"); err == nil {
+		t.Error("a service without a process has no sockets")
+	}
+	if _, err := m.SocketStats("gone"); err == nil {
+		t.Error("an unknown service has no sockets")
+	}
+}
@@ -0,0 +1,84 @@
+package manager
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// lineQueueSize is how many lines of a process's output can wait to be
+// logged. A process printing faster than pf takes its lines in loses the
+// oldest waiting ones, rather than blocking on its pipe.
+const lineQueueSize = 256
+
+// throttleWindow is how long a service shows as throttled after a line of its
+// output was dropped.
+const throttleWindow = 5 * time.Second
+
+type outputLine struct {
+	text    string
+	isError bool
+	prompt  bool // an unfinished line that looks like a prompt
+}
+
+// lineQueue hands the lines a process prints from its output readers to the
+// goroutine that logs them.
+type lineQueue chan outputLine
+
+// push queues line without blocking: when the queue is full it drops the
+// oldest waiting line to make room, and reports that it did.
+func (q lineQueue) push(line outputLine) (dropped bool) {
+	for {
+		select {
+		case q <- line:
+			return dropped
+		default:
+		}
+		select {
+		case <-q:
+			dropped = true
+		default:
+		}
+	}
+}
+
+// readOutput queues each non-empty line read from reader until it ends,
+// counting the lines dropped on the way. A prompt is queued without waiting
+// for its newline.
+func readOutput(svc *runningService, reader io.Reader, isError bool, q lineQueue) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var prompt bool
+	scanner.Split(scanLinesOrPrompt(&prompt))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if q.push(outputLine{text: line, isError: isError, prompt: prompt}) {
+			svc.noteDropped(time.Now())
+		}
+	}
+}
+
+// noteDropped counts a dropped output line. The first drop after a quiet
+// spell is marked in the log.
+func (s *runningService) noteDropped(now time.Time) {
+	s.mu.Lock()
+	s.outputDropped++
+	episode := !now.Before(s.throttledUntil)
+	if episode {
+		s.pushLogLocked(model.LogEntry{
+			Message: "━━━━ OUTPUT THROTTLED: dropping lines faster than pf can log them ━━━━",
+			Kind:    model.LogKindStatus,
+		})
+	}
+	s.throttledUntil = now.Add(throttleWindow)
+	s.mu.Unlock()
+	if episode {
+		s.changed()
+	}
+}
@@ -0,0 +1,69 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestLineQueueDropsOldest(t *testing.T) {
+	q := make(lineQueue, 2)
+	var dropped int
+	for _, text := range []string{"a", "b", "c", "d"} {
+		if q.push(outputLine{text: text}) {
+			dropped++
+		}
+	}
+	if dropped != 2 || (<-q).text != "c" || (<-q).text != "d" {
+		t.Errorf("dropped %d, want the oldest two gone", dropped)
+	}
+}
+
+func TestFloodedOutputIsThrottled(t *testing.T) {
+	svc := &runningService{name: "db", status: model.StatusHealthy, logs: newLogRing(maxLogEntries)}
+	// Nothing takes lines off the queue, as when pf falls behind.
+	q := make(lineQueue, 4)
+	readOutput(svc, strings.NewReader(strings.Repeat("Handling connection for 5432\n", 10)), false, q)
+
+	got := svc.snapshot()
+	if got.OutputDropped != 6 || !got.Throttled(time.Now()) {
+		t.Fatalf("dropped %d, throttled until %v", got.OutputDropped, got.ThrottledUntil)
+	}
+	var marks int
+	for _, entry := range got.Logs {
+		if strings.Contains(entry.Message, "OUTPUT THROTTLED") {
+			marks++
+		}
+	}
+	if marks != 1 {
+		t.Errorf("%d throttle markers, want one per episode", marks)
+	}
+	if got.Throttled(time.Now().Add(throttleWindow)) {
+		t.Error("the throttle should wear off after the window")
+	}
+}
+
+func TestStreamOutputLogsBothPipes(t *testing.T) {
+	svc := &runningService{name: "db", status: model.StatusConnecting, logs: newLogRing(maxLogEntries)}
+	(&ServiceManager{}).streamOutput(svc,
+		strings.NewReader("Forwarding from 127.0.0.1:5432 -> 5432\n"),
+		strings.NewReader("error: lost connection to pod\n"))
+
+	got := svc.snapshot()
+	var out, errs int
+	for _, entry := range got.Logs {
+		if entry.Kind != model.LogKindOutput {
+			continue
+		}
+		if entry.IsError {
+			errs++
+		} else {
+			out++
+		}
+	}
+	if out != 1 || errs != 1 || got.OutputDropped != 0 {
+		t.Errorf("logged %d stdout and %d stderr lines, dropped %d", out, errs, got.OutputDropped)
+	}
+}
@@ -0,0 +1,81 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// waitInterval is how often unmet waitFor conditions are checked again, and
+// waitCheckTimeout bounds one check. Vars so tests can shrink them.
+var (
+	waitInterval     = 2 * time.Second
+	waitCheckTimeout = 10 * time.Second
+)
+
+// awaitConditions blocks until every one of svc's waitFor conditions holds,
+// and reports false if ctx ends first. It runs before each start and reconnect,
+// so a forward that needs the VPN waits for the VPN instead of failing and
+// backing off. The unmet condition is logged once each time it changes.
+func awaitConditions(ctx context.Context, svc *runningService) bool {
+	svc.mu.RLock()
+	conds := svc.waitFor
+	svc.mu.RUnlock()
+
+	waiting := ""
+	for {
+		unmet := ""
+		for _, c := range conds {
+			if err := checkCondition(ctx, c); err != nil {
+				unmet = fmt.Sprintf("Waiting for %s: %v", c, err)
+				break
+			}
+		}
+		if unmet == "" {
+			if waiting != "" {
+				svc.appendLog("Wait conditions met, starting", false)
+			}
+			return true
+		}
+		if unmet != waiting {
+			waiting = unmet
+			svc.appendLog(unmet, false)
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(waitInterval):
+		}
+	}
+}
+
+func checkCondition(ctx context.Context, c storage.WaitCondition) error {
+	ctx, cancel := context.WithTimeout(ctx, waitCheckTimeout)
+	defer cancel()
+	switch {
+	case c.TCP != "":
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", c.TCP)
+		if err != nil {
+			return err
+		}
+		conn.Close()
+		return nil
+	case c.File != "":
+		_, err := os.Stat(c.File)
+		return err
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", c.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", c.Command)
+	}
+	return cmd.Run()
+}
@@ -0,0 +1,54 @@
+package manager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+func TestAwaitConditionsWaitsForAFile(t *testing.T) {
+	orig := waitInterval
+	waitInterval = 10 * time.Millisecond
+	defer func() { waitInterval = orig }()
+
+	flag := filepath.Join(t.TempDir(), "vpn-up")
+	svc := &runningService{name: "db", logs: newLogRing(maxLogEntries), waitFor: []storage.WaitCondition{{File: flag}}}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(flag, nil, 0600)
+	}()
+	if !awaitConditions(context.Background(), svc) {
+		t.Fatal("should start once the file exists")
+	}
+	var lines []string
+	for _, e := range svc.logs.Snapshot() {
+		lines = append(lines, e.Message)
+	}
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "Waiting for file "+flag) || lines[1] != "Wait conditions met, starting" {
+		t.Errorf("log = %q", lines)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	svc.waitFor = []storage.WaitCondition{{File: flag + ".missing"}}
+	if awaitConditions(ctx, svc) {
+		t.Error("a stopped service should not start")
+	}
+}
+
+func TestCheckCondition(t *testing.T) {
+	if err := checkCondition(context.Background(), storage.WaitCondition{Command: "exit 0"}); err != nil {
+		t.Errorf("exit 0 should pass: %v", err)
+	}
+	if err := checkCondition(context.Background(), storage.WaitCondition{Command: "exit 3"}); err == nil {
+		t.Error("exit 3 should fail")
+	}
+	if err := checkCondition(context.Background(), storage.WaitCondition{TCP: "127.0.0.1:1"}); err == nil {
+		t.Error("a closed port should fail")
+	}
+}
@@ -0,0 +1,84 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/events"
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// configPollInterval is how often a running session re-reads the config for
+// changes made by other pf commands. A var so UseLowPower can lengthen it.
+var configPollInterval = time.Second
+
+// WatchConfig follows what other pf commands change in the config while this
+// session runs until ctx ends: maintenance windows (`pf maintenance`), chaos
+// settings (`pf chaos`) and commands repointed with `pf switch` or `pf edit`,
+// which restart the service in place on its new command. ctx should be the
+// one the services were started with.
+func (m *ServiceManager) WatchConfig(ctx context.Context) {
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+	for {
+		m.applyMaintenance(time.Now())
+		m.applySwitches(ctx)
+		m.applyChaos()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// applySwitches restarts running services whose saved command (or the
+// variant of it for this machine, with the session's parameters) has
+// changed. A new command on a different local port is left for the next
+// `pf run`, since the running forward's port must stay stable.
+func (m *ServiceManager) applySwitches(ctx context.Context) {
+	commands, err := m.storage.LocalServices()
+	if err != nil {
+		return
+	}
+	for _, svc := range m.runningList() {
+		command, ok := commands[svc.name]
+		if !ok || svc.adhoc || ensureValidCommand(command) != nil {
+			continue
+		}
+		if command, err = m.withParams(svc.name, command); err != nil {
+			continue
+		}
+		svc.mu.Lock()
+		local, _ := cmdline.ParsePorts(command)
+		if len(svc.commands) == 0 || command == svc.commands[0] || local != svc.localPort {
+			svc.mu.Unlock()
+			continue
+		}
+		svc.commands[0] = command
+		target := cmdline.ParseForward(command).Target
+		if target == "" {
+			target = command
+		}
+		message := fmt.Sprintf("━━━━ SWITCHING to %s ━━━━", target)
+		svc.pushLogLocked(model.LogEntry{Message: message, Kind: model.LogKindReconnect})
+		svc.event(events.Event{Kind: events.ConfigChanged, Detail: message})
+		svc.mu.Unlock()
+
+		svc.changed()
+		go m.restartInPlace(ctx, svc.name, model.CauseConfigChange)
+	}
+}
+
+// runningList returns the running services, for loops that must not hold m.mu.
+func (m *ServiceManager) runningList() []*runningService {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	services := make([]*runningService, 0, len(m.services))
+	for _, svc := range m.services {
+		services = append(services, svc)
+	}
+	return services
+}
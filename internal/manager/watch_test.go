@@ -0,0 +1,93 @@
+package manager
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+func TestApplySwitchesRepointsRunningService(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	st := storage.NewStorage()
+	old := "kubectl port-forward svc/db-blue 59123:5432"
+	if err := st.AddService("db", old); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // the restart's new loop exits at once
+	svc := &runningService{name: "db", commands: []string{old}, localPort: "59123"}
+	svc.useCommandLocked(0)
+	m := &ServiceManager{services: map[string]*runningService{"db": svc}, storage: st, updates: make(chan struct{}, 1)}
+
+	m.applySwitches(ctx)
+	if len(svc.snapshot().Logs) != 0 {
+		t.Fatal("an unchanged command should not switch")
+	}
+
+	green, err := cmdline.Retarget(old, "svc/db-green", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AddService("db", green); err != nil {
+		t.Fatal(err)
+	}
+	m.applySwitches(ctx)
+	svc.mu.RLock()
+	first := svc.commands[0]
+	svc.mu.RUnlock()
+	if first != green {
+		t.Errorf("commands[0] = %q, want %q", first, green)
+	}
+	if logs := svc.snapshot().Logs; len(logs) == 0 || !strings.Contains(logs[0].Message, "SWITCHING to svc/db-green") {
+		t.Errorf("switch should be logged: %+v", logs)
+	}
+	state := waitFor(t, m, "counted the switch", func(s model.Service) bool { return s.Reconnects[model.CauseConfigChange] == 1 })
+	if state.Reconnects[model.CauseRestart] != 0 {
+		t.Errorf("a switch should not count as a restart: %v", state.Reconnects)
+	}
+
+	// A command on another local port cannot be switched to live.
+	if err := st.AddService("db", "kubectl port-forward svc/db-green 6000:5432"); err != nil {
+		t.Fatal(err)
+	}
+	m.applySwitches(ctx)
+	svc.mu.RLock()
+	first = svc.commands[0]
+	svc.mu.RUnlock()
+	if first != green {
+		t.Errorf("a different local port should be ignored, got %q", first)
+	}
+}
+
+func TestApplySwitchesKeepsSessionParams(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	st := storage.NewStorage()
+	if err := st.AddService("api", "kubectl port-forward -n staging svc/api 59124:80"); err != nil {
+		t.Fatal(err)
+	}
+
+	running := "kubectl port-forward -n pr-7 svc/api 59124:80"
+	svc := &runningService{name: "api", commands: []string{running}, localPort: "59124"}
+	svc.useCommandLocked(0)
+	m := &ServiceManager{services: map[string]*runningService{"api": svc}, storage: st, updates: make(chan struct{}, 1)}
+	m.SetParams(map[string]map[string]string{"": {"remote-port": "81"}, "api": {"namespace": "pr-7", "remote-port": "80"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.applySwitches(ctx)
+	if len(svc.snapshot().Logs) != 0 {
+		t.Fatalf("the saved command with the session's params is what runs, so nothing should switch: %+v", svc.snapshot().Logs)
+	}
+	if got := m.ServiceParams("web"); got["remote-port"] != "81" || len(got) != 1 {
+		t.Errorf("a service without its own params gets those for every service, got %v", got)
+	}
+}
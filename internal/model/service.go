@@ -1,6 +1,11 @@
 package model
 
-import "time"
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
 
 const (
 	StatusConnecting = "connecting"
@@ -8,17 +13,74 @@ const (
 	StatusError      = "error"
 )
 
+// Reconnect causes, the keys of Service.Reconnects. A process that exits for
+// any other reason counts under its exit code ("exit 1"), or "killed" when a
+// signal ended it.
+const (
+	CauseNetworkError  = "network error"  // it died reporting a connection problem (VPN, Wi-Fi)
+	CauseNetworkChange = "network change" // pf dropped the tunnel because the remote host moved
+	CauseHealthCheck   = "health check"   // it died after failing its health check
+	CauseStartFailed   = "start failed"   // the command could not be started at all
+	CausePreConnect    = "pre-connect"    // its pre-connect command failed, so it was not started
+	CauseRestart       = "restart"        // restarted from the TUI or on schedule
+	CauseConfigChange  = "config change"  // restarted on a command changed with pf switch or pf edit
+)
+
+// FormatReconnects summarizes reconnect counts by cause, most frequent first:
+// "3 network error · 1 exit 1".
+func FormatReconnects(counts map[string]int) string {
+	causes := make([]string, 0, len(counts))
+	for cause := range counts {
+		causes = append(causes, cause)
+	}
+	sort.Slice(causes, func(i, j int) bool {
+		if counts[causes[i]] != counts[causes[j]] {
+			return counts[causes[i]] > counts[causes[j]]
+		}
+		return causes[i] < causes[j]
+	})
+	parts := make([]string, len(causes))
+	for i, cause := range causes {
+		parts[i] = fmt.Sprintf("%d %s", counts[cause], cause)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// LogKind tells a child process's own output apart from the marker lines pf
+// injects into a service's log.
+type LogKind int
+
+const (
+	LogKindOutput    LogKind = iota // a line the child process printed
+	LogKindStatus                   // pf marker: the service changed status
+	LogKindReconnect                // pf marker: a reconnect attempt is scheduled
+	LogKindHTTP                     // a request seen by the service's inspecting relay
+	LogKindHint                     // pf advice on fixing the error above it
+)
+
 type LogEntry struct {
 	Time    time.Time
 	Message string
 	IsError bool
+	Kind    LogKind
+	Status  string // for LogKindStatus, the status the service moved to
 }
 
 type Service struct {
 	Name         string
-	Command      string
+	Command      string // the command in use (the service's own or an alternate)
+	Endpoint     int    // 1-based index of Command among the service's commands
+	Endpoints    int    // how many commands the service has; 1 = no alternates
+	Degraded     bool   // running on its fallback command after repeated failures
+	Relay        string // address of pf's relay in front of the forward; "" = none
 	LocalPort    string
 	MainPort     string
+	BindAddress  string // local bind address from the command; "" = loopback
+	Target       string // what the forward reaches, e.g. "svc/postgres"
+	Namespace    string // kubectl namespace of Target; "" when not given
+	APIProxy     bool   // a kubectl proxy service, serving the whole Kubernetes API
+	Monitor      string // what a monitor checks, e.g. "db /healthz"; "" for a forward
+	Deprecated   string // deprecation notice, e.g. "deprecated: use db-v2 instead"; "" = not deprecated
 	IconEnabled  bool
 	IconGlyph    string
 	IconColor    string
@@ -26,7 +88,164 @@ type Service struct {
 	LastError    string
 	StartTime    time.Time
 	RestartCount int
-	Logs         []LogEntry
+	// FlappingUntil is when the service stops counting as flapping (falling
+	// into error repeatedly); zero when it is not flapping. See Flapping.
+	FlappingUntil time.Time
+	// MaintenanceUntil is when the service's maintenance window ends; zero
+	// when it has none. See InMaintenance.
+	MaintenanceUntil time.Time
+	// NextRestart is the service's next scheduled restart, in its schedule's
+	// time zone; zero when it has no schedule.
+	NextRestart time.Time
+	// RollingOut is set while the workload behind the forward is being rolled
+	// out: pf waits for it to finish before reconnecting, and its errors are
+	// not notified.
+	RollingOut bool
+	// Prompt is what the service's process waits on an answer to, e.g.
+	// "me@bastion's password:" (see ServiceManager.AnswerPrompt); "" when it
+	// waits on nothing.
+	Prompt string
+	// Reconnects counts the service's reconnects this session by cause (see
+	// the Cause constants).
+	Reconnects map[string]int
+	// Transitions are the service's latest status changes, oldest first.
+	Transitions []Transition
+	// Metrics are the service's reliability figures this session.
+	Metrics Metrics
+	// LastExit is how the service's process last died, until it is healthy
+	// again; nil when it has not died.
+	LastExit *Exit
+	// OutputDropped counts the output lines dropped this session because the
+	// process printed faster than pf could log them; ThrottledUntil is when
+	// the service stops counting as throttled. See Throttled.
+	OutputDropped  int
+	ThrottledUntil time.Time
+	Logs           []LogEntry
+}
+
+// Exit is how a service's process died: its exit code or the signal that
+// ended it, how long it ran, and what it last printed on stderr.
+type Exit struct {
+	Code   int    // -1 when a signal ended it
+	Signal string // the signal, e.g. "killed"; "" when it exited
+	At     time.Time
+	Ran    time.Duration
+	Stderr []string // its last lines on stderr, oldest first
+	Hint   string   // how to fix the error it died of; "" when none is known
+}
+
+// Reason is "exit 1", or "signal: killed" when a signal ended the process.
+func (e Exit) Reason() string {
+	if e.Signal != "" {
+		return "signal: " + e.Signal
+	}
+	return fmt.Sprintf("exit %d", e.Code)
+}
+
+// Socket is one of the connections a service's process holds to the far
+// side (a bastion, the Kubernetes API server), with how it fares: "it feels
+// slow" as numbers.
+type Socket struct {
+	Local   string
+	Remote  string
+	RTT     time.Duration // smoothed round-trip time
+	RTTVar  time.Duration // how much the round-trip time varies
+	Retrans int           // segments retransmitted over the connection's life
+}
+
+// Transition is one status change: at At the service went from From to To,
+// after Duration in From.
+type Transition struct {
+	From     string
+	To       string
+	At       time.Time
+	Duration time.Duration
+}
+
+// Metrics are a service's reliability figures over a session: how long it
+// was healthy, how often it fell into error and how long its connects took.
+// They only change with its status, so they can be published as they are.
+type Metrics struct {
+	Healthy     time.Duration // time healthy before the current healthy spell
+	UpSince     time.Time     // start of the current healthy spell; zero when not healthy
+	Errors      int           // times it went into error
+	Connects    int           // connects that got it healthy
+	ConnectTime time.Duration // what those connects took, from process start to healthy
+}
+
+// Uptime is how long the service has been healthy in all, at now.
+func (m Metrics) Uptime(now time.Time) time.Duration {
+	if m.UpSince.IsZero() {
+		return m.Healthy
+	}
+	return m.Healthy + now.Sub(m.UpSince)
+}
+
+// AvgConnect is how long a connect took on average; 0 before the first.
+func (m Metrics) AvgConnect() time.Duration {
+	if m.Connects == 0 {
+		return 0
+	}
+	return m.ConnectTime / time.Duration(m.Connects)
+}
+
+// Flapping reports whether the service is flapping at now.
+func (s *Service) Flapping(now time.Time) bool {
+	return now.Before(s.FlappingUntil)
+}
+
+// Throttled reports whether the service's output was being dropped shortly
+// before now.
+func (s *Service) Throttled(now time.Time) bool {
+	return now.Before(s.ThrottledUntil)
+}
+
+// InMaintenance reports whether the service is under maintenance at now:
+// reconnects and notifications are held back until the window ends.
+func (s *Service) InMaintenance(now time.Time) bool {
+	return now.Before(s.MaintenanceUntil)
+}
+
+// GroupState is the combined status of a group started in a session: healthy
+// only while every member is healthy, in error as soon as one is in error or
+// stopped, and connecting otherwise.
+type GroupState struct {
+	Name    string
+	Status  string
+	Reason  string // the first member holding the group back, e.g. "db: connection refused"
+	Healthy int    // members that are healthy
+	Members []string
+}
+
+// NewGroupState combines the states of a group's members, looked up by name in
+// services.
+func NewGroupState(name string, members []string, services []Service) GroupState {
+	byName := make(map[string]*Service, len(services))
+	for i := range services {
+		byName[services[i].Name] = &services[i]
+	}
+	g := GroupState{Name: name, Status: StatusHealthy, Members: members}
+	for _, member := range members {
+		svc, ok := byName[member]
+		switch {
+		case !ok:
+			if g.Status != StatusError {
+				g.Status, g.Reason = StatusError, member+" is stopped"
+			}
+		case svc.Status == StatusHealthy:
+			g.Healthy++
+		case svc.Status == StatusError:
+			if g.Status != StatusError {
+				g.Status, g.Reason = StatusError, member+" is in error"
+				if svc.LastError != "" {
+					g.Reason = member + ": " + svc.LastError
+				}
+			}
+		case g.Status == StatusHealthy:
+			g.Status, g.Reason = svc.Status, member+" is "+svc.Status
+		}
+	}
+	return g
 }
 
 type PortConflict struct {
@@ -62,3 +62,37 @@ func TestPortConflictStruct(t *testing.T) {
 		t.Errorf("Services len = %d", len(conflict.Services))
 	}
 }
+
+func TestNewGroupState(t *testing.T) {
+	services := []Service{
+		{Name: "api", Status: StatusHealthy},
+		{Name: "db", Status: StatusConnecting},
+		{Name: "cache", Status: StatusError, LastError: "connection refused"},
+	}
+	tests := []struct {
+		members        []string
+		status, reason string
+		healthy        int
+	}{
+		{[]string{"api"}, StatusHealthy, "", 1},
+		{[]string{"api", "db"}, StatusConnecting, "db is connecting", 1},
+		{[]string{"db", "cache", "api"}, StatusError, "cache: connection refused", 1},
+		{[]string{"api", "queue"}, StatusError, "queue is stopped", 1},
+	}
+	for _, tt := range tests {
+		g := NewGroupState("stack", tt.members, services)
+		if g.Status != tt.status || g.Reason != tt.reason || g.Healthy != tt.healthy {
+			t.Errorf("NewGroupState(%v) = %s %q %d, want %s %q %d", tt.members, g.Status, g.Reason, g.Healthy, tt.status, tt.reason, tt.healthy)
+		}
+	}
+}
+
+func TestFormatReconnects(t *testing.T) {
+	got := FormatReconnects(map[string]int{"exit 1": 1, CauseNetworkError: 3, CauseRestart: 1})
+	if want := "3 network error · 1 exit 1 · 1 restart"; got != want {
+		t.Errorf("FormatReconnects = %q, want %q", got, want)
+	}
+	if got := FormatReconnects(nil); got != "" {
+		t.Errorf("no reconnects should format as empty, got %q", got)
+	}
+}
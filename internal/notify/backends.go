@@ -0,0 +1,171 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+func newNotifier(c storage.NotifierConfig) (Notifier, error) {
+	switch c.Type {
+	case "desktop":
+		if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+			return nil, fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+		}
+		return desktopNotifier{}, nil
+	case "webhook":
+		if c.URL == "" {
+			return nil, fmt.Errorf("webhook needs a url")
+		}
+		return webhookNotifier{url: c.URL}, nil
+	case "command":
+		if strings.TrimSpace(c.Command) == "" {
+			return nil, fmt.Errorf("command needs a command")
+		}
+		return commandNotifier{command: c.Command}, nil
+	case "email":
+		if c.SMTP == "" || c.From == "" || len(c.To) == 0 {
+			return nil, fmt.Errorf("email needs smtp, from and to")
+		}
+		if _, _, err := net.SplitHostPort(c.SMTP); err != nil {
+			return nil, fmt.Errorf("email smtp must be host:port: %v", err)
+		}
+		return emailNotifier{addr: c.SMTP, from: c.From, to: c.To, username: c.Username, passwordEnv: c.PasswordEnv}, nil
+	case "":
+		return nil, fmt.Errorf("missing type (desktop, webhook, email or command)")
+	}
+	return nil, fmt.Errorf("unknown type %q (use desktop, webhook, email or command)", c.Type)
+}
+
+func title(e Event) string {
+	subject := e.Service
+	if e.Group != "" {
+		subject = "group " + e.Group
+	}
+	switch e.Kind {
+	case EventRecovered:
+		return "pf: " + subject + " recovered"
+	case EventFlapping:
+		return "pf: " + subject + " is flapping"
+	}
+	return "pf: " + subject + " is down"
+}
+
+func body(e Event) string {
+	if e.Error != "" {
+		return e.Message + ": " + e.Error
+	}
+	return e.Message
+}
+
+// desktopNotifier pops up a notification with notify-send (Linux) or
+// osascript (macOS).
+type desktopNotifier struct{}
+
+func (desktopNotifier) Notify(ctx context.Context, e Event) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(body(e)), appleScriptString(title(e)))
+		cmd = exec.CommandContext(ctx, "osascript", "-e", script)
+	} else {
+		cmd = exec.CommandContext(ctx, "notify-send", "--app-name=pf", title(e), body(e))
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("desktop notification: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// webhookNotifier POSTs the event as JSON.
+type webhookNotifier struct {
+	url string
+}
+
+func (w webhookNotifier) Notify(ctx context.Context, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook: %s returned %s", w.url, resp.Status)
+	}
+	return nil
+}
+
+// commandNotifier runs a shell command with the event in PF_EVENT,
+// PF_SERVICE (or PF_GROUP), PF_MESSAGE, PF_ERROR and PF_SINCE.
+type commandNotifier struct {
+	command string
+}
+
+func (c commandNotifier) Notify(ctx context.Context, e Event) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", c.command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", c.command)
+	}
+	cmd.Env = append(os.Environ(),
+		"PF_EVENT="+e.Kind,
+		"PF_SERVICE="+e.Service,
+		"PF_GROUP="+e.Group,
+		"PF_MESSAGE="+body(e),
+		"PF_ERROR="+e.Error,
+		"PF_SINCE="+e.Since.Format("2006-01-02T15:04:05Z07:00"),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("notify command: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// emailNotifier sends a plain-text mail over SMTP. The password is read from
+// the environment variable named in the config, never from the file itself.
+type emailNotifier struct {
+	addr        string
+	from        string
+	to          []string
+	username    string
+	passwordEnv string
+}
+
+func (m emailNotifier) Notify(_ context.Context, e Event) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		host, _, _ := net.SplitHostPort(m.addr)
+		auth = smtp.PlainAuth("", m.username, os.Getenv(m.passwordEnv), host)
+	}
+	msg := "From: " + m.from + "\r\n" +
+		"To: " + strings.Join(m.to, ", ") + "\r\n" +
+		"Subject: " + title(e) + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + body(e) + "\r\n"
+	if err := smtp.SendMail(m.addr, auth, m.from, m.to, []byte(msg)); err != nil {
+		return fmt.Errorf("email: %v", err)
+	}
+	return nil
+}
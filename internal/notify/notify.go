@@ -0,0 +1,362 @@
+// Package notify tells the user about service trouble through pluggable
+// backends (desktop, webhook, email, command). Each configured backend comes
+// with its own filters, so e.g. a webhook can hear only about one service's
+// errors that last over a minute while the desktop gets everything.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/events"
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// Event kinds.
+const (
+	EventError     = "error"     // a service has been in error for the rule's minDuration
+	EventRecovered = "recovered" // a service reported in error is healthy again
+	EventFlapping  = "flapping"  // a service keeps falling into error; sent once per episode
+)
+
+// Event is one notification, about a service or, for rules with groups, about
+// a started group as a whole.
+type Event struct {
+	Kind    string    `json:"event"`
+	Service string    `json:"service,omitempty"`
+	Group   string    `json:"group,omitempty"`
+	Message string    `json:"message"`         // one-line summary for humans
+	Error   string    `json:"error,omitempty"` // the service's last error
+	Since   time.Time `json:"since"`           // when the service entered error
+	Time    time.Time `json:"time"`
+}
+
+// Notifier delivers events through one backend.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// Rule is a notifier plus the filters from its config entry. A rule with
+// Groups hears about those groups turning unhealthy and healthy again, and
+// about services only if it names them too.
+type Rule struct {
+	Notifier    Notifier
+	Services    map[string]bool // empty = all services, unless Groups is set
+	Groups      map[string]bool
+	Events      map[string]bool // empty = all events
+	MinDuration time.Duration
+
+	config storage.NotifierConfig // the entry the rule was built from
+}
+
+func (r *Rule) wants(kind, service string) bool {
+	return (len(r.Events) == 0 || r.Events[kind]) && ((len(r.Services) == 0 && len(r.Groups) == 0) || r.Services[service])
+}
+
+func (r *Rule) wantsGroup(kind, group string) bool {
+	return (len(r.Events) == 0 || r.Events[kind]) && r.Groups[group]
+}
+
+// Build turns the config's "notify" list into rules, rejecting unknown types
+// and events, bad durations and backends missing required fields.
+func Build(cfgs []storage.NotifierConfig) ([]Rule, error) {
+	rules := make([]Rule, 0, len(cfgs))
+	for i, c := range cfgs {
+		n, err := newNotifier(c)
+		if err != nil {
+			return nil, fmt.Errorf("notify[%d]: %v", i, err)
+		}
+		r := Rule{Notifier: n, Services: map[string]bool{}, Groups: map[string]bool{}, Events: map[string]bool{}, config: c}
+		for _, s := range c.Services {
+			r.Services[s] = true
+		}
+		for _, g := range c.Groups {
+			r.Groups[g] = true
+		}
+		for _, e := range c.Events {
+			if e != EventError && e != EventRecovered && e != EventFlapping {
+				return nil, fmt.Errorf("notify[%d]: unknown event %q (use %s, %s or %s)", i, e, EventError, EventRecovered, EventFlapping)
+			}
+			r.Events[e] = true
+		}
+		if c.MinDuration != "" {
+			d, err := time.ParseDuration(c.MinDuration)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("notify[%d]: invalid minDuration %q", i, c.MinDuration)
+			}
+			r.MinDuration = d
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// sendTimeout bounds one delivery, so a hung webhook or SMTP server cannot
+// pile up goroutines.
+const sendTimeout = 15 * time.Second
+
+// Dispatcher watches service states and sends events to the rules that want
+// them. An error is sent once per rule after it has lasted that rule's
+// MinDuration; recovery is only sent to rules that were told about the error.
+// While a service is flapping its individual errors and recoveries are held
+// back and a single flapping event goes out instead. Services under
+// maintenance or waiting out a rollout send nothing. Groups (see FollowGroups) get the same error and
+// recovery handling as services.
+type Dispatcher struct {
+	rules   []Rule
+	states  func() []model.Service
+	groups  func() []model.GroupState // nil = no group events
+	onError func(error)               // delivery failures; may be nil
+	changes <-chan events.Event       // session events that call for a check now; nil = ticks only
+
+	errorSince map[string]time.Time      // service → when it entered error
+	flapping   map[string]bool           // services whose flapping event went out
+	sent       []map[string]bool         // per rule: services told about an error
+	groupSince map[string]time.Time      // group → when it became unhealthy
+	groupSent  []map[string]bool         // per rule: groups told about an error
+	send       func(n Notifier, e Event) // delivery; asynchronous outside tests
+	wg         sync.WaitGroup
+	mu         sync.Mutex // guards rules and the per-rule state against SetRules
+}
+
+// NewDispatcher builds a dispatcher over states. onError, if set, hears about
+// deliveries that fail.
+func NewDispatcher(rules []Rule, states func() []model.Service, onError func(error)) *Dispatcher {
+	d := &Dispatcher{
+		rules:      rules,
+		states:     states,
+		onError:    onError,
+		errorSince: map[string]time.Time{},
+		flapping:   map[string]bool{},
+		sent:       make([]map[string]bool, len(rules)),
+		groupSince: map[string]time.Time{},
+		groupSent:  make([]map[string]bool, len(rules)),
+	}
+	for i := range d.sent {
+		d.sent[i] = map[string]bool{}
+		d.groupSent[i] = map[string]bool{}
+	}
+	d.send = d.deliver
+	return d
+}
+
+// FollowGroups makes the dispatcher watch groups too, for rules that name
+// them. Call it before Run.
+func (d *Dispatcher) FollowGroups(groups func() []model.GroupState) {
+	d.groups = groups
+}
+
+// FollowEvents makes the dispatcher check the states as soon as an event
+// arrives on changes, so a rule without a minimum duration hears of an error
+// when it happens rather than on the next tick. Call it before Run.
+func (d *Dispatcher) FollowEvents(changes <-chan events.Event) {
+	d.changes = changes
+}
+
+// Run checks the states every second, and on each event (see FollowEvents),
+// until ctx ends, then waits for deliveries in flight.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			d.wg.Wait()
+			return
+		case now = <-ticker.C:
+		case _, ok := <-d.changes:
+			if !ok {
+				d.changes = nil // the bus is gone: ticks only
+				continue
+			}
+			now = time.Now()
+		}
+		d.mu.Lock()
+		d.check(now)
+		d.mu.Unlock()
+	}
+}
+
+// SetRules replaces the rules of a running dispatcher, e.g. after the config
+// was reloaded. A rule built from the same config entry as an old one keeps
+// what it was already told, so an ongoing error is not reported twice; new
+// rules hear about it once it lasts their MinDuration.
+func (d *Dispatcher) SetRules(rules []Rule) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	sent := make([]map[string]bool, len(rules))
+	groupSent := make([]map[string]bool, len(rules))
+	kept := make([]bool, len(d.rules))
+	for i := range rules {
+		sent[i], groupSent[i] = map[string]bool{}, map[string]bool{}
+		for j := range d.rules {
+			if !kept[j] && reflect.DeepEqual(rules[i].config, d.rules[j].config) {
+				sent[i], groupSent[i], kept[j] = d.sent[j], d.groupSent[j], true
+				break
+			}
+		}
+	}
+	d.rules, d.sent, d.groupSent = rules, sent, groupSent
+}
+
+func (d *Dispatcher) check(now time.Time) {
+	seen := map[string]bool{}
+	for _, svc := range d.states() {
+		if svc.InMaintenance(now) || svc.RollingOut {
+			continue // treated as stopped: any open episode ends quietly
+		}
+		seen[svc.Name] = true
+		if svc.Flapping(now) {
+			if !d.flapping[svc.Name] {
+				d.flapping[svc.Name] = true
+				for i := range d.rules {
+					if d.rules[i].wants(EventFlapping, svc.Name) {
+						d.send(d.rules[i].Notifier, Event{
+							Kind:    EventFlapping,
+							Service: svc.Name,
+							Message: svc.Name + " is flapping: it keeps falling into error",
+							Error:   svc.LastError,
+							Since:   now,
+							Time:    now,
+						})
+					}
+				}
+			}
+			continue
+		}
+		delete(d.flapping, svc.Name)
+
+		if svc.Status == model.StatusError {
+			since, ok := d.errorSince[svc.Name]
+			if !ok {
+				since = now
+				d.errorSince[svc.Name] = since
+			}
+			for i := range d.rules {
+				r := &d.rules[i]
+				if d.sent[i][svc.Name] || !r.wants(EventError, svc.Name) || now.Sub(since) < r.MinDuration {
+					continue
+				}
+				d.sent[i][svc.Name] = true
+				d.send(r.Notifier, Event{
+					Kind:    EventError,
+					Service: svc.Name,
+					Message: fmt.Sprintf("%s has been in error for %s", svc.Name, now.Sub(since).Round(time.Second)),
+					Error:   svc.LastError,
+					Since:   since,
+					Time:    now,
+				})
+			}
+			continue
+		}
+
+		since, wasError := d.errorSince[svc.Name]
+		if !wasError || svc.Status != model.StatusHealthy {
+			continue // still reconnecting: keep the error episode open
+		}
+		delete(d.errorSince, svc.Name)
+		for i := range d.rules {
+			if !d.sent[i][svc.Name] {
+				continue
+			}
+			delete(d.sent[i], svc.Name)
+			if d.rules[i].wants(EventRecovered, svc.Name) {
+				d.send(d.rules[i].Notifier, Event{
+					Kind:    EventRecovered,
+					Service: svc.Name,
+					Message: fmt.Sprintf("%s recovered after %s", svc.Name, now.Sub(since).Round(time.Second)),
+					Since:   since,
+					Time:    now,
+				})
+			}
+		}
+	}
+
+	// A stopped service ends its episode without a recovery message.
+	for name := range d.errorSince {
+		if !seen[name] {
+			delete(d.errorSince, name)
+			for i := range d.sent {
+				delete(d.sent[i], name)
+			}
+		}
+	}
+	for name := range d.flapping {
+		if !seen[name] {
+			delete(d.flapping, name)
+		}
+	}
+
+	if d.groups != nil {
+		d.checkGroups(now)
+	}
+}
+
+// checkGroups is check for groups: unhealthy for a rule's MinDuration sends an
+// error, healthy again a recovery. A group that is connecting (a member is
+// reconnecting) counts as unhealthy, since the stack as a whole is not usable.
+func (d *Dispatcher) checkGroups(now time.Time) {
+	for _, g := range d.groups() {
+		if g.Status != model.StatusHealthy {
+			since, ok := d.groupSince[g.Name]
+			if !ok {
+				since = now
+				d.groupSince[g.Name] = since
+			}
+			for i := range d.rules {
+				r := &d.rules[i]
+				if d.groupSent[i][g.Name] || !r.wantsGroup(EventError, g.Name) || now.Sub(since) < r.MinDuration {
+					continue
+				}
+				d.groupSent[i][g.Name] = true
+				d.send(r.Notifier, Event{
+					Kind:    EventError,
+					Group:   g.Name,
+					Message: fmt.Sprintf("group %s has been unhealthy for %s (%d/%d healthy)", g.Name, now.Sub(since).Round(time.Second), g.Healthy, len(g.Members)),
+					Error:   g.Reason,
+					Since:   since,
+					Time:    now,
+				})
+			}
+			continue
+		}
+
+		since, wasDown := d.groupSince[g.Name]
+		if !wasDown {
+			continue
+		}
+		delete(d.groupSince, g.Name)
+		for i := range d.rules {
+			if !d.groupSent[i][g.Name] {
+				continue
+			}
+			delete(d.groupSent[i], g.Name)
+			if d.rules[i].wantsGroup(EventRecovered, g.Name) {
+				d.send(d.rules[i].Notifier, Event{
+					Kind:    EventRecovered,
+					Group:   g.Name,
+					Message: fmt.Sprintf("group %s is healthy again after %s", g.Name, now.Sub(since).Round(time.Second)),
+					Since:   since,
+					Time:    now,
+				})
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(n Notifier, e Event) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		if err := n.Notify(ctx, e); err != nil && d.onError != nil {
+			d.onError(err)
+		}
+	}()
+}
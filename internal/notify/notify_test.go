@@ -0,0 +1,264 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/events"
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+type recorder struct{ events []Event }
+
+func (r *recorder) Notify(_ context.Context, e Event) error {
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *recorder) kinds() string {
+	var k []string
+	for _, e := range r.events {
+		if e.Group != "" {
+			k = append(k, e.Kind+":group "+e.Group)
+			continue
+		}
+		k = append(k, e.Kind+":"+e.Service)
+	}
+	return strings.Join(k, ",")
+}
+
+func TestDispatcherAppliesPerRuleFilters(t *testing.T) {
+	everything, dbOnlySlow := &recorder{}, &recorder{}
+	states := []model.Service{
+		{Name: "db", Status: model.StatusHealthy},
+		{Name: "api", Status: model.StatusHealthy},
+	}
+	d := NewDispatcher([]Rule{
+		{Notifier: everything},
+		{Notifier: dbOnlySlow, Services: map[string]bool{"db": true}, Events: map[string]bool{EventError: true}, MinDuration: time.Minute},
+	}, func() []model.Service { return states }, nil)
+	d.send = func(n Notifier, e Event) { n.Notify(context.Background(), e) }
+
+	t0 := time.Now()
+	d.check(t0)
+	states[0].Status, states[0].LastError = model.StatusError, "connection refused"
+	states[1].Status = model.StatusError
+	d.check(t0.Add(time.Second))
+	d.check(t0.Add(30 * time.Second))
+	if got := everything.kinds(); got != "error:db,error:api" {
+		t.Errorf("unfiltered rule got %q, want one error per service", got)
+	}
+	if len(dbOnlySlow.events) != 0 {
+		t.Fatalf("minDuration rule fired early: %q", dbOnlySlow.kinds())
+	}
+
+	d.check(t0.Add(62 * time.Second))
+	if got := dbOnlySlow.kinds(); got != "error:db" {
+		t.Errorf("filtered rule got %q, want only error:db after a minute", got)
+	}
+	if e := dbOnlySlow.events[0]; e.Error != "connection refused" {
+		t.Errorf("event error = %q", e.Error)
+	}
+
+	states[0].Status, states[1].Status = model.StatusHealthy, model.StatusHealthy
+	d.check(t0.Add(70 * time.Second))
+	if got := everything.kinds(); got != "error:db,error:api,recovered:db,recovered:api" {
+		t.Errorf("unfiltered rule got %q, want recoveries", got)
+	}
+	if got := dbOnlySlow.kinds(); got != "error:db" {
+		t.Errorf("error-only rule should not get recoveries: %q", got)
+	}
+}
+
+func TestDispatcherSkipsShortErrors(t *testing.T) {
+	r := &recorder{}
+	states := []model.Service{{Name: "db", Status: model.StatusError}}
+	d := NewDispatcher([]Rule{{Notifier: r, MinDuration: time.Minute}}, func() []model.Service { return states }, nil)
+	d.send = func(n Notifier, e Event) { n.Notify(context.Background(), e) }
+
+	t0 := time.Now()
+	d.check(t0)
+	states[0].Status = model.StatusHealthy
+	d.check(t0.Add(10 * time.Second))
+	if len(r.events) != 0 {
+		t.Errorf("a 10s blip should not notify a 1m rule, or recover: %q", r.kinds())
+	}
+}
+
+func TestBuildValidatesConfig(t *testing.T) {
+	for _, c := range []storage.NotifierConfig{
+		{Type: "pager"},
+		{Type: "webhook"},
+		{Type: "command", Command: "true", Events: []string{"flap"}},
+		{Type: "command", Command: "true", MinDuration: "soon"},
+		{Type: "email", SMTP: "mail.example.com", From: "pf@example.com", To: []string{"me@example.com"}},
+	} {
+		if _, err := Build([]storage.NotifierConfig{c}); err == nil {
+			t.Errorf("Build(%+v) should fail", c)
+		}
+	}
+
+	rules, err := Build([]storage.NotifierConfig{{Type: "webhook", URL: "http://x", Services: []string{"db"}, MinDuration: "60s"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := rules[0]; !r.Services["db"] || r.MinDuration != time.Minute || !r.wants(EventRecovered, "db") || r.wants(EventError, "api") {
+		t.Errorf("unexpected rule: %+v", r)
+	}
+}
+
+func TestWebhookPostsEventJSON(t *testing.T) {
+	var got Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("content type %q", ct)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	n := webhookNotifier{url: srv.URL}
+	if err := n.Notify(context.Background(), Event{Kind: EventError, Service: "db"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != EventError || got.Service != "db" {
+		t.Errorf("webhook received %+v", got)
+	}
+}
+
+func TestDispatcherAggregatesFlapping(t *testing.T) {
+	r := &recorder{}
+	t0 := time.Now()
+	states := []model.Service{{Name: "db", Status: model.StatusError}}
+	d := NewDispatcher([]Rule{{Notifier: r}}, func() []model.Service { return states }, nil)
+	d.send = func(n Notifier, e Event) { n.Notify(context.Background(), e) }
+
+	states[0].FlappingUntil = t0.Add(5 * time.Minute)
+	for i := 0; i < 10; i++ {
+		if i%2 == 0 {
+			states[0].Status = model.StatusHealthy
+		} else {
+			states[0].Status = model.StatusError
+		}
+		d.check(t0.Add(time.Duration(i) * time.Second))
+	}
+	if got := r.kinds(); got != "flapping:db" {
+		t.Fatalf("got %q, want a single flapping event", got)
+	}
+
+	// Stable again: normal error reporting resumes.
+	states[0].FlappingUntil = time.Time{}
+	states[0].Status = model.StatusError
+	d.check(t0.Add(6 * time.Minute))
+	if got := r.kinds(); got != "flapping:db,error:db" {
+		t.Errorf("got %q, want errors reported again once stable", got)
+	}
+}
+
+func TestDispatcherIsQuietDuringMaintenance(t *testing.T) {
+	r := &recorder{}
+	t0 := time.Now()
+	states := []model.Service{{Name: "db", Status: model.StatusError, MaintenanceUntil: t0.Add(time.Hour)}}
+	d := NewDispatcher([]Rule{{Notifier: r}}, func() []model.Service { return states }, nil)
+	d.send = func(n Notifier, e Event) { n.Notify(context.Background(), e) }
+
+	d.check(t0)
+	states[0].Status = model.StatusHealthy
+	d.check(t0.Add(time.Minute))
+	if len(r.events) != 0 {
+		t.Errorf("no events expected during maintenance, got %q", r.kinds())
+	}
+}
+
+func TestDispatcherReportsGroups(t *testing.T) {
+	services, stack := &recorder{}, &recorder{}
+	states := []model.Service{{Name: "db", Status: model.StatusHealthy}, {Name: "api", Status: model.StatusHealthy}}
+	d := NewDispatcher([]Rule{
+		{Notifier: services},
+		{Notifier: stack, Groups: map[string]bool{"backend": true}},
+	}, func() []model.Service { return states }, nil)
+	d.FollowGroups(func() []model.GroupState {
+		return []model.GroupState{model.NewGroupState("backend", []string{"db", "api"}, states)}
+	})
+	d.send = func(n Notifier, e Event) { n.Notify(context.Background(), e) }
+
+	t0 := time.Now()
+	d.check(t0)
+	states[0].Status, states[0].LastError = model.StatusError, "connection refused"
+	d.check(t0.Add(time.Second))
+	states[0].Status = model.StatusConnecting
+	d.check(t0.Add(2 * time.Second))
+	states[0].Status = model.StatusHealthy
+	d.check(t0.Add(3 * time.Second))
+
+	if got := stack.kinds(); got != "error:group backend,recovered:group backend" {
+		t.Errorf("group rule got %q", got)
+	}
+	if e := stack.events[0]; e.Error != "db: connection refused" || title(e) != "pf: group backend is down" {
+		t.Errorf("group error event = %+v", e)
+	}
+	if got := services.kinds(); got != "error:db,recovered:db" {
+		t.Errorf("a rule without groups should hear only services, got %q", got)
+	}
+}
+
+func TestSetRulesKeepsWhatUnchangedRulesWereTold(t *testing.T) {
+	kept, added := &recorder{}, &recorder{}
+	states := []model.Service{{Name: "db", Status: model.StatusHealthy}}
+	d := NewDispatcher([]Rule{{Notifier: kept, config: storage.NotifierConfig{Type: "command", Command: "a"}}},
+		func() []model.Service { return states }, nil)
+	d.send = func(n Notifier, e Event) { n.Notify(context.Background(), e) }
+
+	t0 := time.Now()
+	d.check(t0)
+	states[0].Status = model.StatusError
+	d.check(t0.Add(time.Second))
+	d.SetRules([]Rule{
+		{Notifier: kept, config: storage.NotifierConfig{Type: "command", Command: "a"}},
+		{Notifier: added, config: storage.NotifierConfig{Type: "command", Command: "b"}},
+	})
+	d.check(t0.Add(2 * time.Second))
+	if got := kept.kinds(); got != "error:db" {
+		t.Errorf("unchanged rule got %q, want the error once", got)
+	}
+	if got := added.kinds(); got != "error:db" {
+		t.Errorf("new rule got %q, want the ongoing error", got)
+	}
+}
+
+func TestDispatcherChecksOnEvents(t *testing.T) {
+	got := make(chan Event, 1)
+	states := []model.Service{{Name: "db", Status: model.StatusError, LastError: "connection refused"}}
+	d := NewDispatcher([]Rule{{Notifier: &recorder{}}}, func() []model.Service { return states }, nil)
+	d.send = func(_ Notifier, e Event) { got <- e }
+	var bus events.Bus
+	sub := bus.Subscribe(0, events.ServiceStatus)
+	d.FollowEvents(sub.C)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	bus.Publish(events.Event{Kind: events.ServiceStatus, Service: "db", To: model.StatusError})
+	select {
+	case e := <-got:
+		if e.Kind != EventError || e.Service != "db" {
+			t.Errorf("event = %+v, want an error for db", e)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("the status event did not trigger a check before the next tick")
+	}
+	sub.Close() // a closed subscription leaves the dispatcher on ticks
+}
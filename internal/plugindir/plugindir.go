@@ -0,0 +1,89 @@
+// Package plugindir finds the plugins pf runs, probes and forwarders alike:
+// each is an executable named after the plugin, in its kind's directory
+// under ~/.pf/plugins.
+package plugindir
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Plugin is a plugin's executable Path, found under Name.
+type Plugin struct {
+	Name string
+	Path string
+}
+
+// Dir is where the plugins of kind live: ~/.pf/plugins/<kind>.
+func Dir(kind string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".pf", "plugins", kind), nil
+}
+
+// namePattern is what a plugin's name may look like; it is a file name.
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ValidName reports whether name can name a plugin. what is what errors
+// call the plugin, such as "probe".
+func ValidName(what, name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("%s plugin names are lowercase letters, digits, - and _, not %q", what, name)
+	}
+	return nil
+}
+
+// Find returns the plugin name in dir: the executable <name>, or <name>.exe
+// on Windows.
+func Find(what, dir, name string) (Plugin, error) {
+	if err := ValidName(what, name); err != nil {
+		return Plugin{}, err
+	}
+	file := name
+	if runtime.GOOS == "windows" {
+		file += ".exe"
+	}
+	path := filepath.Join(dir, file)
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Plugin{}, fmt.Errorf("no %s plugin %q in %s", what, name, dir)
+	}
+	if err != nil {
+		return Plugin{}, err
+	}
+	if info.IsDir() || (runtime.GOOS != "windows" && info.Mode().Perm()&0111 == 0) {
+		return Plugin{}, fmt.Errorf("%s plugin %s is not executable", what, path)
+	}
+	return Plugin{Name: name, Path: path}, nil
+}
+
+// List returns the plugins in dir, by name; none when dir does not exist.
+// Files that are not executable are left out.
+func List(what, dir string) ([]Plugin, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var plugins []Plugin
+	for _, e := range entries {
+		name := e.Name()
+		if runtime.GOOS == "windows" {
+			name = strings.TrimSuffix(name, ".exe")
+		}
+		if p, err := Find(what, dir, name); err == nil {
+			plugins = append(plugins, p)
+		}
+	}
+	return plugins, nil
+}
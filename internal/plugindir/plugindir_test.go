@@ -0,0 +1,42 @@
+package plugindir
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestFindAndList(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("executables are .exe files")
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "vpn"), []byte("#!/bin/sh\n"), 0755)
+	os.WriteFile(filepath.Join(dir, "lag"), []byte("#!/bin/sh\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a plugin"), 0755)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+
+	plugins, err := List("probe", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plugins) != 1 || plugins[0] != (Plugin{Name: "vpn", Path: filepath.Join(dir, "vpn")}) {
+		t.Errorf("plugins = %v, want only vpn", plugins)
+	}
+	if none, err := List("probe", filepath.Join(dir, "missing")); err != nil || none != nil {
+		t.Errorf("a missing directory = %v, %v, want none", none, err)
+	}
+
+	for name, want := range map[string]string{
+		"lag":      "probe plugin " + filepath.Join(dir, "lag") + " is not executable",
+		"sub":      "is not executable",
+		"ztna":     `no probe plugin "ztna" in ` + dir,
+		"Bad.Name": `probe plugin names are lowercase letters`,
+	} {
+		if _, err := Find("probe", dir, name); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Find(%q) = %v, want %q", name, err, want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Package probe holds the health checks monitors run (see cmdline.Monitor):
+// pf's own, over TCP and HTTP, and probe plugins, executables an
+// organization drops in ~/.pf/plugins/probes to check what pf cannot know
+// about, such as replication lag or a proprietary gateway's status, without
+// forking pf.
+//
+// A plugin is run once per check, with a JSON Request on stdin, and answers
+// with a JSON Response on stdout:
+//
+//	{"protocol": 1, "monitor": "db-lag", "via": "db", "address": "127.0.0.1:5432", "params": {"max": "10s"}, "timeout": "5s"}
+//	{"healthy": false, "message": "replication lag 42s"}
+//
+// It must answer within the timeout, or it is killed and the check fails.
+// What it prints on stderr explains a failure that has no response.
+package probe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/plugindir"
+)
+
+// Protocol is the version of the plugin protocol pf speaks.
+const Protocol = 1
+
+// Target is what a check is about.
+type Target struct {
+	Monitor string            // the monitor running the check
+	Via     string            // the forward the check goes through; "" = none
+	Address string            // Via's local address, host:port; "" without Via
+	Params  map[string]string // the monitor's --param settings, for plugins
+}
+
+// HealthProbe is a health check: Check returns nil when the target is
+// healthy, and why not otherwise. ctx bounds the check.
+type HealthProbe interface {
+	Check(ctx context.Context, t Target) error
+}
+
+// TCP passes when a connection to the target stays open for Hold. A
+// port-forward accepts locally even when the remote end is down, then drops
+// the connection once its own dial fails, so an accepted connection alone
+// proves nothing.
+type TCP struct {
+	Hold time.Duration
+}
+
+func (p TCP) Check(ctx context.Context, t Target) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", t.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(p.Hold))
+	// Servers that greet (MySQL, SSH) send bytes; others wait
+	// for the client. Either is fine, as long as the tunnel does not hang up.
+	var b [1]byte
+	if n, err := conn.Read(b[:]); n == 0 {
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			return fmt.Errorf("the remote end behind '%s' dropped the connection", t.Via)
+		}
+	}
+	return nil
+}
+
+// HTTP passes when a GET of Path on the target answers below 400, asked with
+// Client, or http.DefaultClient when nil.
+type HTTP struct {
+	Path   string
+	Client *http.Client
+}
+
+func (p HTTP) Check(ctx context.Context, t Target) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+t.Address+p.Path, nil)
+	if err != nil {
+		return err
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("GET %s: %v", p.Path, err)
+	}
+	// Read a little of the body, so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("GET %s: %s", p.Path, resp.Status)
+	}
+	return nil
+}
+
+// Request is what a plugin reads on stdin.
+type Request struct {
+	Protocol int               `json:"protocol"`
+	Monitor  string            `json:"monitor"`
+	Via      string            `json:"via,omitempty"`
+	Address  string            `json:"address,omitempty"`
+	Params   map[string]string `json:"params,omitempty"`
+	Timeout  string            `json:"timeout,omitempty"` // how long it has, e.g. "5s"
+}
+
+// Response is what a plugin writes on stdout.
+type Response struct {
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"` // why not, when unhealthy
+}
+
+// Plugin is a probe plugin: the executable Path, found under Name.
+type Plugin struct {
+	Name string
+	Path string
+}
+
+func (p Plugin) Check(ctx context.Context, t Target) error {
+	req := Request{Protocol: Protocol, Monitor: t.Monitor, Via: t.Via, Address: t.Address, Params: t.Params}
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Timeout = time.Until(deadline).Round(time.Millisecond).String()
+	}
+	in, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Path)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	// What it leaves behind must not hold the check up by keeping the
+	// output open.
+	cmd.WaitDelay = time.Second
+	runErr := cmd.Run()
+	if errors.Is(runErr, exec.ErrWaitDelay) {
+		runErr = nil // it exited; only what it started lives on
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("probe %s: no answer in time", p.Name)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(bytes.TrimSpace(stdout.Bytes()), &resp); err != nil {
+		if runErr == nil {
+			return fmt.Errorf("probe %s: bad response: %v", p.Name, err)
+		}
+		return fmt.Errorf("probe %s: %s", p.Name, failure(runErr, stderr.String()))
+	}
+	switch {
+	case !resp.Healthy && resp.Message != "":
+		return errors.New(resp.Message)
+	case !resp.Healthy:
+		return fmt.Errorf("probe %s: unhealthy", p.Name)
+	case runErr != nil:
+		return fmt.Errorf("probe %s: %s", p.Name, failure(runErr, stderr.String()))
+	}
+	return nil
+}
+
+// failure describes a plugin run that failed: its last stderr line, or how
+// it exited.
+func failure(err error, stderr string) string {
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return last
+	}
+	return err.Error()
+}
+
+// Dir is where probe plugins live: ~/.pf/plugins/probes.
+func Dir() (string, error) {
+	return plugindir.Dir("probes")
+}
+
+// ValidName reports whether name can name a probe plugin.
+func ValidName(name string) error {
+	return plugindir.ValidName("probe", name)
+}
+
+// Find returns the plugin name in dir: the executable <name>, or <name>.exe
+// on Windows.
+func Find(dir, name string) (Plugin, error) {
+	p, err := plugindir.Find("probe", dir, name)
+	return Plugin(p), err
+}
+
+// List returns the plugins in dir, by name; none when dir does not exist.
+// Files that are not executable are left out.
+func List(dir string) ([]Plugin, error) {
+	found, err := plugindir.List("probe", dir)
+	var plugins []Plugin
+	for _, p := range found {
+		plugins = append(plugins, Plugin(p))
+	}
+	return plugins, err
+}
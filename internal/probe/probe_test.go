@@ -0,0 +1,77 @@
+package probe
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writePlugin saves a shell script as the plugin name in dir.
+func writePlugin(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlugin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	// Echoes the request back in its message, so the test sees what it got.
+	writePlugin(t, dir, "echo", `req=$(cat); case "$req" in *'"max":"10s"'*) echo '{"healthy": true}';; *) echo "{\"healthy\": false, \"message\": \"lag too high\"}";; esac`)
+	writePlugin(t, dir, "crash", `echo "cannot reach the gateway" >&2; exit 3`)
+	writePlugin(t, dir, "garbage", `echo ok`)
+	writePlugin(t, dir, "slow", `sleep 5`)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a plugin"), 0644)
+
+	plugins, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range plugins {
+		names = append(names, p.Name)
+	}
+	if strings.Join(names, ",") != "crash,echo,garbage,slow" {
+		t.Errorf("plugins = %v", names)
+	}
+
+	check := func(name string, params map[string]string, timeout time.Duration) error {
+		t.Helper()
+		p, err := Find(dir, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return p.Check(ctx, Target{Monitor: "db-lag", Via: "db", Address: "127.0.0.1:5432", Params: params})
+	}
+	if err := check("echo", map[string]string{"max": "10s"}, 5*time.Second); err != nil {
+		t.Errorf("healthy answer: %v", err)
+	}
+	if err := check("echo", nil, 5*time.Second); err == nil || err.Error() != "lag too high" {
+		t.Errorf("unhealthy answer: %v", err)
+	}
+	if err := check("crash", nil, 5*time.Second); err == nil || err.Error() != "probe crash: cannot reach the gateway" {
+		t.Errorf("crash: %v", err)
+	}
+	if err := check("garbage", nil, 5*time.Second); err == nil || !strings.Contains(err.Error(), "bad response") {
+		t.Errorf("garbage: %v", err)
+	}
+	if err := check("slow", nil, 100*time.Millisecond); err == nil || !strings.Contains(err.Error(), "no answer in time") {
+		t.Errorf("slow: %v", err)
+	}
+
+	if _, err := Find(dir, "notes.txt"); err == nil {
+		t.Error("a name with a dot should be refused")
+	}
+	if _, err := Find(dir, "missing"); err == nil {
+		t.Error("a missing plugin should not be found")
+	}
+}
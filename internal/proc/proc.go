@@ -0,0 +1,192 @@
+// Package proc finds and stops the processes pf deals with: what listens on
+// a local port, what a process is called, the process trees of services and
+// the connections they hold. It is the one place that runs and reads lsof,
+// netstat, tasklist, ss and friends, for the manager and for `pf cleanup`
+// alike.
+package proc
+
+import (
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Process is a process as far as the system tells.
+type Process struct {
+	PID  int
+	Name string // "" when unknown
+}
+
+func (p Process) String() string {
+	if p.Name == "" {
+		return fmt.Sprintf("pid %d", p.PID)
+	}
+	return fmt.Sprintf("%s (pid %d)", p.Name, p.PID)
+}
+
+// FreePort kills whatever listens on port and returns the PIDs it killed.
+// Only the listeners themselves go, not their process trees.
+func FreePort(port string) []int {
+	port = strings.TrimSpace(port)
+	if port == "" {
+		return nil
+	}
+	var pids []int
+	for _, p := range ListListeners(port) {
+		kill(p.PID)
+		pids = append(pids, p.PID)
+	}
+	return pids
+}
+
+// netstatRow is one connection from `netstat -ano` output.
+type netstatRow struct {
+	Proto, Local, Remote, State string
+	PID                         int
+}
+
+// parseNetstat reads the TCP rows of `netstat -ano` output into their columns;
+// headers and anything else that does not parse are skipped.
+func parseNetstat(output string) []netstatRow {
+	var rows []netstatRow
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 5 || !strings.EqualFold(fields[0], "TCP") {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[4])
+		if err != nil || pid <= 0 {
+			continue
+		}
+		rows = append(rows, netstatRow{Proto: fields[0], Local: fields[1], Remote: fields[2], State: fields[3], PID: pid})
+	}
+	return rows
+}
+
+// listening reports whether the row is a listening socket. Windows translates
+// the state column ("ABHÖREN", "EN ESCUCHA"), so a foreign port of 0, which
+// only a listener has, counts too.
+func (r netstatRow) listening() bool {
+	return strings.EqualFold(r.State, "LISTENING") || addrPort(r.Remote) == "0"
+}
+
+// addrPort is the port of a netstat address such as "127.0.0.1:5432" or
+// "[::1]:5432"; "" when it has none.
+func addrPort(addr string) string {
+	i := strings.LastIndex(addr, ":")
+	if i < 0 {
+		return ""
+	}
+	return addr[i+1:]
+}
+
+// parseNetstatListeners lists the processes that listen on port, going by
+// the local address alone: a connection to that port elsewhere, or a local
+// port that merely starts or ends with the same digits, is not one.
+func parseNetstatListeners(output, port string) []int {
+	seen := make(map[int]bool)
+	var pids []int
+	for _, r := range parseNetstat(output) {
+		if !r.listening() || addrPort(r.Local) != port {
+			continue
+		}
+		if !seen[r.PID] {
+			seen[r.PID] = true
+			pids = append(pids, r.PID)
+		}
+	}
+	return pids
+}
+
+// parseLsofOwners reads `lsof -F pc` output: a "p<pid>" line for each
+// process, followed by its "c<command>" line.
+func parseLsofOwners(output string) []Process {
+	var procs []Process
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "p"):
+			if pid, err := strconv.Atoi(line[1:]); err == nil && pid > 0 {
+				procs = append(procs, Process{PID: pid})
+			}
+		case strings.HasPrefix(line, "c") && len(procs) > 0:
+			procs[len(procs)-1].Name = line[1:]
+		}
+	}
+	return procs
+}
+
+// parseTasklistName reads the image name from `tasklist /FO CSV /NH` output
+// for one process; "" when there is none.
+func parseTasklistName(output string) string {
+	record, err := csv.NewReader(strings.NewReader(strings.TrimSpace(output))).Read()
+	if err != nil || len(record) < 2 {
+		return ""
+	}
+	return record[0]
+}
+
+// Socket is one established TCP connection, with the kernel's figures for
+// how it fares.
+type Socket struct {
+	Local, Remote string
+	PIDs          []int         // the processes that hold it
+	RTT           time.Duration // smoothed round-trip time
+	RTTVar        time.Duration // how much the round-trip time varies
+	Retrans       int           // segments retransmitted over its life
+}
+
+// ssPIDPattern finds the processes in the users:(...) column of ss -p.
+var ssPIDPattern = regexp.MustCompile(`pid=(\d+)`)
+
+// parseSS reads `ss -tinp` output: a row per connection, followed by an
+// indented line of its TCP_INFO figures (e.g. "rtt:15.2/3.1 retrans:0/2").
+// Connections that are not established are skipped.
+func parseSS(output string) []Socket {
+	var sockets []Socket
+	established := false
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			if !established {
+				continue
+			}
+			s := &sockets[len(sockets)-1]
+			for _, f := range strings.Fields(line) {
+				name, value, _ := strings.Cut(f, ":")
+				switch name {
+				case "rtt":
+					rtt, rttVar, _ := strings.Cut(value, "/")
+					s.RTT, s.RTTVar = millis(rtt), millis(rttVar)
+				case "retrans":
+					_, total, _ := strings.Cut(value, "/")
+					s.Retrans, _ = strconv.Atoi(total)
+				}
+			}
+			continue
+		}
+		fields := strings.Fields(line)
+		established = len(fields) >= 5 && fields[0] == "ESTAB"
+		if !established {
+			continue
+		}
+		s := Socket{Local: fields[3], Remote: fields[4]}
+		for _, m := range ssPIDPattern.FindAllStringSubmatch(line, -1) {
+			pid, _ := strconv.Atoi(m[1])
+			s.PIDs = append(s.PIDs, pid)
+		}
+		sockets = append(sockets, s)
+	}
+	return sockets
+}
+
+// millis reads a number of milliseconds such as "15.2"; 0 when it is not one.
+func millis(ms string) time.Duration {
+	f, err := strconv.ParseFloat(ms, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(f * float64(time.Millisecond))
+}
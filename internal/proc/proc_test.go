@@ -0,0 +1,96 @@
+package proc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseNetstatListeners(t *testing.T) {
+	output := `
+Active Connections
+
+  Proto  Local Address          Foreign Address        State           PID
+  TCP    0.0.0.0:5432           0.0.0.0:0              LISTENING       1234
+  TCP    127.0.0.1:5432         0.0.0.0:0              LISTENING       1234
+  TCP    [::1]:5432             [::]:0                 LISTENING       5678
+  TCP    127.0.0.1:15432        0.0.0.0:0              LISTENING       9999
+  TCP    127.0.0.1:6379         0.0.0.0:0              ESTABLISHED     4321
+`
+
+	got := parseNetstatListeners(output, "5432")
+	want := []int{1234, 5678}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNetstatListeners = %v, want %v", got, want)
+	}
+}
+
+func TestParseNetstatListenersNoMatch(t *testing.T) {
+	output := "  TCP    127.0.0.1:8080   0.0.0.0:0   LISTENING   100"
+	if got := parseNetstatListeners(output, "9090"); len(got) != 0 {
+		t.Errorf("expected no pids, got %v", got)
+	}
+}
+
+func TestParseNetstatListenersMatchesOnlyTheLocalPort(t *testing.T) {
+	output := `
+  TCP    127.0.0.1:543          0.0.0.0:0              LISTENING       111
+  TCP    127.0.0.1:5400         0.0.0.0:0              LISTENING       222
+  TCP    10.0.0.5:50123         10.0.0.9:54            ESTABLISHED     333
+  TCP    127.0.0.1:54           0.0.0.0:0              LISTENING       444
+  TCP    [fe80::1%4]:54         [::]:0                 LISTENING       555
+`
+	got := parseNetstatListeners(output, "54")
+	want := []int{444, 555}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNetstatListeners = %v, want %v", got, want)
+	}
+}
+
+func TestParseNetstatListenersLocalizedState(t *testing.T) {
+	output := `
+  TCP    0.0.0.0:5432           0.0.0.0:0              ABHÖREN         1234
+  TCP    127.0.0.1:5432         127.0.0.1:60000        HERGESTELLT     5678
+`
+	got := parseNetstatListeners(output, "5432")
+	if !reflect.DeepEqual(got, []int{1234}) {
+		t.Errorf("parseNetstatListeners = %v, want [1234]", got)
+	}
+}
+
+func TestParseLsofOwners(t *testing.T) {
+	got := parseLsofOwners("p812\ncpostgres\np9001\ncdocker-proxy\np77\n")
+	want := []Process{{812, "postgres"}, {9001, "docker-proxy"}, {77, ""}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseLsofOwners = %v, want %v", got, want)
+	}
+	if got[0].String() != "postgres (pid 812)" || got[2].String() != "pid 77" {
+		t.Errorf("String = %q, %q", got[0], got[2])
+	}
+}
+
+func TestParseTasklistName(t *testing.T) {
+	if got := parseTasklistName(`"postgres.exe","1234","Services","0","12,345 K"` + "\r\n"); got != "postgres.exe" {
+		t.Errorf("name = %q", got)
+	}
+	if got := parseTasklistName("INFO: No tasks are running which match the specified criteria.\r\n"); got != "" {
+		t.Errorf("name = %q, want none", got)
+	}
+}
+
+func TestParseSS(t *testing.T) {
+	output := "State  Recv-Q Send-Q Local Address:Port  Peer Address:Port Process\n" +
+		"ESTAB  0      0      192.168.1.10:40122   10.1.2.3:22  users:((\"ssh\",pid=4242,fd=3))\n" +
+		"\t cubic wscale:7,7 rto:216 rtt:15.2/3.1 ato:40 mss:1448 bbr:(bw:1bps,mrtt:0.002) retrans:0/2 rcv_rtt:9\n" +
+		"TIME-WAIT 0   0      192.168.1.10:40100   10.1.2.3:22\n" +
+		"\t cubic rtt:99/1\n" +
+		"ESTAB  0      0      127.0.0.1:5432       127.0.0.1:50000 users:((\"kubectl\",pid=7,fd=9),(\"kubectl\",pid=8,fd=9))\n" +
+		"\t cubic rtt:0.027/0.018\n"
+	want := []Socket{
+		{Local: "192.168.1.10:40122", Remote: "10.1.2.3:22", PIDs: []int{4242}, RTT: 15200 * time.Microsecond, RTTVar: 3100 * time.Microsecond, Retrans: 2},
+		{Local: "127.0.0.1:5432", Remote: "127.0.0.1:50000", PIDs: []int{7, 8}, RTT: 27 * time.Microsecond, RTTVar: 18 * time.Microsecond},
+	}
+	if got := parseSS(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSS =\n%+v\nwant\n%+v", got, want)
+	}
+}
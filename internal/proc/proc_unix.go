@@ -0,0 +1,59 @@
+//go:build !windows
+
+package proc
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ListListeners lists the processes listening on TCP port, by lsof.
+func ListListeners(port string) []Process {
+	out, err := exec.Command("lsof", "-nP", "-iTCP:"+port, "-sTCP:LISTEN", "-Fpc").Output()
+	if err != nil {
+		return nil
+	}
+	return parseLsofOwners(string(out))
+}
+
+// ProcessInfo describes the process pid, by ps; false when there is none.
+func ProcessInfo(pid int) (Process, bool) {
+	out, err := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "comm=").Output()
+	name := strings.TrimSpace(string(out))
+	if err != nil || name == "" {
+		return Process{}, false
+	}
+	return Process{PID: pid, Name: name}, true
+}
+
+// KillTree kills the process group pid leads, as the manager starts each
+// service in its own: with SIGTERM when graceful, so kubectl and ssh can
+// close their connections, else with SIGKILL.
+func KillTree(pid int, graceful bool) {
+	sig := syscall.SIGKILL
+	if graceful {
+		sig = syscall.SIGTERM
+	}
+	syscall.Kill(-pid, sig)
+}
+
+// KillTrees is KillTree for several trees. Each kill is a direct syscall, so
+// a loop costs no more than a batch would.
+func KillTrees(pids []int, graceful bool) {
+	for _, pid := range pids {
+		KillTree(pid, graceful)
+	}
+}
+
+// KillNamed kills every process called one of names, by pkill.
+func KillNamed(names ...string) {
+	for _, name := range names {
+		exec.Command("pkill", "-9", name).Run()
+	}
+}
+
+func kill(pid int) {
+	syscall.Kill(pid, syscall.SIGKILL)
+}
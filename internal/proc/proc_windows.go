@@ -0,0 +1,77 @@
+//go:build windows
+
+package proc
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// ListListeners lists the processes listening on TCP port, by netstat and
+// tasklist.
+func ListListeners(port string) []Process {
+	out, err := exec.Command("netstat", "-ano", "-p", "tcp").Output()
+	if err != nil {
+		return nil
+	}
+	var procs []Process
+	for _, pid := range parseNetstatListeners(string(out), port) {
+		p, ok := ProcessInfo(pid)
+		if !ok {
+			p = Process{PID: pid}
+		}
+		procs = append(procs, p)
+	}
+	return procs
+}
+
+// ProcessInfo describes the process pid, by tasklist; false when there is
+// none.
+func ProcessInfo(pid int) (Process, bool) {
+	out, err := exec.Command("tasklist", "/FI", "PID eq "+strconv.Itoa(pid), "/FO", "CSV", "/NH").Output()
+	if err != nil {
+		return Process{}, false
+	}
+	name := parseTasklistName(string(out))
+	if name == "" {
+		return Process{}, false
+	}
+	return Process{PID: pid, Name: name}, true
+}
+
+// KillTree kills pid and its children with taskkill. Windows has no signal a
+// console program can be asked to exit with, so a graceful kill only asks
+// windowed programs to close, and console ones such as kubectl and ssh
+// carry on.
+func KillTree(pid int, graceful bool) {
+	KillTrees([]int{pid}, graceful)
+}
+
+// KillTrees is KillTree for several trees in a single taskkill, so a bulk
+// shutdown costs one process spawn however many services run.
+func KillTrees(pids []int, graceful bool) {
+	if len(pids) == 0 {
+		return
+	}
+	args := make([]string, 0, 2+len(pids)*2)
+	args = append(args, "/T")
+	if !graceful {
+		args = append(args, "/F")
+	}
+	for _, pid := range pids {
+		args = append(args, "/PID", strconv.Itoa(pid))
+	}
+	exec.Command("taskkill", args...).Run()
+}
+
+// KillNamed kills every process called one of names, with its .exe, by
+// taskkill.
+func KillNamed(names ...string) {
+	for _, name := range names {
+		exec.Command("taskkill", "/F", "/IM", name+".exe").Run()
+	}
+}
+
+func kill(pid int) {
+	exec.Command("taskkill", "/F", "/PID", strconv.Itoa(pid)).Run()
+}
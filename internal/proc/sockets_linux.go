@@ -0,0 +1,43 @@
+package proc
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// GroupSockets lists the established TCP connections that processes of the
+// process group pgid hold (a service's tree; see KillTree), by ss.
+func GroupSockets(pgid int) ([]Socket, error) {
+	out, err := exec.Command("ss", "-tinp").Output()
+	if err != nil {
+		return nil, fmt.Errorf("ss: %w", err)
+	}
+	var held []Socket
+	for _, s := range parseSS(string(out)) {
+		if slices.ContainsFunc(s.PIDs, func(pid int) bool { return processGroup(pid) == pgid }) {
+			held = append(held, s)
+		}
+	}
+	return held, nil
+}
+
+// processGroup reads pid's process group from /proc; 0 when it cannot.
+func processGroup(pid int) int {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return 0
+	}
+	// The command name, in parentheses, may hold spaces; after it come the
+	// state, the parent and the group.
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	if len(fields) < 3 {
+		return 0
+	}
+	pgrp, _ := strconv.Atoi(fields[2])
+	return pgrp
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package proc
+
+import "errors"
+
+// GroupSockets lists the established TCP connections that processes of the
+// process group pgid hold. Only Linux tells pf how a connection fares (ss
+// and TCP_INFO), so elsewhere it returns errors.ErrUnsupported.
+func GroupSockets(pgid int) ([]Socket, error) {
+	return nil, errors.ErrUnsupported
+}
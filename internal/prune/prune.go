@@ -0,0 +1,98 @@
+// Package prune finds saved services that can no longer work on this machine:
+// their program is not installed, their kube context is gone from the
+// kubeconfig, or their ssh host does not resolve. Config that has grown over
+// years collects such entries; pf prune offers to delete or archive them.
+package prune
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+)
+
+// Probe asks the machine what a service needs. LookPath is exec.LookPath
+// outside tests; Contexts are the kube contexts kubectl knows, nil when it
+// cannot tell, which skips the check; ResolveHost fails for an ssh host that
+// does not resolve.
+type Probe struct {
+	LookPath    func(string) (string, error)
+	Contexts    []string
+	ResolveHost func(string) error
+}
+
+// Dead is a service that cannot work, and why.
+type Dead struct {
+	Name     string
+	Command  string
+	Problems []string
+}
+
+// Check returns the services (name to command) that cannot work, by name.
+// Monitors run nothing of their own and are left to pf lint.
+func Check(services map[string]string, p Probe) []Dead {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var dead []Dead
+	for _, name := range names {
+		command := services[name]
+		if cmdline.IsMonitor(command) {
+			continue
+		}
+		if problems := check(command, p); len(problems) > 0 {
+			dead = append(dead, Dead{Name: name, Command: command, Problems: problems})
+		}
+	}
+	return dead
+}
+
+func check(command string, p Probe) []string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return []string{"the command is empty"}
+	}
+	if _, err := p.LookPath(fields[0]); err != nil {
+		// Nothing else can be checked without the program.
+		return []string{fmt.Sprintf("'%s' is not installed or not on PATH", fields[0])}
+	}
+
+	var problems []string
+	switch strings.TrimSuffix(filepath.Base(fields[0]), ".exe") {
+	case "kubectl":
+		if context := kubeContext(fields); context != "" && p.Contexts != nil && !slices.Contains(p.Contexts, context) {
+			problems = append(problems, fmt.Sprintf("kube context '%s' is not in the kubeconfig", context))
+		}
+	case "ssh":
+		if host := cmdline.SSHHost(fields); host != "" {
+			if err := p.ResolveHost(host); err != nil {
+				problems = append(problems, fmt.Sprintf("ssh host '%s' does not resolve: %v", host, err))
+			}
+		}
+	}
+	return problems
+}
+
+// kubeContext returns the --context a kubectl command names, or "" when it
+// uses the current one.
+func kubeContext(fields []string) string {
+	for i, f := range fields {
+		name, value, inline := strings.Cut(f, "=")
+		if name != "--context" {
+			continue
+		}
+		if inline {
+			return value
+		}
+		if i+1 < len(fields) {
+			return fields[i+1]
+		}
+	}
+	return ""
+}
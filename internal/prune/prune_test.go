@@ -0,0 +1,52 @@
+package prune
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	installed := map[string]bool{"kubectl": true, "ssh": true}
+	probe := Probe{
+		LookPath: func(name string) (string, error) {
+			if installed[name] {
+				return "/usr/bin/" + name, nil
+			}
+			return "", errors.New("not found")
+		},
+		Contexts: []string{"prd", "stg"},
+		ResolveHost: func(host string) error {
+			if host == "old-bastion" {
+				return errors.New("no such host")
+			}
+			return nil
+		},
+	}
+	services := map[string]string{
+		"api":     "kubectl --context prd port-forward svc/api 8080:80",
+		"legacy":  "kubectl port-forward --context=qa svc/legacy 8081:80",
+		"current": "kubectl port-forward svc/x 8082:80",
+		"db":      "ssh -fN -L 5432:db:5432 -i ~/.ssh/id ops@old-bastion",
+		"cache":   "ssh -N -L 6379:cache:6379 -p 2222 bastion",
+		"tunnel":  "autossh -M 0 -L 9000:x:9000 bastion",
+		"health":  "monitor --via api --http /healthz",
+	}
+	var got []string
+	for _, d := range Check(services, probe) {
+		got = append(got, d.Name+": "+strings.Join(d.Problems, "; "))
+	}
+	want := []string{
+		"db: ssh host 'old-bastion' does not resolve: no such host",
+		"legacy: kube context 'qa' is not in the kubeconfig",
+		"tunnel: 'autossh' is not installed or not on PATH",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	probe.Contexts = nil
+	if dead := Check(map[string]string{"legacy": services["legacy"]}, probe); len(dead) != 0 {
+		t.Errorf("without known contexts the context check should be skipped, got %+v", dead)
+	}
+}
@@ -0,0 +1,127 @@
+// Package recording captures what passes through a forward, for reproducing
+// bugs that only show up through the tunnel. A relay sits in front of the
+// forward's local port and logs every chunk each way (size and timing, plus
+// the bytes when payload capture is on) as JSON lines; Replay serves a
+// recording back to clients as a local mock of the remote end.
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Event directions.
+const (
+	DirOpen     = "open"  // a client connected
+	DirToServer = "c2s"   // client → server
+	DirToClient = "s2c"   // server → client
+	DirClose    = "close" // the connection ended
+)
+
+// Header is the first line of a recording.
+type Header struct {
+	Version int       `json:"version"`
+	Service string    `json:"service"`
+	Target  string    `json:"target"`  // the address the relay forwarded to
+	Payload bool      `json:"payload"` // whether events carry their bytes
+	Started time.Time `json:"started"`
+}
+
+// Event is one chunk of traffic, or a connection opening or closing.
+type Event struct {
+	Conn int    `json:"conn"` // 1-based connection number
+	At   int64  `json:"at"`   // milliseconds since the recording started
+	Dir  string `json:"dir"`
+	Len  int    `json:"len,omitempty"`
+	Data []byte `json:"data,omitempty"` // base64 in the file; only with payload capture
+}
+
+// Recorder writes a recording. It is safe for concurrent use by the relay's
+// connections.
+type Recorder struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	started time.Time
+	payload bool
+	conns   int
+	bytes   int64
+}
+
+// NewRecorder writes h to w and returns a recorder for the events that follow.
+func NewRecorder(w io.Writer, h Header) (*Recorder, error) {
+	h.Version = 1
+	if h.Started.IsZero() {
+		h.Started = time.Now()
+	}
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(h); err != nil {
+		return nil, err
+	}
+	return &Recorder{enc: enc, started: h.Started, payload: h.Payload}, nil
+}
+
+// Stats reports how many connections and bytes have been recorded.
+func (r *Recorder) Stats() (conns int, bytes int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.conns, r.bytes
+}
+
+func (r *Recorder) open() int {
+	r.mu.Lock()
+	r.conns++
+	conn := r.conns
+	r.mu.Unlock()
+	r.record(conn, DirOpen, nil)
+	return conn
+}
+
+func (r *Recorder) record(conn int, dir string, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := Event{Conn: conn, At: time.Since(r.started).Milliseconds(), Dir: dir, Len: len(data)}
+	if r.payload {
+		e.Data = data
+	}
+	r.bytes += int64(len(data))
+	r.enc.Encode(e) // a full disk shows up as a short recording, not a broken relay
+}
+
+// Load reads a recording, returning its header and each connection's events
+// in connection order.
+func Load(r io.Reader) (Header, [][]Event, error) {
+	var h Header
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	if !scanner.Scan() {
+		return h, nil, fmt.Errorf("empty recording")
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &h); err != nil || h.Version != 1 {
+		return h, nil, fmt.Errorf("not a pf recording")
+	}
+
+	byConn := map[int][]Event{}
+	maxConn := 0
+	for line := 2; scanner.Scan(); line++ {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return h, nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		byConn[e.Conn] = append(byConn[e.Conn], e)
+		maxConn = max(maxConn, e.Conn)
+	}
+	if err := scanner.Err(); err != nil {
+		return h, nil, err
+	}
+	conns := make([][]Event, 0, len(byConn))
+	for i := 1; i <= maxConn; i++ {
+		if events, ok := byConn[i]; ok {
+			conns = append(conns, events)
+		}
+	}
+	return h, conns, nil
+}
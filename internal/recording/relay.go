@@ -0,0 +1,80 @@
+package recording
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Relay accepts connections on ln, forwards each to target and records the
+// traffic both ways, until ctx ends.
+func Relay(ctx context.Context, ln net.Listener, target string, rec *Recorder) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		client, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			relayConn(ctx, client, target, rec)
+		}()
+	}
+}
+
+func relayConn(ctx context.Context, client net.Conn, target string, rec *Recorder) {
+	defer client.Close()
+	conn := rec.open()
+	defer rec.record(conn, DirClose, nil)
+
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	server, err := dialer.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return
+	}
+	defer server.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		client.Close()
+		server.Close()
+	})
+	defer stop()
+
+	done := make(chan struct{}, 2)
+	go func() { pipe(server, client, conn, DirToServer, rec); done <- struct{}{} }()
+	go func() { pipe(client, server, conn, DirToClient, rec); done <- struct{}{} }()
+	// When either side is done the conversation is over.
+	<-done
+	client.Close()
+	server.Close()
+	<-done
+}
+
+// pipe copies src to dst, recording each chunk.
+func pipe(dst io.Writer, src io.Reader, conn int, dir string, rec *Recorder) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			rec.record(conn, dir, append([]byte(nil), buf[:n]...))
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package recording
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Replay serves a recording's connections to clients on ln until ctx ends,
+// acting as the remote end: the nth client gets the nth recorded connection
+// (starting over when they run out). For every chunk the client sent it
+// reads as many bytes, and every chunk the server sent is written back,
+// after the recorded delay when timing is set. The recording needs payloads.
+func Replay(ctx context.Context, ln net.Listener, conns [][]Event, timing bool) error {
+	if len(conns) == 0 {
+		return fmt.Errorf("the recording has no connections")
+	}
+	for _, events := range conns {
+		for _, e := range events {
+			if e.Dir == DirToClient && len(e.Data) != e.Len {
+				return fmt.Errorf("the recording has no payloads; record with payload capture on")
+			}
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for i := 0; ; i++ {
+		client, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		events := conns[i%len(conns)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer client.Close()
+			stop := context.AfterFunc(ctx, func() { client.Close() })
+			defer stop()
+			replayConn(client, events, timing)
+		}()
+	}
+}
+
+func replayConn(client net.Conn, events []Event, timing bool) {
+	var last int64
+	if len(events) > 0 {
+		last = events[0].At
+	}
+	for _, e := range events {
+		switch e.Dir {
+		case DirToServer:
+			if _, err := io.CopyN(io.Discard, client, int64(e.Len)); err != nil {
+				return
+			}
+		case DirToClient:
+			if timing && e.At > last {
+				time.Sleep(time.Duration(e.At-last) * time.Millisecond)
+			}
+			if _, err := client.Write(e.Data); err != nil {
+				return
+			}
+		case DirClose:
+			return
+		}
+		last = e.At
+	}
+}
@@ -0,0 +1,123 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// errInFlight is why a carried connection is given up: the upstream went
+// down between a client's request and its answer, which cannot be replayed.
+var errInFlight = errors.New("a request was in flight when the forward went down")
+
+// carriedConn is a client connection that outlives its upstream: when the
+// upstream drops while the client is waiting for nothing, a new upstream is
+// dialed and the client carries on over it.
+type carriedConn struct {
+	client net.Conn
+	opts   Options
+
+	// mu guards server. upstream holds it shared while writing, so a swap
+	// cannot slip between its check of the upstream and its write.
+	mu         sync.RWMutex
+	server     net.Conn
+	pending    atomic.Bool // client bytes went upstream and nothing has come back since
+	clientDone atomic.Bool
+}
+
+// carry relays client over server, and over fresh upstream connections as
+// the old ones drop, until either side is done for good.
+func carry(ctx context.Context, client, server net.Conn, opts Options) {
+	c := &carriedConn{client: client, opts: opts, server: server}
+	stop := context.AfterFunc(ctx, func() {
+		client.Close()
+		c.closeServer()
+	})
+	defer stop()
+
+	go c.upstream()
+	c.downstream(ctx)
+	client.Close()
+	c.closeServer()
+}
+
+func (c *carriedConn) closeServer() {
+	c.mu.RLock()
+	c.server.Close()
+	c.mu.RUnlock()
+}
+
+// upstream copies the client to whichever upstream is current.
+func (c *carriedConn) upstream() {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := c.client.Read(buf)
+		if n > 0 {
+			c.mu.RLock()
+			c.pending.Store(true)
+			if _, werr := c.server.Write(buf[:n]); werr != nil {
+				c.server.Close() // downstream sees it and gives up: pending is set
+			}
+			c.mu.RUnlock()
+		}
+		if err != nil {
+			c.clientDone.Store(true)
+			c.closeServer()
+			return
+		}
+	}
+}
+
+// downstream copies the current upstream to the client and replaces the
+// upstream when it drops at a quiet moment.
+func (c *carriedConn) downstream(ctx context.Context) {
+	buf := make([]byte, 32*1024)
+	for {
+		c.mu.RLock()
+		server := c.server
+		c.mu.RUnlock()
+
+		for {
+			n, err := server.Read(buf)
+			if n > 0 {
+				c.pending.Store(false)
+				if _, werr := c.client.Write(buf[:n]); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		if c.clientDone.Load() || ctx.Err() != nil {
+			return
+		}
+
+		// The upstream dropped under a live client. Hold the lock while
+		// redialing so no client bytes slip out in between.
+		c.mu.Lock()
+		if c.pending.Load() {
+			c.mu.Unlock()
+			c.report(errInFlight)
+			return
+		}
+		fresh, err := dialUpstream(ctx, c.opts)
+		if err != nil {
+			c.mu.Unlock()
+			c.report(err)
+			return
+		}
+		server.Close()
+		c.server = fresh
+		c.mu.Unlock()
+		c.report(nil)
+	}
+}
+
+func (c *carriedConn) report(err error) {
+	if c.opts.OnCarried != nil {
+		c.opts.OnCarried(err)
+	}
+}
@@ -0,0 +1,117 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chaos degrades relayed traffic on purpose, to test apps against a poor
+// tunnel. Every chunk read from or written to a client is affected.
+type Chaos struct {
+	Latency   time.Duration // added to every chunk in each direction
+	Jitter    time.Duration // random extra delay, up to this
+	Bandwidth int64         // bytes per second per connection and direction; 0 = unlimited
+	Drop      float64       // chance (0–1) that a chunk cuts its connection instead
+}
+
+// Active reports whether c changes anything.
+func (c Chaos) Active() bool {
+	return c != Chaos{}
+}
+
+// errDropped is what a chaos-cut connection reports to the relay.
+var errDropped = errors.New("connection dropped by chaos mode")
+
+// chaosListener wraps accepted connections in chaosConn.
+type chaosListener struct {
+	net.Listener
+	chaos func() Chaos
+}
+
+func (l chaosListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &chaosConn{Conn: c, chaos: l.chaos}, nil
+}
+
+// chaosConn applies the current chaos settings to a client connection. They
+// are read per chunk, so `pf chaos` changes apply to open connections too.
+type chaosConn struct {
+	net.Conn
+	chaos func() Chaos
+}
+
+func (c *chaosConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 && !c.disturb(n) {
+		return 0, errDropped
+	}
+	return n, err
+}
+
+func (c *chaosConn) Write(b []byte) (int, error) {
+	if len(b) > 0 && !c.disturb(len(b)) {
+		return 0, errDropped
+	}
+	return c.Conn.Write(b)
+}
+
+// disturb delays a chunk of n bytes, or cuts the connection and returns false.
+func (c *chaosConn) disturb(n int) bool {
+	ch := c.chaos()
+	if !ch.Active() {
+		return true
+	}
+	if ch.Drop > 0 && rand.Float64() < ch.Drop {
+		c.Conn.Close()
+		return false
+	}
+	delay := ch.Latency
+	if ch.Jitter > 0 {
+		delay += rand.N(ch.Jitter)
+	}
+	if ch.Bandwidth > 0 {
+		delay += time.Duration(int64(n) * int64(time.Second) / ch.Bandwidth)
+	}
+	time.Sleep(delay)
+	return true
+}
+
+// ParseBandwidth reads a rate such as "512kb", "1mb/s" or "2048" (bytes) into
+// bytes per second. Units are 1024-based.
+func ParseBandwidth(s string) (int64, error) {
+	v := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "/s")
+	unit := int64(1)
+	for _, u := range []struct {
+		suffix string
+		size   int64
+	}{{"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10}, {"b", 1}} {
+		if strings.HasSuffix(v, u.suffix) {
+			v, unit = strings.TrimSuffix(v, u.suffix), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q (e.g. 512kb or 1mb)", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// FormatBandwidth renders bytes per second the way ParseBandwidth reads them.
+func FormatBandwidth(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%dmb/s", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%dkb/s", n>>10)
+	}
+	return fmt.Sprintf("%db/s", n)
+}
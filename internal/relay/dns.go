@@ -0,0 +1,64 @@
+package relay
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+)
+
+// dnsTimeout bounds one UDP query's round trip through the forward.
+const dnsTimeout = 5 * time.Second
+
+// serveDNSUDP answers DNS queries arriving over UDP by asking the upstream
+// over TCP, since port forwards (kubectl's in particular) carry TCP only.
+// DNS over TCP is the same message behind a two-byte length.
+func serveDNSUDP(ctx context.Context, pc net.PacketConn, opts Options) {
+	go func() {
+		<-ctx.Done()
+		pc.Close()
+	}()
+	buf := make([]byte, 64*1024)
+	for {
+		n, client, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		query := append([]byte(nil), buf[:n]...)
+		go func() {
+			if answer, err := exchangeTCP(ctx, query, opts); err == nil {
+				pc.WriteTo(answer, client)
+			}
+		}()
+	}
+}
+
+// exchangeTCP sends one DNS message to the upstream over TCP and returns the
+// answer.
+func exchangeTCP(ctx context.Context, query []byte, opts Options) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsTimeout+opts.Hold)
+	defer cancel()
+	conn, err := dialUpstream(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(dnsTimeout))
+
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	var size [2]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return nil, err
+	}
+	answer := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
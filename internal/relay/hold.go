@@ -0,0 +1,33 @@
+package relay
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// holdRetryInterval is how often a held client's upstream dial is retried.
+// A var so tests can shrink it.
+var holdRetryInterval = 250 * time.Millisecond
+
+// dialUpstream connects to opts.Upstream. While opts.Hold lasts a failed dial
+// is retried, so a client arriving during a brief tunnel blip waits for the
+// forward to come back instead of being cut off. OnHeld hears how it went.
+func dialUpstream(ctx context.Context, opts Options) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	start := time.Now()
+	deadline := start.Add(opts.Hold)
+	for attempt := 1; ; attempt++ {
+		conn, err := dialer.DialContext(ctx, "tcp", opts.Upstream)
+		if err == nil || opts.Hold <= 0 || ctx.Err() != nil || !time.Now().Before(deadline) {
+			if attempt > 1 && opts.OnHeld != nil {
+				opts.OnHeld(time.Since(start), err)
+			}
+			return conn, err
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(min(holdRetryInterval, time.Until(deadline))):
+		}
+	}
+}
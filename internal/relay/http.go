@@ -0,0 +1,97 @@
+package relay
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+// newInspector is a reverse proxy to opts.Upstream that applies
+// opts.Rewrite and reports each request with its status and latency.
+func newInspector(opts Options) http.Handler {
+	target := &url.URL{Scheme: "http", Host: opts.Upstream}
+	rw := opts.Rewrite
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialUpstream(ctx, opts)
+	}
+	proxy := &httputil.ReverseProxy{
+		Transport: transport,
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.Out.Host = r.In.Host // the backend sees the host the client asked for
+			if rw.Host != "" {
+				r.Out.Host = rw.Host
+			}
+			r.SetXForwarded()
+			for name, value := range rw.Headers {
+				r.Out.Header.Set(name, value)
+			}
+			if rw.StripCookies {
+				r.Out.Header.Del("Cookie")
+			}
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			if rw.StripCookies {
+				resp.Header.Del("Set-Cookie")
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if rec, ok := w.(*statusRecorder); ok {
+				rec.err = err
+			}
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		proxy.ServeHTTP(rec, r)
+		if opts.OnRequest == nil {
+			return
+		}
+		status := rec.status
+		if rec.err != nil {
+			status = 0
+		}
+		opts.OnRequest(Request{
+			Method:   r.Method,
+			Path:     r.URL.RequestURI(),
+			Status:   status,
+			Duration: time.Since(start),
+			Err:      rec.err,
+		})
+	})
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	err    error
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the real writer (for flushing
+// streamed responses).
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
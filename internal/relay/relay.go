@@ -0,0 +1,157 @@
+// Package relay puts pf's own listener in front of a forward, for features
+// that need to see the traffic: a plain TCP relay, or an inspecting HTTP
+// proxy that reports every request.
+package relay
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configure one relay.
+type Options struct {
+	Listen   string // port or host:port clients connect to; a bare port binds loopback
+	Upstream string // the forward's local address, host:port
+	HTTP     bool   // proxy HTTP and report each request through OnRequest
+	DNS      bool   // also answer DNS over UDP on Listen, asking the upstream over TCP
+	Rewrite  Rewrite
+	// Chaos returns the chaos settings to apply; called per chunk, may be nil.
+	Chaos func() Chaos
+	// Hold is how long a client waits for an unreachable upstream (e.g. a
+	// forward that is reconnecting) before it is let go; 0 = not at all.
+	Hold time.Duration
+	// OnHeld hears about each client that had to wait for the upstream, with
+	// how long it waited and the dial error if it gave up; may be nil.
+	OnHeld func(waited time.Duration, err error)
+	// HoldConnections keeps a TCP client connected when the upstream drops
+	// while the client awaits no answer, and moves it to a fresh upstream
+	// (dialed within Hold). Only safe for protocols whose requests stand
+	// alone; see carry. HTTP relays dial per request and need no help.
+	HoldConnections bool
+	// OnCarried hears how each upstream drop under a held connection ended:
+	// nil when the client was carried over, else why it was let go; may be nil.
+	OnCarried func(err error)
+
+	// OnRequest hears about every proxied HTTP request; may be nil.
+	OnRequest func(Request)
+}
+
+// Rewrite edits the headers of proxied HTTP traffic.
+type Rewrite struct {
+	Host         string            // Host header sent upstream; "" keeps the client's
+	Headers      map[string]string // request headers to set, replacing the client's
+	StripCookies bool              // drop Cookie from requests and Set-Cookie from responses
+}
+
+// Request is one proxied HTTP request.
+type Request struct {
+	Method   string
+	Path     string
+	Status   int // 0 when the upstream could not be reached
+	Duration time.Duration
+	Err      error
+}
+
+// ListenAddress turns a bare port into a loopback address.
+func ListenAddress(listen string) string {
+	if !strings.Contains(listen, ":") {
+		return "127.0.0.1:" + listen
+	}
+	return listen
+}
+
+// Run listens on opts.Listen and relays to opts.Upstream until ctx ends. It
+// returns early only if it cannot listen.
+func Run(ctx context.Context, opts Options) error {
+	ln, err := net.Listen("tcp", ListenAddress(opts.Listen))
+	if err != nil {
+		return err
+	}
+	if opts.DNS {
+		pc, err := net.ListenPacket("udp", ListenAddress(opts.Listen))
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		go serveDNSUDP(ctx, pc, opts)
+	}
+	if opts.Chaos != nil {
+		ln = chaosListener{Listener: ln, chaos: opts.Chaos}
+	}
+	if opts.HTTP {
+		return serveHTTP(ctx, ln, opts)
+	}
+	return serveTCP(ctx, ln, opts)
+}
+
+func serveTCP(ctx context.Context, ln net.Listener, opts Options) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		client, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			relayConn(ctx, client, opts)
+		}()
+	}
+}
+
+func relayConn(ctx context.Context, client net.Conn, opts Options) {
+	defer client.Close()
+	server, err := dialUpstream(ctx, opts)
+	if err != nil {
+		return
+	}
+	if opts.HoldConnections {
+		carry(ctx, client, server, opts)
+		return
+	}
+	defer server.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		client.Close()
+		server.Close()
+	})
+	defer stop()
+
+	done := make(chan struct{}, 2)
+	go func() { io.Copy(server, client); done <- struct{}{} }()
+	go func() { io.Copy(client, server); done <- struct{}{} }()
+	// When either side is done the conversation is over.
+	<-done
+	client.Close()
+	server.Close()
+	<-done
+}
+
+func serveHTTP(ctx context.Context, ln net.Listener, opts Options) error {
+	srv := &http.Server{Handler: newInspector(opts), ReadHeaderTimeout: 30 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
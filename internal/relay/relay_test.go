@@ -0,0 +1,385 @@
+package relay
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// freePort returns a loopback port nothing listens on.
+func freePort(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return port
+}
+
+// start runs a relay for the test's lifetime and waits until it accepts.
+func start(t *testing.T, opts Options) string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go Run(ctx, opts)
+	addr := ListenAddress(opts.Listen)
+	for i := 0; i < 50; i++ {
+		if c, err := net.Dial("tcp", addr); err == nil {
+			c.Close()
+			return addr
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("relay never listened on %s", addr)
+	return ""
+}
+
+func TestTCPRelayCopiesBothWays(t *testing.T) {
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+	go func() {
+		for {
+			c, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+			go func() { io.Copy(c, c); c.Close() }()
+		}
+	}()
+
+	addr := start(t, Options{Listen: freePort(t), Upstream: upstream.Addr().String()})
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadFull(c, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("echo = %q, %v", buf, err)
+	}
+}
+
+func TestHTTPRelayReportsRequests(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, "hello "+r.Host)
+	}))
+	defer backend.Close()
+
+	requests := make(chan Request, 4)
+	addr := start(t, Options{
+		Listen:    freePort(t),
+		Upstream:  strings.TrimPrefix(backend.URL, "http://"),
+		HTTP:      true,
+		OnRequest: func(r Request) { requests <- r },
+	})
+
+	resp, err := http.Get("http://" + addr + "/api/users?page=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello "+addr {
+		t.Errorf("body = %q, want the client's host passed through", body)
+	}
+	if r := <-requests; r.Method != "GET" || r.Path != "/api/users?page=2" || r.Status != 200 {
+		t.Errorf("reported %+v", r)
+	}
+
+	resp, err = http.Get("http://" + addr + "/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if r := <-requests; r.Status != 404 {
+		t.Errorf("status = %d, want 404", r.Status)
+	}
+}
+
+func TestHTTPRelayReportsUnreachableUpstream(t *testing.T) {
+	requests := make(chan Request, 1)
+	addr := start(t, Options{
+		Listen:    freePort(t),
+		Upstream:  "127.0.0.1:" + freePort(t),
+		HTTP:      true,
+		OnRequest: func(r Request) { requests <- r },
+	})
+
+	resp, err := http.Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("client got %s, want 502", resp.Status)
+	}
+	if r := <-requests; r.Status != 0 || r.Err == nil {
+		t.Errorf("reported %+v, want a failure", r)
+	}
+}
+
+func TestHTTPRelayRewritesHeaders(t *testing.T) {
+	var host, auth, cookie string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, auth, cookie = r.Host, r.Header.Get("Authorization"), r.Header.Get("Cookie")
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+	}))
+	defer backend.Close()
+
+	addr := start(t, Options{
+		Listen:   freePort(t),
+		Upstream: strings.TrimPrefix(backend.URL, "http://"),
+		HTTP:     true,
+		Rewrite: Rewrite{
+			Host:         "api.internal",
+			Headers:      map[string]string{"Authorization": "Bearer s3cret"},
+			StripCookies: true,
+		},
+	})
+
+	req, _ := http.NewRequest("GET", "http://"+addr+"/", nil)
+	req.Header.Set("Authorization", "Basic client")
+	req.Header.Set("Cookie", "session=old")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if host != "api.internal" || auth != "Bearer s3cret" || cookie != "" {
+		t.Errorf("backend saw host=%q auth=%q cookie=%q", host, auth, cookie)
+	}
+	if sc := resp.Header.Get("Set-Cookie"); sc != "" {
+		t.Errorf("client got Set-Cookie %q, want it stripped", sc)
+	}
+}
+
+func TestChaosDelaysAndDrops(t *testing.T) {
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+	go func() {
+		for {
+			c, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+			go func() { io.Copy(c, c); c.Close() }()
+		}
+	}()
+
+	var chaos atomic.Pointer[Chaos]
+	chaos.Store(&Chaos{Latency: 50 * time.Millisecond})
+	addr := start(t, Options{
+		Listen:   freePort(t),
+		Upstream: upstream.Addr().String(),
+		Chaos:    func() Chaos { return *chaos.Load() },
+	})
+
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	began := time.Now()
+	c.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		t.Fatal(err)
+	}
+	if rtt := time.Since(began); rtt < 100*time.Millisecond {
+		t.Errorf("round trip took %s, want latency each way", rtt)
+	}
+
+	// Settings apply to open connections.
+	chaos.Store(&Chaos{Drop: 1})
+	c.Write([]byte("ping"))
+	if _, err := io.ReadFull(c, buf); err == nil {
+		t.Error("connection survived a 100% drop rate")
+	}
+}
+
+func TestParseBandwidth(t *testing.T) {
+	for in, want := range map[string]int64{"2048": 2048, "512kb": 512 << 10, "1MB/s": 1 << 20, "1.5kb": 1536} {
+		if got, err := ParseBandwidth(in); err != nil || got != want {
+			t.Errorf("ParseBandwidth(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "fast", "-1kb", "0"} {
+		if _, err := ParseBandwidth(in); err == nil {
+			t.Errorf("ParseBandwidth(%q) should fail", in)
+		}
+	}
+	if got := FormatBandwidth(1 << 20); got != "1mb/s" {
+		t.Errorf("FormatBandwidth = %q", got)
+	}
+}
+
+func TestHoldWaitsForUpstream(t *testing.T) {
+	upstreamAddr := "127.0.0.1:" + freePort(t)
+	held := make(chan error, 2) // start's probe connection is held too
+	addr := start(t, Options{
+		Listen:   freePort(t),
+		Upstream: upstreamAddr,
+		Hold:     2 * time.Second,
+		OnHeld:   func(_ time.Duration, err error) { held <- err },
+	})
+
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// The forward comes back while the client waits.
+	time.Sleep(100 * time.Millisecond)
+	upstream, err := net.Listen("tcp", upstreamAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+	go func() {
+		for {
+			s, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+			go func() { io.Copy(s, s); s.Close() }()
+		}
+	}()
+
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	c.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(c, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("held client got %q, %v", buf, err)
+	}
+	if err := <-held; err != nil {
+		t.Errorf("OnHeld reported %v, want success", err)
+	}
+}
+
+func TestHoldConnectionsCarriesIdleClients(t *testing.T) {
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+	go func() {
+		for {
+			s, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer s.Close()
+				buf := make([]byte, 4)
+				for {
+					if _, err := io.ReadFull(s, buf); err != nil {
+						return
+					}
+					if string(buf) == "lost" {
+						return // dies with the request unanswered
+					}
+					s.Write(buf)
+					if string(buf) == "ping" {
+						return // dies after answering: the client is idle
+					}
+				}
+			}()
+		}
+	}()
+
+	carried := make(chan error, 4)
+	addr := start(t, Options{
+		Listen:          freePort(t),
+		Upstream:        upstream.Addr().String(),
+		Hold:            time.Second,
+		HoldConnections: true,
+		OnCarried:       func(err error) { carried <- err },
+	})
+
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(3 * time.Second))
+	buf := make([]byte, 4)
+	for _, msg := range []string{"ping", "pong"} {
+		c.Write([]byte(msg))
+		if _, err := io.ReadFull(c, buf); err != nil || string(buf) != msg {
+			t.Fatalf("sent %q, got %q, %v", msg, buf, err)
+		}
+		if msg == "ping" {
+			if err := <-carried; err != nil {
+				t.Fatalf("client was not carried over: %v", err)
+			}
+		}
+	}
+
+	c.Write([]byte("lost"))
+	if _, err := io.ReadFull(c, buf); err == nil {
+		t.Error("a client with a request in flight should be let go")
+	}
+	if err := <-carried; err != errInFlight {
+		t.Errorf("got %v, want errInFlight", err)
+	}
+}
+
+func TestDNSRelayCarriesUDPQueriesOverTCP(t *testing.T) {
+	// A DNS-over-TCP upstream that answers "q" with "a:q".
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+	go func() {
+		for {
+			s, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+			var size [2]byte
+			io.ReadFull(s, size[:])
+			query := make([]byte, int(size[0])<<8|int(size[1]))
+			io.ReadFull(s, query)
+			answer := append([]byte("a:"), query...)
+			s.Write(append([]byte{0, byte(len(answer))}, answer...))
+			s.Close()
+		}
+	}()
+
+	addr := start(t, Options{Listen: freePort(t), Upstream: upstream.Addr().String(), DNS: true})
+	c, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(2 * time.Second))
+	c.Write([]byte("query"))
+	buf := make([]byte, 64)
+	n, err := c.Read(buf)
+	if err != nil || string(buf[:n]) != "a:query" {
+		t.Errorf("answer = %q, %v", buf[:n], err)
+	}
+}
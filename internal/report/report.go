@@ -0,0 +1,199 @@
+// Package report sums up a service catalog for `pf report`: how many services
+// there are by type, label and kube context, and what is probably left over
+// in it: services and groups no session has run for a while, services
+// forwarding to the same target, and services sharing a local port. A large
+// shared catalog collects all of these; the report is where a periodic
+// cleanup starts.
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/alinemone/go-port-forward/forwarder"
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/stats"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// DefaultIdle is how long a service or group may go without running before
+// the report lists it.
+const DefaultIdle = 90 * 24 * time.Hour
+
+// Service types, by the forwarder a command runs.
+const (
+	TypePortForward = "kubectl port-forward"
+	TypeProxy       = "kubectl proxy"
+	TypeSSH         = "ssh"
+	TypePlugin      = "plugin"
+	TypeMonitor     = "monitor"
+	TypeOther       = "other"
+)
+
+// CurrentContext counts the kubectl services that name no --context, and so
+// follow kubectl's current one.
+const CurrentContext = "(current)"
+
+// Report is the summary of a catalog. Lists are sorted by name.
+type Report struct {
+	Services  int            `json:"services"`
+	Groups    int            `json:"groups"`
+	ByType    map[string]int `json:"byType"`
+	ByLabel   map[string]int `json:"byLabel"`   // "key=value" → services
+	ByContext map[string]int `json:"byContext"` // kubectl services only
+	// Idle are the services no session has run within the idle period.
+	Idle []Unused `json:"idle"`
+	// UnusedGroups are the groups no session has run within it.
+	UnusedGroups []Unused `json:"unusedGroups"`
+	// SameTarget are services forwarding to the same place.
+	SameTarget []Shared `json:"sameTarget"`
+	// SamePort are services binding the same local port.
+	SamePort []Shared `json:"samePort"`
+}
+
+// Unused is a service or group and when it last ran; zero when no session
+// has run it since pf began keeping track.
+type Unused struct {
+	Name    string    `json:"name"`
+	LastRun time.Time `json:"lastRun,omitzero"`
+}
+
+// Shared is what two or more services have in common: a target such as
+// "svc/postgres:5432 in prod (context eu)" or a local port.
+type Shared struct {
+	What     string   `json:"what"`
+	Services []string `json:"services"`
+}
+
+// Build sums up data, with kept telling when services and groups last ran.
+// Services and groups not run since now-idle are listed as idle.
+func Build(data *storage.StorageData, kept stats.File, now time.Time, idle time.Duration) Report {
+	r := Report{
+		Services:  len(data.Services),
+		Groups:    len(data.Groups),
+		ByType:    map[string]int{},
+		ByLabel:   map[string]int{},
+		ByContext: map[string]int{},
+	}
+	cutoff := now.Add(-idle)
+	targets := map[string][]string{}
+	ports := map[string][]string{}
+	for _, name := range sortedKeys(data.Services) {
+		command := data.Services[name]
+		kind := Type(command)
+		r.ByType[kind]++
+		for key, value := range data.Labels[name] {
+			r.ByLabel[key+"="+value]++
+		}
+		if kind == TypePortForward || kind == TypeProxy {
+			context := cmdline.ParseForward(command).Context
+			if context == "" {
+				context = CurrentContext
+			}
+			r.ByContext[context]++
+		}
+
+		var lastRun time.Time
+		if s := kept.Services[name]; s != nil {
+			lastRun = s.LastRun
+		}
+		if lastRun.Before(cutoff) {
+			r.Idle = append(r.Idle, Unused{Name: name, LastRun: lastRun})
+		}
+		if kind == TypeMonitor {
+			continue
+		}
+		if target := targetOf(command); target != "" {
+			targets[target] = append(targets[target], name)
+		}
+		if local, _ := cmdline.ParsePorts(command); local != "" {
+			ports[local] = append(ports[local], name)
+		}
+	}
+	for _, name := range sortedKeys(data.Groups) {
+		if lastRun := kept.Groups[name]; lastRun.Before(cutoff) {
+			r.UnusedGroups = append(r.UnusedGroups, Unused{Name: name, LastRun: lastRun})
+		}
+	}
+	r.SameTarget = shared(targets)
+	r.SamePort = shared(ports)
+	sort.SliceStable(r.SamePort, func(i, j int) bool {
+		a, _ := strconv.Atoi(r.SamePort[i].What)
+		b, _ := strconv.Atoi(r.SamePort[j].What)
+		return a < b
+	})
+	return r
+}
+
+// Type is the type of service command runs; see the Type constants.
+func Type(command string) string {
+	switch fw := cmdline.ParseForward(command); {
+	case cmdline.IsMonitor(command):
+		return TypeMonitor
+	case forwarder.IsCommand(command):
+		return TypePlugin
+	case fw.APIProxy:
+		return TypeProxy
+	case fw.SSH:
+		return TypeSSH
+	case fw.Target != "":
+		return TypePortForward
+	}
+	return TypeOther
+}
+
+// targetOf describes where command forwards to, the same way for every
+// service forwarding there: "svc/postgres:5432 in prod (context eu)" or
+// "db.internal:5432 via bastion". "" when it cannot tell.
+func targetOf(command string) string {
+	fw := cmdline.ParseForward(command)
+	_, remote := cmdline.ParsePorts(command)
+	if fw.Target == "" || remote == "" {
+		return ""
+	}
+	target := fw.Target + ":" + remote
+	if fw.SSH {
+		return target + " via " + cmdline.SSHDestination(command)
+	}
+	if fw.Namespace != "" {
+		target += " in " + fw.Namespace
+	}
+	if fw.Context != "" {
+		target += " (context " + fw.Context + ")"
+	}
+	return target
+}
+
+// shared returns the entries of m with more than one service, by what they
+// share.
+func shared(m map[string][]string) []Shared {
+	var list []Shared
+	for _, what := range sortedKeys(m) {
+		if names := m[what]; len(names) > 1 {
+			list = append(list, Shared{What: what, Services: names})
+		}
+	}
+	return list
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counts returns m's entries as "key (n)", most first, then by key.
+func Counts(m map[string]int) []string {
+	keys := sortedKeys(m)
+	sort.SliceStable(keys, func(i, j int) bool { return m[keys[i]] > m[keys[j]] })
+	list := make([]string, len(keys))
+	for i, key := range keys {
+		list[i] = fmt.Sprintf("%s (%d)", key, m[key])
+	}
+	return list
+}
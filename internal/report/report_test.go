@@ -0,0 +1,78 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/stats"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+func TestBuild(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	data := &storage.StorageData{
+		Services: map[string]string{
+			"db":       "kubectl port-forward -n prod svc/postgres 5432:5432",
+			"db-old":   "kubectl port-forward svc/postgres -n prod 15432:5432",
+			"api":      "kubectl --context eu port-forward svc/api 8080:80",
+			"web":      "kubectl port-forward svc/web 8080:80",
+			"k8s":      "kubectl proxy --port 8001",
+			"bastion":  "ssh -N -L 6379:redis.internal:6379 jump",
+			"api-up":   "monitor --via api --http /healthz",
+			"sidecars": "plugin socat 9000:9000",
+			"notes":    "echo hello",
+		},
+		Groups: map[string][]string{"backend": {"db", "api"}, "legacy": {"db-old"}},
+		Labels: map[string]map[string]string{"db": {"team": "data"}, "db-old": {"team": "data"}, "api": {"team": "web"}},
+	}
+	kept := stats.File{
+		Services: map[string]*stats.Service{
+			"db":  {LastRun: now.AddDate(0, 0, -3)},
+			"api": {LastRun: now.AddDate(0, 0, -100)},
+			"web": {LastRun: now.AddDate(0, 0, -10)},
+		},
+		Groups: map[string]time.Time{"backend": now.AddDate(0, 0, -1)},
+	}
+	r := Build(data, kept, now, DefaultIdle)
+
+	if r.Services != 9 || r.Groups != 2 {
+		t.Errorf("counted %d services and %d groups", r.Services, r.Groups)
+	}
+	if r.ByType[TypePortForward] != 4 || r.ByType[TypeProxy] != 1 || r.ByType[TypeSSH] != 1 || r.ByType[TypeMonitor] != 1 || r.ByType[TypePlugin] != 1 || r.ByType[TypeOther] != 1 {
+		t.Errorf("by type = %v", r.ByType)
+	}
+	if !reflect.DeepEqual(r.ByLabel, map[string]int{"team=data": 2, "team=web": 1}) {
+		t.Errorf("by label = %v", r.ByLabel)
+	}
+	if !reflect.DeepEqual(r.ByContext, map[string]int{CurrentContext: 4, "eu": 1}) {
+		t.Errorf("by context = %v", r.ByContext)
+	}
+
+	var idle []string
+	for _, u := range r.Idle {
+		idle = append(idle, u.Name)
+	}
+	if want := []string{"api", "api-up", "bastion", "db-old", "k8s", "notes", "sidecars"}; !reflect.DeepEqual(idle, want) {
+		t.Errorf("idle = %v, want %v", idle, want)
+	}
+	if r.Idle[0].LastRun != now.AddDate(0, 0, -100) || !r.Idle[1].LastRun.IsZero() {
+		t.Errorf("idle = %+v, want api's last run and none for api-up", r.Idle)
+	}
+	if want := []Unused{{Name: "legacy"}}; !reflect.DeepEqual(r.UnusedGroups, want) {
+		t.Errorf("unused groups = %+v, want %+v", r.UnusedGroups, want)
+	}
+	if want := []Shared{{What: "svc/postgres:5432 in prod", Services: []string{"db", "db-old"}}}; !reflect.DeepEqual(r.SameTarget, want) {
+		t.Errorf("same target = %+v, want %+v", r.SameTarget, want)
+	}
+	if want := []Shared{{What: "8080", Services: []string{"api", "web"}}}; !reflect.DeepEqual(r.SamePort, want) {
+		t.Errorf("same port = %+v, want %+v", r.SamePort, want)
+	}
+}
+
+func TestCounts(t *testing.T) {
+	got := Counts(map[string]int{"b": 1, "a": 1, "c": 3})
+	if want := []string{"c (3)", "a (1)", "b (1)"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Counts = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,45 @@
+// Package schema holds the JSON Schemas of what pf hands to other programs:
+// `pf status --format json`, the events notifiers send, and the catalogs
+// teams publish. They are the contract for integrations: the structs behind
+// these documents may change, the documents only in ways the schemas allow,
+// and a change that breaks a reader comes with a new Version.
+package schema
+
+import (
+	"embed"
+	"sort"
+)
+
+// Version is the version of the schemas, the v1 in their $id. Adding an
+// optional field keeps it; removing or renaming one, or changing its type,
+// makes a new one.
+const Version = 1
+
+//go:embed v1/*.json
+var files embed.FS
+
+// Descriptions say what each schema describes, by name.
+var Descriptions = map[string]string{
+	"status":  "pf status --format json: running sessions and their forwards",
+	"event":   "what webhook notifiers POST for each event",
+	"catalog": "what a catalog URL serves (pf catalog)",
+}
+
+// Names lists the schemas, sorted.
+func Names() []string {
+	names := make([]string, 0, len(Descriptions))
+	for name := range Descriptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the schema called name. ok is false for an unknown name.
+func Get(name string) (data []byte, ok bool) {
+	if _, known := Descriptions[name]; !known {
+		return nil, false
+	}
+	data, err := files.ReadFile("v1/" + name + ".json")
+	return data, err == nil
+}
@@ -0,0 +1,112 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/catalog"
+	"github.com/alinemone/go-port-forward/internal/notify"
+	"github.com/alinemone/go-port-forward/internal/status"
+)
+
+// object is the part of a schema object the tests compare with a struct.
+type object struct {
+	ID         string                     `json:"$id"`
+	Required   []string                   `json:"required"`
+	Properties map[string]json.RawMessage `json:"properties"`
+	Defs       map[string]object          `json:"$defs"`
+}
+
+func load(t *testing.T, name string) object {
+	t.Helper()
+	data, ok := Get(name)
+	if !ok {
+		t.Fatalf("no schema %q", name)
+	}
+	var o object
+	if err := json.Unmarshal(data, &o); err != nil {
+		t.Fatalf("schema %q: %v", name, err)
+	}
+	return o
+}
+
+// fields returns the JSON names of a struct's fields, and those always
+// present (no omitempty or omitzero).
+func fields(typ reflect.Type) (all, required []string) {
+	for i := 0; i < typ.NumField(); i++ {
+		tag := typ.Field(i).Tag.Get("json")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" || name == "" {
+			continue
+		}
+		all = append(all, name)
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(all)
+	sort.Strings(required)
+	return all, required
+}
+
+// match fails unless o declares exactly v's fields, requiring the ones v
+// always writes.
+func match(t *testing.T, where string, o object, v any) {
+	t.Helper()
+	all, required := fields(reflect.TypeOf(v))
+	var props []string
+	for name := range o.Properties {
+		props = append(props, name)
+	}
+	sort.Strings(props)
+	if !slices.Equal(props, all) {
+		t.Errorf("%s: schema properties %v, struct fields %v", where, props, all)
+	}
+	got := slices.Sorted(slices.Values(o.Required))
+	if !slices.Equal(got, required) {
+		t.Errorf("%s: schema requires %v, struct always writes %v", where, got, required)
+	}
+}
+
+// TestSchemasMatchStructs keeps the schemas and the structs behind the
+// documents in step: a field added, renamed or made optional shows up here,
+// so the change is a deliberate one to the contract.
+func TestSchemasMatchStructs(t *testing.T) {
+	st := load(t, "status")
+	for def, v := range map[string]any{
+		"session":    status.Session{},
+		"service":    status.Service{},
+		"transition": status.Transition{},
+		"metrics":    status.Metrics{},
+		"group":      status.Group{},
+	} {
+		match(t, "status $defs/"+def, st.Defs[def], v)
+	}
+
+	match(t, "event", load(t, "event"), notify.Event{})
+
+	cat := load(t, "catalog")
+	match(t, "catalog", cat, catalog.Catalog{})
+	match(t, "catalog $defs/deprecation", cat.Defs["deprecation"], catalog.Deprecation{})
+	match(t, "catalog $defs/variant", cat.Defs["variant"], catalog.Variant{})
+}
+
+func TestSchemasAreVersioned(t *testing.T) {
+	if len(Names()) != len(Descriptions) {
+		t.Fatalf("Names() = %v", Names())
+	}
+	for _, name := range Names() {
+		want := fmt.Sprintf("https://github.com/alinemone/go-port-forward/schema/v%d/%s.json", Version, name)
+		if id := load(t, name).ID; id != want {
+			t.Errorf("%s: $id = %q, want %q", name, id, want)
+		}
+	}
+	if _, ok := Get("nope"); ok {
+		t.Error("Get should not find an unknown schema")
+	}
+}
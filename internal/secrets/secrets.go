@@ -0,0 +1,166 @@
+// Package secrets resolves references to secrets in service commands through
+// password managers' own command-line tools, so a command (a shared
+// catalog's, say) names a secret instead of carrying it:
+//
+//	{{op://vault/item/field}}   1Password: op read op://vault/item/field
+//	{{bw:password/prod-db}}     Bitwarden: bw get password prod-db
+//
+// The values reach the command as environment variables rather than in its
+// text, so they stay out of process listings and logs, and the shell does not
+// parse them: a reference needs no quoting, but inside single quotes sh does
+// not expand it. On Windows the command must run under delayed expansion
+// (cmd /V:ON), where !NAME! is read after cmd parsed & | < > and ^; the
+// %NAME% form is read before and would let a value run other commands.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// refPattern finds the references in a command.
+var refPattern = regexp.MustCompile(`\{\{\s*(op://[^\s{}]+|bw:[^\s{}]+)\s*\}\}`)
+
+// EnvPrefix starts the names of the variables that carry the values, which
+// are numbered: PF_SECRET_1, PF_SECRET_2, ...
+const EnvPrefix = "PF_SECRET_"
+
+// TTL is how long a value is kept before the tool is asked again, and Timeout
+// how long one lookup may take: op and bw may wait for the user to unlock
+// them. Vars so tests can shrink them.
+var (
+	TTL     = 10 * time.Minute
+	Timeout = 2 * time.Minute
+)
+
+// run runs a tool and returns what it printed. A var so tests can fake op
+// and bw.
+var run = func(ctx context.Context, name string, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		lines := strings.Split(strings.TrimSpace(string(exitErr.Stderr)), "\n")
+		if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+			return "", fmt.Errorf("%s: %s", name, last)
+		}
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", fmt.Errorf("%s is not installed", name)
+	}
+	return string(out), err
+}
+
+// Has reports whether command references a secret.
+func Has(command string) bool {
+	return refPattern.MatchString(command)
+}
+
+// Expand replaces each reference in command with the variable that carries
+// its value, and returns those variables as NAME=value for the command's
+// environment. A reference that cannot be resolved fails it, naming the
+// reference but not any value.
+func Expand(ctx context.Context, command string) (string, []string, error) {
+	var env []string
+	vars := map[string]string{} // reference → variable
+	var failed error
+	expanded := refPattern.ReplaceAllStringFunc(command, func(match string) string {
+		ref := refPattern.FindStringSubmatch(match)[1]
+		name, ok := vars[ref]
+		if !ok && failed == nil {
+			value, err := lookup(ctx, ref)
+			if err != nil {
+				failed = fmt.Errorf("secret %s: %v", ref, err)
+				return match
+			}
+			name = EnvPrefix + strconv.Itoa(len(vars)+1)
+			vars[ref] = name
+			env = append(env, name+"="+value)
+		}
+		return shellVar(name)
+	})
+	if failed != nil {
+		return "", nil, failed
+	}
+	return expanded, env, nil
+}
+
+// shellVar is how the service's shell reads the variable name; see the
+// package doc for Windows.
+func shellVar(name string) string {
+	if runtime.GOOS == "windows" {
+		return `"!` + name + `!"`
+	}
+	return `"$` + name + `"`
+}
+
+// cache keeps the values looked up in the last TTL. Each reference has its
+// own lock, so services starting together ask a tool once.
+var cache = struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+}{entries: map[string]*entry{}}
+
+type entry struct {
+	mu    sync.Mutex
+	value string
+	at    time.Time // zero until looked up
+}
+
+// lookup returns ref's value, from the cache when it is fresh enough.
+func lookup(ctx context.Context, ref string) (string, error) {
+	cache.mu.Lock()
+	e, ok := cache.entries[ref]
+	if !ok {
+		e = &entry{}
+		cache.entries[ref] = e
+	}
+	cache.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.at.IsZero() && time.Since(e.at) < TTL {
+		return e.value, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	value, err := resolve(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	e.value, e.at = value, time.Now()
+	return value, nil
+}
+
+// resolve asks ref's tool for its value.
+func resolve(ctx context.Context, ref string) (string, error) {
+	var out string
+	var err error
+	if strings.HasPrefix(ref, "op://") {
+		out, err = run(ctx, "op", "read", "--no-newline", ref)
+	} else {
+		object, item, ok := strings.Cut(strings.TrimPrefix(ref, "bw:"), "/")
+		if !ok || object == "" || item == "" {
+			return "", fmt.Errorf("want bw:<object>/<item>, e.g. bw:password/prod-db")
+		}
+		out, err = run(ctx, "bw", "get", object, item)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("no answer within %s", Timeout)
+	}
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimRight(out, "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("it is empty")
+	}
+	return value, nil
+}
@@ -0,0 +1,124 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTools stands in for op and bw, answering from values, and counts the
+// lookups.
+func fakeTools(t *testing.T, values map[string]string) *int {
+	t.Helper()
+	calls := 0
+	saved := run
+	t.Cleanup(func() { run = saved })
+	run = func(_ context.Context, name string, args ...string) (string, error) {
+		calls++
+		key := name + " " + strings.Join(args, " ")
+		value, ok := values[key]
+		if !ok {
+			return "", errors.New(name + ": item not found")
+		}
+		return value, nil
+	}
+	t.Cleanup(func() { cache.entries = map[string]*entry{} })
+	return &calls
+}
+
+func TestExpandPassesValuesInTheEnvironment(t *testing.T) {
+	calls := fakeTools(t, map[string]string{
+		"op read --no-newline op://dev/db/password": "p@ss 'word'",
+		"bw get username prod-db":                   "admin\n",
+	})
+	command := "connect --password={{op://dev/db/password}} --user {{ bw:username/prod-db }} --again {{op://dev/db/password}}"
+	got, env, err := Expand(context.Background(), command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `connect --password="$PF_SECRET_1" --user "$PF_SECRET_2" --again "$PF_SECRET_1"`
+	if runtime.GOOS == "windows" {
+		want = `connect --password="!PF_SECRET_1!" --user "!PF_SECRET_2!" --again "!PF_SECRET_1!"`
+	}
+	if got != want {
+		t.Errorf("Expand = %q, want %q", got, want)
+	}
+	if !slices.Equal(env, []string{"PF_SECRET_1=p@ss 'word'", "PF_SECRET_2=admin"}) {
+		t.Errorf("env = %q", env)
+	}
+
+	Expand(context.Background(), command)
+	if *calls != 2 {
+		t.Errorf("%d lookups, want 2: values are cached", *calls)
+	}
+	defer func(ttl time.Duration) { TTL = ttl }(TTL)
+	TTL = 0
+	Expand(context.Background(), command)
+	if *calls != 4 {
+		t.Errorf("%d lookups, want 4 once the values are stale", *calls)
+	}
+}
+
+func TestExpandedCommandSeesTheValue(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	fakeTools(t, map[string]string{"op read --no-newline op://dev/db/password": `a b 'c' "d" $e`})
+	command, env, err := Expand(context.Background(), "printf %s {{op://dev/db/password}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.Output()
+	if err != nil || string(out) != `a b 'c' "d" $e` {
+		t.Errorf("the command printed %q, %v", out, err)
+	}
+}
+
+// metacharacters is a value the shell would take as more commands,
+// redirections and substitutions, were it part of the command text.
+const metacharacters = `x & echo pwned | sort > out < in ^ ; $(id) ` + "`id`" + ` %PATH% !PATH!`
+
+func TestExpandedCommandKeepsMetacharacters(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh; see secrets_windows_test.go")
+	}
+	fakeTools(t, map[string]string{"op read --no-newline op://dev/db/password": metacharacters})
+	command, env, err := Expand(context.Background(), "printf %s {{op://dev/db/password}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.Output()
+	if err != nil || string(out) != metacharacters {
+		t.Errorf("the command printed %q, %v", out, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+		t.Errorf("the value redirected output to %s", entries[0].Name())
+	}
+}
+
+func TestExpandFailures(t *testing.T) {
+	fakeTools(t, nil)
+	for command, want := range map[string]string{
+		"connect {{op://dev/db/gone}}": "secret op://dev/db/gone: op: item not found",
+		"connect {{bw:prod-db}}":       "secret bw:prod-db: want bw:<object>/<item>",
+	} {
+		if _, _, err := Expand(context.Background(), command); err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("Expand(%q) = %v, want %q", command, err, want)
+		}
+	}
+	if Has("kubectl port-forward svc/db 5432:5432") || !Has("x {{op://a/b/c}}") {
+		t.Error("Has should spot references only")
+	}
+}
@@ -0,0 +1,33 @@
+//go:build windows
+
+package secrets
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+// TestExpandedCommandKeepsMetacharactersInCmd runs the command the way the
+// manager does on Windows, under cmd's delayed expansion.
+func TestExpandedCommandKeepsMetacharactersInCmd(t *testing.T) {
+	fakeTools(t, map[string]string{"op read --no-newline op://dev/db/password": metacharacters})
+	command, env, err := Expand(context.Background(), "echo {{op://dev/db/password}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	cmd := exec.Command("cmd")
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: "cmd /V:ON /C " + command}
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.Output()
+	if want := `"` + metacharacters + "\"\r\n"; err != nil || string(out) != want {
+		t.Errorf("the command printed %q, %v; want %q", out, err, want)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+		t.Errorf("the value redirected output to %s", entries[0].Name())
+	}
+}
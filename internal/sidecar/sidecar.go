@@ -0,0 +1,175 @@
+// Package sidecar publishes running forwards inside a Docker network, so
+// containers (e.g. of a docker compose project) reach pf-managed tunnels by
+// service name. Each forward gets a small socat container on the network,
+// aliased as the service, that relays to the forward on the host through
+// host.docker.internal.
+package sidecar
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Image is the container image sidecars run.
+const Image = "alpine/socat"
+
+// Sidecar is one forward as published in a network.
+type Sidecar struct {
+	Service  string // the forward's name, also the container's network alias
+	Port     string // the port containers connect to
+	HostPort string // the forward's local port on the host
+}
+
+// ContainerName is the name of a service's sidecar container: "pf-" and the
+// service name, with anything Docker does not allow in names replaced by "-".
+func ContainerName(service string) string {
+	var b strings.Builder
+	b.WriteString("pf-")
+	for _, r := range service {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.' || r == '-' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+// ContainerPort picks the port containers connect to for a forward: the
+// remote port at the end of its target (e.g. 5432 for "svc/postgres:5432"),
+// so they use the address they would inside the cluster, else its local port.
+func ContainerPort(target, localPort string) string {
+	if i := strings.LastIndex(target, ":"); i >= 0 {
+		if _, err := strconv.Atoi(target[i+1:]); err == nil {
+			return target[i+1:]
+		}
+	}
+	return localPort
+}
+
+// RunArgs are the `docker` arguments that start s's sidecar on network.
+func RunArgs(network string, s Sidecar) []string {
+	return []string{
+		"run", "-d", "--rm",
+		"--name", ContainerName(s.Service),
+		"--network", network,
+		"--network-alias", s.Service,
+		"--add-host", "host.docker.internal:host-gateway",
+		Image,
+		fmt.Sprintf("TCP-LISTEN:%s,fork,reuseaddr", s.Port),
+		"TCP:host.docker.internal:" + s.HostPort,
+	}
+}
+
+// Start starts s's sidecar on network, replacing one left over from an
+// earlier run.
+func Start(ctx context.Context, network string, s Sidecar) error {
+	Remove(ctx, s.Service) // none left over is the usual case
+	return docker(ctx, RunArgs(network, s)...)
+}
+
+// Remove removes the sidecars of services, if they are there.
+func Remove(ctx context.Context, services ...string) error {
+	if len(services) == 0 {
+		return nil
+	}
+	args := []string{"rm", "-f"}
+	for _, s := range services {
+		args = append(args, ContainerName(s))
+	}
+	return docker(ctx, args...)
+}
+
+// NeedsBridge reports whether sidecars reach the host through a gateway
+// address rather than loopback, so forwards bound to loopback need Bridge.
+// Docker Desktop (macOS, Windows) passes host.docker.internal through to the
+// host's loopback itself.
+func NeedsBridge() bool {
+	return runtime.GOOS == "linux"
+}
+
+// HostGateway asks Docker for the address host.docker.internal resolves to
+// in containers: the default bridge network's gateway.
+func HostGateway(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, "docker", "network", "inspect", "bridge",
+		"--format", "{{range .IPAM.Config}}{{.Gateway}} {{end}}").Output()
+	if err != nil {
+		return "", dockerError(err)
+	}
+	for _, gw := range strings.Fields(string(out)) {
+		if ip := net.ParseIP(gw); ip != nil && ip.To4() != nil {
+			return gw, nil
+		}
+	}
+	return "", fmt.Errorf("docker reports no IPv4 gateway for the bridge network")
+}
+
+// Bridge listens on listen and relays each connection to upstream until ctx
+// ends. It returns once listening, or the error that kept it from listening.
+func Bridge(ctx context.Context, listen, upstream string) error {
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	go func() {
+		for {
+			client, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go relay(ctx, client, upstream)
+		}
+	}()
+	return nil
+}
+
+func relay(ctx context.Context, client net.Conn, upstream string) {
+	defer client.Close()
+	var d net.Dialer
+	server, err := d.DialContext(ctx, "tcp", upstream)
+	if err != nil {
+		return
+	}
+	defer server.Close()
+	done := make(chan struct{}, 2)
+	go func() { io.Copy(server, client); done <- struct{}{} }()
+	go func() { io.Copy(client, server); done <- struct{}{} }()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+func docker(ctx context.Context, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("docker %s: %s", args[0], msg)
+		}
+		return dockerError(err)
+	}
+	return nil
+}
+
+// dockerError explains a docker invocation that failed without saying why.
+func dockerError(err error) error {
+	var notFound *exec.Error
+	if errors.As(err, &notFound) {
+		return fmt.Errorf("docker not found: %v", notFound.Err)
+	}
+	return fmt.Errorf("docker: %v", err)
+}
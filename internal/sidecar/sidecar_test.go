@@ -0,0 +1,68 @@
+package sidecar
+
+import (
+	"context"
+	"io"
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestRunArgs(t *testing.T) {
+	s := Sidecar{Service: "db@eu", Port: ContainerPort("svc/postgres:5432", "15432"), HostPort: "15432"}
+	want := []string{
+		"run", "-d", "--rm", "--name", "pf-db-eu", "--network", "shop_default", "--network-alias", "db@eu",
+		"--add-host", "host.docker.internal:host-gateway", Image,
+		"TCP-LISTEN:5432,fork,reuseaddr", "TCP:host.docker.internal:15432",
+	}
+	if got := RunArgs("shop_default", s); !slices.Equal(got, want) {
+		t.Errorf("RunArgs = %q\nwant %q", got, want)
+	}
+	if got := ContainerPort("deploy/api", "8080"); got != "8080" {
+		t.Errorf("a target without a port should keep the local one, got %s", got)
+	}
+}
+
+func TestBridge(t *testing.T) {
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+	go func() {
+		for {
+			conn, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+			go func() { defer conn.Close(); io.Copy(conn, conn) }()
+		}
+	}()
+
+	// Find a free port to bridge on.
+	probe, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listen := probe.Addr().String()
+	probe.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := Bridge(ctx, listen, upstream.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	if err := Bridge(ctx, listen, upstream.Addr().String()); err == nil {
+		t.Error("a second bridge on the same address should fail to listen")
+	}
+	conn, err := net.Dial("tcp", listen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Errorf("read %q, %v through the bridge", buf, err)
+	}
+}
@@ -0,0 +1,170 @@
+// Package stack applies a declarative stack file: the services and groups it
+// defines are merged into the config, and a running session is brought to run
+// exactly what the file lists, starting what is missing, stopping the rest and
+// restarting services whose definition changed under them.
+package stack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/alinemone/go-port-forward/internal/cmdline"
+	"github.com/alinemone/go-port-forward/internal/configedit"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+// Stack is the content of a stack file.
+type Stack struct {
+	Services map[string]string   `json:"services,omitempty"`
+	Groups   map[string][]string `json:"groups,omitempty"`
+	Run      []string            `json:"run,omitempty"` // services and groups that should be running
+}
+
+// Parse reads a stack file. Unknown keys are an error, so a typo does not
+// silently leave part of the stack out.
+func Parse(data []byte) (*Stack, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var s Stack
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("invalid stack file: %w", err)
+	}
+	return &s, nil
+}
+
+// Change is one service or group the stack adds to the config ("+") or
+// redefines ("~").
+type Change struct {
+	Action string
+	Kind   string // "service" or "group"
+	Name   string
+	Detail string // the new command, or the group's members
+}
+
+// Plan is what applying a stack does.
+type Plan struct {
+	Config  []Change
+	Desired []string // the services the stack runs
+	Start   []string
+	Stop    []string
+	Restart []string // running services whose local port changes with their new command
+	Merged  *storage.StorageData
+}
+
+// Empty reports whether applying the plan changes nothing.
+func (p *Plan) Empty() bool {
+	return len(p.Config) == 0 && len(p.Start) == 0 && len(p.Stop) == 0 && len(p.Restart) == 0
+}
+
+// Make works out what applying s to the config data changes, and what a
+// session running the services in running has to start and stop; running is
+// nil when no session runs. Services and groups the stack does not mention
+// stay in the config untouched. A running service whose command changes on
+// the same local port is left to the session, which switches it in place.
+func Make(data *storage.StorageData, s *Stack, running []string) (*Plan, error) {
+	merged, err := merge(data, s)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{Merged: merged}
+
+	for _, name := range sortedKeys(s.Services) {
+		command := s.Services[name]
+		old, exists := data.Services[name]
+		switch {
+		case !exists:
+			plan.Config = append(plan.Config, Change{"+", "service", name, command})
+		case old != command:
+			plan.Config = append(plan.Config, Change{"~", "service", name, command})
+			oldLocal, _ := cmdline.ParsePorts(old)
+			newLocal, _ := cmdline.ParsePorts(command)
+			if oldLocal != newLocal && slices.Contains(running, name) {
+				plan.Restart = append(plan.Restart, name)
+			}
+		}
+	}
+	for _, name := range sortedKeys(s.Groups) {
+		members := s.Groups[name]
+		old, exists := data.Groups[name]
+		switch {
+		case !exists:
+			plan.Config = append(plan.Config, Change{"+", "group", name, strings.Join(members, ", ")})
+		case !slices.Equal(old, members):
+			plan.Config = append(plan.Config, Change{"~", "group", name, strings.Join(members, ", ")})
+		}
+	}
+
+	for _, target := range s.Run {
+		var names []string
+		if _, ok := merged.Services[target]; ok {
+			names = []string{target}
+		} else if members, ok := merged.Groups[target]; ok {
+			names = members
+		} else {
+			return nil, fmt.Errorf("run: '%s' is not a service or group", target)
+		}
+		for _, name := range names {
+			if !slices.Contains(plan.Desired, name) {
+				plan.Desired = append(plan.Desired, name)
+			}
+		}
+	}
+	for _, name := range plan.Desired {
+		if !slices.Contains(running, name) {
+			plan.Start = append(plan.Start, name)
+		}
+	}
+	for _, name := range running {
+		if !slices.Contains(plan.Desired, name) {
+			plan.Stop = append(plan.Stop, name)
+		}
+	}
+	plan.Restart = slices.DeleteFunc(plan.Restart, func(name string) bool {
+		return slices.Contains(plan.Stop, name)
+	})
+	return plan, nil
+}
+
+// merge returns a copy of data with the stack's services and groups in it,
+// checked like a hand-edited config.
+func merge(data *storage.StorageData, s *Stack) (*storage.StorageData, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var merged storage.StorageData
+	if err := json.Unmarshal(raw, &merged); err != nil {
+		return nil, err
+	}
+	if merged.Services == nil {
+		merged.Services = map[string]string{}
+	}
+	if merged.Groups == nil {
+		merged.Groups = map[string][]string{}
+	}
+	for name, command := range s.Services {
+		merged.Services[name] = command
+	}
+	for name, members := range s.Groups {
+		merged.Groups[name] = members
+	}
+
+	raw, err = json.Marshal(&merged)
+	if err != nil {
+		return nil, err
+	}
+	return configedit.Validate(raw)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
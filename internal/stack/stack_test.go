@@ -0,0 +1,86 @@
+package stack
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+func TestMakePlansConfigAndSession(t *testing.T) {
+	data := &storage.StorageData{
+		Services: map[string]string{
+			"db":    "kubectl port-forward svc/db 5432:5432",
+			"cache": "kubectl port-forward svc/redis 6379:6379",
+			"web":   "kubectl port-forward svc/web 8080:80",
+			"old":   "kubectl port-forward svc/old 9000:80",
+		},
+		Groups: map[string][]string{"backend": {"db", "cache"}},
+	}
+	s, err := Parse([]byte(`{
+		"services": {
+			"db": "kubectl port-forward svc/db 5432:5432",
+			"cache": "kubectl port-forward svc/redis-v2 6379:6379",
+			"web": "kubectl port-forward svc/web 8081:80",
+			"search": "kubectl port-forward svc/search 9200:9200"
+		},
+		"groups": {"backend": ["db", "cache", "search"]},
+		"run": ["backend", "web"]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := Make(data, s, []string{"db", "cache", "web", "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config []string
+	for _, c := range plan.Config {
+		config = append(config, c.Action+" "+c.Kind+" "+c.Name)
+	}
+	for field, got := range map[string][]string{
+		"~ service cache,+ service search,~ service web,~ group backend": config,
+		"db,cache,search,web": plan.Desired,
+		"search":              plan.Start,
+		"old":                 plan.Stop,
+		"web":                 plan.Restart,
+	} {
+		if strings.Join(got, ",") != field {
+			t.Errorf("got %v, want %s", got, field)
+		}
+	}
+	if plan.Merged.Services["old"] == "" || len(plan.Merged.Groups["backend"]) != 3 {
+		t.Errorf("merged config should keep unmentioned services and take the stack's groups: %+v", plan.Merged)
+	}
+	if data.Services["search"] != "" {
+		t.Error("Make must not change the config it is given")
+	}
+
+	if again, err := Make(plan.Merged, s, plan.Desired); err != nil || !again.Empty() {
+		t.Errorf("applying the same stack twice should change nothing, got %+v, %v", again, err)
+	}
+	if plan, err := Make(data, s, nil); err != nil || fmt.Sprint(plan.Start) != fmt.Sprint(plan.Desired) || plan.Restart != nil {
+		t.Errorf("without a session everything starts: %+v, %v", plan, err)
+	}
+}
+
+func TestMakeRejectsBadStacks(t *testing.T) {
+	data := &storage.StorageData{Services: map[string]string{"db": "kubectl port-forward svc/db 5432:5432"}}
+	for input, want := range map[string]string{
+		`{"run": ["nope"]}`:                      "'nope' is not a service or group",
+		`{"groups": {"g": ["missing"]}}`:         "unknown service",
+		`{"services": {"a b": "kubectl proxy"}}`: `service "a b"`,
+		`{"service": {"x": "kubectl proxy"}}`:    "unknown field",
+		`{"groups": {"db": ["db"]}, "run": []}`:  "clashes",
+	} {
+		s, err := Parse([]byte(input))
+		if err == nil {
+			_, err = Make(data, s, nil)
+		}
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want an error with %q", input, err, want)
+		}
+	}
+}
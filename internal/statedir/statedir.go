@@ -0,0 +1,166 @@
+// Package statedir inspects the files and directories pf keeps its state in,
+// for `pf state`: where each is, how much it holds and whether pf can read
+// and write it, which is the first thing to check when pf behaves oddly on
+// one machine. WriteFile is how pf replaces those files.
+package statedir
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Entry is one place pf keeps something.
+type Entry struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Dir      bool   `json:"dir"`
+	Exists   bool   `json:"exists"`
+	Size     int64  `json:"size"`            // bytes; a directory's files, all the way down
+	Files    int    `json:"files,omitempty"` // a directory's files, all the way down
+	Readable bool   `json:"readable"`
+	Writable bool   `json:"writable"` // for a missing entry: whether pf can create it
+	Error    string `json:"error,omitempty"`
+}
+
+// Inspect looks at the file, or the directory with dir, at path. It changes
+// nothing: writing is tried by opening a file without truncating it, or by
+// creating and removing a temporary file in a directory.
+func Inspect(name, path string, dir bool) Entry {
+	e := Entry{Name: name, Path: path, Dir: dir}
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		e.Writable = creatable(filepath.Dir(path))
+		return e
+	}
+	if err != nil {
+		e.Error = err.Error()
+		return e
+	}
+	e.Exists = true
+	if info.IsDir() != dir {
+		kind := "a file"
+		if dir {
+			kind = "a directory"
+		}
+		e.Error = fmt.Sprintf("not %s", kind)
+		return e
+	}
+	if !dir {
+		e.Size = info.Size()
+		e.Readable = canOpen(path, os.O_RDONLY)
+		e.Writable = canOpen(path, os.O_WRONLY|os.O_APPEND)
+		return e
+	}
+	e.Size, e.Files, err = du(path)
+	if err != nil {
+		e.Error = err.Error()
+	}
+	_, err = os.ReadDir(path)
+	e.Readable = err == nil
+	e.Writable = canCreateIn(path)
+	return e
+}
+
+// creatable reports whether a missing entry could be created in dir, or in
+// the nearest parent of dir that exists.
+func creatable(dir string) bool {
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			return info.IsDir() && canCreateIn(dir)
+		}
+		parent := filepath.Dir(dir)
+		if !errors.Is(err, fs.ErrNotExist) || parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+func canOpen(path string, flag int) bool {
+	f, err := os.OpenFile(path, flag, 0)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
+func canCreateIn(dir string) bool {
+	f, err := os.CreateTemp(dir, ".pf-state-*")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
+
+// du adds up the sizes of the files under dir.
+func du(dir string) (size int64, files int, err error) {
+	err = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+			files++
+		}
+		return nil
+	})
+	return size, files, err
+}
+
+// Total adds up the sizes of entries.
+func Total(entries []Entry) int64 {
+	var n int64
+	for _, e := range entries {
+		n += e.Size
+	}
+	return n
+}
+
+// FormatSize writes n bytes the short way, e.g. "512 B" or "4.2 MiB".
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// OK reports whether pf can use the entry: read and write it, or create it
+// when it is missing.
+func (e Entry) OK() bool {
+	return e.Error == "" && e.Writable && (e.Readable || !e.Exists)
+}
+
+// Access describes what pf can do with the entry: "read/write", "read-only",
+// "not readable", "missing" (which pf creates when it needs it) or "missing,
+// cannot be created".
+func (e Entry) Access() string {
+	switch {
+	case !e.Exists && e.Writable:
+		return "missing"
+	case !e.Exists:
+		return "missing, cannot be created"
+	case e.Readable && e.Writable:
+		return "read/write"
+	case e.Readable:
+		return "read-only"
+	default:
+		return "not readable"
+	}
+}
@@ -0,0 +1,84 @@
+package statedir
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "services.json")
+	os.WriteFile(config, []byte(`{"services": {}}`), 0o600)
+	catalogs := filepath.Join(dir, "catalogs")
+	os.MkdirAll(filepath.Join(catalogs, "nested"), 0o700)
+	os.WriteFile(filepath.Join(catalogs, "corp.json"), make([]byte, 100), 0o600)
+	os.WriteFile(filepath.Join(catalogs, "nested", "x.json"), make([]byte, 50), 0o600)
+
+	e := Inspect("config", config, false)
+	if !e.Exists || e.Size != 16 || e.Access() != "read/write" || !e.OK() {
+		t.Errorf("config: %+v", e)
+	}
+	e = Inspect("catalogs", catalogs, true)
+	if !e.Exists || e.Size != 150 || e.Files != 2 || e.Access() != "read/write" {
+		t.Errorf("catalogs: %+v", e)
+	}
+	if entries, _ := os.ReadDir(catalogs); len(entries) != 2 {
+		t.Errorf("Inspect left files behind in catalogs: %v", entries)
+	}
+	e = Inspect("stats", filepath.Join(dir, "state", "stats.json"), false)
+	if e.Exists || e.Access() != "missing" {
+		t.Errorf("stats: %+v", e)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "state")); err == nil {
+		t.Error("Inspect created a missing entry's directory")
+	}
+	if e = Inspect("certs", config, true); e.Error != "not a directory" {
+		t.Errorf("a file for a directory: %+v", e)
+	}
+
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		return // permissions do not hold there
+	}
+	os.Chmod(config, 0o400)
+	if e = Inspect("config", config, false); e.Access() != "read-only" || e.OK() {
+		t.Errorf("read-only config: %s", e.Access())
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{
+		0:         "0 B",
+		1023:      "1023 B",
+		1536:      "1.5 KiB",
+		5 << 20:   "5.0 MiB",
+		3<<30 + 1: "3.0 GiB",
+	} {
+		if got := FormatSize(n); got != want {
+			t.Errorf("FormatSize(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "stats.json")
+	os.WriteFile(path, []byte("old"), 0o644)
+
+	if err := WriteFile(path, []byte("new"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Errorf("content = %q, want new", data)
+	}
+	if info, _ := os.Stat(path); runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	if err := WriteFile(filepath.Join(dir, "missing", "x.json"), []byte("x"), 0o600); err == nil {
+		t.Error("writing into a missing directory should fail")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("WriteFile left files behind: %v", entries)
+	}
+}
@@ -0,0 +1,31 @@
+package statedir
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile replaces path with data in one step, so a reader never sees half
+// of it: data goes to a temporary file next to path, which is given perm and
+// renamed over path. It leaves path as it was when any step fails.
+func WriteFile(path string, data []byte, perm fs.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".pf-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/status"
+)
+
+// Row is one service's reliability figures in an export, for teams that
+// track tunnels over time in a spreadsheet or a notebook.
+type Row struct {
+	Time              time.Time `json:"time"`    // when the figures were taken
+	Session           string    `json:"session"` // what the session ran, e.g. a group
+	Service           string    `json:"service"`
+	Status            string    `json:"status"`
+	UptimeSeconds     float64   `json:"uptimeSeconds"` // time healthy this session
+	Reconnects        int       `json:"reconnects"`
+	Errors            int       `json:"errors"`
+	AvgConnectSeconds float64   `json:"avgConnectSeconds"` // from process start to healthy
+}
+
+// csvHeader names the columns of a CSV export, in Row's order.
+var csvHeader = []string{"time", "session", "service", "status", "uptime_seconds", "reconnects", "errors", "avg_connect_seconds"}
+
+// Rows takes the figures of the sessions' services at now, by session and
+// then service.
+func Rows(sessions []status.Session, now time.Time) []Row {
+	var rows []Row
+	for _, s := range sessions {
+		for _, svc := range s.Services {
+			m := svc.Metrics.Model()
+			reconnects := 0
+			for _, n := range svc.Reconnects {
+				reconnects += n
+			}
+			rows = append(rows, Row{
+				Time:              now.UTC().Truncate(time.Second),
+				Session:           s.Label,
+				Service:           svc.Name,
+				Status:            svc.Status,
+				UptimeSeconds:     m.Uptime(now).Round(time.Millisecond).Seconds(),
+				Reconnects:        reconnects,
+				Errors:            m.Errors,
+				AvgConnectSeconds: m.AvgConnect().Round(time.Millisecond).Seconds(),
+			})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Session != rows[j].Session {
+			return rows[i].Session < rows[j].Session
+		}
+		return rows[i].Service < rows[j].Service
+	})
+	return rows
+}
+
+// WriteCSV writes rows as CSV, after the header line when header is set.
+func WriteCSV(w io.Writer, rows []Row, header bool) error {
+	cw := csv.NewWriter(w)
+	if header {
+		cw.Write(csvHeader)
+	}
+	for _, r := range rows {
+		cw.Write([]string{
+			r.Time.Format(time.RFC3339), r.Session, r.Service, r.Status,
+			strconv.FormatFloat(r.UptimeSeconds, 'f', -1, 64), strconv.Itoa(r.Reconnects), strconv.Itoa(r.Errors),
+			strconv.FormatFloat(r.AvgConnectSeconds, 'f', -1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes rows as JSON Lines: one object per line, so exports can
+// be appended to one another.
+func WriteJSON(w io.Writer, rows []Row) error {
+	enc := json.NewEncoder(w)
+	for _, r := range rows {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write writes rows in format, "csv" or "json".
+func Write(w io.Writer, rows []Row, format string, header bool) error {
+	switch format {
+	case "csv":
+		return WriteCSV(w, rows, header)
+	case "json":
+		return WriteJSON(w, rows)
+	}
+	return fmt.Errorf("unknown format %q (csv or json)", format)
+}
+
+// FormatOf picks the export format for path from its extension: "json" for
+// .json and .jsonl, else "csv".
+func FormatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".jsonl":
+		return "json"
+	}
+	return "csv"
+}
+
+// Append adds rows to the export file at path, in the format its extension
+// names, creating it (and, for CSV, its header line) when it is new. A
+// session appends its figures on exit, so the file grows into a history.
+func Append(path string, rows []Row) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err == nil {
+		err = Write(f, rows, FormatOf(path), info.Size() == 0)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
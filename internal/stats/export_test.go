@@ -0,0 +1,52 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/status"
+)
+
+func TestExport(t *testing.T) {
+	now := time.Date(2026, 10, 16, 14, 0, 0, 0, time.UTC)
+	sessions := []status.Session{{Label: "backend", Services: []status.Service{
+		{Name: "db", Status: model.StatusHealthy, Reconnects: map[string]int{"exit 1": 2, "network error": 1},
+			Metrics: status.Metrics{Healthy: "1h", UpSince: now.Add(-30 * time.Minute), Errors: 3, Connects: 4, ConnectTime: "6s"}},
+		{Name: "api", Status: model.StatusError, Metrics: status.Metrics{Healthy: "10s", Errors: 1}},
+	}}}
+	rows := Rows(sessions, now)
+	if len(rows) != 2 || rows[0].Service != "api" {
+		t.Fatalf("rows = %+v", rows)
+	}
+	db := rows[1]
+	if db.UptimeSeconds != 5400 || db.Reconnects != 3 || db.Errors != 3 || db.AvgConnectSeconds != 1.5 || db.Session != "backend" {
+		t.Errorf("db = %+v", db)
+	}
+
+	path := filepath.Join(t.TempDir(), "metrics.csv")
+	for range 2 {
+		if err := Append(path, rows); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 5 || lines[0] != strings.Join(csvHeader, ",") || lines[2] != "2026-10-16T14:00:00Z,backend,db,healthy,5400,3,3,1.5" {
+		t.Errorf("csv = %q", lines)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, rows[1:], FormatOf("m.jsonl"), false); err != nil {
+		t.Fatal(err)
+	}
+	var back Row
+	if err := json.Unmarshal(buf.Bytes(), &back); err != nil || back != db {
+		t.Errorf("json = %s (%v)", buf.String(), err)
+	}
+}
@@ -0,0 +1,306 @@
+// Package stats keeps per-service runtime statistics across sessions in
+// ~/.pf/state/stats.json: reconnects by cause for each day, the latest
+// errors, and when each service and group last ran. A session's own counters
+// start at zero, so these are what tell a fresh session from a tunnel that
+// has failed 47 times today, through restarts, upgrades and crashes of pf.
+package stats
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/events"
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/statedir"
+)
+
+const (
+	// keepDays is how many days of reconnect counts a service keeps.
+	keepDays = 30
+	// keepErrors is how many of its latest errors a service keeps.
+	keepErrors = 20
+	// dateLayout keys the days, in local time.
+	dateLayout = "2006-01-02"
+	// flushInterval is how often a session looks for changes its events
+	// did not announce.
+	flushInterval = 5 * time.Second
+)
+
+// Day is one day's reconnects of a service, by cause (see model.Service).
+type Day struct {
+	Date       string         `json:"date"` // YYYY-MM-DD, local time
+	Reconnects map[string]int `json:"reconnects"`
+}
+
+// Total is the day's reconnects of any cause.
+func (d Day) Total() int {
+	n := 0
+	for _, c := range d.Reconnects {
+		n += c
+	}
+	return n
+}
+
+// Error is an error a service reported, the first time it did so in a row.
+type Error struct {
+	At    time.Time `json:"at"`
+	Error string    `json:"error"`
+}
+
+// Service is what the file keeps of one service, oldest first.
+type Service struct {
+	Days   []Day   `json:"days,omitempty"`
+	Errors []Error `json:"errors,omitempty"`
+	// LastRun is the last day a session ran the service, at the time it
+	// first did so that day; zero when none has since pf began keeping it.
+	LastRun time.Time `json:"lastRun,omitempty"`
+}
+
+// Today returns the service's reconnects on now's date.
+func (s *Service) Today(now time.Time) Day {
+	date := now.Format(dateLayout)
+	if s != nil {
+		for _, d := range s.Days {
+			if d.Date == date {
+				return d
+			}
+		}
+	}
+	return Day{Date: date}
+}
+
+// File is the content of the stats file.
+type File struct {
+	Services map[string]*Service `json:"services"`
+	// Groups maps a group to the last time a session ran it; see
+	// Keeper.RecordGroups.
+	Groups map[string]time.Time `json:"groups,omitempty"`
+}
+
+// Path is where the stats file lives: ~/.pf/state/stats.json.
+func Path() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".pf", "state", "stats.json"), nil
+}
+
+// Load reads the stats file; a missing one holds nothing yet.
+func Load(path string) (File, error) {
+	f := File{Services: map[string]*Service{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return f, err
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return File{Services: map[string]*Service{}}, err
+	}
+	if f.Services == nil {
+		f.Services = map[string]*Service{}
+	}
+	return f, nil
+}
+
+func save(path string, f File) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return statedir.WriteFile(path, data, 0600)
+}
+
+// Keeper adds a session's reconnects and errors to the stats file as they
+// happen. Other sessions may write the file too: each change is merged into
+// what the file holds at the time, so their counts add up.
+type Keeper struct {
+	path string
+
+	mu   sync.Mutex
+	file File // as last read or written
+	// seen are the session counters already added, by service and cause.
+	seen map[string]map[string]int
+	// erring is the error last recorded for each service still in error.
+	erring map[string]string
+	// ran is the date each service's LastRun was last set by this session.
+	ran map[string]string
+}
+
+// NewKeeper returns a keeper of the stats file at path, with what it holds
+// so far.
+func NewKeeper(path string) (*Keeper, error) {
+	f, err := Load(path)
+	k := &Keeper{path: path, file: f, seen: map[string]map[string]int{}, erring: map[string]string{}, ran: map[string]string{}}
+	return k, err
+}
+
+// Follow records the session's states on every event on changes, and every
+// few seconds, until stop; stop records them one last time. Write failures
+// are dropped: the stats are a convenience and must not disturb the session.
+func (k *Keeper) Follow(states func() []model.Service, changes <-chan events.Event) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case _, ok := <-changes:
+				if !ok {
+					changes = nil
+					continue
+				}
+			case <-ticker.C:
+			}
+			k.Record(states(), time.Now())
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+			k.Record(states(), time.Now())
+		})
+	}
+}
+
+// Record adds what changed in the services since the last call: reconnects
+// counted since, under now's date, and an error each service newly reports.
+// A service seen for the first time in the session, or on a new day, has
+// its LastRun set to now, so one that runs for weeks does not look unused.
+func (k *Keeper) Record(services []model.Service, now time.Time) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	date := now.Format(dateLayout)
+	reconnects := map[string]map[string]int{}
+	errors := map[string]string{}
+	var ran []string
+	for _, svc := range services {
+		if k.ran[svc.Name] != date {
+			k.ran[svc.Name] = date
+			ran = append(ran, svc.Name)
+		}
+		seen := k.seen[svc.Name]
+		for cause, n := range seen {
+			if svc.Reconnects[cause] < n {
+				seen = nil // the service was stopped and started again
+				break
+			}
+		}
+		if seen == nil {
+			seen = map[string]int{}
+			k.seen[svc.Name] = seen
+		}
+		for cause, n := range svc.Reconnects {
+			if added := n - seen[cause]; added > 0 {
+				if reconnects[svc.Name] == nil {
+					reconnects[svc.Name] = map[string]int{}
+				}
+				reconnects[svc.Name][cause] = added
+			}
+			seen[cause] = n
+		}
+		switch {
+		case svc.Status != model.StatusError || svc.LastError == "":
+			delete(k.erring, svc.Name)
+		case k.erring[svc.Name] != svc.LastError:
+			k.erring[svc.Name] = svc.LastError
+			errors[svc.Name] = svc.LastError
+		}
+	}
+	if len(reconnects) == 0 && len(errors) == 0 && len(ran) == 0 {
+		return nil
+	}
+
+	f, err := Load(k.path)
+	if err != nil {
+		f = k.file // unreadable: start over from what this session knows
+	}
+	for _, name := range ran {
+		serviceIn(f, name).LastRun = now
+	}
+	for name, counts := range reconnects {
+		s := serviceIn(f, name)
+		i := slices.IndexFunc(s.Days, func(d Day) bool { return d.Date == date })
+		if i < 0 {
+			s.Days = append(s.Days, Day{Date: date, Reconnects: map[string]int{}})
+			i = len(s.Days) - 1
+		}
+		if s.Days[i].Reconnects == nil {
+			s.Days[i].Reconnects = map[string]int{}
+		}
+		for cause, n := range counts {
+			s.Days[i].Reconnects[cause] += n
+		}
+		if len(s.Days) > keepDays {
+			s.Days = s.Days[len(s.Days)-keepDays:]
+		}
+	}
+	for name, msg := range errors {
+		s := serviceIn(f, name)
+		s.Errors = append(s.Errors, Error{At: now, Error: msg})
+		if len(s.Errors) > keepErrors {
+			s.Errors = s.Errors[len(s.Errors)-keepErrors:]
+		}
+	}
+	k.file = f
+	return save(k.path, f)
+}
+
+// Service returns what the file held of name when this session last read or
+// wrote it; nil when nothing.
+func (k *Keeper) Service(name string) *Service {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	s := k.file.Services[name]
+	if s == nil {
+		return nil
+	}
+	return &Service{Days: slices.Clone(s.Days), Errors: slices.Clone(s.Errors), LastRun: s.LastRun}
+}
+
+// RecordGroups notes that the session runs the groups names, at now.
+func (k *Keeper) RecordGroups(names []string, now time.Time) error {
+	if len(names) == 0 {
+		return nil
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	f, err := Load(k.path)
+	if err != nil {
+		f = k.file
+	}
+	if f.Groups == nil {
+		f.Groups = map[string]time.Time{}
+	}
+	for _, name := range names {
+		f.Groups[name] = now
+	}
+	k.file = f
+	return save(k.path, f)
+}
+
+func serviceIn(f File, name string) *Service {
+	s := f.Services[name]
+	if s == nil {
+		s = &Service{}
+		f.Services[name] = s
+	}
+	return s
+}
@@ -0,0 +1,102 @@
+package stats
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestKeeperAddsSessionCounters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "stats.json")
+	day := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+
+	// A first session: db reconnects twice, then errors.
+	first, err := NewKeeper(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := model.Service{Name: "db", Status: model.StatusHealthy, Reconnects: map[string]int{model.CauseNetworkError: 2}}
+	if err := first.Record([]model.Service{db}, day); err != nil {
+		t.Fatal(err)
+	}
+	db.Status, db.LastError = model.StatusError, "connection refused"
+	first.Record([]model.Service{db}, day.Add(time.Minute))
+	first.Record([]model.Service{db}, day.Add(2*time.Minute)) // the same error goes on
+
+	// A second session, after pf restarted: its counters start over.
+	second, err := NewKeeper(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := second.Service("db").Today(day).Total(); got != 2 {
+		t.Errorf("a new session sees %d reconnects today, want 2", got)
+	}
+	db = model.Service{Name: "db", Status: model.StatusHealthy, Reconnects: map[string]int{model.CauseNetworkError: 2, "exit 1": 1}}
+	second.Record([]model.Service{db}, day.Add(time.Hour))
+	// db was stopped and started again: its counters dropped to zero.
+	db.Reconnects = map[string]int{model.CauseNetworkError: 1}
+	second.Record([]model.Service{db}, day.Add(2*time.Hour))
+	db.Reconnects = map[string]int{model.CauseNetworkError: 1, "exit 1": 1}
+	second.Record([]model.Service{db}, day.Add(3*time.Hour))
+	second.Record([]model.Service{db}, day.Add(24*time.Hour)) // nothing new
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := f.Services["db"]
+	today := s.Today(day)
+	if today.Reconnects[model.CauseNetworkError] != 5 || today.Reconnects["exit 1"] != 2 {
+		t.Errorf("today = %v, want 5 network errors and 2 exit 1", today.Reconnects)
+	}
+	if len(s.Days) != 1 {
+		t.Errorf("days = %+v, want only the day with reconnects", s.Days)
+	}
+	if len(s.Errors) != 1 || s.Errors[0].Error != "connection refused" || !s.Errors[0].At.Equal(day.Add(time.Minute)) {
+		t.Errorf("errors = %+v, want the refused connection once", s.Errors)
+	}
+}
+
+func TestKeeperPrunesHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	k, _ := NewKeeper(path)
+	day := time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local)
+	for i := range keepDays + 5 {
+		svc := model.Service{Name: "api", Status: model.StatusError, LastError: "refused " + day.String(),
+			Reconnects: map[string]int{"exit 1": i + 1}}
+		k.Record([]model.Service{svc}, day)
+		day = day.AddDate(0, 0, 1)
+	}
+	s := k.Service("api")
+	if len(s.Days) != keepDays || len(s.Errors) != keepErrors {
+		t.Errorf("kept %d days and %d errors, want %d and %d", len(s.Days), len(s.Errors), keepDays, keepErrors)
+	}
+	if last := s.Days[len(s.Days)-1]; last.Total() != 1 {
+		t.Errorf("the last day counted %d reconnects, want 1", last.Total())
+	}
+}
+
+func TestKeeperRecordsLastRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	k, _ := NewKeeper(path)
+	day := time.Date(2026, 5, 4, 9, 0, 0, 0, time.Local)
+	db := model.Service{Name: "db", Status: model.StatusHealthy}
+	k.Record([]model.Service{db}, day)
+	k.Record([]model.Service{db}, day.Add(time.Hour)) // the same day
+	k.RecordGroups([]string{"backend"}, day)
+
+	f, _ := Load(path)
+	if got := f.Services["db"].LastRun; !got.Equal(day) {
+		t.Errorf("last run = %v, want the session's first sight of db, %v", got, day)
+	}
+	if got := f.Groups["backend"]; !got.Equal(day) {
+		t.Errorf("group last run = %v, want %v", got, day)
+	}
+
+	k.Record([]model.Service{db}, day.AddDate(0, 0, 1))
+	if got := k.Service("db").LastRun; !got.Equal(day.AddDate(0, 0, 1)) {
+		t.Errorf("a session running into the next day should move the last run, got %v", got)
+	}
+}
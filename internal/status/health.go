@@ -0,0 +1,70 @@
+package status
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// Health is pf's own health as `pf status --check` reports it, for a
+// supervisor or monitor rather than a person. Live means some session is
+// refreshing its file; Ready adds that the config can be read, so the session
+// can take requests and reload it.
+type Health struct {
+	Live     bool     `json:"live"`
+	Ready    bool     `json:"ready"`
+	Sessions int      `json:"sessions"`
+	Services int      `json:"services"`
+	Healthy  int      `json:"healthy"`
+	Age      string   `json:"heartbeat_age,omitempty"` // since the freshest session last wrote its file
+	Problems []string `json:"problems,omitempty"`
+}
+
+// CheckHealth judges the live sessions read at now, given whether the config
+// (configErr) and the session dir (dirErr) are usable.
+func CheckHealth(sessions []Session, now time.Time, configErr, dirErr error) Health {
+	h := Health{Sessions: len(sessions)}
+	var freshest time.Time
+	for _, s := range sessions {
+		if s.Updated.After(freshest) {
+			freshest = s.Updated
+		}
+		h.Services += len(s.Services)
+		h.Healthy += countHealthy(s.Services)
+	}
+	if len(sessions) == 0 {
+		h.Problems = append(h.Problems, "no pf session is running")
+	} else {
+		h.Live = true
+		h.Age = now.Sub(freshest).Round(time.Second).String()
+	}
+	if configErr != nil {
+		h.Problems = append(h.Problems, fmt.Sprintf("config: %v", configErr))
+	}
+	if dirErr != nil {
+		h.Problems = append(h.Problems, fmt.Sprintf("session dir: %v", dirErr))
+	}
+	h.Ready = h.Live && configErr == nil && dirErr == nil
+	return h
+}
+
+// sdNotify tells systemd about the session when it runs as a Type=notify
+// unit (NOTIFY_SOCKET is set), e.g. "READY=1" or "WATCHDOG=1". Anywhere else
+// it does nothing.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:] // abstract socket
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
@@ -0,0 +1,500 @@
+// Package status shares a running session's service states with other
+// processes. Each `pf run` publishes a small JSON file under ~/.pf/run that it
+// refreshes while it lives; `pf status` and status-bar modules read those files
+// instead of talking to the TUI, so polling them is cheap.
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/endpoint"
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/statedir"
+)
+
+const (
+	// pollInterval is how often a session re-reads its states; the file is
+	// only rewritten when something changed or the heartbeat is due.
+	pollInterval = 2 * time.Second
+	heartbeat    = 10 * time.Second
+	// staleAfter drops files left behind by a session that was killed before
+	// it could remove its own.
+	staleAfter = 3 * heartbeat
+)
+
+// Service is one forward as published by a session.
+type Service struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Address  string `json:"address"`          // local address, e.g. "127.0.0.1:5432"; "" for a monitor
+	Host     string `json:"host"`             // host a local client dials
+	Port     string `json:"port"`             // local port
+	Target   string `json:"target,omitempty"` // e.g. "svc/postgres:5432"
+	Error    string `json:"error,omitempty"`
+	Restarts int    `json:"restarts"`
+	// Reconnects counts the reconnects this session by cause, e.g.
+	// {"network error": 3, "exit 1": 1}.
+	Reconnects map[string]int `json:"reconnects,omitempty"`
+	// Transitions are the latest status changes, oldest first.
+	Transitions []Transition `json:"transitions,omitempty"`
+	// Metrics are the reliability figures this session; see model.Metrics.
+	Metrics Metrics `json:"metrics"`
+}
+
+// Metrics are a service's model.Metrics as published, durations as strings
+// such as "1h2m3.5s".
+type Metrics struct {
+	Healthy     string    `json:"healthy"`
+	UpSince     time.Time `json:"upSince,omitzero"`
+	Errors      int       `json:"errors"`
+	Connects    int       `json:"connects"`
+	ConnectTime string    `json:"connectTime"`
+}
+
+// Model reads the figures back; a duration that does not parse counts as 0.
+func (m Metrics) Model() model.Metrics {
+	healthy, _ := time.ParseDuration(m.Healthy)
+	connectTime, _ := time.ParseDuration(m.ConnectTime)
+	return model.Metrics{Healthy: healthy, UpSince: m.UpSince, Errors: m.Errors, Connects: m.Connects, ConnectTime: connectTime}
+}
+
+// Transition is one status change as published: e.g. from "error" to
+// "healthy" at At, after "4m12s" in error.
+type Transition struct {
+	From     string    `json:"from"`
+	To       string    `json:"to"`
+	At       time.Time `json:"at"`
+	Duration string    `json:"duration"`
+}
+
+// Group is the combined status of a group a session started.
+type Group struct {
+	Name    string   `json:"name"`
+	Status  string   `json:"status"`           // healthy only while every member is
+	Reason  string   `json:"reason,omitempty"` // e.g. "db: connection refused"
+	Healthy int      `json:"healthy"`
+	Members []string `json:"members"`
+}
+
+// Session is the content of one session file.
+type Session struct {
+	PID      int       `json:"pid"`
+	Label    string    `json:"label,omitempty"` // what was run, e.g. a group name
+	Started  time.Time `json:"started"`
+	Updated  time.Time `json:"updated"`
+	Services []Service `json:"services"`
+	Groups   []Group   `json:"groups,omitempty"`
+}
+
+// Dir is where session files live: ~/.pf/run.
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".pf", "run"), nil
+}
+
+// Publish starts writing this process's session file and keeps it current
+// until the returned stop func is called, which also removes the file. A
+// session that cannot write its file still runs; status readers just won't
+// see it. groups, if not nil, adds the session's started groups.
+//
+// Under systemd (Type=notify) the session reports ready after its first
+// write and pings the watchdog on every poll, so a wedged session that stops
+// publishing gets restarted (WatchdogSec= of 10s or more).
+func Publish(label string, states func() []model.Service, groups func() []model.GroupState) (stop func()) {
+	dir, err := Dir()
+	if err != nil || os.MkdirAll(dir, 0700) != nil {
+		return func() {}
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%d.json", os.Getpid()))
+	session := Session{PID: os.Getpid(), Label: label, Started: time.Now()}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		var last []Service
+		var lastGroups []Group
+		var written time.Time
+		for {
+			current := Snapshot(states())
+			var currentGroups []Group
+			if groups != nil {
+				currentGroups = SnapshotGroups(groups())
+			}
+			if written.IsZero() || time.Since(written) >= heartbeat || !reflect.DeepEqual(current, last) || !reflect.DeepEqual(currentGroups, lastGroups) {
+				session.Services, session.Groups = current, currentGroups
+				session.Updated = time.Now()
+				if writeSession(path, session) == nil {
+					if written.IsZero() {
+						sdNotify("READY=1")
+					}
+					last, lastGroups, written = current, currentGroups, session.Updated
+				}
+			}
+			sdNotify(fmt.Sprintf("WATCHDOG=1\nSTATUS=%d/%d services healthy", countHealthy(current), len(current)))
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+			os.Remove(path)
+		})
+	}
+}
+
+func countHealthy(services []Service) int {
+	n := 0
+	for _, svc := range services {
+		if svc.Status == model.StatusHealthy {
+			n++
+		}
+	}
+	return n
+}
+
+// Snapshot converts manager states to their published form, sorted by name.
+func Snapshot(services []model.Service) []Service {
+	out := make([]Service, 0, len(services))
+	for i := range services {
+		svc := &services[i]
+		host := svc.BindAddress
+		if host == "" {
+			host = "127.0.0.1"
+		}
+		published := Service{
+			Name:       svc.Name,
+			Status:     svc.Status,
+			Address:    net.JoinHostPort(host, svc.LocalPort),
+			Host:       endpoint.DialHost(svc.BindAddress),
+			Port:       svc.LocalPort,
+			Target:     endpoint.TargetLabel(svc),
+			Error:      svc.LastError,
+			Restarts:   svc.RestartCount,
+			Reconnects: svc.Reconnects,
+			Metrics: Metrics{
+				Healthy:     svc.Metrics.Healthy.Round(time.Millisecond).String(),
+				UpSince:     svc.Metrics.UpSince,
+				Errors:      svc.Metrics.Errors,
+				Connects:    svc.Metrics.Connects,
+				ConnectTime: svc.Metrics.ConnectTime.Round(time.Millisecond).String(),
+			},
+		}
+		for _, t := range svc.Transitions {
+			published.Transitions = append(published.Transitions, Transition{
+				From: t.From, To: t.To, At: t.At, Duration: t.Duration.Round(time.Second).String(),
+			})
+		}
+		if svc.Monitor != "" {
+			// A monitor listens nowhere; only its check is worth showing.
+			published.Address, published.Host = "", ""
+		}
+		out = append(out, published)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// SnapshotGroups converts the manager's group states to their published form.
+func SnapshotGroups(groups []model.GroupState) []Group {
+	if len(groups) == 0 {
+		return nil
+	}
+	out := make([]Group, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, Group{Name: g.Name, Status: g.Status, Reason: g.Reason, Healthy: g.Healthy, Members: g.Members})
+	}
+	return out
+}
+
+// FindGroup returns the group name as published by the first session that
+// started it.
+func FindGroup(sessions []Session, name string) (Group, bool) {
+	for _, s := range sessions {
+		for _, g := range s.Groups {
+			if g.Name == name {
+				return g, true
+			}
+		}
+	}
+	return Group{}, false
+}
+
+// WaybarGroup is Waybar for one group: "backend 2/3" with the group's status
+// as the class and its members in the tooltip. A group no session runs is
+// "idle", which hides the module.
+func WaybarGroup(sessions []Session, name string) WaybarOutput {
+	g, ok := FindGroup(sessions, name)
+	if !ok {
+		return WaybarOutput{Alt: "idle", Class: "idle", Tooltip: "Group " + name + " is not running"}
+	}
+	tooltip := fmt.Sprintf("%s: %s  (%d/%d healthy)", g.Name, g.Status, g.Healthy, len(g.Members))
+	if g.Reason != "" {
+		tooltip += "\n" + g.Reason
+	}
+	return WaybarOutput{
+		Text:    fmt.Sprintf("%s %d/%d", g.Name, g.Healthy, len(g.Members)),
+		Alt:     g.Status,
+		Tooltip: pangoEscape(tooltip),
+		Class:   g.Status,
+	}
+}
+
+// writeSession replaces path atomically so readers never see half a file.
+func writeSession(path string, session Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return statedir.WriteFile(path, data, 0600)
+}
+
+// ReadSessions loads every live session file in dir, oldest first. Files that
+// are unreadable or have not been refreshed for a while are skipped (stale
+// ones are deleted). A missing dir just means nothing is running.
+func ReadSessions(dir string, now time.Time) ([]Session, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sessions []Session
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var s Session
+		if json.Unmarshal(data, &s) != nil {
+			continue
+		}
+		if now.Sub(s.Updated) > staleAfter {
+			os.Remove(path)
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Started.Before(sessions[j].Started) })
+	return sessions, nil
+}
+
+// Endpoints flattens the services of all sessions into endpoints, sorted by
+// name, for `pf env` and its templates.
+func Endpoints(sessions []Session) []endpoint.Endpoint {
+	var out []endpoint.Endpoint
+	for _, s := range sessions {
+		for _, svc := range s.Services {
+			out = append(out, endpoint.Endpoint{
+				Name:   svc.Name,
+				Host:   svc.Host,
+				Port:   svc.Port,
+				Status: svc.Status,
+				Target: svc.Target,
+			})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Worst returns the most severe status across all sessions: error, then
+// connecting, then healthy; "" when nothing is running.
+func Worst(sessions []Session) string {
+	rank := map[string]int{model.StatusHealthy: 1, model.StatusConnecting: 2, model.StatusError: 3}
+	worst := ""
+	for _, s := range sessions {
+		for _, svc := range s.Services {
+			if rank[svc.Status] > rank[worst] {
+				worst = svc.Status
+			}
+		}
+	}
+	return worst
+}
+
+// WaybarOutput is the JSON a waybar "custom" module (return-type json)
+// expects. polybar and i3blocks scripts can read the same object with jq.
+type WaybarOutput struct {
+	Text    string `json:"text"`
+	Alt     string `json:"alt"`
+	Tooltip string `json:"tooltip"`
+	Class   string `json:"class"`
+}
+
+// Waybar summarizes sessions for a status bar: the text counts healthy
+// forwards out of the total, the tooltip lists every forward, and the class
+// is the worst status ("idle" with empty text when nothing runs, which makes
+// waybar hide the module).
+func Waybar(sessions []Session) WaybarOutput {
+	total, healthy := 0, 0
+	var tooltip []string
+	for _, s := range sessions {
+		for _, svc := range s.Services {
+			total++
+			if svc.Status == model.StatusHealthy {
+				healthy++
+			}
+			line := fmt.Sprintf("%s: %s  %s", svc.Name, svc.Status, svc.Address)
+			if svc.Target != "" {
+				line += " → " + svc.Target
+			}
+			if svc.Status == model.StatusError && svc.Error != "" {
+				line += "  (" + svc.Error + ")"
+			}
+			tooltip = append(tooltip, pangoEscape(line))
+		}
+	}
+	if total == 0 {
+		return WaybarOutput{Alt: "idle", Class: "idle", Tooltip: "No port forwards running"}
+	}
+	class := Worst(sessions)
+	return WaybarOutput{
+		Text:    fmt.Sprintf("pf %d/%d", healthy, total),
+		Alt:     class,
+		Tooltip: strings.Join(tooltip, "\n"),
+		Class:   class,
+	}
+}
+
+// pangoEscape escapes text for waybar tooltips, which are Pango markup.
+func pangoEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// requestSuffix names the file another pf process leaves next to a session's
+// own to ask it to start or stop services (`pf run --only-failed`, `pf
+// apply`), to dump its logs (`pf logs export`) or to reload its settings (`pf
+// config reload`). It is not ".json", so ReadSessions never takes it for a session.
+const requestSuffix = ".request"
+
+// Request is what another process asks a session to do. The session stops
+// first, then starts; a service it is asked to start that already runs there,
+// retrying after an error, restarts at once instead. Export names files to
+// dump the session's logs to; Reload re-reads the config's settings.
+type Request struct {
+	Start  []string `json:"start,omitempty"`
+	Stop   []string `json:"stop,omitempty"`
+	Export []string `json:"export,omitempty"`
+	Reload bool     `json:"reload,omitempty"`
+}
+
+func requestPath(dir string, pid int) string {
+	return filepath.Join(dir, fmt.Sprintf("%d%s", pid, requestSuffix))
+}
+
+// SendRequest asks the session with pid to carry out r. Names still waiting
+// from an earlier request are kept.
+func SendRequest(dir string, pid int, r Request) error {
+	var pending Request
+	if data, err := os.ReadFile(requestPath(dir, pid)); err == nil {
+		json.Unmarshal(data, &pending)
+	}
+	pending.Start = appendMissing(pending.Start, r.Start)
+	pending.Stop = appendMissing(pending.Stop, r.Stop)
+	pending.Export = appendMissing(pending.Export, r.Export)
+	pending.Reload = pending.Reload || r.Reload
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	return statedir.WriteFile(requestPath(dir, pid), data, 0600)
+}
+
+func appendMissing(list, names []string) []string {
+	for _, name := range names {
+		if !slices.Contains(list, name) {
+			list = append(list, name)
+		}
+	}
+	return list
+}
+
+// RequestPending reports whether the session with pid has yet to take the
+// last request sent to it.
+func RequestPending(dir string, pid int) bool {
+	_, err := os.Stat(requestPath(dir, pid))
+	return err == nil
+}
+
+// TakeRequest returns what other processes asked this session to do, and
+// clears the request.
+func TakeRequest(dir string, pid int) Request {
+	var r Request
+	data, err := os.ReadFile(requestPath(dir, pid))
+	if err != nil {
+		return r
+	}
+	os.Remove(requestPath(dir, pid))
+	json.Unmarshal(data, &r)
+	return r
+}
+
+// FindFailed picks the session running most of names and returns it with the
+// names that failed there: in error in it, or not running in any session (a
+// member that could not even start). ok is false when no session runs any of
+// names.
+func FindFailed(sessions []Session, names []string) (session Session, failed []string, ok bool) {
+	best := 0
+	for _, s := range sessions {
+		count := 0
+		for _, svc := range s.Services {
+			if slices.Contains(names, svc.Name) {
+				count++
+			}
+		}
+		if count > best {
+			session, best, ok = s, count, true
+		}
+	}
+	if !ok {
+		return Session{}, nil, false
+	}
+
+	mine := map[string]string{}
+	for _, svc := range session.Services {
+		mine[svc.Name] = svc.Status
+	}
+	// A member another session runs is that session's business.
+	elsewhere := map[string]bool{}
+	for _, s := range sessions {
+		for _, svc := range s.Services {
+			elsewhere[svc.Name] = elsewhere[svc.Name] || s.PID != session.PID
+		}
+	}
+	for _, name := range names {
+		status, ok := mine[name]
+		if ok && status == model.StatusError || !ok && !elsewhere[name] {
+			failed = append(failed, name)
+		}
+	}
+	return session, failed, true
+}
@@ -0,0 +1,183 @@
+package status
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestReadSessionsSkipsStaleAndBrokenFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	live := Session{PID: 1, Updated: now, Services: []Service{{Name: "db", Status: model.StatusHealthy}}}
+	stale := Session{PID: 2, Updated: now.Add(-time.Hour)}
+	if err := writeSession(filepath.Join(dir, "1.json"), live); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSession(filepath.Join(dir, "2.json"), stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "3.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := ReadSessions(dir, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].PID != 1 {
+		t.Fatalf("sessions = %+v, want only pid 1", sessions)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2.json")); !os.IsNotExist(err) {
+		t.Error("stale session file should be removed")
+	}
+
+	if s, err := ReadSessions(filepath.Join(dir, "missing"), now); err != nil || s != nil {
+		t.Errorf("missing dir: got %v, %v; want nothing running", s, err)
+	}
+}
+
+func TestWaybarUsesWorstStatus(t *testing.T) {
+	sessions := []Session{{Services: Snapshot([]model.Service{
+		{Name: "db", LocalPort: "15432", MainPort: "5432", Target: "svc/postgres", Status: model.StatusHealthy},
+		{Name: "api", LocalPort: "8080", MainPort: "80", Status: model.StatusError, LastError: "pod <api> not found"},
+	})}}
+
+	out := Waybar(sessions)
+	if out.Text != "pf 1/2" || out.Class != model.StatusError {
+		t.Errorf("text=%q class=%q, want pf 1/2 / error", out.Text, out.Class)
+	}
+	for _, want := range []string{"db: healthy  127.0.0.1:15432 → svc/postgres:5432", "&lt;api&gt;"} {
+		if !strings.Contains(out.Tooltip, want) {
+			t.Errorf("tooltip missing %q: %q", want, out.Tooltip)
+		}
+	}
+
+	if idle := Waybar(nil); idle.Text != "" || idle.Class != "idle" {
+		t.Errorf("no sessions: %+v, want empty text and idle class", idle)
+	}
+}
+
+func TestWaybarGroup(t *testing.T) {
+	services := []model.Service{
+		{Name: "db", Status: model.StatusHealthy},
+		{Name: "api", Status: model.StatusError, LastError: "pod <api> not found"},
+	}
+	sessions := []Session{{Groups: SnapshotGroups([]model.GroupState{
+		model.NewGroupState("backend", []string{"db", "api"}, services),
+	})}}
+
+	out := WaybarGroup(sessions, "backend")
+	if out.Text != "backend 1/2" || out.Class != model.StatusError || !strings.Contains(out.Tooltip, "api: pod &lt;api&gt; not found") {
+		t.Errorf("got %+v", out)
+	}
+	if idle := WaybarGroup(sessions, "frontend"); idle.Text != "" || idle.Class != "idle" {
+		t.Errorf("a group no session runs: %+v, want idle", idle)
+	}
+}
+
+func TestFindFailed(t *testing.T) {
+	sessions := []Session{
+		{PID: 1, Services: []Service{{Name: "cache", Status: model.StatusHealthy}}},
+		{PID: 2, Services: []Service{
+			{Name: "api", Status: model.StatusHealthy},
+			{Name: "db", Status: model.StatusError},
+			{Name: "queue", Status: model.StatusConnecting},
+		}},
+	}
+	session, failed, ok := FindFailed(sessions, []string{"api", "db", "queue", "search", "cache"})
+	if !ok || session.PID != 2 {
+		t.Fatalf("FindFailed picked %+v, %v; want pid 2", session, ok)
+	}
+	if strings.Join(failed, ",") != "db,search" {
+		t.Errorf("failed = %v, want db and search", failed)
+	}
+	if _, _, ok := FindFailed(sessions, []string{"search"}); ok {
+		t.Error("no session runs search")
+	}
+}
+
+func TestRequests(t *testing.T) {
+	dir := t.TempDir()
+	if err := SendRequest(dir, 7, Request{Start: []string{"db"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SendRequest(dir, 7, Request{Start: []string{"db", "search"}, Stop: []string{"cache"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !RequestPending(dir, 7) {
+		t.Error("the request should be pending")
+	}
+	if sessions, _ := ReadSessions(dir, time.Now()); len(sessions) != 0 {
+		t.Errorf("a request is not a session: %+v", sessions)
+	}
+	r := TakeRequest(dir, 7)
+	if strings.Join(r.Start, ",") != "db,search" || strings.Join(r.Stop, ",") != "cache" {
+		t.Errorf("TakeRequest = %+v", r)
+	}
+	if RequestPending(dir, 7) || TakeRequest(dir, 7).Start != nil {
+		t.Error("a taken request should be gone")
+	}
+}
+
+func TestSnapshotPublishesTransitions(t *testing.T) {
+	at := time.Date(2026, 3, 1, 14, 2, 0, 0, time.UTC)
+	got := Snapshot([]model.Service{{
+		Name: "db", Status: model.StatusHealthy, LocalPort: "5432",
+		Transitions: []model.Transition{{From: model.StatusError, To: model.StatusHealthy, At: at, Duration: 252400 * time.Millisecond}},
+	}})
+	want := []Transition{{From: "error", To: "healthy", At: at, Duration: "4m12s"}}
+	if len(got) != 1 || !reflect.DeepEqual(got[0].Transitions, want) {
+		t.Errorf("transitions = %+v, want %+v", got[0].Transitions, want)
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	now := time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC)
+	sessions := []Session{
+		{PID: 1, Updated: now.Add(-8 * time.Second), Services: []Service{{Name: "db", Status: model.StatusHealthy}}},
+		{PID: 2, Updated: now.Add(-3 * time.Second), Services: []Service{{Name: "api", Status: model.StatusError}}},
+	}
+	h := CheckHealth(sessions, now, nil, nil)
+	if !h.Live || !h.Ready || h.Services != 2 || h.Healthy != 1 || h.Age != "3s" || h.Problems != nil {
+		t.Errorf("healthy sessions: %+v", h)
+	}
+
+	h = CheckHealth(sessions, now, os.ErrPermission, nil)
+	if !h.Live || h.Ready || len(h.Problems) != 1 {
+		t.Errorf("unreadable config: %+v, want live but not ready", h)
+	}
+	if h := CheckHealth(nil, now, nil, nil); h.Live || h.Ready || len(h.Problems) != 1 {
+		t.Errorf("no sessions: %+v, want neither live nor ready", h)
+	}
+}
+
+func TestSDNotify(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("no unix datagram sockets here: %v", err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", socket)
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "READY=1" {
+		t.Errorf("got %q, %v", buf[:n], err)
+	}
+
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := sdNotify("READY=1"); err != nil {
+		t.Errorf("outside systemd sdNotify should do nothing, got %v", err)
+	}
+}
@@ -2,16 +2,29 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"math"
+	"net"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"runtime"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/alinemone/go-port-forward/internal/catalog"
+	"github.com/alinemone/go-port-forward/internal/cmdline"
 	"github.com/alinemone/go-port-forward/internal/icons"
 	"github.com/alinemone/go-port-forward/internal/model"
 	"github.com/alinemone/go-port-forward/internal/theme"
+	"github.com/alinemone/go-port-forward/internal/totp"
 )
 
 // IconSpec is a user-supplied icon override read from config. Either field may
@@ -46,138 +59,1799 @@ type ThemeSpec struct {
 	Selected  string `json:"selected,omitempty"`
 }
 
+// NotifierConfig is one entry of the config's "notify" list: a backend plus
+// the filters that decide which events reach it. Only the fields of its Type
+// are used; see internal/notify for what each backend does.
+type NotifierConfig struct {
+	Type        string   `json:"type"`                  // desktop, webhook, email or command
+	Services    []string `json:"services,omitempty"`    // only these services; empty = all
+	Groups      []string `json:"groups,omitempty"`      // these started groups as a whole; see notify.Rule
+	Events      []string `json:"events,omitempty"`      // error, recovered; empty = all
+	MinDuration string   `json:"minDuration,omitempty"` // an error must last this long, e.g. "60s"
+
+	URL         string   `json:"url,omitempty"`         // webhook: POSTed a JSON event
+	Command     string   `json:"command,omitempty"`     // command: run through the shell
+	SMTP        string   `json:"smtp,omitempty"`        // email: server host:port
+	From        string   `json:"from,omitempty"`        // email
+	To          []string `json:"to,omitempty"`          // email
+	Username    string   `json:"username,omitempty"`    // email: SMTP auth user
+	PasswordEnv string   `json:"passwordEnv,omitempty"` // email: env var holding the SMTP password
+}
+
+// FlapConfig tunes flap detection: a service that falls into error Errors
+// times within Window is flapping. Zero values keep the defaults; a negative
+// Errors turns detection off.
+type FlapConfig struct {
+	Errors int    `json:"errors,omitempty"`
+	Window string `json:"window,omitempty"` // e.g. "5m"
+}
+
+// ShutdownConfig orders how a session's services stop when it ends. Order
+// names services that stop one at a time, in that order, once all the others
+// have stopped together: list what other services depend on (a bastion
+// tunnel, say) last. Timeout is how long a service gets to exit before its
+// process tree is killed, e.g. "10s"; Timeouts sets it for single services.
+// Unset timeouts leave the caller's default.
+type ShutdownConfig struct {
+	Order    []string          `json:"order,omitempty"`
+	Timeout  string            `json:"timeout,omitempty"`
+	Timeouts map[string]string `json:"timeouts,omitempty"`
+}
+
+// TimeoutFor parses the service's shutdown timeout; 0 when neither Timeouts
+// nor Timeout sets one.
+func (c ShutdownConfig) TimeoutFor(name string) (time.Duration, error) {
+	value := c.Timeout
+	if v, ok := c.Timeouts[name]; ok {
+		value = v
+	}
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid shutdown timeout %q", value)
+	}
+	return d, nil
+}
+
+// Validate checks the timeouts and that no service is ordered twice.
+func (c ShutdownConfig) Validate() error {
+	if _, err := c.TimeoutFor(""); err != nil {
+		return err
+	}
+	for name := range c.Timeouts {
+		if _, err := c.TimeoutFor(name); err != nil {
+			return fmt.Errorf("service %q: %v", name, err)
+		}
+	}
+	seen := make(map[string]bool, len(c.Order))
+	for _, name := range c.Order {
+		if seen[name] {
+			return fmt.Errorf("shutdown order lists %q twice", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// FallbackConfig is a service's fallback command, e.g. an ssh tunnel for when
+// the Kubernetes API is unreachable. The service switches to it after After
+// failed runs in a row (0 = DefaultFallbackAfter) and stays on it, marked as
+// degraded, until restarted.
+type FallbackConfig struct {
+	Command string `json:"command"`
+	After   int    `json:"after,omitempty"`
+}
+
+// WaitCondition is something that must hold before a service's forward starts,
+// e.g. the VPN being up. Exactly one field is set: TCP is a host:port that must
+// accept a connection, File a path that must exist, Command a shell command
+// that must exit 0.
+type WaitCondition struct {
+	TCP     string `json:"tcp,omitempty"`
+	File    string `json:"file,omitempty"`
+	Command string `json:"command,omitempty"`
+}
+
+// String describes the condition for logs, e.g. "tcp vpn.corp:443".
+func (c WaitCondition) String() string {
+	switch {
+	case c.TCP != "":
+		return "tcp " + c.TCP
+	case c.File != "":
+		return "file " + c.File
+	}
+	return "command " + c.Command
+}
+
+// Validate checks that exactly one kind of condition is set, and that a TCP
+// condition is a host:port.
+func (c WaitCondition) Validate() error {
+	set := 0
+	for _, v := range []string{c.TCP, c.File, c.Command} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("waitFor entries need exactly one of tcp, file or command")
+	}
+	if c.TCP != "" {
+		if _, port, err := net.SplitHostPort(c.TCP); err != nil || port == "" {
+			return fmt.Errorf("waitFor tcp %q must be host:port", c.TCP)
+		}
+	}
+	return nil
+}
+
+// PreConnect is a command run before each of a service's connection attempts,
+// e.g. a port-knock script or a check that the `aws sso login` session is
+// still valid. Timeout bounds one run (DefaultPreConnectTimeout when empty). A
+// run that fails or times out fails the attempt, which backs off and tries
+// again like a dropped forward; with OnFailure "continue" the failure is only
+// logged and the forward starts anyway.
+type PreConnect struct {
+	Command   string `json:"command"`
+	Timeout   string `json:"timeout,omitempty"`
+	OnFailure string `json:"onFailure,omitempty"` // "retry" (the default) or "continue"
+}
+
+// DefaultPreConnectTimeout bounds a pre-connect command when the config does
+// not say.
+const DefaultPreConnectTimeout = 30 * time.Second
+
+// TimeoutDuration parses Timeout; "" is DefaultPreConnectTimeout.
+func (p PreConnect) TimeoutDuration() (time.Duration, error) {
+	if p.Timeout == "" {
+		return DefaultPreConnectTimeout, nil
+	}
+	d, err := time.ParseDuration(p.Timeout)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid preConnect timeout %q", p.Timeout)
+	}
+	return d, nil
+}
+
+// Continue reports whether a failed run lets the forward start anyway.
+func (p PreConnect) Continue() bool {
+	return p.OnFailure == "continue"
+}
+
+// Validate checks that there is a command, that the timeout parses and that
+// OnFailure is one of the known values.
+func (p PreConnect) Validate() error {
+	if strings.TrimSpace(p.Command) == "" {
+		return fmt.Errorf("preConnect needs a command")
+	}
+	switch p.OnFailure {
+	case "", "retry", "continue":
+	default:
+		return fmt.Errorf("preConnect onFailure must be retry or continue, not %q", p.OnFailure)
+	}
+	_, err := p.TimeoutDuration()
+	return err
+}
+
+// PostConnect is a command run once a service is first healthy in a session,
+// e.g. to run migrations or warm a cache through the new forward. Its output
+// goes to the service's log. Timeout bounds the run
+// (DefaultPostConnectTimeout when empty); a failure is logged and leaves the
+// forward as it is.
+type PostConnect struct {
+	Command string `json:"command"`
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// DefaultPostConnectTimeout bounds a post-connect command when the config
+// does not say.
+const DefaultPostConnectTimeout = 5 * time.Minute
+
+// TimeoutDuration parses Timeout; "" is DefaultPostConnectTimeout.
+func (p PostConnect) TimeoutDuration() (time.Duration, error) {
+	if p.Timeout == "" {
+		return DefaultPostConnectTimeout, nil
+	}
+	d, err := time.ParseDuration(p.Timeout)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid postConnect timeout %q", p.Timeout)
+	}
+	return d, nil
+}
+
+// Validate checks that there is a command and that the timeout parses.
+func (p PostConnect) Validate() error {
+	if strings.TrimSpace(p.Command) == "" {
+		return fmt.Errorf("postConnect needs a command")
+	}
+	_, err := p.TimeoutDuration()
+	return err
+}
+
+// OTP is a time-based one-time code (TOTP) generator for a service whose
+// bastion asks for a code on every connection. Its base32 secret, the one an
+// authenticator app is set up with, lives in the OS keyring under Service and
+// Account. pf answers the service's prompts matching Prompt
+// (DefaultOTPPrompt when empty) with a code, and hands one to its pre-connect
+// command as $PF_OTP. Digits (6 when 0) and Period ("30s" when empty) follow
+// the secret's setup.
+type OTP struct {
+	Service string `json:"service"`
+	Account string `json:"account"`
+	Digits  int    `json:"digits,omitempty"`
+	Period  string `json:"period,omitempty"`
+	Prompt  string `json:"prompt,omitempty"`
+}
+
+// DefaultOTPPrompt matches the prompts bastions ask for codes with.
+const DefaultOTPPrompt = `(?i)verification code|one-time|\botp\b|passcode|\btoken\b|two-factor|2fa|authenticator`
+
+// Settings returns the OTP's digits, period and prompt pattern, defaults
+// filled in.
+func (o OTP) Settings() (digits int, period time.Duration, prompt *regexp.Regexp, err error) {
+	digits = o.Digits
+	if digits == 0 {
+		digits = totp.DefaultDigits
+	}
+	if digits < 6 || digits > 8 {
+		return 0, 0, nil, fmt.Errorf("otp digits must be 6 to 8, not %d", o.Digits)
+	}
+	period = totp.DefaultPeriod
+	if o.Period != "" {
+		if period, err = time.ParseDuration(o.Period); err != nil || period < time.Second || period%time.Second != 0 {
+			return 0, 0, nil, fmt.Errorf("invalid otp period %q (whole seconds)", o.Period)
+		}
+	}
+	pattern := o.Prompt
+	if pattern == "" {
+		pattern = DefaultOTPPrompt
+	}
+	if prompt, err = regexp.Compile(pattern); err != nil {
+		return 0, 0, nil, fmt.Errorf("invalid otp prompt: %v", err)
+	}
+	return digits, period, prompt, nil
+}
+
+// Validate checks that the keyring entry is named and the settings parse.
+func (o OTP) Validate() error {
+	if o.Service == "" || o.Account == "" {
+		return fmt.Errorf("otp needs the keyring service and account of its secret")
+	}
+	_, _, _, err := o.Settings()
+	return err
+}
+
+// RemoteCheck makes pf check the far end of a service's forward while it is
+// up, not just that the local port listens: a pod that is not Ready, or a
+// host behind the bastion that does not answer, puts the service in error
+// until it is back. Every is how often (DefaultRemoteCheckEvery when empty).
+// See cmdline.RemoteProbeFor for what is checked.
+type RemoteCheck struct {
+	Every string `json:"every,omitempty"`
+}
+
+// DefaultRemoteCheckEvery is how often a remote check runs when the config
+// does not say.
+const DefaultRemoteCheckEvery = 30 * time.Second
+
+// EveryDuration parses Every; "" is DefaultRemoteCheckEvery.
+func (r RemoteCheck) EveryDuration() (time.Duration, error) {
+	if r.Every == "" {
+		return DefaultRemoteCheckEvery, nil
+	}
+	d, err := time.ParseDuration(r.Every)
+	if err != nil || d < time.Second {
+		return 0, fmt.Errorf("invalid remoteCheck every %q (1s or more)", r.Every)
+	}
+	return d, nil
+}
+
+// Validate checks that the interval parses.
+func (r RemoteCheck) Validate() error {
+	_, err := r.EveryDuration()
+	return err
+}
+
+// DNS is how pf finds the address of an ssh forward's remote host, for
+// split-DNS setups (a VPN, say) where the name resolves to the wrong address
+// for the bastion. Resolver is one of:
+//
+//   - "system" (the default): pf leaves the name in the command and the ssh
+//     server resolves it, as without this setting.
+//   - "server": before each connection pf looks the name up at Server
+//     (host[:port], port 53 by default) over TCP, so Server may be another
+//     service's local end forwarding a DNS server behind the tunnel, and
+//     forwards to the address it got.
+//   - "pin": pf forwards to IP and never resolves the name.
+//
+// Only IPv4 addresses are used, as ssh -L specs separate fields with colons.
+type DNS struct {
+	Resolver string `json:"resolver,omitempty"`
+	Server   string `json:"server,omitempty"`
+	IP       string `json:"ip,omitempty"`
+}
+
+// The DNS resolvers.
+const (
+	DNSSystem = "system"
+	DNSServer = "server"
+	DNSPin    = "pin"
+)
+
+// ServerAddress is Server with the DNS port added when it has none.
+func (d DNS) ServerAddress() string {
+	if _, _, err := net.SplitHostPort(d.Server); err == nil {
+		return d.Server
+	}
+	return net.JoinHostPort(d.Server, "53")
+}
+
+// Validate checks that the resolver is known and has what it needs.
+func (d DNS) Validate() error {
+	switch d.Resolver {
+	case "", DNSSystem:
+		if d.Server != "" || d.IP != "" {
+			return fmt.Errorf("dns server and ip need resolver %q or %q", DNSServer, DNSPin)
+		}
+	case DNSServer:
+		if d.Server == "" {
+			return fmt.Errorf("dns resolver %q needs a server", DNSServer)
+		}
+		if _, port, err := net.SplitHostPort(d.ServerAddress()); err != nil || port == "" {
+			return fmt.Errorf("invalid dns server %q (host or host:port)", d.Server)
+		}
+	case DNSPin:
+		if ip := net.ParseIP(d.IP); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("dns resolver %q needs an IPv4 ip, not %q", DNSPin, d.IP)
+		}
+	default:
+		return fmt.Errorf("unknown dns resolver %q (system, server or pin)", d.Resolver)
+	}
+	return nil
+}
+
+// Keepalive is the policy for the connection options pf adds to a service's
+// commands, so a dead connection is noticed and reconnected instead of the
+// tunnel hanging, and a connection that cannot be made fails in time. For
+// ssh: Interval is how long ssh waits between keepalives
+// (DefaultKeepaliveInterval when empty), CountMax how many may go unanswered
+// (DefaultKeepaliveCountMax when 0) and ConnectTimeout how long connecting
+// may take (DefaultConnectTimeout when empty). For kubectl port-forward,
+// PodRunningTimeout sets --pod-running-timeout; kubectl's own default
+// applies when empty. Off adds nothing at all, for bastions that drop
+// connections which send keepalives.
+type Keepalive struct {
+	Interval          string `json:"interval,omitempty"`
+	CountMax          int    `json:"countMax,omitempty"`
+	ConnectTimeout    string `json:"connectTimeout,omitempty"`
+	PodRunningTimeout string `json:"podRunningTimeout,omitempty"`
+	Off               bool   `json:"off,omitempty"`
+}
+
+// Keepalive defaults, for services the config does not tune.
+const (
+	DefaultKeepaliveInterval = 15 * time.Second
+	DefaultKeepaliveCountMax = 3
+	DefaultConnectTimeout    = 10 * time.Second
+)
+
+// SSHOptions returns the -o options, "Name=value", that carry the ssh
+// settings; none when Off.
+func (k Keepalive) SSHOptions() ([]string, error) {
+	if k.Off {
+		return nil, nil
+	}
+	interval, err := keepaliveSeconds("interval", k.Interval, DefaultKeepaliveInterval)
+	if err != nil {
+		return nil, err
+	}
+	connect, err := keepaliveSeconds("connectTimeout", k.ConnectTimeout, DefaultConnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+	count := k.CountMax
+	if count < 0 {
+		return nil, fmt.Errorf("keepalive countMax must not be negative")
+	}
+	if count == 0 {
+		count = DefaultKeepaliveCountMax
+	}
+	return []string{
+		fmt.Sprintf("ServerAliveInterval=%d", interval),
+		fmt.Sprintf("ServerAliveCountMax=%d", count),
+		fmt.Sprintf("ConnectTimeout=%d", connect),
+	}, nil
+}
+
+// keepaliveSeconds parses the duration setting value in whole seconds, which
+// is what ssh takes; "" is def.
+func keepaliveSeconds(setting, value string, def time.Duration) (int, error) {
+	d := def
+	if value != "" {
+		var err error
+		if d, err = time.ParseDuration(value); err != nil || d < time.Second {
+			return 0, fmt.Errorf("invalid keepalive %s %q (at least 1s)", setting, value)
+		}
+	}
+	return int(d.Seconds()), nil
+}
+
+// KubectlFlags returns the flags, "--name=value", that carry the kubectl
+// settings; none when Off or unset.
+func (k Keepalive) KubectlFlags() ([]string, error) {
+	if k.Off || k.PodRunningTimeout == "" {
+		return nil, nil
+	}
+	if d, err := time.ParseDuration(k.PodRunningTimeout); err != nil || d <= 0 {
+		return nil, fmt.Errorf("invalid keepalive podRunningTimeout %q", k.PodRunningTimeout)
+	}
+	return []string{"--pod-running-timeout=" + k.PodRunningTimeout}, nil
+}
+
+// Validate checks that the durations parse and the count is not negative.
+func (k Keepalive) Validate() error {
+	if _, err := k.SSHOptions(); err != nil {
+		return err
+	}
+	_, err := k.KubectlFlags()
+	return err
+}
+
+// HostKeyChecking is a service's policy for ssh host keys it does not know,
+// set as StrictHostKeyChecking on its ssh invocations: "strict" refuses to
+// connect to a host whose key is not pinned in known_hosts yet (see `pf ssh
+// trust`), "accept-new" pins a new host's key on first connect but still
+// refuses a changed one, and "off" accepts any key without pinning it, for
+// throwaway hosts in CI. Empty leaves it to ssh_config.
+type HostKeyChecking string
+
+// The host key policies.
+const (
+	HostKeysStrict    HostKeyChecking = "strict"
+	HostKeysAcceptNew HostKeyChecking = "accept-new"
+	HostKeysOff       HostKeyChecking = "off"
+)
+
+// SSHOptions returns the -o options, "Name=value", that carry the policy.
+// Off also keeps the accepted keys out of known_hosts, where a later host
+// reusing the address would otherwise trip over them.
+func (h HostKeyChecking) SSHOptions() []string {
+	switch h {
+	case HostKeysStrict:
+		return []string{"StrictHostKeyChecking=yes"}
+	case HostKeysAcceptNew:
+		return []string{"StrictHostKeyChecking=accept-new"}
+	case HostKeysOff:
+		return []string{"StrictHostKeyChecking=no", "UserKnownHostsFile=/dev/null"}
+	}
+	return nil
+}
+
+// Validate checks that the policy is one of the known ones.
+func (h HostKeyChecking) Validate() error {
+	switch h {
+	case "", HostKeysStrict, HostKeysAcceptNew, HostKeysOff:
+		return nil
+	}
+	return fmt.Errorf("hostKeyChecking must be strict, accept-new or off, not %q", string(h))
+}
+
+// RestartSchedule restarts a service at fixed times of day, for tunnels that
+// degrade over time or whose credentials rotate daily. At lists the times as
+// "HH:MM"; Zone is the IANA time zone they are in, e.g. "Europe/Berlin", and
+// the machine's own when empty.
+type RestartSchedule struct {
+	At   []string `json:"at"`
+	Zone string   `json:"zone,omitempty"`
+}
+
+// Validate checks that there is at least one time and that the times and
+// zone parse.
+func (r RestartSchedule) Validate() error {
+	_, err := r.Next(time.Now())
+	return err
+}
+
+// Next returns the first scheduled time after now, in the schedule's zone. On
+// a day a daylight saving change skips a time, it comes as much later as the
+// clocks jumped: 02:30 at 03:30.
+func (r RestartSchedule) Next(now time.Time) (time.Time, error) {
+	if len(r.At) == 0 {
+		return time.Time{}, fmt.Errorf("restart schedule needs at least one time in \"at\"")
+	}
+	loc := time.Local
+	if r.Zone != "" {
+		var err error
+		if loc, err = time.LoadLocation(r.Zone); err != nil {
+			return time.Time{}, fmt.Errorf("restart schedule: unknown time zone %q", r.Zone)
+		}
+	}
+	local := now.In(loc)
+	var next time.Time
+	for _, at := range r.At {
+		clock, err := time.Parse("15:04", at)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("restart schedule: invalid time %q, want HH:MM", at)
+		}
+		for day := 0; day <= 1; day++ {
+			t := time.Date(local.Year(), local.Month(), local.Day()+day, clock.Hour(), clock.Minute(), 0, 0, loc)
+			if t.Hour() != clock.Hour() || t.Minute() != clock.Minute() {
+				skipped := time.Duration(clock.Hour()-t.Hour())*time.Hour + time.Duration(clock.Minute()-t.Minute())*time.Minute
+				t = t.Add(skipped)
+			}
+			if t.After(now) {
+				if next.IsZero() || t.Before(next) {
+					next = t
+				}
+				break
+			}
+		}
+	}
+	return next, nil
+}
+
+// Deprecation marks a service as on its way out, so people move off it before
+// it goes: `pf run` warns about it and the TUI flags it. Every field is
+// optional. Sunset is the date it goes away, as YYYY-MM-DD.
+type Deprecation struct {
+	Replacement string `json:"replacement,omitempty"`
+	Sunset      string `json:"sunset,omitempty"`
+	Note        string `json:"note,omitempty"`
+}
+
+// SunsetLayout is the date format of Deprecation.Sunset.
+const SunsetLayout = "2006-01-02"
+
+// Validate checks the sunset date.
+func (d Deprecation) Validate() error {
+	if d.Sunset == "" {
+		return nil
+	}
+	if _, err := time.Parse(SunsetLayout, d.Sunset); err != nil {
+		return fmt.Errorf("invalid sunset %q (use YYYY-MM-DD)", d.Sunset)
+	}
+	return nil
+}
+
+// Describe renders the deprecation as of now, e.g. "deprecated: use db-v2
+// instead, goes away on 2026-12-31".
+func (d Deprecation) Describe(now time.Time) string {
+	text := "deprecated"
+	if d.Replacement != "" {
+		text += ": use " + d.Replacement + " instead"
+	}
+	if d.Sunset != "" {
+		sep := ", "
+		if d.Replacement == "" {
+			sep = ": "
+		}
+		if sunset, err := time.ParseInLocation(SunsetLayout, d.Sunset, now.Location()); err == nil && !now.Before(sunset) {
+			text += sep + "past its sunset on " + d.Sunset
+		} else {
+			text += sep + "goes away on " + d.Sunset
+		}
+	}
+	if d.Note != "" {
+		text += " (" + d.Note + ")"
+	}
+	return text
+}
+
+// Limits caps what a service's child process (kubectl, ssh) may use, so a
+// runaway one cannot drag the workstation down. Nice lowers its CPU priority
+// (0-19); Memory caps its data segment, e.g. "512M"; Files caps its open file
+// descriptors. Memory and Files only apply on Unix; on Windows Nice picks a
+// lower priority class.
+type Limits struct {
+	Nice   int    `json:"nice,omitempty"`
+	Memory string `json:"memory,omitempty"`
+	Files  int    `json:"files,omitempty"`
+}
+
+// Validate checks the ranges and the memory size.
+func (l Limits) Validate() error {
+	if l.Nice < 0 || l.Nice > 19 {
+		return fmt.Errorf("limits: nice must be between 0 and 19, not %d", l.Nice)
+	}
+	if l.Files < 0 {
+		return fmt.Errorf("limits: files must not be negative")
+	}
+	_, err := l.MemoryBytes()
+	return err
+}
+
+// MemoryBytes parses Memory: a number of bytes with an optional K, M or G
+// suffix (powers of 1024; "Mi", "MB" and "MiB" mean the same). 0 means no
+// limit.
+func (l Limits) MemoryBytes() (int64, error) {
+	if l.Memory == "" {
+		return 0, nil
+	}
+	value := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(l.Memory)), "B"), "I")
+	shift := 0
+	if n := len(value); n > 0 {
+		switch value[n-1] {
+		case 'K':
+			shift = 10
+		case 'M':
+			shift = 20
+		case 'G':
+			shift = 30
+		}
+		if shift > 0 {
+			value = value[:n-1]
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 || n > math.MaxInt64>>shift {
+		return 0, fmt.Errorf("limits: invalid memory %q (use e.g. 512M or 2G)", l.Memory)
+	}
+	return n << shift, nil
+}
+
+// Variant replaces a service's command on some machines only, e.g. where
+// Windows needs another ssh binary: OS is a Go OS name ("windows", "darwin",
+// "linux") and Host a host name or a pattern such as "build-*", matched
+// without case; a variant with both needs both. It must forward the same local
+// port as the command it replaces.
+type Variant struct {
+	OS      string `json:"os,omitempty"`
+	Host    string `json:"host,omitempty"`
+	Command string `json:"command"`
+}
+
+// knownOS are the Go OS names a variant may name.
+var knownOS = []string{"aix", "android", "darwin", "dragonfly", "freebsd", "illumos", "ios", "linux", "netbsd", "openbsd", "plan9", "solaris", "windows"}
+
+// Validate checks that the variant says where it applies and runs something.
+func (v Variant) Validate() error {
+	if v.OS == "" && v.Host == "" {
+		return fmt.Errorf("variant: needs an os, a host or both")
+	}
+	if v.OS != "" && !slices.Contains(knownOS, v.OS) {
+		return fmt.Errorf("variant: unknown os %q (use a Go OS name such as linux, darwin or windows)", v.OS)
+	}
+	if _, err := path.Match(v.Host, ""); err != nil {
+		return fmt.Errorf("variant: invalid host pattern %q", v.Host)
+	}
+	if strings.TrimSpace(v.Command) == "" {
+		return fmt.Errorf("variant: command is empty")
+	}
+	return nil
+}
+
+// Matches reports whether the variant applies on a machine running goos with
+// host name host. A pattern is tried on the full host name and on its first
+// label, so "laptop" matches "laptop.local" too.
+func (v Variant) Matches(goos, host string) bool {
+	if v.OS != "" && v.OS != goos {
+		return false
+	}
+	if v.Host == "" {
+		return true
+	}
+	pattern, host := strings.ToLower(v.Host), strings.ToLower(host)
+	short, _, _ := strings.Cut(host, ".")
+	full, _ := path.Match(pattern, host)
+	first, _ := path.Match(pattern, short)
+	return full || first
+}
+
+// SelectVariant returns the command of the first of variants that matches
+// goos and host, or command when none does.
+func SelectVariant(command string, variants []Variant, goos, host string) string {
+	for _, v := range variants {
+		if v.Matches(goos, host) {
+			return v.Command
+		}
+	}
+	return command
+}
+
+// Override is this machine's own change to a service, kept in
+// services.local.json next to services.json so it never travels with the
+// shared config or a catalog. Command replaces the service's command and its
+// variants; LocalPort moves the forward to another local port, and Context
+// points kubectl at another kube context.
+type Override struct {
+	Command   string `json:"command,omitempty"`
+	LocalPort int    `json:"localPort,omitempty"`
+	Context   string `json:"context,omitempty"`
+}
+
+// localOverrides is what services.local.json holds.
+type localOverrides struct {
+	Overrides map[string]Override `json:"overrides"`
+}
+
+// Validate checks that the override changes something, and its port.
+func (o Override) Validate() error {
+	if strings.TrimSpace(o.Command) == "" && o.LocalPort == 0 && o.Context == "" {
+		return fmt.Errorf("override: needs a command, a local port or a context")
+	}
+	if o.LocalPort < 0 || o.LocalPort > 65535 {
+		return fmt.Errorf("override: local port %d is not a valid port", o.LocalPort)
+	}
+	if strings.ContainsAny(o.Context, " \t\"'") {
+		return fmt.Errorf("override: invalid context %q", o.Context)
+	}
+	return nil
+}
+
+// Describe renders the override for listings, e.g. "local port 15432,
+// context kind-dev".
+func (o Override) Describe() string {
+	var parts []string
+	if o.Command != "" {
+		parts = append(parts, "runs "+o.Command)
+	}
+	if o.LocalPort != 0 {
+		parts = append(parts, fmt.Sprintf("local port %d", o.LocalPort))
+	}
+	if o.Context != "" {
+		parts = append(parts, "context "+o.Context)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Apply returns command with the override's local port and context. Command
+// is left to the caller, since a service's alternates and fallback keep their
+// own commands but get the same port and context.
+func (o Override) Apply(command string) (string, error) {
+	if o.LocalPort == 0 && o.Context == "" {
+		return command, nil
+	}
+	if cmdline.IsMonitor(command) {
+		return "", fmt.Errorf("a monitor has no local port or context")
+	}
+	var err error
+	if o.LocalPort != 0 {
+		if command, err = cmdline.SetLocalPort(command, strconv.Itoa(o.LocalPort)); err != nil {
+			return "", err
+		}
+	}
+	if o.Context != "" {
+		if command, err = cmdline.SetContext(command, o.Context); err != nil {
+			return "", err
+		}
+	}
+	return command, nil
+}
+
+// ParamKeys are the fields of a service a run can set for its session only,
+// with `pf run --set key=value`.
+var ParamKeys = []string{"namespace", "context", "local-port", "remote-port"}
+
+// ValidateParam checks a --set key and its value.
+func ValidateParam(key, value string) error {
+	switch key {
+	case "namespace", "context":
+		if value == "" || strings.ContainsAny(value, " \t\"'") {
+			return fmt.Errorf("invalid %s %q", key, value)
+		}
+	case "local-port", "remote-port":
+		if port, err := strconv.Atoi(value); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("%s %q is not a valid port", key, value)
+		}
+	default:
+		return fmt.Errorf("unknown parameter %q (use %s)", key, strings.Join(ParamKeys, ", "))
+	}
+	return nil
+}
+
+// ApplyParams returns command with the fields params sets, by ParamKeys key.
+func ApplyParams(command string, params map[string]string) (string, error) {
+	if len(params) == 0 {
+		return command, nil
+	}
+	if cmdline.IsMonitor(command) {
+		return "", fmt.Errorf("a monitor has no parameters to set")
+	}
+	for key, value := range params {
+		if err := ValidateParam(key, value); err != nil {
+			return "", err
+		}
+	}
+	setters := map[string]func(string, string) (string, error){
+		"namespace":   cmdline.SetNamespace,
+		"context":     cmdline.SetContext,
+		"local-port":  cmdline.SetLocalPort,
+		"remote-port": cmdline.SetRemotePort,
+	}
+	for _, key := range ParamKeys {
+		value, ok := params[key]
+		if !ok {
+			continue
+		}
+		var err error
+		if command, err = setters[key](command, value); err != nil {
+			return "", fmt.Errorf("%s: %v", key, err)
+		}
+	}
+	return command, nil
+}
+
+// OverridesFile is services.local.json, next to services.json.
+func (s *Storage) OverridesFile() string {
+	return filepath.Join(filepath.Dir(s.filePath), "services.local.json")
+}
+
+// Overrides returns this machine's overrides from services.local.json, by
+// service name; nil when the file does not exist.
+func (s *Storage) Overrides() (map[string]Override, error) {
+	data, err := os.ReadFile(s.OverridesFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var local localOverrides
+	if err := json.Unmarshal(data, &local); err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(s.OverridesFile()), err)
+	}
+	return local.Overrides, nil
+}
+
+// SetOverride saves this machine's override of a service, local or from a
+// catalog, or with nil removes it.
+func (s *Storage) SetOverride(name string, o *Override) error {
+	overrides, err := s.Overrides()
+	if err != nil {
+		return err
+	}
+	if o == nil {
+		if _, ok := overrides[name]; !ok {
+			return fmt.Errorf("service '%s' has no local override", name)
+		}
+		delete(overrides, name)
+		return s.writeOverrides(overrides)
+	}
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	command, err := s.GetService(name)
+	if err != nil {
+		return err
+	}
+	if o.Command != "" {
+		command = o.Command
+	}
+	if _, err := o.Apply(command); err != nil {
+		return err
+	}
+	if overrides == nil {
+		overrides = map[string]Override{}
+	}
+	overrides[name] = *o
+	return s.writeOverrides(overrides)
+}
+
+// moveOverride carries a renamed or deleted service's override along; an
+// empty newName drops it.
+func (s *Storage) moveOverride(oldName, newName string) error {
+	overrides, err := s.Overrides()
+	if err != nil {
+		return err
+	}
+	o, ok := overrides[oldName]
+	if !ok {
+		return nil
+	}
+	delete(overrides, oldName)
+	if newName != "" {
+		overrides[newName] = o
+	}
+	return s.writeOverrides(overrides)
+}
+
+func (s *Storage) writeOverrides(overrides map[string]Override) error {
+	if len(overrides) == 0 {
+		if err := os.Remove(s.OverridesFile()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(localOverrides{Overrides: overrides}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.OverridesFile(), data, 0o600)
+}
+
+// DefaultFallbackAfter is how many failed runs in a row switch a service to
+// its fallback command when the config does not say.
+const DefaultFallbackAfter = 3
+
+// RelayConfig puts pf's own listener in front of a service: clients connect to
+// Listen (a port, or host:port) and pf relays to the forward's local port.
+// With HTTP set the relay is an inspecting proxy that logs every request's
+// method, path, status and latency to the service log. Hold keeps clients
+// that arrive while the forward is down waiting for it, up to that long
+// (e.g. "10s"), instead of cutting them off. HoldConnections goes further
+// for TCP relays: a connected client that awaits no answer when the forward
+// drops is kept and moved to the reconnected forward (within Hold). With DNS
+// set the relay also answers DNS over UDP on Listen, asking the forwarded
+// resolver over TCP, since port forwards carry TCP only.
+type RelayConfig struct {
+	Listen          string         `json:"listen"`
+	HTTP            bool           `json:"http,omitempty"`
+	DNS             bool           `json:"dns,omitempty"`
+	Hold            string         `json:"hold,omitempty"`
+	HoldConnections bool           `json:"holdConnections,omitempty"`
+	Rewrite         *RewriteConfig `json:"rewrite,omitempty"` // HTTP only
+}
+
+// HoldDuration parses Hold; "" is 0.
+func (r RelayConfig) HoldDuration() (time.Duration, error) {
+	if r.Hold == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(r.Hold)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid relay hold %q", r.Hold)
+	}
+	return d, nil
+}
+
+// RewriteConfig edits the headers an HTTP relay passes through, standing in
+// for a local nginx in front of the backend.
+type RewriteConfig struct {
+	Host         string            `json:"host,omitempty"`         // Host header sent upstream
+	Headers      map[string]string `json:"headers,omitempty"`      // request headers to set
+	Auth         *KeyringHeader    `json:"auth,omitempty"`         // a header whose value lives in the keyring
+	StripCookies bool              `json:"stripCookies,omitempty"` // drop Cookie and Set-Cookie
+}
+
+// ChaosConfig degrades a relayed service's traffic on purpose, set by
+// `pf chaos` to test apps against a poor tunnel.
+type ChaosConfig struct {
+	Latency   string  `json:"latency,omitempty"`   // added to every chunk each way, e.g. "200ms"
+	Jitter    string  `json:"jitter,omitempty"`    // random extra delay, up to this
+	Bandwidth int64   `json:"bandwidth,omitempty"` // bytes per second per connection and direction
+	Drop      float64 `json:"drop,omitempty"`      // percent chance that a chunk cuts its connection
+}
+
+// Durations parses the latency and jitter.
+func (c ChaosConfig) Durations() (latency, jitter time.Duration, err error) {
+	if c.Latency != "" {
+		if latency, err = time.ParseDuration(c.Latency); err != nil || latency < 0 {
+			return 0, 0, fmt.Errorf("invalid chaos latency %q", c.Latency)
+		}
+	}
+	if c.Jitter != "" {
+		if jitter, err = time.ParseDuration(c.Jitter); err != nil || jitter < 0 {
+			return 0, 0, fmt.Errorf("invalid chaos jitter %q", c.Jitter)
+		}
+	}
+	if c.Bandwidth < 0 || c.Drop < 0 || c.Drop > 100 {
+		return 0, 0, fmt.Errorf("chaos bandwidth must not be negative and drop must be 0-100%%")
+	}
+	return latency, jitter, nil
+}
+
+// KeyringHeader is a request header set to Prefix plus the OS keyring secret
+// stored under Service and Account, e.g. "Authorization: Bearer <token>".
+type KeyringHeader struct {
+	Header  string `json:"header,omitempty"` // default "Authorization"
+	Prefix  string `json:"prefix,omitempty"` // e.g. "Bearer "
+	Service string `json:"service"`
+	Account string `json:"account"`
+}
+
+// Revision is an earlier command of a service, kept in its history when the
+// command was replaced at Replaced.
+type Revision struct {
+	Command  string    `json:"command"`
+	Replaced time.Time `json:"replaced"`
+}
+
+// maxRevisions is how many earlier commands a service's history keeps.
+const maxRevisions = 20
+
+// CatalogConfig is a read-only service catalog published at URL (see package
+// catalog). Its services and groups are run as Name/<service> and
+// Name/<group>; local entries never change it. With Allow set, its services
+// may only start those programs, apart from the commands in Trusted (by
+// service), approved as they were with `pf catalog trust`. With PublicKey set
+// (a minisign public key), a sync only accepts the catalog if URL.minisig
+// holds a valid signature by that key.
+type CatalogConfig struct {
+	Name      string            `json:"name"`
+	URL       string            `json:"url"`
+	PublicKey string            `json:"publicKey,omitempty"`
+	Allow     []string          `json:"allow,omitempty"`
+	Trusted   map[string]string `json:"trusted,omitempty"`
+}
+
+// Policy returns the catalog's command policy.
+func (c CatalogConfig) Policy() catalog.Policy {
+	return catalog.Policy{Allow: c.Allow, Trusted: c.Trusted}
+}
+
 type StorageData struct {
 	Services map[string]string    `json:"services"`
 	Groups   map[string][]string  `json:"groups"`
 	Icon     *IconConfig          `json:"icon,omitempty"`
 	Theme    string               `json:"theme,omitempty"`
 	Themes   map[string]ThemeSpec `json:"themes,omitempty"`
-	Legacy   map[string]string    `json:"-"`
+	Keymap   map[string][]string  `json:"keymap,omitempty"`
+	Confirm  map[string]bool      `json:"confirm,omitempty"`
+	EnvFile  string               `json:"envFile,omitempty"`
+	Notify   []NotifierConfig     `json:"notify,omitempty"`
+	Flap     *FlapConfig          `json:"flap,omitempty"`
+	// Shutdown orders and times how services stop when a session ends.
+	Shutdown *ShutdownConfig `json:"shutdown,omitempty"`
+	// DefaultGroup is the group a bare `pf run` starts; see SetDefaultGroup.
+	DefaultGroup string `json:"defaultGroup,omitempty"`
+	// MetricsFile is where sessions append their services' reliability
+	// figures on exit; see MetricsFile.
+	MetricsFile string `json:"metricsFile,omitempty"`
+	// MergeForwards runs kubectl port-forwards to the same resource in one
+	// kubectl; see cmdline.MergeablePortForward.
+	MergeForwards bool `json:"mergeForwards,omitempty"`
+	// Maintenance maps a service to the end of its maintenance window, set
+	// by `pf maintenance`; running sessions poll it.
+	Maintenance map[string]time.Time `json:"maintenance,omitempty"`
+	// Alternates maps a service to more commands reaching the same backend
+	// another way (a replica, another bastion), tried in order when the
+	// current one cannot connect. They must forward the same local port.
+	Alternates map[string][]string `json:"alternates,omitempty"`
+	// Fallback maps a service to a last-resort command used once its own
+	// (and alternates) keep failing; see FallbackConfig.
+	Fallback map[string]FallbackConfig `json:"fallback,omitempty"`
+	// Relay maps a service to a relay in front of it; see RelayConfig.
+	Relay map[string]RelayConfig `json:"relay,omitempty"`
+	// Chaos maps a relayed service to the degradation `pf chaos` injects;
+	// running sessions poll it.
+	Chaos map[string]ChaosConfig `json:"chaos,omitempty"`
+	// WaitFor maps a service to conditions that must all hold before its
+	// forward starts or reconnects.
+	WaitFor map[string][]WaitCondition `json:"waitFor,omitempty"`
+	// PreConnect maps a service to a command run before each of its
+	// connection attempts; see PreConnect.
+	PreConnect map[string]PreConnect `json:"preConnect,omitempty"`
+	// PostConnect maps a service to a command run once it is first healthy
+	// in a session; see PostConnect.
+	PostConnect map[string]PostConnect `json:"postConnect,omitempty"`
+	// RemoteCheck maps a service to a check of its forward's far end; see
+	// RemoteCheck.
+	RemoteCheck map[string]RemoteCheck `json:"remoteCheck,omitempty"`
+	// DNS maps an ssh service to how its forward's remote host is resolved;
+	// see DNS.
+	DNS map[string]DNS `json:"dns,omitempty"`
+	// Keepalive maps a service to the ssh keepalive settings it uses
+	// instead of the defaults; see Keepalive.
+	Keepalive map[string]Keepalive `json:"keepalive,omitempty"`
+	// OTP maps a service to the one-time codes it answers its bastion's
+	// prompts with; see OTP.
+	OTP map[string]OTP `json:"otp,omitempty"`
+	// HostKeyChecking maps a service to its policy for unknown ssh host
+	// keys; see HostKeyChecking.
+	HostKeyChecking map[string]HostKeyChecking `json:"hostKeyChecking,omitempty"`
+	// Deprecated maps a service to its deprecation, set by `pf deprecate`.
+	Deprecated map[string]Deprecation `json:"deprecated,omitempty"`
+	// Schedule maps a service to the times it is restarted at while it
+	// runs; see RestartSchedule.
+	Schedule map[string]RestartSchedule `json:"schedule,omitempty"`
+	// Limits maps a service to resource limits for its child process.
+	Limits map[string]Limits `json:"limits,omitempty"`
+	// History maps a service to its earlier commands, oldest first; see
+	// Revision.
+	History map[string][]Revision `json:"history,omitempty"`
+	// Variants maps a service to the commands it runs instead on some
+	// machines; the first that matches wins. See Variant.
+	Variants map[string][]Variant `json:"variants,omitempty"`
+	// Enabled maps a service to false once `pf disable` hides it from
+	// "all", group runs and the TUI's add list; it stays saved. Enabled
+	// services have no entry.
+	Enabled map[string]bool `json:"enabled,omitempty"`
+	// Labels maps a service to its labels, e.g. {"team": "payments"}, which
+	// "label:team=payments" selects as a virtual group.
+	Labels map[string]map[string]string `json:"labels,omitempty"`
+	// Ephemeral marks the services `pf ephemeral add` made, which are
+	// deleted when they expire; see Ephemeral.
+	Ephemeral map[string]Ephemeral `json:"ephemeral,omitempty"`
+	Catalogs  []CatalogConfig      `json:"catalogs,omitempty"`
+	Legacy    map[string]string    `json:"-"`
+}
+
+// ErrNotFound is what a lookup of a service or group that is not defined
+// wraps; test for it with errors.Is.
+var ErrNotFound = errors.New("not found")
+
+type Storage struct {
+	filePath string
+}
+
+func NewStorage() *Storage {
+	newPath, ok := configStoragePath()
+	if !ok {
+		return &Storage{filePath: legacyStoragePath()}
+	}
+
+	if old := legacyStoragePath(); old != "" {
+		migrateLegacyStorage(newPath, old)
+	}
+
+	return &Storage{filePath: newPath}
+}
+
+func configStoragePath() (string, bool) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", false
+	}
+	configDir := filepath.Join(homeDir, ".pf")
+	if err := os.MkdirAll(configDir, 0700); err != nil {
+		return "", false
+	}
+	return filepath.Join(configDir, "services.json"), true
+}
+
+func legacyStoragePath() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(exe), "services.json")
 }
 
-type Storage struct {
-	filePath string
+func migrateLegacyStorage(newPath, oldPath string) {
+	if newPath == oldPath || oldPath == "" {
+		return
+	}
+	if _, err := os.Stat(newPath); err == nil {
+		return
+	}
+	data, err := os.ReadFile(oldPath)
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(newPath, data, 0600)
+}
+
+func (s *Storage) Path() string {
+	return s.filePath
+}
+
+// SaveData replaces the whole config, keeping the earlier command of every
+// service whose command it changes.
+func (s *Storage) SaveData(data *StorageData) error {
+	if prev, err := s.readStorage(); err == nil {
+		keepHistory(prev.Services, data, time.Now())
+	}
+	return s.writeStorage(data)
+}
+
+func (s *Storage) LoadData() (*StorageData, error) {
+	return s.readStorage()
+}
+
+// ThemeName returns the saved theme name ("" when none is set, meaning default).
+func (s *Storage) ThemeName() (string, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return "", err
+	}
+	return data.Theme, nil
+}
+
+// RegisterCustomThemes loads user-defined palettes from the config's "themes"
+// map and registers them with the theme package so they can be selected by name
+// like the built-ins. Each spec is layered over the built-in "default" palette,
+// so omitted colors inherit sensible values. A missing or empty "themes" key is
+// a no-op, keeping configs without custom themes fully backward compatible.
+// Best-effort and idempotent: safe to call more than once.
+func (s *Storage) RegisterCustomThemes() error {
+	data, err := s.readStorage()
+	if err != nil {
+		return err
+	}
+	for name, spec := range data.Themes {
+		theme.Register(customPalette(name, spec))
+	}
+	return nil
+}
+
+// customPalette builds a theme palette for name by overlaying the non-empty
+// fields of spec onto the built-in "default" palette.
+func customPalette(name string, spec ThemeSpec) theme.Palette {
+	p, _ := theme.Get("default")
+	p.Name = name
+	for _, f := range []struct {
+		val string
+		dst *string
+	}{
+		{spec.Text, &p.Text},
+		{spec.Muted, &p.Muted},
+		{spec.Border, &p.Border},
+		{spec.Accent, &p.Accent},
+		{spec.AccentAlt, &p.AccentAlt},
+		{spec.Warn, &p.Warn},
+		{spec.Error, &p.Error},
+		{spec.Heading, &p.Heading},
+		{spec.Selected, &p.Selected},
+	} {
+		if f.val != "" {
+			*f.dst = f.val
+		}
+	}
+	return p
+}
+
+// SetTheme persists the selected theme name, preserving the rest of the config.
+func (s *Storage) SetTheme(name string) error {
+	data, err := s.readStorage()
+	if err != nil {
+		return err
+	}
+	data.Theme = name
+	return s.writeStorage(data)
+}
+
+// Keymap returns the user's key binding overrides from the config's "keymap"
+// section (action name → keys). Nil when the section is absent.
+func (s *Storage) Keymap() (map[string][]string, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return nil, err
+	}
+	return data.Keymap, nil
+}
+
+// Notifiers returns the config's "notify" list; nil when notifications are
+// not configured.
+func (s *Storage) Notifiers() ([]NotifierConfig, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return nil, err
+	}
+	return data.Notify, nil
+}
+
+// FlapSettings returns the config's "flap" thresholds; zeros when unset, for
+// the caller to replace with its defaults.
+func (s *Storage) FlapSettings() (int, time.Duration, error) {
+	data, err := s.readStorage()
+	if err != nil || data.Flap == nil {
+		return 0, 0, err
+	}
+	var window time.Duration
+	if data.Flap.Window != "" {
+		window, err = time.ParseDuration(data.Flap.Window)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid flap window %q", data.Flap.Window)
+		}
+	}
+	return data.Flap.Errors, window, nil
+}
+
+// Shutdown returns the config's "shutdown" settings, checked; the zero value
+// when unset.
+func (s *Storage) Shutdown() (ShutdownConfig, error) {
+	data, err := s.readStorage()
+	if err != nil || data.Shutdown == nil {
+		return ShutdownConfig{}, err
+	}
+	if err := data.Shutdown.Validate(); err != nil {
+		return ShutdownConfig{}, err
+	}
+	return *data.Shutdown, nil
+}
+
+// Alternates returns the service's alternate commands, in the order to try
+// them after its own, with the local port and context of its override.
+func (s *Storage) Alternates(name string) ([]string, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return nil, err
+	}
+	return s.applyOverride(name, data.Alternates[name])
+}
+
+// applyOverride moves commands (a service's alternates or fallback) to the
+// local port and context of the service's override, if it has one.
+func (s *Storage) applyOverride(name string, commands []string) ([]string, error) {
+	overrides, err := s.Overrides()
+	if err != nil {
+		return nil, err
+	}
+	o, ok := overrides[name]
+	if !ok {
+		return commands, nil
+	}
+	applied := make([]string, len(commands))
+	for i, command := range commands {
+		if applied[i], err = o.Apply(command); err != nil {
+			return nil, fmt.Errorf("service '%s' in services.local.json: %v", name, err)
+		}
+	}
+	return applied, nil
+}
+
+// Catalogs returns the configured remote catalogs.
+func (s *Storage) Catalogs() ([]CatalogConfig, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return nil, err
+	}
+	return data.Catalogs, nil
+}
+
+// CatalogDir is where the local copies of remote catalogs are kept.
+func (s *Storage) CatalogDir() string {
+	return filepath.Join(filepath.Dir(s.filePath), "catalogs")
+}
+
+// HintsFile is the user's own error hint rules (see package hints).
+func (s *Storage) HintsFile() string {
+	return filepath.Join(filepath.Dir(s.filePath), "hints.json")
+}
+
+// SplitCatalogName splits a catalog entry's name, "corp/db", into the
+// catalog and the entry; ok is false for a local name.
+func SplitCatalogName(name string) (catalogName, entry string, ok bool) {
+	return strings.Cut(name, "/")
+}
+
+// remoteCatalog returns the local copy of the configured catalog that name
+// belongs to, and name within it. A copy the catalog's public key did not
+// verify counts as none (see catalog.ErrUnverified).
+func (s *Storage) remoteCatalog(data *StorageData, name string) (catalog.Catalog, string, bool) {
+	catalogName, entry, ok := SplitCatalogName(name)
+	if !ok {
+		return catalog.Catalog{}, "", false
+	}
+	for _, c := range data.Catalogs {
+		if c.Name == catalogName {
+			remote, _, err := catalog.Load(s.CatalogDir(), catalogName, c.PublicKey)
+			return remote, entry, err == nil
+		}
+	}
+	return catalog.Catalog{}, "", false
+}
+
+// RemoteServices returns the services of every remote catalog that has a
+// local copy, by their catalog names.
+func (s *Storage) RemoteServices() (map[string]string, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return nil, err
+	}
+	services := map[string]string{}
+	for _, c := range data.Catalogs {
+		remote, _, err := catalog.Load(s.CatalogDir(), c.Name, c.PublicKey)
+		if err != nil {
+			continue
+		}
+		for name, command := range remote.Services {
+			services[c.Name+"/"+name] = command
+		}
+	}
+	return services, nil
+}
+
+// Deprecation returns the service's deprecation, if it is deprecated. A remote
+// catalog's service is deprecated by its catalog, with the replacement named
+// as "catalog/service" like the service itself.
+func (s *Storage) Deprecation(name string) (Deprecation, bool, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return Deprecation{}, false, err
+	}
+	if d, ok := data.Deprecated[name]; ok {
+		return d, true, nil
+	}
+	if remote, entry, ok := s.remoteCatalog(data, name); ok {
+		if d, ok := remote.Deprecated[entry]; ok {
+			dep := Deprecation(d)
+			if dep.Replacement != "" {
+				catalogName, _, _ := SplitCatalogName(name)
+				dep.Replacement = catalogName + "/" + dep.Replacement
+			}
+			return dep, true, nil
+		}
+	}
+	return Deprecation{}, false, nil
+}
+
+// SetDeprecation deprecates a saved service, or with nil takes the
+// deprecation back.
+func (s *Storage) SetDeprecation(name string, d *Deprecation) error {
+	data, err := s.readStorage()
+	if err != nil {
+		return err
+	}
+	if _, exists := data.Services[name]; !exists {
+		return fmt.Errorf("service '%s' %w", name, ErrNotFound)
+	}
+	if d == nil {
+		delete(data.Deprecated, name)
+		return s.writeStorage(data)
+	}
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	if data.Deprecated == nil {
+		data.Deprecated = map[string]Deprecation{}
+	}
+	data.Deprecated[name] = *d
+	return s.writeStorage(data)
+}
+
+// WaitFor returns the conditions the service waits for before it starts.
+func (s *Storage) WaitFor(name string) ([]WaitCondition, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return nil, err
+	}
+	return data.WaitFor[name], nil
+}
+
+// PreConnect returns the command the service runs before each connection
+// attempt, if it has one.
+func (s *Storage) PreConnect(name string) (PreConnect, bool, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return PreConnect{}, false, err
+	}
+	p, ok := data.PreConnect[name]
+	return p, ok, nil
+}
+
+// OTP returns the service's one-time code generator, if it has one.
+func (s *Storage) OTP(name string) (OTP, bool, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return OTP{}, false, err
+	}
+	o, ok := data.OTP[name]
+	return o, ok, nil
+}
+
+// PostConnect returns the command the service runs once it is first healthy,
+// if it has one.
+func (s *Storage) PostConnect(name string) (PostConnect, bool, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return PostConnect{}, false, err
+	}
+	p, ok := data.PostConnect[name]
+	return p, ok, nil
+}
+
+// RemoteCheck returns how the far end of the service's forward is checked, if
+// it is.
+func (s *Storage) RemoteCheck(name string) (RemoteCheck, bool, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return RemoteCheck{}, false, err
+	}
+	r, ok := data.RemoteCheck[name]
+	return r, ok, nil
+}
+
+// DNS returns how the remote host of the service's forward is resolved, if
+// the config says.
+func (s *Storage) DNS(name string) (DNS, bool, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return DNS{}, false, err
+	}
+	d, ok := data.DNS[name]
+	return d, ok, nil
+}
+
+// Keepalive returns the service's ssh keepalive settings; the zero Keepalive,
+// meaning the defaults, when the config does not tune them.
+func (s *Storage) Keepalive(name string) (Keepalive, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return Keepalive{}, err
+	}
+	return data.Keepalive[name], nil
+}
+
+// HostKeyChecking returns the service's host key policy; "" when the config
+// leaves it to ssh_config.
+func (s *Storage) HostKeyChecking(name string) (HostKeyChecking, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return "", err
+	}
+	return data.HostKeyChecking[name], nil
+}
+
+// RestartSchedule returns the service's restart schedule, if it has one.
+func (s *Storage) RestartSchedule(name string) (RestartSchedule, bool, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return RestartSchedule{}, false, err
+	}
+	r, ok := data.Schedule[name]
+	return r, ok, nil
+}
+
+// ServiceLimits returns the resource limits of the service's child process;
+// zero when it has none.
+func (s *Storage) ServiceLimits(name string) (Limits, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return Limits{}, err
+	}
+	return data.Limits[name], nil
+}
+
+// LocalCommand returns the command the service runs on this machine: its
+// first variant for this OS and host name, or its command, with this
+// machine's override from services.local.json. A remote catalog's variant
+// must pass the catalog's allow list like its command; an override is the
+// user's own and need not.
+func (s *Storage) LocalCommand(name string) (string, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return "", err
+	}
+	overrides, err := s.Overrides()
+	if err != nil {
+		return "", err
+	}
+	goos, host := localMachine()
+	if cmd, exists := data.Services[name]; exists {
+		return overrideCommand(name, SelectVariant(cmd, data.Variants[name], goos, host), overrides)
+	}
+	if remote, entry, ok := s.remoteCatalog(data, name); ok {
+		if cmd, exists := remote.Services[entry]; exists {
+			cmd = SelectVariant(cmd, catalogVariants(remote.Variants[entry]), goos, host)
+			if err := checkCatalogPolicy(data, name, cmd); err != nil && overrides[name].Command == "" {
+				return "", err
+			}
+			return overrideCommand(name, cmd, overrides)
+		}
+	}
+	return "", fmt.Errorf("service '%s' %w", name, ErrNotFound)
+}
+
+// LocalServices returns the saved services as LoadServices does, but with
+// the command each runs on this machine. A service whose override cannot be
+// applied keeps its command.
+func (s *Storage) LocalServices() (map[string]string, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := s.Overrides()
+	if err != nil {
+		return nil, err
+	}
+	goos, host := localMachine()
+	services := make(map[string]string, len(data.Services))
+	for name, command := range data.Services {
+		command = SelectVariant(command, data.Variants[name], goos, host)
+		if overridden, err := overrideCommand(name, command, overrides); err == nil {
+			command = overridden
+		}
+		services[name] = command
+	}
+	return services, nil
 }
 
-func NewStorage() *Storage {
-	newPath, ok := configStoragePath()
+// overrideCommand applies name's override, if it has one, to command.
+func overrideCommand(name, command string, overrides map[string]Override) (string, error) {
+	o, ok := overrides[name]
 	if !ok {
-		return &Storage{filePath: legacyStoragePath()}
+		return command, nil
+	}
+	if o.Command != "" {
+		command = o.Command
+	}
+	command, err := o.Apply(command)
+	if err != nil {
+		return "", fmt.Errorf("service '%s' in services.local.json: %v", name, err)
 	}
+	return command, nil
+}
 
-	if old := legacyStoragePath(); old != "" {
-		migrateLegacyStorage(newPath, old)
+// localMachine returns the OS and host name variants are matched against.
+func localMachine() (string, string) {
+	host, _ := os.Hostname()
+	return runtime.GOOS, host
+}
+
+func catalogVariants(variants []catalog.Variant) []Variant {
+	converted := make([]Variant, len(variants))
+	for i, v := range variants {
+		converted[i] = Variant(v)
 	}
+	return converted
+}
 
-	return &Storage{filePath: newPath}
+// Fallback returns the service's fallback command, if it has one, with After
+// defaulted and the service's override applied.
+func (s *Storage) Fallback(name string) (FallbackConfig, bool, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return FallbackConfig{}, false, err
+	}
+	fb, ok := data.Fallback[name]
+	if !ok || strings.TrimSpace(fb.Command) == "" {
+		return FallbackConfig{}, false, nil
+	}
+	if fb.After <= 0 {
+		fb.After = DefaultFallbackAfter
+	}
+	commands, err := s.applyOverride(name, []string{fb.Command})
+	if err != nil {
+		return FallbackConfig{}, false, err
+	}
+	fb.Command = commands[0]
+	return fb, true, nil
 }
 
-func configStoragePath() (string, bool) {
-	homeDir, err := os.UserHomeDir()
+// Relay returns the service's relay, if it has one.
+func (s *Storage) Relay(name string) (RelayConfig, bool, error) {
+	data, err := s.readStorage()
 	if err != nil {
-		return "", false
+		return RelayConfig{}, false, err
 	}
-	configDir := filepath.Join(homeDir, ".pf")
-	if err := os.MkdirAll(configDir, 0700); err != nil {
-		return "", false
+	r, ok := data.Relay[name]
+	if !ok || strings.TrimSpace(r.Listen) == "" {
+		return RelayConfig{}, false, nil
 	}
-	return filepath.Join(configDir, "services.json"), true
+	return r, true, nil
 }
 
-func legacyStoragePath() string {
-	exe, err := os.Executable()
+// Chaos returns the chaos settings of every service that has them.
+func (s *Storage) Chaos() (map[string]ChaosConfig, error) {
+	data, err := s.readStorage()
 	if err != nil {
-		return ""
+		return nil, err
 	}
-	return filepath.Join(filepath.Dir(exe), "services.json")
+	return data.Chaos, nil
 }
 
-func migrateLegacyStorage(newPath, oldPath string) {
-	if newPath == oldPath || oldPath == "" {
-		return
+// SetChaos sets a service's chaos settings; the zero value turns chaos off.
+// Only relayed services can have them, since pf sees no other traffic.
+func (s *Storage) SetChaos(name string, cfg ChaosConfig) error {
+	data, err := s.readStorage()
+	if err != nil {
+		return err
 	}
-	if _, err := os.Stat(newPath); err == nil {
-		return
+	if _, exists := data.Services[name]; !exists {
+		return fmt.Errorf("service '%s' %w", name, ErrNotFound)
 	}
-	data, err := os.ReadFile(oldPath)
-	if err != nil {
-		return
+	if cfg == (ChaosConfig{}) {
+		delete(data.Chaos, name)
+		if len(data.Chaos) == 0 {
+			data.Chaos = nil
+		}
+		return s.writeStorage(data)
 	}
-	_ = os.WriteFile(newPath, data, 0600)
+	if _, _, err := cfg.Durations(); err != nil {
+		return err
+	}
+	if r, ok := data.Relay[name]; !ok || strings.TrimSpace(r.Listen) == "" {
+		return fmt.Errorf("service '%s' has no relay; chaos needs one (see \"relay\" in the config)", name)
+	}
+	if data.Chaos == nil {
+		data.Chaos = make(map[string]ChaosConfig)
+	}
+	data.Chaos[name] = cfg
+	return s.writeStorage(data)
 }
 
-func (s *Storage) Path() string {
-	return s.filePath
+// CheckSamePort reports one of others (a service's alternate or fallback
+// commands) that does not forward the same local port as command, since
+// switching between them must keep the port stable.
+func CheckSamePort(command string, others ...string) error {
+	local, _ := cmdline.ParsePorts(command)
+	for _, other := range others {
+		if otherLocal, _ := cmdline.ParsePorts(other); otherLocal != local {
+			return fmt.Errorf("%q forwards local port %q, not %q", other, otherLocal, local)
+		}
+	}
+	return nil
 }
 
-func (s *Storage) SaveData(data *StorageData) error {
-	return s.writeStorage(data)
+// MaintenanceWindows returns the services under maintenance at now, mapped to
+// when each window ends. Expired windows are left out.
+func (s *Storage) MaintenanceWindows(now time.Time) (map[string]time.Time, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return nil, err
+	}
+	windows := make(map[string]time.Time)
+	for name, until := range data.Maintenance {
+		if now.Before(until) {
+			windows[name] = until
+		}
+	}
+	return windows, nil
 }
 
-func (s *Storage) LoadData() (*StorageData, error) {
-	return s.readStorage()
+// SetMaintenance puts a service under maintenance until the given time; a
+// zero time ends its window. Expired windows are pruned on the way.
+func (s *Storage) SetMaintenance(name string, until time.Time) error {
+	data, err := s.readStorage()
+	if err != nil {
+		return err
+	}
+	if _, exists := data.Services[name]; !exists {
+		return fmt.Errorf("service '%s' %w", name, ErrNotFound)
+	}
+	now := time.Now()
+	for n, end := range data.Maintenance {
+		if !now.Before(end) {
+			delete(data.Maintenance, n)
+		}
+	}
+	if until.IsZero() {
+		delete(data.Maintenance, name)
+	} else {
+		if data.Maintenance == nil {
+			data.Maintenance = make(map[string]time.Time)
+		}
+		data.Maintenance[name] = until
+	}
+	if len(data.Maintenance) == 0 {
+		data.Maintenance = nil
+	}
+	return s.writeStorage(data)
 }
 
-// ThemeName returns the saved theme name ("" when none is set, meaning default).
-func (s *Storage) ThemeName() (string, error) {
+// EnvFile returns the config's "envFile" path, where running sessions keep a
+// dotenv file of their live endpoints. A leading "~/" is expanded to the home
+// directory; "" means no env file.
+func (s *Storage) EnvFile() (string, error) {
 	data, err := s.readStorage()
 	if err != nil {
 		return "", err
 	}
-	return data.Theme, nil
+	return ExpandHome(data.EnvFile), nil
 }
 
-// RegisterCustomThemes loads user-defined palettes from the config's "themes"
-// map and registers them with the theme package so they can be selected by name
-// like the built-ins. Each spec is layered over the built-in "default" palette,
-// so omitted colors inherit sensible values. A missing or empty "themes" key is
-// a no-op, keeping configs without custom themes fully backward compatible.
-// Best-effort and idempotent: safe to call more than once.
-func (s *Storage) RegisterCustomThemes() error {
+// MetricsFile returns the config's "metricsFile" path, a CSV file (or JSON
+// Lines, for a .json or .jsonl name) that every session appends its
+// services' uptime, reconnects, errors and connect times to on exit. A
+// leading "~/" is expanded; "" means none.
+func (s *Storage) MetricsFile() (string, error) {
 	data, err := s.readStorage()
 	if err != nil {
-		return err
+		return "", err
 	}
-	for name, spec := range data.Themes {
-		theme.Register(customPalette(name, spec))
+	return ExpandHome(data.MetricsFile), nil
+}
+
+// MergeForwards reports whether the config's "mergeForwards" is on.
+func (s *Storage) MergeForwards() (bool, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return false, err
 	}
-	return nil
+	return data.MergeForwards, nil
 }
 
-// customPalette builds a theme palette for name by overlaying the non-empty
-// fields of spec onto the built-in "default" palette.
-func customPalette(name string, spec ThemeSpec) theme.Palette {
-	p, _ := theme.Get("default")
-	p.Name = name
-	for _, f := range []struct {
-		val string
-		dst *string
-	}{
-		{spec.Text, &p.Text},
-		{spec.Muted, &p.Muted},
-		{spec.Border, &p.Border},
-		{spec.Accent, &p.Accent},
-		{spec.AccentAlt, &p.AccentAlt},
-		{spec.Warn, &p.Warn},
-		{spec.Error, &p.Error},
-		{spec.Heading, &p.Heading},
-		{spec.Selected, &p.Selected},
-	} {
-		if f.val != "" {
-			*f.dst = f.val
+// ExpandHome replaces a leading "~/" in path with the user's home directory.
+func ExpandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
 		}
 	}
-	return p
+	return path
 }
 
-// SetTheme persists the selected theme name, preserving the rest of the config.
-func (s *Storage) SetTheme(name string) error {
+// ConfirmEnabled reports whether the TUI should ask before the given action
+// ("stop", "restart" or "quit"). Prompts are on unless the config's "confirm"
+// section sets that action to false.
+func (s *Storage) ConfirmEnabled(action string) (bool, error) {
 	data, err := s.readStorage()
 	if err != nil {
-		return err
+		return true, err
 	}
-	data.Theme = name
-	return s.writeStorage(data)
+	enabled, set := data.Confirm[action]
+	return !set || enabled, nil
 }
 
 func (s *Storage) IconEnabled() (bool, error) {
@@ -257,28 +1931,62 @@ func (s *Storage) readStorage() (*StorageData, error) {
 		return nil, err
 	}
 
-	var storageData StorageData
-	if err := json.Unmarshal(data, &storageData); err == nil && (storageData.Services != nil || storageData.Groups != nil || storageData.Icon != nil || storageData.Theme != "" || storageData.Themes != nil) {
-		if storageData.Services == nil {
-			storageData.Services = make(map[string]string)
-		}
-		if storageData.Groups == nil {
-			storageData.Groups = make(map[string][]string)
-		}
-		return &storageData, nil
+	if legacy, ok := legacyServices(data); ok {
+		return &StorageData{
+			Services: legacy,
+			Groups:   make(map[string][]string),
+		}, nil
 	}
 
-	var legacy map[string]string
-	if err := json.Unmarshal(data, &legacy); err != nil {
+	var storageData StorageData
+	if err := json.Unmarshal(data, &storageData); err != nil {
 		return nil, err
 	}
+	if storageData.Services == nil {
+		storageData.Services = make(map[string]string)
+	}
+	if storageData.Groups == nil {
+		storageData.Groups = make(map[string][]string)
+	}
+	return &storageData, nil
+}
 
-	return &StorageData{
-		Services: legacy,
-		Groups:   make(map[string][]string),
-	}, nil
+// legacyServices reads data as the first config format, a flat map of
+// service names to commands. It is that format when every value is a
+// string and no key is one of StorageData's, so a config with a single
+// string setting such as {"defaultGroup":"dev"} is not taken for a service.
+func legacyServices(data []byte) (map[string]string, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, false
+	}
+	legacy := make(map[string]string, len(fields))
+	for key, raw := range fields {
+		if storageDataKeys[key] {
+			return nil, false
+		}
+		var command string
+		if err := json.Unmarshal(raw, &command); err != nil {
+			return nil, false
+		}
+		legacy[key] = command
+	}
+	return legacy, true
 }
 
+// storageDataKeys are the JSON keys of StorageData.
+var storageDataKeys = func() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeFor[StorageData]()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}()
+
 func (s *Storage) writeStorage(data *StorageData) error {
 	jsonData, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
@@ -324,50 +2032,325 @@ func (s *Storage) LoadServices() (map[string]string, error) {
 	if err != nil {
 		return nil, err
 	}
-	return data.Services, nil
-}
+	return data.Services, nil
+}
+
+// AddService saves a service, keeping its earlier command in its history if
+// it replaces one.
+func (s *Storage) AddService(name, command string) error {
+	data, err := s.readStorage()
+	if err != nil {
+		return err
+	}
+	prev := maps.Clone(data.Services)
+	data.Services[name] = command
+	keepHistory(prev, data, time.Now())
+	return s.writeStorage(data)
+}
+
+// keepHistory adds the previous command of each service in data whose
+// command differs from prev to its history, trimmed to maxRevisions, and
+// drops the history of services data no longer has.
+func keepHistory(prev map[string]string, data *StorageData, now time.Time) {
+	for name, command := range data.Services {
+		old, ok := prev[name]
+		if !ok || old == command {
+			continue
+		}
+		if data.History == nil {
+			data.History = make(map[string][]Revision)
+		}
+		revisions := append(data.History[name], Revision{Command: old, Replaced: now})
+		if len(revisions) > maxRevisions {
+			revisions = revisions[len(revisions)-maxRevisions:]
+		}
+		data.History[name] = revisions
+	}
+	for name := range data.History {
+		if _, ok := data.Services[name]; !ok {
+			delete(data.History, name)
+		}
+	}
+}
+
+// ServiceHistory returns the earlier commands of a service, newest first.
+func (s *Storage) ServiceHistory(name string) ([]Revision, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := data.Services[name]; !ok {
+		return nil, fmt.Errorf("service '%s' %w", name, ErrNotFound)
+	}
+	revisions := slices.Clone(data.History[name])
+	slices.Reverse(revisions)
+	return revisions, nil
+}
+
+// RollbackService restores the command a service had steps changes ago (1 is
+// the previous one) and returns it. The command it replaces goes into the
+// history, so a rollback can be rolled back too.
+func (s *Storage) RollbackService(name string, steps int) (string, error) {
+	revisions, err := s.ServiceHistory(name)
+	if err != nil {
+		return "", err
+	}
+	if len(revisions) == 0 {
+		return "", fmt.Errorf("service '%s' has no earlier commands", name)
+	}
+	if steps < 1 || steps > len(revisions) {
+		return "", fmt.Errorf("service '%s' has earlier commands 1 to %d, not %d", name, len(revisions), steps)
+	}
+	command := revisions[steps-1].Command
+	return command, s.AddService(name, command)
+}
+
+func (s *Storage) DeleteService(name string) error {
+	data, err := s.readStorage()
+	if err != nil {
+		return err
+	}
+
+	if _, exists := data.Services[name]; !exists {
+		return fmt.Errorf("service '%s' %w", name, ErrNotFound)
+	}
+
+	delete(data.Services, name)
+	dropServiceSettings(data, name)
+	if sd := data.Shutdown; sd != nil {
+		sd.Order = slices.DeleteFunc(sd.Order, func(n string) bool { return n == name })
+		delete(sd.Timeouts, name)
+	}
+
+	for groupName, members := range data.Groups {
+		filtered := make([]string, 0, len(members))
+		for _, m := range members {
+			if m != name {
+				filtered = append(filtered, m)
+			}
+		}
+		data.Groups[groupName] = filtered
+	}
+
+	if err := s.writeStorage(data); err != nil {
+		return err
+	}
+	return s.moveOverride(name, "")
+}
+
+// ArchivedService is a service taken out of the config by `pf prune`, or
+// deleted into the trash by `pf delete`, with everything the config held for
+// it (its settings, and its groups as one-member lists), so it can be put
+// back as it was.
+type ArchivedService struct {
+	Archived time.Time   `json:"archived"`
+	Reason   string      `json:"reason,omitempty"`
+	Config   StorageData `json:"config"`
+}
+
+// archiveFile is what archive.json and trash.json hold.
+type archiveFile struct {
+	Services map[string]ArchivedService `json:"services"`
+}
+
+// TrashRetention is how long a deleted service stays in the trash.
+const TrashRetention = 30 * 24 * time.Hour
+
+// ArchiveFile is archive.json, next to services.json: the services `pf prune`
+// archived.
+func (s *Storage) ArchiveFile() string {
+	return filepath.Join(filepath.Dir(s.filePath), "archive.json")
+}
+
+// TrashFile is trash.json, next to services.json: the services `pf delete`
+// deleted in the last TrashRetention.
+func (s *Storage) TrashFile() string {
+	return filepath.Join(filepath.Dir(s.filePath), "trash.json")
+}
+
+// Archived returns the archived services by name.
+func (s *Storage) Archived() (map[string]ArchivedService, error) {
+	return readArchive(s.ArchiveFile(), 0)
+}
+
+// Trashed returns the services in the trash by name.
+func (s *Storage) Trashed() (map[string]ArchivedService, error) {
+	return readArchive(s.TrashFile(), TrashRetention)
+}
+
+// ArchiveService moves a saved service out of the config into archive.json,
+// noting why.
+func (s *Storage) ArchiveService(name, reason string) error {
+	return s.moveToArchive(s.ArchiveFile(), 0, name, reason)
+}
+
+// TrashService deletes a saved service into trash.json, where RestoreTrashed
+// can bring it back for TrashRetention. A service deleted under the same
+// name before takes its place in the trash.
+func (s *Storage) TrashService(name string) error {
+	return s.moveToArchive(s.TrashFile(), TrashRetention, name, "")
+}
+
+// RestoreService puts an archived service back in the config with its
+// settings, and back in its groups, creating any that are gone.
+func (s *Storage) RestoreService(name string) error {
+	return s.restoreFrom(s.ArchiveFile(), 0, name, "archived")
+}
+
+// RestoreTrashed is RestoreService for a service in the trash.
+func (s *Storage) RestoreTrashed(name string) error {
+	return s.restoreFrom(s.TrashFile(), TrashRetention, name, "in the trash")
+}
+
+// readArchive reads the archive at path. With keep set, entries older than
+// that are left out, and so purged the next time the archive is written.
+func readArchive(path string, keep time.Duration) (map[string]ArchivedService, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]ArchivedService{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var archive archiveFile
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+	if archive.Services == nil {
+		archive.Services = map[string]ArchivedService{}
+	}
+	if keep > 0 {
+		cutoff := time.Now().Add(-keep)
+		maps.DeleteFunc(archive.Services, func(_ string, a ArchivedService) bool { return a.Archived.Before(cutoff) })
+	}
+	return archive.Services, nil
+}
+
+func writeArchive(path string, services map[string]ArchivedService) error {
+	data, err := json.MarshalIndent(archiveFile{Services: services}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// moveToArchive moves a saved service out of the config into the archive at
+// path. The archive is written first, so a failure loses nothing.
+func (s *Storage) moveToArchive(path string, keep time.Duration, name, reason string) error {
+	data, err := s.readStorage()
+	if err != nil {
+		return err
+	}
+	command, exists := data.Services[name]
+	if !exists {
+		return fmt.Errorf("service '%s' %w", name, ErrNotFound)
+	}
+	archived, err := readArchive(path, keep)
+	if err != nil {
+		return err
+	}
+
+	entry := ArchivedService{
+		Archived: time.Now(),
+		Reason:   reason,
+		Config:   StorageData{Services: map[string]string{name: command}, Groups: map[string][]string{}},
+	}
+	moveServiceSettings(data, &entry.Config, name, name)
+	for group, members := range data.Groups {
+		if i := slices.Index(members, name); i >= 0 {
+			entry.Config.Groups[group] = []string{name}
+			data.Groups[group] = slices.Delete(members, i, i+1)
+		}
+	}
+	delete(data.Services, name)
 
-func (s *Storage) saveServices(services map[string]string) error {
-	data, err := s.readStorage()
-	if err != nil {
+	archived[name] = entry
+	if err := writeArchive(path, archived); err != nil {
 		return err
 	}
-	data.Services = services
 	return s.writeStorage(data)
 }
 
-func (s *Storage) AddService(name, command string) error {
-	services, err := s.LoadServices()
+// restoreFrom puts a service from the archive at path back in the config;
+// where says where it was in the error when it is not there.
+func (s *Storage) restoreFrom(path string, keep time.Duration, name, where string) error {
+	archived, err := readArchive(path, keep)
 	if err != nil {
 		return err
 	}
-	services[name] = command
-	return s.saveServices(services)
-}
-
-func (s *Storage) DeleteService(name string) error {
+	entry, ok := archived[name]
+	if !ok {
+		return fmt.Errorf("service '%s' is not %s", name, where)
+	}
 	data, err := s.readStorage()
 	if err != nil {
 		return err
 	}
+	if _, exists := data.Services[name]; exists {
+		return fmt.Errorf("a service with name '%s' already exists", name)
+	}
+	if _, exists := data.Groups[name]; exists {
+		return fmt.Errorf("a group with name '%s' already exists", name)
+	}
 
-	if _, exists := data.Services[name]; !exists {
-		return fmt.Errorf("service '%s' not found", name)
+	data.Services[name] = entry.Config.Services[name]
+	moveServiceSettings(&entry.Config, data, name, name)
+	for group := range entry.Config.Groups {
+		if !slices.Contains(data.Groups[group], name) {
+			data.Groups[group] = append(data.Groups[group], name)
+		}
 	}
+	if err := s.writeStorage(data); err != nil {
+		return err
+	}
+	delete(archived, name)
+	return writeArchive(path, archived)
+}
 
-	delete(data.Services, name)
+// moveServiceSettings moves name's entries in the per-service maps from one
+// config to another, under newName; from and to are the same config for a
+// rename. It is the one list of those maps: a new per-service setting is
+// added here, and deleting, renaming, archiving and restoring a service
+// carry it along.
+func moveServiceSettings(from, to *StorageData, name, newName string) {
+	moveEntry(from.Maintenance, &to.Maintenance, name, newName)
+	moveEntry(from.Alternates, &to.Alternates, name, newName)
+	moveEntry(from.Fallback, &to.Fallback, name, newName)
+	moveEntry(from.Relay, &to.Relay, name, newName)
+	moveEntry(from.Chaos, &to.Chaos, name, newName)
+	moveEntry(from.WaitFor, &to.WaitFor, name, newName)
+	moveEntry(from.PreConnect, &to.PreConnect, name, newName)
+	moveEntry(from.PostConnect, &to.PostConnect, name, newName)
+	moveEntry(from.RemoteCheck, &to.RemoteCheck, name, newName)
+	moveEntry(from.DNS, &to.DNS, name, newName)
+	moveEntry(from.Keepalive, &to.Keepalive, name, newName)
+	moveEntry(from.HostKeyChecking, &to.HostKeyChecking, name, newName)
+	moveEntry(from.OTP, &to.OTP, name, newName)
+	moveEntry(from.Deprecated, &to.Deprecated, name, newName)
+	moveEntry(from.Schedule, &to.Schedule, name, newName)
+	moveEntry(from.Limits, &to.Limits, name, newName)
+	moveEntry(from.History, &to.History, name, newName)
+	moveEntry(from.Variants, &to.Variants, name, newName)
+	moveEntry(from.Enabled, &to.Enabled, name, newName)
+	moveEntry(from.Labels, &to.Labels, name, newName)
+	moveEntry(from.Ephemeral, &to.Ephemeral, name, newName)
+}
 
-	for groupName, members := range data.Groups {
-		filtered := make([]string, 0, len(members))
-		for _, m := range members {
-			if m != name {
-				filtered = append(filtered, m)
-			}
-		}
-		data.Groups[groupName] = filtered
+func moveEntry[V any](from map[string]V, to *map[string]V, name, newName string) {
+	v, ok := from[name]
+	if !ok {
+		return
+	}
+	delete(from, name)
+	if *to == nil {
+		*to = map[string]V{}
 	}
+	(*to)[newName] = v
+}
 
-	return s.writeStorage(data)
+// dropServiceSettings deletes name's entries in the per-service maps.
+func dropServiceSettings(data *StorageData, name string) {
+	moveServiceSettings(data, &StorageData{}, name, name)
 }
 
 func (s *Storage) RenameService(oldName, newName string) error {
@@ -382,7 +2365,7 @@ func (s *Storage) RenameService(oldName, newName string) error {
 
 	command, exists := data.Services[oldName]
 	if !exists {
-		return fmt.Errorf("service '%s' not found", oldName)
+		return fmt.Errorf("service '%s' %w", oldName, ErrNotFound)
 	}
 	if _, exists := data.Services[newName]; exists {
 		return fmt.Errorf("a service with name '%s' already exists", newName)
@@ -393,6 +2376,22 @@ func (s *Storage) RenameService(oldName, newName string) error {
 
 	delete(data.Services, oldName)
 	data.Services[newName] = command
+	moveServiceSettings(data, data, oldName, newName)
+	if sd := data.Shutdown; sd != nil {
+		if i := slices.Index(sd.Order, oldName); i >= 0 {
+			sd.Order[i] = newName
+		}
+		if t, ok := sd.Timeouts[oldName]; ok {
+			delete(sd.Timeouts, oldName)
+			sd.Timeouts[newName] = t
+		}
+	}
+	for name, d := range data.Deprecated {
+		if d.Replacement == oldName {
+			d.Replacement = newName
+			data.Deprecated[name] = d
+		}
+	}
 
 	for groupName, members := range data.Groups {
 		for i, member := range members {
@@ -402,7 +2401,10 @@ func (s *Storage) RenameService(oldName, newName string) error {
 		}
 	}
 
-	return s.writeStorage(data)
+	if err := s.writeStorage(data); err != nil {
+		return err
+	}
+	return s.moveOverride(oldName, newName)
 }
 
 func (s *Storage) RenameGroup(oldName, newName string) error {
@@ -417,7 +2419,7 @@ func (s *Storage) RenameGroup(oldName, newName string) error {
 
 	members, exists := data.Groups[oldName]
 	if !exists {
-		return fmt.Errorf("group '%s' not found", oldName)
+		return fmt.Errorf("group '%s' %w", oldName, ErrNotFound)
 	}
 	if _, exists := data.Services[newName]; exists {
 		return fmt.Errorf("a service with name '%s' already exists", newName)
@@ -428,32 +2430,90 @@ func (s *Storage) RenameGroup(oldName, newName string) error {
 
 	delete(data.Groups, oldName)
 	data.Groups[newName] = members
+	if data.DefaultGroup == oldName {
+		data.DefaultGroup = newName
+	}
 
 	return s.writeStorage(data)
 }
 
+// GetService returns the command of a local service, or of a remote
+// catalog's service named "catalog/service".
 func (s *Storage) GetService(name string) (string, error) {
-	services, err := s.LoadServices()
+	data, err := s.readStorage()
 	if err != nil {
 		return "", err
 	}
 
-	cmd, exists := services[name]
-	if !exists {
-		return "", fmt.Errorf("service '%s' not found", name)
+	if cmd, exists := data.Services[name]; exists {
+		return cmd, nil
 	}
-
-	return cmd, nil
+	if remote, entry, ok := s.remoteCatalog(data, name); ok {
+		if cmd, exists := remote.Services[entry]; exists {
+			if err := checkCatalogPolicy(data, name, cmd); err != nil {
+				return "", err
+			}
+			return cmd, nil
+		}
+	}
+	return "", fmt.Errorf("service '%s' %w", name, ErrNotFound)
 }
 
-var portRegex = regexp.MustCompile(`(\d+):(\d+)`)
+// checkCatalogPolicy refuses a catalog service whose command its catalog's
+// allow list does not permit. Monitors run nothing, so they always pass.
+func checkCatalogPolicy(data *StorageData, name, command string) error {
+	catalogName, entry, _ := SplitCatalogName(name)
+	for _, c := range data.Catalogs {
+		if c.Name != catalogName || cmdline.IsMonitor(command) {
+			continue
+		}
+		if err := c.Policy().Check(entry, command); err != nil {
+			return fmt.Errorf("service '%s' is refused: %v; `pf catalog trust %s` allows this command", name, err, name)
+		}
+	}
+	return nil
+}
 
-func ParsePortsFromCommand(command string) (local, remote string) {
-	matches := portRegex.FindStringSubmatch(command)
-	if len(matches) == 3 {
-		return matches[1], matches[2]
+// TrustCatalogService approves the current command of a catalog's service,
+// "corp/db", despite the catalog's allow list, or with trust false takes the
+// approval back. The command is the one this machine runs, its variant if one
+// matches.
+func (s *Storage) TrustCatalogService(name string, trust bool) error {
+	data, err := s.readStorage()
+	if err != nil {
+		return err
+	}
+	catalogName, entry, ok := SplitCatalogName(name)
+	i := slices.IndexFunc(data.Catalogs, func(c CatalogConfig) bool { return c.Name == catalogName })
+	if !ok || i < 0 {
+		return fmt.Errorf("'%s' is not a service of a configured catalog", name)
+	}
+	c := &data.Catalogs[i]
+	if !trust {
+		if _, ok := c.Trusted[entry]; !ok {
+			return fmt.Errorf("'%s' is not trusted", name)
+		}
+		delete(c.Trusted, entry)
+		return s.writeStorage(data)
+	}
+	remote, _, err := catalog.Load(s.CatalogDir(), catalogName, c.PublicKey)
+	if errors.Is(err, catalog.ErrUnverified) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("catalog '%s' has no local copy yet (pf catalog sync)", catalogName)
+	}
+	command, ok := remote.Services[entry]
+	if !ok {
+		return fmt.Errorf("service '%s' %w", name, ErrNotFound)
+	}
+	goos, host := localMachine()
+	command = SelectVariant(command, catalogVariants(remote.Variants[entry]), goos, host)
+	if c.Trusted == nil {
+		c.Trusted = map[string]string{}
 	}
-	return "", ""
+	c.Trusted[entry] = command
+	return s.writeStorage(data)
 }
 
 func (s *Storage) AddGroup(name string, services []string) error {
@@ -468,7 +2528,7 @@ func (s *Storage) AddGroup(name string, services []string) error {
 
 	for _, svcName := range services {
 		if _, exists := data.Services[svcName]; !exists {
-			return fmt.Errorf("service '%s' not found", svcName)
+			return fmt.Errorf("service '%s' %w", svcName, ErrNotFound)
 		}
 	}
 
@@ -484,7 +2544,7 @@ func (s *Storage) AddServicesToGroup(groupName string, services []string) error
 
 	members, exists := data.Groups[groupName]
 	if !exists {
-		return fmt.Errorf("group '%s' not found", groupName)
+		return fmt.Errorf("group '%s' %w", groupName, ErrNotFound)
 	}
 
 	existing := make(map[string]bool, len(members))
@@ -494,7 +2554,7 @@ func (s *Storage) AddServicesToGroup(groupName string, services []string) error
 
 	for _, svc := range services {
 		if _, ok := data.Services[svc]; !ok {
-			return fmt.Errorf("service '%s' not found", svc)
+			return fmt.Errorf("service '%s' %w", svc, ErrNotFound)
 		}
 		if !existing[svc] {
 			members = append(members, svc)
@@ -514,7 +2574,7 @@ func (s *Storage) RemoveServicesFromGroup(groupName string, services []string) e
 
 	members, exists := data.Groups[groupName]
 	if !exists {
-		return fmt.Errorf("group '%s' not found", groupName)
+		return fmt.Errorf("group '%s' %w", groupName, ErrNotFound)
 	}
 
 	toRemove := make(map[string]bool, len(services))
@@ -533,6 +2593,123 @@ func (s *Storage) RemoveServicesFromGroup(groupName string, services []string) e
 	return s.writeStorage(data)
 }
 
+// GroupsFile is what `pf group export` writes and `pf group import` reads:
+// group definitions without the services they name, to carry a team's ways
+// of running things to another machine.
+type GroupsFile struct {
+	Groups map[string][]string `json:"groups"`
+}
+
+// ExportGroups returns the named groups, or every group without names.
+func (s *Storage) ExportGroups(names []string) (GroupsFile, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return GroupsFile{}, err
+	}
+	out := GroupsFile{Groups: map[string][]string{}}
+	if len(names) == 0 {
+		maps.Copy(out.Groups, data.Groups)
+		return out, nil
+	}
+	for _, name := range names {
+		members, ok := data.Groups[name]
+		if !ok {
+			return GroupsFile{}, fmt.Errorf("group '%s' %w", name, ErrNotFound)
+		}
+		out.Groups[name] = members
+	}
+	return out, nil
+}
+
+// GroupImport is what ImportGroups did, by group and service name.
+type GroupImport struct {
+	Added    []string // new groups
+	Replaced []string // groups that existed with other members
+	Kept     []string // groups that existed with other members and were left alone
+	Stubs    []string // services created for missing members
+}
+
+// ImportGroups saves the groups of f. A group that exists with other members
+// is replaced with overwrite, and kept as it is without. A member of a group
+// it saves must be a saved service or a remote catalog's; with createMissing
+// a missing one is saved as a disabled stub (see StubCommand) to give a
+// command later, otherwise the import fails before saving anything. The
+// members of a group it keeps are left alone.
+func (s *Storage) ImportGroups(f GroupsFile, createMissing, overwrite bool) (GroupImport, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return GroupImport{}, err
+	}
+	names := slices.Sorted(maps.Keys(f.Groups))
+
+	var result GroupImport
+	var saved, missing []string
+	for _, name := range names {
+		if _, exists := data.Services[name]; exists {
+			return GroupImport{}, fmt.Errorf("a service with name '%s' already exists, cannot create group with same name", name)
+		}
+		current, exists := data.Groups[name]
+		switch {
+		case !exists:
+			result.Added = append(result.Added, name)
+		case slices.Equal(current, f.Groups[name]):
+			continue
+		case !overwrite:
+			result.Kept = append(result.Kept, name)
+			continue
+		default:
+			result.Replaced = append(result.Replaced, name)
+		}
+		saved = append(saved, name)
+		for _, member := range f.Groups[name] {
+			if _, exists := data.Services[member]; exists || slices.Contains(missing, member) {
+				continue
+			}
+			if catalogName, _, ok := SplitCatalogName(member); ok && slices.ContainsFunc(data.Catalogs, func(c CatalogConfig) bool { return c.Name == catalogName }) {
+				continue
+			}
+			missing = append(missing, member)
+		}
+	}
+	if len(missing) > 0 && !createMissing {
+		return GroupImport{}, fmt.Errorf("missing services %s (import with --create-missing to add stubs for them)", strings.Join(missing, ", "))
+	}
+	for _, member := range missing {
+		if _, _, remote := SplitCatalogName(member); remote {
+			return GroupImport{}, fmt.Errorf("service '%s' names a catalog that is not configured", member)
+		}
+		if _, exists := data.Groups[member]; exists {
+			return GroupImport{}, fmt.Errorf("cannot add a stub for '%s': a group has that name", member)
+		}
+		if _, exists := f.Groups[member]; exists {
+			return GroupImport{}, fmt.Errorf("cannot add a stub for '%s': an imported group has that name", member)
+		}
+	}
+
+	for _, member := range missing {
+		data.Services[member] = StubCommand(member)
+		if data.Enabled == nil {
+			data.Enabled = map[string]bool{}
+		}
+		data.Enabled[member] = false
+		result.Stubs = append(result.Stubs, member)
+	}
+	for _, name := range saved {
+		data.Groups[name] = slices.Clone(f.Groups[name])
+	}
+	if err := s.writeStorage(data); err != nil {
+		return GroupImport{}, err
+	}
+	return result, nil
+}
+
+// StubCommand is the placeholder command of a service `pf group import
+// --create-missing` adds for a member it does not know. The stub is
+// disabled; `pf add` gives it its real command and `pf enable` turns it on.
+func StubCommand(name string) string {
+	return fmt.Sprintf("echo stub for %s: save its command with pf add and enable it with pf enable", name)
+}
+
 func (s *Storage) DeleteGroup(name string) error {
 	data, err := s.readStorage()
 	if err != nil {
@@ -540,10 +2717,40 @@ func (s *Storage) DeleteGroup(name string) error {
 	}
 
 	if _, exists := data.Groups[name]; !exists {
-		return fmt.Errorf("group '%s' not found", name)
+		return fmt.Errorf("group '%s' %w", name, ErrNotFound)
 	}
 
 	delete(data.Groups, name)
+	if data.DefaultGroup == name {
+		data.DefaultGroup = ""
+	}
+	return s.writeStorage(data)
+}
+
+// DefaultGroup returns the group a bare `pf run` starts, "" when none is set.
+func (s *Storage) DefaultGroup() (string, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return "", err
+	}
+	return data.DefaultGroup, nil
+}
+
+// SetDefaultGroup makes name the group a bare `pf run` starts: a saved
+// group, a remote catalog's ("corp/backend") or a virtual one
+// ("label:team=payments"). "" clears it. Deleting the group clears it too,
+// and renaming it follows.
+func (s *Storage) SetDefaultGroup(name string) error {
+	if name != "" && !IsSelector(name) {
+		if _, err := s.GetGroupServices(name); err != nil {
+			return err
+		}
+	}
+	data, err := s.readStorage()
+	if err != nil {
+		return err
+	}
+	data.DefaultGroup = name
 	return s.writeStorage(data)
 }
 
@@ -553,12 +2760,35 @@ func (s *Storage) GetGroupServices(name string) ([]string, error) {
 		return nil, err
 	}
 
-	services, exists := data.Groups[name]
-	if !exists {
-		return nil, fmt.Errorf("group '%s' not found", name)
+	if services, exists := data.Groups[name]; exists {
+		return services, nil
 	}
+	// A remote group's members are services of the same catalog.
+	if remote, entry, ok := s.remoteCatalog(data, name); ok {
+		if members, exists := remote.Groups[entry]; exists {
+			catalogName, _, _ := SplitCatalogName(name)
+			services := make([]string, len(members))
+			for i, m := range members {
+				services[i] = catalogName + "/" + m
+			}
+			return services, nil
+		}
+	}
+	return nil, fmt.Errorf("group '%s' %w", name, ErrNotFound)
+}
 
-	return services, nil
+// EnabledGroupServices returns a group's members like GetGroupServices,
+// without the disabled ones: what running the group starts.
+func (s *Storage) EnabledGroupServices(name string) ([]string, error) {
+	members, err := s.GetGroupServices(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.readStorage()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(slices.Clone(members), func(m string) bool { return isDisabled(data, m) }), nil
 }
 
 func (s *Storage) ListGroups() (map[string][]string, error) {
@@ -583,6 +2813,251 @@ func (s *Storage) ListServiceNames() ([]string, error) {
 	return names, nil
 }
 
+// EnabledServiceNames returns the saved services' names like
+// ListServiceNames, without the disabled ones: what "all" runs.
+func (s *Storage) EnabledServiceNames() ([]string, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(data.Services))
+	for name := range data.Services {
+		if !isDisabled(data, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// IsEnabled reports whether a service is enabled, as every service is until
+// `pf disable`.
+func (s *Storage) IsEnabled(name string) (bool, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return false, err
+	}
+	return !isDisabled(data, name), nil
+}
+
+// SetEnabled disables a service, local or from a catalog, or enables it
+// again.
+func (s *Storage) SetEnabled(name string, enabled bool) error {
+	data, err := s.readStorage()
+	if err != nil {
+		return err
+	}
+	_, exists := data.Services[name]
+	if remote, entry, ok := s.remoteCatalog(data, name); ok && !exists {
+		_, exists = remote.Services[entry]
+	}
+	if !exists {
+		return fmt.Errorf("service '%s' %w", name, ErrNotFound)
+	}
+	if enabled {
+		delete(data.Enabled, name)
+	} else {
+		if data.Enabled == nil {
+			data.Enabled = map[string]bool{}
+		}
+		data.Enabled[name] = false
+	}
+	return s.writeStorage(data)
+}
+
+func isDisabled(data *StorageData, name string) bool {
+	enabled, ok := data.Enabled[name]
+	return ok && !enabled
+}
+
+// Virtual group prefixes: a run target such as "ns:staging" selects the
+// services matching it instead of naming a group.
+const (
+	SelectLabel     = "label:"   // label:team=payments, or label:team for any value
+	SelectNamespace = "ns:"      // kubectl namespace given with -n
+	SelectContext   = "context:" // kubectl --context
+)
+
+// IsSelector reports whether a run target is a virtual group. Service and
+// group names cannot hold a colon, so it never shadows one.
+func IsSelector(target string) bool {
+	for _, prefix := range []string{SelectLabel, SelectNamespace, SelectContext} {
+		if strings.HasPrefix(target, prefix) && len(target) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectServices returns the enabled saved services a virtual group selects,
+// by name. Namespaces and contexts are read from the commands as this
+// machine runs them, so a local override of the context counts.
+func (s *Storage) SelectServices(selector string) ([]string, error) {
+	if !IsSelector(selector) {
+		return nil, fmt.Errorf("'%s' is not a selector (use label:key=value, ns:namespace or context:name)", selector)
+	}
+	data, err := s.readStorage()
+	if err != nil {
+		return nil, err
+	}
+	commands, err := s.LocalServices()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name, command := range commands {
+		if isDisabled(data, name) {
+			continue
+		}
+		fw := cmdline.ParseForward(command)
+		var match bool
+		switch {
+		case strings.HasPrefix(selector, SelectLabel):
+			key, value, withValue := strings.Cut(strings.TrimPrefix(selector, SelectLabel), "=")
+			got, ok := data.Labels[name][key]
+			match = ok && (!withValue || got == value)
+		case strings.HasPrefix(selector, SelectNamespace):
+			match = fw.Namespace != "" && fw.Namespace == strings.TrimPrefix(selector, SelectNamespace)
+		case strings.HasPrefix(selector, SelectContext):
+			match = fw.Context != "" && fw.Context == strings.TrimPrefix(selector, SelectContext)
+		}
+		if match {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Labels returns the service's labels.
+func (s *Storage) Labels(name string) (map[string]string, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return nil, err
+	}
+	return data.Labels[name], nil
+}
+
+// SetLabels sets labels of a saved service and removes those named in
+// remove.
+func (s *Storage) SetLabels(name string, set map[string]string, remove []string) error {
+	data, err := s.readStorage()
+	if err != nil {
+		return err
+	}
+	if _, exists := data.Services[name]; !exists {
+		return fmt.Errorf("service '%s' %w", name, ErrNotFound)
+	}
+	labels := maps.Clone(data.Labels[name])
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for key, value := range set {
+		if err := ValidateLabel(key, value); err != nil {
+			return err
+		}
+		labels[key] = value
+	}
+	for _, key := range remove {
+		delete(labels, key)
+	}
+	if data.Labels == nil {
+		data.Labels = map[string]map[string]string{}
+	}
+	if len(labels) == 0 {
+		delete(data.Labels, name)
+	} else {
+		data.Labels[name] = labels
+	}
+	return s.writeStorage(data)
+}
+
+var labelPattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// ValidateLabel checks a label's key and value: letters, digits, '_', '.'
+// and '-', so they fit in a selector. The value may be empty.
+func ValidateLabel(key, value string) error {
+	if !labelPattern.MatchString(key) {
+		return fmt.Errorf("invalid label key %q (use letters, digits, '_', '.' and '-')", key)
+	}
+	if value != "" && !labelPattern.MatchString(value) {
+		return fmt.Errorf("invalid value %q for label %q (use letters, digits, '_', '.' and '-')", value, key)
+	}
+	return nil
+}
+
+// Ephemeral is how a short-lived service was made: the service it copies,
+// with Params (see ApplyParams) set in its command. It is deleted once
+// Expires passes or, when Expires is zero, by the next `pf cleanup`.
+type Ephemeral struct {
+	Template string            `json:"template"`
+	Params   map[string]string `json:"params,omitempty"`
+	Created  time.Time         `json:"created"`
+	Expires  time.Time         `json:"expires,omitzero"`
+}
+
+// Expired reports whether the service is due for deletion at now; a cleanup
+// also takes those without an expiry.
+func (e Ephemeral) Expired(now time.Time, cleanup bool) bool {
+	if e.Expires.IsZero() {
+		return cleanup
+	}
+	return !now.Before(e.Expires)
+}
+
+// AddEphemeral saves a short-lived service called name: the command the
+// template service runs on this machine, with params set in it. The name must
+// be free.
+func (s *Storage) AddEphemeral(name, template string, params map[string]string, expires time.Time) (string, error) {
+	command, err := s.LocalCommand(template)
+	if err != nil {
+		return "", err
+	}
+	if command, err = ApplyParams(command, params); err != nil {
+		return "", err
+	}
+	data, err := s.readStorage()
+	if err != nil {
+		return "", err
+	}
+	if _, exists := data.Services[name]; exists {
+		return "", fmt.Errorf("service '%s' already exists", name)
+	}
+	if _, exists := data.Groups[name]; exists {
+		return "", fmt.Errorf("a group with name '%s' already exists", name)
+	}
+	data.Services[name] = command
+	if data.Ephemeral == nil {
+		data.Ephemeral = map[string]Ephemeral{}
+	}
+	data.Ephemeral[name] = Ephemeral{Template: template, Params: params, Created: time.Now(), Expires: expires}
+	return command, s.writeStorage(data)
+}
+
+// ExpireEphemeral deletes the ephemeral services expired at now, and with
+// cleanup those without an expiry too, as DeleteService would. It returns
+// their names, sorted.
+func (s *Storage) ExpireEphemeral(now time.Time, cleanup bool) ([]string, error) {
+	data, err := s.readStorage()
+	if err != nil {
+		return nil, err
+	}
+	var expired []string
+	for name, e := range data.Ephemeral {
+		if e.Expired(now, cleanup) {
+			expired = append(expired, name)
+		}
+	}
+	sort.Strings(expired)
+	for _, name := range expired {
+		if err := s.DeleteService(name); err != nil {
+			return nil, err
+		}
+	}
+	return expired, nil
+}
+
 func (s *Storage) HasNameConflict(name string) (bool, error) {
 	data, err := s.readStorage()
 	if err != nil {
@@ -591,6 +3066,10 @@ func (s *Storage) HasNameConflict(name string) (bool, error) {
 
 	_, isService := data.Services[name]
 	_, isGroup := data.Groups[name]
+	if remote, entry, ok := s.remoteCatalog(data, name); ok {
+		_, isService = remote.Services[entry]
+		_, isGroup = remote.Groups[entry]
+	}
 
 	return isService && isGroup, nil
 }
@@ -601,14 +3080,25 @@ func (s *Storage) FindPortConflicts(serviceNames []string) ([]model.PortConflict
 		return nil, err
 	}
 
+	overrides, err := s.Overrides()
+	if err != nil {
+		return nil, err
+	}
+
 	portMap := make(map[string][]string)
 	for _, name := range serviceNames {
 		command, exists := data.Services[name]
+		if remote, entry, ok := s.remoteCatalog(data, name); ok && !exists {
+			command, exists = remote.Services[entry]
+		}
 		if !exists {
 			continue
 		}
+		if overridden, err := overrideCommand(name, command, overrides); err == nil {
+			command = overridden
+		}
 
-		localPort, _ := ParsePortsFromCommand(command)
+		localPort, _ := cmdline.ParsePorts(command)
 		if localPort == "" {
 			continue
 		}
@@ -1,12 +1,21 @@
 package storage
 
 import (
+	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/alinemone/go-port-forward/internal/catalog"
+	"github.com/alinemone/go-port-forward/internal/cmdline"
 	"github.com/alinemone/go-port-forward/internal/theme"
 )
 
@@ -128,32 +137,6 @@ func TestListServiceNames(t *testing.T) {
 	}
 }
 
-func TestParsePortsFromCommand(t *testing.T) {
-	tests := []struct {
-		command    string
-		wantLocal  string
-		wantRemote string
-	}{
-		{"kubectl port-forward svc/db 5432:5432", "5432", "5432"},
-		{"kubectl port-forward svc/redis 6379:6379", "6379", "6379"},
-		{"kubectl port-forward svc/web 8080:80", "8080", "80"},
-		{"no ports here", "", ""},
-		{"", "", ""},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.command, func(t *testing.T) {
-			local, remote := ParsePortsFromCommand(tt.command)
-			if local != tt.wantLocal {
-				t.Errorf("local = %q, want %q", local, tt.wantLocal)
-			}
-			if remote != tt.wantRemote {
-				t.Errorf("remote = %q, want %q", remote, tt.wantRemote)
-			}
-		})
-	}
-}
-
 func TestGroupOperations(t *testing.T) {
 	s := newTestStorage(t)
 
@@ -664,6 +647,27 @@ func TestLegacyFormatMigration(t *testing.T) {
 	}
 }
 
+func TestStructuredFormatWithOnlySettings(t *testing.T) {
+	s := newTestStorage(t)
+	for _, raw := range []string{`{"defaultGroup":"dev"}`, `{"mergeForwards":true}`, `{"theme":"nord"}`} {
+		if err := os.WriteFile(s.filePath, []byte(raw), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		data, err := s.readStorage()
+		if err != nil {
+			t.Errorf("%s: %v", raw, err)
+			continue
+		}
+		if len(data.Services) != 0 {
+			t.Errorf("%s read as the legacy format: services %v", raw, data.Services)
+		}
+	}
+	data, _ := s.readStorage()
+	if data.Theme != "nord" {
+		t.Errorf("theme = %q, want nord", data.Theme)
+	}
+}
+
 func TestRegisterCustomThemesFromConfig(t *testing.T) {
 	defer theme.Set("") // restore default for other tests
 
@@ -723,3 +727,1320 @@ func TestRegisterCustomThemesBackwardCompatible(t *testing.T) {
 		t.Errorf("theme count changed from %d to %d with no custom themes", before, after)
 	}
 }
+
+func TestKeymapLoadsAndSurvivesSave(t *testing.T) {
+	s := newTestStorage(t)
+	if err := os.WriteFile(s.filePath, []byte(`{"keymap":{"restart":["R"],"up":["up","w"]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	km, err := s.Keymap()
+	if err != nil {
+		t.Fatalf("Keymap: %v", err)
+	}
+	if got := strings.Join(km["up"], ","); got != "up,w" {
+		t.Errorf("up = %q, want up,w", got)
+	}
+
+	// Unrelated writes must keep the keymap section.
+	if err := s.AddService("db", "kubectl port-forward svc/db 5432:5432"); err != nil {
+		t.Fatalf("AddService: %v", err)
+	}
+	km, err = s.Keymap()
+	if err != nil {
+		t.Fatalf("Keymap: %v", err)
+	}
+	if got := strings.Join(km["restart"], ","); got != "R" {
+		t.Errorf("restart = %q after save, want R", got)
+	}
+}
+
+func TestKeymapAbsentIsNil(t *testing.T) {
+	s := newTestStorage(t)
+	if err := os.WriteFile(s.filePath, []byte(`{"services":{}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	km, err := s.Keymap()
+	if err != nil {
+		t.Fatalf("Keymap: %v", err)
+	}
+	if km != nil {
+		t.Errorf("Keymap = %v, want nil", km)
+	}
+}
+
+func TestConfirmEnabledDefaultsOn(t *testing.T) {
+	s := newTestStorage(t)
+	if err := os.WriteFile(s.filePath, []byte(`{"confirm":{"restart":false,"stop":true}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for action, want := range map[string]bool{"restart": false, "stop": true, "quit": true} {
+		got, err := s.ConfirmEnabled(action)
+		if err != nil {
+			t.Fatalf("ConfirmEnabled(%s): %v", action, err)
+		}
+		if got != want {
+			t.Errorf("ConfirmEnabled(%s) = %v, want %v", action, got, want)
+		}
+	}
+}
+
+func TestEnvFileExpandsHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	s := newTestStorage(t)
+	if err := os.WriteFile(s.filePath, []byte(`{"envFile":"~/.pf/endpoints.env"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.EnvFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, ".pf", "endpoints.env"); got != want {
+		t.Errorf("EnvFile() = %q, want %q", got, want)
+	}
+}
+
+func TestFlapSettings(t *testing.T) {
+	s := newTestStorage(t)
+	if n, w, err := s.FlapSettings(); err != nil || n != 0 || w != 0 {
+		t.Fatalf("unset: got %d, %v, %v; want zeros", n, w, err)
+	}
+	if err := os.WriteFile(s.filePath, []byte(`{"flap":{"errors":5,"window":"10m"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if n, w, err := s.FlapSettings(); err != nil || n != 5 || w != 10*time.Minute {
+		t.Fatalf("got %d, %v, %v; want 5, 10m", n, w, err)
+	}
+	if err := os.WriteFile(s.filePath, []byte(`{"flap":{"window":"soon"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.FlapSettings(); err == nil {
+		t.Error("a bad window should be an error")
+	}
+}
+
+func TestMaintenanceWindows(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.AddService("db", "kubectl port-forward svc/db 5432:5432"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetMaintenance("nope", time.Now().Add(time.Hour)); err == nil {
+		t.Error("an unknown service should be rejected")
+	}
+
+	now := time.Now()
+	until := now.Add(time.Hour).Round(time.Second)
+	if err := s.SetMaintenance("db", until); err != nil {
+		t.Fatal(err)
+	}
+	windows, err := s.MaintenanceWindows(now)
+	if err != nil || !windows["db"].Equal(until) {
+		t.Fatalf("got %v, %v; want db until %v", windows, err, until)
+	}
+	if windows, _ := s.MaintenanceWindows(until.Add(time.Second)); len(windows) != 0 {
+		t.Errorf("expired window still listed: %v", windows)
+	}
+
+	if err := s.RenameService("db", "pg"); err != nil {
+		t.Fatal(err)
+	}
+	if windows, _ := s.MaintenanceWindows(now); !windows["pg"].Equal(until) {
+		t.Errorf("window should follow a rename: %v", windows)
+	}
+	if err := s.SetMaintenance("pg", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if windows, _ := s.MaintenanceWindows(now); len(windows) != 0 {
+		t.Errorf("window should be cleared: %v", windows)
+	}
+}
+
+func TestSetChaosNeedsRelay(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.AddService("db", "kubectl port-forward svc/db 5432:5432"); err != nil {
+		t.Fatal(err)
+	}
+	cfg := ChaosConfig{Latency: "200ms", Drop: 1}
+	if err := s.SetChaos("db", cfg); err == nil {
+		t.Error("chaos without a relay should be rejected")
+	}
+
+	data, _ := s.readStorage()
+	data.Relay = map[string]RelayConfig{"db": {Listen: "15432"}}
+	if err := s.writeStorage(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetChaos("db", ChaosConfig{Latency: "soon"}); err == nil {
+		t.Error("a bad latency should be rejected")
+	}
+	if err := s.SetChaos("db", cfg); err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := s.Chaos(); all["db"] != cfg {
+		t.Errorf("chaos = %v", all)
+	}
+	if err := s.SetChaos("db", ChaosConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := s.Chaos(); len(all) != 0 {
+		t.Errorf("chaos should be off: %v", all)
+	}
+}
+
+func TestCheckSamePort(t *testing.T) {
+	command := "kubectl port-forward svc/db 5432:5432"
+	if err := CheckSamePort(command, "ssh -N -L 5432:replica:5432 bastion"); err != nil {
+		t.Errorf("same local port should pass: %v", err)
+	}
+	if err := CheckSamePort(command, "ssh -N -L 5432:replica:5432 a", "ssh -N -L 6432:replica:5432 b"); err == nil {
+		t.Error("a different local port should be rejected")
+	}
+}
+
+func TestRemoteCheckEvery(t *testing.T) {
+	if d, err := (RemoteCheck{}).EveryDuration(); d != DefaultRemoteCheckEvery || err != nil {
+		t.Errorf("default every = %s, %v", d, err)
+	}
+	if d, err := (RemoteCheck{Every: "1m"}).EveryDuration(); d != time.Minute || err != nil {
+		t.Errorf("every 1m = %s, %v", d, err)
+	}
+	for _, bad := range []string{"10ms", "soon", "-5s"} {
+		if err := (RemoteCheck{Every: bad}).Validate(); err == nil {
+			t.Errorf("every %q should not validate", bad)
+		}
+	}
+}
+
+func TestDNSValidate(t *testing.T) {
+	for _, ok := range []DNS{{}, {Resolver: DNSSystem}, {Resolver: DNSServer, Server: "10.0.0.2"}, {Resolver: DNSServer, Server: "127.0.0.1:5353"}, {Resolver: DNSPin, IP: "10.0.0.5"}} {
+		if err := ok.Validate(); err != nil {
+			t.Errorf("%+v: %v", ok, err)
+		}
+	}
+	for _, bad := range []DNS{{Resolver: "mdns"}, {Server: "10.0.0.2"}, {Resolver: DNSServer}, {Resolver: DNSPin}, {Resolver: DNSPin, IP: "db"}, {Resolver: DNSPin, IP: "fd00::5"}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v should not validate", bad)
+		}
+	}
+	if got := (DNS{Server: "10.0.0.2"}).ServerAddress(); got != "10.0.0.2:53" {
+		t.Errorf("ServerAddress = %q", got)
+	}
+	if got := (DNS{Server: "127.0.0.1:5353"}).ServerAddress(); got != "127.0.0.1:5353" {
+		t.Errorf("ServerAddress = %q", got)
+	}
+}
+
+func TestWaitForFollowsRenames(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.AddService("db", "kubectl port-forward svc/db 5432:5432"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := s.readStorage()
+	data.WaitFor = map[string][]WaitCondition{"db": {{TCP: "vpn.corp:443"}}}
+	if err := s.writeStorage(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RenameService("db", "pg"); err != nil {
+		t.Fatal(err)
+	}
+	if conds, _ := s.WaitFor("pg"); len(conds) != 1 || conds[0].String() != "tcp vpn.corp:443" {
+		t.Errorf("waitFor after rename = %v", conds)
+	}
+
+	for _, bad := range []WaitCondition{{}, {TCP: "vpn.corp"}, {File: "/tmp/x", Command: "true"}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v should be invalid", bad)
+		}
+	}
+}
+
+func TestPreConnectSettings(t *testing.T) {
+	s := newTestStorage(t)
+	content := []byte(`{"services": {"db": "ssh -L 5432:db:5432 bastion"},
+		"preConnect": {"db": {"command": "knock bastion 7000 8000", "timeout": "5s"}}}`)
+	if err := os.WriteFile(s.filePath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RenameService("db", "pg"); err != nil {
+		t.Fatal(err)
+	}
+	p, ok, err := s.PreConnect("pg")
+	if err != nil || !ok || p.Command != "knock bastion 7000 8000" {
+		t.Fatalf("preConnect after rename = %+v, %v, %v", p, ok, err)
+	}
+	if d, err := p.TimeoutDuration(); err != nil || d != 5*time.Second || p.Continue() {
+		t.Errorf("timeout = %v, %v; continue = %v", d, err, p.Continue())
+	}
+	if d, _ := (PreConnect{Command: "true"}).TimeoutDuration(); d != DefaultPreConnectTimeout {
+		t.Errorf("default timeout = %v", d)
+	}
+
+	for _, bad := range []PreConnect{{}, {Command: "true", Timeout: "soon"}, {Command: "true", OnFailure: "ignore"}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v should be invalid", bad)
+		}
+	}
+}
+
+func TestPostConnectSettings(t *testing.T) {
+	s := newTestStorage(t)
+	content := []byte(`{"services": {"db": "kubectl port-forward svc/db 5432:5432"},
+		"postConnect": {"db": {"command": "make migrate"}}}`)
+	if err := os.WriteFile(s.filePath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RenameService("db", "pg"); err != nil {
+		t.Fatal(err)
+	}
+	p, ok, err := s.PostConnect("pg")
+	if err != nil || !ok || p.Command != "make migrate" {
+		t.Fatalf("postConnect after rename = %+v, %v, %v", p, ok, err)
+	}
+	if d, err := p.TimeoutDuration(); err != nil || d != DefaultPostConnectTimeout {
+		t.Errorf("default timeout = %v, %v", d, err)
+	}
+	if err := s.DeleteService("pg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := s.PostConnect("pg"); ok {
+		t.Error("deleting the service should drop its postConnect")
+	}
+
+	for _, bad := range []PostConnect{{}, {Command: "true", Timeout: "-1s"}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v should be invalid", bad)
+		}
+	}
+}
+
+func TestKeepaliveSettings(t *testing.T) {
+	s := newTestStorage(t)
+	content := []byte(`{"services": {"db": "ssh -N -L 5432:db:5432 bastion"},
+		"keepalive": {"db": {"interval": "1m", "countMax": 5}}}`)
+	if err := os.WriteFile(s.filePath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RenameService("db", "pg"); err != nil {
+		t.Fatal(err)
+	}
+	k, err := s.Keepalive("pg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts, err := k.SSHOptions(); err != nil || !slices.Equal(opts, []string{"ServerAliveInterval=60", "ServerAliveCountMax=5", "ConnectTimeout=10"}) {
+		t.Errorf("options = %q, %v", opts, err)
+	}
+	if opts, _ := (Keepalive{ConnectTimeout: "30s"}).SSHOptions(); !slices.Equal(opts, []string{"ServerAliveInterval=15", "ServerAliveCountMax=3", "ConnectTimeout=30"}) {
+		t.Errorf("default options = %q", opts)
+	}
+	if flags, _ := (Keepalive{}).KubectlFlags(); flags != nil {
+		t.Errorf("kubectl keeps its own defaults, got %q", flags)
+	}
+	off := Keepalive{Off: true, PodRunningTimeout: "20s"}
+	if opts, _ := off.SSHOptions(); opts != nil {
+		t.Errorf("off should add nothing, got %q", opts)
+	}
+	if flags, _ := off.KubectlFlags(); flags != nil {
+		t.Errorf("off should add nothing, got %q", flags)
+	}
+	for _, bad := range []Keepalive{{Interval: "fast"}, {Interval: "500ms"}, {CountMax: -1}, {ConnectTimeout: "0s"}, {PodRunningTimeout: "soon"}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v should be invalid", bad)
+		}
+	}
+}
+
+func TestHostKeyChecking(t *testing.T) {
+	s := newTestStorage(t)
+	content := []byte(`{"services": {"db": "ssh -N -L 5432:db:5432 bastion"},
+		"hostKeyChecking": {"db": "accept-new"}}`)
+	if err := os.WriteFile(s.filePath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RenameService("db", "pg"); err != nil {
+		t.Fatal(err)
+	}
+	h, err := s.HostKeyChecking("pg")
+	if err != nil || h != HostKeysAcceptNew {
+		t.Fatalf("policy = %q, %v", h, err)
+	}
+	if got := cmdline.AddSSHOptions("ssh -N -L 5432:db:5432 bastion", h.SSHOptions()); got != "ssh -o StrictHostKeyChecking=accept-new -N -L 5432:db:5432 bastion" {
+		t.Errorf("command = %q", got)
+	}
+	if opts := HostKeysOff.SSHOptions(); !slices.Equal(opts, []string{"StrictHostKeyChecking=no", "UserKnownHostsFile=/dev/null"}) {
+		t.Errorf("off options = %q", opts)
+	}
+	if opts := HostKeyChecking("").SSHOptions(); opts != nil {
+		t.Errorf("unset should leave ssh_config alone, got %q", opts)
+	}
+	if err := HostKeyChecking("yes").Validate(); err == nil {
+		t.Error(`"yes" should be invalid`)
+	}
+}
+
+func TestOTPSettings(t *testing.T) {
+	s := newTestStorage(t)
+	content := []byte(`{"services": {"db": "ssh -N -L 5432:db:5432 bastion"},
+		"otp": {"db": {"service": "pf", "account": "db-otp", "digits": 8, "period": "60s"}}}`)
+	if err := os.WriteFile(s.filePath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RenameService("db", "pg"); err != nil {
+		t.Fatal(err)
+	}
+	o, ok, err := s.OTP("pg")
+	if err != nil || !ok || o.Account != "db-otp" {
+		t.Fatalf("otp after rename = %+v, %v, %v", o, ok, err)
+	}
+	digits, period, prompt, err := o.Settings()
+	if err != nil || digits != 8 || period != time.Minute || !prompt.MatchString("Verification code:") {
+		t.Errorf("settings = %d, %v, %v, %v", digits, period, prompt, err)
+	}
+	if _, _, prompt, _ := (OTP{Service: "pf", Account: "a", Prompt: "^PIN"}).Settings(); prompt.MatchString("Verification code:") {
+		t.Error("a custom prompt should replace the default")
+	}
+
+	for _, bad := range []OTP{{Service: "pf"}, {Service: "pf", Account: "a", Digits: 4}, {Service: "pf", Account: "a", Period: "1500ms"}, {Service: "pf", Account: "a", Prompt: "("}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v should be invalid", bad)
+		}
+	}
+}
+
+func TestRemoteCatalogEntries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"services":{"db":"kubectl port-forward svc/db 5432:5432","cache":"kubectl port-forward svc/redis 6379:6379"},"groups":{"data":["db","cache"]}}`))
+	}))
+	defer srv.Close()
+
+	s := newTestStorage(t)
+	if err := s.AddService("db", "kubectl port-forward svc/local-db 5432:5432"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := s.readStorage()
+	data.Catalogs = []CatalogConfig{{Name: "corp", URL: srv.URL}}
+	if err := s.writeStorage(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetService("corp/db"); err == nil {
+		t.Fatal("corp/db should not resolve before the catalog is fetched")
+	}
+	if _, err := catalog.Sync(context.Background(), srv.Client(), s.CatalogDir(), "corp", srv.URL, catalog.Verification{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd, err := s.GetService("corp/db"); err != nil || cmd != "kubectl port-forward svc/db 5432:5432" {
+		t.Errorf("GetService(corp/db) = %q, %v", cmd, err)
+	}
+	if cmd, _ := s.GetService("db"); cmd != "kubectl port-forward svc/local-db 5432:5432" {
+		t.Errorf("the local db should be unaffected, got %q", cmd)
+	}
+	if _, err := s.GetService("other/db"); err == nil {
+		t.Error("an unconfigured catalog should not resolve")
+	}
+	members, err := s.GetGroupServices("corp/data")
+	if err != nil || strings.Join(members, ",") != "corp/db,corp/cache" {
+		t.Errorf("GetGroupServices(corp/data) = %v, %v", members, err)
+	}
+	conflicts, _ := s.FindPortConflicts([]string{"db", "corp/db"})
+	if len(conflicts) != 1 {
+		t.Errorf("db and corp/db share 5432, got conflicts %+v", conflicts)
+	}
+	if services, _ := s.LoadServices(); len(services) != 1 {
+		t.Errorf("LoadServices should stay local, got %v", services)
+	}
+	if remote, _ := s.RemoteServices(); len(remote) != 2 || remote["corp/cache"] == "" {
+		t.Errorf("RemoteServices = %v", remote)
+	}
+}
+
+func TestCatalogAllowList(t *testing.T) {
+	command := "kubectl port-forward svc/db 5432:5432"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"services":{"db":"` + command + `","tool":"curl -s https://x.example"}}`))
+	}))
+	defer srv.Close()
+
+	s := newTestStorage(t)
+	data, _ := s.readStorage()
+	data.Catalogs = []CatalogConfig{{Name: "corp", URL: srv.URL, Allow: []string{"kubectl"}}}
+	if err := s.writeStorage(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := catalog.Sync(context.Background(), srv.Client(), s.CatalogDir(), "corp", srv.URL, catalog.Verification{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd, err := s.GetService("corp/db"); err != nil || cmd != command {
+		t.Errorf("an allowed command should resolve, got %q, %v", cmd, err)
+	}
+	if _, err := s.GetService("corp/tool"); err == nil || !strings.Contains(err.Error(), "pf catalog trust corp/tool") {
+		t.Errorf("curl should be refused with a hint, got %v", err)
+	}
+	if err := s.TrustCatalogService("corp/tool", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetService("corp/tool"); err != nil {
+		t.Errorf("a trusted command should resolve, got %v", err)
+	}
+	if err := s.TrustCatalogService("corp/tool", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetService("corp/tool"); err == nil {
+		t.Error("untrusting should refuse the command again")
+	}
+	if err := s.TrustCatalogService("db", true); err == nil {
+		t.Error("a local service cannot be trusted")
+	}
+}
+
+func TestDeprecation(t *testing.T) {
+	s := newTestStorage(t)
+	for _, name := range []string{"db", "db-v2"} {
+		if err := s.AddService(name, "kubectl port-forward svc/"+name+" 5432:5432"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SetDeprecation("db", &Deprecation{Sunset: "31.12.2026"}); err == nil {
+		t.Error("a sunset that is not YYYY-MM-DD should be rejected")
+	}
+	if err := s.SetDeprecation("db", &Deprecation{Replacement: "db-v2", Sunset: "2026-12-31"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RenameService("db-v2", "pg"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RenameService("db", "old-db"); err != nil {
+		t.Fatal(err)
+	}
+
+	d, ok, err := s.Deprecation("old-db")
+	if err != nil || !ok {
+		t.Fatalf("Deprecation(old-db) = %v, %v", ok, err)
+	}
+	before := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+	if got := d.Describe(before); got != "deprecated: use pg instead, goes away on 2026-12-31" {
+		t.Errorf("Describe = %q", got)
+	}
+	if got := (Deprecation{Sunset: "2026-12-31", Note: "see #db"}).Describe(before.AddDate(0, 1, 0)); got != "deprecated: past its sunset on 2026-12-31 (see #db)" {
+		t.Errorf("Describe after sunset = %q", got)
+	}
+
+	if err := s.SetDeprecation("old-db", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := s.Deprecation("old-db"); ok {
+		t.Error("the deprecation should be gone")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	for memory, want := range map[string]int64{"": 0, "4096": 4096, "64K": 64 << 10, "512M": 512 << 20, "512Mi": 512 << 20, "2GiB": 2 << 30, "1gb": 1 << 30} {
+		if got, err := (Limits{Memory: memory}).MemoryBytes(); err != nil || got != want {
+			t.Errorf("MemoryBytes(%q) = %d, %v; want %d", memory, got, err, want)
+		}
+	}
+	for _, bad := range []Limits{{Memory: "lots"}, {Memory: "-1M"}, {Memory: "0"}, {Nice: -5}, {Nice: 20}, {Files: -1}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", bad)
+		}
+	}
+
+	s := newTestStorage(t)
+	if err := s.AddService("db", "kubectl port-forward svc/db 5432:5432"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := s.LoadData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.Limits = map[string]Limits{"db": {Nice: 10, Files: 256}}
+	if err := s.SaveData(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RenameService("db", "pg"); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := s.ServiceLimits("pg"); err != nil || l.Nice != 10 || l.Files != 256 {
+		t.Errorf("limits should follow the rename, got %+v, %v", l, err)
+	}
+}
+
+func TestSelectVariant(t *testing.T) {
+	variants := []Variant{
+		{OS: "windows", Host: "build-*", Command: "windows build"},
+		{OS: "windows", Command: "windows"},
+		{Host: "Laptop", Command: "laptop"},
+	}
+	for _, tc := range []struct{ goos, host, want string }{
+		{"windows", "BUILD-7", "windows build"},
+		{"windows", "desk", "windows"},
+		{"darwin", "laptop.local", "laptop"},
+		{"linux", "laptop-2", "base"},
+		{"linux", "", "base"},
+	} {
+		if got := SelectVariant("base", variants, tc.goos, tc.host); got != tc.want {
+			t.Errorf("SelectVariant on %s/%s = %q, want %q", tc.goos, tc.host, got, tc.want)
+		}
+	}
+	for _, bad := range []Variant{{Command: "ssh"}, {OS: "macos", Command: "ssh"}, {Host: "[", Command: "ssh"}, {OS: "linux"}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", bad)
+		}
+	}
+}
+
+func TestLocalCommandPicksTheVariant(t *testing.T) {
+	s := newTestStorage(t)
+	command := "ssh -N -L 5432:db.internal:5432 bastion"
+	variant := "/opt/ssh/bin/ssh -N -L 5432:db.internal:5432 bastion"
+	if err := s.AddService("db", command); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := s.readStorage()
+	data.Variants = map[string][]Variant{"db": {{OS: "plan9", Command: "nope"}, {OS: runtime.GOOS, Command: variant}}}
+	if err := s.writeStorage(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := s.LocalCommand("db"); err != nil || got != variant {
+		t.Errorf("LocalCommand = %q, %v; want the %s variant", got, err, runtime.GOOS)
+	}
+	if got, _ := s.GetService("db"); got != command {
+		t.Errorf("GetService should stay on the saved command, got %q", got)
+	}
+	if services, _ := s.LocalServices(); services["db"] != variant {
+		t.Errorf("LocalServices = %v", services)
+	}
+	if err := s.RenameService("db", "pg"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.LocalCommand("pg"); got != variant {
+		t.Errorf("variants should follow the rename, got %q", got)
+	}
+	if err := s.DeleteService("pg"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := s.readStorage(); len(data.Variants) != 0 {
+		t.Errorf("variants should go with the service, got %v", data.Variants)
+	}
+}
+
+func TestCatalogVariantsPassTheAllowList(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"services":{"db":"kubectl port-forward svc/db 5432:5432"},` +
+			`"variants":{"db":[{"os":"` + runtime.GOOS + `","command":"ssh -N -L 5432:db:5432 bastion"}]}}`))
+	}))
+	defer srv.Close()
+
+	s := newTestStorage(t)
+	data, _ := s.readStorage()
+	data.Catalogs = []CatalogConfig{{Name: "corp", URL: srv.URL, Allow: []string{"kubectl"}}}
+	if err := s.writeStorage(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := catalog.Sync(context.Background(), srv.Client(), s.CatalogDir(), "corp", srv.URL, catalog.Verification{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.LocalCommand("corp/db"); err == nil {
+		t.Error("the ssh variant is not on the allow list and should be refused")
+	}
+	if err := s.TrustCatalogService("corp/db", true); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.LocalCommand("corp/db"); err != nil || got != "ssh -N -L 5432:db:5432 bastion" {
+		t.Errorf("the trusted variant should resolve, got %q, %v", got, err)
+	}
+}
+
+func TestOverrideApply(t *testing.T) {
+	for _, tc := range []struct {
+		command string
+		o       Override
+		want    string
+	}{
+		{"kubectl port-forward svc/db 5432:5432", Override{LocalPort: 15432}, "kubectl port-forward svc/db 15432:5432"},
+		{"kubectl --context=prd port-forward svc/db 5432:5432", Override{Context: "kind-dev"}, "kubectl --context=kind-dev port-forward svc/db 5432:5432"},
+		{"kubectl port-forward --context prd svc/db 5432:5432", Override{Context: "kind-dev"}, "kubectl port-forward --context kind-dev svc/db 5432:5432"},
+		{"kubectl port-forward svc/db 5432:5432", Override{LocalPort: 6432, Context: "kind-dev"}, "kubectl --context kind-dev port-forward svc/db 6432:5432"},
+		{"ssh -N -L 127.0.0.1:5432:db:5432 bastion", Override{LocalPort: 15432}, "ssh -N -L 127.0.0.1:15432:db:5432 bastion"},
+		{"ssh -N -L5432:db:5432 bastion", Override{LocalPort: 15432}, "ssh -N -L15432:db:5432 bastion"},
+		{"kubectl proxy --port=8001", Override{LocalPort: 8002}, "kubectl proxy --port=8002"},
+		{"kubectl proxy", Override{LocalPort: 8002}, "kubectl proxy --port=8002"},
+	} {
+		if got, err := tc.o.Apply(tc.command); err != nil || got != tc.want {
+			t.Errorf("Apply(%q, %+v) = %q, %v; want %q", tc.command, tc.o, got, err, tc.want)
+		}
+	}
+	if _, err := (Override{Context: "dev"}).Apply("ssh -N -L 5432:db:5432 bastion"); err == nil {
+		t.Error("a context should not apply to ssh")
+	}
+	for _, bad := range []Override{{}, {LocalPort: 70000}, {Context: "a b"}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", bad)
+		}
+	}
+}
+
+func TestApplyParams(t *testing.T) {
+	for _, tc := range []struct {
+		command string
+		params  map[string]string
+		want    string
+	}{
+		{"kubectl port-forward -n staging svc/api 8080:80", map[string]string{"namespace": "feature-123", "remote-port": "8081"}, "kubectl port-forward -n feature-123 svc/api 8080:8081"},
+		{"kubectl port-forward --namespace=staging svc/api 8080:80", map[string]string{"namespace": "pr-7"}, "kubectl port-forward --namespace=pr-7 svc/api 8080:80"},
+		{"kubectl port-forward svc/api 8080:80", map[string]string{"namespace": "pr-7", "context": "dev"}, "kubectl --context dev --namespace pr-7 port-forward svc/api 8080:80"},
+		{"kubectl port-forward svc/api 8080:80", map[string]string{"local-port": "18080"}, "kubectl port-forward svc/api 18080:80"},
+		{"ssh -N -L 127.0.0.1:5432:db:5432 bastion", map[string]string{"remote-port": "6432"}, "ssh -N -L 127.0.0.1:5432:db:6432 bastion"},
+		{"kubectl port-forward svc/api 8080:80", nil, "kubectl port-forward svc/api 8080:80"},
+	} {
+		if got, err := ApplyParams(tc.command, tc.params); err != nil || got != tc.want {
+			t.Errorf("ApplyParams(%q, %v) = %q, %v; want %q", tc.command, tc.params, got, err, tc.want)
+		}
+	}
+	for _, bad := range []map[string]string{
+		{"target": "svc/x"},
+		{"remote-port": "http"},
+		{"namespace": "a b"},
+	} {
+		if _, err := ApplyParams("kubectl port-forward svc/api 8080:80", bad); err == nil {
+			t.Errorf("ApplyParams(%v) should fail", bad)
+		}
+	}
+	if _, err := ApplyParams("kubectl proxy --port=8001", map[string]string{"remote-port": "80"}); err == nil {
+		t.Error("kubectl proxy has no remote port to set")
+	}
+	if _, err := ApplyParams("ssh -N -L 5432:db:5432 bastion", map[string]string{"namespace": "x"}); err == nil {
+		t.Error("a namespace should not apply to ssh")
+	}
+}
+
+func TestOverridesStayLocal(t *testing.T) {
+	s := newTestStorage(t)
+	command := "kubectl port-forward --context prd svc/db 5432:5432"
+	if err := s.AddService("db", command); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddService("api", "kubectl port-forward svc/api 15432:80"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := s.readStorage()
+	data.Alternates = map[string][]string{"db": {"kubectl port-forward --context prd svc/db-replica 5432:5432"}}
+	if err := s.writeStorage(data); err != nil {
+		t.Fatal(err)
+	}
+	shared, _ := os.ReadFile(s.Path())
+
+	if err := s.SetOverride("db", &Override{LocalPort: 15432, Context: "kind-dev"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetOverride("ghost", &Override{LocalPort: 1}); err == nil {
+		t.Error("an override needs a known service")
+	}
+	if got, _ := os.ReadFile(s.Path()); string(got) != string(shared) {
+		t.Errorf("services.json changed:\n%s", got)
+	}
+	if got, err := s.LocalCommand("db"); err != nil || got != "kubectl port-forward --context kind-dev svc/db 15432:5432" {
+		t.Errorf("LocalCommand = %q, %v", got, err)
+	}
+	if got, _ := s.GetService("db"); got != command {
+		t.Errorf("GetService should stay on the shared command, got %q", got)
+	}
+	if alts, _ := s.Alternates("db"); len(alts) != 1 || alts[0] != "kubectl port-forward --context kind-dev svc/db-replica 15432:5432" {
+		t.Errorf("alternates should move along, got %v", alts)
+	}
+	if conflicts, _ := s.FindPortConflicts([]string{"db", "api"}); len(conflicts) != 1 || conflicts[0].Port != "15432" {
+		t.Errorf("the overridden port should clash with api, got %+v", conflicts)
+	}
+
+	if err := s.RenameService("db", "pg"); err != nil {
+		t.Fatal(err)
+	}
+	if overrides, _ := s.Overrides(); overrides["pg"].LocalPort != 15432 || len(overrides) != 1 {
+		t.Errorf("the override should follow the rename, got %v", overrides)
+	}
+	if err := s.DeleteService("pg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(s.Path()), "services.local.json")); !os.IsNotExist(err) {
+		t.Errorf("the last override went with its service, but the file is still there: %v", err)
+	}
+}
+
+func TestDefaultGroup(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.AddService("db", "kubectl port-forward svc/db 5432:5432"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddGroup("backend", []string{"db"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetDefaultGroup("nope"); err == nil {
+		t.Error("an unknown group should not become the default")
+	}
+	if err := s.SetDefaultGroup("label:team=data"); err != nil {
+		t.Errorf("a virtual group should do: %v", err)
+	}
+
+	if err := s.SetDefaultGroup("backend"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RenameGroup("backend", "core"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.DefaultGroup(); got != "core" {
+		t.Errorf("after a rename the default is %q, want core", got)
+	}
+	if err := s.DeleteGroup("core"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.DefaultGroup(); got != "" {
+		t.Errorf("after deleting the group the default is %q, want none", got)
+	}
+}
+
+func TestExportImportGroups(t *testing.T) {
+	from := newTestStorage(t)
+	for _, name := range []string{"api", "db", "cache"} {
+		if err := from.AddService(name, "kubectl port-forward svc/"+name+" 8080:80"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := from.AddGroup("backend", []string{"api", "db"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := from.AddGroup("data", []string{"db", "cache"}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := from.ExportGroups([]string{"backend"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Groups) != 1 || strings.Join(f.Groups["backend"], ",") != "api,db" {
+		t.Errorf("export = %+v", f)
+	}
+	if _, err := from.ExportGroups([]string{"nope"}); err == nil {
+		t.Error("exporting an unknown group should fail")
+	}
+	all, _ := from.ExportGroups(nil)
+
+	to := newTestStorage(t)
+	if err := to.AddService("db", "kubectl port-forward -n prod svc/db 5432:5432"); err != nil {
+		t.Fatal(err)
+	}
+	if err := to.AddGroup("data", []string{"db"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := to.ImportGroups(all, false, false); err == nil || !strings.Contains(err.Error(), "missing services api (") {
+		t.Errorf("import without the members: %v", err)
+	}
+	if groups, _ := to.ListGroups(); len(groups) != 1 {
+		t.Errorf("a failed import should save nothing, got %v", groups)
+	}
+
+	got, err := to.ImportGroups(all, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got.Added, ",") != "backend" || strings.Join(got.Kept, ",") != "data" || strings.Join(got.Stubs, ",") != "api" {
+		t.Errorf("import = %+v", got)
+	}
+	if command, _ := to.GetService("api"); command != StubCommand("api") {
+		t.Errorf("api = %q, want a stub", command)
+	}
+	if enabled, _ := to.IsEnabled("api"); enabled {
+		t.Error("a stub should be disabled")
+	}
+	if _, err := to.GetService("cache"); err == nil {
+		t.Error("a member only of the kept group should get no stub")
+	}
+	if members, _ := to.GetGroupServices("data"); strings.Join(members, ",") != "db" {
+		t.Errorf("data = %v, want it kept without --overwrite", members)
+	}
+
+	got, err = to.ImportGroups(all, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got.Replaced, ",") != "data" || len(got.Added) != 0 || strings.Join(got.Stubs, ",") != "cache" {
+		t.Errorf("second import = %+v", got)
+	}
+}
+
+func TestImportGroupsKeepsExistingWithoutStubs(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.AddService("db", "kubectl port-forward svc/db 5432:5432"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddGroup("data", []string{"db"}); err != nil {
+		t.Fatal(err)
+	}
+	f := GroupsFile{Groups: map[string][]string{"data": {"db", "cache", "queue"}}}
+
+	got, err := s.ImportGroups(f, false, false)
+	if err != nil {
+		t.Fatalf("import over a group it keeps should not need its members: %v", err)
+	}
+	if strings.Join(got.Kept, ",") != "data" || len(got.Added)+len(got.Replaced)+len(got.Stubs) != 0 {
+		t.Errorf("import = %+v", got)
+	}
+	got, err = s.ImportGroups(f, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Stubs) != 0 {
+		t.Errorf("stubs = %v, want none for a kept group", got.Stubs)
+	}
+	if services, _ := s.ListServiceNames(); len(services) != 1 {
+		t.Errorf("services = %v, want only db", services)
+	}
+	if members, _ := s.GetGroupServices("data"); strings.Join(members, ",") != "db" {
+		t.Errorf("data = %v, want it kept", members)
+	}
+}
+
+func TestArchiveAndRestore(t *testing.T) {
+	s := newTestStorage(t)
+	command := "ssh -N -L 5432:db:5432 old-bastion"
+	if err := s.AddService("db", command); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddService("api", "kubectl port-forward svc/api 8080:80"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddGroup("backend", []string{"api", "db"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := s.readStorage()
+	data.Limits = map[string]Limits{"db": {Nice: 5}}
+	if err := s.writeStorage(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ArchiveService("db", "ssh host 'old-bastion' does not resolve"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = s.readStorage()
+	if _, ok := data.Services["db"]; ok || len(data.Limits) != 0 || strings.Join(data.Groups["backend"], ",") != "api" {
+		t.Errorf("db should be gone from the config, got %+v", data)
+	}
+	archived, _ := s.Archived()
+	if a := archived["db"]; a.Reason == "" || a.Config.Services["db"] != command || a.Config.Limits["db"].Nice != 5 {
+		t.Errorf("archive = %+v", archived)
+	}
+
+	if err := s.DeleteGroup("backend"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RestoreService("db"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = s.readStorage()
+	if data.Services["db"] != command || data.Limits["db"].Nice != 5 || strings.Join(data.Groups["backend"], ",") != "db" {
+		t.Errorf("db should be back as it was, in a recreated group: %+v", data)
+	}
+	if archived, _ := s.Archived(); len(archived) != 0 {
+		t.Errorf("the archive should be empty again, got %v", archived)
+	}
+	if err := s.RestoreService("db"); err == nil {
+		t.Error("restoring what is not archived should fail")
+	}
+}
+
+func TestTrashAndRestore(t *testing.T) {
+	s := newTestStorage(t)
+	command := "kubectl port-forward -n prod svc/postgres 5432:5432"
+	if err := s.AddService("db", command); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddService("api", "kubectl port-forward svc/api 8080:80"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddGroup("backend", []string{"api", "db"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.TrashService("db"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := s.readStorage()
+	if _, ok := data.Services["db"]; ok || strings.Join(data.Groups["backend"], ",") != "api" {
+		t.Errorf("db should be gone from the config, got %+v", data)
+	}
+	if archived, _ := s.Archived(); len(archived) != 0 {
+		t.Errorf("a deleted service should not be archived, got %v", archived)
+	}
+	if err := s.RestoreService("db"); err == nil {
+		t.Error("prune's restore should not find a deleted service")
+	}
+
+	if err := s.RestoreTrashed("db"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = s.readStorage()
+	if data.Services["db"] != command || strings.Join(data.Groups["backend"], ",") != "api,db" {
+		t.Errorf("db should be back as it was: %+v", data)
+	}
+	if trashed, _ := s.Trashed(); len(trashed) != 0 {
+		t.Errorf("the trash should be empty again, got %v", trashed)
+	}
+	if err := s.RestoreTrashed("db"); err == nil || !strings.Contains(err.Error(), "not in the trash") {
+		t.Errorf("restoring what is not in the trash: %v", err)
+	}
+}
+
+func TestTrashPurgesOldEntries(t *testing.T) {
+	s := newTestStorage(t)
+	for _, name := range []string{"old", "new"} {
+		if err := s.AddService(name, "kubectl port-forward svc/"+name+" 8080:80"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.TrashService("old"); err != nil {
+		t.Fatal(err)
+	}
+	trashed, _ := s.Trashed()
+	old := trashed["old"]
+	old.Archived = time.Now().Add(-TrashRetention - time.Hour)
+	if err := writeArchive(s.TrashFile(), map[string]ArchivedService{"old": old}); err != nil {
+		t.Fatal(err)
+	}
+
+	if trashed, _ := s.Trashed(); len(trashed) != 0 {
+		t.Errorf("an entry past the retention should be gone, got %v", trashed)
+	}
+	if err := s.RestoreTrashed("old"); err == nil {
+		t.Error("an entry past the retention should not be restorable")
+	}
+	if err := s.TrashService("new"); err != nil {
+		t.Fatal(err)
+	}
+	var file archiveFile
+	raw, _ := os.ReadFile(s.TrashFile())
+	if err := json.Unmarshal(raw, &file); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := file.Services["old"]; ok || len(file.Services) != 1 {
+		t.Errorf("writing the trash should purge old entries, got %v", file.Services)
+	}
+}
+
+func TestDisabledServices(t *testing.T) {
+	s := newTestStorage(t)
+	for _, name := range []string{"api", "db", "old"} {
+		if err := s.AddService(name, "kubectl port-forward svc/"+name+" 8080:80"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AddGroup("backend", []string{"old", "db"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetEnabled("old", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetEnabled("ghost", false); err == nil {
+		t.Error("disabling an unknown service should fail")
+	}
+
+	if names, _ := s.EnabledServiceNames(); strings.Join(names, ",") != "api,db" {
+		t.Errorf("EnabledServiceNames = %v", names)
+	}
+	if members, _ := s.EnabledGroupServices("backend"); strings.Join(members, ",") != "db" {
+		t.Errorf("EnabledGroupServices = %v", members)
+	}
+	if members, _ := s.GetGroupServices("backend"); strings.Join(members, ",") != "old,db" {
+		t.Errorf("the group itself should keep old, got %v", members)
+	}
+	if err := s.RenameService("old", "legacy"); err != nil {
+		t.Fatal(err)
+	}
+	if enabled, _ := s.IsEnabled("legacy"); enabled {
+		t.Error("the rename should keep the service disabled")
+	}
+	if err := s.SetEnabled("legacy", true); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := s.readStorage(); len(data.Enabled) != 0 {
+		t.Errorf("enabling should leave no entry, got %v", data.Enabled)
+	}
+}
+
+func TestSelectServices(t *testing.T) {
+	s := newTestStorage(t)
+	for name, command := range map[string]string{
+		"api":  "kubectl port-forward -n staging --context prod svc/api 8080:80",
+		"db":   "kubectl port-forward --namespace=staging svc/db 5432:5432",
+		"web":  "kubectl port-forward svc/web 3000:80",
+		"old":  "kubectl port-forward -n staging svc/old 8081:80",
+		"jump": "ssh -L 6379:redis:6379 bastion",
+	} {
+		if err := s.AddService(name, command); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SetLabels("api", map[string]string{"team": "payments", "tier": "backend"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetLabels("web", map[string]string{"team": "growth"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetLabels("api", nil, []string{"tier"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetLabels("api", map[string]string{"bad key": "x"}, nil); err == nil {
+		t.Error("a label key with a space should be refused")
+	}
+	if err := s.SetLabels("ghost", map[string]string{"team": "x"}, nil); err == nil {
+		t.Error("labelling an unknown service should fail")
+	}
+	if err := s.SetEnabled("old", false); err != nil {
+		t.Fatal(err)
+	}
+
+	for selector, want := range map[string]string{
+		"label:team=payments": "api",
+		"label:team":          "api,web",
+		"label:tier":          "",
+		"ns:staging":          "api,db",
+		"context:prod":        "api",
+	} {
+		got, err := s.SelectServices(selector)
+		if err != nil {
+			t.Fatalf("SelectServices(%q): %v", selector, err)
+		}
+		if strings.Join(got, ",") != want {
+			t.Errorf("SelectServices(%q) = %v, want [%s]", selector, got, want)
+		}
+	}
+	if IsSelector("ns:") || IsSelector("api") || !IsSelector("label:team") {
+		t.Error("IsSelector misjudged a target")
+	}
+
+	if err := s.RenameService("api", "gateway"); err != nil {
+		t.Fatal(err)
+	}
+	if labels, _ := s.Labels("gateway"); labels["team"] != "payments" {
+		t.Errorf("the rename should carry the labels, got %v", labels)
+	}
+	if err := s.DeleteService("web"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := s.readStorage(); len(data.Labels) != 1 {
+		t.Errorf("deleting should drop the labels, got %v", data.Labels)
+	}
+}
+
+func TestEphemeralServices(t *testing.T) {
+	s := newTestStorage(t)
+	if err := s.AddService("api", "kubectl port-forward -n staging svc/api 8080:80"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddGroup("backend", []string{"api"}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	command, err := s.AddEphemeral("pr-42-api", "api", map[string]string{"namespace": "pr-42", "local-port": "18080"}, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command != "kubectl port-forward -n pr-42 svc/api 18080:80" {
+		t.Errorf("command = %q", command)
+	}
+	if _, err := s.AddEphemeral("pr-43-api", "api", nil, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddEphemeral("backend", "api", nil, time.Time{}); err == nil {
+		t.Error("an ephemeral service should not take a group's name")
+	}
+	if _, err := s.AddEphemeral("pr-44-api", "ghost", nil, time.Time{}); err == nil {
+		t.Error("an unknown template should fail")
+	}
+
+	if expired, err := s.ExpireEphemeral(now, false); err != nil || len(expired) != 0 {
+		t.Fatalf("nothing is due yet, got %v, %v", expired, err)
+	}
+	if expired, _ := s.ExpireEphemeral(now.Add(2*time.Hour), false); strings.Join(expired, ",") != "pr-42-api" {
+		t.Errorf("after the TTL, expired = %v", expired)
+	}
+	if expired, _ := s.ExpireEphemeral(now, true); strings.Join(expired, ",") != "pr-43-api" {
+		t.Errorf("a cleanup takes those without a TTL, expired = %v", expired)
+	}
+	data, _ := s.readStorage()
+	if len(data.Ephemeral) != 0 || len(data.Services) != 1 {
+		t.Errorf("services = %v, ephemeral = %v", data.Services, data.Ephemeral)
+	}
+}
+
+func TestRestartScheduleNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone data")
+	}
+	r := RestartSchedule{At: []string{"15:00", "03:00"}, Zone: "America/New_York"}
+	for _, c := range []struct{ now, want time.Time }{
+		{time.Date(2026, 5, 4, 1, 0, 0, 0, ny), time.Date(2026, 5, 4, 3, 0, 0, 0, ny)},
+		{time.Date(2026, 5, 4, 3, 0, 0, 0, ny), time.Date(2026, 5, 4, 15, 0, 0, 0, ny)},
+		{time.Date(2026, 5, 4, 16, 0, 0, 0, ny), time.Date(2026, 5, 5, 3, 0, 0, 0, ny)},
+		// 06:30 UTC is 02:30 in New York: the zone decides, not the clock given.
+		{time.Date(2026, 5, 4, 6, 30, 0, 0, time.UTC), time.Date(2026, 5, 4, 3, 0, 0, 0, ny)},
+	} {
+		got, err := r.Next(c.now)
+		if err != nil || !got.Equal(c.want) {
+			t.Errorf("Next(%v) = %v, %v; want %v", c.now, got, err, c.want)
+		}
+	}
+
+	// 02:30 does not exist on the day clocks spring forward.
+	gap := RestartSchedule{At: []string{"02:30"}, Zone: "America/New_York"}
+	got, _ := gap.Next(time.Date(2026, 3, 8, 1, 0, 0, 0, ny))
+	if want := time.Date(2026, 3, 8, 3, 30, 0, 0, ny); !got.Equal(want) {
+		t.Errorf("Next across the DST gap = %v, want %v", got, want)
+	}
+
+	for _, bad := range []RestartSchedule{{}, {At: []string{"25:00"}}, {At: []string{"03:00"}, Zone: "Nowhere/Town"}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v should be invalid", bad)
+		}
+	}
+}
+
+func TestShutdownSettings(t *testing.T) {
+	s := newTestStorage(t)
+	content := []byte(`{"services": {"db": "ssh -L 5432:db:5432 bastion", "api": "ssh -L 8080:api:80 bastion"},
+		"shutdown": {"order": ["api", "db"], "timeout": "2s", "timeouts": {"db": "10s"}}}`)
+	if err := os.WriteFile(s.filePath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := s.Shutdown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]time.Duration{"db": 10 * time.Second, "api": 2 * time.Second} {
+		if got, err := cfg.TimeoutFor(name); err != nil || got != want {
+			t.Errorf("TimeoutFor(%s) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+
+	if err := s.RenameService("db", "postgres"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteService("api"); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _ = s.Shutdown()
+	if !slices.Equal(cfg.Order, []string{"postgres"}) || cfg.Timeouts["postgres"] != "10s" || len(cfg.Timeouts) != 1 {
+		t.Errorf("after rename and delete: %+v", cfg)
+	}
+
+	for _, bad := range []ShutdownConfig{
+		{Timeout: "soon"},
+		{Timeouts: map[string]string{"db": "-1s"}},
+		{Order: []string{"db", "db"}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v should be invalid", bad)
+		}
+	}
+}
+
+func TestServiceHistoryAndRollback(t *testing.T) {
+	s := newTestStorage(t)
+	for _, command := range []string{
+		"kubectl port-forward svc/db 5432:5432",
+		"kubectl port-forward svc/db 5433:5432",
+		"kubectl port-forward svc/db 5433:5432", // unchanged: no revision
+		"kubectl port-forward -n data svc/db 5433:5432",
+	} {
+		if err := s.AddService("db", command); err != nil {
+			t.Fatal(err)
+		}
+	}
+	revisions, err := s.ServiceHistory("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 || revisions[0].Command != "kubectl port-forward svc/db 5433:5432" || revisions[1].Command != "kubectl port-forward svc/db 5432:5432" {
+		t.Fatalf("history = %+v", revisions)
+	}
+
+	restored, err := s.RollbackService("db", 2)
+	if err != nil || restored != "kubectl port-forward svc/db 5432:5432" {
+		t.Fatalf("RollbackService = %q, %v", restored, err)
+	}
+	if command, _ := s.GetService("db"); command != restored {
+		t.Errorf("command after rollback = %q", command)
+	}
+	if revisions, _ := s.ServiceHistory("db"); len(revisions) != 3 || revisions[0].Command != "kubectl port-forward -n data svc/db 5433:5432" {
+		t.Errorf("the replaced command should be history too: %+v", revisions)
+	}
+	if _, err := s.RollbackService("db", 4); err == nil {
+		t.Error("rolling back past the history should fail")
+	}
+
+	data, _ := s.LoadData()
+	data.Services["db"] = "kubectl port-forward svc/pg 5432:5432"
+	if err := s.SaveData(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RenameService("db", "pg"); err != nil {
+		t.Fatal(err)
+	}
+	if revisions, _ := s.ServiceHistory("pg"); len(revisions) != 4 || revisions[0].Command != restored {
+		t.Errorf("history after edit and rename = %+v", revisions)
+	}
+	if err := s.DeleteService("pg"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := s.LoadData(); data.History != nil && len(data.History) != 0 {
+		t.Errorf("history kept after delete: %+v", data.History)
+	}
+}
+
+// TestServiceSettingsFollowTheService fills every per-service map of
+// StorageData, so a new one left out of moveServiceSettings fails here.
+func TestServiceSettingsFollowTheService(t *testing.T) {
+	notPerService := map[string]bool{"Services": true, "Groups": true, "Themes": true, "Keymap": true, "Confirm": true, "Legacy": true}
+	fill := func(data *StorageData, name string) (filled []string) {
+		v := reflect.ValueOf(data).Elem()
+		for i := range v.NumField() {
+			f := v.Type().Field(i)
+			if f.Type.Kind() != reflect.Map || notPerService[f.Name] {
+				continue
+			}
+			m := reflect.MakeMap(f.Type)
+			m.SetMapIndex(reflect.ValueOf(name), reflect.New(f.Type.Elem()).Elem())
+			v.Field(i).Set(m)
+			filled = append(filled, f.Name)
+		}
+		return filled
+	}
+	holds := func(data *StorageData, name string) (fields []string) {
+		v := reflect.ValueOf(data).Elem()
+		for i := range v.NumField() {
+			f := v.Type().Field(i)
+			if f.Type.Kind() == reflect.Map && !notPerService[f.Name] && v.Field(i).MapIndex(reflect.ValueOf(name)).IsValid() {
+				fields = append(fields, f.Name)
+			}
+		}
+		return fields
+	}
+
+	data := &StorageData{Services: map[string]string{"db": "kubectl port-forward svc/db 5432:5432"}}
+	filled := fill(data, "db")
+	moveServiceSettings(data, data, "db", "pg")
+	if left := holds(data, "db"); len(left) != 0 {
+		t.Errorf("rename left %v under the old name", left)
+	}
+	if moved := holds(data, "pg"); !slices.Equal(moved, filled) {
+		t.Errorf("rename moved %v, want %v", moved, filled)
+	}
+	dropServiceSettings(data, "pg")
+	if left := holds(data, "pg"); len(left) != 0 {
+		t.Errorf("delete left %v", left)
+	}
+}
@@ -0,0 +1,52 @@
+// Package totp generates time-based one-time codes (RFC 6238) as
+// authenticator apps do, for bastions that ask for one on every connection.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Defaults of authenticator apps, which most secrets are set up for.
+const (
+	DefaultDigits = 6
+	DefaultPeriod = 30 * time.Second
+)
+
+// Decode parses a base32 secret as authenticator apps take it: any case,
+// with or without spaces and padding.
+func Decode(secret string) ([]byte, error) {
+	s := strings.ToUpper(strings.Join(strings.Fields(secret), ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(s, "="))
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("the TOTP secret is not base32")
+	}
+	return key, nil
+}
+
+// Code returns the code for key at t: HMAC-SHA1 over the number of periods
+// since the Unix epoch, truncated to digits decimal digits.
+func Code(key []byte, t time.Time, digits int, period time.Duration) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/int64(period/time.Second)))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for range digits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+// Next is when the period holding t ends and the code changes.
+func Next(t time.Time, period time.Duration) time.Time {
+	return t.Truncate(period).Add(period)
+}
@@ -0,0 +1,38 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1: the ASCII secret "12345678901234567890".
+	key, err := Decode("gezd gnbv gy3t qojq gezd gnbv gy3t qojq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for unix, want := range map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	} {
+		if got := Code(key, time.Unix(unix, 0), 8, DefaultPeriod); got != want {
+			t.Errorf("Code at %d = %s, want %s", unix, got, want)
+		}
+	}
+	if got := Code(key, time.Unix(59, 0), DefaultDigits, DefaultPeriod); got != "287082" {
+		t.Errorf("six digits = %s", got)
+	}
+	if _, err := Decode("not base32!"); err == nil {
+		t.Error("a bad secret should not decode")
+	}
+}
+
+func TestNext(t *testing.T) {
+	if got := Next(time.Unix(59, 0), DefaultPeriod); !got.Equal(time.Unix(60, 0)) {
+		t.Errorf("Next = %v", got.Unix())
+	}
+}
@@ -0,0 +1,54 @@
+package ui
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// benchServices are n running services with full logs, as a busy session has.
+func benchServices(n int) []model.Service {
+	services := make([]model.Service, n)
+	for i := range services {
+		svc := chattyService(120)
+		svc.Name = fmt.Sprintf("svc-%02d", i)
+		svc.LocalPort = fmt.Sprint(8000 + i)
+		services[i] = svc
+	}
+	return services
+}
+
+// BenchmarkUpdateAndView measures a frame of the live view: a state change
+// arrives, the UI takes it in and renders.
+func BenchmarkUpdateAndView(b *testing.B) {
+	services := benchServices(20)
+	u := newSizedUI(services, 160, 50)
+	ctrl := u.manager.(*fakeController)
+	b.ReportAllocs()
+	i := 0
+	for b.Loop() {
+		// A new log line on one service, as in a steady session.
+		svc := &ctrl.states[i%len(ctrl.states)]
+		svc.Logs = append(svc.Logs[1:], model.LogEntry{Time: time.Unix(int64(i), 0), Message: "Handling connection for 8000"})
+		u.Update(stateChangedMsg{})
+		_ = u.View()
+		i++
+	}
+}
+
+// BenchmarkRenderServiceTable measures the service table when one row
+// changes between frames.
+func BenchmarkRenderServiceTable(b *testing.B) {
+	services := benchServices(20)
+	var cache rowCache
+	b.ReportAllocs()
+	i := 0
+	for b.Loop() {
+		svc := &services[i%len(services)]
+		svc.RestartCount++
+		_ = renderServiceTableCached(&cache, services, 0, 0, 20, 160)
+		i++
+	}
+}
@@ -0,0 +1,219 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alinemone/go-port-forward/internal/stringutil"
+)
+
+// Action is a named main-view command that can be bound to one or more keys.
+// The names are what users write in the "keymap" section of the config.
+type Action string
+
+const (
+	ActionQuit       Action = "quit"
+	ActionUp         Action = "up"
+	ActionDown       Action = "down"
+	ActionPageUp     Action = "pageUp"
+	ActionPageDown   Action = "pageDown"
+	ActionHalfUp     Action = "halfPageUp"
+	ActionHalfDown   Action = "halfPageDown"
+	ActionTop        Action = "top"
+	ActionBottom     Action = "bottom"
+	ActionRestart    Action = "restart"
+	ActionRestartAll Action = "restartAll"
+	ActionStop       Action = "stop"
+	ActionAdd        Action = "add"
+	ActionGroups     Action = "groups"
+	ActionConfig     Action = "config"
+	ActionLogScope   Action = "filter"
+	ActionFollow     Action = "follow"
+	ActionLayout     Action = "layout"
+	ActionFold       Action = "fold"
+	ActionPalette    Action = "palette"
+	ActionCopy       Action = "copy"
+)
+
+// defaultBindings is the built-in keymap. Arrow keys and their vim-style
+// alternatives share an action, so remapping one keeps the other unless the
+// user lists both.
+var defaultBindings = map[Action][]string{
+	ActionQuit:       {"q", "esc"},
+	ActionUp:         {"up", "k"},
+	ActionDown:       {"down", "j"},
+	ActionPageUp:     {"pgup"},
+	ActionPageDown:   {"pgdown"},
+	ActionHalfUp:     {"ctrl+u"},
+	ActionHalfDown:   {"ctrl+d"},
+	ActionTop:        {"home"},
+	ActionBottom:     {"end"},
+	ActionRestart:    {"r"},
+	ActionRestartAll: {"ctrl+r"},
+	ActionStop:       {"s"},
+	ActionAdd:        {"a"},
+	ActionGroups:     {"g"},
+	ActionConfig:     {"c"},
+	ActionLogScope:   {"l"},
+	ActionFollow:     {"f"},
+	ActionLayout:     {"tab"},
+	ActionFold:       {"z"},
+	ActionPalette:    {":"},
+	ActionCopy:       {"y"},
+}
+
+// Keymap resolves pressed keys to actions and renders key labels for the help
+// bar. ctrl+c always quits regardless of the map, so a broken config can never
+// trap the user in the TUI.
+type Keymap struct {
+	keys  map[Action][]string
+	byKey map[string]Action
+}
+
+// DefaultKeymap returns the built-in bindings.
+func DefaultKeymap() Keymap {
+	km, _ := NewKeymap(nil)
+	return km
+}
+
+// NewKeymap layers overrides (action name → keys) over the defaults. An action
+// listed in overrides replaces its default keys entirely. Unknown action names,
+// empty key lists, and a key bound to two actions are errors.
+func NewKeymap(overrides map[string][]string) (Keymap, error) {
+	keys := make(map[Action][]string, len(defaultBindings))
+	for a, ks := range defaultBindings {
+		keys[a] = ks
+	}
+
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		action := Action(name)
+		if _, ok := defaultBindings[action]; !ok {
+			return Keymap{}, fmt.Errorf("keymap: unknown action %q", name)
+		}
+		var bound []string
+		for _, k := range overrides[name] {
+			if k = normalizeKey(k); k != "" {
+				bound = append(bound, k)
+			}
+		}
+		if len(bound) == 0 {
+			return Keymap{}, fmt.Errorf("keymap: action %q has no keys", name)
+		}
+		keys[action] = bound
+	}
+
+	byKey := make(map[string]Action)
+	actions := make([]string, 0, len(keys))
+	for a := range keys {
+		actions = append(actions, string(a))
+	}
+	sort.Strings(actions)
+	for _, name := range actions {
+		a := Action(name)
+		for _, k := range keys[a] {
+			if k == "ctrl+c" && a != ActionQuit {
+				return Keymap{}, fmt.Errorf("keymap: ctrl+c is reserved for quit")
+			}
+			if other, dup := byKey[k]; dup && other != a {
+				return Keymap{}, fmt.Errorf("keymap: key %q is bound to both %q and %q", k, other, a)
+			}
+			byKey[k] = a
+		}
+	}
+	return Keymap{keys: keys, byKey: byKey}, nil
+}
+
+// normalizeKey folds a configured key the same way pressed keys are folded in
+// Update, so "Ctrl+R" in the config matches the ctrl+r key press.
+func normalizeKey(k string) string {
+	if strings.EqualFold(strings.TrimSpace(k), "space") {
+		return "space"
+	}
+	return stringutil.NormalizeToken(k)
+}
+
+// Action returns the action bound to the (already normalized) key.
+func (km Keymap) Action(key string) (Action, bool) {
+	if key == "ctrl+c" {
+		return ActionQuit, true
+	}
+	a, ok := km.byKey[key]
+	return a, ok
+}
+
+// Label renders the keys bound to a for the help bar, e.g. "↑/k" or "^r".
+func (km Keymap) Label(a Action) string {
+	ks := km.keys[a]
+	labels := make([]string, 0, len(ks))
+	for _, k := range ks {
+		labels = append(labels, keyLabel(k))
+	}
+	return strings.Join(labels, "/")
+}
+
+// PrimaryLabel renders only the first key bound to a, for compact help bars.
+func (km Keymap) PrimaryLabel(a Action) string {
+	ks := km.keys[a]
+	if len(ks) == 0 {
+		return ""
+	}
+	return keyLabel(ks[0])
+}
+
+// MoveLabel renders the cursor movement keys as one chip label: the primary
+// up/down pair followed by any alternatives, e.g. "↑↓/j/k".
+func (km Keymap) MoveLabel() string {
+	label := km.PrimaryLabel(ActionUp) + km.PrimaryLabel(ActionDown)
+	var alts []string
+	for _, a := range []Action{ActionDown, ActionUp} {
+		if ks := km.keys[a]; len(ks) > 1 {
+			for _, k := range ks[1:] {
+				alts = append(alts, keyLabel(k))
+			}
+		}
+	}
+	if len(alts) == 0 {
+		return label
+	}
+	return label + "/" + strings.Join(alts, "/")
+}
+
+func keyLabel(k string) string {
+	switch k {
+	case "up":
+		return "↑"
+	case "down":
+		return "↓"
+	case "left":
+		return "←"
+	case "right":
+		return "→"
+	case "pgdown":
+		return "pgdn"
+	}
+	if rest, ok := strings.CutPrefix(k, "ctrl+"); ok {
+		return "^" + rest
+	}
+	return k
+}
+
+// activeKeymap is the keymap the main view uses. Like the theme colors it is
+// process-wide, set once at startup by ApplyKeymap.
+var activeKeymap = DefaultKeymap()
+
+// ApplyKeymap installs the user's keymap overrides. On error the previous map
+// stays active and the error is returned for the caller to report.
+func ApplyKeymap(overrides map[string][]string) error {
+	km, err := NewKeymap(overrides)
+	if err != nil {
+		return err
+	}
+	activeKeymap = km
+	return nil
+}
@@ -0,0 +1,94 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestDefaultKeymapResolvesBuiltins(t *testing.T) {
+	km := DefaultKeymap()
+	for key, want := range map[string]Action{
+		"q":      ActionQuit,
+		"esc":    ActionQuit,
+		"ctrl+c": ActionQuit,
+		"k":      ActionUp,
+		"down":   ActionDown,
+		"ctrl+r": ActionRestartAll,
+		"l":      ActionLogScope,
+	} {
+		if got, ok := km.Action(key); !ok || got != want {
+			t.Errorf("Action(%q) = %q, %v; want %q", key, got, ok, want)
+		}
+	}
+	if _, ok := km.Action("x"); ok {
+		t.Error("Action(x) should be unbound")
+	}
+}
+
+func TestNewKeymapOverrideReplacesDefaults(t *testing.T) {
+	km, err := NewKeymap(map[string][]string{"restart": {"Ctrl+T", "space"}})
+	if err != nil {
+		t.Fatalf("NewKeymap: %v", err)
+	}
+	if _, ok := km.Action("r"); ok {
+		t.Error("default r should no longer be bound after overriding restart")
+	}
+	if got, ok := km.Action("ctrl+t"); !ok || got != ActionRestart {
+		t.Errorf("Action(ctrl+t) = %q, %v; want restart", got, ok)
+	}
+	if got := km.Label(ActionRestart); got != "^t/space" {
+		t.Errorf("Label(restart) = %q, want ^t/space", got)
+	}
+	// Untouched actions keep their defaults.
+	if got, ok := km.Action("s"); !ok || got != ActionStop {
+		t.Errorf("Action(s) = %q, %v; want stop", got, ok)
+	}
+}
+
+func TestNewKeymapRejectsInvalid(t *testing.T) {
+	for name, overrides := range map[string]map[string][]string{
+		"unknown action": {"explode": {"x"}},
+		"empty keys":     {"stop": {" "}},
+		"duplicate key":  {"stop": {"r"}},
+		"steals ctrl+c":  {"stop": {"ctrl+c"}},
+	} {
+		if _, err := NewKeymap(overrides); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestApplyKeymapKeepsPreviousOnError(t *testing.T) {
+	defer func() { activeKeymap = DefaultKeymap() }()
+
+	if err := ApplyKeymap(map[string][]string{"stop": {"x"}}); err != nil {
+		t.Fatalf("ApplyKeymap: %v", err)
+	}
+	if err := ApplyKeymap(map[string][]string{"bogus": {"y"}}); err == nil {
+		t.Fatal("expected error for unknown action")
+	}
+	if got, ok := activeKeymap.Action("x"); !ok || got != ActionStop {
+		t.Errorf("previous keymap should stay active, Action(x) = %q, %v", got, ok)
+	}
+}
+
+func TestHelpLinesReflectActiveKeymap(t *testing.T) {
+	defer func() { activeKeymap = DefaultKeymap() }()
+
+	if got := DefaultKeymap().MoveLabel(); got != "↑↓/j/k" {
+		t.Errorf("default MoveLabel = %q, want ↑↓/j/k", got)
+	}
+
+	if err := ApplyKeymap(map[string][]string{"restart": {"ctrl+t"}, "stop": {"x"}}); err != nil {
+		t.Fatalf("ApplyKeymap: %v", err)
+	}
+	out := ansi.Strip(strings.Join(helpLines(140, helpState{logScope: "ALL"}), "\n"))
+	if !strings.Contains(out, "^t restart") || !strings.Contains(out, "x stop") {
+		t.Errorf("help bar should show remapped keys, got %q", out)
+	}
+	if strings.Contains(out, "s stop") {
+		t.Errorf("help bar still shows default stop key: %q", out)
+	}
+}
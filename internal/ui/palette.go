@@ -0,0 +1,407 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/storage"
+	"github.com/alinemone/go-port-forward/internal/stringutil"
+)
+
+// paletteCommand is one entry in the ":" command palette. title is both what
+// the list shows and what the query is fuzzy-matched against.
+type paletteCommand struct {
+	title string
+	run   func(u *UI) tea.Cmd
+}
+
+// openPalette builds the command list from the running services and stored
+// groups, then shows the palette with an empty query. The list is a snapshot:
+// services that start or stop while it is open show up the next time.
+func (u *UI) openPalette() {
+	u.paletteMode = true
+	u.paletteQuery = ""
+	u.paletteCursor = 0
+	u.paletteOffset = 0
+	u.paletteCommands = u.buildPaletteCommands()
+	u.filterPalette()
+}
+
+func (u *UI) closePalette() {
+	u.paletteMode = false
+	u.paletteQuery = ""
+	u.paletteCommands = nil
+	u.paletteMatches = nil
+}
+
+func (u *UI) buildPaletteCommands() []paletteCommand {
+	var cmds []paletteCommand
+
+	for i := range u.services {
+		name := u.services[i].Name
+		if prompt := u.services[i].Prompt; prompt != "" {
+			cmds = append(cmds, paletteCommand{"answer " + name, func(u *UI) tea.Cmd {
+				return u.openPrompt(name, prompt)
+			}})
+		}
+		cmds = append(cmds,
+			paletteCommand{"restart " + name, func(u *UI) tea.Cmd {
+				u.manager.RestartService(u.ctx, name)
+				return nil
+			}},
+			paletteCommand{"stop " + name, func(u *UI) tea.Cmd {
+				return func() tea.Msg {
+					u.manager.StopService(name)
+					return nil
+				}
+			}},
+			paletteCommand{"copy address " + name, func(u *UI) tea.Cmd {
+				for i := range u.services {
+					if u.services[i].Name == name {
+						return u.copyAddress(&u.services[i])
+					}
+				}
+				return nil
+			}},
+			paletteCommand{"logs: only " + name, func(u *UI) tea.Cmd {
+				u.selectService(name)
+				u.logFilterSelected = true
+				u.refreshViewportContent()
+				u.viewport.GotoBottom()
+				u.logFollow = true
+				return nil
+			}},
+		)
+	}
+
+	groups, err := storage.NewStorage().ListGroups()
+	if err != nil {
+		groups = nil
+	}
+	groupNames := make([]string, 0, len(groups))
+	for name := range groups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+	running := u.runningNameSet()
+	for _, group := range groupNames {
+		var up, down []string
+		for _, member := range groups[group] {
+			if running[member] {
+				up = append(up, member)
+			} else {
+				down = append(down, member)
+			}
+		}
+		if len(down) > 0 {
+			cmds = append(cmds, paletteCommand{"start group " + group, func(u *UI) tea.Cmd {
+				return u.startServices(down)
+			}})
+		}
+		if len(up) > 0 {
+			cmds = append(cmds,
+				paletteCommand{"restart group " + group, func(u *UI) tea.Cmd {
+					for _, name := range up {
+						u.manager.RestartService(u.ctx, name)
+					}
+					return nil
+				}},
+				paletteCommand{"stop group " + group, func(u *UI) tea.Cmd {
+					return func() tea.Msg {
+						for _, name := range up {
+							u.manager.StopService(name)
+						}
+						return nil
+					}
+				}},
+			)
+		}
+	}
+
+	if len(u.services) > 0 {
+		cmds = append(cmds, paletteCommand{"restart all", func(u *UI) tea.Cmd {
+			u.manager.RestartAllServices(u.ctx)
+			return nil
+		}})
+	}
+	cmds = append(cmds, paletteCommand{"logs: all services", func(u *UI) tea.Cmd {
+		u.logFilterSelected = false
+		u.refreshViewportContent()
+		u.viewport.GotoBottom()
+		u.logFollow = true
+		return nil
+	}})
+	for _, mode := range []string{sortByName, sortByStatus, sortByPort} {
+		cmds = append(cmds, paletteCommand{"sort by " + mode, func(u *UI) tea.Cmd {
+			u.setSort(mode)
+			return nil
+		}})
+	}
+	cmds = append(cmds,
+		paletteCommand{"toggle follow", func(u *UI) tea.Cmd {
+			u.logFollow = !u.logFollow
+			if u.logFollow {
+				u.viewport.GotoBottom()
+			}
+			return nil
+		}},
+		paletteCommand{"toggle log layout", func(u *UI) tea.Cmd {
+			u.logGrouped = !u.logGrouped
+			u.refreshViewportContent()
+			return nil
+		}},
+		paletteCommand{"add/edit services", func(u *UI) tea.Cmd {
+			u.enterManageMode(true)
+			return nil
+		}},
+		paletteCommand{"manage groups", func(u *UI) tea.Cmd {
+			u.enterManageMode(false)
+			return nil
+		}},
+		paletteCommand{"new group from running services", func(u *UI) tea.Cmd {
+			u.enterManageMode(false)
+			return u.openNewGroupFormWith(u.groupCandidates())
+		}},
+		paletteCommand{"edit config", func(u *UI) tea.Cmd {
+			return u.launchEditor()
+		}},
+		paletteCommand{"quit", func(u *UI) tea.Cmd {
+			return u.quit()
+		}},
+	)
+	return cmds
+}
+
+// startServices starts stored services that are not running and reports the
+// outcome on the status line, like the manage overlay's Enter.
+func (u *UI) startServices(names []string) tea.Cmd {
+	var failed []string
+	for _, name := range names {
+		if err := u.manager.StartStoredService(u.ctx, name); err != nil {
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) > 0 {
+		return u.setStatus(fmt.Sprintf("✗ Failed to start: %s", strings.Join(failed, ", ")))
+	}
+	return u.setStatus(fmt.Sprintf("✓ Started %d service(s)", len(names)))
+}
+
+// filterPalette re-ranks the commands against the current query. Better fuzzy
+// scores sort first; ties keep the build order so related entries stay together.
+func (u *UI) filterPalette() {
+	type scored struct {
+		cmd   paletteCommand
+		score int
+	}
+	var hits []scored
+	for _, c := range u.paletteCommands {
+		if s, ok := fuzzyScore(u.paletteQuery, c.title); ok {
+			hits = append(hits, scored{c, s})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+
+	u.paletteMatches = make([]paletteCommand, len(hits))
+	for i, h := range hits {
+		u.paletteMatches[i] = h.cmd
+	}
+	u.paletteCursor = 0
+	u.paletteOffset = 0
+}
+
+// fuzzyScore reports whether every rune of query appears in text in order
+// (case-insensitive) and scores the match: consecutive runs and matches at the
+// start of a word score higher, so "rsdb" ranks "restart db" above "restart
+// dashboard-backend". An empty query matches everything with score 0.
+func fuzzyScore(query, text string) (int, bool) {
+	q := []rune(strings.ToLower(strings.TrimSpace(query)))
+	if len(q) == 0 {
+		return 0, true
+	}
+	t := []rune(strings.ToLower(text))
+
+	score, qi, prev := 0, 0, -2
+	for ti := 0; ti < len(t) && qi < len(q); ti++ {
+		if t[ti] != q[qi] {
+			continue
+		}
+		switch {
+		case ti == prev+1:
+			score += 3
+		case ti == 0 || !unicode.IsLetter(t[ti-1]) && !unicode.IsDigit(t[ti-1]):
+			score += 2
+		default:
+			score++
+		}
+		prev = ti
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return score, true
+}
+
+// fuzzyFilter returns the names query fuzzy-matches (see fuzzyScore), best
+// first; ties keep their order.
+func fuzzyFilter(query string, names []string) []string {
+	type scored struct {
+		name  string
+		score int
+	}
+	var hits []scored
+	for _, name := range names {
+		if s, ok := fuzzyScore(query, name); ok {
+			hits = append(hits, scored{name, s})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+	matches := make([]string, len(hits))
+	for i, h := range hits {
+		matches[i] = h.name
+	}
+	return matches
+}
+
+func (u *UI) updatePalette(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	keyRaw := msg.String()
+	key := keyRaw
+	if keyRaw != "space" {
+		key = stringutil.NormalizeToken(keyRaw)
+	}
+
+	switch key {
+	case "esc":
+		// Like the manage overlay: the first Esc clears the query, the next closes.
+		if u.paletteQuery != "" {
+			u.paletteQuery = ""
+			u.filterPalette()
+		} else {
+			u.closePalette()
+		}
+	case "ctrl+c":
+		u.closePalette()
+	case "up", "ctrl+p":
+		if u.paletteCursor > 0 {
+			u.paletteCursor--
+		}
+	case "down", "ctrl+n":
+		if u.paletteCursor < len(u.paletteMatches)-1 {
+			u.paletteCursor++
+		}
+	case "enter":
+		if u.paletteCursor >= len(u.paletteMatches) {
+			return u, nil
+		}
+		c := u.paletteMatches[u.paletteCursor]
+		u.closePalette()
+		return u, c.run(u)
+	case "backspace":
+		if u.paletteQuery != "" {
+			r := []rune(u.paletteQuery)
+			u.paletteQuery = string(r[:len(r)-1])
+			u.filterPalette()
+		}
+	case "space":
+		u.paletteQuery += " "
+		u.filterPalette()
+	default:
+		if rs := []rune(keyRaw); len(rs) == 1 && unicode.IsPrint(rs[0]) {
+			u.paletteQuery += keyRaw
+			u.filterPalette()
+		}
+	}
+	return u, nil
+}
+
+// paletteVisibleRows is how many commands fit under the palette's title and
+// query line, leaving room for the box border and the action chips.
+func (u *UI) paletteVisibleRows() int {
+	if u.height <= 0 {
+		return 20
+	}
+	v := u.height - 7
+	if v < 5 {
+		v = 5
+	}
+	if v > 20 {
+		v = 20
+	}
+	return v
+}
+
+func (u *UI) ensurePaletteVisible() {
+	visible := u.paletteVisibleRows()
+	if u.paletteCursor < u.paletteOffset {
+		u.paletteOffset = u.paletteCursor
+	}
+	if u.paletteCursor >= u.paletteOffset+visible {
+		u.paletteOffset = u.paletteCursor - visible + 1
+	}
+	if maxOff := len(u.paletteMatches) - visible; u.paletteOffset > maxOff {
+		u.paletteOffset = max(maxOff, 0)
+	}
+}
+
+func (u *UI) renderPalette() string {
+	width := u.width
+	if width <= 0 {
+		width = 120
+	}
+	if width < 60 {
+		width = 60
+	}
+
+	u.ensurePaletteVisible()
+	visible := u.paletteVisibleRows()
+	start := u.paletteOffset
+	end := min(start+visible, len(u.paletteMatches))
+
+	label := lipgloss.NewStyle().Foreground(colorAccent).Bold(true).Render(":")
+	cursor := lipgloss.NewStyle().Foreground(colorAccent).Render("▏")
+	query := lipgloss.NewStyle().Foreground(colorAccentAlt).Bold(true).Render(u.paletteQuery) + cursor
+	if u.paletteQuery == "" {
+		query = cursor + lipgloss.NewStyle().Foreground(colorMuted).Italic(true).Render("type a command…")
+	}
+
+	rows := make([]string, 0, end-start+3)
+	rows = append(rows, lipgloss.NewStyle().Foreground(colorAccent).Bold(true).Render("COMMANDS")+
+		lipgloss.NewStyle().Foreground(colorMuted).Render("  — run an action by name"))
+	rows = append(rows, label+" "+query)
+	if len(u.paletteMatches) == 0 {
+		rows = append(rows, lipgloss.NewStyle().Foreground(colorMuted).Italic(true).Render("  (no matching commands)"))
+	}
+	for i := start; i < end; i++ {
+		title := truncateDisplay(u.paletteMatches[i].title, width-8)
+		if i == u.paletteCursor {
+			rows = append(rows, lipgloss.NewStyle().Foreground(colorAccent).Bold(true).Render("► "+title))
+			continue
+		}
+		rows = append(rows, "  "+lipgloss.NewStyle().Foreground(colorText).Render(title))
+	}
+	if len(u.paletteMatches) > visible {
+		rows = append(rows, lipgloss.NewStyle().Foreground(colorMuted).
+			Render(fmt.Sprintf("(%d–%d of %d)", start+1, end, len(u.paletteMatches))))
+	}
+
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorBorder).
+		Padding(0, 1).
+		Width(width - 2).
+		Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+
+	chips := renderActionChips([][2]string{
+		{"type", "filter"},
+		{"↑↓", "navigate"},
+		{"Enter", "run"},
+		{"Esc", "clear/close"},
+	})
+	return lipgloss.JoinVertical(lipgloss.Left, box, chips)
+}
@@ -0,0 +1,151 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/storage"
+)
+
+func TestFuzzyScoreRanksWordStartsAndRuns(t *testing.T) {
+	if _, ok := fuzzyScore("rsx", "restart db"); ok {
+		t.Error("rsx should not match restart db")
+	}
+	if s, ok := fuzzyScore("", "anything"); !ok || s != 0 {
+		t.Errorf("empty query = %d, %v; want 0, true", s, ok)
+	}
+	near, ok1 := fuzzyScore("rsdb", "restart db")
+	far, ok2 := fuzzyScore("rsdb", "restart dashboard-backend")
+	if !ok1 || !ok2 {
+		t.Fatal("rsdb should match both titles")
+	}
+	if near <= far {
+		t.Errorf("restart db (%d) should outrank restart dashboard-backend (%d)", near, far)
+	}
+}
+
+func typeKeys(u *UI, s string) {
+	for _, r := range s {
+		if r == ' ' {
+			u.Update(tea.KeyPressMsg{Code: tea.KeySpace, Text: " "})
+			continue
+		}
+		u.Update(tea.KeyPressMsg{Code: r, Text: string(r)})
+	}
+}
+
+func TestPaletteRunsMatchedCommand(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", t.TempDir())
+
+	u := newSizedUI([]model.Service{
+		{Name: "api", LocalPort: "9000", Status: model.StatusHealthy},
+		{Name: "db", LocalPort: "5432", Status: model.StatusHealthy},
+	}, 120, 40)
+
+	typeKeys(u, ":")
+	if !u.paletteMode {
+		t.Fatal(": should open the command palette")
+	}
+	typeKeys(u, "sort by port")
+	if len(u.paletteMatches) == 0 || u.paletteMatches[0].title != "sort by port" {
+		t.Fatalf("best match = %v, want sort by port", u.paletteMatches)
+	}
+	u.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	if u.paletteMode {
+		t.Fatal("Enter should close the palette")
+	}
+	if u.sortMode != sortByPort || u.services[0].Name != "db" {
+		t.Errorf("table should be sorted by port, got mode %q first %q", u.sortMode, u.services[0].Name)
+	}
+}
+
+func TestPaletteEscClearsThenCloses(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", t.TempDir())
+
+	u := newSizedUI([]model.Service{chattyService(3)}, 120, 40)
+	typeKeys(u, ":stop")
+	u.Update(tea.KeyPressMsg{Code: tea.KeyEscape})
+	if !u.paletteMode || u.paletteQuery != "" {
+		t.Fatal("first Esc should only clear the query")
+	}
+	u.Update(tea.KeyPressMsg{Code: tea.KeyEscape})
+	if u.paletteMode {
+		t.Fatal("second Esc should close the palette")
+	}
+	if u.quitting {
+		t.Fatal("Esc in the palette must not quit the app")
+	}
+}
+
+func TestSortByStatusPutsErrorsFirst(t *testing.T) {
+	services := []model.Service{
+		{Name: "a", Status: model.StatusHealthy},
+		{Name: "b", Status: model.StatusError},
+		{Name: "c", Status: model.StatusConnecting},
+	}
+	sortServices(services, sortByStatus)
+	if got := services[0].Name + services[1].Name + services[2].Name; got != "bca" {
+		t.Errorf("status order = %q, want bca", got)
+	}
+}
+
+func TestPickerSearchesFuzzilyAndQuitsWhenClosedEmpty(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", t.TempDir())
+	st := storage.NewStorage()
+	for name, port := range map[string]string{"dashboard": "3000", "db": "5432", "api": "9000"} {
+		if err := st.AddService(name, "kubectl port-forward svc/"+name+" "+port+":"+port); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u := newSizedUI(nil, 120, 40)
+	u.OpenPicker()
+	if !u.manageMode {
+		t.Fatal("the picker should open the groups and services list")
+	}
+	typeKeys(u, "db")
+	var names []string
+	for _, row := range u.manageRows {
+		if row.kind == rowService {
+			names = append(names, row.name)
+		}
+	}
+	if strings.Join(names, ",") != "db,dashboard" {
+		t.Fatalf("matches = %v, want db before dashboard and no api", names)
+	}
+	typeKeys(u, " ")
+	if !u.manageSelSvcs["db"] {
+		t.Fatalf("Space should tick the best match, got %v", u.manageSelSvcs)
+	}
+
+	u.Update(tea.KeyPressMsg{Code: tea.KeyEscape})
+	if u.quitting || !u.manageMode {
+		t.Fatal("the first Esc should only clear the search")
+	}
+	u.Update(tea.KeyPressMsg{Code: tea.KeyEscape})
+	if !u.quitting {
+		t.Fatal("closing the picker with nothing running should quit")
+	}
+}
+
+func TestPickerRunsTheSelection(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", t.TempDir())
+	if err := storage.NewStorage().AddService("db", "kubectl port-forward svc/db 5432:5432"); err != nil {
+		t.Fatal(err)
+	}
+
+	u := newSizedUI(nil, 120, 40)
+	u.OpenPicker()
+	typeKeys(u, " ")
+	u.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	if u.manageMode || u.picking || u.quitting {
+		t.Errorf("Enter should start the selection and show the session, got manage %v picking %v quitting %v", u.manageMode, u.picking, u.quitting)
+	}
+}
@@ -0,0 +1,404 @@
+package ui
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// Plain is the --accessible front end: instead of redrawing an alt-screen
+// table it prints one plain-text line per status change, a full summary every
+// so often, and reads commands line by line. No colour, glyphs or cursor
+// movement, so screen readers and braille displays get every update in order.
+type Plain struct {
+	manager  Controller
+	in       io.Reader
+	out      io.Writer
+	confirm  ConfirmOptions
+	interval time.Duration // between periodic summaries; 0 disables them
+	deadline time.Time     // session TTL end; zero = none
+
+	last    map[string]string // name → last reported status line
+	pending func() bool       // action waiting for a y/n answer; returns true to quit
+}
+
+// NewPlain builds the accessible front end over the same controller the TUI
+// uses. Confirmation prompts default to on, as in the TUI.
+func NewPlain(manager Controller, in io.Reader, out io.Writer) *Plain {
+	return &Plain{
+		manager:  manager,
+		in:       in,
+		out:      out,
+		confirm:  ConfirmOptions{Stop: true, Restart: true, Quit: true},
+		interval: time.Minute,
+		last:     map[string]string{},
+	}
+}
+
+// SetConfirm replaces the default confirmation settings.
+func (p *Plain) SetConfirm(c ConfirmOptions) {
+	p.confirm = c
+}
+
+// SetDeadline announces when the session's TTL stops every forward and prints
+// a warning TTLWarning beforehand, like the TUI's countdown.
+func (p *Plain) SetDeadline(t time.Time) {
+	p.deadline = t
+}
+
+// Run prints updates and serves commands until the user quits, input ends
+// (Ctrl+D) or ctx is cancelled. Stopping the services is left to the caller,
+// exactly as after the TUI exits.
+func (p *Plain) Run(ctx context.Context) error {
+	done := make(chan struct{})
+	defer close(done)
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		sc := bufio.NewScanner(p.in)
+		for sc.Scan() {
+			select {
+			case lines <- sc.Text():
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var tick <-chan time.Time
+	if p.interval > 0 {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var warn <-chan time.Time
+	if !p.deadline.IsZero() {
+		warn = time.After(time.Until(p.deadline.Add(-TTLWarning)))
+	}
+
+	p.printf("pf accessible mode. Type help for commands, or press Enter for status.")
+	if !p.deadline.IsZero() {
+		p.printf("All forwards stop at %s (session TTL).", p.deadline.Format("15:04"))
+	}
+	p.reportChanges()
+
+	updates := p.manager.Updates()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-updates:
+			p.reportChanges()
+		case <-tick:
+			p.printSummary()
+		case <-warn:
+			warn = nil
+			p.printf("Warning: session TTL, all forwards stop in %s.", formatDuration(max(time.Until(p.deadline), 0)))
+		case line, ok := <-lines:
+			if !ok {
+				return nil
+			}
+			if p.handleLine(ctx, line) {
+				return nil
+			}
+		}
+	}
+}
+
+// handleLine runs one typed command and reports whether the session should end.
+func (p *Plain) handleLine(ctx context.Context, line string) bool {
+	fields := strings.Fields(line)
+
+	if p.pending != nil {
+		action := p.pending
+		p.pending = nil
+		if len(fields) > 0 && (strings.EqualFold(fields[0], "y") || strings.EqualFold(fields[0], "yes")) {
+			return action()
+		}
+		p.printf("Cancelled.")
+		return false
+	}
+
+	if len(fields) == 0 {
+		p.printSummary()
+		return false
+	}
+
+	cmd, args := strings.ToLower(fields[0]), fields[1:]
+	switch cmd {
+	case "help", "h", "?":
+		p.printHelp()
+	case "status", "list", "ls":
+		p.printSummary()
+	case "restart":
+		if len(args) == 0 {
+			p.printf("Usage: restart <name> or restart all")
+			return false
+		}
+		if strings.EqualFold(args[0], "all") {
+			p.ask(p.confirm.Restart, "Restart all services?", func() bool {
+				p.manager.RestartAllServices(ctx)
+				p.printf("Restarting all services.")
+				return false
+			})
+			return false
+		}
+		name, ok := p.runningName(args[0])
+		if !ok {
+			return false
+		}
+		p.ask(p.confirm.Restart, "Restart "+name+"?", func() bool {
+			if err := p.manager.RestartService(ctx, name); err != nil {
+				p.printf("Could not restart %s: %v", name, err)
+			} else {
+				p.printf("Restarting %s.", name)
+			}
+			return false
+		})
+	case "stop":
+		if len(args) == 0 {
+			p.printf("Usage: stop <name>")
+			return false
+		}
+		name, ok := p.runningName(args[0])
+		if !ok {
+			return false
+		}
+		p.ask(p.confirm.Stop, "Stop "+name+"?", func() bool {
+			p.manager.StopService(name)
+			p.printf("Stopping %s.", name)
+			return false
+		})
+	case "start":
+		if len(args) == 0 {
+			p.printf("Usage: start <name>")
+			return false
+		}
+		if err := p.manager.StartStoredService(ctx, args[0]); err != nil {
+			p.printf("Could not start %s: %v", args[0], err)
+		} else {
+			p.printf("Starting %s.", args[0])
+		}
+	case "logs", "log":
+		if len(args) == 0 {
+			p.printf("Usage: logs <name> [count]")
+			return false
+		}
+		count := 10
+		if len(args) > 1 {
+			if n, err := strconv.Atoi(args[1]); err == nil && n > 0 {
+				count = n
+			}
+		}
+		p.printLogs(args[0], count)
+	case "quit", "q", "exit":
+		ask := p.confirm.Quit && len(p.manager.ListServiceStates()) > 0
+		return p.ask(ask, "Quit and stop all services?", func() bool { return true })
+	default:
+		p.printf("Unknown command %q. Type help for commands.", cmd)
+	}
+	return false
+}
+
+// ask runs action now, or when confirmation is on, prints the question and
+// runs it if the next line is y or yes. Its result is action's when run now.
+func (p *Plain) ask(confirm bool, question string, action func() bool) bool {
+	if !confirm {
+		return action()
+	}
+	p.printf("%s Type y to confirm, anything else to cancel.", question)
+	p.pending = action
+	return false
+}
+
+// runningName matches a typed name against the running services
+// case-insensitively, reporting when nothing matches.
+func (p *Plain) runningName(typed string) (string, bool) {
+	for _, svc := range p.manager.ListServiceStates() {
+		if strings.EqualFold(svc.Name, typed) {
+			return svc.Name, true
+		}
+	}
+	p.printf("No running service named %s.", typed)
+	return "", false
+}
+
+// reportChanges prints a line for every service or started group whose status
+// (or error) has changed since the last report, and for services that have
+// gone away.
+func (p *Plain) reportChanges() {
+	services := sortedByName(p.manager.ListServiceStates())
+	seen := make(map[string]bool, len(services))
+	for i := range services {
+		svc := &services[i]
+		seen[svc.Name] = true
+		line := plainStatusLine(svc)
+		if p.last[svc.Name] != line {
+			p.last[svc.Name] = line
+			p.printf("%s", line)
+		}
+	}
+	for _, g := range p.manager.GroupStates() {
+		key := "group " + g.Name // cannot clash: service names have no spaces
+		seen[key] = true
+		line := plainGroupLine(g)
+		if p.last[key] != line {
+			p.last[key] = line
+			p.printf("%s", line)
+		}
+	}
+	var gone []string
+	for name := range p.last {
+		if !seen[name] {
+			gone = append(gone, name)
+		}
+	}
+	sort.Strings(gone)
+	for _, name := range gone {
+		delete(p.last, name)
+		p.printf("%s: stopped.", name)
+	}
+}
+
+func (p *Plain) printSummary() {
+	services := sortedByName(p.manager.ListServiceStates())
+	if len(services) == 0 {
+		p.printf("No services running.")
+		return
+	}
+	counts := map[string]int{}
+	for i := range services {
+		counts[services[i].Status]++
+	}
+	var parts []string
+	for _, status := range []string{model.StatusHealthy, model.StatusConnecting, model.StatusError} {
+		if n := counts[status]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, status))
+		}
+	}
+	p.printf("Status at %s: %d services, %s.", time.Now().Format("15:04"), len(services), strings.Join(parts, ", "))
+	for i := range services {
+		line := plainStatusLine(&services[i])
+		p.last[services[i].Name] = line
+		p.printf("%s", line)
+	}
+	for _, g := range p.manager.GroupStates() {
+		line := plainGroupLine(g)
+		p.last["group "+g.Name] = line
+		p.printf("%s", line)
+	}
+}
+
+func (p *Plain) printLogs(typed string, count int) {
+	for _, svc := range p.manager.ListServiceStates() {
+		if !strings.EqualFold(svc.Name, typed) {
+			continue
+		}
+		logs := svc.Logs
+		if len(logs) > count {
+			logs = logs[len(logs)-count:]
+		}
+		if len(logs) == 0 {
+			p.printf("No log lines for %s.", svc.Name)
+			return
+		}
+		p.printf("Last %d log lines for %s:", len(logs), svc.Name)
+		for _, entry := range logs {
+			p.printf("%s %s", entry.Time.Format("15:04:05"), entry.Message)
+		}
+		return
+	}
+	p.printf("No running service named %s.", typed)
+}
+
+func (p *Plain) printHelp() {
+	for _, line := range []string{
+		"Commands:",
+		"  status, or Enter: list every service and its status",
+		"  logs <name> [count]: print the last log lines of a service (default 10)",
+		"  restart <name>, or restart all",
+		"  stop <name>",
+		"  start <name>: start another stored service",
+		"  quit, or Ctrl+D: stop all services and exit",
+	} {
+		p.printf("%s", line)
+	}
+}
+
+func (p *Plain) printf(format string, args ...any) {
+	fmt.Fprintf(p.out, format+"\n", args...)
+}
+
+// plainStatusLine describes one service in a sentence, e.g.
+// "db: healthy, 127.0.0.1:15432 to svc/postgres:5432." Errors carry the last
+// error text so it is read out without opening the logs.
+func plainStatusLine(svc *model.Service) string {
+	target := svc.MainPort
+	if svc.Target != "" {
+		target = svc.Target + ":" + svc.MainPort
+	}
+	now := time.Now()
+	if svc.InMaintenance(now) {
+		return fmt.Sprintf("%s: in maintenance until %s.", svc.Name, svc.MaintenanceUntil.Format("15:04"))
+	}
+	if svc.RollingOut {
+		return fmt.Sprintf("%s: waiting for a rollout to finish.", svc.Name)
+	}
+	if svc.Flapping(now) {
+		if svc.LastError != "" {
+			return fmt.Sprintf("%s: flapping, last error %s", svc.Name, svc.LastError)
+		}
+		return fmt.Sprintf("%s: flapping.", svc.Name)
+	}
+	if svc.Monitor != "" {
+		// A monitor listens nowhere; say what it checks instead.
+		switch svc.Status {
+		case model.StatusHealthy:
+			return fmt.Sprintf("%s: healthy, monitoring %s.", svc.Name, svc.Monitor)
+		case model.StatusError:
+			return fmt.Sprintf("%s: error, %s", svc.Name, svc.LastError)
+		}
+		return fmt.Sprintf("%s: checking %s.", svc.Name, svc.Monitor)
+	}
+	switch svc.Status {
+	case model.StatusHealthy:
+		if svc.Degraded {
+			return fmt.Sprintf("%s: degraded, on its fallback command, %s to %s.", svc.Name, localAddress(svc), target)
+		}
+		if target == "" {
+			return fmt.Sprintf("%s: healthy on %s.", svc.Name, localAddress(svc))
+		}
+		return fmt.Sprintf("%s: healthy, %s to %s.", svc.Name, localAddress(svc), target)
+	case model.StatusError:
+		if svc.LastError != "" {
+			return fmt.Sprintf("%s: error, %s", svc.Name, svc.LastError)
+		}
+		return fmt.Sprintf("%s: error.", svc.Name)
+	default:
+		return fmt.Sprintf("%s: connecting on %s.", svc.Name, localAddress(svc))
+	}
+}
+
+// plainGroupLine says whether a started group is usable as a whole.
+func plainGroupLine(g model.GroupState) string {
+	line := fmt.Sprintf("Group %s: %s, %d of %d healthy", g.Name, g.Status, g.Healthy, len(g.Members))
+	if g.Reason != "" {
+		return line + ", " + g.Reason
+	}
+	return line + "."
+}
+
+func sortedByName(services []model.Service) []model.Service {
+	out := append([]model.Service(nil), services...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
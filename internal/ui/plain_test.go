@@ -0,0 +1,77 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+type stopRecorder struct {
+	fakeController
+	stopped []string
+}
+
+func (r *stopRecorder) StopService(name string) { r.stopped = append(r.stopped, name) }
+
+func TestPlainReportsOnlyStatusChanges(t *testing.T) {
+	ctrl := &fakeController{states: []model.Service{
+		{Name: "db", LocalPort: "15432", MainPort: "5432", Target: "svc/postgres", Status: model.StatusConnecting},
+	}}
+	var out bytes.Buffer
+	p := NewPlain(ctrl, strings.NewReader(""), &out)
+
+	p.reportChanges()
+	p.reportChanges()
+	if got := strings.Count(out.String(), "db: connecting"); got != 1 {
+		t.Fatalf("unchanged status should be reported once, got %d times: %q", got, out.String())
+	}
+
+	ctrl.states[0].Status = model.StatusHealthy
+	p.reportChanges()
+	if !strings.Contains(out.String(), "db: healthy, 127.0.0.1:15432 to svc/postgres:5432.") {
+		t.Errorf("missing healthy line: %q", out.String())
+	}
+
+	ctrl.states = nil
+	p.reportChanges()
+	if !strings.Contains(out.String(), "db: stopped.") {
+		t.Errorf("missing stopped line: %q", out.String())
+	}
+	if strings.ContainsRune(out.String(), '\x1b') {
+		t.Errorf("accessible output must not contain escape sequences: %q", out.String())
+	}
+}
+
+func TestPlainStopAsksBeforeActing(t *testing.T) {
+	ctrl := &stopRecorder{fakeController: fakeController{states: []model.Service{
+		{Name: "db", Status: model.StatusHealthy},
+	}}}
+	var out bytes.Buffer
+	p := NewPlain(ctrl, strings.NewReader(""), &out)
+	ctx := context.Background()
+
+	p.handleLine(ctx, "stop DB")
+	if len(ctrl.stopped) != 0 {
+		t.Fatal("stop ran before it was confirmed")
+	}
+	p.handleLine(ctx, "n")
+	if len(ctrl.stopped) != 0 || !strings.Contains(out.String(), "Cancelled.") {
+		t.Fatalf("answering n should cancel: %q", out.String())
+	}
+
+	p.handleLine(ctx, "stop db")
+	p.handleLine(ctx, "y")
+	if len(ctrl.stopped) != 1 || ctrl.stopped[0] != "db" {
+		t.Fatalf("stopped = %v, want [db]", ctrl.stopped)
+	}
+
+	if p.handleLine(ctx, "quit") {
+		t.Fatal("quit with running services should ask first")
+	}
+	if !p.handleLine(ctx, "yes") {
+		t.Fatal("confirmed quit should end the session")
+	}
+}
@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/alinemone/go-port-forward/internal/statedir"
+)
+
+// Prefs are the live view's layout choices, kept across sessions in
+// ~/.pf/state/ui.json so a new session looks like the last one left off. The
+// theme is not among them: `pf theme` keeps it in the config.
+type Prefs struct {
+	Sort      string   `json:"sort,omitempty"`      // sortByName, sortByStatus or sortByPort
+	Selected  bool     `json:"selected,omitempty"`  // the log shows the selected service only
+	Follow    bool     `json:"follow"`              // the log keeps to the newest line
+	Grouped   bool     `json:"grouped,omitempty"`   // the log is grouped by service
+	Collapsed []string `json:"collapsed,omitempty"` // services folded in the grouped log
+}
+
+// PrefsPath is where the live view's preferences live: ~/.pf/state/ui.json.
+func PrefsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".pf", "state", "ui.json"), nil
+}
+
+// LoadPrefs reads the preferences at path. A missing or unreadable file gives
+// the defaults, with the error of an unreadable one.
+func LoadPrefs(path string) (Prefs, error) {
+	p := Prefs{Sort: sortByName, Follow: true}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Prefs{Sort: sortByName, Follow: true}, err
+	}
+	return p, nil
+}
+
+// SavePrefs writes p to path, replacing what was there in one step.
+func SavePrefs(path string, p Prefs) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return statedir.WriteFile(path, data, 0600)
+}
+
+// SetPrefs lays the view out as p says. Call it before the program starts.
+func (u *UI) SetPrefs(p Prefs) {
+	switch p.Sort {
+	case sortByName, sortByStatus, sortByPort:
+		u.sortMode = p.Sort
+	}
+	u.logFilterSelected = p.Selected
+	u.logFollow = p.Follow
+	u.logGrouped = p.Grouped
+	u.logCollapsed = make(map[string]bool, len(p.Collapsed))
+	for _, name := range p.Collapsed {
+		u.logCollapsed[name] = true
+	}
+}
+
+// Prefs returns the view's layout as it is now, to save when the session
+// ends.
+func (u *UI) Prefs() Prefs {
+	p := Prefs{Sort: u.sortMode, Selected: u.logFilterSelected, Follow: u.logFollow, Grouped: u.logGrouped}
+	for name, folded := range u.logCollapsed {
+		if folded {
+			p.Collapsed = append(p.Collapsed, name)
+		}
+	}
+	sort.Strings(p.Collapsed)
+	return p
+}
@@ -0,0 +1,38 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestPrefsRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "ui.json")
+	p, err := LoadPrefs(path)
+	if err != nil || p.Sort != sortByName || !p.Follow {
+		t.Fatalf("a missing file = %+v, %v; want the defaults", p, err)
+	}
+
+	u := newSizedUI([]model.Service{{Name: "db"}, {Name: "api"}}, 120, 40)
+	u.SetPrefs(Prefs{Sort: sortByPort, Selected: true, Grouped: true, Collapsed: []string{"db"}})
+	if u.sortMode != sortByPort || !u.logFilterSelected || u.logFollow || !u.logGrouped || !u.logCollapsed["db"] {
+		t.Fatalf("SetPrefs did not lay the view out: %+v", u.Prefs())
+	}
+	u.logCollapsed["api"] = true
+	if err := SavePrefs(path, u.Prefs()); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadPrefs(path)
+	want := Prefs{Sort: sortByPort, Selected: true, Grouped: true, Collapsed: []string{"api", "db"}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %+v, %v; want %+v", got, err, want)
+	}
+
+	os.WriteFile(path, []byte("{"), 0600)
+	if p, err := LoadPrefs(path); err == nil || p.Sort != sortByName || !p.Follow {
+		t.Errorf("a broken file = %+v, %v; want the defaults and an error", p, err)
+	}
+}
@@ -0,0 +1,92 @@
+package ui
+
+import (
+	"strings"
+
+	"charm.land/bubbles/v2/textinput"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+)
+
+// promptLines is how many lines the prompt input takes below the log box.
+const promptLines = 2
+
+// syncPrompt keeps the prompt input in step with the services: it closes the
+// input once its prompt is gone (answered elsewhere, or the process moved on
+// or died) and opens it for the first service waiting on a prompt the user
+// has not dismissed, unless an overlay or a y/n question is in the way.
+func (u *UI) syncPrompt() tea.Cmd {
+	if u.promptService != "" {
+		for i := range u.services {
+			if u.services[i].Name == u.promptService && u.services[i].Prompt == u.promptText {
+				return nil
+			}
+		}
+		u.closePrompt()
+	}
+	if u.manageMode || u.paletteMode || u.confirmRun != nil {
+		return nil
+	}
+	for i := range u.services {
+		svc := &u.services[i]
+		if svc.Prompt != "" && u.promptDismissed[svc.Name] != svc.Prompt {
+			return u.openPrompt(svc.Name, svc.Prompt)
+		}
+	}
+	return nil
+}
+
+// openPrompt asks for the answer to a service's prompt. Answers are masked,
+// except to yes/no questions.
+func (u *UI) openPrompt(name, prompt string) tea.Cmd {
+	in := textinput.New()
+	in.Prompt = ""
+	in.CharLimit = 1000
+	in.SetWidth(max(u.width-32, 10)) // room for the key chips beside it
+	if !strings.Contains(strings.ToLower(prompt), "(yes/no") {
+		in.EchoMode = textinput.EchoPassword
+		in.EchoCharacter = '•'
+	}
+	u.promptService, u.promptText, u.promptInput = name, prompt, in
+	return u.promptInput.Focus()
+}
+
+func (u *UI) closePrompt() {
+	u.promptService, u.promptText = "", ""
+	u.promptInput = textinput.Model{}
+}
+
+// updatePrompt sends the answer on Enter. Esc dismisses the prompt until the
+// service asks something else; the palette's "answer" command brings it back.
+func (u *UI) updatePrompt(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		name, answer := u.promptService, u.promptInput.Value()
+		u.closePrompt()
+		if err := u.manager.AnswerPrompt(name, answer); err != nil {
+			return u, u.setStatus("✗ " + err.Error())
+		}
+		return u, u.setStatus("Sent the answer to " + name)
+	case "esc":
+		if u.promptDismissed == nil {
+			u.promptDismissed = make(map[string]string)
+		}
+		u.promptDismissed[u.promptService] = u.promptText
+		u.closePrompt()
+		return u, nil
+	case "ctrl+c":
+		return u, u.quit()
+	}
+	var cmd tea.Cmd
+	u.promptInput, cmd = u.promptInput.Update(msg)
+	return u, cmd
+}
+
+// renderPrompt shows the prompt and the answer typed so far, below the log.
+func (u *UI) renderPrompt() string {
+	question := lipgloss.NewStyle().Foreground(colorWarn).Bold(true).Render("⌨ "+u.promptService+" asks: ") +
+		lipgloss.NewStyle().Foreground(colorText).Render(u.promptText)
+	answer := lipgloss.NewStyle().Foreground(colorAccent).Render("› ") + u.promptInput.View() + "  " +
+		renderActionChips([][2]string{{"Enter", "send"}, {"Esc", "dismiss"}})
+	return truncateDisplay(question, u.width) + "\n" + truncateDisplay(answer, u.width)
+}
@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestPromptAsksAndSendsTheAnswer(t *testing.T) {
+	fake := &fakeController{
+		states:  []model.Service{{Name: "db", LocalPort: "5432", Status: model.StatusConnecting, Prompt: "me@bastion's password:"}},
+		updates: make(chan struct{}, 1),
+	}
+	u := NewUI(fake, context.Background())
+	u.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	u.Update(stateChangedMsg{})
+	if u.promptService != "db" {
+		t.Fatal("a service waiting on a prompt should open the input")
+	}
+	if view := ansi.Strip(u.viewContent()); !strings.Contains(view, "db asks: me@bastion's password:") {
+		t.Errorf("view should show the prompt:\n%s", view)
+	}
+
+	typeKeys(u, "s3cret")
+	if strings.Contains(ansi.Strip(u.viewContent()), "s3cret") {
+		t.Error("the answer to a password prompt should be masked")
+	}
+	u.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	if u.promptService != "" || fake.answered["db"] != "s3cret" {
+		t.Fatalf("Enter should send the answer, got %q", fake.answered)
+	}
+
+	fake.states[0].Prompt = "Verification code:"
+	u.Update(stateChangedMsg{})
+	if u.promptText != "Verification code:" {
+		t.Fatal("the next prompt should open the input again")
+	}
+	u.Update(tea.KeyPressMsg{Code: tea.KeyEscape})
+	u.Update(stateChangedMsg{})
+	if u.promptService != "" {
+		t.Error("a dismissed prompt should stay closed while the service asks the same")
+	}
+	fake.states[0].Prompt = ""
+	u.Update(stateChangedMsg{})
+	if badge := nameBadge(&fake.states[0]); strings.Contains(badge, "⌨") {
+		t.Errorf("badge = %q once the service asks nothing", badge)
+	}
+}
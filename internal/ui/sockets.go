@@ -0,0 +1,102 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+// socketRefresh is how often the detail view samples its service's
+// connections again.
+const socketRefresh = 5 * time.Second
+
+// socketSample is the latest sample of a service's connections; see
+// Controller.SocketStats.
+type socketSample struct {
+	name    string
+	at      time.Time
+	sockets []model.Socket
+	err     error
+	pending bool // a sample is being taken
+}
+
+// socketsMsg delivers a sample taken by sampleSockets.
+type socketsMsg struct {
+	name    string
+	sockets []model.Socket
+	err     error
+}
+
+// detailService is the service whose log and details the view shows alone;
+// "" when it shows every service's.
+func (u *UI) detailService() string {
+	if !u.logFilterSelected {
+		return ""
+	}
+	return u.selectedServiceName()
+}
+
+// sampleSockets samples the connections of the service in the detail view,
+// when it has none yet or the last is socketRefresh old. The controller runs
+// ss, so the sample is taken off the UI's goroutine.
+func (u *UI) sampleSockets() tea.Cmd {
+	name := u.detailService()
+	if name == "" || u.sockets.pending || (name == u.sockets.name && time.Since(u.sockets.at) < socketRefresh) {
+		return nil
+	}
+	u.sockets.pending = true
+	return func() tea.Msg {
+		sockets, err := u.manager.SocketStats(name)
+		return socketsMsg{name: name, sockets: sockets, err: err}
+	}
+}
+
+// setSockets records a sample and shows it, when its service is still the
+// one in the detail view.
+func (u *UI) setSockets(msg socketsMsg) {
+	u.sockets = socketSample{name: msg.name, at: time.Now(), sockets: msg.sockets, err: msg.err}
+	if msg.name == u.detailService() {
+		u.refreshViewportContent()
+	}
+}
+
+// renderSockets lists a service's connections to the far side with their
+// round-trip time and retransmits, e.g. "10.0.0.2:40122 → 10.1.2.3:22  rtt
+// 15.2ms ±3.1ms  retrans 2". Nothing when the system cannot tell.
+func renderSockets(sample socketSample, maxWidth int) []string {
+	var lines []string
+	switch {
+	case errors.Is(sample.err, errors.ErrUnsupported):
+		return nil
+	case sample.err != nil:
+		lines = []string{"Connections: " + sample.err.Error()}
+	case len(sample.sockets) == 0:
+		lines = []string{"Connections: none to the far side"}
+	}
+	for _, s := range sample.sockets {
+		line := fmt.Sprintf("Connection %s → %s  rtt %s ±%s", s.Local, s.Remote, formatRTT(s.RTT), formatRTT(s.RTTVar))
+		if s.Retrans > 0 {
+			line += fmt.Sprintf("  retrans %d", s.Retrans)
+		}
+		lines = append(lines, line)
+	}
+	style := lipgloss.NewStyle().Foreground(colorMuted)
+	for i, line := range lines {
+		lines[i] = style.Render(truncateDisplay(line, maxWidth))
+	}
+	return lines
+}
+
+// formatRTT shows a round-trip time in milliseconds, to a tenth below 100ms.
+func formatRTT(d time.Duration) string {
+	ms := float64(d) / float64(time.Millisecond)
+	if ms < 100 {
+		return fmt.Sprintf("%.1fms", ms)
+	}
+	return fmt.Sprintf("%.0fms", ms)
+}
@@ -0,0 +1,57 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/alinemone/go-port-forward/internal/model"
+)
+
+func TestDetailShowsTheServiceConnections(t *testing.T) {
+	fake := &fakeController{
+		states:  []model.Service{{Name: "db", LocalPort: "5432", Status: model.StatusHealthy}},
+		updates: make(chan struct{}, 1),
+		sockets: map[string][]model.Socket{"db": {{Local: "10.0.0.2:40122", Remote: "10.1.2.3:22", RTT: 15200 * time.Microsecond, RTTVar: 3100 * time.Microsecond, Retrans: 2}}},
+	}
+	u := NewUI(fake, context.Background())
+	u.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	u.Update(stateChangedMsg{})
+	if cmd := u.sampleSockets(); cmd != nil {
+		t.Fatal("nothing is sampled while every service's log shows")
+	}
+
+	u.logFilterSelected = true
+	cmd := u.sampleSockets()
+	if cmd == nil {
+		t.Fatal("the detail view should sample its service's connections")
+	}
+	if u.sampleSockets() != nil {
+		t.Error("one sample at a time")
+	}
+	u.Update(cmd())
+	want := "Connection 10.0.0.2:40122 → 10.1.2.3:22  rtt 15.2ms ±3.1ms  retrans 2"
+	if view := ansi.Strip(u.viewport.GetContent()); !strings.Contains(view, want) {
+		t.Errorf("detail should show %q:\n%s", want, view)
+	}
+	if u.sampleSockets() != nil {
+		t.Error("a fresh sample is not taken again before socketRefresh")
+	}
+}
+
+func TestRenderSockets(t *testing.T) {
+	if lines := renderSockets(socketSample{err: errors.ErrUnsupported}, 120); lines != nil {
+		t.Errorf("nothing to show where the system cannot tell, got %q", lines)
+	}
+	if lines := renderSockets(socketSample{}, 120); len(lines) != 1 || !strings.Contains(ansi.Strip(lines[0]), "none to the far side") {
+		t.Errorf("no connections = %q", lines)
+	}
+	if got := formatRTT(250 * time.Millisecond); got != "250ms" {
+		t.Errorf("formatRTT = %q", got)
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"image/color"
+	"net"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
@@ -15,7 +17,10 @@ import (
 	"charm.land/bubbles/v2/viewport"
 	tea "charm.land/bubbletea/v2"
 	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
 
+	"github.com/alinemone/go-port-forward/forwarder"
+	"github.com/alinemone/go-port-forward/internal/cmdline"
 	"github.com/alinemone/go-port-forward/internal/configedit"
 	"github.com/alinemone/go-port-forward/internal/icons"
 	"github.com/alinemone/go-port-forward/internal/manager"
@@ -27,12 +32,24 @@ import (
 
 type tickMsg time.Time
 
+// stateChangedMsg is delivered when the manager signals that service state or
+// logs changed; the UI re-reads a snapshot only then, instead of every tick.
+type stateChangedMsg struct{}
+
 type spinnerTickMsg time.Time
 
 type shutdownDoneMsg struct{}
 
+// ctxDoneMsg is delivered when the session context is cancelled (e.g. SIGTERM
+// from another terminal), so the UI shuts down without waiting for a key.
+type ctxDoneMsg struct{}
+
 type clearStatusMsg struct{ seq int }
 
+// NoticeMsg shows a session notice (see events.SessionNotice) in the status
+// line; the session sends it with the program's Send.
+type NoticeMsg string
+
 const statusClearDelay = 5 * time.Second
 
 var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
@@ -122,11 +139,24 @@ type Controller interface {
 	StopAllServices()
 	RestartService(ctx context.Context, name string) error
 	RestartAllServices(ctx context.Context)
+	Updates() <-chan struct{}
+	// GroupStates and TrackGroup follow the groups started in the session,
+	// whose combined status the header shows.
+	GroupStates() []model.GroupState
+	TrackGroup(name string, members []string)
+	// AnswerPrompt answers what a service's process asks for (see
+	// model.Service.Prompt).
+	AnswerPrompt(name, answer string) error
+	// SocketStats lists the connections a service's process holds to the
+	// far side, for its detail view; errors.ErrUnsupported where the
+	// system does not tell.
+	SocketStats(name string) ([]model.Socket, error)
 }
 
 type UI struct {
 	manager     Controller
 	services    []model.Service
+	groups      []model.GroupState
 	cursorIndex int
 	quitting    bool
 	width       int
@@ -152,13 +182,15 @@ type UI struct {
 	groupFormSvcCursor int
 	// unified manage overlay (groups + services in one list)
 	manageMode          bool
+	picking             bool // the session started in the picker; see OpenPicker
 	manageRows          []manageRow
 	manageCursor        int
 	manageOffset        int
 	manageGroups        map[string][]string
 	manageGroupNames    []string
 	manageServices      []string
-	manageIcons         overlayIcons // resolved icon state for the overlay list
+	manageIcons         overlayIcons      // resolved icon state for the overlay list
+	manageTargets       map[string]string // service name → "svc/db @ ns", from its command
 	manageSelGroups     map[string]bool
 	manageSelSvcs       map[string]bool
 	manageConfirmDelete string
@@ -170,22 +202,137 @@ type UI struct {
 	editStatus          string
 	editStatusSeq       int
 	logFilterSelected   bool
+	logFollow           bool // keep the log view pinned to the newest line
+	logGrouped          bool // group log lines under per-service headers
+	logCollapsed        map[string]bool
+	tableRows           rowCache     // rendered service rows, reused while unchanged
+	logLines            logLineCache // rendered log entries, reused across refreshes
 	spinnerFrame        int
 	tableOffset         int
-}
-
-const uiTickInterval = 500 * time.Millisecond
+	sortMode            string // table order: sortByName, sortByStatus or sortByPort
+	confirm             ConfirmOptions
+	confirmPrompt       string         // question shown while confirmRun waits for y/n
+	confirmRun          func() tea.Cmd // action held until the prompt is answered
+	sessionStart        time.Time
+	sessionLabel        string // what was run, e.g. a group name or "all"
+	kubeContext         string // kubectl context at startup, "" when unknown
+	// ":" command palette
+	paletteMode     bool
+	paletteQuery    string
+	paletteCommands []paletteCommand // every command, built when the palette opens
+	paletteMatches  []paletteCommand // commands matching the query, best first
+	paletteCursor   int
+	paletteOffset   int
+	// input for a service's prompt (see syncPrompt); "" = none open
+	promptService   string
+	promptText      string
+	promptInput     textinput.Model
+	promptDismissed map[string]string // service → the prompt Esc dismissed
+	// session TTL: when every forward stops (zero = no TTL)
+	deadline       time.Time
+	deadlineWarned bool
+	// history sums up a service's past sessions; see SetHistory
+	history func(name string) string
+	// sockets is the latest sample of the detailed service's connections;
+	// see sampleSockets
+	sockets socketSample
+	// tickInterval is uiTickInterval, or lowPowerTickInterval; see
+	// SetLowPower
+	tickInterval time.Duration
+}
+
+// uiTickInterval only drives time-based redraws (the uptime column). State
+// changes arrive over Controller.Updates, so the tick never snapshots services.
+const uiTickInterval = time.Second
+
+// lowPowerTickInterval replaces uiTickInterval in low-power mode.
+const lowPowerTickInterval = 30 * time.Second
 
 func NewUI(mgr Controller, ctx context.Context) *UI {
 	return &UI{
-		manager:  mgr,
-		services: []model.Service{},
-		ctx:      ctx,
+		manager:      mgr,
+		services:     []model.Service{},
+		ctx:          ctx,
+		logFollow:    true,
+		sortMode:     sortByName,
+		confirm:      ConfirmOptions{Stop: true, Restart: true, Quit: true},
+		sessionStart: time.Now(),
+		tickInterval: uiTickInterval,
 	}
 }
 
+// SetLowPower redraws the view when services change, and otherwise only every
+// lowPowerTickInterval instead of every second, so the uptimes lag behind.
+// For small machines that run pf around the clock.
+func (u *UI) SetLowPower() {
+	u.tickInterval = lowPowerTickInterval
+}
+
+// OpenPicker starts the session in the groups and services list, for `pf run`
+// without names: the user searches, ticks what to run with Space and starts it
+// with Enter. Closing the list with nothing running quits.
+func (u *UI) OpenPicker() {
+	u.enterManageMode(false)
+	u.picking = true
+}
+
+// TTLWarning is how long before a session's TTL runs out the user is warned
+// that every forward is about to stop.
+const TTLWarning = 5 * time.Minute
+
+// SetHistory adds a line to the detail view from history, which sums up a
+// service's past beyond this session (e.g. its reconnects today across
+// sessions); a "" result adds nothing.
+func (u *UI) SetHistory(history func(name string) string) {
+	u.history = history
+}
+
+// SetDeadline makes the header count down to t, when the session's TTL stops
+// every forward, and warns on the status line TTLWarning beforehand. Stopping
+// is up to the caller (the session context's deadline).
+func (u *UI) SetDeadline(t time.Time) {
+	u.deadline = t
+}
+
+// ConfirmOptions picks which keys ask "are you sure?" before acting. All are on
+// by default; quitting only asks while services are running.
+type ConfirmOptions struct {
+	Stop    bool
+	Restart bool // restart and restart-all
+	Quit    bool
+}
+
+// SetConfirm replaces the default confirmation settings.
+func (u *UI) SetConfirm(c ConfirmOptions) {
+	u.confirm = c
+}
+
+// SetSessionInfo sets what the session header shows beside the live counts:
+// the run target the user asked for and the kubectl context in effect. Either
+// may be empty and is then left out.
+func (u *UI) SetSessionInfo(label, kubeContext string) {
+	u.sessionLabel = label
+	u.kubeContext = kubeContext
+}
+
 func (u *UI) Init() tea.Cmd {
-	return tickCmd(uiTickInterval)
+	// The initial stateChangedMsg loads the first snapshot and arms waitForUpdate.
+	return tea.Batch(tickCmd(u.tickInterval), func() tea.Msg { return stateChangedMsg{} })
+}
+
+// waitForUpdate blocks until the manager reports a change or the session
+// context ends. It is re-armed after every stateChangedMsg, so at most one
+// waiter is outstanding.
+func (u *UI) waitForUpdate() tea.Cmd {
+	updates := u.manager.Updates()
+	return func() tea.Msg {
+		select {
+		case <-updates:
+			return stateChangedMsg{}
+		case <-u.ctx.Done():
+			return ctxDoneMsg{}
+		}
+	}
 }
 
 func (u *UI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -217,6 +364,17 @@ func (u *UI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case tea.MouseWheelMsg:
 		switch {
+		case u.paletteMode:
+			switch msg.Button {
+			case tea.MouseWheelUp:
+				if u.paletteCursor > 0 {
+					u.paletteCursor--
+				}
+			case tea.MouseWheelDown:
+				if u.paletteCursor < len(u.paletteMatches)-1 {
+					u.paletteCursor++
+				}
+			}
 		case u.manageMode:
 			if u.addFormMode == "" && u.groupFormMode == "" {
 				switch msg.Button {
@@ -228,6 +386,7 @@ func (u *UI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		default:
 			u.viewport, cmd = u.viewport.Update(msg)
+			u.syncLogFollow()
 		}
 
 	case tea.KeyPressMsg:
@@ -242,67 +401,142 @@ func (u *UI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if u.manageMode {
 			return u.updateManageMode(msg)
 		}
+		if u.paletteMode {
+			return u.updatePalette(msg)
+		}
+		if u.promptService != "" {
+			return u.updatePrompt(msg)
+		}
+		if u.confirmRun != nil {
+			return u.updateConfirm(key)
+		}
 
-		switch key {
-		case "q", "ctrl+c", "esc":
-			u.quitting = true
-			return u, tea.Batch(u.shutdownCmd(), spinnerTick())
+		// Unbound keys resolve to the zero Action and fall through to the
+		// viewport's own scrolling keys.
+		action, _ := activeKeymap.Action(key)
+		switch action {
+		case ActionQuit:
+			// ctrl+c is the escape hatch and never asks.
+			ask := u.confirm.Quit && key != "ctrl+c" && len(u.services) > 0
+			return u, u.confirmThen(ask, fmt.Sprintf("Quit and stop %d running service(s)?", len(u.services)), u.quit)
 
-		case "up", "k":
+		case ActionUp:
 			if u.cursorIndex > 0 {
 				u.cursorIndex--
 				u.onCursorMoved()
 			} else {
-				u.viewport, cmd = u.viewport.Update(msg)
+				u.viewport.ScrollUp(1)
+				u.syncLogFollow()
 			}
 
-		case "down", "j":
+		case ActionDown:
 			if u.cursorIndex < len(u.services)-1 {
 				u.cursorIndex++
 				u.onCursorMoved()
 			} else {
-				u.viewport, cmd = u.viewport.Update(msg)
+				u.viewport.ScrollDown(1)
+				u.syncLogFollow()
 			}
 
-		case "pgup", "pgdown", "home", "end", "ctrl+u", "ctrl+d":
-			u.viewport, cmd = u.viewport.Update(msg)
+		case ActionPageUp:
+			u.viewport.PageUp()
+			u.syncLogFollow()
 
-		case "r":
+		case ActionPageDown:
+			u.viewport.PageDown()
+			u.syncLogFollow()
+
+		case ActionHalfUp:
+			u.viewport.HalfPageUp()
+			u.syncLogFollow()
+
+		case ActionHalfDown:
+			u.viewport.HalfPageDown()
+			u.syncLogFollow()
+
+		case ActionTop:
+			u.viewport.GotoTop()
+			u.logFollow = false
+
+		case ActionBottom:
+			u.viewport.GotoBottom()
+			u.logFollow = true
+
+		case ActionLayout:
+			u.logGrouped = !u.logGrouped
+			u.refreshViewportContent()
+
+		case ActionFold:
+			if u.logGrouped && u.cursorIndex < len(u.services) {
+				name := u.services[u.cursorIndex].Name
+				if u.logCollapsed == nil {
+					u.logCollapsed = make(map[string]bool)
+				}
+				u.logCollapsed[name] = !u.logCollapsed[name]
+				u.refreshViewportContent()
+			}
+
+		case ActionFollow:
+			u.logFollow = !u.logFollow
+			if u.logFollow {
+				u.viewport.GotoBottom()
+			}
+
+		case ActionRestart:
 			if u.cursorIndex < len(u.services) && len(u.services) > 0 {
 				serviceName := u.services[u.cursorIndex].Name
-				u.manager.RestartService(u.ctx, serviceName)
+				return u, u.confirmThen(u.confirm.Restart, fmt.Sprintf("Restart '%s'?", serviceName), func() tea.Cmd {
+					u.manager.RestartService(u.ctx, serviceName)
+					return nil
+				})
 			}
 
-		case "ctrl+r":
+		case ActionRestartAll:
 			if len(u.services) > 0 {
-				u.manager.RestartAllServices(u.ctx)
+				return u, u.confirmThen(u.confirm.Restart, fmt.Sprintf("Restart all %d service(s)?", len(u.services)), func() tea.Cmd {
+					u.manager.RestartAllServices(u.ctx)
+					return nil
+				})
 			}
 
-		case "s":
+		case ActionStop:
 			if u.cursorIndex < len(u.services) && len(u.services) > 0 {
 				name := u.services[u.cursorIndex].Name
-				return u, func() tea.Msg {
-					u.manager.StopService(name)
-					return nil
-				}
+				return u, u.confirmThen(u.confirm.Stop, fmt.Sprintf("Stop '%s'?", name), func() tea.Cmd {
+					return func() tea.Msg {
+						u.manager.StopService(name)
+						return nil
+					}
+				})
 			}
 
-		case "a":
+		case ActionAdd:
 			u.enterManageMode(true)
 
-		case "g":
+		case ActionGroups:
 			u.enterManageMode(false)
 
-		case "c":
+		case ActionConfig:
 			return u, u.launchEditor()
 
-		case "l":
+		case ActionPalette:
+			u.openPalette()
+
+		case ActionCopy:
+			if u.cursorIndex < len(u.services) && len(u.services) > 0 {
+				return u, u.copyAddress(&u.services[u.cursorIndex])
+			}
+
+		case ActionLogScope:
 			u.logFilterSelected = !u.logFilterSelected
 			u.refreshViewportContent()
 			u.viewport.GotoBottom()
+			u.logFollow = true
+			return u, u.sampleSockets()
 
 		default:
 			u.viewport, cmd = u.viewport.Update(msg)
+			u.syncLogFollow()
 		}
 
 	case editResultMsg:
@@ -323,6 +557,9 @@ func (u *UI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return u, u.setStatus(status)
 
+	case NoticeMsg:
+		return u, u.setStatus("⚠ " + string(msg))
+
 	case clearStatusMsg:
 		if msg.seq == u.editStatusSeq {
 			u.editStatus = ""
@@ -332,6 +569,8 @@ func (u *UI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case spinnerTickMsg:
 		if u.quitting {
 			u.spinnerFrame++
+			// Services leave the list as they stop, in the shutdown order.
+			u.services = u.manager.ListServiceStates()
 			return u, spinnerTick()
 		}
 		return u, nil
@@ -339,14 +578,39 @@ func (u *UI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case shutdownDoneMsg:
 		return u, tea.Quit
 
+	case ctxDoneMsg:
+		if u.quitting {
+			return u, nil
+		}
+		return u, u.quit()
+
 	case tickMsg:
 		if u.quitting {
 			return u, nil
 		}
+		if !u.deadline.IsZero() && !u.deadlineWarned && time.Until(u.deadline) <= TTLWarning {
+			u.deadlineWarned = true
+			left := formatDuration(max(time.Until(u.deadline), 0))
+			return u, tea.Batch(tickCmd(u.tickInterval), u.setStatus("⚠ Session TTL: all forwards stop in "+left), u.sampleSockets())
+		}
+		return u, tea.Batch(tickCmd(u.tickInterval), u.sampleSockets())
+
+	case socketsMsg:
+		u.setSockets(msg)
+		return u, nil
+
+	case stateChangedMsg:
+		if u.quitting {
+			return u, nil
+		}
+		selected := u.selectedServiceName()
 		u.services = u.manager.ListServiceStates()
+		u.groups = u.manager.GroupStates()
+		sortServices(u.services, u.sortMode)
+		u.selectService(selected)
 		u.ensureCursorInRange()
 		u.refreshViewportContent()
-		return u, tickCmd(uiTickInterval)
+		return u, tea.Batch(u.waitForUpdate(), u.syncPrompt())
 
 	default:
 		if u.manageMode {
@@ -357,6 +621,37 @@ func (u *UI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return u, cmd
 }
 
+// quit starts the shutdown: the spinner screen shows while every service stops.
+func (u *UI) quit() tea.Cmd {
+	u.quitting = true
+	return tea.Batch(u.shutdownCmd(), spinnerTick())
+}
+
+// confirmThen runs action now, or when ask is set, holds it behind a y/n prompt
+// on the status line until the user answers.
+func (u *UI) confirmThen(ask bool, prompt string, action func() tea.Cmd) tea.Cmd {
+	if !ask {
+		return action()
+	}
+	u.confirmPrompt = prompt
+	u.confirmRun = action
+	return nil
+}
+
+func (u *UI) updateConfirm(key string) (tea.Model, tea.Cmd) {
+	run := u.confirmRun
+	switch key {
+	case "y", "enter":
+		u.confirmPrompt, u.confirmRun = "", nil
+		return u, run()
+	case "ctrl+c":
+		return u, u.quit()
+	case "n", "esc":
+		u.confirmPrompt, u.confirmRun = "", nil
+	}
+	return u, nil
+}
+
 func (u *UI) shutdownCmd() tea.Cmd {
 	return func() tea.Msg {
 		u.manager.StopAllServices()
@@ -448,6 +743,15 @@ func (u *UI) viewContent() string {
 		return "Initializing..."
 	}
 
+	// Below the minimum size the boxed layout would overflow and corrupt the
+	// screen, so fall back to a condensed list (or, for overlays, a notice).
+	if u.tooSmall() {
+		if u.manageMode || u.paletteMode {
+			return renderTooSmall(u.width, u.height)
+		}
+		return u.renderCondensed()
+	}
+
 	if u.manageMode {
 		if u.addFormMode != "" {
 			return u.renderServiceForm()
@@ -458,15 +762,20 @@ func (u *UI) viewContent() string {
 		return u.renderManageOverlay()
 	}
 
+	if u.paletteMode {
+		return u.renderPalette()
+	}
+
 	u.ensureViewportSize()
 
-	sections := make([]string, 0, 3)
+	sections := make([]string, 0, 5)
+	sections = append(sections, u.renderSessionHeader())
 	if len(u.services) == 0 {
 		sections = append(sections, renderEmptyState())
 	} else {
 		maxVis := maxVisibleServices(u.height)
 		u.ensureCursorVisible(maxVis)
-		sections = append(sections, renderServiceTable(u.services, u.cursorIndex, u.tableOffset, maxVis, u.width))
+		sections = append(sections, renderServiceTableCached(&u.tableRows, u.services, u.cursorIndex, u.tableOffset, maxVis, u.width))
 	}
 
 	logBoxWidth := u.width - 2
@@ -480,7 +789,12 @@ func (u *UI) viewContent() string {
 		Render(u.viewport.View())
 	sections = append(sections, logBox)
 
-	if u.editStatus != "" {
+	if u.promptService != "" {
+		sections = append(sections, u.renderPrompt())
+	} else if u.confirmRun != nil {
+		prompt := lipgloss.NewStyle().Foreground(colorWarn).Bold(true).Render(u.confirmPrompt)
+		sections = append(sections, prompt+"  "+renderActionChips([][2]string{{"y", "confirm"}, {"n", "cancel"}}))
+	} else if u.editStatus != "" {
 		statusColor := colorAccentAlt
 		if strings.HasPrefix(u.editStatus, "✗") {
 			statusColor = colorError
@@ -488,7 +802,7 @@ func (u *UI) viewContent() string {
 		sections = append(sections, lipgloss.NewStyle().Foreground(statusColor).Render(u.editStatus))
 	}
 
-	sections = append(sections, renderHelp(u.width, u.logScopeLabel()))
+	sections = append(sections, renderHelp(u.width, u.helpState()))
 	return lipgloss.JoinVertical(lipgloss.Left, sections...)
 }
 
@@ -516,6 +830,7 @@ func (u *UI) enterManageMode(focusServices bool) {
 
 func (u *UI) exitManageMode() {
 	u.manageMode = false
+	u.picking = false
 	u.addFormMode = ""
 	u.groupFormMode = ""
 	u.manageErr = ""
@@ -529,6 +844,7 @@ func (u *UI) exitManageMode() {
 	u.manageGroupNames = nil
 	u.manageServices = nil
 	u.manageIcons = overlayIcons{}
+	u.manageTargets = nil
 	u.manageSelGroups = nil
 	u.manageSelSvcs = nil
 	u.manageCursor = 0
@@ -552,7 +868,8 @@ func (u *UI) buildManageRows() {
 	}
 	sort.Strings(groupNames)
 
-	svcNames, err := st.ListServiceNames()
+	// Disabled services stay out of the list; `pf enable` brings them back.
+	svcNames, err := st.EnabledServiceNames()
 	if err != nil {
 		svcNames = nil
 	}
@@ -569,16 +886,30 @@ func (u *UI) buildManageRows() {
 		commands = nil
 	}
 	ports := make(map[string]string, len(commands))
+	targets := make(map[string]string, len(commands))
 	for name, command := range commands {
-		if _, main := storage.ParsePortsFromCommand(command); main != "" {
+		if _, main := cmdline.ParsePorts(command); main != "" {
 			ports[name] = main
 		}
+		if fw := cmdline.ParseForward(command); fw.Target != "" {
+			targets[name] = fw.Target
+			if fw.Namespace != "" {
+				targets[name] += " @ " + fw.Namespace
+			}
+		} else if fw.APIProxy {
+			targets[name] = kubeAPILabel
+		} else if cmdline.IsMonitor(command) {
+			targets[name] = "monitor"
+		} else if c, err := forwarder.ParseCommand(command); err == nil {
+			targets[name] = "plugin " + c.Plugin
+		}
 	}
 
 	u.manageGroups = groups
 	u.manageGroupNames = groupNames
 	u.manageServices = svcNames
 	u.manageIcons = overlayIcons{set: iconSet, enabled: iconsEnabled, ports: ports}
+	u.manageTargets = targets
 
 	if u.manageSelGroups != nil {
 		valid := make(map[string]bool, len(groupNames))
@@ -607,37 +938,27 @@ func (u *UI) buildManageRows() {
 }
 
 // rebuildManageRows reconstructs the visible row list from the already-loaded
-// group and service names, applying the live search filter. Section headers are
+// group and service names, applying the live fuzzy search, best matches first
+// (see fuzzyFilter). Section headers are
 // always shown; a section with no matches shows its empty placeholder. Call this
 // (instead of buildManageRows) when only the filter changed — it avoids a disk
 // reload.
 func (u *UI) rebuildManageRows() {
-	q := strings.ToLower(strings.TrimSpace(u.manageSearch))
-	match := func(name string) bool {
-		return q == "" || strings.Contains(strings.ToLower(name), q)
-	}
-
 	rows := make([]manageRow, 0, len(u.manageGroupNames)+len(u.manageServices)+2)
 	rows = append(rows, manageRow{kind: rowHeaderGroups})
-	groupMatches := 0
-	for _, n := range u.manageGroupNames {
-		if match(n) {
-			rows = append(rows, manageRow{kind: rowGroup, name: n})
-			groupMatches++
-		}
+	groupMatches := fuzzyFilter(u.manageSearch, u.manageGroupNames)
+	for _, n := range groupMatches {
+		rows = append(rows, manageRow{kind: rowGroup, name: n})
 	}
-	if groupMatches == 0 {
+	if len(groupMatches) == 0 {
 		rows = append(rows, manageRow{kind: rowEmptyGroups})
 	}
 	rows = append(rows, manageRow{kind: rowHeaderServices})
-	svcMatches := 0
-	for _, n := range u.manageServices {
-		if match(n) {
-			rows = append(rows, manageRow{kind: rowService, name: n})
-			svcMatches++
-		}
+	svcMatches := fuzzyFilter(u.manageSearch, u.manageServices)
+	for _, n := range svcMatches {
+		rows = append(rows, manageRow{kind: rowService, name: n})
 	}
-	if svcMatches == 0 {
+	if len(svcMatches) == 0 {
 		rows = append(rows, manageRow{kind: rowEmptyServices})
 	}
 	u.manageRows = rows
@@ -727,6 +1048,23 @@ func (u *UI) currentManageRow() manageRow {
 	return u.manageRows[u.manageCursor]
 }
 
+// groupCandidates is what "new group from running" starts with: every running
+// service plus any stopped ones ticked in the overlay, in name order.
+func (u *UI) groupCandidates() []string {
+	set := u.runningNameSet()
+	for name, ticked := range u.manageSelSvcs {
+		if ticked {
+			set[name] = true
+		}
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (u *UI) runningNameSet() map[string]bool {
 	set := make(map[string]bool, len(u.services))
 	for i := range u.services {
@@ -769,7 +1107,7 @@ func (u *UI) updateManageMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				err = st.DeleteGroup(name)
 				delete(u.manageSelGroups, name)
 			} else {
-				err = st.DeleteService(name)
+				err = st.TrashService(name)
 				delete(u.manageSelSvcs, name)
 			}
 			if err != nil {
@@ -806,6 +1144,9 @@ func (u *UI) updateManageMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			u.manageSearch = ""
 			u.manageInfo = ""
 			u.rebuildManageRows()
+		} else if u.picking && len(u.services) == 0 {
+			u.exitManageMode()
+			return u, u.quit()
 		} else {
 			u.exitManageMode()
 		}
@@ -828,6 +1169,10 @@ func (u *UI) updateManageMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		u.manageErr = ""
 		u.manageInfo = ""
 		u.manageNewPrompt = true
+	case "ctrl+g":
+		u.manageErr = ""
+		u.manageInfo = ""
+		return u, u.openNewGroupFormWith(u.groupCandidates())
 	case "ctrl+e":
 		row := u.currentManageRow()
 		switch row.kind {
@@ -878,7 +1223,8 @@ func (u *UI) updateManageMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 // runManageSelection starts every non-running service across the selected groups
-// and selected loose services (each at most once). Returns true when something was
+// and selected loose services (each at most once), leaving out a group's
+// disabled members like `pf run <group>` does. Returns true when something was
 // selected (caller closes the overlay so the main list shows the run); false when
 // nothing was selected (overlay stays open with a hint).
 func (u *UI) runManageSelection() bool {
@@ -897,9 +1243,15 @@ func (u *UI) runManageSelection() bool {
 		seen[name] = true
 		_ = u.manager.StartStoredService(u.ctx, name)
 	}
+	st := storage.NewStorage()
 	for _, g := range u.manageGroupNames {
 		if u.manageSelGroups[g] {
-			for _, svc := range u.manageGroups[g] {
+			members := u.manageGroups[g]
+			if enabled, err := st.EnabledGroupServices(g); err == nil {
+				members = enabled
+			}
+			u.manager.TrackGroup(g, members)
+			for _, svc := range members {
 				start(svc)
 			}
 		}
@@ -910,6 +1262,7 @@ func (u *UI) runManageSelection() bool {
 		}
 	}
 	u.services = u.manager.ListServiceStates()
+	u.groups = u.manager.GroupStates()
 	return true
 }
 
@@ -1090,6 +1443,12 @@ func (u *UI) submitServiceForm() (tea.Model, tea.Cmd) {
 }
 
 func (u *UI) openNewGroupForm() tea.Cmd {
+	return u.openNewGroupFormWith(nil)
+}
+
+// openNewGroupFormWith opens the new-group form with members already ticked,
+// so a group can be made from what is running without re-picking it.
+func (u *UI) openNewGroupFormWith(members []string) tea.Cmd {
 	names, err := storage.NewStorage().ListServiceNames()
 	if err != nil {
 		return nil
@@ -1099,7 +1458,10 @@ func (u *UI) openNewGroupForm() tea.Cmd {
 	u.groupFormErr = ""
 	u.groupFormName = newServiceTextInput("e.g. backend", "", u.formInputWidth())
 	u.groupFormServices = names
-	u.groupFormSelected = make(map[string]bool)
+	u.groupFormSelected = make(map[string]bool, len(members))
+	for _, svc := range members {
+		u.groupFormSelected[svc] = true
+	}
 	u.groupFormFocus = 0
 	u.groupFormSvcCursor = 0
 	return u.groupFormName.Focus()
@@ -1255,6 +1617,62 @@ func (u *UI) ensureCursorInRange() {
 	}
 }
 
+// Table sort orders, selectable from the command palette.
+const (
+	sortByName   = "name"
+	sortByStatus = "status"
+	sortByPort   = "port"
+)
+
+// sortServices orders the table. Name order is the manager's own; status puts
+// failing services first so problems are visible without scrolling, and port
+// sorts numerically by local port. Name breaks ties in every mode.
+func sortServices(services []model.Service, mode string) {
+	statusRank := map[string]int{model.StatusError: 0, model.StatusConnecting: 1, model.StatusHealthy: 2}
+	sort.SliceStable(services, func(i, j int) bool {
+		a, b := &services[i], &services[j]
+		switch mode {
+		case sortByStatus:
+			if ra, rb := statusRank[a.Status], statusRank[b.Status]; ra != rb {
+				return ra < rb
+			}
+		case sortByPort:
+			pa, _ := strconv.Atoi(a.LocalPort)
+			pb, _ := strconv.Atoi(b.LocalPort)
+			if pa != pb {
+				return pa < pb
+			}
+		}
+		return a.Name < b.Name
+	})
+}
+
+// setSort re-orders the table, keeping the cursor on the same service.
+func (u *UI) setSort(mode string) {
+	selected := u.selectedServiceName()
+	u.sortMode = mode
+	sortServices(u.services, mode)
+	u.selectService(selected)
+	u.refreshViewportContent()
+}
+
+func (u *UI) selectedServiceName() string {
+	if u.cursorIndex >= 0 && u.cursorIndex < len(u.services) {
+		return u.services[u.cursorIndex].Name
+	}
+	return ""
+}
+
+// selectService moves the cursor to the named service, if it is in the table.
+func (u *UI) selectService(name string) {
+	for i := range u.services {
+		if u.services[i].Name == name {
+			u.cursorIndex = i
+			return
+		}
+	}
+}
+
 func maxVisibleServices(totalHeight int) int {
 	if totalHeight <= 0 {
 		return 8
@@ -1304,12 +1722,32 @@ func (u *UI) refreshViewportContent() {
 	}
 
 	services := u.services
-	if u.logFilterSelected && u.cursorIndex >= 0 && u.cursorIndex < len(u.services) {
+	filtered := u.logFilterSelected && u.cursorIndex >= 0 && u.cursorIndex < len(u.services)
+	if filtered {
 		services = []model.Service{u.services[u.cursorIndex]}
 	}
 
-	follow := u.viewport.AtBottom()
-	newContent := renderLogsContent(services, contentWidth)
+	follow := u.logFollow
+	var newContent string
+	if u.logGrouped {
+		newContent = renderGroupedLogsCached(&u.logLines, services, u.logCollapsed, contentWidth)
+	} else {
+		newContent = renderLogsContentCached(&u.logLines, services, contentWidth)
+	}
+	if filtered {
+		// The log of one service opens with its details.
+		history := ""
+		if u.history != nil {
+			history = u.history(services[0].Name)
+		}
+		detail := renderServiceDetail(&services[0], history, contentWidth)
+		if u.sockets.name == services[0].Name {
+			for _, line := range renderSockets(u.sockets, contentWidth) {
+				detail += "\n" + line
+			}
+		}
+		newContent = detail + "\n" + newContent
+	}
 	u.viewport.SetContent(newContent)
 	if follow {
 		u.viewport.GotoBottom()
@@ -1320,12 +1758,49 @@ func (u *UI) onCursorMoved() {
 	if u.logFilterSelected {
 		u.refreshViewportContent()
 		u.viewport.GotoBottom()
+		u.logFollow = true
 	}
 }
 
+// syncLogFollow updates follow mode after a manual scroll: scrolling away from
+// the bottom pauses it so new lines don't yank the view, and scrolling back to
+// the bottom resumes it.
+func (u *UI) syncLogFollow() {
+	u.logFollow = u.viewport.AtBottom()
+}
+
+// helpState is the live UI state the help bar reflects in its chip labels.
+type helpState struct {
+	logScope string // "ALL" or the name the log is filtered to
+	follow   string // follow toggle label
+	layout   string // log layout toggle label
+	grouped  bool   // logs grouped by service, so the fold key applies
+}
+
+func (u *UI) helpState() helpState {
+	layout := "by service"
+	if u.logGrouped {
+		layout = "by time"
+	}
+	return helpState{
+		logScope: u.logScopeLabel(),
+		follow:   u.followLabel(),
+		layout:   layout,
+		grouped:  u.logGrouped,
+	}
+}
+
+// followLabel is the help-bar description for the follow toggle.
+func (u *UI) followLabel() string {
+	if u.logFollow {
+		return "follow=on"
+	}
+	return "follow=off"
+}
+
 func (u *UI) logScopeLabel() string {
 	if u.logFilterSelected && u.cursorIndex >= 0 && u.cursorIndex < len(u.services) {
-		return truncateRunes(u.services[u.cursorIndex].Name, 14)
+		return truncateDisplay(u.services[u.cursorIndex].Name, 14)
 	}
 	return "ALL"
 }
@@ -1349,8 +1824,10 @@ func (u *UI) ensureViewportSize() {
 // bar can wrap to multiple rows on narrow terminals, so this must be measured,
 // not assumed, or the bottom border gets clipped off-screen.
 func (u *UI) chromeBelowLog() int {
-	h := len(helpLines(u.width, u.logScopeLabel())) + 2 // help box border
-	if u.editStatus != "" {
+	h := len(helpLines(u.width, u.helpState())) + 2 // help box border
+	if u.promptService != "" {
+		h += promptLines
+	} else if u.editStatus != "" || u.confirmRun != nil {
 		h++
 	}
 	return h
@@ -1372,7 +1849,7 @@ func calculateViewportHeight(serviceCount, totalHeight, chromeBelow int) int {
 	if serviceCount > maxVis {
 		tableLines++
 	}
-	overhead := tableLines + 2 + chromeBelow
+	overhead := sessionHeaderLines + tableLines + 2 + chromeBelow
 	viewportHeight := totalHeight - overhead
 	if viewportHeight < 3 {
 		viewportHeight = 3
@@ -1380,6 +1857,195 @@ func calculateViewportHeight(serviceCount, totalHeight, chromeBelow int) int {
 	return viewportHeight
 }
 
+// The boxed layout (table, log box, help bar) needs at least this much room.
+// Smaller terminals get the condensed single-column view instead.
+const (
+	minLayoutWidth  = 60
+	minLayoutHeight = 16
+)
+
+// tooSmall reports whether the terminal is below the boxed layout's minimum.
+// An unknown size (before the first WindowSizeMsg) is not "too small".
+func (u *UI) tooSmall() bool {
+	if u.width <= 0 || u.height <= 0 {
+		return false
+	}
+	return u.width < minLayoutWidth || u.height < minLayoutHeight
+}
+
+// renderCondensed is the main view for terminals below the minimum size: one
+// unboxed line per service ("► ● db :5432"), clipped to the screen, with a
+// one-line hint at the bottom. Keys keep working as in the full view.
+func (u *UI) renderCondensed() string {
+	width, height := u.width, u.height
+	lines := make([]string, 0, height)
+
+	if height >= 3 {
+		lines = append(lines, truncateDisplay(u.renderSessionHeader(), width))
+	}
+
+	rows := height - len(lines) - 1 // leave the hint line
+	if rows < 1 {
+		rows = 1
+	}
+	u.ensureCursorVisible(rows)
+	if len(u.services) == 0 {
+		lines = append(lines, lipgloss.NewStyle().Foreground(colorMuted).Italic(true).
+			Render(truncateDisplay("No services running", width)))
+	}
+	for i := u.tableOffset; i < len(u.services) && i < u.tableOffset+rows; i++ {
+		svc := &u.services[i]
+		marker := "  "
+		if i == u.cursorIndex {
+			marker = "► "
+		}
+		icon, c := "◐", statusConnectingColor
+		switch svc.Status {
+		case model.StatusHealthy:
+			icon, c = "●", statusHealthyColor
+		case model.StatusError:
+			icon, c = "✗", statusErrorColor
+		}
+		if now := time.Now(); svc.InMaintenance(now) {
+			icon, c = "○", colorMuted
+		} else if svc.RollingOut {
+			icon, c = "◐", colorWarn
+		} else if svc.Flapping(now) {
+			icon, c = "↯", colorWarn
+		} else if svc.Degraded && svc.Status == model.StatusHealthy {
+			c = colorWarn
+		}
+		nameColor := colorText
+		if i == u.cursorIndex {
+			nameColor = colorAccent
+		}
+		line := lipgloss.NewStyle().Foreground(nameColor).Render(marker) +
+			lipgloss.NewStyle().Foreground(c).Render(icon) + " " +
+			lipgloss.NewStyle().Foreground(nameColor).Render(svc.Name) +
+			lipgloss.NewStyle().Foreground(colorWarn).Render(nameBadge(svc)) +
+			lipgloss.NewStyle().Foreground(nameColor).Render(sidebarPort(svc))
+		lines = append(lines, truncateDisplay(line, width))
+	}
+
+	if len(lines) < height {
+		hint := "enlarge for logs • " + activeKeymap.PrimaryLabel(ActionQuit) + " quit"
+		if u.promptService != "" {
+			hint = u.promptService + " asks: " + u.promptText + " (enlarge to answer)"
+		} else if u.confirmRun != nil {
+			hint = u.confirmPrompt + " y/n"
+		} else if u.editStatus != "" {
+			hint = u.editStatus
+		}
+		lines = append(lines, lipgloss.NewStyle().Foreground(colorMuted).Render(truncateDisplay(hint, width)))
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderTooSmall replaces overlays that cannot fit below the minimum size.
+func renderTooSmall(width, height int) string {
+	text := fmt.Sprintf("Terminal too small (%dx%d, need %dx%d) — enlarge or press Esc", width, height, minLayoutWidth, minLayoutHeight)
+	lines := strings.Split(lipgloss.NewStyle().Width(width).Render(text), "\n")
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return lipgloss.NewStyle().Foreground(colorWarn).Render(strings.Join(lines, "\n"))
+}
+
+// sessionHeaderLines is the height of the session header above the table.
+const sessionHeaderLines = 1
+
+// renderSessionHeader renders the one-line session summary: elapsed time,
+// service counts by status, total reconnects, and what is being run where.
+func (u *UI) renderSessionHeader() string {
+	return renderSessionHeader(u.services, u.groups, u.sessionStart, u.deadline, u.sessionLabel, u.kubeContext, u.width)
+}
+
+// renderSessionHeader renders the header for the given state. A non-zero
+// deadline adds the session TTL countdown, in the warning color once it is
+// within TTLWarning. Each started group shows as "▣ backend 2/3" in the color
+// of its combined status.
+func renderSessionHeader(services []model.Service, groups []model.GroupState, start, deadline time.Time, label, kubeContext string, width int) string {
+	muted := lipgloss.NewStyle().Foreground(colorMuted)
+	sep := muted.Render("  •  ")
+
+	counts := map[string]int{}
+	reconnects := 0
+	for i := range services {
+		counts[services[i].Status]++
+		reconnects += services[i].RestartCount
+	}
+
+	parts := []string{
+		lipgloss.NewStyle().Foreground(colorAccent).Bold(true).Render("pf") +
+			muted.Render(" "+formatUptime(start)),
+	}
+	if !deadline.IsZero() {
+		left := max(time.Until(deadline), 0)
+		style := muted
+		if left <= TTLWarning {
+			style = lipgloss.NewStyle().Foreground(colorWarn).Bold(true)
+		}
+		parts = append(parts, style.Render("⏳ "+formatDuration(left)+" left"))
+	}
+
+	var statuses []string
+	for _, st := range []struct {
+		status string
+		color  color.Color
+	}{
+		{model.StatusHealthy, statusHealthyColor},
+		{model.StatusConnecting, statusConnectingColor},
+		{model.StatusError, statusErrorColor},
+	} {
+		if n := counts[st.status]; n > 0 {
+			statuses = append(statuses, lipgloss.NewStyle().Foreground(st.color).
+				Render(fmt.Sprintf("● %d %s", n, st.status)))
+		}
+	}
+	if len(statuses) == 0 {
+		statuses = append(statuses, muted.Render("no services"))
+	}
+	parts = append(parts, strings.Join(statuses, "  "))
+
+	reconnectColor := colorMuted
+	if reconnects > 0 {
+		reconnectColor = colorWarn
+	}
+	parts = append(parts, lipgloss.NewStyle().Foreground(reconnectColor).
+		Render(fmt.Sprintf("↻ %d reconnects", reconnects)))
+
+	if label != "" {
+		parts = append(parts, muted.Render("run: ")+lipgloss.NewStyle().Foreground(colorText).Render(label))
+	}
+	if len(groups) > 0 {
+		stacks := make([]string, 0, len(groups))
+		for _, g := range groups {
+			c := statusConnectingColor
+			switch g.Status {
+			case model.StatusHealthy:
+				c = statusHealthyColor
+			case model.StatusError:
+				c = statusErrorColor
+			}
+			stacks = append(stacks, lipgloss.NewStyle().Foreground(c).
+				Render(fmt.Sprintf("▣ %s %d/%d", g.Name, g.Healthy, len(g.Members))))
+		}
+		parts = append(parts, strings.Join(stacks, "  "))
+	}
+	if kubeContext != "" {
+		parts = append(parts, muted.Render("ctx: ")+lipgloss.NewStyle().Foreground(colorText).Render(kubeContext))
+	}
+
+	line := " " + strings.Join(parts, sep)
+	if width > 0 && lipgloss.Width(line) > width {
+		line = ansi.Truncate(line, width-1, "…")
+	}
+	return line
+}
+
 func renderEmptyState() string {
 	emptyStyle := lipgloss.NewStyle().
 		Foreground(colorMuted).
@@ -1387,7 +2053,37 @@ func renderEmptyState() string {
 	return emptyStyle.Render("⚬ No services running...")
 }
 
+// serviceTableLayout holds the column widths computed once per render of the
+// services table, shared by the header and every row.
+type serviceTableLayout struct {
+	compact       bool
+	showIcons     bool
+	iconWidth     int
+	nameWidth     int
+	nameCellWidth int
+	statusWidth   int
+	uptimeWidth   int
+	portWidth     int
+	restartWidth  int
+}
+
+// rowCache memoizes rendered table rows keyed by everything that affects how a
+// row looks, so a change to one service only re-styles that service's row.
+// Entries for services no longer on screen are dropped on each render.
+type rowCache struct {
+	rows map[string]cachedRow
+}
+
+type cachedRow struct {
+	key string
+	out string
+}
+
 func renderServiceTable(services []model.Service, selectedIndex, offset, maxVisible, width int) string {
+	return renderServiceTableCached(nil, services, selectedIndex, offset, maxVisible, width)
+}
+
+func renderServiceTableCached(cache *rowCache, services []model.Service, selectedIndex, offset, maxVisible, width int) string {
 	if width < 60 {
 		width = 60
 	}
@@ -1404,76 +2100,22 @@ func renderServiceTable(services []model.Service, selectedIndex, offset, maxVisi
 		end = len(services)
 	}
 
-	compact := width < 90
-	showIcons := false
-	for i := start; i < end; i++ {
-		if services[i].IconEnabled {
-			showIcons = true
-			break
-		}
-	}
-	iconWidth := 0
-	if showIcons {
-		iconWidth = 2
-	}
-	statusWidth := 12
-	uptimeWidth := 8
-	portWidth := 6
-	restartWidth := 8
-	maxNameLen := 7
-	for i := range services {
-		nameLen := len(services[i].Name)
-		if nameLen > maxNameLen {
-			maxNameLen = nameLen
-		}
-	}
-	if maxNameLen > 30 {
-		maxNameLen = 30
-	}
-
-	available := width - 2
-	if available < 60 {
-		available = 60
-	}
-	if compact {
-		minName := 8
-		fixed := statusWidth + portWidth + iconWidth + 6
-		nameWidth := available - fixed
-		if nameWidth < minName {
-			nameWidth = minName
-		}
-		if nameWidth > maxNameLen {
-			nameWidth = maxNameLen
-		}
-		maxNameLen = nameWidth
-	} else {
-		minName := 10
-		fixed := statusWidth + uptimeWidth + portWidth + restartWidth + iconWidth + 10
-		nameWidth := available - fixed
-		if nameWidth < minName {
-			nameWidth = minName
-		}
-		if nameWidth > maxNameLen {
-			nameWidth = maxNameLen
-		}
-		maxNameLen = nameWidth
-	}
+	layout := newServiceTableLayout(services[start:end], services, width)
 
 	rows := make([]string, 0, len(services)+2)
 	headerPrefix := "  "
-	nameCellWidth := maxNameLen + iconWidth
-	headerLine := headerPrefix + padRightDisplayWidth("SERVICE", nameCellWidth) + fmt.Sprintf(
+	headerLine := headerPrefix + padRightDisplayWidth("SERVICE", layout.nameCellWidth) + fmt.Sprintf(
 		"  %-*s",
-		statusWidth, "STATUS",
+		layout.statusWidth, "STATUS",
 	)
-	if compact {
-		headerLine += fmt.Sprintf("  %-*s", portWidth, "PORT")
+	if layout.compact {
+		headerLine += fmt.Sprintf("  %-*s", layout.portWidth, "PORT")
 	} else {
 		headerLine += fmt.Sprintf(
 			"  %-*s  %-*s  %-*s",
-			uptimeWidth, "UPTIME",
-			portWidth, "PORT",
-			restartWidth, "RESTARTS",
+			layout.uptimeWidth, "UPTIME",
+			layout.portWidth, "ADDRESS",
+			layout.restartWidth, "RESTARTS",
 		)
 	}
 	header := lipgloss.NewStyle().
@@ -1491,86 +2133,29 @@ func renderServiceTable(services []model.Service, selectedIndex, offset, maxVisi
 	}
 	rows = append(rows, lipgloss.NewStyle().Foreground(colorBorder).Render(strings.Repeat("─", sepWidth)))
 
+	var seen map[string]cachedRow
+	if cache != nil {
+		seen = make(map[string]cachedRow, end-start)
+	}
 	for i := start; i < end; i++ {
 		svc := &services[i]
-		var statusIcon, statusText string
-		var statusColor color.Color
-
 		selected := i == selectedIndex
-		highlight := "  "
-		if selected {
-			highlight = "► "
-		}
-
-		switch svc.Status {
-		case model.StatusHealthy:
-			statusColor = statusHealthyColor
-			statusIcon = "●"
-			statusText = "HEALTHY"
-		case model.StatusConnecting:
-			statusColor = statusConnectingColor
-			statusIcon = "◐"
-			statusText = "CONNECTING"
-		case model.StatusError:
-			statusColor = statusErrorColor
-			statusIcon = "✗"
-			statusText = "ERROR"
-		}
-
 		uptime := formatUptime(svc.StartTime)
 
-		status := fmt.Sprintf("%s %-*s", statusIcon, statusWidth-2, statusText)
-		uptimeStr := fmt.Sprintf("%-*s", uptimeWidth, uptime)
-		portStr := fmt.Sprintf("%-*s", portWidth, svc.LocalPort)
-		restarts := fmt.Sprintf("%-*d", restartWidth, svc.RestartCount)
-
-		nameColor := colorText
-		if selected {
-			nameColor = colorAccent
-		}
-		displayName := truncateRunes(svc.Name, maxNameLen)
-		nameText := padRightDisplayWidth(displayName, maxNameLen)
-		styledName := lipgloss.NewStyle().
-			Foreground(nameColor).
-			Bold(true).
-			Render(nameText)
-		if showIcons {
-			cell := "  "
-			if svc.IconEnabled {
-				icon := serviceIcon(svc)
-				cell = renderIconCell(icon.Glyph, icon.Color)
-			}
-			styledName = padRightDisplayWidth(cell+styledName, nameCellWidth)
+		if cache == nil {
+			rows = append(rows, renderServiceRow(svc, selected, uptime, layout))
+			continue
 		}
-
-		styledStatus := lipgloss.NewStyle().
-			Foreground(statusColor).
-			Render(status)
-
-		styledUptime := lipgloss.NewStyle().
-			Foreground(colorMuted).
-			Render(uptimeStr)
-
-		styledRestarts := lipgloss.NewStyle().
-			Foreground(colorMuted).
-			Render(restarts)
-
-		styledPort := lipgloss.NewStyle().
-			Foreground(colorText).
-			Render(portStr)
-
-		marker := highlight
-		if selected {
-			marker = lipgloss.NewStyle().Foreground(colorAccent).Bold(true).Render(highlight)
+		key := serviceRowKey(svc, selected, uptime, layout)
+		entry, ok := cache.rows[svc.Name]
+		if !ok || entry.key != key {
+			entry = cachedRow{key: key, out: renderServiceRow(svc, selected, uptime, layout)}
 		}
-
-		row := marker + styledName + "  " + styledStatus
-		if compact {
-			row += "  " + styledPort
-		} else {
-			row += "  " + styledUptime + "  " + styledPort + "  " + styledRestarts
-		}
-		rows = append(rows, row)
+		seen[svc.Name] = entry
+		rows = append(rows, entry.out)
+	}
+	if cache != nil {
+		cache.rows = seen
 	}
 
 	if len(services) > maxVisible {
@@ -1588,7 +2173,7 @@ func renderServiceTable(services []model.Service, selectedIndex, offset, maxVisi
 		rows = append(rows, lipgloss.NewStyle().
 			Foreground(colorWarn).
 			Bold(true).
-			Render(indicator))
+			Render(truncateDisplay(indicator, width-4)))
 	}
 
 	table := lipgloss.JoinVertical(lipgloss.Left, rows...)
@@ -1601,12 +2186,336 @@ func renderServiceTable(services []model.Service, selectedIndex, offset, maxVisi
 	return style.Render(table)
 }
 
+// newServiceTableLayout sizes the columns for the visible rows. Name widths are
+// measured in terminal cells (not bytes or runes) so wide CJK or emoji names
+// line up with ASCII ones.
+func newServiceTableLayout(visible, all []model.Service, width int) serviceTableLayout {
+	l := serviceTableLayout{
+		compact:      width < 90,
+		statusWidth:  13, // "○ MAINTENANCE"
+		uptimeWidth:  8,
+		portWidth:    6,
+		restartWidth: 8,
+	}
+	for i := range visible {
+		if visible[i].IconEnabled {
+			l.showIcons = true
+			break
+		}
+	}
+	if l.showIcons {
+		l.iconWidth = 2
+	}
+
+	maxNameLen := 7
+	for i := range all {
+		if w := lipgloss.Width(all[i].Name + nameBadge(&all[i])); w > maxNameLen {
+			maxNameLen = w
+		}
+	}
+	if maxNameLen > 30 {
+		maxNameLen = 30
+	}
+
+	available := width - 2
+	if available < 60 {
+		available = 60
+	}
+	minName := 10
+	if !l.compact {
+		// The wide layout shows the full forward ("127.0.0.1:5432 → svc/db:5432")
+		// in place of the bare port, sized to the longest one but never so wide
+		// that names drop below their minimum.
+		l.portWidth = len("ADDRESS")
+		for i := range all {
+			if w := lipgloss.Width(forwardLabel(&all[i])); w > l.portWidth {
+				l.portWidth = w
+			}
+		}
+		if room := available - (l.statusWidth + l.uptimeWidth + l.restartWidth + l.iconWidth + 10) - minName; l.portWidth > room {
+			l.portWidth = room
+		}
+	}
+	fixed := l.statusWidth + l.uptimeWidth + l.portWidth + l.restartWidth + l.iconWidth + 10
+	if l.compact {
+		minName = 8
+		fixed = l.statusWidth + l.portWidth + l.iconWidth + 6
+	}
+	nameWidth := available - fixed
+	if nameWidth < minName {
+		nameWidth = minName
+	}
+	if nameWidth > maxNameLen {
+		nameWidth = maxNameLen
+	}
+	l.nameWidth = nameWidth
+	l.nameCellWidth = nameWidth + l.iconWidth
+	return l
+}
+
+// serviceRowKey captures every input renderServiceRow reads, so equal keys mean
+// an identical rendered row.
+func serviceRowKey(svc *model.Service, selected bool, uptime string, l serviceTableLayout) string {
+	now := time.Now()
+	return fmt.Sprintf("%v|%s|%t|%t|%t|%t|%s|%s|%d|%d|%s|%s|%s|%t|%s|%s|%s|%t|%+v",
+		selected, svc.Status, svc.Flapping(now), svc.InMaintenance(now), svc.RollingOut, svc.Degraded, uptime, svc.LocalPort, svc.RestartCount, svc.Endpoint,
+		svc.MainPort, svc.BindAddress, svc.Target+"@"+svc.Namespace+"|"+svc.Relay, svc.IconEnabled, svc.IconGlyph, svc.IconColor, svc.Deprecated, svc.Throttled(now), l)
+}
+
+func renderServiceRow(svc *model.Service, selected bool, uptime string, l serviceTableLayout) string {
+	var statusIcon, statusText string
+	var statusColor color.Color
+
+	highlight := "  "
+	if selected {
+		highlight = "► "
+	}
+
+	switch svc.Status {
+	case model.StatusHealthy:
+		statusColor = statusHealthyColor
+		statusIcon = "●"
+		statusText = "HEALTHY"
+	case model.StatusConnecting:
+		statusColor = statusConnectingColor
+		statusIcon = "◐"
+		statusText = "CONNECTING"
+	case model.StatusError:
+		statusColor = statusErrorColor
+		statusIcon = "✗"
+		statusText = "ERROR"
+	}
+	if now := time.Now(); svc.InMaintenance(now) {
+		statusColor = colorMuted
+		statusIcon = "○"
+		statusText = "MAINTENANCE"
+	} else if svc.RollingOut {
+		statusColor = colorWarn
+		statusIcon = "◐"
+		statusText = "ROLLOUT"
+	} else if svc.Flapping(now) {
+		statusColor = colorWarn
+		statusIcon = "↯"
+		statusText = "FLAPPING"
+	} else if svc.Degraded && svc.Status == model.StatusHealthy {
+		statusColor = colorWarn
+		statusText = "DEGRADED"
+	}
+
+	status := fmt.Sprintf("%s %-*s", statusIcon, l.statusWidth-2, statusText)
+	uptimeStr := fmt.Sprintf("%-*s", l.uptimeWidth, uptime)
+	port := svc.LocalPort
+	if !l.compact || svc.Monitor != "" {
+		port = forwardLabel(svc)
+	}
+	portStr := padRightDisplayWidth(truncateDisplay(port, l.portWidth), l.portWidth)
+	restarts := fmt.Sprintf("%-*d", l.restartWidth, svc.RestartCount)
+
+	nameColor := colorText
+	if selected {
+		nameColor = colorAccent
+	}
+	badge := nameBadge(svc)
+	displayName := truncateDisplay(svc.Name, l.nameWidth-lipgloss.Width(badge))
+	styledName := lipgloss.NewStyle().
+		Foreground(nameColor).
+		Bold(true).
+		Render(displayName) +
+		lipgloss.NewStyle().Foreground(colorWarn).Render(badge)
+	styledName = padRightDisplayWidth(styledName, l.nameWidth)
+	if l.showIcons {
+		cell := "  "
+		if svc.IconEnabled {
+			icon := serviceIcon(svc)
+			cell = renderIconCell(icon.Glyph, icon.Color)
+		}
+		styledName = padRightDisplayWidth(cell+styledName, l.nameCellWidth)
+	}
+
+	styledStatus := lipgloss.NewStyle().
+		Foreground(statusColor).
+		Render(status)
+
+	styledUptime := lipgloss.NewStyle().
+		Foreground(colorMuted).
+		Render(uptimeStr)
+
+	styledRestarts := lipgloss.NewStyle().
+		Foreground(colorMuted).
+		Render(restarts)
+
+	styledPort := lipgloss.NewStyle().
+		Foreground(colorText).
+		Render(portStr)
+
+	marker := highlight
+	if selected {
+		marker = lipgloss.NewStyle().Foreground(colorAccent).Bold(true).Render(highlight)
+	}
+
+	row := marker + styledName + "  " + styledStatus
+	if l.compact {
+		row += "  " + styledPort
+	} else {
+		row += "  " + styledUptime + "  " + styledPort + "  " + styledRestarts
+	}
+	return row
+}
+
+// detailTransitions is how many of a service's latest status changes its
+// detail shows.
+const detailTransitions = 5
+
+// renderServiceDetail is the summary above a single service's log: why its
+// process last died, until it is healthy again, how often it reconnected this
+// session and why, history beyond the session when there is any, its next
+// scheduled restart, how much of its output was dropped, and its latest status changes with how long it was
+// in each status before.
+func renderServiceDetail(svc *model.Service, history string, maxWidth int) string {
+	var died []string
+	if e := svc.LastExit; e != nil {
+		died = append(died, fmt.Sprintf("Died at %s: %s after %s", e.At.Format("15:04:05"), e.Reason(), formatDuration(e.Ran)))
+		for _, line := range e.Stderr {
+			died = append(died, "  │ "+line)
+		}
+		if len(e.Stderr) == 0 {
+			died = append(died, "  │ (nothing on stderr)")
+		}
+		errStyle := lipgloss.NewStyle().Foreground(colorError)
+		for i, line := range died {
+			died[i] = errStyle.Render(truncateDisplay(line, maxWidth))
+		}
+		if e.Hint != "" {
+			for _, line := range wrapText("Hint: "+e.Hint, maxWidth) {
+				died = append(died, lipgloss.NewStyle().Foreground(colorAccent).Render(line))
+			}
+		}
+	}
+
+	reconnects := "none"
+	if len(svc.Reconnects) > 0 {
+		reconnects = model.FormatReconnects(svc.Reconnects)
+	}
+	lines := []string{"Reconnects: " + reconnects}
+	if history != "" {
+		lines = append(lines, "History: "+history)
+	}
+	if !svc.NextRestart.IsZero() {
+		lines = append(lines, fmt.Sprintf("Next restart: %s (in %s)",
+			svc.NextRestart.Format("Mon 15:04 MST"), formatDuration(max(time.Until(svc.NextRestart), 0))))
+	}
+	if svc.OutputDropped > 0 {
+		dropped := fmt.Sprintf("Output: %d lines dropped, printed faster than pf could log them", svc.OutputDropped)
+		if svc.Throttled(time.Now()) {
+			dropped += " (throttled now)"
+		}
+		lines = append(lines, dropped)
+	}
+	transitions := svc.Transitions
+	if len(transitions) > detailTransitions {
+		transitions = transitions[len(transitions)-detailTransitions:]
+	}
+	for _, t := range transitions {
+		lines = append(lines, fmt.Sprintf("%s  %s → %s  after %s in %s",
+			t.At.Format("15:04:05"), t.From, t.To, formatDuration(t.Duration), t.From))
+	}
+	style := lipgloss.NewStyle().Foreground(colorMuted)
+	for i, line := range lines {
+		lines[i] = style.Render(truncateDisplay(line, maxWidth))
+	}
+	return strings.Join(append(died, lines...), "\n")
+}
+
+// nameBadge marks a deprecated service after its name, and one whose output
+// is being throttled; what it means is in the service's log.
+func nameBadge(svc *model.Service) string {
+	badge := ""
+	if svc.Deprecated != "" {
+		badge += " †"
+	}
+	if svc.Throttled(time.Now()) {
+		badge += " ≋"
+	}
+	if svc.Prompt != "" {
+		badge += " ⌨"
+	}
+	return badge
+}
+
+// sidebarPort is the " :5432" after a service's name in the narrow layout;
+// monitors listen nowhere and get none.
+func sidebarPort(svc *model.Service) string {
+	if svc.Monitor != "" {
+		return ""
+	}
+	return " :" + svc.LocalPort
+}
+
+// copyAddress puts the service's local address on the clipboard (via the
+// terminal, so it also works over ssh) and confirms it on the status line.
+func (u *UI) copyAddress(svc *model.Service) tea.Cmd {
+	if svc.Monitor != "" {
+		return u.setStatus(svc.Name + " is a monitor: it has no address")
+	}
+	addr := localAddress(svc)
+	return tea.Batch(tea.SetClipboard(addr), u.setStatus("✓ Copied "+addr))
+}
+
+// localAddress is where a service actually listens, e.g. "127.0.0.1:5432".
+// Commands without an explicit bind address listen on loopback.
+func localAddress(svc *model.Service) string {
+	host := svc.BindAddress
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, svc.LocalPort)
+}
+
+// kubeAPILabel stands for the remote side of a kubectl proxy service.
+const kubeAPILabel = "kube-api"
+
+// forwardLabel renders a service's forward as "127.0.0.1:5432 → svc/db:5432",
+// falling back to just the remote port when the target is unknown. A namespace
+// is appended as " @ ns" so same-port forwards from different namespaces can be
+// told apart, and a relay as " via addr" since that is where clients connect.
+// A monitor forwards nothing, so it shows what it checks instead.
+func forwardLabel(svc *model.Service) string {
+	if svc.Monitor != "" {
+		return "monitor " + svc.Monitor
+	}
+	remote := svc.MainPort
+	if svc.Target != "" {
+		remote = svc.Target + ":" + svc.MainPort
+	}
+	if svc.APIProxy {
+		remote = kubeAPILabel
+	}
+	label := localAddress(svc) + " → " + remote
+	if svc.Namespace != "" {
+		label += " @ " + svc.Namespace
+	}
+	if svc.Relay != "" {
+		label += " via " + svc.Relay
+	}
+	if svc.Degraded {
+		label += " (fallback)"
+	} else if svc.Endpoints > 1 {
+		label += fmt.Sprintf(" (%d/%d)", svc.Endpoint, svc.Endpoints)
+	}
+	return label
+}
+
 func formatUptime(startTime time.Time) string {
 	if startTime.IsZero() {
 		return "-"
 	}
 
-	duration := time.Since(startTime)
+	return formatDuration(time.Since(startTime))
+}
+
+// formatDuration renders d at the precision the TUI shows for times:
+// "2h 5m", "5m 3s" or "42s".
+func formatDuration(duration time.Duration) string {
 	hours := int(duration.Hours())
 	minutes := int(duration.Minutes()) % 60
 	seconds := int(duration.Seconds()) % 60
@@ -1619,7 +2528,18 @@ func formatUptime(startTime time.Time) string {
 	return fmt.Sprintf("%ds", seconds)
 }
 
-func renderLogsContent(services []model.Service, maxWidth int) string {
+// logLineCache memoizes the rendered lines of each log entry. Entries are
+// immutable once logged, so a refresh only styles lines that are new (or whose
+// wrap width changed); entries that scrolled out of the ring are dropped.
+type logLineCache struct {
+	lines map[string]string
+}
+
+func logEntryKey(serviceName string, entry model.LogEntry, maxWidth int) string {
+	return fmt.Sprintf("%s|%d|%d|%t|%d|%s|%s", serviceName, entry.Time.UnixNano(), maxWidth, entry.IsError, entry.Kind, entry.Status, entry.Message)
+}
+
+func renderLogsContentCached(cache *logLineCache, services []model.Service, maxWidth int) string {
 	var content strings.Builder
 
 	type logWithService struct {
@@ -1650,154 +2570,188 @@ func renderLogsContent(services []model.Service, maxWidth int) string {
 		return content.String()
 	}
 
-	for i := 0; i < len(allLogs); i++ {
-		log := allLogs[i]
-		timestamp := log.Entry.Time.Format("15:04:05")
-
-		nameWidth := maxWidth / 4
-		if nameWidth < 8 {
-			nameWidth = 8
-		}
-		if nameWidth > 24 {
-			nameWidth = 24
-		}
-		serviceName := truncateRunes(log.ServiceName, nameWidth)
-		namePlain := padRightRunes(serviceName, nameWidth)
-
-		message := log.Entry.Message
-		msgColor := colorText
-		if log.Entry.IsError {
-			msgColor = colorError
-		} else if strings.Contains(message, "━━━━") {
-			msgColor = colorWarn
+	var seen map[string]string
+	if cache != nil {
+		seen = make(map[string]string, len(allLogs))
+	}
+	for _, log := range allLogs {
+		if cache == nil {
+			content.WriteString(renderLogEntry(log.ServiceName, log.Entry, maxWidth))
+			continue
 		}
-
-		prefixWidth := nameWidth + 12
-		availableWidth := maxWidth - prefixWidth
-		if availableWidth < 20 {
-			availableWidth = 20
+		key := logEntryKey(log.ServiceName, log.Entry, maxWidth)
+		out, ok := cache.lines[key]
+		if !ok {
+			out = renderLogEntry(log.ServiceName, log.Entry, maxWidth)
 		}
+		seen[key] = out
+		content.WriteString(out)
+	}
+	if cache != nil {
+		cache.lines = seen
+	}
 
-		wrappedLines := wrapText(message, availableWidth)
-
-		nameStyled := lipgloss.NewStyle().
-			Foreground(colorAccent).
-			Bold(true).
-			Render(namePlain)
+	return content.String()
+}
 
-		timeStyled := lipgloss.NewStyle().
+// renderGroupedLogsCached renders the log grouped by service instead of
+// interleaved by time: each service gets a header with its line count, followed
+// by its own lines unless it is collapsed. A noisy service can then be folded
+// away without hiding the others.
+func renderGroupedLogsCached(cache *logLineCache, services []model.Service, collapsed map[string]bool, maxWidth int) string {
+	if len(services) == 0 {
+		return lipgloss.NewStyle().
 			Foreground(colorMuted).
-			Render(timestamp)
-
-		if len(wrappedLines) > 0 {
-			msgStyled := lipgloss.NewStyle().
-				Foreground(msgColor).
-				Render(wrappedLines[0])
-			logLine := fmt.Sprintf("[%s %s] %s", nameStyled, timeStyled, msgStyled)
-			content.WriteString(logLine)
-			content.WriteString("\n")
-
-			if len(wrappedLines) > 1 {
-				indent := strings.Repeat(" ", prefixWidth)
-				for j := 1; j < len(wrappedLines); j++ {
-					msgStyled := lipgloss.NewStyle().
-						Foreground(msgColor).
-						Render(wrappedLines[j])
-					content.WriteString(indent + msgStyled + "\n")
-				}
+			Italic(true).
+			Render("No logs yet...")
+	}
+
+	var content strings.Builder
+	var seen map[string]string
+	if cache != nil {
+		seen = make(map[string]string)
+	}
+	headerStyle := lipgloss.NewStyle().Foreground(colorHeading).Bold(true)
+	countStyle := lipgloss.NewStyle().Foreground(colorMuted)
+	for i := range services {
+		svc := &services[i]
+		folded := collapsed[svc.Name]
+		arrow := "▾"
+		if folded {
+			arrow = "▸"
+		}
+		count := fmt.Sprintf("  (%d lines)", len(svc.Logs))
+		if folded {
+			count = fmt.Sprintf("  (%d lines hidden — z to expand)", len(svc.Logs))
+		}
+		content.WriteString(headerStyle.Render(arrow+" "+truncateDisplay(svc.Name, maxWidth-len(count)-2)) + countStyle.Render(count) + "\n")
+		if folded {
+			continue
+		}
+		for _, entry := range svc.Logs {
+			if cache == nil {
+				content.WriteString(renderLogEntry(svc.Name, entry, maxWidth))
+				continue
 			}
+			key := logEntryKey(svc.Name, entry, maxWidth)
+			out, ok := cache.lines[key]
+			if !ok {
+				out = renderLogEntry(svc.Name, entry, maxWidth)
+			}
+			seen[key] = out
+			content.WriteString(out)
 		}
 	}
-
+	if cache != nil {
+		cache.lines = seen
+	}
 	return content.String()
 }
 
-func wrapText(text string, maxWidth int) []string {
-	if maxWidth <= 0 {
-		return []string{text}
+// renderLogEntry renders one log entry as "[name time] message", wrapping long
+// messages onto indented continuation lines. The result ends with a newline.
+func renderLogEntry(serviceName string, entry model.LogEntry, maxWidth int) string {
+	var content strings.Builder
+	timestamp := entry.Time.Format("15:04:05")
+
+	nameWidth := maxWidth / 4
+	if nameWidth < 8 {
+		nameWidth = 8
 	}
-	if len(text) <= maxWidth {
-		return []string{text}
+	if nameWidth > 24 {
+		nameWidth = 24
 	}
+	namePlain := padRightDisplayWidth(truncateDisplay(serviceName, nameWidth), nameWidth)
 
-	var lines []string
-	words := strings.Fields(text)
-	if len(words) == 0 {
-		for i := 0; i < len(text); i += maxWidth {
-			end := i + maxWidth
-			if end > len(text) {
-				end = len(text)
-			}
-			lines = append(lines, text[i:end])
-		}
-		return lines
+	message := entry.Message
+	msgColor := logEntryColor(entry)
+
+	prefixWidth := nameWidth + 12
+	availableWidth := maxWidth - prefixWidth
+	if availableWidth < 20 {
+		availableWidth = 20
 	}
 
-	var currentLine strings.Builder
-	for _, word := range words {
-		if len(word) > maxWidth {
-			if currentLine.Len() > 0 {
-				lines = append(lines, currentLine.String())
-				currentLine.Reset()
-			}
-			for i := 0; i < len(word); i += maxWidth {
-				end := i + maxWidth
-				if end > len(word) {
-					end = len(word)
-				}
-				lines = append(lines, word[i:end])
-			}
-			continue
-		}
+	wrappedLines := wrapText(message, availableWidth)
 
-		testLine := currentLine.String()
-		if len(testLine) > 0 {
-			testLine += " " + word
-		} else {
-			testLine = word
-		}
+	nameStyled := lipgloss.NewStyle().
+		Foreground(colorAccent).
+		Bold(true).
+		Render(namePlain)
 
-		if len(testLine) > maxWidth {
-			if currentLine.Len() > 0 {
-				lines = append(lines, currentLine.String())
-				currentLine.Reset()
-			}
-			currentLine.WriteString(word)
-		} else {
-			if currentLine.Len() > 0 {
-				currentLine.WriteString(" ")
+	timeStyled := lipgloss.NewStyle().
+		Foreground(colorMuted).
+		Render(timestamp)
+
+	msgStyle := lipgloss.NewStyle().Foreground(msgColor).Bold(entry.Kind != model.LogKindOutput && entry.Kind != model.LogKindHTTP)
+	if len(wrappedLines) > 0 {
+		msgStyled := msgStyle.Render(wrappedLines[0])
+		content.WriteString(fmt.Sprintf("[%s %s] %s", nameStyled, timeStyled, msgStyled))
+		content.WriteString("\n")
+
+		if len(wrappedLines) > 1 {
+			indent := strings.Repeat(" ", prefixWidth)
+			for j := 1; j < len(wrappedLines); j++ {
+				msgStyled := msgStyle.Render(wrappedLines[j])
+				content.WriteString(indent + msgStyled + "\n")
 			}
-			currentLine.WriteString(word)
 		}
 	}
 
-	if currentLine.Len() > 0 {
-		lines = append(lines, currentLine.String())
+	return content.String()
+}
+
+// logEntryColor picks a log line's color. Status markers take the fixed color
+// of the status they moved to, so an "→ ERROR" marker reads red under any theme.
+func logEntryColor(entry model.LogEntry) color.Color {
+	switch entry.Kind {
+	case model.LogKindStatus:
+		switch entry.Status {
+		case model.StatusHealthy:
+			return statusHealthyColor
+		case model.StatusError:
+			return statusErrorColor
+		default:
+			return statusConnectingColor
+		}
+	case model.LogKindReconnect:
+		return colorWarn
+	case model.LogKindHint:
+		return colorAccent
+	case model.LogKindHTTP:
+		if !entry.IsError {
+			return colorAccentAlt
+		}
 	}
+	if entry.IsError {
+		return colorError
+	}
+	return colorText
+}
 
-	return lines
+// wrapText word-wraps text to maxWidth terminal cells, hard-breaking words that
+// are longer than a line. Runs of whitespace collapse to a single space.
+func wrapText(text string, maxWidth int) []string {
+	if maxWidth <= 0 || lipgloss.Width(text) <= maxWidth {
+		return []string{text}
+	}
+	return strings.Split(ansi.Wrap(strings.Join(strings.Fields(text), " "), maxWidth, ""), "\n")
 }
 
-func truncateRunes(text string, max int) string {
+// truncateDisplay shortens text to at most max terminal cells, ending in "..."
+// when there is room for it. Widths are measured in cells, so double-width CJK
+// characters and emoji never push a column past its budget.
+func truncateDisplay(text string, max int) string {
 	if max <= 0 {
 		return ""
 	}
-	runes := []rune(text)
-	if len(runes) <= max {
+	if lipgloss.Width(text) <= max {
 		return text
 	}
 	if max <= 3 {
-		return string(runes[:max])
+		return ansi.Truncate(text, max, "")
 	}
-	return string(runes[:max-3]) + "..."
-}
-
-func padRightRunes(text string, width int) string {
-	runes := []rune(text)
-	if len(runes) >= width {
-		return text
-	}
-	return text + strings.Repeat(" ", width-len(runes))
+	return ansi.Truncate(text, max, "...")
 }
 
 func padRightDisplayWidth(text string, width int) string {
@@ -1876,7 +2830,7 @@ func (u *UI) renderManageGroupRow(name string, cursorOn bool, maxNameLen int, ru
 		nameColor = colorAccent
 	}
 	styledName := lipgloss.NewStyle().Foreground(nameColor).Bold(true).
-		Render(padRightDisplayWidth(truncateRunes(name, maxNameLen), maxNameLen))
+		Render(padRightDisplayWidth(truncateDisplay(name, maxNameLen), maxNameLen))
 
 	members := u.manageGroups[name]
 	run := 0
@@ -1904,7 +2858,7 @@ func (u *UI) renderManageServiceRow(name string, cursorOn bool, maxNameLen int,
 		nameColor = colorAccent
 	}
 	styledName := lipgloss.NewStyle().Foreground(nameColor).Bold(true).
-		Render(padRightDisplayWidth(truncateRunes(name, maxNameLen), maxNameLen))
+		Render(padRightDisplayWidth(truncateDisplay(name, maxNameLen), maxNameLen))
 
 	var box, indicator string
 	if running[name] {
@@ -1917,7 +2871,11 @@ func (u *UI) renderManageServiceRow(name string, cursorOn bool, maxNameLen int,
 
 	icon := u.overlayIconCell(u.manageIcons.set.ForPort(u.manageIcons.ports[name]))
 
-	return highlight + box + " " + icon + styledName + "  " + indicator
+	row := highlight + box + " " + icon + styledName + "  " + indicator
+	if target := u.manageTargets[name]; target != "" {
+		row += lipgloss.NewStyle().Foreground(colorMuted).Render("  " + target)
+	}
+	return row
 }
 
 // renderSelectCheckbox draws a multi-select checkbox, brightening to the accent
@@ -1951,13 +2909,13 @@ func (u *UI) renderManageOverlay() string {
 
 	maxNameLen := 7
 	for _, n := range u.manageGroupNames {
-		if len(n) > maxNameLen {
-			maxNameLen = len(n)
+		if w := lipgloss.Width(n); w > maxNameLen {
+			maxNameLen = w
 		}
 	}
 	for _, n := range u.manageServices {
-		if len(n) > maxNameLen {
-			maxNameLen = len(n)
+		if w := lipgloss.Width(n); w > maxNameLen {
+			maxNameLen = w
 		}
 	}
 	if maxNameLen > 30 {
@@ -2033,7 +2991,7 @@ func (u *UI) renderManageOverlay() string {
 			Width(width-2).
 			Render(promptBody))
 	case u.manageConfirmDelete != "":
-		msg := fmt.Sprintf("Delete service '%s'? This cannot be undone.", u.manageConfirmDelete)
+		msg := fmt.Sprintf("Delete service '%s'? pf restore brings it back for 30 days.", u.manageConfirmDelete)
 		if u.manageConfirmKind == "group" {
 			msg = fmt.Sprintf("Delete group '%s'? Member services are kept.", u.manageConfirmDelete)
 		}
@@ -2058,6 +3016,7 @@ func (u *UI) renderManageOverlay() string {
 		{"Space", "select"},
 		{"Enter", "run"},
 		{"^n", "new"},
+		{"^g", "group running"},
 		{"^e", "edit"},
 		{"^d", "delete"},
 		{"^c", "config"},
@@ -2146,7 +3105,7 @@ func summarizeMembers(members []string) string {
 		return "(empty)"
 	}
 	joined := strings.Join(members, ", ")
-	if len(joined) > 48 {
+	if lipgloss.Width(joined) > 48 {
 		return fmt.Sprintf("%d services", len(members))
 	}
 	return joined
@@ -2272,7 +3231,7 @@ func renderActionChips(pairs [][2]string) string {
 // helpLines builds the wrapped, balanced content rows for the help bar (without
 // the surrounding border). The height layout depends on len(helpLines(...)), so
 // renderHelp must render exactly these lines.
-func helpLines(width int, logScope string) []string {
+func helpLines(width int, hs helpState) []string {
 	if width < 60 {
 		width = 60
 	}
@@ -2283,29 +3242,46 @@ func helpLines(width int, logScope string) []string {
 	sepStyled := descStyle.Render(sepText)
 	sepW := lipgloss.Width(sepText)
 
+	km := activeKeymap
 	type chip struct{ k, d string }
 	var chips []chip
 	if width < 90 {
 		chips = []chip{
-			{"↑↓", "move"},
-			{"l", "logs=" + logScope},
-			{"a", "add/edit"},
-			{"c", "config"},
-			{"r", "restart"},
-			{"s", "stop"},
-			{"q", "quit"},
+			{km.PrimaryLabel(ActionUp) + km.PrimaryLabel(ActionDown), "move"},
+			{km.PrimaryLabel(ActionLogScope), "logs=" + hs.logScope},
+			{km.PrimaryLabel(ActionFollow), hs.follow},
+			{km.PrimaryLabel(ActionLayout), hs.layout},
+			{km.PrimaryLabel(ActionAdd), "add/edit"},
+			{km.PrimaryLabel(ActionConfig), "config"},
+			{km.PrimaryLabel(ActionPalette), "commands"},
+			{km.PrimaryLabel(ActionRestart), "restart"},
+			{km.PrimaryLabel(ActionStop), "stop"},
+			{km.PrimaryLabel(ActionQuit), "quit"},
 		}
 	} else {
 		chips = []chip{
-			{"↑↓/j/k", "move"},
-			{"l", "logs=" + logScope},
-			{"a", "add/edit"},
-			{"c", "config"},
-			{"r", "restart"},
-			{"^r", "restart all"},
-			{"s", "stop"},
-			{"q", "quit"},
-		}
+			{km.MoveLabel(), "move"},
+			{km.Label(ActionLogScope), "logs=" + hs.logScope},
+			{km.Label(ActionFollow), hs.follow},
+			{km.Label(ActionLayout), hs.layout},
+			{strings.Join([]string{
+				km.PrimaryLabel(ActionPageUp), km.PrimaryLabel(ActionPageDown),
+				km.PrimaryLabel(ActionTop), km.PrimaryLabel(ActionBottom),
+			}, "/"), "scroll"},
+			{km.Label(ActionAdd), "add/edit"},
+			{km.Label(ActionGroups), "groups"},
+			{km.Label(ActionConfig), "config"},
+			{km.Label(ActionPalette), "commands"},
+			{km.Label(ActionRestart), "restart"},
+			{km.Label(ActionRestartAll), "restart all"},
+			{km.Label(ActionStop), "stop"},
+			{km.Label(ActionCopy), "copy addr"},
+			{km.PrimaryLabel(ActionQuit), "quit"},
+		}
+	}
+
+	if hs.grouped {
+		chips = append(chips, chip{km.Label(ActionFold), "fold service"})
 	}
 
 	n := len(chips)
@@ -2343,13 +3319,13 @@ func helpLines(width int, logScope string) []string {
 	return balancedHelpLines(styled, widths, sepStyled, sepW, inner, minLines)
 }
 
-func renderHelp(width int, logScope string) string {
+func renderHelp(width int, hs helpState) string {
 	boxWidth := width
 	if boxWidth < 60 {
 		boxWidth = 60
 	}
 
-	help := strings.Join(helpLines(width, logScope), "\n")
+	help := strings.Join(helpLines(width, hs), "\n")
 
 	style := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
@@ -2423,16 +3399,34 @@ func greedyHelpLines(styled []string, widths []int, sepStyled string, sepW, inne
 func (u *UI) renderShutdownScreen() string {
 	frame := spinnerFrames[u.spinnerFrame%len(spinnerFrames)]
 
+	if u.tooSmall() {
+		text := fmt.Sprintf("%s Stopping…", frame)
+		return lipgloss.NewStyle().Foreground(colorAccentAlt).Bold(true).Render(truncateDisplay(text, u.width))
+	}
+
 	shutdownStyle := lipgloss.NewStyle().
 		Foreground(colorAccentAlt).
 		Bold(true)
 
+	text := shutdownStyle.Render(fmt.Sprintf("%s  Stopping services, please wait...", frame))
+	if len(u.services) > 0 {
+		names := make([]string, len(u.services))
+		for i, svc := range u.services {
+			names[i] = svc.Name
+		}
+		waiting := "Waiting for " + strings.Join(names, ", ")
+		if u.width > 0 {
+			waiting = truncateDisplay(waiting, max(u.width-12, 20))
+		}
+		text += "\n\n" + lipgloss.NewStyle().Foreground(colorMuted).Render(waiting)
+	}
+
 	box := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
 		BorderForeground(colorBorder).
 		Padding(1, 4).
 		Align(lipgloss.Center).
-		Render(shutdownStyle.Render(fmt.Sprintf("%s  Stopping services, please wait...", frame)))
+		Render(text)
 
 	if u.width <= 0 || u.height <= 0 {
 		return box
@@ -1,11 +1,19 @@
 package ui
 
 import (
+	"context"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
 
 	"github.com/alinemone/go-port-forward/internal/icons"
 	"github.com/alinemone/go-port-forward/internal/model"
+	"github.com/alinemone/go-port-forward/internal/storage"
 	"github.com/alinemone/go-port-forward/internal/theme"
 )
 
@@ -114,3 +122,468 @@ func TestRenderServiceTableShowsDefaultIconForUnknownPort(t *testing.T) {
 		t.Fatalf("expected default icon %q in output: %q", icons.DefaultGlyph, out)
 	}
 }
+
+func TestTruncateDisplayMeasuresCells(t *testing.T) {
+	// Each CJK character is two cells wide.
+	got := truncateDisplay("数据库服务器", 7)
+	if w := lipgloss.Width(got); w > 7 {
+		t.Fatalf("truncateDisplay width = %d, want <= 7 (%q)", w, got)
+	}
+	if !strings.HasSuffix(got, "...") {
+		t.Fatalf("expected ellipsis, got %q", got)
+	}
+	if got := truncateDisplay("db", 7); got != "db" {
+		t.Fatalf("short text changed: %q", got)
+	}
+}
+
+func TestRenderServiceTableAlignsWideNames(t *testing.T) {
+	out := renderServiceTable([]model.Service{
+		{Name: "db", LocalPort: "5432", Status: model.StatusHealthy},
+		{Name: "数据库", LocalPort: "6379", Status: model.StatusHealthy},
+		{Name: "🚀api", LocalPort: "8080", Status: model.StatusHealthy},
+	}, 0, 0, 10, 120)
+
+	col := -1
+	for _, line := range strings.Split(out, "\n") {
+		plain := ansi.Strip(line)
+		i := strings.Index(plain, "HEALTHY")
+		if i < 0 {
+			continue
+		}
+		c := lipgloss.Width(plain[:i])
+		if col == -1 {
+			col = c
+		} else if c != col {
+			t.Fatalf("STATUS column misaligned: %d vs %d\n%s", c, col, out)
+		}
+	}
+	if col == -1 {
+		t.Fatalf("no rows rendered: %q", out)
+	}
+}
+
+func TestRowCacheReusesUnchangedRows(t *testing.T) {
+	services := []model.Service{
+		{Name: "db", LocalPort: "5432", Status: model.StatusHealthy},
+		{Name: "api", LocalPort: "8080", Status: model.StatusConnecting},
+	}
+	var cache rowCache
+	first := renderServiceTableCached(&cache, services, 0, 0, 10, 120)
+	if len(cache.rows) != 2 {
+		t.Fatalf("cache holds %d rows, want 2", len(cache.rows))
+	}
+	dbKey := cache.rows["db"].key
+
+	services[1].Status = model.StatusHealthy
+	second := renderServiceTableCached(&cache, services, 0, 0, 10, 120)
+	if cache.rows["db"].key != dbKey {
+		t.Error("unchanged row should keep its cache entry")
+	}
+	if first == second {
+		t.Error("changed status should re-render its row")
+	}
+
+	renderServiceTableCached(&cache, services[:1], 0, 0, 10, 120)
+	if _, ok := cache.rows["api"]; ok {
+		t.Error("rows no longer shown should be evicted")
+	}
+}
+
+type fakeController struct {
+	states   []model.Service
+	groups   []model.GroupState
+	updates  chan struct{}
+	answered map[string]string
+	sockets  map[string][]model.Socket
+}
+
+func (f *fakeController) ListServiceStates() []model.Service                        { return f.states }
+func (f *fakeController) StartStoredService(ctx context.Context, name string) error { return nil }
+func (f *fakeController) StopService(name string)                                   {}
+func (f *fakeController) StopAllServices()                                          {}
+func (f *fakeController) RestartService(ctx context.Context, name string) error     { return nil }
+func (f *fakeController) RestartAllServices(ctx context.Context)                    {}
+func (f *fakeController) Updates() <-chan struct{}                                  { return f.updates }
+func (f *fakeController) GroupStates() []model.GroupState                           { return f.groups }
+func (f *fakeController) TrackGroup(name string, members []string)                  {}
+func (f *fakeController) SocketStats(name string) ([]model.Socket, error) {
+	return f.sockets[name], nil
+}
+func (f *fakeController) AnswerPrompt(name, answer string) error {
+	if f.answered == nil {
+		f.answered = map[string]string{}
+	}
+	f.answered[name] = answer
+	return nil
+}
+
+// newSizedUI builds a UI over a fake controller and delivers a window size so
+// the viewport is ready, as the first frame of a real session would.
+func newSizedUI(states []model.Service, width, height int) *UI {
+	u := NewUI(&fakeController{states: states, updates: make(chan struct{}, 1)}, context.Background())
+	u.Update(tea.WindowSizeMsg{Width: width, Height: height})
+	u.Update(stateChangedMsg{})
+	return u
+}
+
+func chattyService(lines int) model.Service {
+	logs := make([]model.LogEntry, lines)
+	for i := range logs {
+		logs[i] = model.LogEntry{Time: time.Unix(int64(i), 0), Message: fmt.Sprintf("line %d", i)}
+	}
+	return model.Service{Name: "db", LocalPort: "5432", Status: model.StatusHealthy, Logs: logs}
+}
+
+func TestLogFollowPausesWhenScrolledUp(t *testing.T) {
+	u := newSizedUI([]model.Service{chattyService(200)}, 120, 40)
+	if !u.logFollow || !u.viewport.AtBottom() {
+		t.Fatal("a fresh session should follow the newest log line")
+	}
+
+	u.Update(tea.KeyPressMsg{Code: tea.KeyPgUp})
+	if u.logFollow {
+		t.Fatal("scrolling up should pause follow mode")
+	}
+	offset := u.viewport.YOffset()
+	u.Update(stateChangedMsg{})
+	if u.viewport.YOffset() != offset {
+		t.Fatal("new content must not move a paused view")
+	}
+
+	u.Update(tea.KeyPressMsg{Code: tea.KeyEnd})
+	if !u.logFollow || !u.viewport.AtBottom() {
+		t.Fatal("End should jump to the bottom and resume following")
+	}
+}
+
+func TestLogFollowToggleKey(t *testing.T) {
+	u := newSizedUI([]model.Service{chattyService(200)}, 120, 40)
+	u.Update(tea.KeyPressMsg{Code: 'f', Text: "f"})
+	if u.logFollow {
+		t.Fatal("f should turn follow off")
+	}
+	u.Update(tea.KeyPressMsg{Code: tea.KeyHome})
+	u.Update(tea.KeyPressMsg{Code: 'f', Text: "f"})
+	if !u.logFollow || !u.viewport.AtBottom() {
+		t.Fatal("f should turn follow back on and jump to the bottom")
+	}
+}
+
+func TestStatusMarkerUsesFixedStatusColor(t *testing.T) {
+	out := renderLogEntry("db", model.LogEntry{
+		Time:    time.Unix(0, 0),
+		Message: "━━━━ HEALTHY → ERROR ━━━━",
+		Kind:    model.LogKindStatus,
+		Status:  model.StatusError,
+	}, 120)
+	// #FF6B6B = 255;107;107
+	if !strings.Contains(out, "255;107;107") {
+		t.Fatalf("ERROR marker should use the fixed error color: %q", out)
+	}
+}
+
+func TestGroupedLogsFoldSelectedService(t *testing.T) {
+	noisy := chattyService(5)
+	quiet := model.Service{Name: "api", Status: model.StatusHealthy, Logs: []model.LogEntry{
+		{Time: time.Unix(2, 500), Message: "api ready"},
+	}}
+	u := newSizedUI([]model.Service{quiet, noisy}, 120, 40)
+
+	u.Update(tea.KeyPressMsg{Code: tea.KeyTab})
+	if !u.logGrouped {
+		t.Fatal("tab should switch to the grouped layout")
+	}
+	u.Update(tea.KeyPressMsg{Code: tea.KeyDown})
+	u.Update(tea.KeyPressMsg{Code: 'z', Text: "z"})
+	if !u.logCollapsed["db"] {
+		t.Fatal("z should collapse the selected service's section")
+	}
+
+	out := ansi.Strip(renderGroupedLogsCached(nil, u.services, u.logCollapsed, 100))
+	if !strings.Contains(out, "api ready") {
+		t.Errorf("expanded service lines missing:\n%s", out)
+	}
+	if strings.Contains(out, "line 3") {
+		t.Errorf("collapsed service lines should be hidden:\n%s", out)
+	}
+	if !strings.Contains(out, "5 lines hidden") {
+		t.Errorf("collapsed header should show the hidden count:\n%s", out)
+	}
+}
+
+func TestSessionHeaderSummarizesServices(t *testing.T) {
+	services := []model.Service{
+		{Name: "api", Status: model.StatusHealthy, RestartCount: 2},
+		{Name: "db", Status: model.StatusHealthy},
+		{Name: "cache", Status: model.StatusError, RestartCount: 1},
+	}
+	out := ansi.Strip(renderSessionHeader(services, nil, time.Now().Add(-90*time.Second), time.Time{}, "backend", "prod", 200))
+	for _, want := range []string{"1m 30s", "2 healthy", "1 error", "3 reconnects", "run: backend", "ctx: prod"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("header missing %q: %q", want, out)
+		}
+	}
+	if strings.Contains(out, "connecting") {
+		t.Errorf("zero counts should be omitted: %q", out)
+	}
+
+	groups := []model.GroupState{model.NewGroupState("backend", []string{"api", "db", "cache"}, services)}
+	if out := ansi.Strip(renderSessionHeader(services, groups, time.Now(), time.Time{}, "backend", "", 200)); !strings.Contains(out, "▣ backend 2/3") {
+		t.Errorf("header missing the group's status: %q", out)
+	}
+
+	narrow := renderSessionHeader(services, nil, time.Now(), time.Time{}, "backend", "a-very-long-context-name", 60)
+	if w := lipgloss.Width(narrow); w > 60 {
+		t.Errorf("header width %d exceeds terminal width 60", w)
+	}
+}
+
+func TestSessionTTLCountsDownAndWarns(t *testing.T) {
+	services := []model.Service{{Name: "db", Status: model.StatusHealthy}}
+	out := ansi.Strip(renderSessionHeader(services, nil, time.Now(), time.Now().Add(2*time.Hour+30*time.Second), "", "", 200))
+	if !strings.Contains(out, "⏳ 2h 0m left") {
+		t.Errorf("header missing countdown: %q", out)
+	}
+
+	u := newSizedUI(services, 120, 40)
+	u.SetDeadline(time.Now().Add(time.Hour))
+	u.Update(tickMsg(time.Now()))
+	if u.editStatus != "" {
+		t.Fatalf("no warning expected an hour out, got %q", u.editStatus)
+	}
+
+	u.SetDeadline(time.Now().Add(TTLWarning - time.Second))
+	u.Update(tickMsg(time.Now()))
+	if !strings.Contains(u.editStatus, "all forwards stop in") {
+		t.Fatalf("expected TTL warning on the status line, got %q", u.editStatus)
+	}
+}
+
+func TestTinyTerminalUsesCondensedView(t *testing.T) {
+	services := []model.Service{
+		{Name: "a-service-with-a-long-name", LocalPort: "15432", Status: model.StatusHealthy},
+		{Name: "db", LocalPort: "5432", Status: model.StatusError},
+	}
+	for _, size := range [][2]int{{30, 8}, {59, 40}, {120, 10}, {1, 1}} {
+		u := newSizedUI(services, size[0], size[1])
+		out := u.viewContent()
+		lines := strings.Split(out, "\n")
+		if len(lines) > size[1] {
+			t.Errorf("%dx%d: %d lines exceed height", size[0], size[1], len(lines))
+		}
+		for _, line := range lines {
+			if w := lipgloss.Width(line); w > size[0] {
+				t.Errorf("%dx%d: line width %d exceeds width: %q", size[0], size[1], w, ansi.Strip(line))
+			}
+		}
+		if strings.ContainsAny(out, "╭╰│") {
+			t.Errorf("%dx%d: condensed view should have no borders", size[0], size[1])
+		}
+	}
+
+	u := newSizedUI(services, 40, 12)
+	if out := ansi.Strip(u.viewContent()); !strings.Contains(out, "db :5432") {
+		t.Errorf("condensed view should list services: %q", out)
+	}
+
+	u.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	if out := u.viewContent(); !strings.Contains(out, "╭") {
+		t.Error("full layout should come back once the terminal is large enough")
+	}
+}
+
+func TestServiceTableMarksFlappingDegradedAndMaintenance(t *testing.T) {
+	services := []model.Service{
+		{Name: "db", LocalPort: "15432", Status: model.StatusHealthy, FlappingUntil: time.Now().Add(time.Minute)},
+		{Name: "web", LocalPort: "8080", Status: model.StatusHealthy, FlappingUntil: time.Now().Add(-time.Minute)},
+	}
+	out := ansi.Strip(renderServiceTable(services, 0, 0, 10, 140))
+	if !strings.Contains(out, "↯ FLAPPING") {
+		t.Errorf("flapping service should be marked: %q", out)
+	}
+	if strings.Count(out, "FLAPPING") != 1 {
+		t.Errorf("badge should clear once the window has passed: %q", out)
+	}
+
+	services = append(services, model.Service{Name: "pg", LocalPort: "5433", Status: model.StatusHealthy, Degraded: true})
+	if out := ansi.Strip(renderServiceTable(services, 0, 0, 10, 140)); !strings.Contains(out, "● DEGRADED") {
+		t.Errorf("service on its fallback should be marked degraded: %q", out)
+	}
+
+	services[1].MaintenanceUntil = time.Now().Add(time.Hour)
+	if out := ansi.Strip(renderServiceTable(services, 0, 0, 10, 140)); !strings.Contains(out, "○ MAINTENANCE") {
+		t.Errorf("service under maintenance should be marked: %q", out)
+	}
+}
+
+func TestDetailShowsHistory(t *testing.T) {
+	svc := model.Service{Name: "db", LocalPort: "5432", Status: model.StatusHealthy}
+	if detail := ansi.Strip(renderServiceDetail(&svc, "47 reconnects today across sessions", 120)); !strings.Contains(detail, "History: 47 reconnects today") {
+		t.Errorf("detail should carry the history line: %q", detail)
+	}
+	if detail := ansi.Strip(renderServiceDetail(&svc, "", 120)); strings.Contains(detail, "History") {
+		t.Errorf("no history, no line: %q", detail)
+	}
+}
+
+func TestDetailShowsNextRestart(t *testing.T) {
+	svc := model.Service{Name: "db", LocalPort: "5432", Status: model.StatusHealthy}
+	if detail := ansi.Strip(renderServiceDetail(&svc, "", 120)); strings.Contains(detail, "Next restart") {
+		t.Errorf("no schedule, no line: %q", detail)
+	}
+	svc.NextRestart = time.Now().Add(5*time.Hour + 30*time.Second)
+	want := "Next restart: " + svc.NextRestart.Format("Mon 15:04 MST") + " (in 5h"
+	if detail := ansi.Strip(renderServiceDetail(&svc, "", 120)); !strings.Contains(detail, want) {
+		t.Errorf("detail should carry %q: %q", want, detail)
+	}
+}
+
+func TestThrottledOutputIsMarked(t *testing.T) {
+	svc := model.Service{Name: "db", LocalPort: "5432", Status: model.StatusHealthy, OutputDropped: 6, ThrottledUntil: time.Now().Add(time.Minute)}
+	if out := ansi.Strip(renderServiceTable([]model.Service{svc}, 0, 0, 10, 140)); !strings.Contains(out, "db ≋") {
+		t.Errorf("throttled service should get a badge: %q", out)
+	}
+	if detail := ansi.Strip(renderServiceDetail(&svc, "", 120)); !strings.Contains(detail, "Output: 6 lines dropped") || !strings.Contains(detail, "(throttled now)") {
+		t.Errorf("detail should count the dropped lines: %q", detail)
+	}
+
+	svc.ThrottledUntil = time.Now().Add(-time.Minute)
+	if out := ansi.Strip(renderServiceTable([]model.Service{svc}, 0, 0, 10, 140)); strings.Contains(out, "≋") {
+		t.Errorf("badge should clear once the output calms down: %q", out)
+	}
+}
+
+func TestServiceTableShowsResolvedAddress(t *testing.T) {
+	services := []model.Service{
+		{Name: "db", LocalPort: "15432", MainPort: "5432", Target: "svc/postgres", Status: model.StatusHealthy},
+		{Name: "web", LocalPort: "8080", MainPort: "80", BindAddress: "0.0.0.0", Status: model.StatusHealthy},
+	}
+
+	wide := ansi.Strip(renderServiceTable(services, 0, 0, 10, 140))
+	for _, want := range []string{"ADDRESS", "127.0.0.1:15432 → svc/postgres:5432", "0.0.0.0:8080 → 80"} {
+		if !strings.Contains(wide, want) {
+			t.Errorf("wide table missing %q:\n%s", want, wide)
+		}
+	}
+
+	compact := ansi.Strip(renderServiceTable(services, 0, 0, 10, 80))
+	if strings.Contains(compact, "→") || !strings.Contains(compact, "15432") {
+		t.Errorf("compact table should show only the local port:\n%s", compact)
+	}
+
+	ns := forwardLabel(&model.Service{LocalPort: "15432", MainPort: "5432", Target: "svc/postgres", Namespace: "payments"})
+	if ns != "127.0.0.1:15432 → svc/postgres:5432 @ payments" {
+		t.Errorf("forwardLabel with namespace = %q", ns)
+	}
+	relayed := forwardLabel(&model.Service{LocalPort: "8080", MainPort: "80", Target: "svc/api", Relay: "127.0.0.1:8081"})
+	if relayed != "127.0.0.1:8080 → svc/api:80 via 127.0.0.1:8081" {
+		t.Errorf("forwardLabel with a relay = %q", relayed)
+	}
+	proxy := forwardLabel(&model.Service{LocalPort: "8001", MainPort: "8001", APIProxy: true})
+	if proxy != "127.0.0.1:8001 → kube-api" {
+		t.Errorf("forwardLabel for kubectl proxy = %q", proxy)
+	}
+	if mon := forwardLabel(&model.Service{Monitor: "db /healthz"}); mon != "monitor db /healthz" {
+		t.Errorf("forwardLabel for a monitor = %q", mon)
+	}
+	alt := forwardLabel(&model.Service{LocalPort: "5432", MainPort: "5432", Target: "db-replica", Endpoint: 2, Endpoints: 3})
+	if alt != "127.0.0.1:5432 → db-replica:5432 (2/3)" {
+		t.Errorf("forwardLabel on an alternate = %q", alt)
+	}
+
+	if got := localAddress(&model.Service{LocalPort: "9000", BindAddress: "::1"}); got != "[::1]:9000" {
+		t.Errorf("localAddress(::1) = %q, want [::1]:9000", got)
+	}
+}
+
+func TestNewGroupFromRunningServices(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", t.TempDir())
+	st := storage.NewStorage()
+	for name, port := range map[string]string{"api": "9000", "db": "5432", "idle": "6379"} {
+		if err := st.AddService(name, "kubectl port-forward svc/"+name+" "+port+":"+port); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u := newSizedUI([]model.Service{
+		{Name: "api", LocalPort: "9000", Status: model.StatusHealthy},
+		{Name: "db", LocalPort: "5432", Status: model.StatusHealthy},
+	}, 120, 40)
+	u.Update(tea.KeyPressMsg{Code: 'g', Text: "g"})
+	if !u.manageMode {
+		t.Fatal("g should open the group overlay")
+	}
+
+	u.Update(tea.KeyPressMsg{Code: 'g', Mod: tea.ModCtrl})
+	if u.groupFormMode != "new" {
+		t.Fatal("ctrl+g should open the new-group form")
+	}
+	if !u.groupFormSelected["api"] || !u.groupFormSelected["db"] || u.groupFormSelected["idle"] {
+		t.Fatalf("running services should be pre-selected, got %v", u.groupFormSelected)
+	}
+
+	for _, r := range "web" {
+		u.Update(tea.KeyPressMsg{Code: r, Text: string(r)})
+	}
+	u.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+
+	members, err := st.GetGroupServices("web")
+	if err != nil {
+		t.Fatalf("group not saved: %v", err)
+	}
+	if strings.Join(members, ",") != "api,db" {
+		t.Errorf("members = %v, want [api db]", members)
+	}
+}
+
+func TestStopAsksForConfirmation(t *testing.T) {
+	u := newSizedUI([]model.Service{chattyService(3)}, 120, 40)
+
+	u.Update(tea.KeyPressMsg{Code: 's', Text: "s"})
+	if u.confirmRun == nil || !strings.Contains(u.confirmPrompt, "db") {
+		t.Fatalf("s should ask before stopping, prompt %q", u.confirmPrompt)
+	}
+	if !strings.Contains(ansi.Strip(u.viewContent()), "Stop 'db'?") {
+		t.Error("the prompt should be rendered")
+	}
+	u.Update(tea.KeyPressMsg{Code: 'n', Text: "n"})
+	if u.confirmRun != nil {
+		t.Fatal("n should cancel the prompt")
+	}
+
+	u.Update(tea.KeyPressMsg{Code: 'q', Text: "q"})
+	if u.quitting {
+		t.Fatal("q with running services should ask first")
+	}
+	u.Update(tea.KeyPressMsg{Code: 'y', Text: "y"})
+	if !u.quitting {
+		t.Fatal("y should confirm the quit")
+	}
+}
+
+func TestNoConfirmActsImmediately(t *testing.T) {
+	u := newSizedUI([]model.Service{chattyService(3)}, 120, 40)
+	u.SetConfirm(ConfirmOptions{})
+
+	u.Update(tea.KeyPressMsg{Code: 'q', Text: "q"})
+	if u.confirmRun != nil || !u.quitting {
+		t.Fatal("with prompts off, q should quit straight away")
+	}
+}
+
+func TestCancelledContextShutsDownUI(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	u := NewUI(&fakeController{updates: make(chan struct{})}, ctx)
+	u.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+
+	cancel()
+	msg := u.waitForUpdate()()
+	if _, ok := msg.(ctxDoneMsg); !ok {
+		t.Fatalf("waiter returned %T after cancel, want ctxDoneMsg", msg)
+	}
+	if _, cmd := u.Update(msg); cmd == nil || !u.quitting {
+		t.Fatal("a cancelled context should start the shutdown without a key press")
+	}
+}